@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gowiki/internal/api"
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// Administration commands that open the database directly, for setting up
+// a wiki before anyone can sign in or when the server is down. They read
+// the same configuration as the server.
+//
+//	wiki user create --username ada --email ada@example.com --role admin
+//	wiki token create --user ada --scopes read,write
+//	wiki backup
+
+// openCLIDatabase opens the configured database for an offline command.
+// The caller closes it.
+func openCLIDatabase() (*config.Config, *database.DB, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Opening a missing file would create an empty database
+	if cfg.Database.Driver == database.DriverSQLite {
+		if _, err := os.Stat(cfg.Database.Path); err != nil {
+			return nil, nil, fmt.Errorf("no database at %s: %w\nSet WIKI_DB_PATH to the wiki's database, or start the server once to create it.", cfg.Database.Path, err)
+		}
+	}
+
+	db, err := database.New(&cfg.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, db, nil
+}
+
+// auditCLI records an offline command in the audit log, with "cli" in
+// place of an IP address.
+func auditCLI(ctx context.Context, db *database.DB, action, targetType string, targetID *int64, details map[string]interface{}) {
+	b, _ := json.Marshal(details)
+	if err := db.LogAudit(ctx, nil, action, targetType, targetID, string(b), "cli"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", err)
+	}
+}
+
+const userUsage = `Usage:
+  wiki user create --username NAME --email EMAIL [--role ROLE] [--password-stdin]
+
+Creates an account in the database. Without --password-stdin a random
+password is generated and printed, and the user must change it at their
+first sign-in.
+`
+
+func runUserCommand(args []string) int {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(os.Stderr, userUsage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := fs.String("username", "", "username")
+	email := fs.String("email", "", "email address")
+	role := fs.String("role", "", "role (defaults to WIKI_DEFAULT_ROLE)")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	positional, err := parseCLIFlags(fs, args[1:])
+	if err != nil || len(positional) > 0 || *username == "" || *email == "" {
+		fmt.Fprint(os.Stderr, userUsage)
+		return exitUsage
+	}
+
+	if err := userCreate(*username, *email, *role, *passwordStdin); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func userCreate(username, email, role string, passwordStdin bool) error {
+	cfg, db, err := openCLIDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := services.NewRoleService(db).Load(ctx); err != nil {
+		return fmt.Errorf("failed to load roles: %w", err)
+	}
+	settings := services.NewSettingsService(db, cfg)
+	if role == "" {
+		role = string(settings.DefaultRole(ctx))
+	}
+	if !models.Role(role).IsValid() {
+		return fmt.Errorf("unknown role %q", role)
+	}
+
+	password, generated := "", !passwordStdin
+	if passwordStdin {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	} else if password, err = services.GeneratePassword(); err != nil {
+		return err
+	}
+
+	user, err := services.NewAuthService(db, cfg, settings).CreateUser(ctx, models.UserCreate{
+		Username: username,
+		Email:    email,
+		Password: password,
+		Role:     models.Role(role),
+	})
+	if err != nil {
+		return err
+	}
+	if generated {
+		mustChange := true
+		if err := db.UpdateUser(ctx, user.ID, &models.UserUpdate{MustChangePassword: &mustChange}); err != nil {
+			return fmt.Errorf("user created but not asked to change password: %w", err)
+		}
+	}
+	auditCLI(ctx, db, "user_create", "user", &user.ID, map[string]interface{}{
+		"username": user.Username,
+		"role":     user.Role,
+	})
+
+	fmt.Printf("Created %s (%s)\n", user.Username, user.Role)
+	if generated {
+		fmt.Printf("Password: %s\n", password)
+	}
+	return nil
+}
+
+const tokenUsage = `Usage:
+  wiki token create --user NAME [--name NAME] [--scopes read,write,shares] [--expires 720h]
+
+Creates an API token for a user and prints it; it can't be shown again.
+Use it as WIKI_API_TOKEN for the page, export and search commands.
+`
+
+// tokenScopes are the scopes a token can carry.
+var tokenScopes = map[string]bool{"read": true, "write": true, "shares": true, "admin": true}
+
+func runTokenCommand(args []string) int {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(os.Stderr, tokenUsage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+	username := fs.String("user", "", "username or email of the token's owner")
+	name := fs.String("name", "Command line", "token name")
+	scopes := fs.String("scopes", "read", "comma-separated scopes")
+	expires := fs.Duration("expires", 0, "lifetime (defaults to WIKI_API_TOKEN_EXPIRY)")
+	positional, err := parseCLIFlags(fs, args[1:])
+	if err != nil || len(positional) > 0 || *username == "" {
+		fmt.Fprint(os.Stderr, tokenUsage)
+		return exitUsage
+	}
+	for _, scope := range strings.Split(*scopes, ",") {
+		if !tokenScopes[strings.TrimSpace(scope)] {
+			fmt.Fprintf(os.Stderr, "Error: unknown scope %q\n\n%s", scope, tokenUsage)
+			return exitUsage
+		}
+	}
+
+	if err := tokenCreate(*username, *name, *scopes, *expires); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func tokenCreate(username, name, scopes string, expires time.Duration) error {
+	cfg, db, err := openCLIDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	user, err := db.GetUserByUsername(ctx, username)
+	if err == nil && user == nil {
+		user, err = db.GetUserByEmail(ctx, username)
+	}
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("no user %q", username)
+	}
+	if !user.CanSignIn() {
+		return fmt.Errorf("%s can't sign in; unlock or verify the account first", user.Username)
+	}
+	if expires <= 0 {
+		expires = cfg.Security.APITokenExpiry
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	rawToken := hex.EncodeToString(b)
+	token := &models.APIToken{
+		UserID:    user.ID,
+		TokenHash: api.HashToken(rawToken),
+		Name:      name,
+		Scopes:    strings.ReplaceAll(scopes, " ", ""),
+		ExpiresAt: time.Now().Add(expires),
+	}
+	if err := db.CreateAPIToken(ctx, token); err != nil {
+		return err
+	}
+	auditCLI(ctx, db, "api_token_create", "api_token", &token.ID, map[string]interface{}{
+		"name":   token.Name,
+		"scopes": token.Scopes,
+		"user":   user.Username,
+	})
+
+	fmt.Println(rawToken)
+	fmt.Fprintf(os.Stderr, "Created token %q for %s with scopes %s, expiring %s\n",
+		token.Name, user.Username, token.Scopes, token.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+const backupUsage = `Usage:
+  wiki backup [--list]
+
+Takes a snapshot of the SQLite database into WIKI_SNAPSHOT_PATH, applying
+the same retention as scheduled snapshots, and prints its path. It is safe
+while the server runs. --list prints the existing snapshots instead.
+`
+
+func runBackupCommand(args []string) int {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	list := fs.Bool("list", false, "list snapshots")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil || len(positional) > 0 {
+		fmt.Fprint(os.Stderr, backupUsage)
+		return exitUsage
+	}
+
+	if err := backup(*list); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func backup(list bool) error {
+	cfg, db, err := openCLIDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	scheduler, err := services.NewBackupScheduler(db, cfg, services.NewCluster(db, cfg))
+	if err != nil {
+		return err
+	}
+
+	if list {
+		snapshots, err := scheduler.List()
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			fmt.Printf("%s\t%d\t%s\n", s.Name, s.Size, s.CreatedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	snapshot, err := scheduler.Run(context.Background(), "cli", nil, "cli")
+	if errors.Is(err, services.ErrSnapshotInProgress) {
+		return errors.New("another snapshot is being taken; try again shortly")
+	}
+	if err != nil {
+		return err
+	}
+	path, err := scheduler.Path(snapshot.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	return nil
+}
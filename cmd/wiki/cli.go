@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripting commands that talk to a running server through the REST API.
+// They authenticate with an API token from WIKI_API_TOKEN and find the
+// server through WIKI_API_URL, falling back to WIKI_SITE_URL.
+//
+//	wiki page create --slug x --file y.md
+//	wiki page get x
+//	wiki page put x --file y.md
+//	wiki page update x --file y.md
+//	wiki page delete x
+//	wiki page list --tag docs
+//	wiki export x --format zip
+//	wiki search "query"
+
+// cliCommands maps a first argument to the command it runs instead of the
+// server. Each returns the process exit code.
+var cliCommands = map[string]func(args []string) int{
+	"backup": runBackupCommand,
+	"config": runConfigCommand,
+	"doctor": runDoctorCommand,
+	"export": runExportCommand,
+	"page":   runPageCommand,
+	"search": runSearchCommand,
+	"token":  runTokenCommand,
+	"user":   runUserCommand,
+}
+
+// Exit codes for CLI commands.
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+const pageUsage = `Usage:
+  wiki page create --slug SLUG --file FILE [--title TITLE] [--tags a,b] [--draft]
+  wiki page get SLUG [--json]
+  wiki page put SLUG --file FILE [--title TITLE] [--tags a,b]
+  wiki page list [--tag TAG] [--author NAME] [--limit N] [--json]
+  wiki page update SLUG [--file FILE] [--title TITLE] [--tags a,b] [--publish|--draft]
+  wiki page delete SLUG
+
+put creates the page or replaces its content. FILE may be - to read from
+stdin. Set WIKI_API_TOKEN to an API token and WIKI_API_URL to the server
+address.
+`
+
+func runPageCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, pageUsage)
+		return exitUsage
+	}
+
+	client, err := newAPIClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	var run func(*apiClient, []string) error
+	switch args[0] {
+	case "create":
+		run = pageCreate
+	case "get":
+		run = pageGet
+	case "put":
+		run = pagePut
+	case "list":
+		run = pageList
+	case "update":
+		run = pageUpdate
+	case "delete":
+		run = pageDelete
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown page command %q\n\n%s", args[0], pageUsage)
+		return exitUsage
+	}
+
+	if err := run(client, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, "\n"+pageUsage)
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
+
+func pageCreate(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page create", flag.ContinueOnError)
+	slug := fs.String("slug", "", "page slug")
+	file := fs.String("file", "", "markdown file, or - for stdin")
+	title := fs.String("title", "", "page title (defaults to the first heading)")
+	tags := fs.String("tags", "", "comma-separated tags")
+	draft := fs.Bool("draft", false, "create the page unpublished")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *slug == "" && len(positional) == 1 {
+		*slug = positional[0]
+	}
+	if *slug == "" || *file == "" {
+		return fmt.Errorf("%w: --slug and --file are required", errUsage)
+	}
+
+	content, err := readContent(*file)
+	if err != nil {
+		return err
+	}
+	if *title == "" {
+		*title = titleFromMarkdown(content, *slug)
+	}
+
+	body := map[string]interface{}{
+		"slug":    *slug,
+		"title":   *title,
+		"content": content,
+	}
+	if *tags != "" {
+		body["tags"] = splitTags(*tags)
+	}
+
+	var page cliPage
+	if err := client.do(http.MethodPost, "/pages", body, &page); err != nil {
+		return err
+	}
+
+	if *draft {
+		if err := client.do(http.MethodPut, "/pages/"+url.PathEscape(page.Slug), map[string]interface{}{"is_published": false}, &page); err != nil {
+			return fmt.Errorf("page created but not unpublished: %w", err)
+		}
+	}
+
+	fmt.Printf("Created %s (%s)\n", page.Slug, client.pageURL(page.Slug))
+	return nil
+}
+
+func pageGet(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page get", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the full page as JSON")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: expected one slug", errUsage)
+	}
+
+	var page json.RawMessage
+	if err := client.do(http.MethodGet, "/pages/"+url.PathEscape(positional[0]), nil, &page); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(page)
+	}
+
+	var p cliPage
+	if err := json.Unmarshal(page, &p); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	fmt.Print(p.Content)
+	if !strings.HasSuffix(p.Content, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+// pagePut creates the page or, if it exists, replaces its content, so a
+// directory of markdown files can be synced with one command per file.
+func pagePut(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page put", flag.ContinueOnError)
+	file := fs.String("file", "", "markdown file, or - for stdin")
+	title := fs.String("title", "", "page title (defaults to the first heading for new pages)")
+	tags := fs.String("tags", "", "comma-separated tags, replacing the current ones")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *file == "" {
+		return fmt.Errorf("%w: expected one slug and --file", errUsage)
+	}
+	slug := positional[0]
+
+	content, err := readContent(*file)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"content": content}
+	if *title != "" {
+		body["title"] = *title
+	}
+	if *tags != "" {
+		body["tags"] = splitTags(*tags)
+	}
+
+	// The server creates pages PUT to a free slug, but needs a title for them
+	var page cliPage
+	err = client.do(http.MethodGet, "/pages/"+url.PathEscape(slug), nil, &page)
+	var apiErr *apiError
+	exists := err == nil
+	if !exists && (!errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound) {
+		return err
+	}
+	if !exists && *title == "" {
+		body["title"] = titleFromMarkdown(content, slug)
+	}
+
+	if err := client.do(http.MethodPut, "/pages/"+url.PathEscape(slug), body, &page); err != nil {
+		return err
+	}
+	if exists {
+		fmt.Printf("Updated %s\n", page.Slug)
+	} else {
+		fmt.Printf("Created %s (%s)\n", page.Slug, client.pageURL(page.Slug))
+	}
+	return nil
+}
+
+func pageList(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page list", flag.ContinueOnError)
+	tag := fs.String("tag", "", "only pages with this tag")
+	author := fs.String("author", "", "only pages by this author")
+	limit := fs.Int("limit", 0, "maximum number of pages (default all)")
+	asJSON := fs.Bool("json", false, "print pages as JSON")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, positional[0])
+	}
+
+	// Fetch every page of results unless a limit is given
+	const batch = 100
+	var pages []json.RawMessage
+	for offset := 0; *limit <= 0 || len(pages) < *limit; offset += batch {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(batch))
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("order_by", "title")
+		query.Set("order_dir", "asc")
+		if *tag != "" {
+			query.Set("tag", *tag)
+		}
+		if *author != "" {
+			query.Set("author", *author)
+		}
+
+		var chunk []json.RawMessage
+		if err := client.do(http.MethodGet, "/pages?"+query.Encode(), nil, &chunk); err != nil {
+			return err
+		}
+		pages = append(pages, chunk...)
+		if len(chunk) < batch {
+			break
+		}
+	}
+	if *limit > 0 && len(pages) > *limit {
+		pages = pages[:*limit]
+	}
+
+	if *asJSON {
+		raw, err := json.Marshal(pages)
+		if err != nil {
+			return err
+		}
+		return printJSON(raw)
+	}
+	for _, raw := range pages {
+		var p cliPage
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return fmt.Errorf("unexpected response: %w", err)
+		}
+		fmt.Printf("%s\t%s\n", p.Slug, p.Title)
+	}
+	return nil
+}
+
+func pageUpdate(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page update", flag.ContinueOnError)
+	file := fs.String("file", "", "markdown file, or - for stdin")
+	title := fs.String("title", "", "new title")
+	tags := fs.String("tags", "", "comma-separated tags, replacing the current ones")
+	publish := fs.Bool("publish", false, "publish the page")
+	draft := fs.Bool("draft", false, "unpublish the page")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: expected one slug", errUsage)
+	}
+	if *publish && *draft {
+		return fmt.Errorf("%w: --publish and --draft can't be combined", errUsage)
+	}
+
+	body := map[string]interface{}{}
+	if *file != "" {
+		content, err := readContent(*file)
+		if err != nil {
+			return err
+		}
+		body["content"] = content
+	}
+	if *title != "" {
+		body["title"] = *title
+	}
+	if *tags != "" {
+		body["tags"] = splitTags(*tags)
+	}
+	if *publish || *draft {
+		body["is_published"] = *publish
+	}
+	if len(body) == 0 {
+		return fmt.Errorf("%w: nothing to update", errUsage)
+	}
+
+	var page cliPage
+	if err := client.do(http.MethodPut, "/pages/"+url.PathEscape(positional[0]), body, &page); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s\n", page.Slug)
+	return nil
+}
+
+func pageDelete(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("page delete", flag.ContinueOnError)
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("%w: expected one slug", errUsage)
+	}
+
+	if err := client.do(http.MethodDelete, "/pages/"+url.PathEscape(positional[0]), nil, nil); err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted %s\n", positional[0])
+	return nil
+}
+
+const searchUsage = `Usage:
+  wiki search QUERY [--limit N] [--json]
+
+Prints one "slug<TAB>title" line per result.
+`
+
+func runSearchCommand(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "maximum number of results (1-100)")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil || len(positional) == 0 {
+		fmt.Fprint(os.Stderr, searchUsage)
+		return exitUsage
+	}
+
+	client, err := newAPIClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	query := url.Values{}
+	query.Set("q", strings.Join(positional, " "))
+	query.Set("limit", strconv.Itoa(*limit))
+
+	var results json.RawMessage
+	if err := client.do(http.MethodGet, "/search?"+query.Encode(), nil, &results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if *asJSON {
+		if err := printJSON(results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	var hits []struct {
+		Slug  string `json:"slug"`
+		Title string `json:"title"`
+	}
+	if err := json.Unmarshal(results, &hits); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: unexpected response: %v\n", err)
+		return exitError
+	}
+	for _, hit := range hits {
+		fmt.Printf("%s\t%s\n", hit.Slug, hit.Title)
+	}
+	return exitOK
+}
+
+const exportUsage = `Usage:
+  wiki export SLUG [--format md|html|zip] [--output FILE]
+
+Downloads a page as markdown, standalone HTML, or a zip of the page and
+its subpages. Writes to stdout unless --output is given; - for FILE also
+means stdout, and --output with a directory saves under the server's
+file name.
+`
+
+func runExportCommand(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "md", "md, html or zip")
+	output := fs.String("output", "-", "file to write")
+	fs.StringVar(output, "o", "-", "shorthand for --output")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil || len(positional) != 1 {
+		fmt.Fprint(os.Stderr, exportUsage)
+		return exitUsage
+	}
+
+	client, err := newAPIClient()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	query := url.Values{}
+	query.Set("format", *format)
+	data, filename, err := client.download("/pages/" + url.PathEscape(positional[0]) + "/export?" + query.Encode())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		path := *output
+		if info, statErr := os.Stat(path); statErr == nil && info.IsDir() && filename != "" {
+			path = filepath.Join(path, filepath.Base(filename))
+		}
+		if err = os.WriteFile(path, data, 0o644); err == nil {
+			fmt.Fprintf(os.Stderr, "Saved %s\n", path)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+var errUsage = errors.New("invalid arguments")
+
+// apiError is an error response from the server.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+// cliPage holds the page fields the CLI prints.
+type cliPage struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// apiClient calls the REST API with an API token.
+type apiClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newAPIClient() (*apiClient, error) {
+	token := os.Getenv("WIKI_API_TOKEN")
+	if token == "" {
+		return nil, errors.New("WIKI_API_TOKEN is not set; create a token on the /tokens page")
+	}
+
+	baseURL := os.Getenv("WIKI_API_URL")
+	if baseURL == "" {
+		baseURL = os.Getenv("WIKI_SITE_URL")
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	return &apiClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *apiClient) pageURL(slug string) string {
+	return c.baseURL + "/wiki/" + slug
+}
+
+// do sends a request to /api/v1 and decodes the data field of the response
+// into out.
+func (c *apiClient) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	data, _, err := c.send(method, path, reader, body != nil)
+	if err != nil {
+		return err
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return fmt.Errorf("unexpected response: %w", err)
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// download fetches a file from /api/v1 and returns it with the file name
+// the server suggests.
+func (c *apiClient) download(path string) ([]byte, string, error) {
+	data, header, err := c.send(http.MethodGet, path, nil, false)
+	if err != nil {
+		return nil, "", err
+	}
+	_, params, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	return data, params["filename"], nil
+}
+
+// send makes a request to /api/v1 and returns the response body and
+// headers, turning error responses into errors.
+func (c *apiClient) send(method, path string, body io.Reader, isJSON bool) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(method, c.baseURL+"/api/v1"+path, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if isJSON {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		msg := "server returned " + resp.Status
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			msg = fmt.Sprintf("%s (HTTP %d)", apiErr.Error, resp.StatusCode)
+		}
+		return nil, nil, &apiError{Status: resp.StatusCode, Message: msg}
+	}
+	return data, resp.Header, nil
+}
+
+// parseCLIFlags parses flags that may appear before or after positional
+// arguments and returns the positional ones.
+func parseCLIFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func readContent(file string) (string, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return string(data), nil
+}
+
+// titleFromMarkdown returns the first level-one heading, or fallback.
+func titleFromMarkdown(content, fallback string) string {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(line[2:])
+		}
+	}
+	return fallback
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func printJSON(raw json.RawMessage) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(os.Stdout)
+	return err
+}
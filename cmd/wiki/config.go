@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gowiki/internal/config"
+)
+
+const configUsage = `Usage:
+  wiki config print [--config FILE]
+
+Prints the effective configuration as a YAML config file, with where each
+value came from: the environment, the config file (WIKI_CONFIG or
+--config) or the default. Secrets are redacted. Exits 1 if the
+configuration is invalid.
+`
+
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	file := fs.String("config", "", "config file (defaults to WIKI_CONFIG)")
+	positional, err := parseCLIFlags(fs, args[1:])
+	if err != nil || len(positional) > 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		return exitUsage
+	}
+	if *file != "" {
+		os.Setenv("WIKI_CONFIG", *file)
+	}
+
+	settings, err := config.Effective()
+	if settings == nil && err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range settings {
+		if seen[s.Key] {
+			continue
+		}
+		seen[s.Key] = true
+		fmt.Printf("%s: %s # %s\n", s.Key, yamlValue(s.Value), s.Source)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// yamlValue quotes value when YAML would otherwise read it differently.
+func yamlValue(value string) string {
+	if value == "" || value != strings.TrimSpace(value) || strings.ContainsAny(value, ":#'\"[]{},&*!|>%@`") {
+		return strconv.Quote(value)
+	}
+	return value
+}
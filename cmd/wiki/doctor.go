@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"gowiki/internal/services"
+)
+
+const doctorUsage = `Usage:
+  wiki doctor [--fix]
+
+Checks the database, directories, secret key and clock with the same
+configuration as the server and prints how to fix what it finds. Exits 1
+if any check fails. --fix recreates missing search index triggers.
+`
+
+func runDoctorCommand(args []string) int {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "repair problems that are safe to fix automatically")
+	positional, err := parseCLIFlags(fs, args)
+	if err != nil || len(positional) > 0 {
+		fmt.Fprint(os.Stderr, doctorUsage)
+		return exitUsage
+	}
+
+	cfg, db, err := openCLIDatabase()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if *fix {
+		if missing, err := db.MissingFTSTriggers(ctx); err == nil && len(missing) > 0 {
+			if err := db.RepairFTSTriggers(ctx); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return exitError
+			}
+			fmt.Println("Recreated search triggers and rebuilt the search index")
+		}
+	}
+
+	failed := false
+	for _, check := range services.RunDoctor(ctx, db, cfg) {
+		printDoctorCheck(check)
+		if check.Status == services.CheckFail {
+			failed = true
+		}
+	}
+	if failed {
+		return exitError
+	}
+	return exitOK
+}
+
+func printDoctorCheck(check services.DoctorCheck) {
+	label := "ok"
+	switch check.Status {
+	case services.CheckWarn:
+		label = "warn"
+	case services.CheckFail:
+		label = "FAIL"
+	}
+
+	fmt.Printf("[%-4s] %s: %s\n", label, check.Name, check.Detail)
+	if check.Fix != "" {
+		fmt.Printf("       fix: %s\n", check.Fix)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"gowiki/internal/config"
+)
+
+// systemdListenFDsStart is the first file descriptor passed by systemd socket activation.
+const systemdListenFDsStart = 3
+
+// listen opens the HTTP listener. A socket passed in by systemd is used when
+// present; otherwise the configured address is bound, optionally with
+// SO_REUSEPORT so a new process can bind while the old one is still draining.
+func listen(cfg *config.Config) (net.Listener, error) {
+	ln, err := systemdListener()
+	if err != nil {
+		return nil, err
+	}
+	if ln != nil {
+		fmt.Println("Using socket from systemd activation")
+		return ln, nil
+	}
+
+	lc := net.ListenConfig{}
+	if cfg.Server.ReusePort {
+		lc.Control = reusePortControl
+	}
+
+	ln, err = lc.Listen(context.Background(), "tcp", cfg.Address())
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", cfg.Address(), err)
+	}
+	return ln, nil
+}
+
+// systemdListener returns the first socket passed via LISTEN_FDS, or nil when
+// the process was not socket activated.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, nil
+	}
+
+	// Don't pass the sockets on to child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	file := os.NewFile(systemdListenFDsStart, "systemd-socket")
+	defer file.Close()
+
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePortControl reports that SO_REUSEPORT is unavailable on this platform.
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return errors.New("WIKI_REUSE_PORT is not supported on this platform")
+}
@@ -2,24 +2,36 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
-	echoMiddleware "github.com/labstack/echo/v4/middleware"
 
 	"gowiki/internal/api"
 	"gowiki/internal/config"
 	"gowiki/internal/database"
 	"gowiki/internal/handlers"
+	"gowiki/internal/i18n"
+	"gowiki/internal/jobs"
 	"gowiki/internal/middleware"
 	"gowiki/internal/services"
+	"gowiki/internal/tracing"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := cliCommands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -27,6 +39,15 @@ func main() {
 }
 
 func run() error {
+	restoreBackups := flag.Bool("restore-backups", false, "recreate pages from the markdown backup directory before starting")
+	restoreReplica := flag.Bool("restore-replica", false, "restore the database from the Litestream replica, then exit")
+	configFile := flag.String("config", "", "YAML or TOML config file; overrides WIKI_CONFIG")
+	flag.Parse()
+
+	if *configFile != "" {
+		os.Setenv("WIKI_CONFIG", *configFile)
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -35,11 +56,38 @@ func run() error {
 
 	fmt.Printf("Starting %s...\n", cfg.Site.Name)
 
+	// Catalogs in the locales directory add languages or override messages
+	if err := i18n.LoadDir(cfg.Site.LocalesDir); err != nil {
+		return fmt.Errorf("failed to load message catalogs: %w", err)
+	}
+	if _, ok := i18n.Supported(cfg.Site.Language); !ok {
+		return fmt.Errorf("WIKI_LANGUAGE %q has no message catalog", cfg.Site.Language)
+	}
+
 	// Create data directory if needed
 	if err := os.MkdirAll("./data", 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	// Pull the latest database from the replica; the old file is kept as .pre-restore
+	if *restoreReplica {
+		fmt.Println("Restoring database from replica...")
+		if err := services.RestoreReplica(cfg); err != nil {
+			return fmt.Errorf("failed to restore replica: %w", err)
+		}
+		fmt.Println("Database restored from replica")
+		return nil
+	}
+
+	// Swap in a staged snapshot restore before the database is opened
+	if cfg.Database.Driver == database.DriverSQLite {
+		if restored, err := services.ApplyPendingRestore(cfg.Database.Path); err != nil {
+			return fmt.Errorf("failed to restore snapshot: %w", err)
+		} else if restored {
+			fmt.Println("Restored database from staged snapshot")
+		}
+	}
+
 	// Initialize database
 	db, err := database.New(&cfg.Database)
 	if err != nil {
@@ -53,74 +101,257 @@ func run() error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// Report problems that would otherwise surface as obscure errors later
+	for _, check := range services.RunDoctor(ctx, db, cfg) {
+		if check.Status == services.CheckOK {
+			continue
+		}
+		if check.Blocking {
+			return fmt.Errorf("%s: %s. %s", check.Name, check.Detail, check.Fix)
+		}
+		fmt.Printf("WARNING: %s: %s\n", check.Name, check.Detail)
+		if check.Fix != "" {
+			fmt.Printf("  fix: %s\n", check.Fix)
+		}
+	}
+
 	// Check if setup is complete
 	setupComplete, _ := db.GetSetting(ctx, "setup_complete")
 	if setupComplete != "true" {
 		fmt.Println("Setup not complete - waiting for admin to complete setup at /setup")
 	}
 
-	// Load persisted settings into config
-	if requireAuth, _ := db.GetSetting(ctx, "require_auth"); requireAuth == "true" {
-		cfg.Site.RequireAuth = true
-	}
-	if siteName, _ := db.GetSetting(ctx, "site_name"); siteName != "" {
-		cfg.Site.Name = siteName
-	}
-	if allowReg, _ := db.GetSetting(ctx, "allow_registration"); allowReg == "true" {
-		cfg.Site.AllowRegistration = true
-	}
-	if defaultRole, _ := db.GetSetting(ctx, "default_role"); defaultRole != "" {
-		cfg.Site.DefaultRole = defaultRole
+	// Custom roles and their permissions
+	roles := services.NewRoleService(db)
+	if err := roles.Load(ctx); err != nil {
+		return fmt.Errorf("failed to load roles: %w", err)
 	}
 
-	// Rebuild FTS index to ensure search works for all existing pages
-	if err := db.RebuildFTSIndex(ctx); err != nil {
+	// Settings the admin dashboard changes, over the configured defaults
+	settings := services.NewSettingsService(db, cfg)
+
+	// Coordinate locks, leader election, and cache invalidation with other replicas
+	cluster := services.NewCluster(db, cfg)
+	cluster.Start()
+	defer cluster.Stop()
+
+	// Rebuild FTS index to ensure search works for all existing pages.
+	// Replicas starting together rebuild it only once.
+	err = cluster.WithLock(ctx, "fts_rebuild", 5*time.Minute, func() error {
+		return db.RebuildFTSIndex(ctx)
+	})
+	if errors.Is(err, services.ErrLockHeld) {
+		fmt.Println("Skipping FTS rebuild: another replica is rebuilding the index")
+	} else if err != nil {
 		fmt.Printf("Warning: Failed to rebuild FTS index: %v\n", err)
 	}
 
 	// Initialize services
 	markdownService := services.NewMarkdownService()
-	authService := services.NewAuthService(db, cfg)
+	if cfg.Diagram.PlantUMLURL != "" {
+		markdownService.EnablePlantUML()
+	}
+	markdownService.SetMath(settings.Math(ctx))
+	authService := services.NewAuthService(db, cfg, settings)
 	wikiService := services.NewWikiService(db, markdownService)
+	wikiService.SetSlugLimits(services.SlugLimits{MaxDepth: cfg.Site.MaxSlugDepth, MaxLength: cfg.Site.MaxSlugLength})
+	auditor := services.NewAuditor(db, cfg)
+	authService.SetAuditor(auditor)
+	wikiService.SetAuditor(auditor)
 	backupService, err := services.NewBackupService(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to initialize backup service: %w", err)
 	}
+	if *restoreBackups {
+		if err := restoreFromBackups(ctx, db, wikiService, cfg.Backup.Path); err != nil {
+			return err
+		}
+	}
+
+	// Refresh stored HTML when the markdown rendering rules changed
+	err = cluster.WithLock(ctx, "markdown_rerender", 5*time.Minute, func() error {
+		count, err := wikiService.RerenderPages(ctx)
+		if count > 0 {
+			fmt.Printf("Re-rendered %d pages\n", count)
+		}
+		return err
+	})
+	if errors.Is(err, services.ErrLockHeld) {
+		fmt.Println("Skipping page re-render: another replica is re-rendering")
+	} else if err != nil {
+		fmt.Printf("Warning: Failed to re-render pages: %v\n", err)
+	}
+
+	// Rebuild the link graph behind the wanted pages report and redlinks
+	err = cluster.WithLock(ctx, "link_rebuild", 5*time.Minute, func() error {
+		return wikiService.RebuildLinkIndex(ctx)
+	})
+	if errors.Is(err, services.ErrLockHeld) {
+		fmt.Println("Skipping link index rebuild: another replica is rebuilding it")
+	} else if err != nil {
+		fmt.Printf("Warning: Failed to rebuild link index: %v\n", err)
+	}
+	replication, err := services.NewReplicationService(cfg, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to initialize replication: %w", err)
+	}
+	replication.Start()
+	defer replication.Stop()
+
+	backupScheduler, err := services.NewBackupScheduler(db, cfg, cluster)
+	if err != nil {
+		return fmt.Errorf("failed to initialize backup scheduler: %w", err)
+	}
+	backupScheduler.Start()
+	defer backupScheduler.Stop()
+
+	webhooks := services.NewWebhookService(db, cfg, cluster)
+	webhooks.Start()
+	defer webhooks.Stop()
+
+	privacy := services.NewPrivacyService(db, cfg, cluster)
+
+	announcements := services.NewAnnouncementService(db)
+	promotion := services.NewPromotionService(db, wikiService, cfg)
+
+	apiUsage := services.NewAPIUsageService(db)
+	apiUsage.Start()
+	defer apiUsage.Stop()
+
+	revisions := services.NewRevisionPruner(db, cfg, cluster)
+
+	// Periodic maintenance, listed under Admin → Jobs
+	jobScheduler := jobs.New(cluster.IsLeader)
+	if privacy.Enabled() {
+		jobScheduler.Register(privacy.Job())
+	}
+	if revisions.Enabled() {
+		jobScheduler.Register(revisions.Job())
+	}
+	jobScheduler.Register(services.NewPublishScheduler(wikiService, webhooks).Job())
+	for _, job := range services.NewCleanupService(db, cfg).Jobs() {
+		jobScheduler.Register(job)
+	}
+	jobScheduler.Start()
+	defer jobScheduler.Stop()
+
+	freshness := services.NewFreshnessService(db)
+	freshness.Start()
+	defer freshness.Stop()
+
+	mail := services.NewMailService(db, cfg, settings)
+	invites := services.NewInviteService(db, cfg, authService, mail)
+	accounts := services.NewAccountService(db, cfg, authService, mail)
+	groups := services.NewGroupService(db)
+	userImport := services.NewUserImportService(db, authService, invites, groups)
+
+	// OpenTelemetry trace export
+	if cfg.Tracing.Enabled {
+		tracer, err := tracing.Init(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = tracer.Shutdown(shutdownCtx)
+		}()
+		fmt.Printf("Tracing enabled: exporting to %s (sample ratio %.2f)\n", cfg.Tracing.Endpoint, cfg.Tracing.SampleRatio)
+	}
 
 	// Initialize Echo
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
+	e.IPExtractor, err = middleware.IPExtractor(cfg.Security.TrustedProxies)
+	if err != nil {
+		return err
+	}
 
 	// Session manager
-	sessionManager := middleware.NewSessionManager(cfg, authService)
+	sessionManager := middleware.NewSessionManager(cfg, db, authService)
 
 	// CSRF protection
 	csrf := middleware.NewCSRF(sessionManager)
+	// API requests authenticate with bearer tokens, which browsers never
+	// attach on their own, so they need no CSRF token
+	csrf.ExemptPrefix("/api/")
 
 	// Rate limiter
-	rateLimiter := middleware.NewRateLimiter(
+	rateLimiter, err := middleware.NewRateLimiter(
+		middleware.NewRateLimitStore(cfg.Security.RateLimitStore, db),
 		cfg.Security.RateLimitRequests,
 		cfg.Security.RateLimitWindow,
+		cfg.Security.RateLimitRoutes,
 	)
+	if err != nil {
+		return err
+	}
+
+	// Persisted IP bans and rate limit exemptions
+	accessControl, err := middleware.NewAccessControl(&cfg.Security)
+	if err != nil {
+		return err
+	}
+	ipFilter := middleware.NewIPFilter(db)
+	cluster.Subscribe(services.TopicIPRules, func(string) {
+		_ = ipFilter.Reload(context.Background())
+	})
+	cluster.Subscribe(services.TopicRoles, func(string) {
+		_ = roles.Load(context.Background())
+	})
+	// Page writes drop the cached sidebar tree, quick switcher and related
+	// pages indexes here and on the other replicas
+	db.OnPagesChanged(func() {
+		_ = cluster.Publish(context.Background(), services.TopicPages, "")
+	})
+	cluster.Subscribe(services.TopicPages, func(string) {
+		db.InvalidatePageTree()
+		wikiService.InvalidateQuickSearch()
+		wikiService.InvalidateRelatedPages()
+	})
+	// Setting writes drop the cached settings here and on the other replicas,
+	// then apply the new values
+	db.OnSettingsChanged(func(key string) {
+		_ = cluster.Publish(context.Background(), services.TopicSettings, key)
+		settings.Changed(key)
+	})
+	cluster.Subscribe(services.TopicSettings, func(key string) {
+		db.InvalidateSettings()
+		settings.Changed(key)
+	})
+	settings.OnChange(services.SettingMath, func() {
+		markdownService.SetMath(settings.Math(context.Background()))
+	})
+
+	// Tracks in-flight requests so shutdown can let saves finish
+	drainer := middleware.NewDrainer()
 
 	// Global middleware (order matters!)
-	e.Use(middleware.RequestID())       // Add request ID first for tracing
+	e.Use(drainer.Middleware())
+	e.Use(middleware.RequestID()) // Add request ID first for tracing
+	e.Use(middleware.Tracing())   // Start the request span before logging so logs carry the trace ID
 	e.Use(middleware.RecoveryMiddleware())
 	e.Use(middleware.RequestLogger())
-	e.Use(middleware.SecurityHeaders())
+	e.Use(middleware.SecurityHeaders(cfg.Security.CSPStrictReportOnly))
+	if accessControl != nil {
+		e.Use(accessControl.Middleware()) // Configured network and country restrictions
+	}
+	e.Use(ipFilter.Middleware())        // Reject bans early; exemptions apply to the rate limiter
 	e.Use(middleware.SetupRequired(db)) // Redirect to /setup if not complete
 	e.Use(rateLimiter.Middleware())
 	e.Use(sessionManager.AuthMiddleware())
+	e.Use(middleware.Localize(cfg.Site.Timezone, cfg.Site.Locale, cfg.Site.Language))
+	e.Use(middleware.Theme(wikiService.SiteTheme))
 	e.Use(csrf.Middleware())
 
-	// Gzip compression
-	e.Use(echoMiddleware.GzipWithConfig(echoMiddleware.GzipConfig{
-		Level: 5,
-		Skipper: func(c echo.Context) bool {
-			// Skip compression for small responses
-			return false
-		},
+	// Brotli or gzip compression for text responses worth compressing
+	e.Use(middleware.Compress(middleware.CompressConfig{
+		Encodings:   cfg.Server.Compression,
+		MinSize:     cfg.Server.CompressionMinSize,
+		Types:       cfg.Server.CompressionTypes,
+		GzipLevel:   cfg.Server.GzipLevel,
+		BrotliLevel: cfg.Server.BrotliLevel,
 	}))
 
 	// Static files with cache headers
@@ -133,17 +364,14 @@ func run() error {
 	})
 	staticGroup.Static("/", "static")
 
-	// Uploads (shorter cache since they can change)
-	e.Static("/uploads", cfg.Upload.Path)
-
 	// Initialize handlers
-	h := handlers.New(cfg, authService, wikiService, backupService, sessionManager)
+	h := handlers.New(cfg, authService, wikiService, backupService, backupScheduler, replication, cluster, webhooks, privacy, announcements, apiUsage, mail, invites, accounts, userImport, revisions, freshness, roles, groups, settings, sessionManager, rateLimiter, ipFilter, jobScheduler)
 
 	// Register routes
 	h.RegisterRoutes(e, sessionManager, csrf)
 
 	// Register API routes
-	api.RegisterRoutes(e, db, cfg, authService, wikiService)
+	api.RegisterRoutes(e, db, cfg, authService, wikiService, webhooks, announcements, apiUsage, promotion, freshness, settings, rateLimiter.Store())
 
 	// Custom error handler
 	e.HTTPErrorHandler = customErrorHandler
@@ -154,14 +382,37 @@ func run() error {
 		ReadTimeout:  cfg.Server.ReadTimeout,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
+	redirectServer, err := configureTLS(cfg, server)
+	if err != nil {
+		return err
+	}
+	listener, err := listen(cfg)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if server.TLSConfig != nil {
+		scheme = "https"
+		e.TLSListener = tls.NewListener(listener, server.TLSConfig)
+	} else {
+		e.Listener = listener
+	}
 
 	// Graceful shutdown
 	go func() {
-		fmt.Printf("Server listening on http://%s\n", cfg.Address())
+		fmt.Printf("Server listening on %s://%s\n", scheme, cfg.Address())
 		if err := e.StartServer(server); err != nil && err != http.ErrServerClosed {
 			fmt.Fprintf(os.Stderr, "Server error: %v\n", err)
 		}
 	}()
+	if redirectServer != nil {
+		go func() {
+			fmt.Printf("Redirecting http://%s to HTTPS\n", redirectServer.Addr)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fmt.Fprintf(os.Stderr, "HTTP redirect server error: %v\n", err)
+			}
+		}()
+	}
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -170,18 +421,64 @@ func run() error {
 
 	fmt.Println("\nShutting down server...")
 
+	// Fail health checks first so a load balancer or replacement process can take over
+	drainer.StartDraining()
+	if cfg.Server.DrainDelay > 0 {
+		fmt.Printf("Draining for %s before closing listener...\n", cfg.Server.DrainDelay)
+		time.Sleep(cfg.Server.DrainDelay)
+	}
+
 	// Graceful shutdown with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
-	if err := e.Shutdown(ctx); err != nil {
-		return fmt.Errorf("server shutdown error: %w", err)
+	if redirectServer != nil {
+		_ = redirectServer.Shutdown(ctx)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		fmt.Printf("Warning: server shutdown: %v\n", err)
+	}
+
+	// Shutdown stops waiting at its timeout, but handlers keep running; give
+	// slow requests such as large saves time to finish before closing the database
+	if active := drainer.Active(); active > 0 {
+		fmt.Printf("Waiting up to %s for %d in-flight requests...\n", cfg.Server.DrainTimeout, active)
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+		defer drainCancel()
+		if err := drainer.Wait(drainCtx); err != nil {
+			fmt.Printf("Warning: %d requests still running at exit\n", drainer.Active())
+		}
 	}
 
 	fmt.Println("Server stopped")
 	return nil
 }
 
+// restoreFromBackups recreates pages from the markdown backup tree, attributing
+// pages with unknown authors to the first account allowed to administer the wiki.
+func restoreFromBackups(ctx context.Context, db *database.DB, wikiService *services.WikiService, dir string) error {
+	adminID, err := db.FirstAdministratorID(ctx)
+	if err != nil {
+		return err
+	}
+	if adminID == 0 {
+		return errors.New("cannot restore backups: complete setup to create an admin account first")
+	}
+
+	fmt.Printf("Restoring pages from %s...\n", dir)
+	result, err := wikiService.RestoreFromBackup(ctx, dir, adminID)
+	if err != nil {
+		return fmt.Errorf("failed to restore backups: %w", err)
+	}
+
+	for _, f := range result.Failed {
+		fmt.Printf("Warning: Failed to restore %s\n", f)
+	}
+	fmt.Printf("Restored %d pages, skipped %d existing, %d failed\n",
+		len(result.Restored), len(result.Skipped), len(result.Failed))
+	return nil
+}
+
 // customErrorHandler handles HTTP errors.
 func customErrorHandler(err error, c echo.Context) {
 	code := http.StatusInternalServerError
@@ -194,25 +491,41 @@ func customErrorHandler(err error, c echo.Context) {
 		}
 	}
 
+	// Trace ID lets users report errors that can be matched to a trace
+	traceID := middleware.GetTraceID(c)
+
 	// For HTMX requests, return minimal HTML
+	ctx := c.Request().Context()
 	if c.Request().Header.Get("HX-Request") == "true" {
-		c.HTML(code, fmt.Sprintf(`<div class="text-red-600">%s</div>`, message))
+		c.HTML(code, fmt.Sprintf(`<div class="text-red-600">%s</div>`, i18n.T(ctx, message)))
 		return
 	}
 
 	// For API requests, return JSON
 	if c.Request().Header.Get("Accept") == "application/json" {
-		c.JSON(code, map[string]interface{}{
+		body := map[string]interface{}{
 			"error": message,
 			"code":  code,
-		})
+		}
+		if traceID != "" {
+			body["trace_id"] = traceID
+		}
+		c.JSON(code, body)
 		return
 	}
 
+	traceHTML := ""
+	if traceID != "" {
+		traceHTML = `<p class="trace">` + i18n.T(ctx, "Trace ID: %s", traceID) + `</p>`
+	}
+
+	// People read the page in their language; JSON clients get the original
+	message = i18n.T(ctx, message)
+
 	// For HTML requests, render error page
 	errorHTML := fmt.Sprintf(`
 <!DOCTYPE html>
-<html lang="en">
+<html lang="%s">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
@@ -224,17 +537,19 @@ func customErrorHandler(err error, c echo.Context) {
         .message { font-size: 1.5rem; color: #475569; margin: 1rem 0; }
         .link { color: #3b82f6; text-decoration: none; }
         .link:hover { text-decoration: underline; }
+        .trace { font-family: monospace; font-size: 0.8rem; color: #94a3b8; }
     </style>
 </head>
 <body>
     <div class="container">
         <p class="code">%d</p>
         <p class="message">%s</p>
-        <a href="/" class="link">← Back to Home</a>
+        <a href="/" class="link">← %s</a>
+        %s
     </div>
 </body>
 </html>
-`, code, message, code, message)
+`, i18n.Lang(ctx), code, message, code, message, i18n.T(ctx, "Back to Home"), traceHTML)
 
 	c.HTML(code, errorHTML)
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"gowiki/internal/config"
+)
+
+// configureTLS sets up server to serve HTTPS when a certificate or ACME
+// domains are configured. It returns the plain HTTP server that redirects
+// to HTTPS and answers ACME challenges, or nil when there is none.
+func configureTLS(cfg *config.Config, server *http.Server) (*http.Server, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil
+	}
+
+	redirect := http.Handler(httpsRedirect(cfg.Server.Port))
+	if len(cfg.Server.ACMEDomains) > 0 {
+		if err := os.MkdirAll(cfg.Server.ACMECacheDir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create ACME cache directory: %w", err)
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.Server.ACMEDomains...),
+			Cache:      autocert.DirCache(cfg.Server.ACMECacheDir),
+			Email:      cfg.Server.ACMEEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		redirect = manager.HTTPHandler(redirect)
+	} else {
+		certs := &certReloader{certFile: cfg.Server.TLSCert, keyFile: cfg.Server.TLSKey}
+		if _, err := certs.GetCertificate(nil); err != nil {
+			return nil, err
+		}
+		server.TLSConfig = &tls.Config{
+			GetCertificate: certs.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
+	}
+	server.TLSConfig.MinVersion = tls.VersionTLS12
+
+	if cfg.Server.HTTPRedirectAddr == "" {
+		return nil, nil
+	}
+	return &http.Server{
+		Addr:              cfg.Server.HTTPRedirectAddr,
+		Handler:           redirect,
+		ReadHeaderTimeout: 10 * time.Second,
+	}, nil
+}
+
+// httpsRedirect sends requests to the same host and path over HTTPS on
+// port. Methods other than GET and HEAD get 308 so clients repeat the body.
+func httpsRedirect(port int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		code := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			code = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	}
+}
+
+// certReloader serves a certificate from PEM files, loading it again when
+// either file changes so renewals by certbot or similar tools take effect
+// without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// certCheckInterval is how often the files are checked for changes.
+const certCheckInterval = time.Minute
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cert != nil && time.Since(r.checked) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	if r.cert != nil && !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		// Keep serving the old certificate while files are half written
+		if r.cert != nil {
+			fmt.Printf("Warning: failed to reload TLS certificate: %v\n", err)
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	if r.cert != nil {
+		fmt.Println("Reloaded TLS certificate")
+	}
+	r.cert, r.modTime = &cert, modTime
+	return r.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
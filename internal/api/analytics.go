@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// GetAnalytics reports page views over a period for dashboards.
+// Administrators get every page, other users the pages they created.
+func (h *Handlers) GetAnalytics(c echo.Context) error {
+	user := GetAPIUser(c)
+	days, _ := strconv.Atoi(c.QueryParam("days"))
+	days = services.ValidAnalyticsPeriod(days)
+
+	var authorID *int64
+	if !policy.CanViewAllAnalytics(user) {
+		authorID = &user.ID
+	}
+
+	report, err := h.freshness.Analytics(c.Request().Context(), days, authorID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load analytics")
+	}
+	return success(c, report)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// ListAnnouncements returns the announcements the caller should see:
+// unexpired, and not dismissed when authenticated.
+func (h *Handlers) ListAnnouncements(c echo.Context) error {
+	var userID int64
+	if user := GetAPIUser(c); user != nil {
+		userID = user.ID
+	}
+
+	announcements, err := h.announcements.Active(c.Request().Context(), userID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load announcements")
+	}
+	if announcements == nil {
+		announcements = []models.Announcement{}
+	}
+	return success(c, announcements)
+}
+
+// ListAllAnnouncements returns every announcement, including expired ones.
+func (h *Handlers) ListAllAnnouncements(c echo.Context) error {
+	announcements, err := h.announcements.List(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load announcements")
+	}
+	if announcements == nil {
+		announcements = []models.Announcement{}
+	}
+	return success(c, announcements)
+}
+
+// CreateAnnouncement publishes a new announcement.
+func (h *Handlers) CreateAnnouncement(c echo.Context) error {
+	user := GetAPIUser(c)
+
+	var req services.AnnouncementInput
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	announcement, err := h.announcements.Create(c.Request().Context(), user.ID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmptyAnnouncement),
+			errors.Is(err, services.ErrInvalidAnnouncement),
+			errors.Is(err, services.ErrAnnouncementExpiry):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create announcement")
+	}
+
+	return created(c, announcement)
+}
+
+// DeleteAnnouncement removes an announcement.
+func (h *Handlers) DeleteAnnouncement(c echo.Context) error {
+	if err := h.announcements.Delete(c.Request().Context(), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrAnnouncementNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "announcement not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete announcement")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// DismissAnnouncement hides an announcement for the authenticated user.
+func (h *Handlers) DismissAnnouncement(c echo.Context) error {
+	user := GetAPIUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+	}
+
+	if err := h.announcements.Dismiss(c.Request().Context(), user.ID, c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrAnnouncementNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "announcement not found")
+		case errors.Is(err, services.ErrAnnouncementPermanent):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to dismiss announcement")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
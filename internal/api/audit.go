@@ -0,0 +1,57 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// ListAuditLog returns audit log entries, newest first, filtered by user,
+// action, entity_type and a from/to date range. With format=csv it returns
+// every matching entry as a CSV download instead of a page.
+func (h *Handlers) ListAuditLog(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter, err := services.ParseAuditFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date: use YYYY-MM-DD")
+	}
+
+	if c.QueryParam("format") == "csv" {
+		entries, err := h.db.ListAuditEntries(ctx, filter)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to export audit log")
+		}
+		filename := fmt.Sprintf("audit-log-%s.csv", time.Now().UTC().Format("20060102"))
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+		c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Response().Header().Set("Cache-Control", "no-store")
+		c.Response().WriteHeader(http.StatusOK)
+		return services.WriteAuditCSV(c.Response(), entries)
+	}
+
+	limit, offset := pageParams(c)
+
+	total, err := h.db.CountAuditEntries(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count audit entries")
+	}
+
+	filter.Limit = limit
+	filter.Offset = offset
+	entries, err := h.db.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list audit entries")
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	return paginated(c, entries, total, limit, offset)
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+const (
+	// maxBatchOperations bounds the operations in one batch request.
+	maxBatchOperations = 1000
+	// defaultBatchComment is the revision comment of batch updates that
+	// don't give one.
+	defaultBatchComment = "API batch update"
+)
+
+// BatchRequest is a set of page writes applied in one transaction.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+	// Comment is recorded with the revisions of updated pages.
+	Comment string `json:"comment"`
+}
+
+// BatchOperation creates, updates or deletes one page. Creates take the
+// fields of CreatePageRequest and updates those of UpdatePageRequest, where
+// omitted fields keep their value. IfMatch optionally carries the page ETag
+// an update or delete expects, like the If-Match header.
+type BatchOperation struct {
+	Action      string   `json:"action"`
+	Slug        string   `json:"slug"`
+	Title       *string  `json:"title"`
+	Content     *string  `json:"content"`
+	Tags        []string `json:"tags"`
+	IsPublished *bool    `json:"is_published"`
+	IfMatch     string   `json:"if_match"`
+}
+
+// BatchResponse reports whether a batch was applied and the outcome of each
+// operation, in request order.
+type BatchResponse struct {
+	Applied bool          `json:"applied"`
+	Results []BatchResult `json:"results"`
+}
+
+// BatchResult is the outcome of one batch operation. Status is the HTTP
+// status the operation would have had as a single request; operations that
+// were valid but not applied because another failed have 424.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Action string `json:"action"`
+	Slug   string `json:"slug"`
+	Status int    `json:"status"`
+	ID     int64  `json:"id,omitempty"`
+	ETag   string `json:"etag,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchError fails a batch operation with an HTTP status.
+type batchError struct {
+	status  int
+	message string
+}
+
+func (e *batchError) Error() string { return e.message }
+
+// BatchPages creates, updates and deletes many pages in one transaction.
+// Every operation is checked first; if any fails, nothing is written and
+// the response is 422 with the error of each failed operation. Updates that
+// would change nothing are left out, as with PUT /pages/:slug.
+func (h *Handlers) BatchPages(c echo.Context) error {
+	user := GetAPIUser(c)
+	ctx := c.Request().Context()
+
+	var req BatchRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if len(req.Operations) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "operations are required")
+	}
+	if len(req.Operations) > maxBatchOperations {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("a batch may have at most %d operations", maxBatchOperations))
+	}
+
+	resp := BatchResponse{Results: make([]BatchResult, len(req.Operations))}
+	var writes []*models.PageBatchWrite
+	writeIndex := make(map[*models.PageBatchWrite]int)
+	seen := make(map[string]bool, len(req.Operations))
+	failed := false
+
+	for i, op := range req.Operations {
+		op.Action = strings.ToLower(strings.TrimSpace(op.Action))
+		op.Slug = strings.TrimSpace(op.Slug)
+		result := &resp.Results[i]
+		*result = BatchResult{Index: i, Action: op.Action, Slug: op.Slug}
+
+		w, status, err := h.planBatchOperation(c, user, op, seen)
+		if err != nil {
+			var be *batchError
+			if !errors.As(err, &be) {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to check operations")
+			}
+			result.Status, result.Error = be.status, be.message
+			failed = true
+			continue
+		}
+		result.Status = status
+		if w == nil {
+			continue
+		}
+		result.Slug = w.Page.Slug
+		seen[strings.ToLower(w.Page.Slug)] = true
+		writes = append(writes, w)
+		writeIndex[w] = i
+	}
+
+	if failed {
+		for i := range resp.Results {
+			if resp.Results[i].Error == "" {
+				resp.Results[i].Status = http.StatusFailedDependency
+				resp.Results[i].Error = "not applied because another operation failed"
+			}
+		}
+		return c.JSON(http.StatusUnprocessableEntity, successResponse{Data: resp})
+	}
+
+	comment := strings.TrimSpace(req.Comment)
+	if comment == "" {
+		comment = defaultBatchComment
+	}
+	if err := h.db.ApplyPageBatch(ctx, user.ID, comment, writes); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to apply batch")
+	}
+	resp.Applied = true
+
+	events := map[string]string{
+		models.BatchCreate: models.EventPageCreated,
+		models.BatchUpdate: models.EventPageUpdated,
+		models.BatchDelete: models.EventPageDeleted,
+	}
+	for _, w := range writes {
+		result := &resp.Results[writeIndex[w]]
+		result.ID = w.Page.ID
+		if w.Action != models.BatchDelete {
+			result.ETag = w.Page.ETag()
+		}
+
+		h.webhooks.EmitPage(ctx, events[w.Action], w.Page, user)
+		details := map[string]interface{}{"slug": w.Page.Slug, "batch": true}
+		if w.Action == models.BatchUpdate {
+			details["comment"] = comment
+		} else {
+			details["title"] = w.Page.Title
+		}
+		h.wikiService.Audit(ctx, "page_"+w.Action, "page", &w.Page.ID, details)
+	}
+
+	return success(c, resp)
+}
+
+// planBatchOperation checks one batch operation and prepares its write,
+// returning the status it will have. Unchanged updates have no write.
+// seen holds the slugs of earlier operations, which may not be repeated.
+func (h *Handlers) planBatchOperation(c echo.Context, user *models.User, op BatchOperation, seen map[string]bool) (*models.PageBatchWrite, int, error) {
+	ctx := c.Request().Context()
+
+	if op.Slug == "" && op.Action != models.BatchCreate {
+		return nil, 0, &batchError{http.StatusBadRequest, "slug is required"}
+	}
+
+	switch op.Action {
+	case models.BatchCreate:
+		if !policy.CanCreate(user) {
+			return nil, 0, &batchError{http.StatusForbidden, "insufficient permissions"}
+		}
+		if op.Title == nil || strings.TrimSpace(*op.Title) == "" {
+			return nil, 0, &batchError{http.StatusBadRequest, "title is required"}
+		}
+		slug := op.Slug
+		if slug == "" {
+			slug = services.Slugify(*op.Title)
+		}
+		if err := h.wikiService.SlugLimits().Check(slug); err != nil {
+			return nil, 0, &batchError{http.StatusBadRequest, err.Error()}
+		}
+		if seen[strings.ToLower(slug)] {
+			return nil, 0, &batchError{http.StatusBadRequest, "slug appears more than once in the batch"}
+		}
+		existing, err := h.db.GetPageBySlug(ctx, slug)
+		if err != nil {
+			return nil, 0, err
+		}
+		if existing != nil {
+			return nil, 0, &batchError{http.StatusConflict, "page with this slug already exists"}
+		}
+
+		page := &models.Page{
+			Slug:        slug,
+			Title:       *op.Title,
+			AuthorID:    user.ID,
+			IsPublished: true,
+		}
+		if op.Content != nil {
+			page.Content = *op.Content
+		}
+		if op.IsPublished != nil {
+			page.IsPublished = *op.IsPublished
+		}
+		if err := h.renderBatchPage(page); err != nil {
+			return nil, 0, err
+		}
+		return &models.PageBatchWrite{
+			Action: models.BatchCreate,
+			Page:   page,
+			Tags:   op.Tags,
+			Links:  h.batchLinks(page),
+		}, http.StatusCreated, nil
+
+	case models.BatchUpdate, models.BatchDelete:
+	default:
+		return nil, 0, &batchError{http.StatusBadRequest, "action must be create, update or delete"}
+	}
+
+	if seen[strings.ToLower(op.Slug)] {
+		return nil, 0, &batchError{http.StatusBadRequest, "slug appears more than once in the batch"}
+	}
+	page, err := h.db.GetPageBySlug(ctx, op.Slug)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !policy.CanView(user, page) {
+		return nil, 0, &batchError{http.StatusNotFound, "page not found"}
+	}
+	if op.IfMatch != "" && op.IfMatch != "*" && op.IfMatch != page.ETag() {
+		return nil, 0, &batchError{http.StatusPreconditionFailed, pageChangedMessage}
+	}
+
+	if op.Action == models.BatchDelete {
+		if !policy.CanDelete(user, page) {
+			return nil, 0, &batchError{http.StatusForbidden, "insufficient permissions"}
+		}
+		return &models.PageBatchWrite{Action: models.BatchDelete, Page: page}, http.StatusNoContent, nil
+	}
+
+	if page.IsArchived() {
+		return nil, 0, &batchError{http.StatusConflict, services.ErrPageArchived.Error()}
+	}
+	if !policy.CanEdit(user, page) {
+		return nil, 0, &batchError{http.StatusForbidden, "insufficient permissions"}
+	}
+	update := UpdatePageRequest{Title: op.Title, Content: op.Content, Tags: op.Tags, IsPublished: op.IsPublished}
+	if !pageChanged(page, update) {
+		return nil, http.StatusOK, nil
+	}
+
+	w := &models.PageBatchWrite{Action: models.BatchUpdate, Page: page, Tags: op.Tags, PreviousContent: page.Content}
+	if op.Title != nil {
+		page.Title = *op.Title
+	}
+	if op.Content != nil && *op.Content != page.Content {
+		page.Content = *op.Content
+		if err := h.renderBatchPage(page); err != nil {
+			return nil, 0, err
+		}
+		w.Links = h.batchLinks(page)
+	}
+	if op.IsPublished != nil {
+		page.IsPublished = *op.IsPublished
+	}
+	return w, http.StatusOK, nil
+}
+
+// renderBatchPage renders a batch page's content, excerpt and word count.
+func (h *Handlers) renderBatchPage(page *models.Page) error {
+	html, err := h.wikiService.RenderMarkdown(page.Slug, page.Content)
+	if err != nil {
+		return fmt.Errorf("failed to render content: %w", err)
+	}
+	page.ContentHTML = html
+	page.Excerpt = h.wikiService.Excerpt(page.Content)
+	page.WordCount = h.wikiService.WordCount(page.Content)
+	return nil
+}
+
+// batchLinks returns the links to index for a batch page, never nil so a
+// page whose links were all removed has them cleared.
+func (h *Handlers) batchLinks(page *models.Page) []models.PageLink {
+	links := h.wikiService.PageLinks(page.Slug, page.Content)
+	if links == nil {
+		links = []models.PageLink{}
+	}
+	return links
+}
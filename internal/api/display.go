@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/timefmt"
+)
+
+// timeFormat returns the formatter for the caller's timezone and locale.
+// Anonymous callers get the site defaults, with their Accept-Language
+// ignored as on the web.
+func (h *Handlers) timeFormat(c echo.Context) timefmt.Formatter {
+	return middleware.UserTimeFormatter(GetAPIUser(c), c.Request().Header.Get("Accept-Language"),
+		h.config.Site.Timezone, h.config.Site.Locale)
+}
+
+// displayTimes describes how the named timestamps display to the caller,
+// leaving out unset ones. Timestamps in responses stay in UTC; the display
+// spares clients converting and formatting them.
+func displayTimes(f timefmt.Formatter, times map[string]time.Time) models.TimeDisplays {
+	display := make(models.TimeDisplays, len(times))
+	for name, t := range times {
+		if t.IsZero() {
+			continue
+		}
+		display[name] = models.TimeDisplay{
+			Local:    f.ISO(t),
+			Text:     f.DateTime(t),
+			Relative: f.Relative(t),
+		}
+	}
+	return display
+}
+
+// pageTimes returns the display of a page's timestamps.
+func pageTimes(f timefmt.Formatter, page *models.Page) models.TimeDisplays {
+	return displayTimes(f, map[string]time.Time{
+		"created_at":   page.CreatedAt,
+		"updated_at":   page.UpdatedAt,
+		"published_at": page.PublishedAt.Time,
+		"publish_at":   page.PublishAt.Time,
+		"archived_at":  page.ArchivedAt.Time,
+	})
+}
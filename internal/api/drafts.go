@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// SaveDraftRequest changes a page's unpublished draft. Omitted fields keep
+// their value from the existing draft, or from the live page if there is
+// no draft yet.
+type SaveDraftRequest struct {
+	Title   *string  `json:"title"`
+	Content *string  `json:"content"`
+	Tags    []string `json:"tags"`
+}
+
+// PublishDraftRequest optionally describes the change a published draft
+// records in the page history.
+type PublishDraftRequest struct {
+	Comment string `json:"comment"`
+}
+
+// pageVariant serves the variant of page that the ?variant query parameter
+// asks for: the live page by default, or its unpublished draft, which only
+// editors can read. The draft variant carries the draft's title, content
+// and tags, and its updated_at is when the draft was last saved.
+func (h *Handlers) pageVariant(c echo.Context, page *models.Page) error {
+	user := GetAPIUser(c)
+	ctx := c.Request().Context()
+
+	switch c.QueryParam("variant") {
+	case "", "live":
+		if policy.CanEdit(user, page) {
+			if draft, _ := h.db.GetPageDraft(ctx, page.ID); draft != nil {
+				page.HasDraft = true
+			}
+		}
+		return h.respondPage(c, page)
+	case "draft":
+		if !policy.CanEdit(user, page) {
+			return echo.NewHTTPError(http.StatusNotFound, "page has no unpublished changes")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "variant must be live or draft")
+	}
+
+	draft, err := h.wikiService.GetDraft(ctx, page.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrNoDraft) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get draft")
+	}
+	html, err := h.wikiService.RenderMarkdown(page.Slug, draft.Content)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to render content")
+	}
+
+	page.Title = draft.Title
+	page.Content = draft.Content
+	page.ContentHTML = html
+	page.Excerpt = h.wikiService.Excerpt(draft.Content)
+	page.WordCount = h.wikiService.WordCount(draft.Content)
+	page.Tags = make([]models.Tag, len(draft.Tags))
+	for i, name := range draft.Tags {
+		page.Tags[i] = models.Tag{Name: name}
+	}
+	page.UpdatedAt = draft.UpdatedAt
+	page.HasDraft = true
+	return h.respondPage(c, page)
+}
+
+// SaveDraft stores unpublished changes to a page without touching the live
+// content.
+func (h *Handlers) SaveDraft(c echo.Context) error {
+	user := GetAPIUser(c)
+	ctx := c.Request().Context()
+	page, err := h.editablePage(c)
+	if err != nil {
+		return err
+	}
+
+	var req SaveDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	draft, err := h.wikiService.GetDraft(ctx, page.ID)
+	if err != nil && !errors.Is(err, services.ErrNoDraft) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get draft")
+	}
+	if draft == nil {
+		draft = &models.PageDraft{PageID: page.ID, Title: page.Title, Content: page.Content}
+		for _, tag := range page.Tags {
+			draft.Tags = append(draft.Tags, tag.Name)
+		}
+	}
+	if req.Title != nil {
+		draft.Title = *req.Title
+	}
+	if req.Content != nil {
+		draft.Content = *req.Content
+	}
+	if req.Tags != nil {
+		draft.Tags = draft.Tags[:0]
+		for _, name := range req.Tags {
+			if name = strings.TrimSpace(name); name != "" {
+				draft.Tags = append(draft.Tags, name)
+			}
+		}
+	}
+
+	if err := h.wikiService.SaveDraft(ctx, user.ID, draft); err != nil {
+		switch {
+		case errors.Is(err, services.ErrPageArchived):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrInvalidTitle):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save draft")
+	}
+
+	draft, err = h.wikiService.GetDraft(ctx, page.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get draft")
+	}
+	return success(c, draft)
+}
+
+// PublishDraft makes a page's unpublished changes live. The live content
+// it replaces is kept as a revision. If-Match guards against publishing
+// over edits made to the live page since it was last read.
+func (h *Handlers) PublishDraft(c echo.Context) error {
+	user := GetAPIUser(c)
+	ctx := c.Request().Context()
+	page, err := h.editablePage(c)
+	if err != nil {
+		return err
+	}
+	if !middleware.IfMatch(c, page.ETag()) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, pageChangedMessage)
+	}
+
+	var req PublishDraftRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	result, err := h.wikiService.PublishDraft(ctx, page.ID, user.ID, strings.TrimSpace(req.Comment))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoDraft):
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrPageArchived):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrInvalidTitle):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to publish draft")
+	}
+
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+	return success(c, h.viewPage(c, result.Page))
+}
+
+// DiscardDraft throws away a page's unpublished changes.
+func (h *Handlers) DiscardDraft(c echo.Context) error {
+	user := GetAPIUser(c)
+	page, err := h.editablePage(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.wikiService.DiscardDraft(c.Request().Context(), page.ID, user.ID); err != nil {
+		if errors.Is(err, services.ErrNoDraft) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to discard draft")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// editablePage loads the page named by the :slug parameter for a change by
+// the caller, who must be able to edit it.
+func (h *Handlers) editablePage(c echo.Context) (*models.Page, error) {
+	user := GetAPIUser(c)
+	page, err := h.db.GetPageBySlug(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+	if !policy.CanView(user, page) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+	if !policy.CanEdit(user, page) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
+	return page, nil
+}
@@ -3,24 +3,37 @@ package api
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/config"
 	"gowiki/internal/database"
+	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
 	"gowiki/internal/services"
 )
 
 // Handlers contains all API request handlers.
 type Handlers struct {
-	db          *database.DB
-	config      *config.Config
-	authService *services.AuthService
-	wikiService *services.WikiService
+	db            *database.DB
+	config        *config.Config
+	authService   *services.AuthService
+	wikiService   *services.WikiService
+	webhooks      *services.WebhookService
+	announcements *services.AnnouncementService
+	usage         *services.APIUsageService
+	promotion     *services.PromotionService
+	freshness     *services.FreshnessService
+	settings      *services.SettingsService
 }
 
 // NewHandlers creates a new API handlers instance.
@@ -29,12 +42,24 @@ func NewHandlers(
 	cfg *config.Config,
 	authService *services.AuthService,
 	wikiService *services.WikiService,
+	webhooks *services.WebhookService,
+	announcements *services.AnnouncementService,
+	usage *services.APIUsageService,
+	promotion *services.PromotionService,
+	freshness *services.FreshnessService,
+	settings *services.SettingsService,
 ) *Handlers {
 	return &Handlers{
-		db:          db,
-		config:      cfg,
-		authService: authService,
-		wikiService: wikiService,
+		db:            db,
+		config:        cfg,
+		authService:   authService,
+		wikiService:   wikiService,
+		webhooks:      webhooks,
+		announcements: announcements,
+		usage:         usage,
+		promotion:     promotion,
+		freshness:     freshness,
+		settings:      settings,
 	}
 }
 
@@ -65,7 +90,32 @@ func created(c echo.Context, data interface{}) error {
 	return c.JSON(http.StatusCreated, successResponse{Data: data})
 }
 
+// defaultPageLimit is how many results list endpoints return without a
+// limit parameter; maxPageLimit caps it.
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageParams reads the limit and offset query parameters, ignoring values
+// out of range.
+func pageParams(c echo.Context) (limit, offset int) {
+	limit = defaultPageLimit
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= maxPageLimit {
+		limit = l
+	}
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+	return limit, offset
+}
+
+// paginated responds with one page of a list and its total, and links the
+// first, previous, next and last pages in an RFC 5988 Link header.
 func paginated(c echo.Context, data interface{}, total, limit, offset int) error {
+	if links := paginationLinks(c, total, limit, offset); links != "" {
+		c.Response().Header().Set("Link", links)
+	}
 	return c.JSON(http.StatusOK, paginatedResponse{
 		Data:   data,
 		Total:  total,
@@ -74,6 +124,34 @@ func paginated(c echo.Context, data interface{}, total, limit, offset int) error
 	})
 }
 
+// paginationLinks builds the Link header value for a page of results,
+// keeping the request's other query parameters.
+func paginationLinks(c echo.Context, total, limit, offset int) string {
+	if limit <= 0 {
+		return ""
+	}
+
+	base := url.URL{Scheme: c.Scheme(), Host: c.Request().Host, Path: c.Request().URL.Path}
+	query := c.Request().URL.Query()
+	link := func(rel string, offset int) string {
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		base.RawQuery = query.Encode()
+		return fmt.Sprintf("<%s>; rel=%q", base.String(), rel)
+	}
+
+	var links []string
+	if offset > 0 {
+		links = append(links, link("first", 0))
+		links = append(links, link("prev", max(offset-limit, 0)))
+	}
+	if offset+limit < total {
+		links = append(links, link("next", offset+limit))
+		links = append(links, link("last", (total-1)/limit*limit))
+	}
+	return strings.Join(links, ", ")
+}
+
 // Auth handlers
 
 // LoginRequest represents a login request.
@@ -106,6 +184,12 @@ func (h *Handlers) Login(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "invalid credentials")
 	}
+	if h.settings.RequireEmailVerification(c.Request().Context()) && !user.EmailVerified() {
+		return echo.NewHTTPError(http.StatusForbidden, "email address not verified")
+	}
+	if user.MustChangePassword {
+		return echo.NewHTTPError(http.StatusForbidden, "password change required, sign in to the website to choose a new one")
+	}
 
 	// Generate access token
 	accessToken, err := GenerateJWT(user, h.config.Security.SecretKey, h.config.Security.JWTAccessExpiry)
@@ -152,43 +236,67 @@ func (h *Handlers) RefreshToken(c echo.Context) error {
 
 // Page handlers
 
-// ListPages returns a paginated list of pages.
+// ListPages returns a paginated list of pages. Archived pages are left out
+// unless archived=1 includes them or archived=only lists just them.
 func (h *Handlers) ListPages(c echo.Context) error {
 	filter := models.NewPageFilter()
 
-	if limit := c.QueryParam("limit"); limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil && l > 0 && l <= 100 {
-			filter.Limit = l
-		}
-	}
-	if offset := c.QueryParam("offset"); offset != "" {
-		if o, err := strconv.Atoi(offset); err == nil && o >= 0 {
-			filter.Offset = o
-		}
-	}
+	filter.Limit, filter.Offset = pageParams(c)
 	if tag := c.QueryParam("tag"); tag != "" {
 		filter.Tag = &tag
 	}
+	if author := c.QueryParam("author"); author != "" {
+		filter.Author = &author
+	}
 	if orderBy := c.QueryParam("order_by"); orderBy != "" {
 		filter.OrderBy = orderBy
 	}
 	if orderDir := c.QueryParam("order_dir"); orderDir != "" {
 		filter.OrderDir = orderDir
 	}
+	switch c.QueryParam("archived") {
+	case "1":
+		filter.IncludeArchived = true
+	case "only":
+		filter.ArchivedOnly = true
+	}
 
 	// Only show published pages for non-editors
-	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanEdit() {
+	if !policy.CanViewUnpublished(GetAPIUser(c)) {
 		published := true
 		filter.IsPublished = &published
 	}
+	filter.HideRestricted, filter.MemberOf = policy.GroupFilter(GetAPIUser(c))
+
+	return h.listPages(c, filter)
+}
+
+// listPages responds with one page of the pages matching filter.
+func (h *Handlers) listPages(c echo.Context, filter models.PageFilter) error {
+	ctx := c.Request().Context()
 
-	pages, err := h.db.ListPages(c.Request().Context(), filter)
+	pages, err := h.db.ListPages(ctx, filter)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list pages")
 	}
+	if pages == nil {
+		pages = []models.PageSummary{}
+	}
 
-	total, _ := h.db.CountPages(c.Request().Context())
+	total, err := h.db.CountFilteredPages(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count pages")
+	}
+
+	f := h.timeFormat(c)
+	for i := range pages {
+		times := map[string]time.Time{"updated_at": pages[i].UpdatedAt}
+		if pages[i].ArchivedAt != nil {
+			times["archived_at"] = *pages[i].ArchivedAt
+		}
+		pages[i].Display = displayTimes(f, times)
+		pages[i].ReadingTime = models.ReadingMinutes(pages[i].WordCount)
+	}
 
 	return paginated(c, pages, total, filter.Limit, filter.Offset)
 }
@@ -208,13 +316,143 @@ func (h *Handlers) GetPage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "page not found")
 	}
 
-	// Check if user can view unpublished pages
+	if !policy.CanView(GetAPIUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+
+	return h.pageVariant(c, page)
+}
+
+// GetPageByID retrieves a page by its ID, which unlike the slug never
+// changes, so clients managing pages declaratively can track and import them.
+func (h *Handlers) GetPageByID(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page id")
+	}
+
+	page, err := h.db.GetPageByID(c.Request().Context(), id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+
+	if !policy.CanView(GetAPIUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+
+	return h.pageVariant(c, page)
+}
+
+// respondPage answers a GET for a page, or responds 304 Not Modified when
+// the client's If-None-Match or If-Modified-Since shows its copy is current.
+func (h *Handlers) respondPage(c echo.Context, page *models.Page) error {
+	c.Response().Header().Set("Cache-Control", "private, no-cache")
+	if middleware.NotModified(c, page.ETag(), page.UpdatedAt) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return success(c, h.viewPage(c, page))
+}
+
+// viewPage prepares a page for a response: includes are expanded for the
+// caller, the content hash and reading time are filled in and the ETag
+// header is set.
+func (h *Handlers) viewPage(c echo.Context, page *models.Page) *models.Page {
+	c.Response().Header().Set("ETag", page.ETag())
 	user := GetAPIUser(c)
-	if !page.IsPublished && (user == nil || !user.Role.CanEdit()) {
+	page.ContentHTML = h.wikiService.ExpandIncludes(c.Request().Context(), page, func(included *models.Page) bool {
+		return policy.CanView(user, included)
+	})
+	page.ContentHash = models.HashContent(page.Content)
+	page.ReadingTime = models.ReadingMinutes(page.WordCount)
+	page.Display = pageTimes(h.timeFormat(c), page)
+	return page
+}
+
+// GetBacklinks lists the pages linking to a page.
+func (h *Handlers) GetBacklinks(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.db.GetPageBySlug(ctx, c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+
+	user := GetAPIUser(c)
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+
+	backlinks, err := h.wikiService.GetBacklinks(ctx, page.Slug, policy.CanViewUnpublished(user))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get backlinks")
+	}
+	if backlinks == nil {
+		backlinks = []models.PageSummary{}
+	}
+	for i := range backlinks {
+		backlinks[i].ReadingTime = models.ReadingMinutes(backlinks[i].WordCount)
+	}
+
+	return success(c, backlinks)
+}
+
+// GetRelatedPages suggests pages related to a page through shared tags,
+// links and similar titles, best first.
+func (h *Handlers) GetRelatedPages(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.db.GetPageBySlug(ctx, c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+
+	user := GetAPIUser(c)
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = services.DefaultRelatedPages
+	}
+	related, err := h.wikiService.RelatedPages(ctx, page, limit, func(p *models.Page) bool {
+		return policy.CanView(user, p)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get related pages")
+	}
+
+	return success(c, related)
+}
+
+// ExportPage downloads a page as markdown, standalone HTML, or a zip of the
+// page and its descendants.
+func (h *Handlers) ExportPage(c echo.Context) error {
+	page, err := h.db.GetPageBySlug(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+
+	user := GetAPIUser(c)
+	if !policy.CanView(user, page) {
 		return echo.NewHTTPError(http.StatusNotFound, "page not found")
 	}
 
-	return success(c, page)
+	file, err := h.wikiService.ExportPage(c.Request().Context(), page, services.ExportOptions{
+		Format:   c.QueryParam("format"),
+		BaseURL:  h.config.Site.URL,
+		SiteName: h.settings.SiteName(c.Request().Context()),
+		CanView:  func(p *models.Page) bool { return policy.CanView(user, p) },
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportFormat) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to export page")
+	}
+
+	c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	return c.Blob(http.StatusOK, file.ContentType, file.Data)
 }
 
 // CreatePageRequest represents a request to create a page.
@@ -223,12 +461,15 @@ type CreatePageRequest struct {
 	Slug    string   `json:"slug"`
 	Content string   `json:"content"`
 	Tags    []string `json:"tags"`
+	// PublishAt is an RFC 3339 time; a future one keeps the page
+	// unpublished until then
+	PublishAt *string `json:"publish_at"`
 }
 
 // CreatePage creates a new page.
 func (h *Handlers) CreatePage(c echo.Context) error {
 	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanEdit() {
+	if !policy.CanCreate(user) {
 		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
 	}
 
@@ -240,6 +481,10 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 	if req.Title == "" {
 		return echo.NewHTTPError(http.StatusBadRequest, "title is required")
 	}
+	publishAt, err := parsePublishAt(req.PublishAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
 	// Generate slug if not provided
 	slug := req.Slug
@@ -247,71 +492,143 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 		slug = services.Slugify(req.Title)
 	}
 
+	if err := h.wikiService.SlugLimits().Check(slug); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	// Check if slug exists
 	existing, _ := h.db.GetPageBySlug(c.Request().Context(), slug)
 	if existing != nil {
 		return echo.NewHTTPError(http.StatusConflict, "page with this slug already exists")
 	}
 
-	// Render content
-	html, err := h.wikiService.RenderMarkdown(req.Content)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to render content")
-	}
-
 	page := &models.Page{
 		Slug:        slug,
 		Title:       req.Title,
 		Content:     req.Content,
-		ContentHTML: html,
 		AuthorID:    user.ID,
 		IsPublished: true,
 	}
+	if publishAt != nil {
+		page.SchedulePublish(*publishAt, time.Now())
+	}
+	return h.createPage(c, user, page, req.Tags)
+}
+
+// parsePublishAt parses a request's publish_at, an RFC 3339 time or "" to
+// cancel the schedule, which is the zero time. It returns nil when raw is.
+func parsePublishAt(raw *string) (*time.Time, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var at time.Time
+	if *raw != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, *raw); err != nil {
+			return nil, errors.New("publish_at must be an RFC 3339 time")
+		}
+	}
+	return &at, nil
+}
+
+// createPage renders and stores a new page and responds with it.
+func (h *Handlers) createPage(c echo.Context, user *models.User, page *models.Page, tags []string) error {
+	ctx := c.Request().Context()
+
+	// Render content
+	html, err := h.wikiService.RenderMarkdown(page.Slug, page.Content)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to render content")
+	}
+	page.ContentHTML = html
+	page.Excerpt = h.wikiService.Excerpt(page.Content)
+	page.WordCount = h.wikiService.WordCount(page.Content)
 
-	if err := h.db.CreatePage(c.Request().Context(), page); err != nil {
+	if err := h.db.CreatePage(ctx, page); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create page")
 	}
+	h.wikiService.IndexLinks(ctx, page)
 
 	// Set tags
-	if len(req.Tags) > 0 {
-		if err := h.db.SetPageTags(c.Request().Context(), page.ID, req.Tags); err != nil {
+	if len(tags) > 0 {
+		if err := h.db.SetPageTags(ctx, page.ID, tags); err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set tags")
 		}
 	}
 
+	h.webhooks.EmitPage(ctx, models.EventPageCreated, page, user)
+	h.wikiService.Audit(ctx, "page_create", "page", &page.ID, map[string]interface{}{
+		"slug":  page.Slug,
+		"title": page.Title,
+	})
+
 	// Reload page with tags
-	page, _ = h.db.GetPageByID(c.Request().Context(), page.ID)
+	page, _ = h.db.GetPageByID(ctx, page.ID)
 
-	return created(c, page)
+	return created(c, h.viewPage(c, page))
 }
 
+// pageChangedMessage rejects a write whose If-Match or If-None-Match
+// precondition failed, so it can't overwrite changes the client hasn't seen.
+const pageChangedMessage = "page has changed since it was fetched; get it again and retry"
+
 // UpdatePageRequest represents a request to update a page.
 type UpdatePageRequest struct {
 	Title       *string  `json:"title"`
 	Content     *string  `json:"content"`
 	Tags        []string `json:"tags"`
 	IsPublished *bool    `json:"is_published"`
+	// PublishAt schedules an unpublished page: an RFC 3339 time, or "" to
+	// cancel the schedule. A time in the future unpublishes the page until
+	// then.
+	PublishAt *string `json:"publish_at"`
 }
 
-// UpdatePage updates an existing page.
+// UpdatePage updates a page, or creates it when no page has the slug, so
+// the same request can be applied repeatedly. A request that would change
+// nothing is a no-op: no revision is recorded and no webhook fires.
 func (h *Handlers) UpdatePage(c echo.Context) error {
 	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanEdit() {
-		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+
+	var req UpdatePageRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	publishAt, err := parsePublishAt(req.PublishAt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	ctx := c.Request().Context()
 	slug := c.Param("slug")
-	page, err := h.db.GetPageBySlug(c.Request().Context(), slug)
+	page, err := h.db.GetPageBySlug(ctx, slug)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
 	}
 	if page == nil {
+		if !policy.CanCreate(user) {
+			return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+		}
+		if !middleware.IfMatch(c, "") {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, pageChangedMessage)
+		}
+		return h.upsertPage(c, user, slug, req)
+	}
+	if !policy.CanView(user, page) {
 		return echo.NewHTTPError(http.StatusNotFound, "page not found")
 	}
+	if !middleware.IfMatch(c, page.ETag()) || c.Request().Header.Get("If-None-Match") == "*" {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, pageChangedMessage)
+	}
+	if page.IsArchived() {
+		return echo.NewHTTPError(http.StatusConflict, services.ErrPageArchived.Error())
+	}
+	if !policy.CanEdit(user, page) {
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
 
-	var req UpdatePageRequest
-	if err := c.Bind(&req); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	if !pageChanged(page, req) {
+		return success(c, h.viewPage(c, page))
 	}
 
 	// Create revision before updating
@@ -321,7 +638,7 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 		AuthorID: user.ID,
 		Comment:  "API update",
 	}
-	h.db.CreateRevision(c.Request().Context(), revision)
+	h.db.CreateRevision(ctx, revision)
 
 	// Update fields
 	if req.Title != nil {
@@ -329,66 +646,228 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 	}
 	if req.Content != nil {
 		page.Content = *req.Content
-		html, err := h.wikiService.RenderMarkdown(page.Content)
+		html, err := h.wikiService.RenderMarkdown(page.Slug, page.Content)
 		if err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to render content")
 		}
 		page.ContentHTML = html
+		page.Excerpt = h.wikiService.Excerpt(page.Content)
+		page.WordCount = h.wikiService.WordCount(page.Content)
 	}
 	if req.IsPublished != nil {
-		page.IsPublished = *req.IsPublished
+		if *req.IsPublished {
+			page.Publish(time.Now())
+		} else {
+			page.IsPublished = false
+		}
+	}
+	if publishAt != nil {
+		page.SchedulePublish(*publishAt, time.Now())
 	}
 
-	if err := h.db.UpdatePage(c.Request().Context(), page); err != nil {
+	if err := h.db.UpdatePage(ctx, page); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update page")
 	}
+	if req.Content != nil {
+		h.wikiService.IndexLinks(ctx, page)
+	}
 
 	// Update tags if provided
 	if req.Tags != nil {
-		if err := h.db.SetPageTags(c.Request().Context(), page.ID, req.Tags); err != nil {
+		if err := h.db.SetPageTags(ctx, page.ID, req.Tags); err != nil {
 			return echo.NewHTTPError(http.StatusInternalServerError, "failed to set tags")
 		}
 	}
 
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, page, user)
+	h.wikiService.Audit(ctx, "page_update", "page", &page.ID, map[string]interface{}{
+		"slug":    page.Slug,
+		"comment": revision.Comment,
+	})
+
 	// Reload page with tags
-	page, _ = h.db.GetPageBySlug(c.Request().Context(), slug)
+	page, _ = h.db.GetPageBySlug(ctx, slug)
 
-	return success(c, page)
+	return success(c, h.viewPage(c, page))
 }
 
-// DeletePage deletes a page.
-func (h *Handlers) DeletePage(c echo.Context) error {
+// upsertPage creates the page a PUT addressed by a slug that is not taken.
+func (h *Handlers) upsertPage(c echo.Context, user *models.User, slug string, req UpdatePageRequest) error {
+	if services.Slugify(slug) != slug {
+		return echo.NewHTTPError(http.StatusBadRequest, "slug may only contain lowercase letters, digits and hyphens")
+	}
+	if err := h.wikiService.SlugLimits().Check(slug); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if req.Title == nil || strings.TrimSpace(*req.Title) == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "title is required to create a page")
+	}
+
+	page := &models.Page{
+		Slug:        slug,
+		Title:       *req.Title,
+		AuthorID:    user.ID,
+		IsPublished: true,
+	}
+	if req.Content != nil {
+		page.Content = *req.Content
+	}
+	if req.IsPublished != nil {
+		page.IsPublished = *req.IsPublished
+	}
+	if at, err := parsePublishAt(req.PublishAt); err == nil && at != nil {
+		page.SchedulePublish(*at, time.Now())
+	}
+
+	return h.createPage(c, user, page, req.Tags)
+}
+
+// pageChanged reports whether applying req would modify page. Content is
+// compared by hash and tags as a case-insensitive set, matching how they
+// are stored.
+func pageChanged(page *models.Page, req UpdatePageRequest) bool {
+	if req.Title != nil && *req.Title != page.Title {
+		return true
+	}
+	if req.Content != nil && models.HashContent(*req.Content) != models.HashContent(page.Content) {
+		return true
+	}
+	if req.IsPublished != nil && *req.IsPublished != page.IsPublished {
+		return true
+	}
+	if at, err := parsePublishAt(req.PublishAt); err == nil && at != nil {
+		scheduled := *page
+		scheduled.SchedulePublish(*at, time.Now())
+		if scheduled.IsPublished != page.IsPublished || scheduled.PublishAt.Valid != page.PublishAt.Valid ||
+			!scheduled.PublishAt.Time.Equal(page.PublishAt.Time) {
+			return true
+		}
+	}
+	if req.Tags != nil {
+		current := make(map[string]bool, len(page.Tags))
+		for _, tag := range page.Tags {
+			current[strings.ToLower(tag.Name)] = true
+		}
+		wanted := make(map[string]bool, len(req.Tags))
+		for _, name := range req.Tags {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				wanted[name] = true
+			}
+		}
+		if len(current) != len(wanted) {
+			return true
+		}
+		for name := range wanted {
+			if !current[name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ArchivePageRequest represents a request to archive or unarchive a page.
+type ArchivePageRequest struct {
+	IncludeSubpages bool `json:"include_subpages"`
+}
+
+// ArchivePageResponse reports the page and how many pages changed state.
+type ArchivePageResponse struct {
+	Page    *models.Page `json:"page"`
+	Changed int64        `json:"changed"`
+}
+
+// ArchivePage archives a page, optionally with every page under it.
+func (h *Handlers) ArchivePage(c echo.Context) error {
+	return h.setArchived(c, true)
+}
+
+// UnarchivePage restores an archived page, optionally with every page under it.
+func (h *Handlers) UnarchivePage(c echo.Context) error {
+	return h.setArchived(c, false)
+}
+
+func (h *Handlers) setArchived(c echo.Context, archived bool) error {
 	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanEdit() {
+	ctx := c.Request().Context()
+	slug := c.Param("slug")
+	page, err := h.db.GetPageBySlug(ctx, slug)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+	if !policy.CanArchive(user, page) {
 		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
 	}
 
+	var req ArchivePageRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	changed, err := h.wikiService.ArchivePage(ctx, page.ID, archived, req.IncludeSubpages)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to archive page")
+	}
+
+	page, _ = h.db.GetPageBySlug(ctx, slug)
+	return success(c, ArchivePageResponse{Page: page, Changed: changed})
+}
+
+// DeletePage deletes a page.
+func (h *Handlers) DeletePage(c echo.Context) error {
+	user := GetAPIUser(c)
 	slug := c.Param("slug")
 	page, err := h.db.GetPageBySlug(c.Request().Context(), slug)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
 	}
-	if page == nil {
+	if !policy.CanView(user, page) {
 		return echo.NewHTTPError(http.StatusNotFound, "page not found")
 	}
+	if !policy.CanDelete(user, page) {
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
+	if !middleware.IfMatch(c, page.ETag()) {
+		return echo.NewHTTPError(http.StatusPreconditionFailed, pageChangedMessage)
+	}
 
 	if err := h.db.DeletePage(c.Request().Context(), page.ID); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete page")
 	}
 
+	h.webhooks.EmitPage(c.Request().Context(), models.EventPageDeleted, page, user)
+	h.wikiService.Audit(c.Request().Context(), "page_delete", "page", &page.ID, map[string]interface{}{
+		"slug":  page.Slug,
+		"title": page.Title,
+	})
+
 	return c.NoContent(http.StatusNoContent)
 }
 
 // Tag handlers
 
-// ListTags returns all tags.
+// ListTags returns a paginated list of tags.
 func (h *Handlers) ListTags(c echo.Context) error {
-	tags, err := h.db.ListTags(c.Request().Context())
+	ctx := c.Request().Context()
+	limit, offset := pageParams(c)
+
+	tags, err := h.db.ListTagsPage(ctx, limit, offset)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list tags")
 	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
 
-	return success(c, tags)
+	total, err := h.db.CountTags(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count tags")
+	}
+
+	return paginated(c, tags, total, limit, offset)
 }
 
 // GetTagPages returns pages for a specific tag.
@@ -400,20 +879,16 @@ func (h *Handlers) GetTagPages(c echo.Context) error {
 
 	filter := models.NewPageFilter()
 	filter.Tag = &tagName
+	filter.Limit, filter.Offset = pageParams(c)
 
 	// Only show published pages for non-editors
-	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanEdit() {
+	if !policy.CanViewUnpublished(GetAPIUser(c)) {
 		published := true
 		filter.IsPublished = &published
 	}
+	filter.HideRestricted, filter.MemberOf = policy.GroupFilter(GetAPIUser(c))
 
-	pages, err := h.db.ListPages(c.Request().Context(), filter)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list pages")
-	}
-
-	return success(c, pages)
+	return h.listPages(c, filter)
 }
 
 // Search handlers
@@ -425,36 +900,57 @@ func (h *Handlers) Search(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "search query is required")
 	}
 
-	limit := 20
-	if l := c.QueryParam("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
-		}
+	ctx := c.Request().Context()
+	limit, offset := pageParams(c)
+	includeArchived := c.QueryParam("include_archived") == "true"
+
+	results, err := h.db.SearchPages(ctx, query, limit, offset, includeArchived)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "search failed")
+	}
+	if results == nil {
+		results = []models.SearchResult{}
 	}
 
-	results, err := h.db.SearchPages(c.Request().Context(), query, limit)
+	total, err := h.db.CountSearchResults(ctx, query, includeArchived)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "search failed")
 	}
 
-	return success(c, results)
+	f := h.timeFormat(c)
+	for i := range results {
+		results[i].Display = displayTimes(f, map[string]time.Time{"updated_at": results[i].UpdatedAt})
+	}
+
+	return paginated(c, results, total, limit, offset)
 }
 
 // User handlers (admin only)
 
-// ListUsers returns all users (admin only).
+// ListUsers returns a paginated list of users (admin only).
 func (h *Handlers) ListUsers(c echo.Context) error {
 	user := GetAPIUser(c)
-	if user == nil || !user.Role.CanAdmin() {
-		return echo.NewHTTPError(http.StatusForbidden, "admin access required")
+	if user == nil || !user.Role.Can(models.PermManageUsers) {
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
 	}
 
-	users, err := h.db.ListUsers(c.Request().Context(), 100, 0)
+	ctx := c.Request().Context()
+	limit, offset := pageParams(c)
+
+	users, err := h.db.ListUsers(ctx, limit, offset)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list users")
 	}
+	if users == nil {
+		users = []models.User{}
+	}
 
-	return success(c, users)
+	total, err := h.db.CountUsers(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count users")
+	}
+
+	return paginated(c, users, total, limit, offset)
 }
 
 // GetCurrentUser returns the current authenticated user.
@@ -518,6 +1014,10 @@ func (h *Handlers) CreateAPIToken(c echo.Context) error {
 	if err := h.db.CreateAPIToken(c.Request().Context(), token); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create token")
 	}
+	h.wikiService.Audit(c.Request().Context(), "api_token_create", "api_token", &token.ID, map[string]interface{}{
+		"name":   token.Name,
+		"scopes": token.Scopes,
+	})
 
 	return created(c, CreateAPITokenResponse{
 		Token:     rawToken,
@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,84 +13,11 @@ import (
 
 	"gowiki/internal/config"
 	"gowiki/internal/database"
+	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/services"
 )
 
-// authRateLimiter tracks failed authentication attempts per IP.
-type authRateLimiter struct {
-	attempts map[string]*authAttempt
-	mu       sync.RWMutex
-	maxFails int
-	window   time.Duration
-}
-
-type authAttempt struct {
-	count     int
-	resetAt   time.Time
-}
-
-func newAuthRateLimiter(maxFails int, window time.Duration) *authRateLimiter {
-	rl := &authRateLimiter{
-		attempts: make(map[string]*authAttempt),
-		maxFails: maxFails,
-		window:   window,
-	}
-	go rl.cleanup()
-	return rl
-}
-
-func (rl *authRateLimiter) check(ip string) bool {
-	rl.mu.RLock()
-	attempt, exists := rl.attempts[ip]
-	rl.mu.RUnlock()
-
-	if !exists {
-		return true
-	}
-
-	if time.Now().After(attempt.resetAt) {
-		return true
-	}
-
-	return attempt.count < rl.maxFails
-}
-
-func (rl *authRateLimiter) recordFailure(ip string) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-	attempt, exists := rl.attempts[ip]
-
-	if !exists || now.After(attempt.resetAt) {
-		rl.attempts[ip] = &authAttempt{
-			count:   1,
-			resetAt: now.Add(rl.window),
-		}
-		return
-	}
-
-	attempt.count++
-}
-
-func (rl *authRateLimiter) cleanup() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for ip, attempt := range rl.attempts {
-			if now.After(attempt.resetAt) {
-				delete(rl.attempts, ip)
-			}
-		}
-		rl.mu.Unlock()
-	}
-}
-
-var apiAuthLimiter = newAuthRateLimiter(10, 15*time.Minute) // 10 failures per 15 min
-
 type contextKey string
 
 const (
@@ -109,15 +35,19 @@ type JWTClaims struct {
 
 // JWTMiddleware handles JWT authentication for the API.
 type JWTMiddleware struct {
-	db     *database.DB
-	config *config.Config
+	db          *database.DB
+	config      *config.Config
+	authLimiter *middleware.LoginRateLimiter
 }
 
-// NewJWTMiddleware creates a new JWT middleware.
-func NewJWTMiddleware(db *database.DB, cfg *config.Config) *JWTMiddleware {
+// NewJWTMiddleware creates a new JWT middleware. Clients that fail to
+// authenticate 10 times are locked out for 15 minutes, counted in
+// rateLimits.
+func NewJWTMiddleware(db *database.DB, cfg *config.Config, rateLimits middleware.RateLimitStore) *JWTMiddleware {
 	return &JWTMiddleware{
-		db:     db,
-		config: cfg,
+		db:          db,
+		config:      cfg,
+		authLimiter: middleware.NewLoginRateLimiter(rateLimits, "api-auth", 10, 15*time.Minute),
 	}
 }
 
@@ -128,21 +58,21 @@ func (m *JWTMiddleware) Middleware() echo.MiddlewareFunc {
 			clientIP := c.RealIP()
 
 			// Check rate limit before processing
-			if !apiAuthLimiter.check(clientIP) {
+			if allowed, _ := m.authLimiter.Check(clientIP); !allowed {
 				return echo.NewHTTPError(http.StatusTooManyRequests, "too many failed authentication attempts")
 			}
 
 			// Get Authorization header
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				apiAuthLimiter.recordFailure(clientIP)
+				m.authLimiter.RecordFailure(clientIP)
 				return echo.NewHTTPError(http.StatusUnauthorized, "missing authorization header")
 			}
 
 			// Check for Bearer token
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				apiAuthLimiter.recordFailure(clientIP)
+				m.authLimiter.RecordFailure(clientIP)
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid authorization format")
 			}
 
@@ -160,13 +90,17 @@ func (m *JWTMiddleware) Middleware() echo.MiddlewareFunc {
 				if err != nil {
 					return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user")
 				}
-				if user == nil || !user.IsActive {
-					apiAuthLimiter.recordFailure(clientIP)
+				if user == nil || !user.CanSignIn() {
+					m.authLimiter.RecordFailure(clientIP)
 					return echo.NewHTTPError(http.StatusUnauthorized, "user not found or inactive")
 				}
+				if user.MustChangePassword {
+					return echo.NewHTTPError(http.StatusForbidden, "password change required")
+				}
 
 				// Set user in context
 				ctx := context.WithValue(c.Request().Context(), userContextKey, user)
+				ctx = services.WithAuditActor(ctx, &user.ID, c.RealIP())
 				c.SetRequest(c.Request().WithContext(ctx))
 				return next(c)
 			}
@@ -178,7 +112,7 @@ func (m *JWTMiddleware) Middleware() echo.MiddlewareFunc {
 				return echo.NewHTTPError(http.StatusInternalServerError, "failed to validate token")
 			}
 			if apiToken == nil {
-				apiAuthLimiter.recordFailure(clientIP)
+				m.authLimiter.RecordFailure(clientIP)
 				return echo.NewHTTPError(http.StatusUnauthorized, "invalid token")
 			}
 
@@ -192,9 +126,12 @@ func (m *JWTMiddleware) Middleware() echo.MiddlewareFunc {
 			if err != nil {
 				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user")
 			}
-			if user == nil || !user.IsActive {
+			if user == nil || !user.CanSignIn() {
 				return echo.NewHTTPError(http.StatusUnauthorized, "user not found or inactive")
 			}
+			if user.MustChangePassword {
+				return echo.NewHTTPError(http.StatusForbidden, "password change required")
+			}
 
 			// Update last used
 			go m.db.UpdateAPITokenLastUsed(context.Background(), apiToken.ID)
@@ -202,6 +139,7 @@ func (m *JWTMiddleware) Middleware() echo.MiddlewareFunc {
 			// Set user and token in context
 			ctx := context.WithValue(c.Request().Context(), userContextKey, user)
 			ctx = context.WithValue(ctx, tokenContextKey, apiToken)
+			ctx = services.WithAuditActor(ctx, &user.ID, c.RealIP())
 			c.SetRequest(c.Request().WithContext(ctx))
 
 			return next(c)
@@ -234,10 +172,24 @@ func (m *JWTMiddleware) OptionalMiddleware() echo.MiddlewareFunc {
 
 			if err == nil && token.Valid {
 				user, err := m.db.GetUserByID(c.Request().Context(), claims.UserID)
-				if err == nil && user != nil && user.IsActive {
+				if err == nil && user != nil && user.CanSignIn() && !user.MustChangePassword {
 					ctx := context.WithValue(c.Request().Context(), userContextKey, user)
 					c.SetRequest(c.Request().WithContext(ctx))
 				}
+				return next(c)
+			}
+
+			// Try API token
+			apiToken, err := m.db.GetAPITokenByHash(c.Request().Context(), hashToken(tokenString))
+			if err == nil && apiToken != nil && time.Now().Before(apiToken.ExpiresAt) {
+				user, err := m.db.GetUserByID(c.Request().Context(), apiToken.UserID)
+				if err == nil && user != nil && user.CanSignIn() && !user.MustChangePassword {
+					go m.db.UpdateAPITokenLastUsed(context.Background(), apiToken.ID)
+
+					ctx := context.WithValue(c.Request().Context(), userContextKey, user)
+					ctx = context.WithValue(ctx, tokenContextKey, apiToken)
+					c.SetRequest(c.Request().WithContext(ctx))
+				}
 			}
 
 			return next(c)
@@ -297,6 +249,22 @@ func RequireRole(role models.Role) echo.MiddlewareFunc {
 	}
 }
 
+// RequirePermission middleware checks that the user's role grants a permission.
+func RequirePermission(perm models.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := GetAPIUser(c)
+			if user == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "authentication required")
+			}
+			if !user.Role.Can(perm) {
+				return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+			}
+			return next(c)
+		}
+	}
+}
+
 // GetAPIUser returns the authenticated user from context.
 func GetAPIUser(c echo.Context) *models.User {
 	user, _ := c.Request().Context().Value(userContextKey).(*models.User)
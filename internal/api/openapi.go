@@ -0,0 +1,645 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// Authentication requirements for an operation.
+const (
+	authOptional = "optional"
+	authRequired = "required"
+)
+
+// Response envelopes used by the API helpers.
+const (
+	envelopeData      = "data"
+	envelopePaginated = "paginated"
+)
+
+// apiParam documents a path or query parameter.
+type apiParam struct {
+	Name        string
+	In          string // "path" or "query"
+	Type        string // "string", "integer" or "boolean"
+	Required    bool
+	Description string
+}
+
+// apiOperation documents a single /api/v1 route. Request and Response are
+// zero values of the Go types that are bound and returned by the handler.
+type apiOperation struct {
+	Summary  string
+	Tag      string
+	Auth     string
+	Perm     models.Permission
+	Params   []apiParam
+	Request  interface{}
+	Response interface{}
+	Envelope string
+	Status   int
+}
+
+var paginationParams = []apiParam{
+	{Name: "limit", In: "query", Type: "integer", Description: "Maximum number of results (1-100)"},
+	{Name: "offset", In: "query", Type: "integer", Description: "Number of results to skip"},
+}
+
+var variantParams = []apiParam{
+	{Name: "variant", In: "query", Type: "string", Description: "live (default) or draft, the page's unpublished changes (editors only)"},
+}
+
+// apiOperations describes every API route, keyed by "METHOD /path" as
+// registered with echo. Routes missing from this table still appear in the
+// generated document with a placeholder summary.
+var apiOperations = map[string]apiOperation{
+	"POST /api/v1/auth/login": {
+		Summary: "Log in and receive JWT access and refresh tokens", Tag: "auth",
+		Request: LoginRequest{}, Response: TokenResponse{},
+	},
+	"POST /api/v1/auth/refresh": {
+		Summary: "Exchange a refresh token for a new access token", Tag: "auth", Auth: authRequired,
+		Response: TokenResponse{},
+	},
+	"GET /api/v1/pages": {
+		Summary: "List pages", Tag: "pages", Auth: authOptional,
+		Params: append([]apiParam{
+			{Name: "tag", In: "query", Type: "string", Description: "Only pages with this tag"},
+			{Name: "author", In: "query", Type: "string", Description: "Only pages by this username"},
+			{Name: "order_by", In: "query", Type: "string", Description: "updated_at (default), created_at, title or author"},
+			{Name: "order_dir", In: "query", Type: "string", Description: "asc or desc"},
+		}, paginationParams...),
+		Response: []models.PageSummary{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/pages/:slug": {
+		Summary: "Get a page by slug", Tag: "pages", Auth: authOptional,
+		Params:   variantParams,
+		Response: models.Page{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/by-id/:id": {
+		Summary: "Get a page by its stable ID", Tag: "pages", Auth: authOptional,
+		Params:   variantParams,
+		Response: models.Page{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/:slug/export": {
+		Summary: "Download a page as markdown, standalone HTML, or a zip of its subtree", Tag: "pages", Auth: authOptional,
+		Params: []apiParam{
+			{Name: "format", In: "query", Type: "string", Description: "md (default), html or zip"},
+		},
+	},
+	"GET /api/v1/pages/:slug/backlinks": {
+		Summary: "List pages that link to a page", Tag: "pages", Auth: authOptional,
+		Response: []models.PageSummary{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/:slug/related": {
+		Summary: "Suggest pages related through shared tags, links and similar titles", Tag: "pages", Auth: authOptional,
+		Params: []apiParam{
+			{Name: "limit", In: "query", Type: "integer", Description: "Maximum results (default 5, max 20)"},
+		},
+		Response: []models.RelatedPage{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/pages": {
+		Summary: "Create a page", Tag: "pages", Auth: authRequired, Perm: models.PermCreatePage,
+		Request: CreatePageRequest{}, Response: models.Page{}, Envelope: envelopeData, Status: http.StatusCreated,
+	},
+	"PUT /api/v1/pages/:slug": {
+		Summary: "Create or update a page; unchanged requests are no-ops", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: UpdatePageRequest{}, Response: models.Page{}, Envelope: envelopeData,
+	},
+	"DELETE /api/v1/pages/:slug": {
+		Summary: "Delete a page", Tag: "pages", Auth: authRequired, Perm: models.PermDeletePage,
+		Status: http.StatusNoContent,
+	},
+	"POST /api/v1/pages/:slug/archive": {
+		Summary: "Archive a page, optionally with its subpages", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: ArchivePageRequest{}, Response: ArchivePageResponse{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/pages/:slug/unarchive": {
+		Summary: "Unarchive a page, optionally with its subpages", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: ArchivePageRequest{}, Response: ArchivePageResponse{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/pages/batch": {
+		Summary: "Create, update and delete many pages in one transaction, with a result per operation", Tag: "pages", Auth: authRequired,
+		Request: BatchRequest{}, Response: BatchResponse{}, Envelope: envelopeData,
+	},
+	"PUT /api/v1/pages/:slug/draft": {
+		Summary: "Save unpublished changes to a page without changing the live content", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: SaveDraftRequest{}, Response: models.PageDraft{}, Envelope: envelopeData,
+	},
+	"DELETE /api/v1/pages/:slug/draft": {
+		Summary: "Discard a page's unpublished changes", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Status: http.StatusNoContent,
+	},
+	"POST /api/v1/pages/:slug/draft/publish": {
+		Summary: "Publish a page's unpublished changes", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: PublishDraftRequest{}, Response: models.Page{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/:slug/properties": {
+		Summary: "Get a page's published state, parent, owner, tags, review date and access summary", Tag: "pages", Auth: authRequired,
+		Response: models.PageProperties{}, Envelope: envelopeData,
+	},
+	"PATCH /api/v1/pages/:slug/properties": {
+		Summary: "Change some of a page's properties without touching its content", Tag: "pages", Auth: authRequired, Perm: models.PermEditPage,
+		Request: models.PagePropertiesUpdate{}, Response: models.PageProperties{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/:slug/revisions": {
+		Summary: "List a page's revisions, newest first", Tag: "revisions", Auth: authRequired, Perm: models.PermEditPage,
+		Params:   paginationParams,
+		Response: []models.RevisionSummary{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/pages/:slug/revisions/:id": {
+		Summary: "Get a revision with its content", Tag: "revisions", Auth: authRequired, Perm: models.PermEditPage,
+		Response: models.Revision{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/pages/:slug/diff": {
+		Summary: "Diff two revisions, or a revision against the current content", Tag: "revisions", Auth: authRequired, Perm: models.PermEditPage,
+		Params: []apiParam{
+			{Name: "from", In: "query", Type: "integer", Required: true, Description: "Older revision ID"},
+			{Name: "to", In: "query", Type: "integer", Description: "Newer revision ID; defaults to the current content"},
+		},
+		Response: DiffResponse{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/pages/:slug/revisions/:id/revert": {
+		Summary: "Restore a page to a revision", Tag: "revisions", Auth: authRequired, Perm: models.PermEditPage,
+		Response: models.Page{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/tags": {
+		Summary: "List tags with page counts", Tag: "tags", Auth: authOptional,
+		Params:   paginationParams,
+		Response: []models.Tag{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/tags/:name": {
+		Summary: "List pages with a tag", Tag: "tags", Auth: authOptional,
+		Params:   paginationParams,
+		Response: []models.PageSummary{}, Envelope: envelopePaginated,
+	},
+	"PATCH /api/v1/tags/:name": {
+		Summary: "Rename a tag or file it under a parent tag", Tag: "tags", Auth: authRequired, Perm: models.PermEditPage,
+		Request: UpdateTagRequest{}, Response: models.Tag{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/tags/:name/merge": {
+		Summary: "Move a tag's pages and child tags to another tag and delete it", Tag: "tags", Auth: authRequired, Perm: models.PermEditPage,
+		Request: MergeTagRequest{}, Response: models.Tag{}, Envelope: envelopeData,
+	},
+	"DELETE /api/v1/tags/:name": {
+		Summary: "Delete a tag no page has", Tag: "tags", Auth: authRequired, Perm: models.PermEditPage,
+		Status: http.StatusNoContent,
+	},
+	"POST /api/v1/tags/prune": {
+		Summary: "Delete every tag no page has", Tag: "tags", Auth: authRequired, Perm: models.PermEditPage,
+		Response: PruneTagsResponse{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/search": {
+		Summary: "Full-text search", Tag: "search", Auth: authOptional,
+		Params: append([]apiParam{
+			{Name: "q", In: "query", Type: "string", Required: true, Description: "Search query"},
+			{Name: "include_archived", In: "query", Type: "boolean", Description: "Include archived pages"},
+		}, paginationParams...),
+		Response: []models.SearchResult{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/analytics": {
+		Summary: "Page views per day, most viewed and unviewed pages; admins get every page, others the pages they created", Tag: "pages", Auth: authRequired,
+		Params: []apiParam{
+			{Name: "days", In: "query", Type: "integer", Description: "Period in days: 7, 30 (default), 90 or 365"},
+		},
+		Response: services.PageAnalytics{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/quicksearch": {
+		Summary: "Match page titles and slugs by prefix or abbreviation, for quick-open palettes", Tag: "search", Auth: authOptional,
+		Params: []apiParam{
+			{Name: "q", In: "query", Type: "string", Required: true, Description: "Words to match; each must match the title or slug"},
+			{Name: "limit", In: "query", Type: "integer", Description: "Maximum number of results (1-20)"},
+		},
+		Response: []models.SearchResult{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/shares": {
+		Summary: "List share links, newest first; admins get every link, others their own", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Params:   paginationParams,
+		Response: []models.ShareLink{}, Envelope: envelopePaginated,
+	},
+	"POST /api/v1/shares": {
+		Summary: "Create a share link to a page, a tag or a search; the URL is only returned once", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Request: CreateShareRequest{}, Response: CreateShareResponse{}, Envelope: envelopeData, Status: http.StatusCreated,
+	},
+	"GET /api/v1/shares/:id": {
+		Summary: "Get a share link with its view counts and most recent accesses", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Params:   paginationParams,
+		Response: ShareStatsResponse{}, Envelope: envelopeData,
+	},
+	"PATCH /api/v1/shares/:id": {
+		Summary: "Change a share link's expiry, limits or revocation; admins can change any link, others their own", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Request: UpdateShareRequest{}, Response: models.ShareLink{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/shares/:id/revoke": {
+		Summary: "Revoke a share link", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Response: models.ShareLink{}, Envelope: envelopeData,
+	},
+	"DELETE /api/v1/shares/:id": {
+		Summary: "Delete a share link and its access records", Tag: "shares", Auth: authRequired, Perm: models.PermManageShares,
+		Status: http.StatusNoContent,
+	},
+	"GET /api/v1/me": {
+		Summary: "Get the authenticated user", Tag: "users", Auth: authRequired,
+		Response: models.User{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/admin/users": {
+		Summary: "List users", Tag: "users", Auth: authRequired, Perm: models.PermManageUsers,
+		Params:   paginationParams,
+		Response: []models.User{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/announcements": {
+		Summary: "List active announcements, excluding ones the caller dismissed", Tag: "announcements", Auth: authOptional,
+		Response: []models.Announcement{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/announcements/:id/dismiss": {
+		Summary: "Dismiss an announcement for the authenticated user", Tag: "announcements", Auth: authRequired,
+		Status: http.StatusNoContent,
+	},
+	"GET /api/v1/admin/announcements": {
+		Summary: "List all announcements, including expired ones", Tag: "announcements", Auth: authRequired, Perm: models.PermAdminister,
+		Response: []models.Announcement{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/admin/announcements": {
+		Summary: "Publish an announcement banner", Tag: "announcements", Auth: authRequired, Perm: models.PermAdminister,
+		Request: services.AnnouncementInput{}, Response: models.Announcement{}, Envelope: envelopeData, Status: http.StatusCreated,
+	},
+	"DELETE /api/v1/admin/announcements/:id": {
+		Summary: "Delete an announcement", Tag: "announcements", Auth: authRequired, Perm: models.PermAdminister,
+		Status: http.StatusNoContent,
+	},
+	"GET /api/v1/admin/api-usage": {
+		Summary: "Request counts, error rates and anomaly flags per API token", Tag: "tokens", Auth: authRequired, Perm: models.PermAdminister,
+		Response: []models.APITokenUsage{}, Envelope: envelopeData,
+	},
+	"GET /api/v1/admin/audit": {
+		Summary: "List audit log entries, newest first; format=csv downloads every match", Tag: "admin", Auth: authRequired, Perm: models.PermAdminister,
+		Params: append([]apiParam{
+			{Name: "user", In: "query", Type: "string", Description: "Only entries by this username"},
+			{Name: "action", In: "query", Type: "string", Description: "Only this action, e.g. user_lock"},
+			{Name: "entity_type", In: "query", Type: "string", Description: "Only this entity type, e.g. page"},
+			{Name: "from", In: "query", Type: "string", Description: "First UTC day, YYYY-MM-DD"},
+			{Name: "to", In: "query", Type: "string", Description: "Last UTC day, YYYY-MM-DD"},
+			{Name: "format", In: "query", Type: "string", Description: "json (default) or csv"},
+		}, paginationParams...),
+		Response: []models.AuditEntry{}, Envelope: envelopePaginated,
+	},
+	"GET /api/v1/admin/promotion/bundle": {
+		Summary: "Download the pages with a tag as a signed bundle for promotion to another wiki", Tag: "admin", Auth: authRequired, Perm: models.PermAdminister,
+		Params: []apiParam{
+			{Name: "label", In: "query", Type: "string", Required: true, Description: "Tag that marks the pages to promote"},
+		},
+		Response: models.SignedBundle{},
+	},
+	"POST /api/v1/admin/promotion": {
+		Summary: "Apply a signed bundle from another wiki in one transaction, or preview it with dry_run", Tag: "admin", Auth: authRequired, Perm: models.PermAdminister,
+		Params: []apiParam{
+			{Name: "dry_run", In: "query", Type: "boolean", Description: "Only return the plan and diffs"},
+		},
+		Request: models.SignedBundle{}, Response: services.PromotionPlan{}, Envelope: envelopeData,
+	},
+	"POST /api/v1/tokens": {
+		Summary: "Create an API token; the raw token is only returned once", Tag: "tokens", Auth: authRequired,
+		Request: CreateAPITokenRequest{}, Response: CreateAPITokenResponse{}, Envelope: envelopeData, Status: http.StatusCreated,
+	},
+	"GET /api/v1/tokens": {
+		Summary: "List your API tokens", Tag: "tokens", Auth: authRequired,
+		Response: []models.APIToken{}, Envelope: envelopeData,
+	},
+	"DELETE /api/v1/tokens/:id": {
+		Summary: "Revoke an API token", Tag: "tokens", Auth: authRequired,
+		Status: http.StatusNoContent,
+	},
+	"GET /api/v1/openapi.json": {
+		Summary: "This OpenAPI document", Tag: "meta",
+	},
+	"GET /api/v1/docs": {
+		Summary: "Interactive API documentation", Tag: "meta",
+	},
+}
+
+var pathParamPattern = regexp.MustCompile(`:([A-Za-z_]+)`)
+
+// OpenAPI serves the generated OpenAPI 3 document for all /api/v1 routes.
+type OpenAPI struct {
+	e        *echo.Echo
+	siteName string
+	siteURL  string
+	once     sync.Once
+	spec     map[string]interface{}
+	schemas  map[string]interface{}
+}
+
+// NewOpenAPI creates an OpenAPI generator over the routes registered on e.
+// The document is built on first request, after all routes are registered.
+func NewOpenAPI(e *echo.Echo, siteName, siteURL string) *OpenAPI {
+	return &OpenAPI{e: e, siteName: siteName, siteURL: siteURL}
+}
+
+// Spec handles GET /api/v1/openapi.json.
+func (o *OpenAPI) Spec(c echo.Context) error {
+	o.once.Do(o.build)
+	return c.JSON(http.StatusOK, o.spec)
+}
+
+func (o *OpenAPI) build() {
+	o.schemas = map[string]interface{}{
+		"Error": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"error": map[string]interface{}{"type": "string"},
+				"code":  map[string]interface{}{"type": "integer"},
+			},
+		},
+	}
+
+	paths := map[string]interface{}{}
+	tags := map[string]bool{}
+
+	routes := o.e.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	for _, route := range routes {
+		// Skip echo's internal not-found handlers for group prefixes
+		if !strings.HasPrefix(route.Path, "/api/v1/") || strings.Contains(route.Path, "*") {
+			continue
+		}
+
+		op, ok := apiOperations[route.Method+" "+route.Path]
+		if !ok {
+			op = apiOperation{Summary: "Undocumented", Tag: "other"}
+		}
+		tags[op.Tag] = true
+
+		path := pathParamPattern.ReplaceAllString(strings.TrimPrefix(route.Path, "/api/v1"), "{$1}")
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = o.operation(route, op)
+	}
+
+	tagList := make([]string, 0, len(tags))
+	for t := range tags {
+		tagList = append(tagList, t)
+	}
+	sort.Strings(tagList)
+	tagObjects := make([]interface{}, 0, len(tagList))
+	for _, t := range tagList {
+		tagObjects = append(tagObjects, map[string]interface{}{"name": t})
+	}
+
+	o.spec = map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   o.siteName + " API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": strings.TrimSuffix(o.siteURL, "/") + "/api/v1"},
+		},
+		"tags":  tagObjects,
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": o.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"description":  "JWT access token from /auth/login, or an API token",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+func (o *OpenAPI) operation(route *echo.Route, op apiOperation) map[string]interface{} {
+	result := map[string]interface{}{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": strings.TrimSuffix(route.Name[strings.LastIndex(route.Name, ".")+1:], "-fm"),
+	}
+
+	var params []interface{}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true,
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, p := range op.Params {
+		params = append(params, map[string]interface{}{
+			"name": p.Name, "in": p.In, "required": p.Required, "description": p.Description,
+			"schema": map[string]interface{}{"type": p.Type},
+		})
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+
+	switch op.Auth {
+	case authRequired:
+		result["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	case authOptional:
+		result["security"] = []interface{}{map[string]interface{}{}, map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	if op.Perm != "" {
+		result["description"] = "Requires a role with the " + string(op.Perm) + " permission."
+	}
+
+	if op.Request != nil {
+		result["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": o.schemaFor(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Response != nil {
+		response["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": o.envelope(op)},
+		}
+	}
+	if op.Envelope == envelopePaginated {
+		response["headers"] = map[string]interface{}{
+			"Link": map[string]interface{}{
+				"description": `RFC 5988 links to the first, prev, next and last pages, when they exist`,
+				"schema":      map[string]interface{}{"type": "string"},
+			},
+		}
+	}
+
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/Error"}},
+		},
+	}
+	result["responses"] = map[string]interface{}{
+		strconv.Itoa(status): response,
+		"default":            errorResponse,
+	}
+
+	return result
+}
+
+// envelope wraps the response schema in the success or paginated helper shape.
+func (o *OpenAPI) envelope(op apiOperation) map[string]interface{} {
+	schema := o.schemaFor(reflect.TypeOf(op.Response))
+
+	switch op.Envelope {
+	case envelopeData:
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"data": schema},
+		}
+	case envelopePaginated:
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"data":   schema,
+				"total":  map[string]interface{}{"type": "integer"},
+				"limit":  map[string]interface{}{"type": "integer"},
+				"offset": map[string]interface{}{"type": "integer"},
+			},
+		}
+	}
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor converts a Go type to a JSON schema following encoding/json rules.
+// Named structs are registered under components/schemas and referenced.
+func (o *OpenAPI) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": o.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": o.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if name == "" {
+			return o.structSchema(t)
+		}
+		if _, ok := o.schemas[name]; !ok {
+			// Register before recursing so self-referencing types terminate
+			o.schemas[name] = map[string]interface{}{}
+			o.schemas[name] = o.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (o *OpenAPI) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		// Embedded structs without a tag are flattened by encoding/json
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			embedded := o.structSchema(field.Type)
+			for k, v := range embedded["properties"].(map[string]interface{}) {
+				properties[k] = v
+			}
+			continue
+		}
+
+		schema := o.schemaFor(field.Type)
+		if field.Type.Kind() == reflect.Ptr {
+			if _, isRef := schema["$ref"]; isRef {
+				schema = map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+			} else {
+				schema["nullable"] = true
+			}
+		}
+		properties[name] = schema
+
+		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// schemaName names a struct after its package and type, e.g. models.Page -> Page.
+// Request and response types in this package keep their own names.
+func schemaName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	if t.PkgPath() == "database/sql" {
+		return "Sql" + t.Name()
+	}
+	return t.Name()
+}
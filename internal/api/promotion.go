@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// maxBundleSize bounds the size of a promotion bundle upload.
+const maxBundleSize = 64 << 20
+
+// ExportBundle downloads the pages tagged with label as a signed bundle for
+// promotion to another wiki.
+func (h *Handlers) ExportBundle(c echo.Context) error {
+	user := GetAPIUser(c)
+
+	bundle, err := h.promotion.Export(c.Request().Context(), c.QueryParam("label"), user.Username)
+	if err != nil {
+		return promotionError(err, "failed to export bundle")
+	}
+
+	filename := fmt.Sprintf("bundle-%s-%s.json", services.Slugify(c.QueryParam("label")), time.Now().UTC().Format("20060102"))
+	c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSON(http.StatusOK, bundle)
+}
+
+// PromoteBundle applies a signed bundle exported by another wiki in one
+// transaction. With dry_run=true it only returns the plan, with a diff of
+// each changed page.
+func (h *Handlers) PromoteBundle(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := GetAPIUser(c)
+
+	data, err := io.ReadAll(io.LimitReader(c.Request().Body, maxBundleSize+1))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read bundle")
+	}
+	if len(data) > maxBundleSize {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "bundle is too large")
+	}
+
+	bundle, err := h.promotion.Open(data)
+	if err != nil {
+		return promotionError(err, "failed to promote bundle")
+	}
+
+	if dryRun, _ := strconv.ParseBool(c.QueryParam("dry_run")); dryRun {
+		plan, _, err := h.promotion.Plan(ctx, bundle)
+		if err != nil {
+			return promotionError(err, "failed to promote bundle")
+		}
+		return success(c, plan)
+	}
+
+	result, err := h.promotion.Apply(ctx, bundle, user.ID)
+	if err != nil {
+		return promotionError(err, "failed to promote bundle")
+	}
+
+	for _, page := range result.Created {
+		h.webhooks.EmitPage(ctx, models.EventPageCreated, page, user)
+	}
+	for _, page := range result.Updated {
+		h.webhooks.EmitPage(ctx, models.EventPageUpdated, page, user)
+	}
+
+	details, _ := json.Marshal(map[string]interface{}{
+		"id":      bundle.ID,
+		"label":   bundle.Label,
+		"source":  bundle.Source,
+		"created": result.Plan.Created,
+		"updated": result.Plan.Updated,
+	})
+	if err := h.db.LogAudit(ctx, &user.ID, "bundle_promote", "bundle", nil, string(details), c.RealIP()); err != nil {
+		fmt.Printf("Warning: failed to log promotion: %v\n", err)
+	}
+
+	return success(c, result.Plan)
+}
+
+// promotionError maps promotion errors to HTTP errors, using fallback for
+// unexpected ones.
+func promotionError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrPromotionDisabled):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrBundleSignature):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrBundleLabel),
+		errors.Is(err, services.ErrInvalidBundle),
+		errors.Is(err, services.ErrSlugTooDeep),
+		errors.Is(err, services.ErrSlugTooLong):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrBundleEmpty):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPageArchived), errors.Is(err, services.ErrBundleApplied):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+}
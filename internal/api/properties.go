@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// GetPageProperties returns a page's published state, parent, owner, tags,
+// review date and access summary.
+func (h *Handlers) GetPageProperties(c echo.Context) error {
+	ctx := c.Request().Context()
+	page, err := h.db.GetPageBySlug(ctx, c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+	if !policy.CanView(GetAPIUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+
+	props, err := h.wikiService.PageProperties(ctx, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page properties")
+	}
+	return success(c, props)
+}
+
+// UpdatePageProperties changes only the properties present in the request,
+// leaving the title and content alone. Setting a new parent moves the page
+// and its subpages, so the response carries the page's new slug.
+func (h *Handlers) UpdatePageProperties(c echo.Context) error {
+	user := GetAPIUser(c)
+	ctx := c.Request().Context()
+	page, err := h.db.GetPageBySlug(ctx, c.Param("slug"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+	if !policy.CanEdit(user, page) {
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
+
+	var req models.PagePropertiesUpdate
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if req.IsEmpty() {
+		return echo.NewHTTPError(http.StatusBadRequest, "no properties to update")
+	}
+
+	result, err := h.wikiService.UpdatePageProperties(ctx, page, user.ID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPageArchived):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrPageExists):
+			return echo.NewHTTPError(http.StatusConflict, "a page with this slug already exists under that parent")
+		case errors.Is(err, services.ErrInvalidParent),
+			errors.Is(err, services.ErrInvalidOwner),
+			errors.Is(err, services.ErrInvalidReviewDate),
+			errors.Is(err, services.ErrDescriptionLength),
+			errors.Is(err, services.ErrInvalidImageURL),
+			errors.Is(err, services.ErrSlugTooDeep),
+			errors.Is(err, services.ErrSlugTooLong):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update page properties")
+	}
+
+	if req.IsPublished != nil || req.Parent != nil || req.Tags != nil {
+		h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+	}
+
+	props, err := h.wikiService.PageProperties(ctx, result.Page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page properties")
+	}
+	return success(c, props)
+}
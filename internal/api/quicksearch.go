@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+)
+
+// QuickSearch matches page titles and slugs for quick-open palettes. It is
+// cheaper than full-text search and tolerates abbreviations.
+func (h *Handlers) QuickSearch(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := GetAPIUser(c)
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	results, err := h.wikiService.QuickSearch(ctx, c.QueryParam("q"), limit, func(page *models.Page) bool {
+		return policy.CanView(user, page)
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "search failed")
+	}
+	if user != nil {
+		results = h.wikiService.BoostRecentlyViewed(ctx, user.ID, results)
+	}
+	return success(c, results)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// Revision handlers
+
+// DiffResponse is a line diff between two versions of a page. To is nil
+// when the diff is against the page's current content.
+type DiffResponse struct {
+	From int64  `json:"from"`
+	To   *int64 `json:"to"`
+	services.ContentDiff
+}
+
+// ListRevisions returns a page's revisions, newest first, filtered by
+// author and a from/to date range.
+func (h *Handlers) ListRevisions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.revisionPage(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := services.ParseRevisionFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date: use YYYY-MM-DD")
+	}
+
+	total, err := h.wikiService.CountPageRevisions(ctx, page.ID, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to count revisions")
+	}
+
+	limit, offset := pageParams(c)
+	filter.Limit, filter.Offset = limit, offset
+
+	revisions, err := h.wikiService.GetPageRevisions(ctx, page.ID, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list revisions")
+	}
+	if revisions == nil {
+		revisions = []models.RevisionSummary{}
+	}
+
+	f := h.timeFormat(c)
+	for i := range revisions {
+		revisions[i].Display = displayTimes(f, map[string]time.Time{"created_at": revisions[i].CreatedAt})
+	}
+
+	return paginated(c, revisions, total, limit, offset)
+}
+
+// GetRevision returns one revision of a page, with its content.
+func (h *Handlers) GetRevision(c echo.Context) error {
+	page, err := h.revisionPage(c)
+	if err != nil {
+		return err
+	}
+
+	rev, err := h.pageRevision(c, page, c.Param("id"))
+	if err != nil {
+		return err
+	}
+	rev.Display = displayTimes(h.timeFormat(c), map[string]time.Time{"created_at": rev.CreatedAt})
+
+	return success(c, rev)
+}
+
+// DiffRevisions compares two revisions of a page. from is required; to
+// defaults to the page's current content.
+func (h *Handlers) DiffRevisions(c echo.Context) error {
+	page, err := h.revisionPage(c)
+	if err != nil {
+		return err
+	}
+
+	if c.QueryParam("from") == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "from is required")
+	}
+	from, err := h.pageRevision(c, page, c.QueryParam("from"))
+	if err != nil {
+		return err
+	}
+
+	resp := DiffResponse{From: from.ID}
+	toName, toContent := "current", page.Content
+	if c.QueryParam("to") != "" {
+		to, err := h.pageRevision(c, page, c.QueryParam("to"))
+		if err != nil {
+			return err
+		}
+		resp.To = &to.ID
+		toName, toContent = "revision "+strconv.FormatInt(to.ID, 10), to.Content
+	}
+
+	resp.ContentDiff, err = services.DiffContent("revision "+strconv.FormatInt(from.ID, 10), toName, from.Content, toContent)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to diff revisions")
+	}
+
+	return success(c, resp)
+}
+
+// RevertRevision restores a page's content to a revision. The current
+// content is kept as a new revision, as with any edit.
+func (h *Handlers) RevertRevision(c echo.Context) error {
+	user := GetAPIUser(c)
+	page, err := h.revisionPage(c)
+	if err != nil {
+		return err
+	}
+	if page.IsArchived() {
+		return echo.NewHTTPError(http.StatusConflict, services.ErrPageArchived.Error())
+	}
+	if !policy.CanEdit(user, page) {
+		return echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
+	rev, err := h.pageRevision(c, page, c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	page, err = h.wikiService.RevertToRevision(ctx, rev.ID, user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrPageArchived) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revert page")
+	}
+
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, page, user)
+
+	page, err = h.db.GetPageByID(ctx, page.ID)
+	if err != nil || page == nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+
+	return success(c, h.viewPage(c, page))
+}
+
+// revisionPage loads the page named in the URL.
+func (h *Handlers) revisionPage(c echo.Context) (*models.Page, error) {
+	page, err := h.db.GetPageBySlug(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get page")
+	}
+	if !policy.CanView(GetAPIUser(c), page) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "page not found")
+	}
+	return page, nil
+}
+
+// pageRevision loads a revision by ID, treating revisions of other pages as
+// missing.
+func (h *Handlers) pageRevision(c echo.Context, page *models.Page, id string) (*models.Revision, error) {
+	revID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid revision id")
+	}
+
+	rev, err := h.wikiService.GetRevision(c.Request().Context(), revID)
+	if err != nil {
+		if errors.Is(err, services.ErrRevisionNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "revision not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get revision")
+	}
+	if rev.PageID != page.ID {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "revision not found")
+	}
+	return rev, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"gowiki/internal/config"
 	"gowiki/internal/database"
+	"gowiki/internal/middleware"
 	"gowiki/internal/models"
 	"gowiki/internal/services"
 )
@@ -16,10 +17,17 @@ func RegisterRoutes(
 	cfg *config.Config,
 	authService *services.AuthService,
 	wikiService *services.WikiService,
+	webhooks *services.WebhookService,
+	announcements *services.AnnouncementService,
+	usage *services.APIUsageService,
+	promotion *services.PromotionService,
+	freshness *services.FreshnessService,
+	settings *services.SettingsService,
+	rateLimits middleware.RateLimitStore,
 ) {
 	// Create handlers and middleware
-	h := NewHandlers(db, cfg, authService, wikiService)
-	jwtMiddleware := NewJWTMiddleware(db, cfg)
+	h := NewHandlers(db, cfg, authService, wikiService, webhooks, announcements, usage, promotion, freshness, settings)
+	jwtMiddleware := NewJWTMiddleware(db, cfg, rateLimits)
 
 	// API group
 	api := e.Group("/api/v1")
@@ -27,18 +35,31 @@ func RegisterRoutes(
 	// Public routes (no auth required)
 	api.POST("/auth/login", h.Login)
 
+	// API documentation
+	docs := NewOpenAPI(e, cfg.Site.Name, cfg.Site.URL)
+	api.GET("/openapi.json", docs.Spec)
+	api.GET("/docs", docs.Docs)
+
 	// Routes with optional auth
 	optionalAuth := api.Group("")
 	optionalAuth.Use(jwtMiddleware.OptionalMiddleware())
+	optionalAuth.Use(UsageMiddleware(usage))
 	optionalAuth.GET("/pages", h.ListPages)
 	optionalAuth.GET("/pages/:slug", h.GetPage)
+	optionalAuth.GET("/pages/by-id/:id", h.GetPageByID)
+	optionalAuth.GET("/pages/:slug/export", h.ExportPage)
+	optionalAuth.GET("/pages/:slug/backlinks", h.GetBacklinks)
+	optionalAuth.GET("/pages/:slug/related", h.GetRelatedPages)
 	optionalAuth.GET("/tags", h.ListTags)
 	optionalAuth.GET("/tags/:name", h.GetTagPages)
 	optionalAuth.GET("/search", h.Search)
+	optionalAuth.GET("/quicksearch", h.QuickSearch)
+	optionalAuth.GET("/announcements", h.ListAnnouncements)
 
 	// Protected routes (auth required)
 	protected := api.Group("")
 	protected.Use(jwtMiddleware.Middleware())
+	protected.Use(UsageMiddleware(usage))
 
 	// Token refresh
 	protected.POST("/auth/refresh", h.RefreshToken)
@@ -46,20 +67,66 @@ func RegisterRoutes(
 	// Current user
 	protected.GET("/me", h.GetCurrentUser)
 
+	// Page properties
+	protected.GET("/pages/:slug/properties", h.GetPageProperties)
+
+	// View analytics, scoped to the caller's own pages unless an admin
+	protected.GET("/analytics", h.GetAnalytics)
+
 	// API tokens management
 	protected.POST("/tokens", h.CreateAPIToken)
 	protected.GET("/tokens", h.ListAPITokens)
 	protected.DELETE("/tokens/:id", h.DeleteAPIToken)
 
-	// Editor routes
+	// Announcements
+	protected.POST("/announcements/:id/dismiss", h.DismissAnnouncement)
+
+	// Editor routes (require the edit_page permission; creating and
+	// deleting have their own)
+	protected.POST("/pages", h.CreatePage, RequirePermission(models.PermCreatePage))
+	protected.DELETE("/pages/:slug", h.DeletePage, RequirePermission(models.PermDeletePage))
+	// Batch operations check each page's permissions
+	protected.POST("/pages/batch", h.BatchPages)
 	editor := protected.Group("")
-	editor.Use(RequireRole(models.RoleEditor))
-	editor.POST("/pages", h.CreatePage)
+	editor.Use(RequirePermission(models.PermEditPage))
 	editor.PUT("/pages/:slug", h.UpdatePage)
-	editor.DELETE("/pages/:slug", h.DeletePage)
+	editor.POST("/pages/:slug/archive", h.ArchivePage)
+	editor.POST("/pages/:slug/unarchive", h.UnarchivePage)
+	editor.PUT("/pages/:slug/draft", h.SaveDraft)
+	editor.DELETE("/pages/:slug/draft", h.DiscardDraft)
+	editor.POST("/pages/:slug/draft/publish", h.PublishDraft)
+	editor.PATCH("/pages/:slug/properties", h.UpdatePageProperties)
+	editor.GET("/pages/:slug/revisions", h.ListRevisions)
+	editor.GET("/pages/:slug/revisions/:id", h.GetRevision)
+	editor.GET("/pages/:slug/diff", h.DiffRevisions)
+	editor.POST("/pages/:slug/revisions/:id/revert", h.RevertRevision)
+	editor.POST("/tags/prune", h.PruneTags)
+	editor.PATCH("/tags/:name", h.UpdateTag)
+	editor.POST("/tags/:name/merge", h.MergeTag)
+	editor.DELETE("/tags/:name", h.DeleteTag)
+
+	// Share links (require the manage_shares permission and, for API
+	// tokens, the shares scope); handlers check the caller owns the link
+	shares := protected.Group("/shares")
+	shares.Use(RequirePermission(models.PermManageShares), RequireScope("shares"))
+	shares.GET("", h.ListShares)
+	shares.POST("", h.CreateShare)
+	shares.GET("/:id", h.GetShareStats)
+	shares.PATCH("/:id", h.UpdateShare)
+	shares.POST("/:id/revoke", h.RevokeShare)
+	shares.DELETE("/:id", h.DeleteShare)
+
+	// User management (requires the manage_users permission)
+	protected.GET("/admin/users", h.ListUsers, RequirePermission(models.PermManageUsers))
 
 	// Admin routes
 	admin := protected.Group("/admin")
-	admin.Use(RequireRole(models.RoleAdmin))
-	admin.GET("/users", h.ListUsers)
+	admin.Use(RequirePermission(models.PermAdminister))
+	admin.GET("/api-usage", h.APIUsageOverview)
+	admin.GET("/audit", h.ListAuditLog)
+	admin.GET("/promotion/bundle", h.ExportBundle)
+	admin.POST("/promotion", h.PromoteBundle)
+	admin.GET("/announcements", h.ListAllAnnouncements)
+	admin.POST("/announcements", h.CreateAnnouncement)
+	admin.DELETE("/announcements/:id", h.DeleteAnnouncement)
 }
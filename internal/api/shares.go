@@ -0,0 +1,304 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// CreateShareRequest creates a share link to a page, or to the pages with
+// a tag or found by a search. Zero limits mean unlimited.
+type CreateShareRequest struct {
+	// TargetType is "page" (the default), "tag" or "search".
+	TargetType string `json:"target_type,omitempty"`
+	// PageSlug is the page a page link shares.
+	PageSlug string `json:"page_slug,omitempty"`
+	// TargetRef is the tag or search query a collection link shares.
+	TargetRef       string `json:"target_ref,omitempty"`
+	IncludeChildren bool   `json:"include_children,omitempty"`
+	// GroupID limits the link to a group's members; omit it to share with
+	// anyone who has the link.
+	GroupID  *int64 `json:"group_id,omitempty"`
+	MaxViews int    `json:"max_views,omitempty"`
+	MaxIPs   int    `json:"max_ips,omitempty"`
+	// ExpiresIn is a duration from now such as "72h"; omitted or "never"
+	// for a link that doesn't expire.
+	ExpiresIn string `json:"expires_in,omitempty"`
+}
+
+// CreateShareResponse includes the link's URL, which is only shown once.
+type CreateShareResponse struct {
+	URL       string            `json:"url"`
+	ShareLink *models.ShareLink `json:"share_link"`
+}
+
+// ShareStatsResponse is a share link with its most recent accesses.
+type ShareStatsResponse struct {
+	ShareLink *models.ShareLink        `json:"share_link"`
+	Accesses  []models.ShareLinkAccess `json:"accesses"`
+}
+
+// UpdateShareRequest changes some of a share link's settings. Omitted
+// fields are left alone; zero limits mean unlimited.
+type UpdateShareRequest struct {
+	IncludeChildren *bool `json:"include_children,omitempty"`
+	MaxViews        *int  `json:"max_views,omitempty"`
+	MaxIPs          *int  `json:"max_ips,omitempty"`
+	// ExpiresIn is a duration from now such as "72h", or "never".
+	ExpiresIn *string `json:"expires_in,omitempty"`
+	Revoked   *bool   `json:"revoked,omitempty"`
+}
+
+// ListShares returns the caller's share links, or every link for admins,
+// newest first.
+func (h *Handlers) ListShares(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := GetAPIUser(c)
+	limit, offset := pageParams(c)
+
+	if policy.CanManageAllShares(user) {
+		links, err := h.db.ListAllShareLinks(ctx, limit, offset)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list share links")
+		}
+		total, err := h.db.CountShareLinks(ctx)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count share links")
+		}
+		return paginated(c, links, total, limit, offset)
+	}
+
+	links, err := h.db.GetShareLinksByUser(ctx, user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list share links")
+	}
+	total := len(links)
+	if offset > total {
+		offset = total
+	}
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+	return paginated(c, links[offset:end], total, limit, offset)
+}
+
+// CreateShare creates a share link and returns its URL, which can't be
+// recovered later: only a hash of its token is stored.
+func (h *Handlers) CreateShare(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := GetAPIUser(c)
+
+	var req CreateShareRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if req.MaxViews < 0 || req.MaxIPs < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, services.ErrInvalidShareUpdate.Error())
+	}
+	expiresIn, err := parseShareExpiry(req.ExpiresIn)
+	if err != nil {
+		return err
+	}
+
+	var group *models.Group
+	if req.GroupID != nil {
+		if group, err = h.db.GetGroup(ctx, *req.GroupID); err != nil || group == nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "group not found")
+		}
+	}
+
+	link := &models.ShareLink{
+		TargetType: models.ShareTargetPage,
+		CreatedBy:  user.ID,
+		MaxViews:   shareLimit(req.MaxViews),
+		MaxIPs:     shareLimit(req.MaxIPs),
+	}
+	switch req.TargetType {
+	case models.ShareTargetTag, models.ShareTargetSearch:
+		link.TargetType = req.TargetType
+		link.TargetRef = strings.TrimSpace(req.TargetRef)
+		if link.TargetRef == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "target_ref is required for tag and search links")
+		}
+		if !policy.CanShareCollection(user, group) {
+			return echo.NewHTTPError(http.StatusForbidden, "you can only share with groups you belong to")
+		}
+
+	case "", models.ShareTargetPage:
+		page, err := h.db.GetPageBySlug(ctx, req.PageSlug)
+		if err != nil || !policy.CanView(user, page) {
+			return echo.NewHTTPError(http.StatusNotFound, "page not found")
+		}
+		if !policy.CanShareWith(user, page, group) {
+			return echo.NewHTTPError(http.StatusForbidden, "this page can only be shared with the groups it's restricted to")
+		}
+		link.PageID, link.PageTitle, link.PageSlug = page.ID, page.Title, page.Slug
+		link.IncludeChildren = req.IncludeChildren
+
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, `target_type must be "page", "tag" or "search"`)
+	}
+	if expiresIn > 0 {
+		t := time.Now().UTC().Add(expiresIn)
+		link.ExpiresAt = &t
+	}
+	if group != nil {
+		link.GroupID, link.GroupName = &group.ID, group.Name
+	}
+
+	token, err := middleware.GenerateShareToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate share link")
+	}
+	link.TokenHash = middleware.HashToken(token)
+	if err := h.db.CreateShareLink(ctx, link); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create share link")
+	}
+	link.CreatorUsername = user.Username
+
+	details := services.ShareAuditDetails(link)
+	if !link.IsCollection() {
+		details["include_children"] = link.IncludeChildren
+	}
+	h.wikiService.Audit(ctx, "share_create", "share_link", &link.ID, details)
+
+	return created(c, CreateShareResponse{
+		URL:       fmt.Sprintf("%s/s/%s", strings.TrimRight(h.config.Site.URL, "/"), token),
+		ShareLink: link,
+	})
+}
+
+// GetShareStats returns a share link with its view and visitor counts and
+// its most recent accesses.
+func (h *Handlers) GetShareStats(c echo.Context) error {
+	link, err := h.managedShare(c)
+	if err != nil {
+		return err
+	}
+	limit, offset := pageParams(c)
+	accesses, err := h.db.GetShareLinkAccesses(c.Request().Context(), link.ID, limit, offset)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get share link accesses")
+	}
+	return success(c, ShareStatsResponse{ShareLink: link, Accesses: accesses})
+}
+
+// RevokeShare stops a share link from working. PATCH with "revoked": false
+// restores it.
+func (h *Handlers) RevokeShare(c echo.Context) error {
+	ctx := c.Request().Context()
+	link, err := h.managedShare(c)
+	if err != nil {
+		return err
+	}
+	if err := h.db.RevokeShareLink(ctx, link.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke share link")
+	}
+	h.wikiService.Audit(ctx, "share_revoke", "share_link", &link.ID, services.ShareAuditDetails(link))
+
+	link.IsRevoked = true
+	return success(c, link)
+}
+
+// DeleteShare permanently deletes a share link and its access records.
+func (h *Handlers) DeleteShare(c echo.Context) error {
+	ctx := c.Request().Context()
+	link, err := h.managedShare(c)
+	if err != nil {
+		return err
+	}
+	if err := h.db.DeleteShareLink(ctx, link.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete share link")
+	}
+	h.wikiService.Audit(ctx, "share_delete", "share_link", &link.ID, services.ShareAuditDetails(link))
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// UpdateShare changes a share link's expiry, limits or revocation without
+// changing its URL.
+func (h *Handlers) UpdateShare(c echo.Context) error {
+	ctx := c.Request().Context()
+	link, err := h.managedShare(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateShareRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	update := models.ShareLinkUpdate{
+		IncludeChildren: req.IncludeChildren,
+		MaxViews:        req.MaxViews,
+		MaxIPs:          req.MaxIPs,
+		Revoked:         req.Revoked,
+	}
+	if req.ExpiresIn != nil {
+		expiresIn, err := parseShareExpiry(*req.ExpiresIn)
+		if err != nil {
+			return err
+		}
+		update.ExpiresIn = &expiresIn
+	}
+
+	if err := h.wikiService.UpdateShareLink(ctx, GetAPIUser(c), link, update); err != nil {
+		if errors.Is(err, services.ErrInvalidShareUpdate) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, services.ErrShareNotAllowed) {
+			return echo.NewHTTPError(http.StatusForbidden, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update share link")
+	}
+	return success(c, link)
+}
+
+// managedShare returns the share link named by the id path parameter, if
+// the caller may manage it.
+func (h *Handlers) managedShare(c echo.Context) (*models.ShareLink, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid share link id")
+	}
+	link, err := h.db.GetShareLinkByID(c.Request().Context(), id)
+	if err != nil || link == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "share link not found")
+	}
+	if !policy.CanManageShare(GetAPIUser(c), link) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, "insufficient permissions")
+	}
+	return link, nil
+}
+
+// parseShareExpiry parses a share link expiry from now, where empty and
+// "never" mean the link doesn't expire (zero).
+func parseShareExpiry(value string) (time.Duration, error) {
+	if value == "" || value == "never" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, `expires_in must be a positive duration such as "72h", or "never"`)
+	}
+	return d, nil
+}
+
+// shareLimit converts a limit where zero means none to a share link's.
+func shareLimit(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// UpdateTagRequest renames a tag or files it under another. Omitted fields
+// are left alone.
+type UpdateTagRequest struct {
+	Name *string `json:"name,omitempty"`
+	// Parent is the parent tag's name, or "" for the top level.
+	Parent *string `json:"parent,omitempty"`
+}
+
+// MergeTagRequest names the tag to merge into.
+type MergeTagRequest struct {
+	Into string `json:"into"`
+}
+
+// PruneTagsResponse reports how many unused tags were deleted.
+type PruneTagsResponse struct {
+	Deleted int64 `json:"deleted"`
+}
+
+// UpdateTag renames a tag and changes its parent.
+func (h *Handlers) UpdateTag(c echo.Context) error {
+	ctx := c.Request().Context()
+	tag, err := h.tagParam(c, c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	var req UpdateTagRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Parent != nil {
+		var parent *models.Tag
+		if *req.Parent != "" {
+			if parent, err = h.tagParam(c, *req.Parent); err != nil {
+				return err
+			}
+		}
+		if err := h.wikiService.SetTagParent(ctx, tag, parent); err != nil {
+			return tagError(err, "failed to update tag")
+		}
+	}
+	if req.Name != nil {
+		if err := h.wikiService.RenameTag(ctx, tag, *req.Name); err != nil {
+			return tagError(err, "failed to rename tag")
+		}
+	}
+
+	return success(c, tag)
+}
+
+// MergeTag moves a tag's pages to another tag and deletes it, returning
+// the merged tag.
+func (h *Handlers) MergeTag(c echo.Context) error {
+	ctx := c.Request().Context()
+	from, err := h.tagParam(c, c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	var req MergeTagRequest
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid request body")
+	}
+	if req.Into == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "into is required")
+	}
+	into, err := h.tagParam(c, req.Into)
+	if err != nil {
+		return err
+	}
+
+	if err := h.wikiService.MergeTags(ctx, from, into); err != nil {
+		return tagError(err, "failed to merge tags")
+	}
+
+	// Reload for the new page count
+	if into, err = h.wikiService.GetTagByID(ctx, into.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load tag")
+	}
+	return success(c, into)
+}
+
+// DeleteTag deletes a tag no page has.
+func (h *Handlers) DeleteTag(c echo.Context) error {
+	tag, err := h.tagParam(c, c.Param("name"))
+	if err != nil {
+		return err
+	}
+
+	if err := h.wikiService.DeleteTag(c.Request().Context(), tag); err != nil {
+		return tagError(err, "failed to delete tag")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// PruneTags deletes every tag no page has.
+func (h *Handlers) PruneTags(c echo.Context) error {
+	n, err := h.wikiService.DeleteUnusedTags(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete unused tags")
+	}
+	return success(c, PruneTagsResponse{Deleted: n})
+}
+
+// tagParam loads a tag by name.
+func (h *Handlers) tagParam(c echo.Context, name string) (*models.Tag, error) {
+	tag, err := h.wikiService.GetTag(c.Request().Context(), name)
+	if errors.Is(err, services.ErrTagNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "tag not found")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to load tag")
+	}
+	return tag, nil
+}
+
+// tagError maps tag management errors to HTTP errors.
+func tagError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, services.ErrTagExists), errors.Is(err, services.ErrTagInUse):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrTagName),
+		errors.Is(err, services.ErrTagParent),
+		errors.Is(err, services.ErrTagMergeSelf):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, fallback)
+	}
+}
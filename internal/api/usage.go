@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+)
+
+// UsageMiddleware records each call made with an API token. JWT sessions are
+// not tracked; they expire quickly and aren't managed on the tokens page.
+func UsageMiddleware(usage *services.APIUsageService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			err := next(c)
+
+			token := GetAPIToken(c)
+			if token == nil {
+				return err
+			}
+
+			status := c.Response().Status
+			if err != nil {
+				var he *echo.HTTPError
+				if errors.As(err, &he) {
+					status = he.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+			usage.Record(token.ID, c.Request().Method, c.Path(), status, c.RealIP())
+
+			return err
+		}
+	}
+}
+
+// APIUsageOverview lists request counts and anomaly flags for every token.
+func (h *Handlers) APIUsageOverview(c echo.Context) error {
+	usage, err := h.usage.Overview(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load API usage")
+	}
+	if usage == nil {
+		usage = []models.APITokenUsage{}
+	}
+	return success(c, usage)
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"gowiki/internal/timefmt"
 )
 
 // Config holds all application configuration.
@@ -19,12 +22,115 @@ type Config struct {
 	Site     SiteConfig
 	Upload   UploadConfig
 	Backup   BackupConfig
+	Snapshot SnapshotConfig
+	Tracing  TracingConfig
+	Cluster  ClusterConfig
+	Replica  ReplicaConfig
+	Diagram  DiagramConfig
+	Mail     MailConfig
+	Revision RevisionConfig
+	LDAP     LDAPConfig
+}
+
+// ReplicaConfig contains continuous SQLite replication settings.
+// Replication is handled by a managed Litestream process.
+type ReplicaConfig struct {
+	URL          string
+	Binary       string
+	SyncInterval time.Duration
+	MaxLag       time.Duration
+}
+
+// ClusterConfig contains settings for coordinating multiple replicas.
+type ClusterConfig struct {
+	NodeID       string
+	LeaderTTL    time.Duration
+	PollInterval time.Duration
+}
+
+// TracingConfig contains OpenTelemetry trace export settings.
+type TracingConfig struct {
+	Enabled     bool
+	Endpoint    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// DiagramConfig contains diagram rendering settings.
+type DiagramConfig struct {
+	// PlantUMLURL is a PlantUML server used to render plantuml code blocks,
+	// e.g. http://plantuml:8080. PlantUML blocks stay plain code when unset.
+	PlantUMLURL string
+	Timeout     time.Duration
+}
+
+// MailConfig contains outgoing email settings. Email is disabled when Host
+// is empty.
+type MailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// LDAPConfig contains directory sign-in settings. LDAP is disabled when URL
+// is empty; local accounts keep working either way.
+type LDAPConfig struct {
+	// URL is the directory server, e.g. ldaps://ldap.example.com:636.
+	URL      string
+	StartTLS bool
+	// SkipVerify accepts any server certificate. For testing only.
+	SkipVerify bool
+	// BindDN and BindPassword are the service account that searches for
+	// users; the search is anonymous when BindDN is empty.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter finds the entry of the user signing in, with {username}
+	// replaced by the escaped name they typed.
+	UserFilter   string
+	UsernameAttr string
+	EmailAttr    string
+	GroupAttr    string
+	// GroupRoles map directory groups to roles, as group=role pairs naming
+	// the group by its common name (cn). The first match wins.
+	GroupRoles []string
+	// DefaultRole is the role of directory users in none of GroupRoles;
+	// empty for the site's default role.
+	DefaultRole string
+	Timeout     time.Duration
+}
+
+// RevisionConfig contains the page revision retention policy. Revisions are
+// kept forever when both MaxCount and MaxAge are zero.
+type RevisionConfig struct {
+	// MaxCount is how many revisions each page keeps.
+	MaxCount int
+	// MaxAge is how long revisions are kept.
+	MaxAge time.Duration
+	// KeepLatest is how many of each page's newest revisions are kept
+	// regardless of the other limits.
+	KeepLatest int
 }
 
 // BackupConfig contains markdown backup settings.
 type BackupConfig struct {
-	Enabled bool
-	Path    string
+	Enabled   bool
+	Path      string
+	Git       bool
+	GitRemote string
+	GitBranch string
+	GitPush   bool
+}
+
+// SnapshotConfig contains scheduled database snapshot settings.
+type SnapshotConfig struct {
+	Enabled    bool
+	Path       string
+	Interval   time.Duration
+	KeepDaily  int
+	KeepWeekly int
 }
 
 // ServerConfig contains HTTP server settings.
@@ -34,11 +140,42 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+	DrainDelay      time.Duration
+	DrainTimeout    time.Duration
+	ReusePort       bool
+
+	// TLSCert and TLSKey are PEM files to serve HTTPS with. They are
+	// reloaded when they change, so renewed certificates need no restart.
+	TLSCert string
+	TLSKey  string
+	// ACMEDomains turns on automatic certificates from Let's Encrypt for
+	// these host names, stored in ACMECacheDir.
+	ACMEDomains  []string
+	ACMEEmail    string
+	ACMECacheDir string
+	// HTTPRedirectAddr is where plain HTTP is answered with a redirect to
+	// HTTPS, and ACME challenges are served; empty turns it off.
+	HTTPRedirectAddr string
+
+	// Compression lists the response encodings to offer, preferred first
+	// ("br", "gzip"); "off" disables compression.
+	Compression        []string
+	CompressionMinSize int      // smaller responses are sent uncompressed
+	CompressionTypes   []string // media types to compress; "text/*" matches a family
+	GzipLevel          int
+	BrotliLevel        int
 }
 
+// defaultCompressionTypes are the text formats the wiki serves.
+const defaultCompressionTypes = "text/*,application/json,application/javascript,application/xml,application/atom+xml,application/rss+xml,application/manifest+json,image/svg+xml"
+
 // DatabaseConfig contains database connection settings.
 type DatabaseConfig struct {
+	// Driver is "sqlite" or "postgres". SQLite uses Path; PostgreSQL
+	// connects to URL.
+	Driver          string
 	Path            string
+	URL             string
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
@@ -58,6 +195,57 @@ type SecurityConfig struct {
 	LoginMaxAttempts    int
 	LoginLockoutTime    time.Duration
 	APITokenExpiry      time.Duration
+
+	// SecretKeyGenerated is set when WIKI_SECRET_KEY was empty and a
+	// random key was generated for this process.
+	SecretKeyGenerated bool
+
+	// CSPStrictReportOnly additionally sends a policy without 'unsafe-inline'
+	// in report-only mode to collect violations before tightening the CSP.
+	CSPStrictReportOnly bool
+	// IPRetention is how long client IP addresses are kept in audit and share
+	// logs before they are anonymized. Zero keeps them indefinitely.
+	IPRetention time.Duration
+	// ShareAccessRetention is how long share link visits are kept. Zero
+	// keeps them indefinitely.
+	ShareAccessRetention time.Duration
+	// AuditActivity records page edits, share links, sign-ins and API token
+	// creation in the audit log, in addition to administrative actions.
+	AuditActivity bool
+	// PromotionKey signs content bundles promoted from one wiki to another,
+	// such as staging to production. Both wikis need the same key; promotion
+	// is disabled while it is empty.
+	PromotionKey string
+	// TrustedProxies lists the addresses, as IPs or CIDR ranges, of the
+	// reverse proxies in front of the wiki. Client IPs are taken from
+	// X-Forwarded-For only when the request came through one of them.
+	TrustedProxies []string
+	// IPAllow, when set, admits only clients in these ranges to the paths
+	// under IPAllowPaths, or to every path when that is empty. IPDeny
+	// turns clients away everywhere.
+	IPAllow      []string
+	IPAllowPaths []string
+	IPDeny       []string
+	// BlockedCountries are ISO country codes turned away, read from
+	// CountryHeader as set by a CDN such as Cloudflare (CF-IPCountry).
+	// The header is only believed from trusted proxies.
+	CountryHeader    string
+	BlockedCountries []string
+	// RateLimitStore keeps rate limit counters and sign-in lockouts in
+	// "memory", or in the "database" so they survive restarts and are
+	// shared by replicas.
+	RateLimitStore string
+	// RateLimitRoutes are stricter limits for paths under a prefix, as
+	// PREFIX=REQUESTS/WINDOW such as /login=10/1m. They count on top of
+	// the overall limit.
+	RateLimitRoutes []string
+}
+
+// RouteRateLimit limits requests to the paths under Prefix.
+type RouteRateLimit struct {
+	Prefix   string
+	Requests int
+	Window   time.Duration
 }
 
 // SiteConfig contains site-wide settings.
@@ -67,6 +255,31 @@ type SiteConfig struct {
 	AllowRegistration bool
 	DefaultRole       string
 	RequireAuth       bool
+	Math              bool
+
+	// RequireEmailVerification makes self-registered users confirm their
+	// email address before they can sign in. It needs mail configured.
+	RequireEmailVerification bool
+
+	// MaxSlugDepth and MaxSlugLength limit how deeply pages nest and how
+	// long their slugs get. Zero means no limit.
+	MaxSlugDepth  int
+	MaxSlugLength int
+
+	// EmbedOrigins are the other sites, such as internal portals, allowed
+	// to load the /fragments navigation widgets cross-origin.
+	EmbedOrigins []string
+
+	// Timezone and Locale format times for visitors and for users who
+	// haven't chosen their own.
+	Timezone string
+	Locale   string
+
+	// Language is the interface language for visitors whose browser asks
+	// for none the wiki has, and LocalesDir holds extra <tag>.json message
+	// catalogs read at startup.
+	Language   string
+	LocalesDir string
 }
 
 // UploadConfig contains file upload settings.
@@ -75,20 +288,54 @@ type UploadConfig struct {
 	MaxSize       int64
 	AllowedTypes  []string
 	AllowedExtens []string
+
+	// SignedURLTTL is how long signed upload URLs stay valid.
+	SignedURLTTL time.Duration
+	// HotlinkProtection rejects anonymous requests for uploads that are
+	// referred by other sites.
+	HotlinkProtection bool
+	// OrphanAge is how old an upload no page, revision, draft or setting
+	// links to must be before it is deleted. Zero keeps such files.
+	OrphanAge time.Duration
 }
 
-// Load reads configuration from environment variables with sensible defaults.
+// Load reads configuration from environment variables and the file named by
+// WIKI_CONFIG, with sensible defaults.
 func Load() (*Config, error) {
-	cfg := &Config{
+	cfg, _, err := load()
+	return cfg, err
+}
+
+// build reads every setting into a new configuration.
+func build() *Config {
+	return &Config{
 		Server: ServerConfig{
 			Port:            getEnvInt("WIKI_PORT", 8080),
 			Host:            getEnv("WIKI_HOST", "0.0.0.0"),
 			ReadTimeout:     getEnvDuration("WIKI_READ_TIMEOUT", 30*time.Second),
 			WriteTimeout:    getEnvDuration("WIKI_WRITE_TIMEOUT", 30*time.Second),
 			ShutdownTimeout: getEnvDuration("WIKI_SHUTDOWN_TIMEOUT", 10*time.Second),
+			DrainDelay:      getEnvDuration("WIKI_DRAIN_DELAY", 0),
+			DrainTimeout:    getEnvDuration("WIKI_DRAIN_TIMEOUT", 60*time.Second),
+			ReusePort:       getEnvBool("WIKI_REUSE_PORT", false),
+
+			TLSCert:          getEnv("WIKI_TLS_CERT", ""),
+			TLSKey:           getEnv("WIKI_TLS_KEY", ""),
+			ACMEDomains:      getEnvList("WIKI_ACME_DOMAINS", ""),
+			ACMEEmail:        getEnv("WIKI_ACME_EMAIL", ""),
+			ACMECacheDir:     getEnv("WIKI_ACME_CACHE_DIR", "./data/acme"),
+			HTTPRedirectAddr: getEnv("WIKI_HTTP_REDIRECT_ADDR", ""),
+
+			Compression:        getEnvList("WIKI_COMPRESSION", "br,gzip"),
+			CompressionMinSize: getEnvInt("WIKI_COMPRESSION_MIN_SIZE", 1024),
+			CompressionTypes:   getEnvList("WIKI_COMPRESSION_TYPES", defaultCompressionTypes),
+			GzipLevel:          getEnvInt("WIKI_GZIP_LEVEL", 5),
+			BrotliLevel:        getEnvInt("WIKI_BROTLI_LEVEL", 4),
 		},
 		Database: DatabaseConfig{
+			Driver:          strings.ToLower(getEnv("WIKI_DB_DRIVER", "sqlite")),
 			Path:            getEnv("WIKI_DB_PATH", "./data/wiki.db"),
+			URL:             getEnv("WIKI_DB_URL", ""),
 			MaxOpenConns:    getEnvInt("WIKI_DB_MAX_OPEN", 25),
 			MaxIdleConns:    getEnvInt("WIKI_DB_MAX_IDLE", 5),
 			ConnMaxLifetime: getEnvDuration("WIKI_DB_CONN_LIFETIME", 5*time.Minute),
@@ -106,12 +353,36 @@ func Load() (*Config, error) {
 			LoginMaxAttempts:  getEnvInt("WIKI_LOGIN_MAX_ATTEMPTS", 5),
 			LoginLockoutTime:  getEnvDuration("WIKI_LOGIN_LOCKOUT", 15*time.Minute),
 			APITokenExpiry:    getEnvDuration("WIKI_API_TOKEN_EXPIRY", 90*24*time.Hour), // 90 days
+
+			CSPStrictReportOnly:  getEnvBool("WIKI_CSP_STRICT_REPORT_ONLY", false),
+			IPRetention:          getEnvDuration("WIKI_IP_RETENTION", 90*24*time.Hour),
+			ShareAccessRetention: getEnvDuration("WIKI_SHARE_ACCESS_RETENTION", 365*24*time.Hour),
+			PromotionKey:         getEnv("WIKI_PROMOTION_KEY", ""),
+			TrustedProxies:       getEnvList("WIKI_TRUSTED_PROXIES", ""),
+			IPAllow:              getEnvList("WIKI_IP_ALLOW", ""),
+			IPAllowPaths:         getEnvList("WIKI_IP_ALLOW_PATHS", ""),
+			IPDeny:               getEnvList("WIKI_IP_DENY", ""),
+			CountryHeader:        getEnv("WIKI_COUNTRY_HEADER", ""),
+			BlockedCountries:     getEnvList("WIKI_BLOCKED_COUNTRIES", ""),
+			AuditActivity:        getEnvBool("WIKI_AUDIT_ACTIVITY", true),
+			RateLimitStore:       getEnv("WIKI_RATE_LIMIT_STORE", "memory"),
+			RateLimitRoutes:      getEnvList("WIKI_RATE_LIMIT_ROUTES", "/login=20/1m,/api/v1/auth=20/1m"),
 		},
 		Site: SiteConfig{
 			Name:              getEnv("WIKI_SITE_NAME", "GoWiki"),
 			URL:               getEnv("WIKI_SITE_URL", "http://localhost:8080"),
 			AllowRegistration: getEnvBool("WIKI_ALLOW_REGISTRATION", false),
 			DefaultRole:       getEnv("WIKI_DEFAULT_ROLE", "viewer"),
+			Math:              getEnvBool("WIKI_MATH", false),
+			MaxSlugDepth:      getEnvInt("WIKI_MAX_SLUG_DEPTH", 10),
+			MaxSlugLength:     getEnvInt("WIKI_MAX_SLUG_LENGTH", 200),
+			Timezone:          getEnv("WIKI_TIMEZONE", "UTC"),
+			Locale:            getEnv("WIKI_LOCALE", "en-US"),
+			Language:          getEnv("WIKI_LANGUAGE", "en"),
+			LocalesDir:        getEnv("WIKI_LOCALES_DIR", ""),
+
+			RequireEmailVerification: getEnvBool("WIKI_REQUIRE_EMAIL_VERIFICATION", false),
+			EmbedOrigins:             getEnvList("WIKI_EMBED_ORIGINS", ""),
 		},
 		Upload: UploadConfig{
 			Path:    getEnv("WIKI_UPLOAD_PATH", "./uploads"),
@@ -130,18 +401,74 @@ func Load() (*Config, error) {
 				".jpg", ".jpeg", ".png", ".gif", ".webp", ".svg",
 				".pdf", ".txt", ".md",
 			},
+			SignedURLTTL:      getEnvDuration("WIKI_UPLOAD_URL_TTL", time.Hour),
+			HotlinkProtection: getEnvBool("WIKI_UPLOAD_HOTLINK_PROTECTION", true),
+			OrphanAge:         getEnvDuration("WIKI_UPLOAD_ORPHAN_AGE", 0),
 		},
 		Backup: BackupConfig{
-			Enabled: getEnvBool("WIKI_BACKUP_ENABLED", true),
-			Path:    getEnv("WIKI_BACKUP_PATH", "./backups"),
+			Enabled:   getEnvBool("WIKI_BACKUP_ENABLED", true),
+			Path:      getEnv("WIKI_BACKUP_PATH", "./backups"),
+			Git:       getEnvBool("WIKI_BACKUP_GIT", false),
+			GitRemote: getEnv("WIKI_BACKUP_GIT_REMOTE", ""),
+			GitBranch: getEnv("WIKI_BACKUP_GIT_BRANCH", "main"),
+			GitPush:   getEnvBool("WIKI_BACKUP_GIT_PUSH", false),
+		},
+		Snapshot: SnapshotConfig{
+			Enabled:    getEnvBool("WIKI_SNAPSHOT_ENABLED", true),
+			Path:       getEnv("WIKI_SNAPSHOT_PATH", "./data/snapshots"),
+			Interval:   getEnvDuration("WIKI_SNAPSHOT_INTERVAL", 24*time.Hour),
+			KeepDaily:  getEnvInt("WIKI_SNAPSHOT_KEEP_DAILY", 7),
+			KeepWeekly: getEnvInt("WIKI_SNAPSHOT_KEEP_WEEKLY", 4),
+		},
+		Tracing: TracingConfig{
+			Enabled:     getEnvBool("WIKI_TRACING_ENABLED", false),
+			Endpoint:    getEnv("WIKI_OTLP_ENDPOINT", getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318")),
+			ServiceName: getEnv("WIKI_TRACING_SERVICE_NAME", getEnv("OTEL_SERVICE_NAME", "gowiki")),
+			SampleRatio: getEnvFloat("WIKI_TRACING_SAMPLE_RATIO", 1.0),
+		},
+		Cluster: ClusterConfig{
+			NodeID:       getEnv("WIKI_NODE_ID", ""),
+			LeaderTTL:    getEnvDuration("WIKI_LEADER_TTL", 30*time.Second),
+			PollInterval: getEnvDuration("WIKI_CLUSTER_POLL", 2*time.Second),
+		},
+		Replica: ReplicaConfig{
+			URL:          getEnv("WIKI_REPLICA_URL", ""),
+			Binary:       getEnv("WIKI_LITESTREAM_BIN", "litestream"),
+			SyncInterval: getEnvDuration("WIKI_REPLICA_SYNC_INTERVAL", time.Second),
+			MaxLag:       getEnvDuration("WIKI_REPLICA_MAX_LAG", time.Minute),
+		},
+		Diagram: DiagramConfig{
+			PlantUMLURL: strings.TrimRight(getEnv("WIKI_PLANTUML_URL", ""), "/"),
+			Timeout:     getEnvDuration("WIKI_PLANTUML_TIMEOUT", 10*time.Second),
+		},
+		Mail: MailConfig{
+			Host:     getEnv("WIKI_SMTP_HOST", ""),
+			Port:     getEnvInt("WIKI_SMTP_PORT", 587),
+			Username: getEnv("WIKI_SMTP_USERNAME", ""),
+			Password: getEnv("WIKI_SMTP_PASSWORD", ""),
+			From:     getEnv("WIKI_MAIL_FROM", ""),
+		},
+		Revision: RevisionConfig{
+			MaxCount:   getEnvInt("WIKI_REVISION_MAX_COUNT", 0),
+			MaxAge:     getEnvDuration("WIKI_REVISION_MAX_AGE", 0),
+			KeepLatest: getEnvInt("WIKI_REVISION_KEEP_LATEST", 10),
+		},
+		LDAP: LDAPConfig{
+			URL:          getEnv("WIKI_LDAP_URL", ""),
+			StartTLS:     getEnvBool("WIKI_LDAP_START_TLS", false),
+			SkipVerify:   getEnvBool("WIKI_LDAP_SKIP_VERIFY", false),
+			BindDN:       getEnv("WIKI_LDAP_BIND_DN", ""),
+			BindPassword: getEnv("WIKI_LDAP_BIND_PASSWORD", ""),
+			BaseDN:       getEnv("WIKI_LDAP_BASE_DN", ""),
+			UserFilter:   getEnv("WIKI_LDAP_USER_FILTER", "(uid={username})"),
+			UsernameAttr: getEnv("WIKI_LDAP_USERNAME_ATTR", "uid"),
+			EmailAttr:    getEnv("WIKI_LDAP_EMAIL_ATTR", "mail"),
+			GroupAttr:    getEnv("WIKI_LDAP_GROUP_ATTR", "memberOf"),
+			GroupRoles:   getEnvList("WIKI_LDAP_GROUP_ROLES", ""),
+			DefaultRole:  getEnv("WIKI_LDAP_DEFAULT_ROLE", ""),
+			Timeout:      getEnvDuration("WIKI_LDAP_TIMEOUT", 10*time.Second),
 		},
 	}
-
-	if err := cfg.validate(); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
-	}
-
-	return cfg, nil
 }
 
 // validate checks that all required configuration is present and valid.
@@ -155,7 +482,8 @@ func (c *Config) validate() error {
 			errs = append(errs, "failed to generate secret key")
 		} else {
 			c.Security.SecretKey = key
-			fmt.Println("WARNING: No WIKI_SECRET_KEY set, using randomly generated key. Sessions will not persist across restarts.")
+			c.Security.SecretKeyGenerated = true
+			fmt.Fprintln(os.Stderr, "WARNING: No WIKI_SECRET_KEY set, using randomly generated key. Sessions will not persist across restarts.")
 		}
 	}
 
@@ -163,10 +491,93 @@ func (c *Config) validate() error {
 		errs = append(errs, "WIKI_SECRET_KEY must be at least 32 characters")
 	}
 
+	if c.Security.PromotionKey != "" && len(c.Security.PromotionKey) < 32 {
+		errs = append(errs, "WIKI_PROMOTION_KEY must be at least 32 characters")
+	}
+
+	for _, proxy := range c.Security.TrustedProxies {
+		if _, err := ParseIPRange(proxy); err != nil {
+			errs = append(errs, "WIKI_TRUSTED_PROXIES must list IP addresses or CIDR ranges such as 10.0.0.0/8")
+			break
+		}
+	}
+
+	for _, list := range []struct {
+		env    string
+		ranges []string
+	}{{"WIKI_IP_ALLOW", c.Security.IPAllow}, {"WIKI_IP_DENY", c.Security.IPDeny}} {
+		for _, r := range list.ranges {
+			if _, err := ParseIPRange(r); err != nil {
+				errs = append(errs, list.env+" must list IP addresses or CIDR ranges")
+				break
+			}
+		}
+	}
+
+	for _, path := range c.Security.IPAllowPaths {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, "WIKI_IP_ALLOW_PATHS must list paths starting with /, such as /admin")
+			break
+		}
+	}
+	if len(c.Security.IPAllowPaths) > 0 && len(c.Security.IPAllow) == 0 {
+		errs = append(errs, "WIKI_IP_ALLOW_PATHS needs WIKI_IP_ALLOW")
+	}
+
+	if len(c.Security.BlockedCountries) > 0 && (c.Security.CountryHeader == "" || len(c.Security.TrustedProxies) == 0) {
+		errs = append(errs, "WIKI_BLOCKED_COUNTRIES needs WIKI_COUNTRY_HEADER and the proxy that sets it in WIKI_TRUSTED_PROXIES")
+	}
+
+	if c.Security.RateLimitStore != "memory" && c.Security.RateLimitStore != "database" {
+		errs = append(errs, "WIKI_RATE_LIMIT_STORE must be memory or database")
+	}
+
+	for _, route := range c.Security.RateLimitRoutes {
+		if _, err := ParseRouteRateLimit(route); err != nil {
+			errs = append(errs, "WIKI_RATE_LIMIT_ROUTES must list limits such as /login=10/1m")
+			break
+		}
+	}
+
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		errs = append(errs, "WIKI_PORT must be between 1 and 65535")
 	}
 
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		errs = append(errs, "WIKI_TLS_CERT and WIKI_TLS_KEY must be set together")
+	}
+
+	if c.Server.TLSCert != "" && len(c.Server.ACMEDomains) > 0 {
+		errs = append(errs, "WIKI_ACME_DOMAINS can't be combined with WIKI_TLS_CERT")
+	}
+
+	// ACME HTTP-01 challenges arrive on port 80
+	if len(c.Server.ACMEDomains) > 0 && c.Server.HTTPRedirectAddr == "" {
+		c.Server.HTTPRedirectAddr = ":80"
+	}
+	if c.Server.HTTPRedirectAddr != "" && !c.TLSEnabled() {
+		errs = append(errs, "WIKI_HTTP_REDIRECT_ADDR needs WIKI_TLS_CERT or WIKI_ACME_DOMAINS")
+	}
+
+	if c.Server.DrainDelay < 0 || c.Server.DrainTimeout < 0 {
+		errs = append(errs, "WIKI_DRAIN_DELAY and WIKI_DRAIN_TIMEOUT must not be negative")
+	}
+
+	for _, enc := range c.Server.Compression {
+		if enc != "br" && enc != "gzip" && enc != "off" {
+			errs = append(errs, "WIKI_COMPRESSION must list br and gzip, or be off")
+			break
+		}
+	}
+
+	if c.Server.GzipLevel < 1 || c.Server.GzipLevel > 9 {
+		errs = append(errs, "WIKI_GZIP_LEVEL must be between 1 and 9")
+	}
+
+	if c.Server.BrotliLevel < 0 || c.Server.BrotliLevel > 11 {
+		errs = append(errs, "WIKI_BROTLI_LEVEL must be between 0 and 11")
+	}
+
 	if c.Security.BcryptCost < 10 || c.Security.BcryptCost > 31 {
 		errs = append(errs, "WIKI_BCRYPT_COST must be between 10 and 31")
 	}
@@ -176,6 +587,96 @@ func (c *Config) validate() error {
 		errs = append(errs, "WIKI_DEFAULT_ROLE must be one of: admin, editor, viewer")
 	}
 
+	if !timefmt.ValidZone(c.Site.Timezone) {
+		errs = append(errs, "WIKI_TIMEZONE must be an IANA timezone such as Europe/Berlin")
+	}
+
+	if _, ok := timefmt.FindLocale(c.Site.Locale); !ok {
+		errs = append(errs, "WIKI_LOCALE must be a supported locale such as en-US")
+	}
+
+	if c.Site.MaxSlugDepth < 0 || c.Site.MaxSlugLength < 0 {
+		errs = append(errs, "WIKI_MAX_SLUG_DEPTH and WIKI_MAX_SLUG_LENGTH must not be negative")
+	}
+
+	switch c.Database.Driver {
+	case "sqlite":
+	case "postgres":
+		if c.Database.URL == "" {
+			errs = append(errs, "WIKI_DB_URL is required when WIKI_DB_DRIVER is postgres")
+		}
+		if c.Replica.URL != "" {
+			errs = append(errs, "WIKI_REPLICA_URL replicates SQLite and can't be used with PostgreSQL")
+		}
+		// Snapshots copy the SQLite file; back up PostgreSQL with pg_dump
+		c.Snapshot.Enabled = false
+	default:
+		errs = append(errs, "WIKI_DB_DRIVER must be sqlite or postgres")
+	}
+
+	if c.Snapshot.Enabled && c.Snapshot.Interval < time.Minute {
+		errs = append(errs, "WIKI_SNAPSHOT_INTERVAL must be at least 1m")
+	}
+
+	if c.Snapshot.KeepDaily < 0 || c.Snapshot.KeepWeekly < 0 {
+		errs = append(errs, "WIKI_SNAPSHOT_KEEP_DAILY and WIKI_SNAPSHOT_KEEP_WEEKLY must not be negative")
+	}
+
+	if c.Revision.MaxCount < 0 || c.Revision.MaxAge < 0 {
+		errs = append(errs, "WIKI_REVISION_MAX_COUNT and WIKI_REVISION_MAX_AGE must not be negative")
+	}
+
+	if c.Revision.KeepLatest < 1 {
+		errs = append(errs, "WIKI_REVISION_KEEP_LATEST must be at least 1")
+	}
+
+	if c.Backup.GitPush && c.Backup.GitRemote == "" {
+		errs = append(errs, "WIKI_BACKUP_GIT_REMOTE is required when WIKI_BACKUP_GIT_PUSH is enabled")
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, "WIKI_TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if c.Cluster.LeaderTTL < 3*time.Second {
+		errs = append(errs, "WIKI_LEADER_TTL must be at least 3s")
+	}
+
+	if c.Cluster.PollInterval < 100*time.Millisecond {
+		errs = append(errs, "WIKI_CLUSTER_POLL must be at least 100ms")
+	}
+
+	if c.Replica.URL != "" && c.Replica.SyncInterval <= 0 {
+		errs = append(errs, "WIKI_REPLICA_SYNC_INTERVAL must be positive")
+	}
+
+	if c.LDAP.URL != "" {
+		if !strings.HasPrefix(c.LDAP.URL, "ldap://") && !strings.HasPrefix(c.LDAP.URL, "ldaps://") {
+			errs = append(errs, "WIKI_LDAP_URL must start with ldap:// or ldaps://")
+		}
+		if c.LDAP.StartTLS && strings.HasPrefix(c.LDAP.URL, "ldaps://") {
+			errs = append(errs, "WIKI_LDAP_START_TLS can't be combined with an ldaps:// WIKI_LDAP_URL")
+		}
+		if c.LDAP.BaseDN == "" {
+			errs = append(errs, "WIKI_LDAP_BASE_DN is required when WIKI_LDAP_URL is set")
+		}
+		if !strings.Contains(c.LDAP.UserFilter, "{username}") {
+			errs = append(errs, "WIKI_LDAP_USER_FILTER must contain {username}")
+		}
+		if c.LDAP.UsernameAttr == "" || c.LDAP.EmailAttr == "" {
+			errs = append(errs, "WIKI_LDAP_USERNAME_ATTR and WIKI_LDAP_EMAIL_ATTR must not be empty")
+		}
+		for _, mapping := range c.LDAP.GroupRoles {
+			if _, _, err := ParseLDAPGroupRole(mapping); err != nil {
+				errs = append(errs, "WIKI_LDAP_GROUP_ROLES must list mappings such as wiki-admins=admin")
+				break
+			}
+		}
+		if c.LDAP.Timeout <= 0 {
+			errs = append(errs, "WIKI_LDAP_TIMEOUT must be positive")
+		}
+	}
+
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, "; "))
 	}
@@ -188,51 +689,140 @@ func (c *Config) Address() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
 }
 
-// Helper functions for reading environment variables
+// ParseIPRange parses a CIDR range, or a single IP address as the range
+// holding just that address.
+func ParseIPRange(s string) (*net.IPNet, error) {
+	if _, ipNet, err := net.ParseCIDR(s); err == nil {
+		return ipNet, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or range %q", s)
+	}
+	bits := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// ParseRouteRateLimit parses a route limit such as /login=10/1m.
+func ParseRouteRateLimit(s string) (RouteRateLimit, error) {
+	prefix, limit, ok := strings.Cut(s, "=")
+	requests, window, ok2 := strings.Cut(limit, "/")
+	if !ok || !ok2 || !strings.HasPrefix(prefix, "/") {
+		return RouteRateLimit{}, fmt.Errorf("invalid route rate limit %q", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return RouteRateLimit{}, fmt.Errorf("invalid request count in %q", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return RouteRateLimit{}, fmt.Errorf("invalid window in %q", s)
+	}
+	return RouteRateLimit{Prefix: strings.TrimSuffix(prefix, "/"), Requests: n, Window: d}, nil
+}
+
+// ParseLDAPGroupRole parses a group to role mapping such as
+// wiki-admins=admin.
+func ParseLDAPGroupRole(s string) (group, role string, err error) {
+	group, role, ok := strings.Cut(s, "=")
+	group, role = strings.TrimSpace(group), strings.TrimSpace(role)
+	if !ok || group == "" || role == "" {
+		return "", "", fmt.Errorf("invalid LDAP group role %q", s)
+	}
+	return group, role, nil
+}
+
+// TLSEnabled reports whether the server serves HTTPS itself.
+func (c *Config) TLSEnabled() bool {
+	return c.Server.TLSCert != "" || len(c.Server.ACMEDomains) > 0
+}
+
+// Helper functions for reading environment variables and the config file
 
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key, defaultValue); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
 func getEnvInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key, strconv.Itoa(defaultValue)); value != "" {
 		if intVal, err := strconv.Atoi(value); err == nil {
 			return intVal
 		}
+		invalid(key, value, "a whole number")
 	}
 	return defaultValue
 }
 
 func getEnvInt64(key string, defaultValue int64) int64 {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key, strconv.FormatInt(defaultValue, 10)); value != "" {
 		if intVal, err := strconv.ParseInt(value, 10, 64); err == nil {
 			return intVal
 		}
+		invalid(key, value, "a whole number")
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := lookup(key, strconv.FormatFloat(defaultValue, 'g', -1, 64)); value != "" {
+		if floatVal, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatVal
+		}
+		invalid(key, value, "a number")
 	}
 	return defaultValue
 }
 
 func getEnvBool(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key, strconv.FormatBool(defaultValue)); value != "" {
 		if boolVal, err := strconv.ParseBool(value); err == nil {
 			return boolVal
 		}
+		invalid(key, value, "true or false")
 	}
 	return defaultValue
 }
 
+// getEnvList reads a comma-separated list, skipping empty items.
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
+	if value := lookup(key, formatDuration(defaultValue)); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
 			return duration
 		}
+		invalid(key, value, "a duration such as 90s, 15m or 24h")
 	}
 	return defaultValue
 }
 
+// formatDuration renders d without trailing zero units, e.g. 2160h
+// rather than 2160h0m0s.
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
 func generateRandomKey(length int) (string, error) {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A configuration file, named by WIKI_CONFIG, holds the same settings as
+// the environment variables, named without the WIKI_ prefix in lower case:
+// port for WIKI_PORT, db_path for WIKI_DB_PATH. Nested tables join their
+// keys with underscores, so
+//
+//	db:
+//	  path: /var/lib/wiki/wiki.db
+//
+// also sets WIKI_DB_PATH. Lists become comma-separated values. Environment
+// variables override the file.
+
+// Setting is one configuration value and where it came from.
+type Setting struct {
+	// Key is the setting's name in a configuration file, e.g. db_path.
+	Key string
+	// Env is its environment variable, e.g. WIKI_DB_PATH.
+	Env   string
+	Value string
+	// Source is "env", "file" or "default".
+	Source string
+}
+
+// loader tracks a single Load: the file's values, what was read, and
+// problems with values from the file.
+type loader struct {
+	path     string
+	file     map[string]string // by environment variable
+	fileKeys map[string]string // environment variable to key as written
+	settings []Setting
+	seen     map[string]bool
+	errs     []string
+}
+
+var (
+	loadMu  sync.Mutex
+	current *loader
+)
+
+// load reads the configuration file, if any, then builds the configuration
+// from the environment and the file.
+func load() (*Config, []Setting, error) {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	l := &loader{
+		file:     map[string]string{},
+		fileKeys: map[string]string{},
+		seen:     map[string]bool{},
+	}
+	if path := os.Getenv("WIKI_CONFIG"); path != "" {
+		if err := l.readFile(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	current = l
+	defer func() { current = nil }()
+
+	cfg := build()
+
+	for env, key := range l.fileKeys {
+		if !l.seen[env] {
+			l.errs = append(l.errs, fmt.Sprintf("%s: unknown setting %q", l.path, key))
+		}
+	}
+	sort.Strings(l.errs)
+	if err := cfg.validate(); err != nil {
+		l.errs = append(l.errs, l.annotate(err.Error()))
+	}
+	if len(l.errs) > 0 {
+		return nil, l.settings, fmt.Errorf("configuration validation failed: %s", strings.Join(l.errs, "; "))
+	}
+	return cfg, l.settings, nil
+}
+
+// Effective returns every setting's effective value and its source, with
+// secrets redacted. The error reports invalid settings; the settings are
+// returned regardless, unless the file couldn't be read.
+func Effective() ([]Setting, error) {
+	_, settings, err := load()
+	for i := range settings {
+		settings[i].Value = redact(settings[i].Env, settings[i].Value)
+	}
+	return settings, err
+}
+
+// secretPattern matches settings whose values are secrets.
+var secretPattern = regexp.MustCompile(`SECRET|PASSWORD|TOKEN|^WIKI_PROMOTION_KEY$`)
+
+func redact(env, value string) string {
+	if value == "" {
+		return ""
+	}
+	if secretPattern.MatchString(env) {
+		return "<redacted>"
+	}
+	// Database and replica URLs can carry a password
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+			return u.String()
+		}
+	}
+	return value
+}
+
+// lookup returns the value of the environment variable key, or the file's
+// value for it, or "" when neither sets it. It records the value, or
+// defaultValue, for Effective.
+func lookup(key, defaultValue string) string {
+	l := current
+	if l == nil {
+		return os.Getenv(key)
+	}
+	l.seen[key] = true
+
+	setting := Setting{Key: fileKey(key), Env: key, Value: defaultValue, Source: "default"}
+	value := os.Getenv(key)
+	if value != "" {
+		setting.Value, setting.Source = value, "env"
+	} else if v := l.file[key]; v != "" {
+		value = v
+		setting.Value, setting.Source = value, "file"
+	}
+	l.settings = append(l.settings, setting)
+	return value
+}
+
+// invalid reports a value from the file that isn't of the expected kind.
+// Invalid environment variables keep falling back to the default.
+func invalid(key, value, kind string) {
+	l := current
+	if l == nil || os.Getenv(key) != "" {
+		return
+	}
+	if _, ok := l.file[key]; ok {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %s must be %s, not %q", l.path, l.fileKeys[key], kind, value))
+	}
+}
+
+// envPattern matches the environment variables named in validation errors.
+var envPattern = regexp.MustCompile(`WIKI_[A-Z0-9_]+`)
+
+// annotate points validation errors about settings from the file at their
+// keys there.
+func (l *loader) annotate(msg string) string {
+	return envPattern.ReplaceAllStringFunc(msg, func(env string) string {
+		if key, ok := l.fileKeys[env]; ok && os.Getenv(env) == "" {
+			return fmt.Sprintf("%s (%s in %s)", env, key, l.path)
+		}
+		return env
+	})
+}
+
+// fileKey returns the key for the environment variable env.
+func fileKey(env string) string {
+	return strings.ToLower(strings.TrimPrefix(env, "WIKI_"))
+}
+
+// readFile loads the YAML or TOML file at path, by its extension.
+func (l *loader) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	l.path = path
+
+	var values map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = parseYAML(data)
+	case ".toml":
+		values, err = parseTOML(data)
+	default:
+		return fmt.Errorf("config file %s must end in .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for key, value := range values {
+		env := "WIKI_" + strings.ToUpper(key)
+		l.file[env] = value
+		l.fileKeys[env] = key
+	}
+	return nil
+}
+
+func parseYAML(data []byte) (map[string]string, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	if err := flatten(values, "", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flatten adds the scalars in table to values, joining the keys of nested
+// tables with underscores.
+func flatten(values map[string]string, prefix string, table map[string]interface{}) error {
+	for key, value := range table {
+		key = prefix + key
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(values, key+"_", v); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, err := scalar(key, item)
+				if err != nil {
+					return err
+				}
+				items = append(items, s)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(key, v)
+			if err != nil {
+				return err
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("%s must be a string, number, boolean or list of them", key)
+}
+
+// parseTOML reads the subset of TOML configuration needs: [tables], and
+// keys set to strings, numbers, booleans or single-line arrays of them.
+func parseTOML(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+	prefix := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header", n)
+			}
+			prefix = strings.ReplaceAll(strings.TrimSpace(line[1:len(line)-1]), ".", "_") + "_"
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		values[prefix+strings.ReplaceAll(key, ".", "_")] = value
+	}
+	return values, scanner.Err()
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("arrays must be on one line")
+		}
+		var items []string
+		for _, item := range splitTOMLArray(raw[1 : len(raw)-1]) {
+			v, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	}
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("unterminated string")
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err != nil {
+		return "", fmt.Errorf("invalid value %s; quote strings", raw)
+	}
+	return strings.ReplaceAll(raw, "_", ""), nil
+}
+
+// splitTOMLArray splits array items on commas outside quotes.
+func splitTOMLArray(s string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote && (quote == '\'' || i == 0 || s[i-1] != '\\') {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, s[start:])
+
+	trimmed := items[:0]
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
+}
+
+// stripTOMLComment removes a # comment that isn't inside a string.
+func stripTOMLComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote && (quote == '\'' || line[i-1] != '\\') {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// settingsCacheTTL bounds how long settings read from the database are
+// reused. Writes through this DB drop them at once; the TTL covers writes
+// by other processes that don't announce them.
+const settingsCacheTTL = 30 * time.Second
+
+// pageTreeCache keeps the navigation tree between page writes, so the
+// sidebar isn't rebuilt from every page on each request.
+type pageTreeCache struct {
+	mu    sync.RWMutex
+	tree  []*PageTreeNode
+	valid bool
+	// gen changes on every invalidation, so a load that raced a write
+	// doesn't store the tree it read before the write.
+	gen uint64
+}
+
+// settingsCache keeps the settings table in memory, since settings are read
+// on most requests and rarely written.
+type settingsCache struct {
+	mu       sync.RWMutex
+	values   map[string]string
+	loadedAt time.Time
+	// gen changes on every invalidation, as in pageTreeCache.
+	gen uint64
+}
+
+// pageHooks are called after this process changes pages.
+type pageHooks struct {
+	mu    sync.RWMutex
+	hooks []func()
+}
+
+// settingsHooks are called with the key after this process changes a
+// setting.
+type settingsHooks struct {
+	mu    sync.RWMutex
+	hooks []func(key string)
+}
+
+// GetPageTree returns the page tree for navigation, from the cache when no
+// page has changed since it was built. Pages restricted to groups are left
+// out. The tree is shared between callers and must not be modified.
+func (db *DB) GetPageTree(ctx context.Context) ([]*PageTreeNode, error) {
+	c := &db.pageTree
+	c.mu.RLock()
+	tree, valid, gen := c.tree, c.valid, c.gen
+	c.mu.RUnlock()
+	if valid {
+		return tree, nil
+	}
+
+	tree, err := db.loadPageTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.tree, c.valid = tree, true
+	}
+	c.mu.Unlock()
+	return tree, nil
+}
+
+// InvalidatePageTree drops the cached page tree. Writes through this DB do
+// it themselves; call it when another replica reports a change.
+func (db *DB) InvalidatePageTree() {
+	c := &db.pageTree
+	c.mu.Lock()
+	c.tree, c.valid = nil, false
+	c.gen++
+	c.mu.Unlock()
+}
+
+// OnPagesChanged registers fn to be called after pages are created,
+// renamed, moved, published, archived, restricted, retagged or deleted
+// through this DB, e.g. to tell other replicas to drop their caches.
+func (db *DB) OnPagesChanged(fn func()) {
+	db.pageHooks.mu.Lock()
+	defer db.pageHooks.mu.Unlock()
+	db.pageHooks.hooks = append(db.pageHooks.hooks, fn)
+}
+
+// pagesChanged invalidates page caches and runs the OnPagesChanged hooks.
+// Call it after the write has committed.
+func (db *DB) pagesChanged() {
+	db.InvalidatePageTree()
+
+	db.pageHooks.mu.RLock()
+	hooks := db.pageHooks.hooks
+	db.pageHooks.mu.RUnlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// settings returns every setting, from the cache while it is fresh. The map
+// is shared between callers and must not be modified.
+func (db *DB) settings(ctx context.Context) (map[string]string, error) {
+	c := &db.settingsCache
+	c.mu.RLock()
+	values, loadedAt, gen := c.values, c.loadedAt, c.gen
+	c.mu.RUnlock()
+	if values != nil && time.Since(loadedAt) < settingsCacheTTL {
+		return values, nil
+	}
+
+	values, err := db.loadSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.gen == gen {
+		c.values, c.loadedAt = values, time.Now()
+	}
+	c.mu.Unlock()
+	return values, nil
+}
+
+// InvalidateSettings drops the cached settings. Writes through this DB do
+// it themselves; call it when another replica reports a change.
+func (db *DB) InvalidateSettings() {
+	c := &db.settingsCache
+	c.mu.Lock()
+	c.values = nil
+	c.gen++
+	c.mu.Unlock()
+}
+
+// OnSettingsChanged registers fn to be called with the key after a setting
+// is written through this DB.
+func (db *DB) OnSettingsChanged(fn func(key string)) {
+	db.settingsHooks.mu.Lock()
+	defer db.settingsHooks.mu.Unlock()
+	db.settingsHooks.hooks = append(db.settingsHooks.hooks, fn)
+}
+
+// settingChanged invalidates the settings cache and runs the
+// OnSettingsChanged hooks. Call it after the write has committed.
+func (db *DB) settingChanged(key string) {
+	db.InvalidateSettings()
+
+	db.settingsHooks.mu.RLock()
+	hooks := db.settingsHooks.hooks
+	db.settingsHooks.mu.RUnlock()
+	for _, fn := range hooks {
+		fn(key)
+	}
+}
@@ -3,27 +3,55 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 
 	"gowiki/internal/config"
+	"gowiki/internal/tracing"
 )
 
 // DB wraps the SQL database connection with application-specific methods.
 type DB struct {
 	*sql.DB
 	config *config.DatabaseConfig
+	driver string
+
+	pageTree  pageTreeCache
+	pageHooks pageHooks
+
+	settingsCache settingsCache
+	settingsHooks settingsHooks
 }
 
-// New creates a new database connection.
+// New creates a new database connection, to the SQLite file at cfg.Path or
+// the PostgreSQL server at cfg.URL.
 func New(cfg *config.DatabaseConfig) (*DB, error) {
-	// SQLite connection string with recommended settings for concurrent access
-	dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_cache_size=1000000000&_foreign_keys=true",
-		cfg.Path)
+	driver := cfg.Driver
+	if driver == "" {
+		driver = DriverSQLite
+	}
 
-	db, err := sql.Open("sqlite3", dsn)
+	var db *sql.DB
+	var err error
+	switch driver {
+	case DriverSQLite:
+		// SQLite connection string with recommended settings for concurrent access
+		dsn := fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=5000&_synchronous=NORMAL&_cache_size=1000000000&_foreign_keys=true",
+			cfg.Path)
+		db, err = sql.Open("sqlite3", dsn)
+	case DriverPostgres:
+		db, err = sql.Open("postgres", cfg.URL)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", driver)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -42,7 +70,61 @@ func New(cfg *config.DatabaseConfig) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{DB: db, config: cfg}, nil
+	return &DB{DB: db, config: cfg, driver: driver}, nil
+}
+
+// ExecContext executes a statement inside a database span.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	query, args = db.rebind(query), db.bindArgs(args)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return result, err
+}
+
+// QueryContext runs a query inside a database span.
+// The span covers execution only, not iterating the returned rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	query, args = db.rebind(query), db.bindArgs(args)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer span.End()
+
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	tracing.RecordError(span, err)
+	return rows, err
+}
+
+// QueryRowContext runs a single-row query inside a database span.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	query, args = db.rebind(query), db.bindArgs(args)
+	ctx, span := db.startQuerySpan(ctx, query)
+	defer span.End()
+
+	return db.DB.QueryRowContext(ctx, query, args...)
+}
+
+// startQuerySpan starts a client span named after the SQL operation.
+func (db *DB) startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if !tracing.Enabled() {
+		return ctx, noop.Span{}
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	operation := statement
+	if i := strings.IndexByte(statement, ' '); i > 0 {
+		operation = statement[:i]
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "db "+strings.ToUpper(operation), trace.WithSpanKind(trace.SpanKindClient))
+	if db.driver == DriverPostgres {
+		span.SetAttributes(attribute.String("db.system", "postgresql"))
+	} else {
+		span.SetAttributes(attribute.String("db.system", "sqlite"))
+	}
+	span.SetAttributes(attribute.String("db.statement", statement))
+	return ctx, span
 }
 
 // Close closes the database connection.
@@ -51,11 +133,12 @@ func (db *DB) Close() error {
 }
 
 // Transaction executes a function within a database transaction.
-func (db *DB) Transaction(ctx context.Context, fn func(*sql.Tx) error) error {
-	tx, err := db.BeginTx(ctx, nil)
+func (db *DB) Transaction(ctx context.Context, fn func(*Tx) error) error {
+	sqlTx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	tx := &Tx{Tx: sqlTx, db: db}
 
 	if err := fn(tx); err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
@@ -80,3 +163,16 @@ func (db *DB) HealthCheck(ctx context.Context) error {
 	}
 	return nil
 }
+
+// VacuumInto writes a consistent, compacted copy of the database to path.
+// The destination file must not already exist. Only SQLite databases can
+// be copied this way.
+func (db *DB) VacuumInto(ctx context.Context, path string) error {
+	if db.driver != DriverSQLite {
+		return errors.New("snapshots need SQLite; back up PostgreSQL with pg_dump")
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	return nil
+}
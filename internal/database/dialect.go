@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Supported database drivers.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Queries are written once, in SQLite's dialect, and rewritten for the
+// database they run on. The rewriting is deliberately small; queries that
+// need more than this branch on the driver instead:
+//
+//   - ? placeholders become $1, $2, ... on PostgreSQL.
+//   - LIKE becomes ILIKE, since SQLite's LIKE ignores case.
+//   - COLLATE NOCASE is dropped. The columns it is used with are citext on
+//     PostgreSQL, which compares them without case already.
+//
+// Booleans are stored as 0 and 1 on both, and bool arguments are bound as
+// integers, so queries compare them (`? = 1`) rather than using them as
+// conditions.
+
+// Driver returns the database driver the wiki runs on.
+func (db *DB) Driver() string {
+	return db.driver
+}
+
+// rebind rewrites query for the database's dialect.
+func (db *DB) rebind(query string) string {
+	if db.driver != DriverPostgres {
+		return query
+	}
+	return rebindPostgres(query)
+}
+
+// bindArgs converts arguments for the database's driver.
+func (db *DB) bindArgs(args []interface{}) []interface{} {
+	if db.driver != DriverPostgres {
+		return args
+	}
+	var converted []interface{}
+	for i, arg := range args {
+		var value interface{}
+		switch v := arg.(type) {
+		case bool:
+			value = boolInt(v)
+		case *bool:
+			if v != nil {
+				value = boolInt(*v)
+			}
+		default:
+			continue
+		}
+		if converted == nil {
+			converted = append([]interface{}(nil), args...)
+		}
+		converted[i] = value
+	}
+	if converted == nil {
+		return args
+	}
+	return converted
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// rebindPostgres rewrites a SQLite query for PostgreSQL, leaving string
+// literals, quoted identifiers and comments alone.
+func rebindPostgres(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 2
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			i++
+		case isWordByte(c):
+			j := i
+			for j < len(query) && isWordByte(query[j]) {
+				j++
+			}
+			word := query[i:j]
+			switch {
+			case strings.EqualFold(word, "LIKE"):
+				b.WriteString("ILIKE")
+			case strings.EqualFold(word, "COLLATE"):
+				k := j
+				for k < len(query) && unicode.IsSpace(rune(query[k])) {
+					k++
+				}
+				if k+6 <= len(query) && strings.EqualFold(query[k:k+6], "NOCASE") && (k+6 == len(query) || !isWordByte(query[k+6])) {
+					j = k + 6
+				} else {
+					b.WriteString(word)
+				}
+			default:
+				b.WriteString(word)
+			}
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// Tx is a transaction whose queries are rewritten for the database's
+// dialect, like DB's.
+type Tx struct {
+	*sql.Tx
+	db *DB
+}
+
+// ExecContext executes a statement in the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.db.rebind(query), tx.db.bindArgs(args)...)
+}
+
+// QueryContext runs a query in the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.db.rebind(query), tx.db.bindArgs(args)...)
+}
+
+// QueryRowContext runs a single-row query in the transaction.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.db.rebind(query), tx.db.bindArgs(args)...)
+}
+
+// queryer runs queries on a DB or in a Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertReturningID runs an INSERT and returns the id of the new row. Both
+// SQLite and PostgreSQL support RETURNING; the PostgreSQL driver has no
+// LastInsertId.
+func insertReturningID(ctx context.Context, q queryer, query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, strings.TrimRight(query, " \t\n")+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSQLiteOnly is returned by maintenance operations that only apply to
+// SQLite databases. PostgreSQL is maintained with its own tools.
+var ErrSQLiteOnly = errors.New("only supported on SQLite; maintain PostgreSQL with its own tools")
+
+// StorageStats describes the pages of the SQLite database file.
+type StorageStats struct {
+	PageSize    int64
+	PageCount   int64
+	FreePages   int64
+	JournalMode string
+}
+
+// Size returns the size of the database file in bytes.
+func (s StorageStats) Size() int64 {
+	return s.PageSize * s.PageCount
+}
+
+// FreeBytes returns the space held by free pages, which VACUUM reclaims.
+func (s StorageStats) FreeBytes() int64 {
+	return s.PageSize * s.FreePages
+}
+
+// StorageStats reports the size and free space of the database file.
+func (db *DB) StorageStats(ctx context.Context) (*StorageStats, error) {
+	if db.driver != DriverSQLite {
+		return nil, ErrSQLiteOnly
+	}
+
+	stats := &StorageStats{}
+	for _, pragma := range []struct {
+		name string
+		dest interface{}
+	}{
+		{"page_size", &stats.PageSize},
+		{"page_count", &stats.PageCount},
+		{"freelist_count", &stats.FreePages},
+		{"journal_mode", &stats.JournalMode},
+	} {
+		if err := db.QueryRowContext(ctx, "PRAGMA "+pragma.name).Scan(pragma.dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", pragma.name, err)
+		}
+	}
+	return stats, nil
+}
+
+// Vacuum rebuilds the database file, reclaiming free pages. Writers wait
+// for it to finish.
+func (db *DB) Vacuum(ctx context.Context) error {
+	if db.driver != DriverSQLite {
+		return ErrSQLiteOnly
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	return nil
+}
+
+// CheckpointResult reports how much of the write-ahead log a checkpoint
+// copied into the database file. Once the log is truncated both frame
+// counts are zero.
+type CheckpointResult struct {
+	// Busy is set when readers or writers kept the checkpoint from finishing.
+	Busy bool
+	// LogFrames is the number of frames in the log.
+	LogFrames int
+	// CheckpointedFrames is the number of frames copied into the database.
+	CheckpointedFrames int
+}
+
+// Checkpoint copies the write-ahead log into the database file and
+// truncates the log.
+func (db *DB) Checkpoint(ctx context.Context) (*CheckpointResult, error) {
+	if db.driver != DriverSQLite {
+		return nil, ErrSQLiteOnly
+	}
+
+	var busy int
+	result := &CheckpointResult{}
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &result.LogFrames, &result.CheckpointedFrames); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	result.Busy = busy != 0
+	return result, nil
+}
+
+// IntegrityCheck verifies the database file and returns the problems
+// found, at most maxProblems of them. No problems means the file is sound.
+func (db *DB) IntegrityCheck(ctx context.Context, maxProblems int) ([]string, error) {
+	if db.driver != DriverSQLite {
+		return nil, ErrSQLiteOnly
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA integrity_check(%d)", maxProblems))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	return problems, rows.Err()
+}
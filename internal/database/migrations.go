@@ -2,7 +2,6 @@ package database
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"time"
 )
@@ -12,6 +11,62 @@ type Migration struct {
 	Version     int
 	Description string
 	SQL         string
+	// Postgres is the migration's SQL for PostgreSQL, when SQL does not run
+	// there as written.
+	Postgres string
+	// RebuildsTables runs the migration with foreign key enforcement off,
+	// so a referenced table can be recreated without cascading deletes.
+	// Foreign keys are checked before the migration commits.
+	RebuildsTables bool
+}
+
+// pagesFTSRows selects the search index row of each page: its title, slug,
+// tag names and content. The tokenizer splits slugs into their segments.
+const pagesFTSRows = `
+	SELECT p.id, p.title, p.slug,
+		COALESCE((SELECT group_concat(t.name, ' ') FROM page_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.page_id = p.id), ''),
+		p.content
+	FROM pages p`
+
+// pagesFTSTriggers keep pages_fts in sync with pages and their tags. They
+// are also run on their own to repair a database whose triggers were
+// dropped.
+const pagesFTSTriggers = `
+	-- Triggers to keep FTS index synchronized
+	CREATE TRIGGER IF NOT EXISTS pages_fts_insert AFTER INSERT ON pages BEGIN
+		INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + ` WHERE p.id = new.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS pages_fts_delete AFTER DELETE ON pages BEGIN
+		DELETE FROM pages_fts WHERE rowid = old.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS pages_fts_update AFTER UPDATE OF title, slug, content ON pages BEGIN
+		DELETE FROM pages_fts WHERE rowid = old.id;
+		INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + ` WHERE p.id = new.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS page_tags_fts_insert AFTER INSERT ON page_tags BEGIN
+		DELETE FROM pages_fts WHERE rowid = new.page_id;
+		INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + ` WHERE p.id = new.page_id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS page_tags_fts_delete AFTER DELETE ON page_tags BEGIN
+		DELETE FROM pages_fts WHERE rowid = old.page_id;
+		INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + ` WHERE p.id = old.page_id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS tags_fts_update AFTER UPDATE OF name ON tags BEGIN
+		DELETE FROM pages_fts WHERE rowid IN (SELECT page_id FROM page_tags WHERE tag_id = new.id);
+		INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + `
+		WHERE p.id IN (SELECT page_id FROM page_tags WHERE tag_id = new.id);
+	END;
+`
+
+// pagesFTSTriggerNames are the triggers pagesFTSTriggers creates.
+var pagesFTSTriggerNames = []string{
+	"pages_fts_insert", "pages_fts_delete", "pages_fts_update",
+	"page_tags_fts_insert", "page_tags_fts_delete", "tags_fts_update",
 }
 
 // migrations contains all database migrations in order.
@@ -258,16 +313,660 @@ var migrations = []Migration{
 			CREATE INDEX IF NOT EXISTS idx_share_access_ip ON share_link_access(share_link_id, ip_address);
 		`,
 	},
+	{
+		Version:     14,
+		Description: "Create notifications and review_requests tables",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS notifications (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				page_id INTEGER REFERENCES pages(id) ON DELETE CASCADE,
+				kind TEXT NOT NULL,
+				message TEXT NOT NULL DEFAULT '',
+				is_read INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id, is_read, created_at);
+
+			CREATE TABLE IF NOT EXISTS review_requests (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				requester_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				reviewer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				comment TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved')),
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				resolved_at DATETIME
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_review_requests_reviewer ON review_requests(reviewer_id, status);
+			CREATE INDEX IF NOT EXISTS idx_review_requests_page ON review_requests(page_id);
+		`,
+	},
+	{
+		Version:     15,
+		Description: "Create ip_rules table for bans and rate limit exemptions",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS ip_rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				cidr TEXT NOT NULL,
+				action TEXT NOT NULL CHECK (action IN ('ban', 'exempt')),
+				reason TEXT NOT NULL DEFAULT '',
+				created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				expires_at DATETIME,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_ip_rules_expires ON ip_rules(expires_at);
+		`,
+	},
+	{
+		Version:     16,
+		Description: "Create cluster lock and event tables for multi-replica coordination",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS cluster_locks (
+				name TEXT PRIMARY KEY,
+				owner TEXT NOT NULL,
+				acquired_at DATETIME NOT NULL,
+				expires_at DATETIME NOT NULL
+			);
+
+			CREATE TABLE IF NOT EXISTS cluster_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				topic TEXT NOT NULL,
+				payload TEXT NOT NULL DEFAULT '',
+				origin TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_cluster_events_created ON cluster_events(created_at);
+		`,
+	},
+	{
+		Version:     17,
+		Description: "Create webhooks and webhook_deliveries tables",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS webhooks (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				url TEXT NOT NULL,
+				secret TEXT NOT NULL,
+				events TEXT NOT NULL,
+				is_active BOOLEAN NOT NULL DEFAULT 1,
+				created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS webhook_deliveries (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+				event TEXT NOT NULL,
+				payload TEXT NOT NULL,
+				status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+				attempts INTEGER NOT NULL DEFAULT 0,
+				response_code INTEGER,
+				last_error TEXT NOT NULL DEFAULT '',
+				next_attempt_at DATETIME NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				delivered_at DATETIME
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+			CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+		`,
+	},
+	{
+		Version:     18,
+		Description: "Index revisions by time for the recent changes feed",
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_revisions_created ON revisions(created_at DESC);
+		`,
+	},
+	{
+		Version:     19,
+		Description: "Create csp_reports table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS csp_reports (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				document_uri TEXT NOT NULL DEFAULT '',
+				directive TEXT NOT NULL DEFAULT '',
+				blocked_uri TEXT NOT NULL DEFAULT '',
+				source_file TEXT NOT NULL DEFAULT '',
+				line_number INTEGER NOT NULL DEFAULT 0,
+				disposition TEXT NOT NULL DEFAULT 'enforce',
+				user_agent TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_csp_reports_created ON csp_reports(created_at);
+		`,
+	},
+	{
+		Version:     20,
+		Description: "Add archived_at to pages",
+		SQL: `
+			ALTER TABLE pages ADD COLUMN archived_at DATETIME;
+			CREATE INDEX IF NOT EXISTS idx_pages_archived ON pages(archived_at);
+		`,
+	},
+	{
+		Version:     21,
+		Description: "Add page link graph",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS page_links (
+				source_page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				target_slug TEXT NOT NULL,
+				kind TEXT NOT NULL DEFAULT 'wiki',
+				PRIMARY KEY (source_page_id, target_slug)
+			);
+			CREATE INDEX IF NOT EXISTS idx_page_links_target ON page_links(target_slug);
+		`,
+	},
+	{
+		Version:     22,
+		Description: "Add announcement dismissals",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS announcement_dismissals (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				announcement_id TEXT NOT NULL,
+				dismissed_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, announcement_id)
+			);
+		`,
+	},
+	{
+		Version:     23,
+		Description: "Add API token usage tracking",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS api_usage (
+				token_id INTEGER NOT NULL REFERENCES api_tokens(id) ON DELETE CASCADE,
+				hour DATETIME NOT NULL,
+				requests INTEGER NOT NULL DEFAULT 0,
+				errors INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (token_id, hour)
+			);
+			CREATE TABLE IF NOT EXISTS api_calls (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				token_id INTEGER NOT NULL REFERENCES api_tokens(id) ON DELETE CASCADE,
+				method TEXT NOT NULL,
+				path TEXT NOT NULL,
+				status INTEGER NOT NULL,
+				ip_address TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_api_calls_token ON api_calls(token_id, id);
+		`,
+	},
+	{
+		Version:     24,
+		Description: "Add editable email templates",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS email_templates (
+				key TEXT NOT NULL,
+				locale TEXT NOT NULL DEFAULT '',
+				subject TEXT NOT NULL,
+				body_text TEXT NOT NULL,
+				body_html TEXT NOT NULL DEFAULT '',
+				updated_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (key, locale)
+			);
+		`,
+	},
+	{
+		Version:     25,
+		Description: "Add user invitations",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS user_invites (
+				token_hash TEXT PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				expires_at DATETIME NOT NULL,
+				created_at DATETIME NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_invites_user ON user_invites(user_id);
+		`,
+	},
+	{
+		Version:     26,
+		Description: "Add page view counters",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS page_views (
+				page_id INTEGER PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+				views INTEGER NOT NULL DEFAULT 0,
+				last_viewed_at DATETIME NOT NULL
+			);
+		`,
+	},
+	{
+		Version:        27,
+		Description:    "Add custom roles with permission flags",
+		RebuildsTables: true,
+		SQL: `
+			CREATE TABLE IF NOT EXISTS roles (
+				name TEXT PRIMARY KEY COLLATE NOCASE,
+				description TEXT NOT NULL DEFAULT '',
+				permissions TEXT NOT NULL DEFAULT '',
+				built_in INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			INSERT OR IGNORE INTO roles (name, description, permissions, built_in) VALUES
+				('admin', 'Full access to the wiki and its administration',
+					'create_page,edit_page,delete_page,view_unpublished,upload_files,manage_shares,manage_users,administer', 1),
+				('editor', 'Create, edit and share pages',
+					'create_page,edit_page,delete_page,view_unpublished,upload_files,manage_shares', 1),
+				('viewer', 'Read published pages', '', 1);
+
+			-- Replace the fixed CHECK on users.role with a reference to roles
+			CREATE TABLE users_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				username TEXT UNIQUE NOT NULL COLLATE NOCASE,
+				email TEXT UNIQUE NOT NULL COLLATE NOCASE,
+				password_hash TEXT NOT NULL,
+				role TEXT NOT NULL DEFAULT 'viewer' REFERENCES roles(name) ON UPDATE CASCADE,
+				is_active INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_login_at DATETIME
+			);
+			INSERT INTO users_new (id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at)
+				SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at FROM users;
+			DROP TABLE users;
+			ALTER TABLE users_new RENAME TO users;
+
+			CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
+			CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+			CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
+		`,
+	},
+	{
+		Version:     28,
+		Description: "Add user groups, group-restricted pages and group share links",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS groups (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT UNIQUE NOT NULL COLLATE NOCASE,
+				description TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS group_members (
+				group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (group_id, user_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_group_members_user ON group_members(user_id);
+
+			-- A page with rows here is only visible to members of those groups
+			CREATE TABLE IF NOT EXISTS page_groups (
+				page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				group_id INTEGER NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+				PRIMARY KEY (page_id, group_id)
+			);
+			CREATE INDEX IF NOT EXISTS idx_page_groups_group ON page_groups(group_id);
+
+			-- Links shared with a group only open for its signed-in members
+			ALTER TABLE share_links ADD COLUMN group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE;
+		`,
+	},
+	{
+		Version:     29,
+		Description: "Track email verification",
+		SQL: `
+			-- NULL until the user follows the link in their verification email.
+			-- Existing accounts were created before verification and count as verified.
+			ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+			UPDATE users SET email_verified_at = created_at;
+		`,
+	},
+	{
+		Version:     30,
+		Description: "Add forced password change and account locks",
+		SQL: `
+			-- Set by an admin; the user must choose a new password before doing anything else.
+			ALTER TABLE users ADD COLUMN must_change_password BOOLEAN NOT NULL DEFAULT 0;
+			-- A lock blocks sign-in like deactivation, but records when and why.
+			ALTER TABLE users ADD COLUMN locked_at DATETIME;
+			ALTER TABLE users ADD COLUMN lock_reason TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     31,
+		Description: "Create page properties table",
+		SQL: `
+			-- Page attributes edited from the properties panel. A page without a
+			-- row is owned by its author and has no review date.
+			CREATE TABLE IF NOT EXISTS page_properties (
+				page_id INTEGER PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+				owner_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				review_at DATE,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_page_properties_review ON page_properties(review_at);
+		`,
+	},
+	{
+		Version:     32,
+		Description: "Track session activity",
+		SQL: `
+			-- Signed-in sessions live in the sessions table so users can see
+			-- and revoke them; last_seen_at is refreshed while they're used.
+			ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+		`,
+	},
+	{
+		Version:     33,
+		Description: "Store page excerpts",
+		SQL: `
+			-- Plain-text excerpts are extracted from the markdown when a page
+			-- is saved; existing pages are filled in by the next re-render.
+			ALTER TABLE pages ADD COLUMN excerpt TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     34,
+		Description: "Index page slugs and tags for search",
+		SQL: `
+			-- The index keeps its own copy of each page's title, slug, tag
+			-- names and content, so searches can weight each field.
+			DROP TRIGGER IF EXISTS pages_fts_insert;
+			DROP TRIGGER IF EXISTS pages_fts_delete;
+			DROP TRIGGER IF EXISTS pages_fts_update;
+			DROP TABLE IF EXISTS pages_fts;
+
+			CREATE VIRTUAL TABLE pages_fts USING fts5(
+				title,
+				slug,
+				tags,
+				content,
+				tokenize='porter unicode61'
+			);
+
+			INSERT INTO pages_fts(rowid, title, slug, tags, content)` + pagesFTSRows + `;
+		` + pagesFTSTriggers,
+	},
+	{
+		Version:     35,
+		Description: "Create page_drafts table",
+		SQL: `
+			-- A page's unpublished changes, kept apart from the live content
+			-- until an editor publishes or discards them.
+			CREATE TABLE IF NOT EXISTS page_drafts (
+				page_id INTEGER PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+				title TEXT NOT NULL,
+				content TEXT NOT NULL,
+				tags TEXT NOT NULL DEFAULT '',
+				author_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		Version:     36,
+		Description: "Add timezone and locale preferences to users",
+		SQL: `
+			-- Empty means the site default
+			ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT '';
+			ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     37,
+		Description: "Add per-user recently viewed pages",
+		SQL: `
+			-- Each signed-in user's latest page views, trimmed to the newest
+			-- few on every write
+			CREATE TABLE IF NOT EXISTS user_page_views (
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				viewed_at DATETIME NOT NULL,
+				PRIMARY KEY (user_id, page_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_page_views_recent ON user_page_views(user_id, viewed_at DESC);
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS user_page_views (
+				user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				viewed_at TIMESTAMPTZ NOT NULL,
+				PRIMARY KEY (user_id, page_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_user_page_views_recent ON user_page_views(user_id, viewed_at DESC);
+		`,
+	},
+	{
+		Version:     38,
+		Description: "Add daily page view counters",
+		SQL: `
+			-- Views per page and UTC day, for the analytics report. The
+			-- all-time totals stay in page_views.
+			CREATE TABLE IF NOT EXISTS page_view_days (
+				page_id INTEGER NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				day DATETIME NOT NULL,
+				views INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY (page_id, day)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_page_view_days_day ON page_view_days(day);
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS page_view_days (
+				page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+				day TIMESTAMPTZ NOT NULL,
+				views BIGINT NOT NULL DEFAULT 0,
+				PRIMARY KEY (page_id, day)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_page_view_days_day ON page_view_days(day);
+		`,
+	},
+	{
+		Version:        39,
+		Description:    "Let share links target a tag or a search",
+		RebuildsTables: true,
+		SQL: `
+			-- A link shares a page (and maybe its subpages), every page
+			-- with the tag in target_ref, or the results of the search in
+			-- target_ref. Only page links have a page_id.
+			CREATE TABLE share_links_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				token_hash TEXT NOT NULL UNIQUE,
+				target_type TEXT NOT NULL DEFAULT 'page',
+				target_ref TEXT NOT NULL DEFAULT '',
+				page_id INTEGER REFERENCES pages(id) ON DELETE CASCADE,
+				created_by INTEGER NOT NULL REFERENCES users(id),
+				include_children INTEGER NOT NULL DEFAULT 0,
+				max_views INTEGER,
+				max_ips INTEGER,
+				expires_at DATETIME,
+				is_revoked INTEGER NOT NULL DEFAULT 0,
+				view_count INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				group_id INTEGER REFERENCES groups(id) ON DELETE CASCADE
+			);
+			INSERT INTO share_links_new (id, token_hash, page_id, created_by, include_children, max_views, max_ips, expires_at, is_revoked, view_count, created_at, group_id)
+				SELECT id, token_hash, page_id, created_by, include_children, max_views, max_ips, expires_at, is_revoked, view_count, created_at, group_id FROM share_links;
+			DROP TABLE share_links;
+			ALTER TABLE share_links_new RENAME TO share_links;
+
+			CREATE INDEX IF NOT EXISTS idx_share_links_token ON share_links(token_hash);
+			CREATE INDEX IF NOT EXISTS idx_share_links_page ON share_links(page_id);
+			CREATE INDEX IF NOT EXISTS idx_share_links_creator ON share_links(created_by);
+		`,
+		Postgres: `
+			ALTER TABLE share_links ADD COLUMN target_type TEXT NOT NULL DEFAULT 'page';
+			ALTER TABLE share_links ADD COLUMN target_ref TEXT NOT NULL DEFAULT '';
+			ALTER TABLE share_links ALTER COLUMN page_id DROP NOT NULL;
+		`,
+	},
+	{
+		Version:     40,
+		Description: "Add public pages",
+		SQL: `
+			-- Pages anonymous visitors may read, with their subpages, when
+			-- the wiki requires sign-in
+			CREATE TABLE IF NOT EXISTS public_pages (
+				page_id INTEGER PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS public_pages (
+				page_id BIGINT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+				created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+		`,
+	},
+	{
+		Version:     41,
+		Description: "Add parent tags",
+		SQL: `
+			-- A tag can fall under a broader one, e.g. "postgres" under
+			-- "databases", for browsing. Pages keep only the tags they name.
+			ALTER TABLE tags ADD COLUMN parent_id INTEGER REFERENCES tags(id) ON DELETE SET NULL;
+		`,
+		Postgres: `
+			ALTER TABLE tags ADD COLUMN parent_id BIGINT REFERENCES tags(id) ON DELETE SET NULL;
+		`,
+	},
+	{
+		Version:     42,
+		Description: "Add page descriptions and social images",
+		SQL: `
+			-- Shown to search engines and link previews in place of the
+			-- excerpt and the site's default card
+			ALTER TABLE page_properties ADD COLUMN description TEXT NOT NULL DEFAULT '';
+			ALTER TABLE page_properties ADD COLUMN social_image TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     43,
+		Description: "Add a theme preference to users",
+		SQL: `
+			-- light or dark; empty follows the browser
+			ALTER TABLE users ADD COLUMN theme TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     44,
+		Description: "Add a language preference to users",
+		SQL: `
+			-- Catalog tag such as de; empty follows the browser
+			ALTER TABLE users ADD COLUMN language TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     45,
+		Description: "Add rate limit counters",
+		SQL: `
+			-- Request and sign-in failure counts shared by every replica
+			-- when WIKI_RATE_LIMIT_STORE=database, and kept across restarts
+			CREATE TABLE IF NOT EXISTS rate_limits (
+				key TEXT PRIMARY KEY,
+				count INTEGER NOT NULL,
+				resets_at DATETIME NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_rate_limits_resets ON rate_limits(resets_at);
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS rate_limits (
+				key TEXT PRIMARY KEY,
+				count INTEGER NOT NULL,
+				resets_at TIMESTAMPTZ NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_rate_limits_resets ON rate_limits(resets_at);
+		`,
+	},
+	{
+		Version:     46,
+		Description: "Add scheduled publishing to pages",
+		SQL: `
+			-- When an unpublished page goes live; cleared once it has
+			ALTER TABLE pages ADD COLUMN publish_at DATETIME;
+			CREATE INDEX IF NOT EXISTS idx_pages_publish_at ON pages(publish_at);
+		`,
+		Postgres: `
+			ALTER TABLE pages ADD COLUMN publish_at TIMESTAMPTZ;
+			CREATE INDEX IF NOT EXISTS idx_pages_publish_at ON pages(publish_at);
+		`,
+	},
+	{
+		Version:     47,
+		Description: "Store page word counts",
+		SQL: `
+			-- Counted from the markdown when a page is saved, for reading
+			-- time estimates; existing pages are filled in by the next
+			-- re-render.
+			ALTER TABLE pages ADD COLUMN word_count INTEGER NOT NULL DEFAULT 0;
+		`,
+	},
+	{
+		Version:     48,
+		Description: "Store attachment content hashes",
+		SQL: `
+			-- SHA-256 of the file, so identical uploads share one stored
+			-- file; empty for attachments saved before hashing.
+			ALTER TABLE attachments ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+			CREATE INDEX IF NOT EXISTS idx_attachments_content_hash ON attachments(content_hash);
+		`,
+	},
+	{
+		Version:     49,
+		Description: "Mark directory accounts",
+		SQL: `
+			-- DN of the LDAP entry the account was provisioned for; empty
+			-- for local accounts, which directory sign-in never takes over
+			ALTER TABLE users ADD COLUMN directory_dn TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     50,
+		Description: "Record applied promotion bundles",
+		SQL: `
+			-- A bundle is applied once; replaying it later would undo
+			-- the edits made since
+			CREATE TABLE IF NOT EXISTS promotion_bundles (
+				id TEXT PRIMARY KEY,
+				label TEXT NOT NULL,
+				source TEXT NOT NULL,
+				applied_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+				applied_at DATETIME NOT NULL
+			);
+		`,
+		Postgres: `
+			CREATE TABLE IF NOT EXISTS promotion_bundles (
+				id TEXT PRIMARY KEY,
+				label TEXT NOT NULL,
+				source TEXT NOT NULL,
+				applied_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+				applied_at TIMESTAMPTZ NOT NULL
+			);
+		`,
+	},
 }
 
 // Migrate runs all pending migrations.
 func (db *DB) Migrate(ctx context.Context) error {
+	pending := migrations
+	appliedAt := "DATETIME"
+	if db.driver == DriverPostgres {
+		pending = postgresMigrations()
+		appliedAt = "TIMESTAMPTZ"
+	}
+
 	// Create migrations tracking table
 	_, err := db.ExecContext(ctx, `
 		CREATE TABLE IF NOT EXISTS schema_migrations (
 			version INTEGER PRIMARY KEY,
 			description TEXT NOT NULL,
-			applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			applied_at `+appliedAt+` NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
 	`)
 	if err != nil {
@@ -282,31 +981,14 @@ func (db *DB) Migrate(ctx context.Context) error {
 	}
 
 	// Apply pending migrations
-	for _, m := range migrations {
+	for _, m := range pending {
 		if m.Version <= currentVersion {
 			continue
 		}
 
 		fmt.Printf("Applying migration %d: %s\n", m.Version, m.Description)
 
-		err := db.Transaction(ctx, func(tx *sql.Tx) error {
-			// Execute migration SQL
-			if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
-				return fmt.Errorf("failed to execute migration SQL: %w", err)
-			}
-
-			// Record migration
-			_, err := tx.ExecContext(ctx,
-				"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
-				m.Version, m.Description, time.Now().UTC())
-			if err != nil {
-				return fmt.Errorf("failed to record migration: %w", err)
-			}
-
-			return nil
-		})
-
-		if err != nil {
+		if err := db.applyMigration(ctx, m); err != nil {
 			return fmt.Errorf("migration %d failed: %w", m.Version, err)
 		}
 
@@ -316,6 +998,82 @@ func (db *DB) Migrate(ctx context.Context) error {
 	return nil
 }
 
+// applyMigration runs a migration and records it in one transaction.
+func (db *DB) applyMigration(ctx context.Context, m Migration) error {
+	if !m.RebuildsTables || db.driver != DriverSQLite {
+		return db.Transaction(ctx, func(tx *Tx) error {
+			return runMigration(ctx, tx, m)
+		})
+	}
+
+	// foreign_keys is a no-op inside a transaction and applies per
+	// connection, so pin one connection for the whole migration.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return fmt.Errorf("failed to disable foreign keys: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+
+	sqlTx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	tx := &Tx{Tx: sqlTx, db: db}
+
+	// Only violations the migration introduces fail it; older databases
+	// may already have dangling rows from before foreign keys were enforced.
+	before, err := countForeignKeyViolations(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := runMigration(ctx, tx, m); err != nil {
+		tx.Rollback()
+		return err
+	}
+	after, err := countForeignKeyViolations(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if after > before {
+		tx.Rollback()
+		return fmt.Errorf("migration left %d new foreign key violations", after-before)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func countForeignKeyViolations(ctx context.Context, tx *Tx) (int, error) {
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_foreign_key_check").Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	return n, nil
+}
+
+func runMigration(ctx context.Context, tx *Tx, m Migration) error {
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("failed to execute migration SQL: %w", err)
+	}
+
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)",
+		m.Version, m.Description, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record migration: %w", err)
+	}
+	return nil
+}
+
 // CurrentVersion returns the current schema version.
 func (db *DB) CurrentVersion(ctx context.Context) (int, error) {
 	var version int
@@ -325,3 +1083,8 @@ func (db *DB) CurrentVersion(ctx context.Context) (int, error) {
 	}
 	return version, nil
 }
+
+// LatestVersion returns the schema version this binary migrates to.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
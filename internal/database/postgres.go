@@ -0,0 +1,502 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// postgresBaseline is the schema version postgresSchema creates. New
+// PostgreSQL databases start there rather than replaying the SQLite
+// migrations before it; later migrations run on both.
+const postgresBaseline = 36
+
+// postgresMigrations returns the migrations for PostgreSQL: the baseline
+// schema, then every later migration in its PostgreSQL form.
+func postgresMigrations() []Migration {
+	pending := []Migration{{
+		Version:     postgresBaseline,
+		Description: "Create PostgreSQL schema",
+		SQL:         postgresSchema + postgresFTSTriggers,
+	}}
+	for _, m := range migrations {
+		if m.Version <= postgresBaseline {
+			continue
+		}
+		if m.Postgres != "" {
+			m.SQL = m.Postgres
+		}
+		pending = append(pending, m)
+	}
+	return pending
+}
+
+// postgresSchema is the SQLite schema at postgresBaseline, translated.
+// Columns SQLite compares with COLLATE NOCASE are citext, and booleans
+// stay integers so queries are the same on both databases. The search
+// index is a tsvector per page instead of an FTS5 table.
+const postgresSchema = `
+	CREATE EXTENSION IF NOT EXISTS citext;
+
+	CREATE TABLE roles (
+		name CITEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		permissions TEXT NOT NULL DEFAULT '',
+		built_in INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE users (
+		id BIGSERIAL PRIMARY KEY,
+		username CITEXT UNIQUE NOT NULL,
+		email CITEXT UNIQUE NOT NULL,
+		password_hash TEXT NOT NULL,
+		role CITEXT NOT NULL DEFAULT 'viewer' REFERENCES roles(name) ON UPDATE CASCADE,
+		is_active INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_login_at TIMESTAMPTZ,
+		email_verified_at TIMESTAMPTZ,
+		must_change_password INTEGER NOT NULL DEFAULT 0,
+		locked_at TIMESTAMPTZ,
+		lock_reason TEXT NOT NULL DEFAULT '',
+		timezone TEXT NOT NULL DEFAULT '',
+		locale TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE pages (
+		id BIGSERIAL PRIMARY KEY,
+		slug CITEXT UNIQUE NOT NULL,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		content_html TEXT NOT NULL DEFAULT '',
+		excerpt TEXT NOT NULL DEFAULT '',
+		author_id BIGINT NOT NULL REFERENCES users(id),
+		parent_id BIGINT REFERENCES pages(id) ON DELETE SET NULL,
+		is_published INTEGER NOT NULL DEFAULT 1,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		published_at TIMESTAMPTZ,
+		archived_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE revisions (
+		id BIGSERIAL PRIMARY KEY,
+		page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		author_id BIGINT NOT NULL REFERENCES users(id),
+		comment TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE tags (
+		id BIGSERIAL PRIMARY KEY,
+		name CITEXT UNIQUE NOT NULL
+	);
+
+	CREATE TABLE page_tags (
+		page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (page_id, tag_id)
+	);
+
+	CREATE TABLE groups (
+		id BIGSERIAL PRIMARY KEY,
+		name CITEXT UNIQUE NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE group_members (
+		group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		added_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (group_id, user_id)
+	);
+
+	CREATE TABLE page_groups (
+		page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+		PRIMARY KEY (page_id, group_id)
+	);
+
+	CREATE TABLE page_links (
+		source_page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		target_slug CITEXT NOT NULL,
+		kind TEXT NOT NULL DEFAULT 'wiki',
+		PRIMARY KEY (source_page_id, target_slug)
+	);
+
+	CREATE TABLE page_drafts (
+		page_id BIGINT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		content TEXT NOT NULL,
+		tags TEXT NOT NULL DEFAULT '',
+		author_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE page_properties (
+		page_id BIGINT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+		owner_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		review_at DATE,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE page_views (
+		page_id BIGINT PRIMARY KEY REFERENCES pages(id) ON DELETE CASCADE,
+		views BIGINT NOT NULL DEFAULT 0,
+		last_viewed_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE attachments (
+		id BIGSERIAL PRIMARY KEY,
+		page_id BIGINT REFERENCES pages(id) ON DELETE SET NULL,
+		filename TEXT NOT NULL,
+		filepath TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size_bytes BIGINT NOT NULL,
+		uploader_id BIGINT NOT NULL REFERENCES users(id),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE sessions (
+		id TEXT PRIMARY KEY,
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		data TEXT NOT NULL DEFAULT '{}',
+		ip_address TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at TIMESTAMPTZ,
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id BIGINT,
+		details TEXT,
+		ip_address TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE api_tokens (
+		id BIGSERIAL PRIMARY KEY,
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		token_hash TEXT UNIQUE NOT NULL,
+		name TEXT NOT NULL DEFAULT '',
+		scopes TEXT NOT NULL DEFAULT 'read',
+		last_used_at TIMESTAMPTZ,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE api_usage (
+		token_id BIGINT NOT NULL REFERENCES api_tokens(id) ON DELETE CASCADE,
+		hour TIMESTAMPTZ NOT NULL,
+		requests BIGINT NOT NULL DEFAULT 0,
+		errors BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (token_id, hour)
+	);
+
+	CREATE TABLE api_calls (
+		id BIGSERIAL PRIMARY KEY,
+		token_id BIGINT NOT NULL REFERENCES api_tokens(id) ON DELETE CASCADE,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		ip_address TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE share_links (
+		id BIGSERIAL PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		created_by BIGINT NOT NULL REFERENCES users(id),
+		group_id BIGINT REFERENCES groups(id) ON DELETE CASCADE,
+		include_children INTEGER NOT NULL DEFAULT 0,
+		max_views INTEGER,
+		max_ips INTEGER,
+		expires_at TIMESTAMPTZ,
+		is_revoked INTEGER NOT NULL DEFAULT 0,
+		view_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE share_link_access (
+		id BIGSERIAL PRIMARY KEY,
+		share_link_id BIGINT NOT NULL REFERENCES share_links(id) ON DELETE CASCADE,
+		ip_address TEXT NOT NULL,
+		user_agent TEXT,
+		accessed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE notifications (
+		id BIGSERIAL PRIMARY KEY,
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		actor_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		page_id BIGINT REFERENCES pages(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		message TEXT NOT NULL DEFAULT '',
+		is_read INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE review_requests (
+		id BIGSERIAL PRIMARY KEY,
+		page_id BIGINT NOT NULL REFERENCES pages(id) ON DELETE CASCADE,
+		requester_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		reviewer_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		comment TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved')),
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE ip_rules (
+		id BIGSERIAL PRIMARY KEY,
+		cidr TEXT NOT NULL,
+		action TEXT NOT NULL CHECK (action IN ('ban', 'exempt')),
+		reason TEXT NOT NULL DEFAULT '',
+		created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE webhooks (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		is_active INTEGER NOT NULL DEFAULT 1,
+		created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER,
+		last_error TEXT NOT NULL DEFAULT '',
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		delivered_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE csp_reports (
+		id BIGSERIAL PRIMARY KEY,
+		document_uri TEXT NOT NULL DEFAULT '',
+		directive TEXT NOT NULL DEFAULT '',
+		blocked_uri TEXT NOT NULL DEFAULT '',
+		source_file TEXT NOT NULL DEFAULT '',
+		line_number INTEGER NOT NULL DEFAULT 0,
+		disposition TEXT NOT NULL DEFAULT 'enforce',
+		user_agent TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE email_templates (
+		key TEXT NOT NULL,
+		locale TEXT NOT NULL DEFAULT '',
+		subject TEXT NOT NULL,
+		body_text TEXT NOT NULL,
+		body_html TEXT NOT NULL DEFAULT '',
+		updated_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		updated_at TIMESTAMPTZ NOT NULL,
+		PRIMARY KEY (key, locale)
+	);
+
+	CREATE TABLE user_invites (
+		token_hash TEXT PRIMARY KEY,
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		invited_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE announcement_dismissals (
+		user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		announcement_id TEXT NOT NULL,
+		dismissed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, announcement_id)
+	);
+
+	CREATE TABLE cluster_events (
+		id BIGSERIAL PRIMARY KEY,
+		topic TEXT NOT NULL,
+		payload TEXT NOT NULL DEFAULT '',
+		origin TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE cluster_locks (
+		name TEXT PRIMARY KEY,
+		owner TEXT NOT NULL,
+		acquired_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	);
+
+	CREATE TABLE pages_fts (
+		page_id BIGINT PRIMARY KEY,
+		document TSVECTOR NOT NULL
+	);
+
+	CREATE INDEX idx_api_calls_token ON api_calls(token_id, id);
+	CREATE INDEX idx_api_tokens_expires ON api_tokens(expires_at);
+	CREATE INDEX idx_api_tokens_user ON api_tokens(user_id);
+	CREATE INDEX idx_attachments_page ON attachments(page_id);
+	CREATE INDEX idx_audit_created ON audit_log(created_at DESC);
+	CREATE INDEX idx_audit_entity ON audit_log(entity_type, entity_id);
+	CREATE INDEX idx_audit_user ON audit_log(user_id);
+	CREATE INDEX idx_cluster_events_created ON cluster_events(created_at);
+	CREATE INDEX idx_csp_reports_created ON csp_reports(created_at);
+	CREATE INDEX idx_group_members_user ON group_members(user_id);
+	CREATE INDEX idx_ip_rules_expires ON ip_rules(expires_at);
+	CREATE INDEX idx_notifications_user ON notifications(user_id, is_read, created_at);
+	CREATE INDEX idx_page_groups_group ON page_groups(group_id);
+	CREATE INDEX idx_page_links_target ON page_links(target_slug);
+	CREATE INDEX idx_page_properties_review ON page_properties(review_at);
+	CREATE INDEX idx_page_tags_tag ON page_tags(tag_id);
+	CREATE INDEX idx_pages_archived ON pages(archived_at);
+	CREATE INDEX idx_pages_author ON pages(author_id);
+	CREATE INDEX idx_pages_parent ON pages(parent_id);
+	CREATE INDEX idx_pages_published ON pages(is_published, published_at DESC);
+	CREATE INDEX idx_pages_updated ON pages(updated_at DESC);
+	CREATE INDEX idx_pages_fts_document ON pages_fts USING GIN (document);
+	CREATE INDEX idx_review_requests_page ON review_requests(page_id);
+	CREATE INDEX idx_review_requests_reviewer ON review_requests(reviewer_id, status);
+	CREATE INDEX idx_revisions_created ON revisions(created_at DESC);
+	CREATE INDEX idx_revisions_page ON revisions(page_id, created_at DESC);
+	CREATE INDEX idx_sessions_expires ON sessions(expires_at);
+	CREATE INDEX idx_sessions_user ON sessions(user_id);
+	CREATE INDEX idx_share_access_link ON share_link_access(share_link_id, ip_address);
+	CREATE INDEX idx_share_links_creator ON share_links(created_by);
+	CREATE INDEX idx_share_links_page ON share_links(page_id);
+	CREATE INDEX idx_user_invites_user ON user_invites(user_id);
+	CREATE INDEX idx_users_role ON users(role);
+	CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+`
+
+// postgresFTSDocument weights a page's search document like the SQLite
+// search ranks its columns: title, then slug, then tags, then content.
+// Slugs are split into their segments.
+const postgresFTSDocument = `
+	CREATE OR REPLACE FUNCTION pages_fts_document(p_id BIGINT) RETURNS TSVECTOR AS $$
+		SELECT setweight(to_tsvector('english', p.title), 'A') ||
+			setweight(to_tsvector('simple', replace(p.slug::text, '/', ' ')), 'B') ||
+			setweight(to_tsvector('simple', COALESCE((
+				SELECT string_agg(t.name::text, ' ') FROM page_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.page_id = p.id
+			), '')), 'C') ||
+			setweight(to_tsvector('english', p.content), 'D')
+		FROM pages p WHERE p.id = p_id
+	$$ LANGUAGE sql STABLE;
+
+	CREATE OR REPLACE FUNCTION pages_fts_refresh(p_id BIGINT) RETURNS void AS $$
+	BEGIN
+		DELETE FROM pages_fts WHERE page_id = p_id;
+		INSERT INTO pages_fts (page_id, document)
+		SELECT p.id, pages_fts_document(p.id) FROM pages p WHERE p.id = p_id;
+	END
+	$$ LANGUAGE plpgsql;
+`
+
+// postgresFTSTriggers keep pages_fts in sync with pages and their tags,
+// like pagesFTSTriggers and under the same names. They replace any
+// existing triggers, so they also repair them.
+const postgresFTSTriggers = postgresFTSDocument + `
+	CREATE OR REPLACE FUNCTION pages_fts_sync() RETURNS trigger AS $$
+	BEGIN
+		IF TG_TABLE_NAME = 'pages' THEN
+			IF TG_OP = 'DELETE' THEN
+				DELETE FROM pages_fts WHERE page_id = OLD.id;
+			ELSE
+				PERFORM pages_fts_refresh(NEW.id);
+			END IF;
+		ELSIF TG_TABLE_NAME = 'page_tags' THEN
+			IF TG_OP = 'DELETE' THEN
+				PERFORM pages_fts_refresh(OLD.page_id);
+			ELSE
+				PERFORM pages_fts_refresh(NEW.page_id);
+			END IF;
+		ELSE
+			PERFORM pages_fts_refresh(pt.page_id) FROM page_tags pt WHERE pt.tag_id = NEW.id;
+		END IF;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS pages_fts_insert ON pages;
+	CREATE TRIGGER pages_fts_insert AFTER INSERT ON pages
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+
+	DROP TRIGGER IF EXISTS pages_fts_delete ON pages;
+	CREATE TRIGGER pages_fts_delete AFTER DELETE ON pages
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+
+	DROP TRIGGER IF EXISTS pages_fts_update ON pages;
+	CREATE TRIGGER pages_fts_update AFTER UPDATE OF title, slug, content ON pages
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+
+	DROP TRIGGER IF EXISTS page_tags_fts_insert ON page_tags;
+	CREATE TRIGGER page_tags_fts_insert AFTER INSERT ON page_tags
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+
+	DROP TRIGGER IF EXISTS page_tags_fts_delete ON page_tags;
+	CREATE TRIGGER page_tags_fts_delete AFTER DELETE ON page_tags
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+
+	DROP TRIGGER IF EXISTS tags_fts_update ON tags;
+	CREATE TRIGGER tags_fts_update AFTER UPDATE OF name ON tags
+		FOR EACH ROW EXECUTE FUNCTION pages_fts_sync();
+`
+
+// postgresFTSRows rebuilds the whole search index.
+const postgresFTSRows = `INSERT INTO pages_fts (page_id, document) SELECT id, pages_fts_document(id) FROM pages`
+
+// postgresSearchFrom joins the search index to visible pages matching the
+// query, like searchFTSWhere. ts_rank takes weights for D, C, B and A, at
+// most 1, so they are relative to the title's.
+var postgresSearchFrom = `
+		FROM pages_fts f
+		JOIN pages p ON p.id = f.page_id
+		CROSS JOIN to_tsquery('english', ?) q
+		WHERE f.document @@ q
+		AND p.is_published = 1
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		AND (? = 1 OR p.archived_at IS NULL)`
+
+// postgresSearchQuery selects search results like SearchPages' SQLite
+// query, with snippets marked the same way.
+var postgresSearchQuery = fmt.Sprintf(`
+		SELECT p.id, p.slug, p.title,
+			   ts_headline('english', p.content, q, 'StartSel=' || chr(1) || ', StopSel=' || chr(2) || ', MaxWords=24, MinWords=12'),
+			   ts_rank('{%g, %g, %g, 1}', f.document, q) AS rank, p.updated_at, p.archived_at IS NOT NULL`+postgresSearchFrom+`
+		ORDER BY rank DESC, p.updated_at DESC
+		LIMIT ? OFFSET ?`,
+	searchContentWeight/searchTitleWeight, searchTagsWeight/searchTitleWeight, searchSlugWeight/searchTitleWeight)
+
+// postgresTSQuery converts a user search query to a tsquery matching any
+// of its words by prefix, or "" when it has no words.
+func postgresTSQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " | ")
+}
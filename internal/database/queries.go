@@ -3,72 +3,29 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"regexp"
+	"html"
 	"strings"
 	"time"
 
-	"gowiki/internal/models"
-)
+	"github.com/mattn/go-sqlite3"
 
-var (
-	mdHeaderRegex = regexp.MustCompile(`^#{1,6}\s+`)
-	mdLinkRegex   = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`)
-	mdBoldRegex   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
-	mdItalicRegex = regexp.MustCompile(`\*([^*]+)\*`)
-	mdCodeRegex   = regexp.MustCompile("`[^`]+`")
+	"gowiki/internal/models"
 )
 
-// cleanExcerpt removes markdown formatting and cleans up an excerpt.
-func cleanExcerpt(raw string) string {
-	// Split into lines
-	lines := strings.Split(raw, "\n")
-	var cleanLines []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		// Skip empty lines and headers
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		// Remove markdown formatting
-		line = mdLinkRegex.ReplaceAllString(line, "$1")
-		line = mdBoldRegex.ReplaceAllString(line, "$1")
-		line = mdItalicRegex.ReplaceAllString(line, "$1")
-		line = mdCodeRegex.ReplaceAllString(line, "")
-
-		cleanLines = append(cleanLines, line)
-	}
-
-	excerpt := strings.Join(cleanLines, " ")
-	// Truncate to ~150 chars at word boundary
-	if len(excerpt) > 150 {
-		excerpt = excerpt[:150]
-		if idx := strings.LastIndex(excerpt, " "); idx > 100 {
-			excerpt = excerpt[:idx]
-		}
-		excerpt += "..."
-	}
-	return excerpt
-}
-
 // User queries
 
 // CreateUser inserts a new user into the database.
 func (db *DB) CreateUser(ctx context.Context, user *models.User) error {
-	result, err := db.ExecContext(ctx, `
-		INSERT INTO users (username, email, password_hash, role, is_active, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, user.Username, user.Email, user.PasswordHash, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt)
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO users (username, email, password_hash, role, is_active, created_at, updated_at, email_verified_at, directory_dn)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, user.Username, user.Email, user.PasswordHash, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt, user.EmailVerifiedAt, user.DirectoryDN)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get user ID: %w", err)
-	}
-
 	user.ID = id
 	return nil
 }
@@ -77,11 +34,13 @@ func (db *DB) CreateUser(ctx context.Context, user *models.User) error {
 func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	user := &models.User{}
 	err := db.QueryRowContext(ctx, `
-		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at, email_verified_at,
+		       must_change_password, locked_at, lock_reason, timezone, locale, theme, language, directory_dn
 		FROM users WHERE id = ?
 	`, id).Scan(
 		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.EmailVerifiedAt,
+		&user.MustChangePassword, &user.LockedAt, &user.LockReason, &user.Timezone, &user.Locale, &user.Theme, &user.Language, &user.DirectoryDN,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -89,6 +48,13 @@ func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+
+	// Load groups for page access checks
+	user.GroupIDs, err = db.getUserGroupIDs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
@@ -96,11 +62,13 @@ func (db *DB) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 func (db *DB) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	user := &models.User{}
 	err := db.QueryRowContext(ctx, `
-		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at, email_verified_at,
+		       must_change_password, locked_at, lock_reason, timezone, locale, theme, language, directory_dn
 		FROM users WHERE username = ? COLLATE NOCASE
 	`, username).Scan(
 		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.EmailVerifiedAt,
+		&user.MustChangePassword, &user.LockedAt, &user.LockReason, &user.Timezone, &user.Locale, &user.Theme, &user.Language, &user.DirectoryDN,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -115,11 +83,13 @@ func (db *DB) GetUserByUsername(ctx context.Context, username string) (*models.U
 func (db *DB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	user := &models.User{}
 	err := db.QueryRowContext(ctx, `
-		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at, email_verified_at,
+		       must_change_password, locked_at, lock_reason, timezone, locale, theme, language, directory_dn
 		FROM users WHERE email = ? COLLATE NOCASE
 	`, email).Scan(
 		&user.ID, &user.Username, &user.Email, &user.PasswordHash,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt,
+		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.EmailVerifiedAt,
+		&user.MustChangePassword, &user.LockedAt, &user.LockReason, &user.Timezone, &user.Locale, &user.Theme, &user.Language, &user.DirectoryDN,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -141,7 +111,8 @@ func (db *DB) UpdateUserLastLogin(ctx context.Context, userID int64) error {
 // ListUsers retrieves all users.
 func (db *DB) ListUsers(ctx context.Context, limit, offset int) ([]models.User, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at, email_verified_at,
+		       must_change_password, locked_at, lock_reason, timezone, locale, theme, language, directory_dn
 		FROM users
 		ORDER BY username ASC
 		LIMIT ? OFFSET ?
@@ -156,7 +127,8 @@ func (db *DB) ListUsers(ctx context.Context, limit, offset int) ([]models.User,
 		var u models.User
 		if err := rows.Scan(
 			&u.ID, &u.Username, &u.Email, &u.PasswordHash,
-			&u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt,
+			&u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.EmailVerifiedAt,
+			&u.MustChangePassword, &u.LockedAt, &u.LockReason, &u.Timezone, &u.Locale, &u.Theme, &u.Language, &u.DirectoryDN,
 		); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
@@ -166,6 +138,26 @@ func (db *DB) ListUsers(ctx context.Context, limit, offset int) ([]models.User,
 	return users, rows.Err()
 }
 
+// FirstAdministratorID returns the ID of the oldest active user whose role
+// grants the administer permission, or 0 if there is none.
+func (db *DB) FirstAdministratorID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, `
+		SELECT u.id FROM users u
+		JOIN roles r ON r.name = u.role
+		WHERE u.is_active = ? AND ',' || r.permissions || ',' LIKE ?
+		ORDER BY u.id ASC
+		LIMIT 1
+	`, true, "%,"+string(models.PermAdminister)+",%").Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find an administrator: %w", err)
+	}
+	return id, nil
+}
+
 // UpdateUser updates user fields.
 func (db *DB) UpdateUser(ctx context.Context, id int64, update *models.UserUpdate) error {
 	var setClauses []string
@@ -187,6 +179,43 @@ func (db *DB) UpdateUser(ctx context.Context, id int64, update *models.UserUpdat
 		setClauses = append(setClauses, "is_active = ?")
 		args = append(args, *update.IsActive)
 	}
+	if update.MustChangePassword != nil {
+		setClauses = append(setClauses, "must_change_password = ?")
+		args = append(args, *update.MustChangePassword)
+	}
+	if update.Locked != nil {
+		// Keep the original lock time when an already locked user is saved again
+		if *update.Locked {
+			setClauses = append(setClauses, "locked_at = COALESCE(locked_at, ?)")
+			args = append(args, time.Now().UTC())
+		} else {
+			setClauses = append(setClauses, "locked_at = NULL", "lock_reason = ''")
+		}
+	}
+	if update.LockReason != nil && (update.Locked == nil || *update.Locked) {
+		setClauses = append(setClauses, "lock_reason = ?")
+		args = append(args, *update.LockReason)
+	}
+	if update.Timezone != nil {
+		setClauses = append(setClauses, "timezone = ?")
+		args = append(args, *update.Timezone)
+	}
+	if update.Locale != nil {
+		setClauses = append(setClauses, "locale = ?")
+		args = append(args, *update.Locale)
+	}
+	if update.Theme != nil {
+		setClauses = append(setClauses, "theme = ?")
+		args = append(args, *update.Theme)
+	}
+	if update.Language != nil {
+		setClauses = append(setClauses, "language = ?")
+		args = append(args, *update.Language)
+	}
+	if update.DirectoryDN != nil {
+		setClauses = append(setClauses, "directory_dn = ?")
+		args = append(args, *update.DirectoryDN)
+	}
 
 	if len(setClauses) == 0 {
 		return nil
@@ -201,6 +230,18 @@ func (db *DB) UpdateUser(ctx context.Context, id int64, update *models.UserUpdat
 	return err
 }
 
+// SetEmailVerified marks a user's email address as verified, provided it
+// is still the address that was verified.
+func (db *DB) SetEmailVerified(ctx context.Context, userID int64, email string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = ? WHERE id = ? AND email = ? COLLATE NOCASE
+	`, time.Now().UTC(), userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	return nil
+}
+
 // DeleteUser removes a user by ID.
 func (db *DB) DeleteUser(ctx context.Context, id int64) error {
 	_, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
@@ -219,19 +260,31 @@ func (db *DB) CreatePage(ctx context.Context, page *models.Page) error {
 		page.PublishedAt = sql.NullTime{Time: now, Valid: true}
 	}
 
-	result, err := db.ExecContext(ctx, `
-		INSERT INTO pages (slug, title, content, content_html, author_id, parent_id, is_published, created_at, updated_at, published_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, page.Slug, page.Title, page.Content, page.ContentHTML, page.AuthorID, page.ParentID,
-		page.IsPublished, page.CreatedAt, page.UpdatedAt, page.PublishedAt)
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO pages (slug, title, content, content_html, excerpt, word_count, author_id, parent_id, is_published, created_at, updated_at, published_at, publish_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, page.Slug, page.Title, page.Content, page.ContentHTML, page.Excerpt, page.WordCount, page.AuthorID, page.ParentID,
+		page.IsPublished, page.CreatedAt, page.UpdatedAt, page.PublishedAt, page.PublishAt)
 	if err != nil {
 		return fmt.Errorf("failed to create page: %w", err)
 	}
+	db.pagesChanged()
 
-	id, err := result.LastInsertId()
+	page.ID = id
+	return nil
+}
+
+// RestorePage inserts a page keeping its original timestamps, for restoring from backups.
+func (db *DB) RestorePage(ctx context.Context, page *models.Page) error {
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO pages (slug, title, content, content_html, excerpt, word_count, author_id, parent_id, is_published, created_at, updated_at, published_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, page.Slug, page.Title, page.Content, page.ContentHTML, page.Excerpt, page.WordCount, page.AuthorID, page.ParentID,
+		page.IsPublished, page.CreatedAt, page.UpdatedAt, page.PublishedAt)
 	if err != nil {
-		return fmt.Errorf("failed to get page ID: %w", err)
+		return fmt.Errorf("failed to restore page: %w", err)
 	}
+	db.pagesChanged()
 
 	page.ID = id
 	return nil
@@ -240,17 +293,18 @@ func (db *DB) CreatePage(ctx context.Context, page *models.Page) error {
 // GetPageByID retrieves a page by ID.
 func (db *DB) GetPageByID(ctx context.Context, id int64) (*models.Page, error) {
 	page := &models.Page{}
+	var authorUsername string
 	err := db.QueryRowContext(ctx, `
-		SELECT p.id, p.slug, p.title, p.content, p.content_html, p.author_id, p.parent_id,
-			   p.is_published, p.created_at, p.updated_at, p.published_at,
+		SELECT p.id, p.slug, p.title, p.content, p.content_html, p.excerpt, p.word_count, p.author_id, p.parent_id,
+			   p.is_published, p.created_at, p.updated_at, p.published_at, p.publish_at, p.archived_at,
 			   u.username
 		FROM pages p
 		JOIN users u ON p.author_id = u.id
 		WHERE p.id = ?
 	`, id).Scan(
-		&page.ID, &page.Slug, &page.Title, &page.Content, &page.ContentHTML,
+		&page.ID, &page.Slug, &page.Title, &page.Content, &page.ContentHTML, &page.Excerpt, &page.WordCount,
 		&page.AuthorID, &page.ParentID, &page.IsPublished, &page.CreatedAt, &page.UpdatedAt,
-		&page.PublishedAt, new(string),
+		&page.PublishedAt, &page.PublishAt, &page.ArchivedAt, &authorUsername,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -259,6 +313,8 @@ func (db *DB) GetPageByID(ctx context.Context, id int64) (*models.Page, error) {
 		return nil, fmt.Errorf("failed to get page: %w", err)
 	}
 
+	page.Author = &models.User{ID: page.AuthorID, Username: authorUsername}
+
 	// Load tags
 	tags, err := db.GetPageTags(ctx, page.ID)
 	if err != nil {
@@ -266,6 +322,12 @@ func (db *DB) GetPageByID(ctx context.Context, id int64) (*models.Page, error) {
 	}
 	page.Tags = tags
 
+	// Load the groups the page is restricted to
+	page.Groups, err = db.GetPageGroups(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return page, nil
 }
 
@@ -275,16 +337,16 @@ func (db *DB) GetPageBySlug(ctx context.Context, slug string) (*models.Page, err
 	var authorUsername string
 
 	err := db.QueryRowContext(ctx, `
-		SELECT p.id, p.slug, p.title, p.content, p.content_html, p.author_id, p.parent_id,
-			   p.is_published, p.created_at, p.updated_at, p.published_at,
+		SELECT p.id, p.slug, p.title, p.content, p.content_html, p.excerpt, p.word_count, p.author_id, p.parent_id,
+			   p.is_published, p.created_at, p.updated_at, p.published_at, p.publish_at, p.archived_at,
 			   u.username
 		FROM pages p
 		JOIN users u ON p.author_id = u.id
 		WHERE p.slug = ? COLLATE NOCASE
 	`, slug).Scan(
-		&page.ID, &page.Slug, &page.Title, &page.Content, &page.ContentHTML,
+		&page.ID, &page.Slug, &page.Title, &page.Content, &page.ContentHTML, &page.Excerpt, &page.WordCount,
 		&page.AuthorID, &page.ParentID, &page.IsPublished, &page.CreatedAt, &page.UpdatedAt,
-		&page.PublishedAt, &authorUsername,
+		&page.PublishedAt, &page.PublishAt, &page.ArchivedAt, &authorUsername,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -302,25 +364,108 @@ func (db *DB) GetPageBySlug(ctx context.Context, slug string) (*models.Page, err
 	}
 	page.Tags = tags
 
+	// Load the groups the page is restricted to
+	page.Groups, err = db.GetPageGroups(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return page, nil
 }
 
+// SetPagesArchived archives or unarchives a page, and all pages under its
+// slug when withDescendants is set. It returns how many pages changed.
+func (db *DB) SetPagesArchived(ctx context.Context, pageID int64, archived, withDescendants bool) (int64, error) {
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now().UTC()
+	}
+
+	query := `UPDATE pages SET archived_at = ? WHERE id = ? AND (archived_at IS NULL) = (? = 1)`
+	args := []interface{}{archivedAt, pageID, archived}
+	if withDescendants {
+		query = `
+			UPDATE pages SET archived_at = ?
+			WHERE (id = ? OR slug LIKE (SELECT slug FROM pages WHERE id = ?) || '/%')
+			  AND (archived_at IS NULL) = (? = 1)
+		`
+		args = []interface{}{archivedAt, pageID, pageID, archived}
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to archive pages: %w", err)
+	}
+	db.pagesChanged()
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
 // UpdatePage updates a page.
 func (db *DB) UpdatePage(ctx context.Context, page *models.Page) error {
 	page.UpdatedAt = time.Now().UTC()
 
 	_, err := db.ExecContext(ctx, `
 		UPDATE pages
-		SET slug = ?, title = ?, content = ?, content_html = ?, parent_id = ?, is_published = ?, updated_at = ?, published_at = ?
+		SET slug = ?, title = ?, content = ?, content_html = ?, excerpt = ?, word_count = ?, parent_id = ?, is_published = ?, updated_at = ?, published_at = ?, publish_at = ?
 		WHERE id = ?
-	`, page.Slug, page.Title, page.Content, page.ContentHTML, page.ParentID, page.IsPublished, page.UpdatedAt, page.PublishedAt, page.ID)
+	`, page.Slug, page.Title, page.Content, page.ContentHTML, page.Excerpt, page.WordCount, page.ParentID, page.IsPublished, page.UpdatedAt, page.PublishedAt, page.PublishAt, page.ID)
+	if err == nil {
+		db.pagesChanged()
+	}
 
 	return err
 }
 
+// ListDuePages returns the IDs of unpublished pages scheduled to go live
+// at or before now.
+func (db *DB) ListDuePages(ctx context.Context, now time.Time) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM pages
+		WHERE is_published = 0 AND publish_at IS NOT NULL AND publish_at <= ?
+		ORDER BY publish_at
+	`, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled pages: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PublishScheduledPage publishes a page whose scheduled time has come,
+// dating its publication to that time. It reports false if the page was
+// published, unscheduled or rescheduled meanwhile.
+func (db *DB) PublishScheduledPage(ctx context.Context, id int64, now time.Time) (bool, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pages
+		SET is_published = 1, published_at = COALESCE(published_at, publish_at), publish_at = NULL, updated_at = ?
+		WHERE id = ? AND is_published = 0 AND publish_at IS NOT NULL AND publish_at <= ?
+	`, now.UTC(), id, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("failed to publish page: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if n > 0 {
+		db.pagesChanged()
+	}
+	return n > 0, nil
+}
+
 // DeletePage removes a page by ID.
 func (db *DB) DeletePage(ctx context.Context, id int64) error {
 	_, err := db.ExecContext(ctx, "DELETE FROM pages WHERE id = ?", id)
+	if err == nil {
+		db.pagesChanged()
+	}
 	return err
 }
 
@@ -331,7 +476,7 @@ func (db *DB) DeletePages(ctx context.Context, ids []int64) error {
 		return nil
 	}
 
-	return db.Transaction(ctx, func(tx *sql.Tx) error {
+	err := db.Transaction(ctx, func(tx *Tx) error {
 		for _, id := range ids {
 			if _, err := tx.ExecContext(ctx, "DELETE FROM pages WHERE id = ?", id); err != nil {
 				return fmt.Errorf("failed to delete page %d: %w", id, err)
@@ -339,10 +484,103 @@ func (db *DB) DeletePages(ctx context.Context, ids []int64) error {
 		}
 		return nil
 	})
+	if err == nil {
+		db.pagesChanged()
+	}
+	return err
+}
+
+// ApplyPageBatch creates, updates and deletes pages in one transaction, so
+// a batch is applied completely or not at all. Created pages are placed
+// under the page their slug names as parent when it exists, including one
+// created earlier in the batch. Updates record a revision of the content
+// they replace, with comment.
+func (db *DB) ApplyPageBatch(ctx context.Context, authorID int64, comment string, writes []*models.PageBatchWrite) error {
+	defer db.pagesChanged()
+	return db.Transaction(ctx, func(tx *Tx) error {
+		now := time.Now().UTC()
+		for _, w := range writes {
+			page := w.Page
+			switch w.Action {
+			case models.BatchCreate:
+				if i := strings.LastIndex(page.Slug, "/"); i > 0 {
+					var id int64
+					err := tx.QueryRowContext(ctx, "SELECT id FROM pages WHERE slug = ? COLLATE NOCASE", page.Slug[:i]).Scan(&id)
+					if err == nil {
+						page.ParentID = &id
+					} else if err != sql.ErrNoRows {
+						return fmt.Errorf("failed to find parent of %s: %w", page.Slug, err)
+					}
+				}
+				page.CreatedAt, page.UpdatedAt = now, now
+				if page.IsPublished {
+					page.PublishedAt = sql.NullTime{Time: now, Valid: true}
+				}
+				var err error
+				page.ID, err = insertReturningID(ctx, tx, `
+					INSERT INTO pages (slug, title, content, content_html, excerpt, word_count, author_id, parent_id, is_published, created_at, updated_at, published_at)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+				`, page.Slug, page.Title, page.Content, page.ContentHTML, page.Excerpt, page.WordCount, page.AuthorID, page.ParentID,
+					page.IsPublished, page.CreatedAt, page.UpdatedAt, page.PublishedAt)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %w", page.Slug, err)
+				}
+
+			case models.BatchUpdate:
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO revisions (page_id, content, author_id, comment, created_at)
+					VALUES (?, ?, ?, ?, ?)
+				`, page.ID, w.PreviousContent, authorID, comment, now); err != nil {
+					return fmt.Errorf("failed to create revision for %s: %w", page.Slug, err)
+				}
+				if page.IsPublished && !page.PublishedAt.Valid {
+					page.PublishedAt = sql.NullTime{Time: now, Valid: true}
+				}
+				page.UpdatedAt = now
+				if _, err := tx.ExecContext(ctx, `
+					UPDATE pages
+					SET title = ?, content = ?, content_html = ?, excerpt = ?, word_count = ?, is_published = ?, updated_at = ?, published_at = ?
+					WHERE id = ?
+				`, page.Title, page.Content, page.ContentHTML, page.Excerpt, page.WordCount, page.IsPublished, page.UpdatedAt, page.PublishedAt, page.ID); err != nil {
+					return fmt.Errorf("failed to update %s: %w", page.Slug, err)
+				}
+
+			case models.BatchDelete:
+				if _, err := tx.ExecContext(ctx, "DELETE FROM pages WHERE id = ?", page.ID); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", page.Slug, err)
+				}
+				continue
+
+			default:
+				return fmt.Errorf("unknown batch action %q", w.Action)
+			}
+
+			if w.Tags != nil {
+				if err := db.setPageTagsTx(ctx, tx, page.ID, w.Tags); err != nil {
+					return fmt.Errorf("failed to set tags for %s: %w", page.Slug, err)
+				}
+			}
+			if w.Links != nil {
+				if err := setPageLinksTx(ctx, tx, page.ID, w.Links); err != nil {
+					return fmt.Errorf("failed to index links for %s: %w", page.Slug, err)
+				}
+			}
+		}
+		return nil
+	})
 }
 
-// ListPages retrieves pages with optional filtering.
-func (db *DB) ListPages(ctx context.Context, filter models.PageFilter) ([]models.PageSummary, error) {
+// pageOrderColumns maps the sort keys a PageFilter accepts to SQL.
+var pageOrderColumns = map[string]string{
+	"updated_at": "p.updated_at",
+	"created_at": "p.created_at",
+	"title":      "p.title COLLATE NOCASE",
+	"author":     "u.username COLLATE NOCASE",
+}
+
+// pageFilterWhere builds the WHERE clause shared by ListPages and
+// CountFilteredPages. Queries must join users as u.
+func pageFilterWhere(filter models.PageFilter) (string, []interface{}) {
 	var whereClauses []string
 	var args []interface{}
 
@@ -356,6 +594,11 @@ func (db *DB) ListPages(ctx context.Context, filter models.PageFilter) ([]models
 		args = append(args, *filter.AuthorID)
 	}
 
+	if filter.Author != nil {
+		whereClauses = append(whereClauses, "u.username = ? COLLATE NOCASE")
+		args = append(args, *filter.Author)
+	}
+
 	if filter.Tag != nil {
 		whereClauses = append(whereClauses, `
 			EXISTS (
@@ -367,31 +610,48 @@ func (db *DB) ListPages(ctx context.Context, filter models.PageFilter) ([]models
 		args = append(args, *filter.Tag)
 	}
 
-	whereSQL := ""
-	if len(whereClauses) > 0 {
-		whereSQL = "WHERE " + strings.Join(whereClauses, " AND ")
+	switch {
+	case filter.ArchivedOnly:
+		whereClauses = append(whereClauses, "p.archived_at IS NOT NULL")
+	case !filter.IncludeArchived:
+		whereClauses = append(whereClauses, "p.archived_at IS NULL")
+	}
+
+	if filter.HideRestricted {
+		clause, groupArgs := pageGroupsWhere(filter.MemberOf)
+		whereClauses = append(whereClauses, clause)
+		args = append(args, groupArgs...)
+	}
+
+	if len(whereClauses) == 0 {
+		return "", args
 	}
+	return "WHERE " + strings.Join(whereClauses, " AND "), args
+}
+
+// ListPages retrieves pages with optional filtering.
+func (db *DB) ListPages(ctx context.Context, filter models.PageFilter) ([]models.PageSummary, error) {
+	whereSQL, args := pageFilterWhere(filter)
 
 	// Validate order by to prevent SQL injection
-	validOrderBy := map[string]bool{"updated_at": true, "created_at": true, "title": true}
-	orderBy := "updated_at"
-	if validOrderBy[filter.OrderBy] {
-		orderBy = filter.OrderBy
+	orderBy, ok := pageOrderColumns[filter.OrderBy]
+	if !ok {
+		orderBy = pageOrderColumns["updated_at"]
 	}
 
 	orderDir := "DESC"
-	if filter.OrderDir == "ASC" {
+	if strings.EqualFold(filter.OrderDir, "ASC") {
 		orderDir = "ASC"
 	}
 
 	query := fmt.Sprintf(`
-		SELECT p.id, p.slug, p.title, SUBSTR(p.content, 1, 200), p.parent_id, p.updated_at, u.username
+		SELECT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username, p.archived_at
 		FROM pages p
 		JOIN users u ON p.author_id = u.id
 		%s
-		ORDER BY p.%s %s
+		ORDER BY %s %s, p.id %s
 		LIMIT ? OFFSET ?
-	`, whereSQL, orderBy, orderDir)
+	`, whereSQL, orderBy, orderDir, orderDir)
 
 	args = append(args, filter.Limit, filter.Offset)
 
@@ -404,18 +664,58 @@ func (db *DB) ListPages(ctx context.Context, filter models.PageFilter) ([]models
 	var pages []models.PageSummary
 	for rows.Next() {
 		var p models.PageSummary
-		var rawExcerpt string
-		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &rawExcerpt, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author, &p.ArchivedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan page: %w", err)
 		}
-		// Clean up the excerpt - remove markdown headers and trim
-		p.Excerpt = cleanExcerpt(rawExcerpt)
 		pages = append(pages, p)
 	}
 
 	return pages, rows.Err()
 }
 
+// CountFilteredPages counts the pages ListPages would return without a
+// limit.
+func (db *DB) CountFilteredPages(ctx context.Context, filter models.PageFilter) (int, error) {
+	whereSQL, args := pageFilterWhere(filter)
+
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM pages p
+		JOIN users u ON p.author_id = u.id
+		`+whereSQL, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pages: %w", err)
+	}
+	return count, nil
+}
+
+// ListPageFiles lists every page matching filter with the size of its
+// content, ordered by slug. Limit, offset and order are ignored.
+func (db *DB) ListPageFiles(ctx context.Context, filter models.PageFilter) ([]models.PageFile, error) {
+	whereSQL, args := pageFilterWhere(filter)
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, OCTET_LENGTH(p.content), p.updated_at
+		FROM pages p
+		JOIN users u ON p.author_id = u.id
+		`+whereSQL+`
+		ORDER BY p.slug`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page files: %w", err)
+	}
+	defer rows.Close()
+
+	var files []models.PageFile
+	for rows.Next() {
+		var f models.PageFile
+		if err := rows.Scan(&f.ID, &f.Slug, &f.Size, &f.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page file: %w", err)
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
 // GetAllDescendants retrieves all descendant pages of a given page using recursive CTE.
 // Returns pages with their IDs and slugs for bulk updates.
 func (db *DB) GetAllDescendants(ctx context.Context, parentID int64) ([]struct {
@@ -457,23 +757,75 @@ func (db *DB) GetAllDescendants(ctx context.Context, parentID int64) ([]struct {
 	return result, rows.Err()
 }
 
+// ListPagesBeyondSlugLimits returns pages nested deeper than maxDepth
+// levels or with slugs longer than maxLength bytes. A zero limit is not
+// checked.
+func (db *DB) ListPagesBeyondSlugLimits(ctx context.Context, maxDepth, maxLength int) ([]models.PageSummary, error) {
+	if maxDepth <= 0 && maxLength <= 0 {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, p.parent_id, p.updated_at, COALESCE(u.username, '')
+		FROM pages p
+		LEFT JOIN users u ON u.id = p.author_id
+		WHERE (? > 0 AND LENGTH(p.slug) - LENGTH(REPLACE(p.slug, '/', '')) + 1 > ?)
+		   OR (? > 0 AND OCTET_LENGTH(p.slug) > ?)
+		ORDER BY p.slug`, maxDepth, maxDepth, maxLength, maxLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages beyond slug limits: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.PageSummary
+	for rows.Next() {
+		var p models.PageSummary
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, rows.Err()
+}
+
 // UpdatePageSlug updates just the slug of a page (used for cascade updates).
 func (db *DB) UpdatePageSlug(ctx context.Context, pageID int64, newSlug string) error {
 	_, err := db.ExecContext(ctx, `
 		UPDATE pages SET slug = ?, updated_at = ? WHERE id = ?
 	`, newSlug, time.Now().UTC(), pageID)
+	if err == nil {
+		db.pagesChanged()
+	}
 	return err
 }
 
-// GetPageChildren retrieves child pages of a given page.
-func (db *DB) GetPageChildren(ctx context.Context, parentID int64) ([]models.PageSummary, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT p.id, p.slug, p.title, SUBSTR(p.content, 1, 200), p.parent_id, p.updated_at, u.username
+// UpdatePageHTML replaces a page's rendered HTML, e.g. after a move changed
+// where its relative links point. The updated timestamp is left alone.
+func (db *DB) UpdatePageHTML(ctx context.Context, pageID int64, html string) error {
+	_, err := db.ExecContext(ctx, "UPDATE pages SET content_html = ? WHERE id = ?", html, pageID)
+	return err
+}
+
+// GetPageChildren retrieves child pages of a given page. With
+// hideRestricted, children restricted to groups are left out unless one of
+// their groups is in memberOf.
+func (db *DB) GetPageChildren(ctx context.Context, parentID int64, hideRestricted bool, memberOf []int64) ([]models.PageSummary, error) {
+	query := `
+		SELECT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username
 		FROM pages p
 		JOIN users u ON p.author_id = u.id
 		WHERE p.parent_id = ?
-		ORDER BY p.title ASC
-	`, parentID)
+	`
+	args := []interface{}{parentID}
+	if hideRestricted {
+		clause, groupArgs := pageGroupsWhere(memberOf)
+		query += " AND " + clause
+		args = append(args, groupArgs...)
+	}
+	query += " ORDER BY p.title ASC"
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get child pages: %w", err)
 	}
@@ -482,11 +834,9 @@ func (db *DB) GetPageChildren(ctx context.Context, parentID int64) ([]models.Pag
 	var pages []models.PageSummary
 	for rows.Next() {
 		var p models.PageSummary
-		var rawExcerpt string
-		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &rawExcerpt, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
 			return nil, fmt.Errorf("failed to scan page: %w", err)
 		}
-		p.Excerpt = cleanExcerpt(rawExcerpt)
 		pages = append(pages, p)
 	}
 
@@ -528,7 +878,7 @@ func (db *DB) GetPagePath(ctx context.Context, pageID int64) ([]models.PageSumma
 // GetRootPages retrieves pages without a parent.
 func (db *DB) GetRootPages(ctx context.Context) ([]models.PageSummary, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT p.id, p.slug, p.title, SUBSTR(p.content, 1, 200), p.parent_id, p.updated_at, u.username
+		SELECT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username
 		FROM pages p
 		JOIN users u ON p.author_id = u.id
 		WHERE p.parent_id IS NULL
@@ -542,11 +892,9 @@ func (db *DB) GetRootPages(ctx context.Context) ([]models.PageSummary, error) {
 	var pages []models.PageSummary
 	for rows.Next() {
 		var p models.PageSummary
-		var rawExcerpt string
-		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &rawExcerpt, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
 			return nil, fmt.Errorf("failed to scan page: %w", err)
 		}
-		p.Excerpt = cleanExcerpt(rawExcerpt)
 		pages = append(pages, p)
 	}
 
@@ -585,7 +933,7 @@ func (db *DB) GetAllPageSummaries(ctx context.Context) ([]models.PageSummary, er
 func (db *DB) CreateRevision(ctx context.Context, rev *models.Revision) error {
 	rev.CreatedAt = time.Now().UTC()
 
-	result, err := db.ExecContext(ctx, `
+	id, err := insertReturningID(ctx, db, `
 		INSERT INTO revisions (page_id, content, author_id, comment, created_at)
 		VALUES (?, ?, ?, ?, ?)
 	`, rev.PageID, rev.Content, rev.AuthorID, rev.Comment, rev.CreatedAt)
@@ -593,11 +941,6 @@ func (db *DB) CreateRevision(ctx context.Context, rev *models.Revision) error {
 		return fmt.Errorf("failed to create revision: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get revision ID: %w", err)
-	}
-
 	rev.ID = id
 	return nil
 }
@@ -625,16 +968,42 @@ func (db *DB) GetRevision(ctx context.Context, id int64) (*models.Revision, erro
 	return rev, nil
 }
 
-// ListRevisions retrieves revisions for a page.
-func (db *DB) ListRevisions(ctx context.Context, pageID int64, limit, offset int) ([]models.RevisionSummary, error) {
-	rows, err := db.QueryContext(ctx, `
-		SELECT r.id, u.username, r.comment, r.created_at
+// revisionWhere builds the WHERE clause selecting a page's revisions
+// matching filter.
+func revisionWhere(pageID int64, filter models.RevisionFilter) (string, []interface{}) {
+	clauses := []string{"r.page_id = ?"}
+	args := []interface{}{pageID}
+	if filter.Author != "" {
+		clauses = append(clauses, "u.username = ? COLLATE NOCASE")
+		args = append(args, filter.Author)
+	}
+	if filter.Since != nil {
+		clauses = append(clauses, "r.created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		clauses = append(clauses, "r.created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// ListRevisions retrieves a page's revisions matching filter, newest first.
+func (db *DB) ListRevisions(ctx context.Context, pageID int64, filter models.RevisionFilter) ([]models.RevisionSummary, error) {
+	where, args := revisionWhere(pageID, filter)
+	query := `
+		SELECT r.id, (SELECT COUNT(*) FROM revisions r2 WHERE r2.page_id = r.page_id AND r2.id <= r.id),
+			u.username, r.comment, r.created_at
 		FROM revisions r
 		JOIN users u ON r.author_id = u.id
-		WHERE r.page_id = ?
-		ORDER BY r.created_at DESC
-		LIMIT ? OFFSET ?
-	`, pageID, limit, offset)
+		WHERE ` + where + `
+		ORDER BY r.created_at DESC, r.id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list revisions: %w", err)
 	}
@@ -643,7 +1012,7 @@ func (db *DB) ListRevisions(ctx context.Context, pageID int64, limit, offset int
 	var revisions []models.RevisionSummary
 	for rows.Next() {
 		var r models.RevisionSummary
-		if err := rows.Scan(&r.ID, &r.Author, &r.Comment, &r.CreatedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.Number, &r.Author, &r.Comment, &r.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan revision: %w", err)
 		}
 		revisions = append(revisions, r)
@@ -652,6 +1021,104 @@ func (db *DB) ListRevisions(ctx context.Context, pageID int64, limit, offset int
 	return revisions, rows.Err()
 }
 
+// CountPageRevisions counts a page's revisions matching filter, ignoring
+// its limit and offset.
+func (db *DB) CountPageRevisions(ctx context.Context, pageID int64, filter models.RevisionFilter) (int, error) {
+	where, args := revisionWhere(pageID, filter)
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		WHERE `+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count revisions: %w", err)
+	}
+	return count, nil
+}
+
+// ListRevisionAuthors returns the usernames that have edited a page, for
+// the history's author filter.
+func (db *DB) ListRevisionAuthors(ctx context.Context, pageID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT u.username
+		FROM revisions r
+		JOIN users u ON r.author_id = u.id
+		WHERE r.page_id = ?
+		ORDER BY u.username
+	`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision authors: %w", err)
+	}
+	defer rows.Close()
+
+	var authors []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		authors = append(authors, name)
+	}
+	return authors, rows.Err()
+}
+
+// ListRecentChanges retrieves edits across all pages, newest first.
+func (db *DB) ListRecentChanges(ctx context.Context, filter models.ChangeFilter) ([]models.RecentChange, error) {
+	query := `
+		SELECT r.id, p.id, p.slug, p.title, u.username, r.comment, r.created_at,
+		       NOT EXISTS (SELECT 1 FROM revisions r2 WHERE r2.page_id = r.page_id AND r2.id < r.id)
+		FROM revisions r
+		JOIN pages p ON r.page_id = p.id
+		JOIN users u ON r.author_id = u.id
+		WHERE 1=1
+	`
+	var args []interface{}
+
+	if filter.PublishedOnly {
+		query += " AND p.is_published = 1"
+	}
+	if filter.Author != "" {
+		query += " AND u.username = ? COLLATE NOCASE"
+		args = append(args, filter.Author)
+	}
+	if filter.Tag != "" {
+		query += ` AND p.id IN (
+			SELECT pt.page_id FROM page_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE t.name = ? COLLATE NOCASE
+		)`
+		args = append(args, filter.Tag)
+	}
+	if filter.HideRestricted {
+		clause, groupArgs := pageGroupsWhere(filter.MemberOf)
+		query += " AND " + clause
+		args = append(args, groupArgs...)
+	}
+
+	query += " ORDER BY r.created_at DESC, r.id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []models.RecentChange
+	for rows.Next() {
+		var ch models.RecentChange
+		if err := rows.Scan(
+			&ch.RevisionID, &ch.PageID, &ch.PageSlug, &ch.PageTitle, &ch.Author, &ch.Comment, &ch.CreatedAt, &ch.IsNew,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan recent change: %w", err)
+		}
+		changes = append(changes, ch)
+	}
+
+	return changes, rows.Err()
+}
+
 // Tag queries
 
 // GetOrCreateTag gets an existing tag or creates a new one.
@@ -667,12 +1134,11 @@ func (db *DB) GetOrCreateTag(ctx context.Context, name string) (*models.Tag, err
 	}
 
 	// Create new tag
-	result, err := db.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?)", name)
+	id, err := insertReturningID(ctx, db, "INSERT INTO tags (name) VALUES (?)", name)
 	if err != nil {
 		return nil, err
 	}
 
-	id, _ := result.LastInsertId()
 	tag.ID = id
 	tag.Name = name
 
@@ -681,37 +1147,46 @@ func (db *DB) GetOrCreateTag(ctx context.Context, name string) (*models.Tag, err
 
 // SetPageTags replaces all tags for a page within a transaction.
 func (db *DB) SetPageTags(ctx context.Context, pageID int64, tagNames []string) error {
-	return db.Transaction(ctx, func(tx *sql.Tx) error {
-		// Remove existing tags
-		if _, err := tx.ExecContext(ctx, "DELETE FROM page_tags WHERE page_id = ?", pageID); err != nil {
-			return err
-		}
+	err := db.Transaction(ctx, func(tx *Tx) error {
+		return db.setPageTagsTx(ctx, tx, pageID, tagNames)
+	})
+	if err == nil {
+		db.pagesChanged()
+	}
+	return err
+}
 
-		// Add new tags
-		for _, name := range tagNames {
-			name = strings.TrimSpace(name)
-			if name == "" {
-				continue
-			}
+// setPageTagsTx replaces all tags for a page within a transaction.
+func (db *DB) setPageTagsTx(ctx context.Context, tx *Tx, pageID int64, tagNames []string) error {
+	// Remove existing tags
+	if _, err := tx.ExecContext(ctx, "DELETE FROM page_tags WHERE page_id = ?", pageID); err != nil {
+		return err
+	}
 
-			// Get or create tag within transaction
-			tag, err := db.getOrCreateTagTx(ctx, tx, name)
-			if err != nil {
-				return err
-			}
+	// Add new tags
+	for _, name := range tagNames {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
 
-			_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO page_tags (page_id, tag_id) VALUES (?, ?)", pageID, tag.ID)
-			if err != nil {
-				return err
-			}
+		// Get or create tag within transaction
+		tag, err := db.getOrCreateTagTx(ctx, tx, name)
+		if err != nil {
+			return err
 		}
 
-		return nil
-	})
+		_, err = tx.ExecContext(ctx, "INSERT INTO page_tags (page_id, tag_id) VALUES (?, ?) ON CONFLICT DO NOTHING", pageID, tag.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // getOrCreateTagTx gets or creates a tag within a transaction.
-func (db *DB) getOrCreateTagTx(ctx context.Context, tx *sql.Tx, name string) (*models.Tag, error) {
+func (db *DB) getOrCreateTagTx(ctx context.Context, tx *Tx, name string) (*models.Tag, error) {
 	name = strings.ToLower(strings.TrimSpace(name))
 
 	// Try to find existing tag
@@ -725,12 +1200,11 @@ func (db *DB) getOrCreateTagTx(ctx context.Context, tx *sql.Tx, name string) (*m
 	}
 
 	// Create new tag
-	result, err := tx.ExecContext(ctx, "INSERT INTO tags (name) VALUES (?)", name)
+	id, err := insertReturningID(ctx, tx, "INSERT INTO tags (name) VALUES (?)", name)
 	if err != nil {
 		return nil, err
 	}
 
-	id, _ := result.LastInsertId()
 	tag.ID = id
 	tag.Name = name
 
@@ -765,13 +1239,25 @@ func (db *DB) GetPageTags(ctx context.Context, pageID int64) ([]models.Tag, erro
 
 // ListTags retrieves all tags with page counts.
 func (db *DB) ListTags(ctx context.Context) ([]models.Tag, error) {
+	return db.ListTagsPage(ctx, -1, 0)
+}
+
+// ListTagsPage retrieves one page of tags with page counts, ordered by
+// name. A negative limit returns every tag from offset on.
+func (db *DB) ListTagsPage(ctx context.Context, limit, offset int) ([]models.Tag, error) {
+	// PostgreSQL rejects negative limits but reads a NULL one as none
+	var limitArg interface{} = limit
+	if limit < 0 && db.driver == DriverPostgres {
+		limitArg = nil
+	}
+
 	rows, err := db.QueryContext(ctx, `
-		SELECT t.id, t.name, COUNT(pt.page_id) as page_count
+		SELECT `+tagColumns+`
 		FROM tags t
-		LEFT JOIN page_tags pt ON t.id = pt.tag_id
-		GROUP BY t.id
+		LEFT JOIN tags parent ON parent.id = t.parent_id
 		ORDER BY t.name
-	`)
+		LIMIT ? OFFSET ?
+	`, limitArg, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -780,7 +1266,7 @@ func (db *DB) ListTags(ctx context.Context) ([]models.Tag, error) {
 	var tags []models.Tag
 	for rows.Next() {
 		var t models.Tag
-		if err := rows.Scan(&t.ID, &t.Name, &t.PageCount); err != nil {
+		if err := rows.Scan(&t.ID, &t.Name, &t.PageCount, &t.ParentID, &t.Parent); err != nil {
 			return nil, err
 		}
 		tags = append(tags, t)
@@ -789,6 +1275,149 @@ func (db *DB) ListTags(ctx context.Context) ([]models.Tag, error) {
 	return tags, rows.Err()
 }
 
+// tagColumns selects a tag aliased t with its page count and the name of
+// its parent, aliased parent.
+const tagColumns = `t.id, t.name,
+		(SELECT COUNT(*) FROM page_tags pt WHERE pt.tag_id = t.id) AS page_count,
+		t.parent_id, COALESCE(parent.name, '')`
+
+// GetTag returns the tag with the name, with its page count and parent, or
+// nil if there is none.
+func (db *DB) GetTag(ctx context.Context, name string) (*models.Tag, error) {
+	return db.getTag(ctx, "t.name = ? COLLATE NOCASE", name)
+}
+
+// GetTagByID returns a tag by ID, with its page count and parent, or nil
+// if there is none.
+func (db *DB) GetTagByID(ctx context.Context, id int64) (*models.Tag, error) {
+	return db.getTag(ctx, "t.id = ?", id)
+}
+
+func (db *DB) getTag(ctx context.Context, where string, arg interface{}) (*models.Tag, error) {
+	var t models.Tag
+	err := db.QueryRowContext(ctx, `
+		SELECT `+tagColumns+`
+		FROM tags t
+		LEFT JOIN tags parent ON parent.id = t.parent_id
+		WHERE `+where, arg).Scan(&t.ID, &t.Name, &t.PageCount, &t.ParentID, &t.Parent)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return &t, nil
+}
+
+// RenameTag changes a tag's name on every page that has it.
+func (db *DB) RenameTag(ctx context.Context, id int64, name string) error {
+	if _, err := db.ExecContext(ctx, "UPDATE tags SET name = ? WHERE id = ?", name, id); err != nil {
+		return fmt.Errorf("failed to rename tag: %w", err)
+	}
+	db.pagesChanged()
+	return nil
+}
+
+// SetTagParent files a tag under a broader one, or at the top level for a
+// nil parentID.
+func (db *DB) SetTagParent(ctx context.Context, id int64, parentID *int64) error {
+	if _, err := db.ExecContext(ctx, "UPDATE tags SET parent_id = ? WHERE id = ?", parentID, id); err != nil {
+		return fmt.Errorf("failed to set tag parent: %w", err)
+	}
+	return nil
+}
+
+// MergeTags moves every page tagged fromID to intoID, along with the tags
+// under fromID, and deletes fromID.
+func (db *DB) MergeTags(ctx context.Context, fromID, intoID int64) error {
+	defer db.pagesChanged()
+	return db.Transaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO page_tags (page_id, tag_id)
+			SELECT page_id, ? FROM page_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING
+		`, intoID, fromID); err != nil {
+			return fmt.Errorf("failed to retag pages: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tags SET parent_id = ? WHERE parent_id = ?", intoID, fromID); err != nil {
+			return fmt.Errorf("failed to move child tags: %w", err)
+		}
+		// Deleted explicitly so the search index triggers run
+		if _, err := tx.ExecContext(ctx, "DELETE FROM page_tags WHERE tag_id = ?", fromID); err != nil {
+			return fmt.Errorf("failed to untag pages: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", fromID); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteTag deletes a tag. Its child tags move to the top level.
+func (db *DB) DeleteTag(ctx context.Context, id int64) error {
+	defer db.pagesChanged()
+	return db.Transaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM page_tags WHERE tag_id = ?", id); err != nil {
+			return fmt.Errorf("failed to untag pages: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tags SET parent_id = NULL WHERE parent_id = ?", id); err != nil {
+			return fmt.Errorf("failed to move child tags: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete tag: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteUnusedTags deletes the tags no page has and no other tag falls
+// under, and returns how many it deleted.
+func (db *DB) DeleteUnusedTags(ctx context.Context) (int64, error) {
+	res, err := db.ExecContext(ctx, `
+		DELETE FROM tags
+		WHERE NOT EXISTS (SELECT 1 FROM page_tags pt WHERE pt.tag_id = tags.id)
+		AND NOT EXISTS (SELECT 1 FROM tags child WHERE child.parent_id = tags.id)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unused tags: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ListAllPageTags returns the tags of every tagged page, keyed by page ID.
+func (db *DB) ListAllPageTags(ctx context.Context) (map[int64][]models.Tag, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT pt.page_id, t.id, t.name
+		FROM page_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		ORDER BY t.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]models.Tag)
+	for rows.Next() {
+		var pageID int64
+		var t models.Tag
+		if err := rows.Scan(&pageID, &t.ID, &t.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan page tag: %w", err)
+		}
+		tags[pageID] = append(tags[pageID], t)
+	}
+	return tags, rows.Err()
+}
+
+// CountTags returns the total number of tags.
+func (db *DB) CountTags(ctx context.Context) (int, error) {
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tags").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count tags: %w", err)
+	}
+	return count, nil
+}
+
 // Search queries
 
 // sanitizeFTS5Query converts a user search query to a valid FTS5 query.
@@ -816,8 +1445,9 @@ func sanitizeFTS5Query(query string) string {
 
 		word = strings.TrimSpace(word)
 		if word != "" {
-			// Add prefix matching with * for partial word matches
-			parts = append(parts, word+"*")
+			// Quote the word so other punctuation can't break the query,
+			// and add prefix matching with * for partial word matches
+			parts = append(parts, `"`+word+`"*`)
 		}
 	}
 
@@ -829,19 +1459,129 @@ func sanitizeFTS5Query(query string) string {
 	return strings.Join(parts, " OR ")
 }
 
-// SearchPages performs full-text search on pages.
-func (db *DB) SearchPages(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+// ftsQuery converts a user search query to a full-text query for the
+// database, or "" when nothing is left to search for.
+func (db *DB) ftsQuery(query string) string {
+	if db.driver == DriverPostgres {
+		return postgresTSQuery(query)
+	}
+	return sanitizeFTS5Query(query)
+}
+
+// Search field weights: a match in the title counts most, then the slug and
+// tags, then the content.
+const (
+	searchTitleWeight   = 10.0
+	searchSlugWeight    = 6.0
+	searchTagsWeight    = 4.0
+	searchContentWeight = 1.0
+)
+
+// searchFTSWhere restricts full-text searches to visible pages matching the
+// query.
+const searchFTSWhere = `
+		WHERE pages_fts MATCH ?
+		AND p.is_published = 1
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		AND (? = 1 OR p.archived_at IS NULL)`
+
+// Snippet match markers, replaced by <mark> once the snippet is escaped.
+const (
+	snippetMatchStart = "\x01"
+	snippetMatchEnd   = "\x02"
+)
+
+// SearchPages performs full-text search on pages, best matches first, over
+// their titles, slugs, tags and content. Archived pages are only included
+// when asked for; pages restricted to groups never are.
+func (db *DB) SearchPages(ctx context.Context, query string, limit, offset int, includeArchived bool) ([]models.SearchResult, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, nil
 	}
 
-	// Always use LIKE search for reliability - FTS5 can be tricky with SQLite
-	return db.searchPagesLike(ctx, query, limit)
+	match := db.ftsQuery(query)
+	if match == "" {
+		return db.searchPagesLike(ctx, query, limit, offset, includeArchived)
+	}
+
+	search := fmt.Sprintf(`
+		SELECT p.id, p.slug, p.title,
+			   snippet(pages_fts, 3, char(1), char(2), '...', 24),
+			   -bm25(pages_fts, %g, %g, %g, %g) AS rank, p.updated_at, p.archived_at IS NOT NULL
+		FROM pages_fts
+		JOIN pages p ON p.id = pages_fts.rowid`+searchFTSWhere+`
+		ORDER BY rank DESC, p.updated_at DESC
+		LIMIT ? OFFSET ?
+	`, searchTitleWeight, searchSlugWeight, searchTagsWeight, searchContentWeight)
+	if db.driver == DriverPostgres {
+		search = postgresSearchQuery
+	}
+
+	rows, err := db.QueryContext(ctx, search, match, includeArchived, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var r models.SearchResult
+		if err := rows.Scan(&r.PageID, &r.Slug, &r.Title, &r.Snippet, &r.Rank, &r.UpdatedAt, &r.Archived); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		r.Snippet = highlightSnippet(r.Snippet)
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// highlightSnippet escapes an FTS snippet for HTML and marks its matches.
+func highlightSnippet(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetMatchStart, "<mark>")
+	return strings.ReplaceAll(snippet, snippetMatchEnd, "</mark>")
+}
+
+// searchLikeWhere restricts LIKE searches to visible pages matching the
+// pattern in the title or content.
+const searchLikeWhere = `
+		WHERE (p.title LIKE ? OR p.content LIKE ?)
+		AND p.is_published = 1
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		AND (? = 1 OR p.archived_at IS NULL)`
+
+// CountSearchResults counts the pages SearchPages would return without a
+// limit.
+func (db *DB) CountSearchResults(ctx context.Context, query string, includeArchived bool) (int, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return 0, nil
+	}
+
+	var count int
+	var err error
+	if match := db.ftsQuery(query); match != "" {
+		from := " FROM pages_fts JOIN pages p ON p.id = pages_fts.rowid" + searchFTSWhere
+		if db.driver == DriverPostgres {
+			from = postgresSearchFrom
+		}
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, match, includeArchived).Scan(&count)
+	} else {
+		likePattern := "%" + query + "%"
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pages p"+searchLikeWhere,
+			likePattern, likePattern, includeArchived).Scan(&count)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+	return count, nil
 }
 
-// searchPagesLike performs a fallback LIKE-based search when FTS5 fails or returns no results.
-func (db *DB) searchPagesLike(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+// searchPagesLike performs a LIKE-based search for queries with no words
+// left for full-text search, e.g. only punctuation.
+func (db *DB) searchPagesLike(ctx context.Context, query string, limit, offset int, includeArchived bool) ([]models.SearchResult, error) {
 	query = strings.TrimSpace(query)
 	if query == "" {
 		return nil, nil
@@ -856,13 +1596,11 @@ func (db *DB) searchPagesLike(ctx context.Context, query string, limit int) ([]m
 				   WHEN p.content LIKE ? THEN substr(p.content, 1, 150) || '...'
 				   ELSE ''
 			   END as snippet,
-			   0.0 as rank, p.updated_at
-		FROM pages p
-		WHERE (p.title LIKE ? OR p.content LIKE ?)
-		AND p.is_published = 1
-		ORDER BY p.updated_at DESC
-		LIMIT ?
-	`, likePattern, likePattern, likePattern, limit)
+			   0.0 as rank, p.updated_at, p.archived_at IS NOT NULL
+		FROM pages p`+searchLikeWhere+`
+		ORDER BY p.updated_at DESC, p.id DESC
+		LIMIT ? OFFSET ?
+	`, likePattern, likePattern, likePattern, includeArchived, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("fallback search failed: %w", err)
 	}
@@ -871,9 +1609,10 @@ func (db *DB) searchPagesLike(ctx context.Context, query string, limit int) ([]m
 	var results []models.SearchResult
 	for rows.Next() {
 		var r models.SearchResult
-		if err := rows.Scan(&r.PageID, &r.Slug, &r.Title, &r.Snippet, &r.Rank, &r.UpdatedAt); err != nil {
+		if err := rows.Scan(&r.PageID, &r.Slug, &r.Title, &r.Snippet, &r.Rank, &r.UpdatedAt, &r.Archived); err != nil {
 			return nil, fmt.Errorf("failed to scan search result: %w", err)
 		}
+		r.Snippet = html.EscapeString(r.Snippet)
 		results = append(results, r)
 	}
 
@@ -888,10 +1627,11 @@ func (db *DB) RebuildFTSIndex(ctx context.Context) error {
 	}
 
 	// Repopulate from pages table
-	if _, err := db.ExecContext(ctx, `
-		INSERT INTO pages_fts(rowid, title, content)
-		SELECT id, title, content FROM pages
-	`); err != nil {
+	rebuild := "INSERT INTO pages_fts(rowid, title, slug, tags, content)" + pagesFTSRows
+	if db.driver == DriverPostgres {
+		rebuild = postgresFTSRows
+	}
+	if _, err := db.ExecContext(ctx, rebuild); err != nil {
 		return fmt.Errorf("failed to rebuild FTS index: %w", err)
 	}
 
@@ -904,15 +1644,14 @@ func (db *DB) RebuildFTSIndex(ctx context.Context) error {
 func (db *DB) CreateAttachment(ctx context.Context, att *models.Attachment) error {
 	att.CreatedAt = time.Now().UTC()
 
-	result, err := db.ExecContext(ctx, `
-		INSERT INTO attachments (page_id, filename, filepath, mime_type, size_bytes, uploader_id, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, att.PageID, att.Filename, att.Filepath, att.MimeType, att.SizeBytes, att.UploaderID, att.CreatedAt)
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO attachments (page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, att.PageID, att.Filename, att.Filepath, att.MimeType, att.SizeBytes, att.Hash, att.UploaderID, att.CreatedAt)
 	if err != nil {
 		return err
 	}
 
-	id, _ := result.LastInsertId()
 	att.ID = id
 
 	return nil
@@ -922,9 +1661,9 @@ func (db *DB) CreateAttachment(ctx context.Context, att *models.Attachment) erro
 func (db *DB) GetAttachment(ctx context.Context, id int64) (*models.Attachment, error) {
 	att := &models.Attachment{}
 	err := db.QueryRowContext(ctx, `
-		SELECT id, page_id, filename, filepath, mime_type, size_bytes, uploader_id, created_at
+		SELECT id, page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at
 		FROM attachments WHERE id = ?
-	`, id).Scan(&att.ID, &att.PageID, &att.Filename, &att.Filepath, &att.MimeType, &att.SizeBytes, &att.UploaderID, &att.CreatedAt)
+	`, id).Scan(&att.ID, &att.PageID, &att.Filename, &att.Filepath, &att.MimeType, &att.SizeBytes, &att.Hash, &att.UploaderID, &att.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -935,7 +1674,7 @@ func (db *DB) GetAttachment(ctx context.Context, id int64) (*models.Attachment,
 // ListAttachments retrieves attachments for a page.
 func (db *DB) ListAttachments(ctx context.Context, pageID int64) ([]models.Attachment, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, page_id, filename, filepath, mime_type, size_bytes, uploader_id, created_at
+		SELECT id, page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at
 		FROM attachments WHERE page_id = ?
 		ORDER BY created_at DESC
 	`, pageID)
@@ -947,7 +1686,122 @@ func (db *DB) ListAttachments(ctx context.Context, pageID int64) ([]models.Attac
 	var attachments []models.Attachment
 	for rows.Next() {
 		var a models.Attachment
-		if err := rows.Scan(&a.ID, &a.PageID, &a.Filename, &a.Filepath, &a.MimeType, &a.SizeBytes, &a.UploaderID, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.PageID, &a.Filename, &a.Filepath, &a.MimeType, &a.SizeBytes, &a.Hash, &a.UploaderID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// FindAttachmentByHash retrieves the earliest attachment whose file has
+// the content hash.
+func (db *DB) FindAttachmentByHash(ctx context.Context, hash string) (*models.Attachment, error) {
+	att := &models.Attachment{}
+	err := db.QueryRowContext(ctx, `
+		SELECT id, page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at
+		FROM attachments WHERE content_hash = ?
+		ORDER BY id LIMIT 1
+	`, hash).Scan(&att.ID, &att.PageID, &att.Filename, &att.Filepath, &att.MimeType, &att.SizeBytes, &att.Hash, &att.UploaderID, &att.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return att, err
+}
+
+// ListAttachmentsByPath retrieves the attachments stored at a file path.
+// Identical uploads share one file, so there can be several.
+func (db *DB) ListAttachmentsByPath(ctx context.Context, path string) ([]models.Attachment, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at
+		FROM attachments WHERE filepath = ?
+		ORDER BY id
+	`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.PageID, &a.Filename, &a.Filepath, &a.MimeType, &a.SizeBytes, &a.Hash, &a.UploaderID, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// DeleteAttachmentsByPath removes the attachments stored at a file path.
+func (db *DB) DeleteAttachmentsByPath(ctx context.Context, path string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM attachments WHERE filepath = ?", path)
+	return err
+}
+
+// ListAttachmentPathsInUse returns the file paths of the attachments
+// linked to a page or waiting to be since after pendingSince.
+func (db *DB) ListAttachmentPathsInUse(ctx context.Context, pendingSince time.Time) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT filepath FROM attachments
+		WHERE page_id IS NOT NULL OR created_at >= ?
+	`, pendingSince.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachment paths: %w", err)
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment path: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// ListAttachmentHashes maps the file paths of attachments to the content
+// hashes recorded for them. Attachments saved before hashing are left out.
+func (db *DB) ListAttachmentHashes(ctx context.Context) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT filepath, content_hash FROM attachments WHERE content_hash != ''")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachment hashes: %w", err)
+	}
+	defer rows.Close()
+
+	hashes := make(map[string]string)
+	for rows.Next() {
+		var path, hash string
+		if err := rows.Scan(&path, &hash); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment hash: %w", err)
+		}
+		hashes[path] = hash
+	}
+	return hashes, rows.Err()
+}
+
+// ListPendingAttachments retrieves the attachments a user uploaded that
+// aren't linked to a page yet.
+func (db *DB) ListPendingAttachments(ctx context.Context, uploaderID int64) ([]models.Attachment, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, page_id, filename, filepath, mime_type, size_bytes, content_hash, uploader_id, created_at
+		FROM attachments WHERE page_id IS NULL AND uploader_id = ?
+		ORDER BY created_at
+	`, uploaderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		var a models.Attachment
+		if err := rows.Scan(&a.ID, &a.PageID, &a.Filename, &a.Filepath, &a.MimeType, &a.SizeBytes, &a.Hash, &a.UploaderID, &a.CreatedAt); err != nil {
 			return nil, err
 		}
 		attachments = append(attachments, a)
@@ -956,6 +1810,12 @@ func (db *DB) ListAttachments(ctx context.Context, pageID int64) ([]models.Attac
 	return attachments, rows.Err()
 }
 
+// LinkAttachment links a pending attachment to a page.
+func (db *DB) LinkAttachment(ctx context.Context, id, pageID int64) error {
+	_, err := db.ExecContext(ctx, "UPDATE attachments SET page_id = ? WHERE id = ? AND page_id IS NULL", pageID, id)
+	return err
+}
+
 // DeleteAttachment removes an attachment.
 func (db *DB) DeleteAttachment(ctx context.Context, id int64) error {
 	_, err := db.ExecContext(ctx, "DELETE FROM attachments WHERE id = ?", id)
@@ -993,7 +1853,7 @@ func (db *DB) CountPages(ctx context.Context) (int, error) {
 func (db *DB) CreateAPIToken(ctx context.Context, token *models.APIToken) error {
 	token.CreatedAt = time.Now().UTC()
 
-	result, err := db.ExecContext(ctx, `
+	id, err := insertReturningID(ctx, db, `
 		INSERT INTO api_tokens (user_id, token_hash, name, scopes, expires_at, created_at)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, token.UserID, token.TokenHash, token.Name, token.Scopes, token.ExpiresAt, token.CreatedAt)
@@ -1001,11 +1861,6 @@ func (db *DB) CreateAPIToken(ctx context.Context, token *models.APIToken) error
 		return fmt.Errorf("failed to create API token: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get token ID: %w", err)
-	}
-
 	token.ID = id
 	return nil
 }
@@ -1070,22 +1925,26 @@ func (db *DB) DeleteAPIToken(ctx context.Context, tokenID int64) error {
 	return err
 }
 
-// DeleteExpiredAPITokens removes all expired tokens.
-func (db *DB) DeleteExpiredAPITokens(ctx context.Context) error {
-	_, err := db.ExecContext(ctx, "DELETE FROM api_tokens WHERE expires_at < ?", time.Now().UTC())
-	return err
+// DeleteExpiredAPITokens removes all expired tokens and returns how many
+// there were.
+func (db *DB) DeleteExpiredAPITokens(ctx context.Context) (int64, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM api_tokens WHERE expires_at < ?", time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired API tokens: %w", err)
+	}
+	return result.RowsAffected()
 }
 
 // Settings queries
 
-// GetSetting retrieves a setting by key.
+// GetSetting retrieves a setting by key, or "" if it isn't set. Settings
+// are cached; see settingsCacheTTL.
 func (db *DB) GetSetting(ctx context.Context, key string) (string, error) {
-	var value string
-	err := db.QueryRowContext(ctx, "SELECT value FROM settings WHERE key = ?", key).Scan(&value)
-	if err == sql.ErrNoRows {
-		return "", nil
+	settings, err := db.settings(ctx)
+	if err != nil {
+		return "", err
 	}
-	return value, err
+	return settings[key], nil
 }
 
 // SetSetting creates or updates a setting.
@@ -1094,7 +1953,56 @@ func (db *DB) SetSetting(ctx context.Context, key, value string) error {
 		INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
 		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at
 	`, key, value, time.Now().UTC())
-	return err
+	if err != nil {
+		return err
+	}
+	db.settingChanged(key)
+	return nil
+}
+
+// ListPageTitles returns every page with only what a title lookup needs:
+// its ID, slug, title, update time, published and archived state, and the
+// IDs of the groups it is restricted to.
+func (db *DB) ListPageTitles(ctx context.Context) ([]models.Page, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, slug, title, is_published, updated_at, archived_at
+		FROM pages
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page titles: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.Page
+	index := make(map[int64]int)
+	for rows.Next() {
+		var p models.Page
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.IsPublished, &p.UpdatedAt, &p.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		index[p.ID] = len(pages)
+		pages = append(pages, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	groupRows, err := db.QueryContext(ctx, "SELECT page_id, group_id FROM page_groups")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page groups: %w", err)
+	}
+	defer groupRows.Close()
+	for groupRows.Next() {
+		var pageID, groupID int64
+		if err := groupRows.Scan(&pageID, &groupID); err != nil {
+			return nil, fmt.Errorf("failed to scan page group: %w", err)
+		}
+		if i, ok := index[pageID]; ok {
+			pages[i].Groups = append(pages[i].Groups, models.Group{ID: groupID})
+		}
+	}
+	return pages, groupRows.Err()
 }
 
 // PageTreeNode represents a page in the navigation tree.
@@ -1105,13 +2013,14 @@ type PageTreeNode struct {
 	Children []*PageTreeNode
 }
 
-// GetPageTree retrieves the full page tree for navigation.
-func (db *DB) GetPageTree(ctx context.Context) ([]*PageTreeNode, error) {
+// loadPageTree builds the page tree for navigation from the database.
+func (db *DB) loadPageTree(ctx context.Context) ([]*PageTreeNode, error) {
 	// Get all pages with parent info
 	rows, err := db.QueryContext(ctx, `
 		SELECT id, slug, title, parent_id
-		FROM pages
-		WHERE is_published = 1
+		FROM pages p
+		WHERE is_published = 1 AND archived_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
 		ORDER BY title ASC
 	`)
 	if err != nil {
@@ -1165,8 +2074,22 @@ func (db *DB) GetPageTree(ctx context.Context) ([]*PageTreeNode, error) {
 	return tree, rows.Err()
 }
 
-// GetAllSettings retrieves all settings as a map.
+// GetAllSettings retrieves all settings as a map, which the caller may
+// modify.
 func (db *DB) GetAllSettings(ctx context.Context) (map[string]string, error) {
+	settings, err := db.settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all := make(map[string]string, len(settings))
+	for key, value := range settings {
+		all[key] = value
+	}
+	return all, nil
+}
+
+// loadSettings reads every setting from the database.
+func (db *DB) loadSettings(ctx context.Context) (map[string]string, error) {
 	rows, err := db.QueryContext(ctx, "SELECT key, value FROM settings")
 	if err != nil {
 		return nil, err
@@ -1187,23 +2110,47 @@ func (db *DB) GetAllSettings(ctx context.Context) (map[string]string, error) {
 
 // Share Link queries
 
+// shareLinkColumns and shareLinkJoins select a share link for
+// shareLinkFields. Collections have no page, so its fields are empty.
+const (
+	shareLinkColumns = `sl.id, sl.token_hash, sl.target_type, sl.target_ref, COALESCE(sl.page_id, 0), sl.created_by, sl.include_children,
+		       sl.max_views, sl.max_ips, sl.expires_at, sl.group_id, sl.is_revoked, sl.view_count, sl.created_at,
+		       COALESCE(p.title, ''), COALESCE(p.slug, ''), u.username, COALESCE(g.name, '')`
+	shareLinkJoins = `
+		LEFT JOIN pages p ON sl.page_id = p.id
+		JOIN users u ON sl.created_by = u.id
+		LEFT JOIN groups g ON sl.group_id = g.id`
+)
+
+// shareLinkFields returns the scan destinations for shareLinkColumns.
+func shareLinkFields(link *models.ShareLink) []interface{} {
+	return []interface{}{
+		&link.ID, &link.TokenHash, &link.TargetType, &link.TargetRef, &link.PageID, &link.CreatedBy, &link.IncludeChildren,
+		&link.MaxViews, &link.MaxIPs, &link.ExpiresAt, &link.GroupID, &link.IsRevoked, &link.ViewCount, &link.CreatedAt,
+		&link.PageTitle, &link.PageSlug, &link.CreatorUsername, &link.GroupName,
+	}
+}
+
+// sharePageID returns the page_id to store for link, NULL for collections.
+func sharePageID(link *models.ShareLink) *int64 {
+	if link.PageID == 0 {
+		return nil
+	}
+	return &link.PageID
+}
+
 // CreateShareLink inserts a new share link.
 func (db *DB) CreateShareLink(ctx context.Context, link *models.ShareLink) error {
 	link.CreatedAt = time.Now().UTC()
 
-	result, err := db.ExecContext(ctx, `
-		INSERT INTO share_links (token_hash, page_id, created_by, include_children, max_views, max_ips, expires_at, is_revoked, view_count, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, link.TokenHash, link.PageID, link.CreatedBy, link.IncludeChildren, link.MaxViews, link.MaxIPs, link.ExpiresAt, link.IsRevoked, link.ViewCount, link.CreatedAt)
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO share_links (token_hash, target_type, target_ref, page_id, created_by, include_children, max_views, max_ips, expires_at, group_id, is_revoked, view_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, link.TokenHash, link.TargetType, link.TargetRef, sharePageID(link), link.CreatedBy, link.IncludeChildren, link.MaxViews, link.MaxIPs, link.ExpiresAt, link.GroupID, link.IsRevoked, link.ViewCount, link.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create share link: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get share link ID: %w", err)
-	}
-
 	link.ID = id
 	return nil
 }
@@ -1212,18 +2159,10 @@ func (db *DB) CreateShareLink(ctx context.Context, link *models.ShareLink) error
 func (db *DB) GetShareLinkByToken(ctx context.Context, tokenHash string) (*models.ShareLink, error) {
 	link := &models.ShareLink{}
 	err := db.QueryRowContext(ctx, `
-		SELECT sl.id, sl.token_hash, sl.page_id, sl.created_by, sl.include_children,
-		       sl.max_views, sl.max_ips, sl.expires_at, sl.is_revoked, sl.view_count, sl.created_at,
-		       p.title, p.slug, u.username
-		FROM share_links sl
-		JOIN pages p ON sl.page_id = p.id
-		JOIN users u ON sl.created_by = u.id
+		SELECT `+shareLinkColumns+`
+		FROM share_links sl`+shareLinkJoins+`
 		WHERE sl.token_hash = ?
-	`, tokenHash).Scan(
-		&link.ID, &link.TokenHash, &link.PageID, &link.CreatedBy, &link.IncludeChildren,
-		&link.MaxViews, &link.MaxIPs, &link.ExpiresAt, &link.IsRevoked, &link.ViewCount, &link.CreatedAt,
-		&link.PageTitle, &link.PageSlug, &link.CreatorUsername,
-	)
+	`, tokenHash).Scan(shareLinkFields(link)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1237,18 +2176,10 @@ func (db *DB) GetShareLinkByToken(ctx context.Context, tokenHash string) (*model
 func (db *DB) GetShareLinkByID(ctx context.Context, id int64) (*models.ShareLink, error) {
 	link := &models.ShareLink{}
 	err := db.QueryRowContext(ctx, `
-		SELECT sl.id, sl.token_hash, sl.page_id, sl.created_by, sl.include_children,
-		       sl.max_views, sl.max_ips, sl.expires_at, sl.is_revoked, sl.view_count, sl.created_at,
-		       p.title, p.slug, u.username
-		FROM share_links sl
-		JOIN pages p ON sl.page_id = p.id
-		JOIN users u ON sl.created_by = u.id
+		SELECT `+shareLinkColumns+`
+		FROM share_links sl`+shareLinkJoins+`
 		WHERE sl.id = ?
-	`, id).Scan(
-		&link.ID, &link.TokenHash, &link.PageID, &link.CreatedBy, &link.IncludeChildren,
-		&link.MaxViews, &link.MaxIPs, &link.ExpiresAt, &link.IsRevoked, &link.ViewCount, &link.CreatedAt,
-		&link.PageTitle, &link.PageSlug, &link.CreatorUsername,
-	)
+	`, id).Scan(shareLinkFields(link)...)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -1269,12 +2200,8 @@ func (db *DB) GetShareLinkByID(ctx context.Context, id int64) (*models.ShareLink
 // GetShareLinksByPage retrieves all share links for a specific page.
 func (db *DB) GetShareLinksByPage(ctx context.Context, pageID int64) ([]models.ShareLink, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT sl.id, sl.token_hash, sl.page_id, sl.created_by, sl.include_children,
-		       sl.max_views, sl.max_ips, sl.expires_at, sl.is_revoked, sl.view_count, sl.created_at,
-		       p.title, p.slug, u.username
-		FROM share_links sl
-		JOIN pages p ON sl.page_id = p.id
-		JOIN users u ON sl.created_by = u.id
+		SELECT `+shareLinkColumns+`
+		FROM share_links sl`+shareLinkJoins+`
 		WHERE sl.page_id = ?
 		ORDER BY sl.created_at DESC
 	`, pageID)
@@ -1289,12 +2216,8 @@ func (db *DB) GetShareLinksByPage(ctx context.Context, pageID int64) ([]models.S
 // GetShareLinksByUser retrieves all share links created by a specific user.
 func (db *DB) GetShareLinksByUser(ctx context.Context, userID int64) ([]models.ShareLink, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT sl.id, sl.token_hash, sl.page_id, sl.created_by, sl.include_children,
-		       sl.max_views, sl.max_ips, sl.expires_at, sl.is_revoked, sl.view_count, sl.created_at,
-		       p.title, p.slug, u.username
-		FROM share_links sl
-		JOIN pages p ON sl.page_id = p.id
-		JOIN users u ON sl.created_by = u.id
+		SELECT `+shareLinkColumns+`
+		FROM share_links sl`+shareLinkJoins+`
 		WHERE sl.created_by = ?
 		ORDER BY sl.created_at DESC
 	`, userID)
@@ -1309,12 +2232,8 @@ func (db *DB) GetShareLinksByUser(ctx context.Context, userID int64) ([]models.S
 // ListAllShareLinks retrieves all share links (admin view).
 func (db *DB) ListAllShareLinks(ctx context.Context, limit, offset int) ([]models.ShareLink, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT sl.id, sl.token_hash, sl.page_id, sl.created_by, sl.include_children,
-		       sl.max_views, sl.max_ips, sl.expires_at, sl.is_revoked, sl.view_count, sl.created_at,
-		       p.title, p.slug, u.username
-		FROM share_links sl
-		JOIN pages p ON sl.page_id = p.id
-		JOIN users u ON sl.created_by = u.id
+		SELECT `+shareLinkColumns+`
+		FROM share_links sl`+shareLinkJoins+`
 		ORDER BY sl.created_at DESC
 		LIMIT ? OFFSET ?
 	`, limit, offset)
@@ -1331,11 +2250,7 @@ func (db *DB) scanShareLinks(ctx context.Context, rows *sql.Rows) ([]models.Shar
 	var links []models.ShareLink
 	for rows.Next() {
 		var link models.ShareLink
-		if err := rows.Scan(
-			&link.ID, &link.TokenHash, &link.PageID, &link.CreatedBy, &link.IncludeChildren,
-			&link.MaxViews, &link.MaxIPs, &link.ExpiresAt, &link.IsRevoked, &link.ViewCount, &link.CreatedAt,
-			&link.PageTitle, &link.PageSlug, &link.CreatorUsername,
-		); err != nil {
+		if err := rows.Scan(shareLinkFields(&link)...); err != nil {
 			return nil, fmt.Errorf("failed to scan share link: %w", err)
 		}
 		links = append(links, link)
@@ -1369,6 +2284,20 @@ func (db *DB) RevokeShareLink(ctx context.Context, linkID int64) error {
 	return err
 }
 
+// UpdateShareLink saves a share link's options, limits, expiry and
+// revocation.
+func (db *DB) UpdateShareLink(ctx context.Context, link *models.ShareLink) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE share_links
+		SET include_children = ?, max_views = ?, max_ips = ?, expires_at = ?, is_revoked = ?
+		WHERE id = ?
+	`, link.IncludeChildren, link.MaxViews, link.MaxIPs, link.ExpiresAt, link.IsRevoked, link.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update share link: %w", err)
+	}
+	return nil
+}
+
 // DeleteShareLink removes a share link and its access records.
 func (db *DB) DeleteShareLink(ctx context.Context, linkID int64) error {
 	// Access records are deleted automatically via ON DELETE CASCADE
@@ -1380,7 +2309,7 @@ func (db *DB) DeleteShareLink(ctx context.Context, linkID int64) error {
 func (db *DB) RecordShareAccess(ctx context.Context, access *models.ShareLinkAccess) error {
 	access.AccessedAt = time.Now().UTC()
 
-	result, err := db.ExecContext(ctx, `
+	id, err := insertReturningID(ctx, db, `
 		INSERT INTO share_link_access (share_link_id, ip_address, user_agent, accessed_at)
 		VALUES (?, ?, ?, ?)
 	`, access.ShareLinkID, access.IPAddress, access.UserAgent, access.AccessedAt)
@@ -1388,11 +2317,52 @@ func (db *DB) RecordShareAccess(ctx context.Context, access *models.ShareLinkAcc
 		return fmt.Errorf("failed to record share access: %w", err)
 	}
 
-	id, _ := result.LastInsertId()
 	access.ID = id
 	return nil
 }
 
+// DeleteShareAccessBefore removes share link visits recorded before the
+// cutoff and returns how many there were. Visits to links limited by
+// distinct IPs are kept, since the limit counts them.
+func (db *DB) DeleteShareAccessBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM share_link_access
+		WHERE accessed_at < ?
+		  AND share_link_id NOT IN (SELECT id FROM share_links WHERE max_ips IS NOT NULL)
+	`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete share access logs: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ScanUploadReferences calls fn with every stored text that links to an
+// upload: page, revision and draft content, social images, settings and
+// email templates.
+func (db *DB) ScanUploadReferences(ctx context.Context, fn func(text string)) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT content FROM pages WHERE content LIKE '%/uploads/%'
+		UNION ALL SELECT content FROM revisions WHERE content LIKE '%/uploads/%'
+		UNION ALL SELECT content FROM page_drafts WHERE content LIKE '%/uploads/%'
+		UNION ALL SELECT social_image FROM page_properties WHERE social_image LIKE '%/uploads/%'
+		UNION ALL SELECT value FROM settings WHERE value LIKE '%/uploads/%'
+		UNION ALL SELECT body_text || body_html FROM email_templates WHERE body_text || body_html LIKE '%/uploads/%'
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to scan upload references: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var text string
+		if err := rows.Scan(&text); err != nil {
+			return fmt.Errorf("failed to scan upload references: %w", err)
+		}
+		fn(text)
+	}
+	return rows.Err()
+}
+
 // GetShareLinkUniqueIPCount returns the count of unique IPs that accessed a share link.
 func (db *DB) GetShareLinkUniqueIPCount(ctx context.Context, linkID int64) (int, error) {
 	var count int
@@ -1437,6 +2407,38 @@ func (db *DB) GetShareLinkAccesses(ctx context.Context, linkID int64, limit, off
 	return accesses, rows.Err()
 }
 
+// ListShareCollection returns the published pages a tag or search share
+// link exposes, at most limit of them: pages with the tag, most recently
+// updated first, or the search's results, best first. Pages restricted to
+// groups are only included in tag links shared with one of their groups.
+func (db *DB) ListShareCollection(ctx context.Context, link *models.ShareLink, limit int) ([]models.PageSummary, error) {
+	switch link.TargetType {
+	case models.ShareTargetTag:
+		filter := models.NewPageFilter()
+		published := true
+		filter.IsPublished = &published
+		filter.Tag = &link.TargetRef
+		filter.HideRestricted = true
+		if link.GroupID != nil {
+			filter.MemberOf = []int64{*link.GroupID}
+		}
+		filter.Limit = limit
+		return db.ListPages(ctx, filter)
+
+	case models.ShareTargetSearch:
+		results, err := db.SearchPages(ctx, link.TargetRef, limit, 0, false)
+		if err != nil {
+			return nil, err
+		}
+		pages := make([]models.PageSummary, len(results))
+		for i, r := range results {
+			pages[i] = models.PageSummary{ID: r.PageID, Slug: r.Slug, Title: r.Title, UpdatedAt: r.UpdatedAt}
+		}
+		return pages, nil
+	}
+	return nil, nil
+}
+
 // CountShareLinks returns the total number of share links.
 func (db *DB) CountShareLinks(ctx context.Context) (int, error) {
 	var count int
@@ -1458,3 +2460,2962 @@ func (db *DB) IsPageDescendant(ctx context.Context, parentID int64, childSlug st
 	`, parentID, childSlug).Scan(&count)
 	return count > 0, err
 }
+
+// Notification queries
+
+// CreateNotification inserts a new notification.
+func (db *DB) CreateNotification(ctx context.Context, n *models.Notification) error {
+	n.CreatedAt = time.Now().UTC()
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO notifications (user_id, actor_id, page_id, kind, message, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, n.UserID, n.ActorID, n.PageID, n.Kind, n.Message, n.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	n.ID = id
+	return nil
+}
+
+// ListNotifications retrieves the most recent notifications for a user.
+// With hideRestricted, notifications about pages restricted to groups are
+// left out unless one of their groups is in memberOf.
+func (db *DB) ListNotifications(ctx context.Context, userID int64, limit int, hideRestricted bool, memberOf []int64) ([]models.Notification, error) {
+	query := `
+		SELECT n.id, n.user_id, n.actor_id, n.page_id, n.kind, n.message, n.is_read, n.created_at,
+		       COALESCE(u.username, ''), COALESCE(p.title, ''), COALESCE(p.slug, '')
+		FROM notifications n
+		LEFT JOIN users u ON n.actor_id = u.id
+		LEFT JOIN pages p ON n.page_id = p.id
+		WHERE n.user_id = ?
+	`
+	args := []interface{}{userID}
+	if hideRestricted {
+		clause, groupArgs := pageGroupsWhere(memberOf)
+		query += " AND " + clause
+		args = append(args, groupArgs...)
+	}
+	query += " ORDER BY n.created_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(
+			&n.ID, &n.UserID, &n.ActorID, &n.PageID, &n.Kind, &n.Message, &n.IsRead, &n.CreatedAt,
+			&n.ActorUsername, &n.PageTitle, &n.PageSlug,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// CountUnreadNotifications returns the number of unread notifications for a user.
+func (db *DB) CountUnreadNotifications(ctx context.Context, userID int64) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM notifications WHERE user_id = ? AND is_read = 0", userID,
+	).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationsRead marks all of a user's notifications as read.
+func (db *DB) MarkNotificationsRead(ctx context.Context, userID int64) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE notifications SET is_read = 1 WHERE user_id = ? AND is_read = 0", userID)
+	return err
+}
+
+// Review request queries
+
+// CreateReviewRequest inserts a new pending review request.
+func (db *DB) CreateReviewRequest(ctx context.Context, r *models.ReviewRequest) error {
+	r.CreatedAt = time.Now().UTC()
+	r.Status = models.ReviewPending
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO review_requests (page_id, requester_id, reviewer_id, comment, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, r.PageID, r.RequesterID, r.ReviewerID, r.Comment, r.Status, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create review request: %w", err)
+	}
+
+	r.ID = id
+	return nil
+}
+
+// GetReviewRequest retrieves a review request by ID.
+func (db *DB) GetReviewRequest(ctx context.Context, id int64) (*models.ReviewRequest, error) {
+	r := &models.ReviewRequest{}
+	err := db.QueryRowContext(ctx, `
+		SELECT r.id, r.page_id, r.requester_id, r.reviewer_id, r.comment, r.status, r.created_at, r.resolved_at,
+		       p.title, p.slug, u.username
+		FROM review_requests r
+		JOIN pages p ON r.page_id = p.id
+		JOIN users u ON r.requester_id = u.id
+		WHERE r.id = ?
+	`, id).Scan(
+		&r.ID, &r.PageID, &r.RequesterID, &r.ReviewerID, &r.Comment, &r.Status, &r.CreatedAt, &r.ResolvedAt,
+		&r.PageTitle, &r.PageSlug, &r.RequesterUsername,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review request: %w", err)
+	}
+	return r, nil
+}
+
+// ListPendingReviewRequests retrieves review requests awaiting a reviewer,
+// filtered by page groups as in ListNotifications.
+func (db *DB) ListPendingReviewRequests(ctx context.Context, reviewerID int64, hideRestricted bool, memberOf []int64) ([]models.ReviewRequest, error) {
+	query := `
+		SELECT r.id, r.page_id, r.requester_id, r.reviewer_id, r.comment, r.status, r.created_at, r.resolved_at,
+		       p.title, p.slug, u.username
+		FROM review_requests r
+		JOIN pages p ON r.page_id = p.id
+		JOIN users u ON r.requester_id = u.id
+		WHERE r.reviewer_id = ? AND r.status = ?
+	`
+	args := []interface{}{reviewerID, models.ReviewPending}
+	if hideRestricted {
+		clause, groupArgs := pageGroupsWhere(memberOf)
+		query += " AND " + clause
+		args = append(args, groupArgs...)
+	}
+	query += " ORDER BY r.created_at DESC"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []models.ReviewRequest
+	for rows.Next() {
+		var r models.ReviewRequest
+		if err := rows.Scan(
+			&r.ID, &r.PageID, &r.RequesterID, &r.ReviewerID, &r.Comment, &r.Status, &r.CreatedAt, &r.ResolvedAt,
+			&r.PageTitle, &r.PageSlug, &r.RequesterUsername,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan review request: %w", err)
+		}
+		requests = append(requests, r)
+	}
+
+	return requests, rows.Err()
+}
+
+// ApproveReviewRequest marks a pending review request as approved.
+func (db *DB) ApproveReviewRequest(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE review_requests SET status = ?, resolved_at = ? WHERE id = ? AND status = ?
+	`, models.ReviewApproved, time.Now().UTC(), id, models.ReviewPending)
+	return err
+}
+
+// IP rule queries
+
+// CreateIPRule inserts a new ban or exemption rule.
+func (db *DB) CreateIPRule(ctx context.Context, rule *models.IPRule) error {
+	rule.CreatedAt = time.Now().UTC()
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO ip_rules (cidr, action, reason, created_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, rule.CIDR, rule.Action, rule.Reason, rule.CreatedBy, rule.ExpiresAt, rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create IP rule: %w", err)
+	}
+
+	rule.ID = id
+	return nil
+}
+
+// ListIPRules retrieves all rules that have not expired.
+func (db *DB) ListIPRules(ctx context.Context) ([]models.IPRule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT r.id, r.cidr, r.action, r.reason, r.created_by, r.expires_at, r.created_at,
+		       COALESCE(u.username, '')
+		FROM ip_rules r
+		LEFT JOIN users u ON r.created_by = u.id
+		WHERE r.expires_at IS NULL OR r.expires_at > ?
+		ORDER BY r.created_at DESC
+	`, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IP rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []models.IPRule
+	for rows.Next() {
+		var r models.IPRule
+		if err := rows.Scan(
+			&r.ID, &r.CIDR, &r.Action, &r.Reason, &r.CreatedBy, &r.ExpiresAt, &r.CreatedAt,
+			&r.CreatorUsername,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan IP rule: %w", err)
+		}
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+// DeleteIPRule removes a rule by ID.
+func (db *DB) DeleteIPRule(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM ip_rules WHERE id = ?", id)
+	return err
+}
+
+// Rate limit queries
+
+// IncrementRateLimit adds one to the counter at key and returns it. A
+// counter that is missing or has reset starts again at one, resetting after
+// window.
+func (db *DB) IncrementRateLimit(ctx context.Context, key string, window time.Duration) (*models.RateLimitCounter, error) {
+	now := time.Now().UTC()
+	c := &models.RateLimitCounter{Key: key}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO rate_limits (key, count, resets_at) VALUES (?, 1, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			count = CASE WHEN rate_limits.resets_at <= ? THEN 1 ELSE rate_limits.count + 1 END,
+			resets_at = CASE WHEN rate_limits.resets_at <= ? THEN excluded.resets_at ELSE rate_limits.resets_at END
+		RETURNING count, resets_at
+	`, key, now.Add(window), now, now).Scan(&c.Count, &c.ResetsAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to increment rate limit: %w", err)
+	}
+	return c, nil
+}
+
+// GetRateLimit returns the counter at key, or nil if there is none or it
+// has reset.
+func (db *DB) GetRateLimit(ctx context.Context, key string) (*models.RateLimitCounter, error) {
+	c := &models.RateLimitCounter{Key: key}
+	err := db.QueryRowContext(ctx,
+		"SELECT count, resets_at FROM rate_limits WHERE key = ? AND resets_at > ?",
+		key, time.Now().UTC()).Scan(&c.Count, &c.ResetsAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate limit: %w", err)
+	}
+	return c, nil
+}
+
+// SetRateLimit replaces the counter at key.
+func (db *DB) SetRateLimit(ctx context.Context, c *models.RateLimitCounter) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, count, resets_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET count = excluded.count, resets_at = excluded.resets_at
+	`, c.Key, c.Count, c.ResetsAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set rate limit: %w", err)
+	}
+	return nil
+}
+
+// DeleteRateLimit removes the counter at key.
+func (db *DB) DeleteRateLimit(ctx context.Context, key string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM rate_limits WHERE key = ?", key)
+	return err
+}
+
+// ListRateLimits returns the counters whose keys start with prefix and
+// haven't reset.
+func (db *DB) ListRateLimits(ctx context.Context, prefix string) ([]models.RateLimitCounter, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT key, count, resets_at FROM rate_limits WHERE key LIKE ? AND resets_at > ?",
+		prefix+"%", time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate limits: %w", err)
+	}
+	defer rows.Close()
+
+	var counters []models.RateLimitCounter
+	for rows.Next() {
+		var c models.RateLimitCounter
+		if err := rows.Scan(&c.Key, &c.Count, &c.ResetsAt); err != nil {
+			return nil, fmt.Errorf("failed to scan rate limit: %w", err)
+		}
+		counters = append(counters, c)
+	}
+	return counters, rows.Err()
+}
+
+// DeleteExpiredRateLimits removes counters that have reset.
+func (db *DB) DeleteExpiredRateLimits(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM rate_limits WHERE resets_at <= ?", time.Now().UTC())
+	return err
+}
+
+// GetShareLinkActivity returns the busiest share links since a point in time.
+func (db *DB) GetShareLinkActivity(ctx context.Context, since time.Time, limit int) ([]models.ShareLinkActivity, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT sl.id,
+		       COALESCE(p.title, CASE sl.target_type WHEN 'tag' THEN 'Tag: ' ELSE 'Search: ' END || sl.target_ref),
+		       COALESCE(p.slug, ''), COUNT(a.id), COUNT(DISTINCT a.ip_address), sl.is_revoked
+		FROM share_link_access a
+		JOIN share_links sl ON a.share_link_id = sl.id
+		LEFT JOIN pages p ON sl.page_id = p.id
+		WHERE a.accessed_at >= ?
+		GROUP BY sl.id, p.id
+		ORDER BY COUNT(a.id) DESC
+		LIMIT ?
+	`, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share link activity: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []models.ShareLinkActivity
+	for rows.Next() {
+		var a models.ShareLinkActivity
+		if err := rows.Scan(&a.LinkID, &a.PageTitle, &a.PageSlug, &a.Accesses, &a.UniqueIPs, &a.IsRevoked); err != nil {
+			return nil, fmt.Errorf("failed to scan share link activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+
+	return activity, rows.Err()
+}
+
+// Cluster coordination queries
+
+// AcquireLock takes or renews the named lock for owner until expiresAt.
+// It returns false when another owner holds an unexpired lease.
+func (db *DB) AcquireLock(ctx context.Context, name, owner string, expiresAt time.Time) (bool, error) {
+	now := time.Now().UTC()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO cluster_locks (name, owner, acquired_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			owner = excluded.owner,
+			acquired_at = CASE WHEN cluster_locks.owner = excluded.owner
+				THEN cluster_locks.acquired_at ELSE excluded.acquired_at END,
+			expires_at = excluded.expires_at
+		WHERE cluster_locks.owner = excluded.owner OR cluster_locks.expires_at < ?
+	`, name, owner, now, expiresAt.UTC(), now)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// ReleaseLock drops the named lock if owner still holds it.
+func (db *DB) ReleaseLock(ctx context.Context, name, owner string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM cluster_locks WHERE name = ? AND owner = ?`, name, owner)
+	if err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+// GetLock retrieves the current holder of a lock, or nil if it is free.
+func (db *DB) GetLock(ctx context.Context, name string) (*models.ClusterLock, error) {
+	var lock models.ClusterLock
+	err := db.QueryRowContext(ctx, `
+		SELECT name, owner, acquired_at, expires_at FROM cluster_locks
+		WHERE name = ? AND expires_at >= ?
+	`, name, time.Now().UTC()).Scan(&lock.Name, &lock.Owner, &lock.AcquiredAt, &lock.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock: %w", err)
+	}
+	return &lock, nil
+}
+
+// PublishClusterEvent records an event for other replicas to pick up.
+func (db *DB) PublishClusterEvent(ctx context.Context, topic, payload, origin string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO cluster_events (topic, payload, origin, created_at) VALUES (?, ?, ?, ?)
+	`, topic, payload, origin, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to publish cluster event: %w", err)
+	}
+	return nil
+}
+
+// ListClusterEventsSince returns events with an ID greater than afterID or
+// created after createdAfter, oldest first. IDs are assigned when an insert
+// starts, so on PostgreSQL an event can commit after one with a higher ID;
+// the time window catches those.
+func (db *DB) ListClusterEventsSince(ctx context.Context, afterID int64, createdAfter time.Time) ([]models.ClusterEvent, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, topic, payload, origin, created_at FROM cluster_events
+		WHERE id > ? OR created_at > ?
+		ORDER BY id
+	`, afterID, createdAfter.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.ClusterEvent
+	for rows.Next() {
+		var e models.ClusterEvent
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Payload, &e.Origin, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cluster event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// LatestClusterEventID returns the highest event ID, or 0 when there are none.
+func (db *DB) LatestClusterEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM cluster_events`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest cluster event: %w", err)
+	}
+	return id, nil
+}
+
+// PruneClusterEvents deletes events created before the cutoff.
+func (db *DB) PruneClusterEvents(ctx context.Context, before time.Time) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM cluster_events WHERE created_at < ?`, before.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to prune cluster events: %w", err)
+	}
+	return nil
+}
+
+// Webhook queries
+
+// CreateWebhook creates a new webhook.
+func (db *DB) CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	hook.CreatedAt = time.Now().UTC()
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO webhooks (url, secret, events, is_active, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, hook.URL, hook.Secret, hook.EventsString(), hook.IsActive, hook.CreatedBy, hook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	hook.ID = id
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+func (db *DB) GetWebhook(ctx context.Context, id int64) (*models.Webhook, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT id, url, secret, events, is_active, created_by, created_at
+		FROM webhooks WHERE id = ?
+	`, id)
+
+	hook, err := scanWebhook(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// ListWebhooks retrieves all webhooks.
+func (db *DB) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, url, secret, events, is_active, created_by, created_at
+		FROM webhooks ORDER BY created_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []models.Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		hooks = append(hooks, *hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+// SetWebhookActive enables or disables a webhook.
+func (db *DB) SetWebhookActive(ctx context.Context, id int64, active bool) error {
+	_, err := db.ExecContext(ctx, `UPDATE webhooks SET is_active = ? WHERE id = ?`, active, id)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (db *DB) DeleteWebhook(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+func scanWebhook(row interface{ Scan(...any) error }) (*models.Webhook, error) {
+	var hook models.Webhook
+	var events string
+	if err := row.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.IsActive, &hook.CreatedBy, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return &hook, nil
+}
+
+// CreateWebhookDelivery queues a delivery for immediate dispatch.
+func (db *DB) CreateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	d.CreatedAt = time.Now().UTC()
+	d.NextAttemptAt = d.CreatedAt
+	d.Status = models.DeliveryPending
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, d.WebhookID, d.Event, d.Payload, d.Status, d.NextAttemptAt, d.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	d.ID = id
+	return nil
+}
+
+// ListDueWebhookDeliveries retrieves pending deliveries whose next attempt is due.
+func (db *DB) ListDueWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, webhook_id, event, payload, status, attempts, response_code, last_error,
+		       next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at
+		LIMIT ?
+	`, models.DeliveryPending, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// ListWebhookDeliveries retrieves the most recent deliveries across all webhooks.
+func (db *DB) ListWebhookDeliveries(ctx context.Context, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, webhook_id, event, payload, status, attempts, response_code, last_error,
+		       next_attempt_at, created_at, delivered_at
+		FROM webhook_deliveries
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	return scanWebhookDeliveries(rows)
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (db *DB) UpdateWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?, delivered_at = ?
+		WHERE id = ?
+	`, d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt.UTC(), d.DeliveredAt, d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// PruneWebhookDeliveries deletes finished deliveries created before the cutoff.
+func (db *DB) PruneWebhookDeliveries(ctx context.Context, before time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM webhook_deliveries WHERE status != ? AND created_at < ?
+	`, models.DeliveryPending, before.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func scanWebhookDeliveries(rows *sql.Rows) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.ResponseCode, &d.LastError,
+			&d.NextAttemptAt, &d.CreatedAt, &d.DeliveredAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CSP report queries
+
+// CreateCSPReport saves a CSP violation report.
+func (db *DB) CreateCSPReport(ctx context.Context, r *models.CSPReport) error {
+	r.CreatedAt = time.Now().UTC()
+
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO csp_reports (document_uri, directive, blocked_uri, source_file, line_number, disposition, user_agent, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.DocumentURI, r.Directive, r.BlockedURI, r.SourceFile, r.LineNumber, r.Disposition, r.UserAgent, r.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create CSP report: %w", err)
+	}
+
+	r.ID = id
+	return nil
+}
+
+// SummarizeCSPReports groups reports since the cutoff by directive and blocked
+// resource, most frequent first.
+func (db *DB) SummarizeCSPReports(ctx context.Context, since time.Time, limit int) ([]models.CSPReportSummary, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT directive, blocked_uri, disposition, COUNT(*), COUNT(DISTINCT document_uri),
+		       MAX(document_uri), MAX(source_file), MAX(created_at)
+		FROM csp_reports
+		WHERE created_at >= ?
+		GROUP BY directive, blocked_uri, disposition
+		ORDER BY COUNT(*) DESC
+		LIMIT ?
+	`, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize CSP reports: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []models.CSPReportSummary
+	for rows.Next() {
+		var s models.CSPReportSummary
+		var lastSeen string
+		if err := rows.Scan(
+			&s.Directive, &s.BlockedURI, &s.Disposition, &s.Count, &s.Documents,
+			&s.SampleDocument, &s.SampleSource, &lastSeen,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan CSP report summary: %w", err)
+		}
+		s.LastSeen = parseSQLiteTime(lastSeen)
+		summaries = append(summaries, s)
+	}
+
+	return summaries, rows.Err()
+}
+
+// ListCSPReports retrieves the most recent CSP reports.
+func (db *DB) ListCSPReports(ctx context.Context, limit int) ([]models.CSPReport, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, document_uri, directive, blocked_uri, source_file, line_number, disposition, user_agent, created_at
+		FROM csp_reports
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CSP reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []models.CSPReport
+	for rows.Next() {
+		var r models.CSPReport
+		if err := rows.Scan(
+			&r.ID, &r.DocumentURI, &r.Directive, &r.BlockedURI, &r.SourceFile, &r.LineNumber,
+			&r.Disposition, &r.UserAgent, &r.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan CSP report: %w", err)
+		}
+		reports = append(reports, r)
+	}
+
+	return reports, rows.Err()
+}
+
+// CountCSPReports counts reports received since the cutoff.
+func (db *DB) CountCSPReports(ctx context.Context, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM csp_reports WHERE created_at >= ?`, since.UTC()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count CSP reports: %w", err)
+	}
+	return count, nil
+}
+
+// PruneCSPReports deletes reports received before the cutoff. A zero cutoff deletes all reports.
+func (db *DB) PruneCSPReports(ctx context.Context, before time.Time) error {
+	var err error
+	if before.IsZero() {
+		_, err = db.ExecContext(ctx, `DELETE FROM csp_reports`)
+	} else {
+		_, err = db.ExecContext(ctx, `DELETE FROM csp_reports WHERE created_at < ?`, before.UTC())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to prune CSP reports: %w", err)
+	}
+	return nil
+}
+
+// parseSQLiteTime parses a timestamp returned by an aggregate such as MAX(),
+// which the driver hands back as text rather than time.Time. PostgreSQL's
+// come back as times, which scan into strings as RFC 3339.
+func parseSQLiteTime(value string) time.Time {
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC()
+	}
+	return time.Time{}
+}
+
+// Privacy queries
+
+// SearchUsers finds users whose username or email contains the query.
+func (db *DB) SearchUsers(ctx context.Context, query string, limit int) ([]models.User, error) {
+	pattern := "%" + query + "%"
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, username, email, password_hash, role, is_active, created_at, updated_at, last_login_at, email_verified_at,
+		       must_change_password, locked_at, lock_reason, timezone, locale, theme, language, directory_dn
+		FROM users
+		WHERE username LIKE ? OR email LIKE ?
+		ORDER BY username ASC
+		LIMIT ?
+	`, pattern, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(
+			&u.ID, &u.Username, &u.Email, &u.PasswordHash,
+			&u.Role, &u.IsActive, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt, &u.EmailVerifiedAt,
+			&u.MustChangePassword, &u.LockedAt, &u.LockReason, &u.Timezone, &u.Locale, &u.Theme, &u.Language, &u.DirectoryDN,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// ListPagesByAuthor lists pages created by a user.
+func (db *DB) ListPagesByAuthor(ctx context.Context, userID int64) ([]models.PersonalPage, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, slug, title, is_published, created_at, updated_at
+		FROM pages
+		WHERE author_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages by author: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.PersonalPage
+	for rows.Next() {
+		var p models.PersonalPage
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.IsPublished, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, rows.Err()
+}
+
+// ListRevisionsByAuthor lists edits made by a user.
+func (db *DB) ListRevisionsByAuthor(ctx context.Context, userID int64) ([]models.PersonalRevision, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT r.id, r.page_id, p.slug, r.comment, r.created_at
+		FROM revisions r
+		JOIN pages p ON r.page_id = p.id
+		WHERE r.author_id = ?
+		ORDER BY r.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions by author: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []models.PersonalRevision
+	for rows.Next() {
+		var r models.PersonalRevision
+		if err := rows.Scan(&r.ID, &r.PageID, &r.PageSlug, &r.Comment, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// ListAuditEntriesByUser lists audit log entries recorded for a user.
+func (db *DB) ListAuditEntriesByUser(ctx context.Context, userID int64) ([]models.PersonalAuditEntry, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, action, entity_type, entity_id, COALESCE(details, ''), COALESCE(ip_address, ''), created_at
+		FROM audit_log
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.PersonalAuditEntry
+	for rows.Next() {
+		var e models.PersonalAuditEntry
+		if err := rows.Scan(&e.ID, &e.Action, &e.EntityType, &e.EntityID, &e.Details, &e.IPAddress, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// ListShareAccessByIPs lists share link visits from any of the given addresses.
+func (db *DB) ListShareAccessByIPs(ctx context.Context, ips []string) ([]models.PersonalShareAccess, error) {
+	if len(ips) == 0 {
+		return nil, nil
+	}
+
+	args := make([]interface{}, len(ips))
+	for i, ip := range ips {
+		args[i] = ip
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT share_link_id, ip_address, COALESCE(user_agent, ''), accessed_at
+		FROM share_link_access
+		WHERE ip_address IN (?`+strings.Repeat(", ?", len(ips)-1)+`)
+		ORDER BY accessed_at DESC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list share accesses: %w", err)
+	}
+	defer rows.Close()
+
+	var accesses []models.PersonalShareAccess
+	for rows.Next() {
+		var a models.PersonalShareAccess
+		if err := rows.Scan(&a.ShareLinkID, &a.IPAddress, &a.UserAgent, &a.AccessedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan share access: %w", err)
+		}
+		accesses = append(accesses, a)
+	}
+
+	return accesses, rows.Err()
+}
+
+// ipColumns lists every column holding a client IP address, with the
+// timestamp used to decide when the address falls out of retention.
+var ipColumns = []struct {
+	table, column, timestamp string
+}{
+	{"audit_log", "ip_address", "created_at"},
+	{"share_link_access", "ip_address", "accessed_at"},
+	{"api_calls", "ip_address", "created_at"},
+}
+
+// AnonymizeIPsBefore rewrites IP addresses recorded before the cutoff with
+// anonymize, and returns how many rows changed. Addresses that are already
+// anonymized, ending in ".0" or "::", are skipped.
+func (db *DB) AnonymizeIPsBefore(ctx context.Context, before time.Time, anonymize func(string) string) (int64, error) {
+	var total int64
+	for _, col := range ipColumns {
+		rows, err := db.QueryContext(ctx, `
+			SELECT DISTINCT `+col.column+` FROM `+col.table+`
+			WHERE `+col.timestamp+` < ? AND `+col.column+` != ''
+			  AND `+col.column+` NOT LIKE '%.0' AND `+col.column+` NOT LIKE '%::'
+		`, before.UTC())
+		if err != nil {
+			return total, fmt.Errorf("failed to list IP addresses in %s: %w", col.table, err)
+		}
+		var ips []string
+		for rows.Next() {
+			var ip string
+			if err := rows.Scan(&ip); err != nil {
+				rows.Close()
+				return total, fmt.Errorf("failed to scan IP address: %w", err)
+			}
+			ips = append(ips, ip)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return total, fmt.Errorf("failed to list IP addresses in %s: %w", col.table, err)
+		}
+
+		for _, ip := range ips {
+			result, err := db.ExecContext(ctx, `
+				UPDATE `+col.table+` SET `+col.column+` = ?
+				WHERE `+col.column+` = ? AND `+col.timestamp+` < ?
+			`, anonymize(ip), ip, before.UTC())
+			if err != nil {
+				return total, fmt.Errorf("failed to anonymize IP addresses in %s: %w", col.table, err)
+			}
+			n, _ := result.RowsAffected()
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// AnonymizeIPs rewrites the given addresses everywhere they were recorded,
+// and returns how many rows changed.
+func (db *DB) AnonymizeIPs(ctx context.Context, ips []string, anonymize func(string) string) (int64, error) {
+	var total int64
+	for _, col := range ipColumns {
+		for _, ip := range ips {
+			result, err := db.ExecContext(ctx, `
+				UPDATE `+col.table+` SET `+col.column+` = ? WHERE `+col.column+` = ?
+			`, anonymize(ip), ip)
+			if err != nil {
+				return total, fmt.Errorf("failed to anonymize IP addresses in %s: %w", col.table, err)
+			}
+			n, _ := result.RowsAffected()
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// Link graph queries
+
+// SetPageLinks replaces the outgoing internal links recorded for a page.
+func (db *DB) SetPageLinks(ctx context.Context, pageID int64, links []models.PageLink) error {
+	return db.Transaction(ctx, func(tx *Tx) error {
+		return setPageLinksTx(ctx, tx, pageID, links)
+	})
+}
+
+func setPageLinksTx(ctx context.Context, tx *Tx, pageID int64, links []models.PageLink) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM page_links WHERE source_page_id = ?", pageID); err != nil {
+		return fmt.Errorf("failed to clear page links: %w", err)
+	}
+	for _, l := range links {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO page_links (source_page_id, target_slug, kind) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+			pageID, l.TargetSlug, l.Kind)
+		if err != nil {
+			return fmt.Errorf("failed to save page link: %w", err)
+		}
+	}
+	return nil
+}
+
+// RebuildPageLinks re-extracts the links of every page with extract.
+func (db *DB) RebuildPageLinks(ctx context.Context, extract func(slug, content string) []models.PageLink) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, slug, content FROM pages")
+	if err != nil {
+		return fmt.Errorf("failed to list pages: %w", err)
+	}
+	type page struct{ slug, content string }
+	pages := make(map[int64]page)
+	for rows.Next() {
+		var id int64
+		var p page
+		if err := rows.Scan(&id, &p.slug, &p.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	return db.Transaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM page_links"); err != nil {
+			return fmt.Errorf("failed to clear page links: %w", err)
+		}
+		for id, p := range pages {
+			if err := setPageLinksTx(ctx, tx, id, extract(p.slug, p.content)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RerenderPages re-renders the stored HTML, excerpt and word count of every
+// page with render, excerpt and words, returning how many pages changed.
+// Updated timestamps are left alone.
+func (db *DB) RerenderPages(ctx context.Context, render func(slug, content string) (string, error), excerpt func(content string) string, words func(content string) int) (int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, slug, content, content_html, excerpt, word_count FROM pages")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list pages: %w", err)
+	}
+	type rendered struct {
+		html, excerpt string
+		words         int
+	}
+	changed := make(map[int64]rendered)
+	for rows.Next() {
+		var id int64
+		var slug, content, oldHTML, oldExcerpt string
+		var oldWords int
+		if err := rows.Scan(&id, &slug, &content, &oldHTML, &oldExcerpt, &oldWords); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan page: %w", err)
+		}
+		html, err := render(slug, content)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to render page %d: %w", id, err)
+		}
+		r := rendered{html: html, excerpt: excerpt(content), words: words(content)}
+		if r.html != oldHTML || r.excerpt != oldExcerpt || r.words != oldWords {
+			changed[id] = r
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	err = db.Transaction(ctx, func(tx *Tx) error {
+		for id, r := range changed {
+			if _, err := tx.ExecContext(ctx, "UPDATE pages SET content_html = ?, excerpt = ?, word_count = ? WHERE id = ?", r.html, r.excerpt, r.words, id); err != nil {
+				return fmt.Errorf("failed to update page html: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// ListLinkEdges returns every link between two existing pages, leaving out
+// links from a page to itself.
+func (db *DB) ListLinkEdges(ctx context.Context) ([]models.LinkEdge, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT l.source_page_id, t.id
+		FROM page_links l
+		JOIN pages t ON t.slug = l.target_slug
+		WHERE t.id != l.source_page_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	defer rows.Close()
+
+	var edges []models.LinkEdge
+	for rows.Next() {
+		var e models.LinkEdge
+		if err := rows.Scan(&e.SourceID, &e.TargetID); err != nil {
+			return nil, fmt.Errorf("failed to scan link: %w", err)
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// ListBrokenLinks returns every internal link whose target page does not
+// exist, ordered by target. With hideRestricted, links from pages
+// restricted to groups are left out unless one of their groups is in
+// memberOf.
+func (db *DB) ListBrokenLinks(ctx context.Context, hideRestricted bool, memberOf []int64) ([]models.BrokenLink, error) {
+	query := `
+		SELECT p.id, p.slug, p.title, l.target_slug, l.kind
+		FROM page_links l
+		JOIN pages p ON p.id = l.source_page_id
+		LEFT JOIN pages t ON t.slug = l.target_slug
+		WHERE t.id IS NULL
+	`
+	var args []interface{}
+	if hideRestricted {
+		clause, groupArgs := pageGroupsWhere(memberOf)
+		query += " AND " + clause
+		args = append(args, groupArgs...)
+	}
+	query += " ORDER BY l.target_slug, p.slug"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list broken links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []models.BrokenLink
+	for rows.Next() {
+		var l models.BrokenLink
+		if err := rows.Scan(&l.SourceID, &l.SourceSlug, &l.SourceTitle, &l.TargetSlug, &l.Kind); err != nil {
+			return nil, fmt.Errorf("failed to scan broken link: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// ListMissingLinkTargets returns the slugs a page links to that don't exist.
+func (db *DB) ListMissingLinkTargets(ctx context.Context, pageID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.target_slug
+		FROM page_links l
+		LEFT JOIN pages t ON t.slug = l.target_slug
+		WHERE l.source_page_id = ? AND t.id IS NULL
+	`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list missing link targets: %w", err)
+	}
+	defer rows.Close()
+
+	var slugs []string
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, fmt.Errorf("failed to scan link target: %w", err)
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs, rows.Err()
+}
+
+// ListBacklinks returns the pages linking to slug, ordered by title. Unless
+// includeUnpublished is set, only published pages are returned. Pages
+// restricted to groups are never listed.
+func (db *DB) ListBacklinks(ctx context.Context, slug string, includeUnpublished bool) ([]models.PageSummary, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username
+		FROM page_links l
+		JOIN pages p ON p.id = l.source_page_id
+		JOIN users u ON p.author_id = u.id
+		WHERE l.target_slug = ? AND p.slug != ? AND (? = 1 OR p.is_published = 1)
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		ORDER BY p.title ASC
+	`, slug, slug, includeUnpublished)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backlinks: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.PageSummary
+	for rows.Next() {
+		var p models.PageSummary
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// ListOrphanPages returns the unarchived pages with no parent, no tags and
+// no links from other pages, ordered by title. Pages restricted to groups
+// are left out unless includeRestricted.
+func (db *DB) ListOrphanPages(ctx context.Context, includeRestricted bool) ([]models.PageSummary, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username
+		FROM pages p
+		JOIN users u ON p.author_id = u.id
+		WHERE p.parent_id IS NULL AND p.archived_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM page_tags pt WHERE pt.page_id = p.id)
+		AND NOT EXISTS (
+			SELECT 1 FROM page_links l
+			WHERE l.target_slug = p.slug AND l.source_page_id != p.id
+		)
+		AND (? = 1 OR NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id))
+		ORDER BY p.title ASC
+	`, includeRestricted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphan pages: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.PageSummary
+	for rows.Next() {
+		var p models.PageSummary
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// Announcement dismissal queries
+
+// DismissAnnouncement records that a user dismissed an announcement.
+func (db *DB) DismissAnnouncement(ctx context.Context, userID int64, announcementID string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO announcement_dismissals (user_id, announcement_id, dismissed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`, userID, announcementID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to dismiss announcement: %w", err)
+	}
+	return nil
+}
+
+// ListDismissedAnnouncements returns the IDs of announcements a user dismissed.
+func (db *DB) ListDismissedAnnouncements(ctx context.Context, userID int64) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT announcement_id FROM announcement_dismissals WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dismissed announcements: %w", err)
+	}
+	defer rows.Close()
+
+	dismissed := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan dismissed announcement: %w", err)
+		}
+		dismissed[id] = true
+	}
+	return dismissed, rows.Err()
+}
+
+// DeleteAnnouncementDismissals forgets every dismissal of an announcement.
+func (db *DB) DeleteAnnouncementDismissals(ctx context.Context, announcementID string) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM announcement_dismissals WHERE announcement_id = ?", announcementID); err != nil {
+		return fmt.Errorf("failed to delete announcement dismissals: %w", err)
+	}
+	return nil
+}
+
+// API usage queries
+
+// RecordAPICalls adds calls to the hourly usage counters and the recent call
+// log, keeping only the latest keepCalls calls per token.
+func (db *DB) RecordAPICalls(ctx context.Context, calls []models.APICall, keepCalls int) error {
+	return db.Transaction(ctx, func(tx *Tx) error {
+		tokens := make(map[int64]bool)
+		for _, call := range calls {
+			failed := 0
+			if call.IsError() {
+				failed = 1
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO api_usage (token_id, hour, requests, errors) VALUES (?, ?, 1, ?)
+				ON CONFLICT(token_id, hour) DO UPDATE SET
+					requests = api_usage.requests + 1,
+					errors = api_usage.errors + excluded.errors
+			`, call.TokenID, call.CreatedAt.UTC().Truncate(time.Hour), failed)
+			if err != nil {
+				return fmt.Errorf("failed to record API usage: %w", err)
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO api_calls (token_id, method, path, status, ip_address, created_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`, call.TokenID, call.Method, call.Path, call.Status, call.IPAddress, call.CreatedAt.UTC())
+			if err != nil {
+				return fmt.Errorf("failed to record API call: %w", err)
+			}
+			tokens[call.TokenID] = true
+		}
+
+		for tokenID := range tokens {
+			_, err := tx.ExecContext(ctx, `
+				DELETE FROM api_calls WHERE token_id = ? AND id NOT IN (
+					SELECT id FROM api_calls WHERE token_id = ? ORDER BY id DESC LIMIT ?
+				)
+			`, tokenID, tokenID, keepCalls)
+			if err != nil {
+				return fmt.Errorf("failed to trim API calls: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteAPIUsageBefore removes hourly usage counters older than the cutoff.
+func (db *DB) DeleteAPIUsageBefore(ctx context.Context, before time.Time) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM api_usage WHERE hour < ?", before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune API usage: %w", err)
+	}
+	return nil
+}
+
+// ListAPIUsage summarizes request counts per token over the last day and
+// week. A nil userID lists every user's tokens.
+func (db *DB) ListAPIUsage(ctx context.Context, userID *int64, now time.Time) ([]models.APITokenUsage, error) {
+	day := now.UTC().Add(-24 * time.Hour)
+	week := now.UTC().Add(-7 * 24 * time.Hour)
+
+	query := `
+		SELECT t.id, t.name, u.username, t.last_used_at,
+			COALESCE(SUM(CASE WHEN a.hour >= ? THEN a.requests END), 0),
+			COALESCE(SUM(CASE WHEN a.hour >= ? THEN a.errors END), 0),
+			COALESCE(SUM(a.requests), 0),
+			COALESCE(SUM(a.errors), 0),
+			(SELECT COUNT(DISTINCT c.ip_address) FROM api_calls c WHERE c.token_id = t.id AND c.created_at >= ?)
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		LEFT JOIN api_usage a ON a.token_id = t.id AND a.hour >= ?
+	`
+	args := []interface{}{day.Truncate(time.Hour), day.Truncate(time.Hour), day, week.Truncate(time.Hour)}
+	if userID != nil {
+		query += " WHERE t.user_id = ?"
+		args = append(args, *userID)
+	}
+	query += " GROUP BY t.id, u.username ORDER BY COALESCE(SUM(a.requests), 0) DESC, t.created_at DESC"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API usage: %w", err)
+	}
+	defer rows.Close()
+
+	var usage []models.APITokenUsage
+	for rows.Next() {
+		var u models.APITokenUsage
+		if err := rows.Scan(&u.TokenID, &u.TokenName, &u.Username, &u.LastUsedAt,
+			&u.Requests24h, &u.Errors24h, &u.Requests7d, &u.Errors7d, &u.DistinctIPs); err != nil {
+			return nil, fmt.Errorf("failed to scan API usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// ListAPICalls returns a token's most recent calls.
+func (db *DB) ListAPICalls(ctx context.Context, tokenID int64, limit int) ([]models.APICall, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT token_id, method, path, status, ip_address, created_at
+		FROM api_calls
+		WHERE token_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, tokenID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API calls: %w", err)
+	}
+	defer rows.Close()
+
+	var calls []models.APICall
+	for rows.Next() {
+		var call models.APICall
+		if err := rows.Scan(&call.TokenID, &call.Method, &call.Path, &call.Status, &call.IPAddress, &call.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API call: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
+
+// Email template queries
+
+// GetEmailTemplate returns a stored email template, or nil if the template
+// was never customized for that locale.
+func (db *DB) GetEmailTemplate(ctx context.Context, key, locale string) (*models.EmailTemplate, error) {
+	t := &models.EmailTemplate{Customized: true}
+	err := db.QueryRowContext(ctx, `
+		SELECT key, locale, subject, body_text, body_html, updated_by, updated_at
+		FROM email_templates
+		WHERE key = ? AND locale = ?
+	`, key, locale).Scan(&t.Key, &t.Locale, &t.Subject, &t.BodyText, &t.BodyHTML, &t.UpdatedBy, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email template: %w", err)
+	}
+	return t, nil
+}
+
+// ListEmailTemplates returns every stored email template.
+func (db *DB) ListEmailTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT key, locale, subject, body_text, body_html, updated_by, updated_at
+		FROM email_templates
+		ORDER BY key, locale
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.EmailTemplate
+	for rows.Next() {
+		t := models.EmailTemplate{Customized: true}
+		if err := rows.Scan(&t.Key, &t.Locale, &t.Subject, &t.BodyText, &t.BodyHTML, &t.UpdatedBy, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan email template: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// SaveEmailTemplate creates or replaces an email template.
+func (db *DB) SaveEmailTemplate(ctx context.Context, t *models.EmailTemplate) error {
+	t.UpdatedAt = time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO email_templates (key, locale, subject, body_text, body_html, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key, locale) DO UPDATE SET
+			subject = excluded.subject,
+			body_text = excluded.body_text,
+			body_html = excluded.body_html,
+			updated_by = excluded.updated_by,
+			updated_at = excluded.updated_at
+	`, t.Key, t.Locale, t.Subject, t.BodyText, t.BodyHTML, t.UpdatedBy, t.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save email template: %w", err)
+	}
+	t.Customized = true
+	return nil
+}
+
+// DeleteEmailTemplate removes a stored email template.
+func (db *DB) DeleteEmailTemplate(ctx context.Context, key, locale string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM email_templates WHERE key = ? AND locale = ?", key, locale)
+	if err != nil {
+		return fmt.Errorf("failed to delete email template: %w", err)
+	}
+	return nil
+}
+
+// User invite queries
+
+// CreateUserInvite stores an invitation for a user.
+func (db *DB) CreateUserInvite(ctx context.Context, tokenHash string, userID int64, invitedBy *int64, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO user_invites (token_hash, user_id, invited_by, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tokenHash, userID, invitedBy, expiresAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	return nil
+}
+
+// GetUserInvite retrieves an invitation by token hash.
+func (db *DB) GetUserInvite(ctx context.Context, tokenHash string) (*models.UserInvite, error) {
+	invite := &models.UserInvite{}
+	err := db.QueryRowContext(ctx, `
+		SELECT i.user_id, u.username, u.email, i.invited_by, i.expires_at, i.created_at
+		FROM user_invites i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.token_hash = ?
+	`, tokenHash).Scan(&invite.UserID, &invite.Username, &invite.Email, &invite.InvitedBy, &invite.ExpiresAt, &invite.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	return invite, nil
+}
+
+// DeleteUserInvites removes all invitations for a user.
+func (db *DB) DeleteUserInvites(ctx context.Context, userID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM user_invites WHERE user_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete invites: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredUserInvites removes invitations past their expiry.
+func (db *DB) DeleteExpiredUserInvites(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM user_invites WHERE expires_at < ?", time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to delete expired invites: %w", err)
+	}
+	return nil
+}
+
+// Revision retention queries
+
+// PruneRevisions deletes the revisions of a page, or of every page when
+// pageID is 0, that the retention policy no longer covers: those beyond the
+// newest maxCount and those created before cutoff. A zero maxCount or cutoff
+// disables that rule. The newest keep revisions of each page are never
+// deleted. It returns how many revisions were deleted per page.
+func (db *DB) PruneRevisions(ctx context.Context, pageID int64, keep, maxCount int, cutoff time.Time) (map[int64]int64, error) {
+	var rules []string
+	args := []interface{}{}
+	filter := ""
+	if pageID != 0 {
+		filter = "WHERE page_id = ?"
+		args = append(args, pageID)
+	}
+	args = append(args, keep)
+	if maxCount > 0 {
+		rules = append(rules, "rn > ?")
+		args = append(args, maxCount)
+	}
+	if !cutoff.IsZero() {
+		rules = append(rules, "created_at < ?")
+		args = append(args, cutoff.UTC())
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	pruned := make(map[int64]int64)
+	err := db.Transaction(ctx, func(tx *Tx) error {
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id, page_id FROM (
+				SELECT id, page_id, created_at,
+				       ROW_NUMBER() OVER (PARTITION BY page_id ORDER BY created_at DESC, id DESC) AS rn
+				FROM revisions `+filter+`
+			)
+			WHERE rn > ? AND (`+strings.Join(rules, " OR ")+`)
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("failed to find prunable revisions: %w", err)
+		}
+		var ids []int64
+		for rows.Next() {
+			var id, page int64
+			if err := rows.Scan(&id, &page); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan revision: %w", err)
+			}
+			ids = append(ids, id)
+			pruned[page]++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to find prunable revisions: %w", err)
+		}
+
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, "DELETE FROM revisions WHERE id = ?", id); err != nil {
+				return fmt.Errorf("failed to delete revision: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pruned, nil
+}
+
+// CountRevisions returns the number of stored revisions of a page, or of
+// every page when pageID is 0.
+func (db *DB) CountRevisions(ctx context.Context, pageID int64) (int64, error) {
+	var count int64
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM revisions WHERE ? = 0 OR page_id = ?", pageID, pageID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count revisions: %w", err)
+	}
+	return count, nil
+}
+
+// ListLargestHistories returns the pages with the most revisions.
+func (db *DB) ListLargestHistories(ctx context.Context, limit int) ([]models.PageRevisionCount, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, COUNT(r.id), MIN(r.created_at)
+		FROM revisions r
+		JOIN pages p ON p.id = r.page_id
+		GROUP BY p.id
+		ORDER BY COUNT(r.id) DESC, p.title
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision counts: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []models.PageRevisionCount
+	for rows.Next() {
+		var c models.PageRevisionCount
+		var oldest string
+		if err := rows.Scan(&c.PageID, &c.Slug, &c.Title, &c.Revisions, &oldest); err != nil {
+			return nil, fmt.Errorf("failed to scan revision count: %w", err)
+		}
+		c.Oldest = parseSQLiteTime(oldest)
+		counts = append(counts, c)
+	}
+
+	return counts, rows.Err()
+}
+
+// Page view queries
+
+// RecordPageViews adds buffered view counts to each page's total and to
+// its count for the day.
+func (db *DB) RecordPageViews(ctx context.Context, views map[int64]int64, at time.Time) error {
+	day := at.UTC().Truncate(24 * time.Hour)
+	return db.Transaction(ctx, func(tx *Tx) error {
+		// PostgreSQL cannot tell the types of bare parameters in a SELECT
+		values := "?, ?"
+		if db.driver == DriverPostgres {
+			values = "CAST(? AS BIGINT), CAST(? AS TIMESTAMPTZ)"
+		}
+		for pageID, n := range views {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO page_views (page_id, views, last_viewed_at)
+				SELECT id, `+values+` FROM pages WHERE id = ?
+				ON CONFLICT(page_id) DO UPDATE SET
+					views = page_views.views + excluded.views,
+					last_viewed_at = excluded.last_viewed_at
+			`, n, at.UTC(), pageID)
+			if err != nil {
+				return fmt.Errorf("failed to record page views: %w", err)
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO page_view_days (page_id, views, day)
+				SELECT id, `+values+` FROM pages WHERE id = ?
+				ON CONFLICT(page_id, day) DO UPDATE SET
+					views = page_view_days.views + excluded.views
+			`, n, day, pageID)
+			if err != nil {
+				return fmt.Errorf("failed to record daily page views: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeletePageViewDaysBefore removes daily view counters older than the
+// cutoff. All-time totals are kept.
+func (db *DB) DeletePageViewDaysBefore(ctx context.Context, before time.Time) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM page_view_days WHERE day < ?", before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune daily page views: %w", err)
+	}
+	return nil
+}
+
+// analyticsScope limits analytics queries on pages p to one author's
+// pages, or to every page for a nil authorID. Archived pages are left out.
+func analyticsScope(authorID *int64) (string, []interface{}) {
+	if authorID == nil {
+		return "p.archived_at IS NULL", nil
+	}
+	return "p.archived_at IS NULL AND p.author_id = ?", []interface{}{*authorID}
+}
+
+// ListPageViewDays totals page views per day since the given day, oldest
+// first. Days without views are left out.
+func (db *DB) ListPageViewDays(ctx context.Context, since time.Time, authorID *int64) ([]models.PageViewDay, error) {
+	scope, args := analyticsScope(authorID)
+	args = append([]interface{}{since.UTC()}, args...)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT d.day, SUM(d.views)
+		FROM page_view_days d
+		JOIN pages p ON p.id = d.page_id
+		WHERE d.day >= ? AND `+scope+`
+		GROUP BY d.day
+		ORDER BY d.day
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list daily page views: %w", err)
+	}
+	defer rows.Close()
+
+	var days []models.PageViewDay
+	for rows.Next() {
+		var d models.PageViewDay
+		if err := rows.Scan(&d.Day, &d.Views); err != nil {
+			return nil, fmt.Errorf("failed to scan daily page views: %w", err)
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// ListMostViewedPages returns the pages viewed most since the given day,
+// most viewed first.
+func (db *DB) ListMostViewedPages(ctx context.Context, since time.Time, authorID *int64, limit int) ([]models.PageViewStat, error) {
+	scope, args := analyticsScope(authorID)
+	args = append([]interface{}{since.UTC()}, args...)
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, u.username, SUM(d.views), COALESCE(v.views, 0), v.last_viewed_at
+		FROM page_view_days d
+		JOIN pages p ON p.id = d.page_id
+		JOIN users u ON u.id = p.author_id
+		LEFT JOIN page_views v ON v.page_id = p.id
+		WHERE d.day >= ? AND `+scope+`
+		GROUP BY p.id, p.slug, p.title, u.username, v.views, v.last_viewed_at
+		ORDER BY SUM(d.views) DESC, p.slug
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list most viewed pages: %w", err)
+	}
+	defer rows.Close()
+	return scanPageViewStats(rows)
+}
+
+// ListUnviewedPages returns published pages nobody viewed since the given
+// day, the longest unviewed first.
+func (db *DB) ListUnviewedPages(ctx context.Context, since time.Time, authorID *int64, limit int) ([]models.PageViewStat, error) {
+	scope, args := analyticsScope(authorID)
+	args = append([]interface{}{since.UTC()}, args...)
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, u.username, 0, COALESCE(v.views, 0), v.last_viewed_at
+		FROM pages p
+		JOIN users u ON u.id = p.author_id
+		LEFT JOIN page_views v ON v.page_id = p.id
+		WHERE p.is_published = 1
+		  AND NOT EXISTS (SELECT 1 FROM page_view_days d WHERE d.page_id = p.id AND d.day >= ?)
+		  AND `+scope+`
+		ORDER BY v.last_viewed_at IS NOT NULL, v.last_viewed_at, p.slug
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list unviewed pages: %w", err)
+	}
+	defer rows.Close()
+	return scanPageViewStats(rows)
+}
+
+func scanPageViewStats(rows *sql.Rows) ([]models.PageViewStat, error) {
+	var stats []models.PageViewStat
+	for rows.Next() {
+		var s models.PageViewStat
+		var lastViewed sql.NullTime
+		if err := rows.Scan(&s.PageID, &s.Slug, &s.Title, &s.Author, &s.Views, &s.TotalViews, &lastViewed); err != nil {
+			return nil, fmt.Errorf("failed to scan page views: %w", err)
+		}
+		if lastViewed.Valid {
+			s.LastViewedAt = &lastViewed.Time
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// RecordUserPageView notes that a user viewed a page, keeping only the
+// keep pages they viewed most recently.
+func (db *DB) RecordUserPageView(ctx context.Context, userID, pageID int64, at time.Time, keep int) error {
+	return db.Transaction(ctx, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_page_views (user_id, page_id, viewed_at) VALUES (?, ?, ?)
+			ON CONFLICT(user_id, page_id) DO UPDATE SET viewed_at = excluded.viewed_at
+		`, userID, pageID, at.UTC())
+		if err != nil {
+			return fmt.Errorf("failed to record page view: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM user_page_views
+			WHERE user_id = ? AND page_id NOT IN (
+				SELECT page_id FROM user_page_views
+				WHERE user_id = ?
+				ORDER BY viewed_at DESC
+				LIMIT ?
+			)
+		`, userID, userID, keep)
+		if err != nil {
+			return fmt.Errorf("failed to trim page views: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListRecentlyViewed returns the pages a user viewed most recently, newest
+// first. Only filter's visibility fields and limit apply.
+func (db *DB) ListRecentlyViewed(ctx context.Context, userID int64, filter models.PageFilter) ([]models.PageSummary, error) {
+	whereSQL, args := pageFilterWhere(filter)
+	args = append([]interface{}{userID}, args...)
+	args = append(args, filter.Limit)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, p.excerpt, p.word_count, p.parent_id, p.updated_at, u.username
+		FROM pages p
+		JOIN user_page_views v ON v.page_id = p.id AND v.user_id = ?
+		JOIN users u ON p.author_id = u.id
+		`+whereSQL+`
+		ORDER BY v.viewed_at DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recently viewed pages: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.PageSummary
+	for rows.Next() {
+		var p models.PageSummary
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Excerpt, &p.WordCount, &p.ParentID, &p.UpdatedAt, &p.Author); err != nil {
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, rows.Err()
+}
+
+// pageActivityQuery selects edit and view activity for published pages.
+// Edits are counted from the revision history since the given time.
+const pageActivityQuery = `
+	SELECT p.id, p.slug, p.updated_at, p.archived_at IS NOT NULL,
+	       (SELECT COUNT(*) FROM revisions r WHERE r.page_id = p.id AND r.created_at >= ?),
+	       COALESCE(v.views, 0)
+	FROM pages p
+	LEFT JOIN page_views v ON v.page_id = p.id
+	WHERE p.is_published = 1
+`
+
+// GetPageActivity returns a published page's edit and view activity.
+func (db *DB) GetPageActivity(ctx context.Context, pageID int64, since time.Time) (*models.PageActivity, error) {
+	var a models.PageActivity
+	err := db.QueryRowContext(ctx, pageActivityQuery+" AND p.id = ?", since.UTC(), pageID).Scan(
+		&a.PageID, &a.Slug, &a.UpdatedAt, &a.Archived, &a.RecentEdits, &a.Views,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page activity: %w", err)
+	}
+	return &a, nil
+}
+
+// ListPageActivity returns the edit and view activity of every published
+// page that isn't restricted to groups, ordered by slug.
+func (db *DB) ListPageActivity(ctx context.Context, since time.Time) ([]models.PageActivity, error) {
+	rows, err := db.QueryContext(ctx, pageActivityQuery+`
+		AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		ORDER BY p.slug`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page activity: %w", err)
+	}
+	defer rows.Close()
+
+	var activity []models.PageActivity
+	for rows.Next() {
+		var a models.PageActivity
+		if err := rows.Scan(&a.PageID, &a.Slug, &a.UpdatedAt, &a.Archived, &a.RecentEdits, &a.Views); err != nil {
+			return nil, fmt.Errorf("failed to scan page activity: %w", err)
+		}
+		activity = append(activity, a)
+	}
+
+	return activity, rows.Err()
+}
+
+// User contribution queries
+
+// GetUserContributions counts a user's edits and the pages they created
+// and edited. With publishedOnly, unpublished pages are left out.
+func (db *DB) GetUserContributions(ctx context.Context, userID int64, publishedOnly bool) (*models.UserContributions, error) {
+	published := ""
+	if publishedOnly {
+		published = " AND p.is_published = 1"
+	}
+
+	stats := &models.UserContributions{}
+	var first, last sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT r.page_id), MIN(r.created_at), MAX(r.created_at)
+		FROM revisions r
+		JOIN pages p ON r.page_id = p.id
+		WHERE r.author_id = ?`+published, userID).Scan(&stats.Edits, &stats.PagesEdited, &first, &last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user edits: %w", err)
+	}
+	if first.Valid {
+		stats.FirstEdit = parseSQLiteTime(first.String)
+	}
+	if last.Valid {
+		stats.LastEdit = parseSQLiteTime(last.String)
+	}
+
+	err = db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM pages p WHERE p.author_id = ?`+published, userID).Scan(&stats.PagesCreated)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user pages: %w", err)
+	}
+
+	return stats, nil
+}
+
+// Role queries
+
+// ListRoles returns every role with the number of users assigned to it,
+// built-in roles first.
+func (db *DB) ListRoles(ctx context.Context) ([]models.RoleDefinition, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT r.name, r.description, r.permissions, r.built_in, r.created_at,
+			(SELECT COUNT(*) FROM users u WHERE u.role = r.name)
+		FROM roles r
+		ORDER BY r.built_in DESC, r.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []models.RoleDefinition
+	for rows.Next() {
+		var r models.RoleDefinition
+		var perms string
+		if err := rows.Scan(&r.Name, &r.Description, &perms, &r.BuiltIn, &r.CreatedAt, &r.UserCount); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		r.Permissions = models.ParsePermissions(perms)
+		roles = append(roles, r)
+	}
+
+	return roles, rows.Err()
+}
+
+// CreateRole adds a custom role.
+func (db *DB) CreateRole(ctx context.Context, role *models.RoleDefinition) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO roles (name, description, permissions, built_in, created_at)
+		VALUES (?, ?, ?, 0, ?)`,
+		role.Name, role.Description, models.FormatPermissions(role.Permissions), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return nil
+}
+
+// UpdateRole replaces a role's description and permissions.
+func (db *DB) UpdateRole(ctx context.Context, role *models.RoleDefinition) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE roles SET description = ?, permissions = ? WHERE name = ?`,
+		role.Description, models.FormatPermissions(role.Permissions), role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	return nil
+}
+
+// DeleteRole removes a custom role. Roles that are still assigned to users
+// can't be deleted.
+func (db *DB) DeleteRole(ctx context.Context, name models.Role) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM roles WHERE name = ? AND built_in = 0", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	return nil
+}
+
+// Group queries
+
+// ListGroups returns every group with its member count, by name.
+func (db *DB) ListGroups(ctx context.Context) ([]models.Group, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.name, g.description, g.created_at,
+			(SELECT COUNT(*) FROM group_members gm WHERE gm.group_id = g.id)
+		FROM groups g
+		ORDER BY g.name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.MemberCount); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// GetGroup retrieves a group by ID.
+func (db *DB) GetGroup(ctx context.Context, id int64) (*models.Group, error) {
+	return db.getGroup(ctx, "g.id = ?", id)
+}
+
+// GetGroupByName retrieves a group by name, case-insensitively.
+func (db *DB) GetGroupByName(ctx context.Context, name string) (*models.Group, error) {
+	return db.getGroup(ctx, "g.name = ? COLLATE NOCASE", name)
+}
+
+func (db *DB) getGroup(ctx context.Context, where string, arg interface{}) (*models.Group, error) {
+	g := &models.Group{}
+	err := db.QueryRowContext(ctx, `
+		SELECT g.id, g.name, g.description, g.created_at,
+			(SELECT COUNT(*) FROM group_members gm WHERE gm.group_id = g.id)
+		FROM groups g
+		WHERE `+where, arg).Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.MemberCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+	return g, nil
+}
+
+// CreateGroup adds a group.
+func (db *DB) CreateGroup(ctx context.Context, group *models.Group) error {
+	group.CreatedAt = time.Now().UTC()
+	id, err := insertReturningID(ctx, db, `
+		INSERT INTO groups (name, description, created_at) VALUES (?, ?, ?)`,
+		group.Name, group.Description, group.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create group: %w", err)
+	}
+
+	group.ID = id
+	return nil
+}
+
+// UpdateGroup renames a group and replaces its description.
+func (db *DB) UpdateGroup(ctx context.Context, group *models.Group) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE groups SET name = ?, description = ? WHERE id = ?`,
+		group.Name, group.Description, group.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup removes a group. Its memberships, page restrictions and
+// share links go with it.
+func (db *DB) DeleteGroup(ctx context.Context, id int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM groups WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+	// Pages restricted only to this group become visible to everyone
+	db.pagesChanged()
+	return nil
+}
+
+// ListGroupMembers returns a group's members by username.
+func (db *DB) ListGroupMembers(ctx context.Context, groupID int64) ([]models.GroupMember, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT u.id, u.username, u.role, gm.added_at
+		FROM group_members gm
+		JOIN users u ON gm.user_id = u.id
+		WHERE gm.group_id = ?
+		ORDER BY u.username COLLATE NOCASE`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.GroupMember
+	for rows.Next() {
+		var m models.GroupMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.AddedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddGroupMember adds a user to a group. Adding an existing member does
+// nothing.
+func (db *DB) AddGroupMember(ctx context.Context, groupID, userID int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO group_members (group_id, user_id, added_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		groupID, userID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to add group member: %w", err)
+	}
+	return nil
+}
+
+// RemoveGroupMember removes a user from a group.
+func (db *DB) RemoveGroupMember(ctx context.Context, groupID, userID int64) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+	return nil
+}
+
+// ListUserGroups returns the groups a user belongs to, by name.
+func (db *DB) ListUserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.name, g.description, g.created_at
+		FROM groups g
+		JOIN group_members gm ON gm.group_id = g.id
+		WHERE gm.user_id = ?
+		ORDER BY g.name COLLATE NOCASE`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// ListGroupNamesByUser returns the names of every user's groups by user ID,
+// each sorted by name.
+func (db *DB) ListGroupNamesByUser(ctx context.Context) (map[int64][]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT gm.user_id, g.name
+		FROM group_members gm
+		JOIN groups g ON gm.group_id = g.id
+		ORDER BY g.name COLLATE NOCASE`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group members: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[int64][]string)
+	for rows.Next() {
+		var userID int64
+		var name string
+		if err := rows.Scan(&userID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan group member: %w", err)
+		}
+		names[userID] = append(names[userID], name)
+	}
+
+	return names, rows.Err()
+}
+
+// getUserGroupIDs returns the IDs of the groups a user belongs to.
+func (db *DB) getUserGroupIDs(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT group_id FROM group_members WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user groups: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan user group: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// GetPageGroups returns the groups a page is restricted to.
+func (db *DB) GetPageGroups(ctx context.Context, pageID int64) ([]models.Group, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT g.id, g.name, g.description, g.created_at
+		FROM groups g
+		JOIN page_groups pg ON pg.group_id = g.id
+		WHERE pg.page_id = ?
+		ORDER BY g.name COLLATE NOCASE`, pageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// SetPageGroups replaces the groups a page is restricted to. An empty list
+// makes the page visible to everyone again.
+func (db *DB) SetPageGroups(ctx context.Context, pageID int64, groupIDs []int64) error {
+	defer db.pagesChanged()
+	return db.Transaction(ctx, func(tx *Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM page_groups WHERE page_id = ?", pageID); err != nil {
+			return fmt.Errorf("failed to clear page groups: %w", err)
+		}
+		for _, id := range groupIDs {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO page_groups (page_id, group_id) VALUES (?, ?) ON CONFLICT DO NOTHING", pageID, id); err != nil {
+				return fmt.Errorf("failed to set page groups: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// IsPagePublic reports whether the page itself is marked public, rather
+// than being under a public page.
+func (db *DB) IsPagePublic(ctx context.Context, pageID int64) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM public_pages WHERE page_id = ?", pageID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check public page: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetPagePublic marks a page, with its subpages, as readable without
+// signing in, or clears the mark.
+func (db *DB) SetPagePublic(ctx context.Context, pageID int64, public bool) error {
+	query := "DELETE FROM public_pages WHERE page_id = ?"
+	if public {
+		query = "INSERT INTO public_pages (page_id) VALUES (?) ON CONFLICT DO NOTHING"
+	}
+	if _, err := db.ExecContext(ctx, query, pageID); err != nil {
+		return fmt.Errorf("failed to set public page: %w", err)
+	}
+	return nil
+}
+
+// ListPublicPageIDs returns the IDs of the pages marked public.
+func (db *DB) ListPublicPageIDs(ctx context.Context) ([]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT page_id FROM public_pages")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list public pages: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan public page: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// InPublicSubtree reports whether the page with the slug is marked public
+// or is a subpage of one that is.
+func (db *DB) InPublicSubtree(ctx context.Context, slug string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM pages WHERE slug = ? COLLATE NOCASE
+			UNION ALL
+			SELECT p.id, p.parent_id FROM pages p
+			JOIN ancestors a ON p.id = a.parent_id
+		)
+		SELECT COUNT(*) FROM ancestors a JOIN public_pages pp ON pp.page_id = a.id
+	`, slug).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check public subtree: %w", err)
+	}
+	return count > 0, nil
+}
+
+// pageGroupsWhere returns a condition on pages aliased p that leaves out
+// pages restricted to groups, unless one of their groups is in memberOf.
+func pageGroupsWhere(memberOf []int64) (string, []interface{}) {
+	unrestricted := "NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)"
+	if len(memberOf) == 0 {
+		return unrestricted, nil
+	}
+
+	args := make([]interface{}, len(memberOf))
+	for i, id := range memberOf {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(memberOf)), ",")
+	return "(" + unrestricted + " OR EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id AND pg.group_id IN (" + placeholders + ")))", args
+}
+
+// Diagnostic queries
+
+// ForeignKeyViolation counts rows in a table whose reference into the
+// parent table points at a row that no longer exists.
+type ForeignKeyViolation struct {
+	Table  string
+	Parent string
+	Count  int
+}
+
+// ListForeignKeyViolations summarizes dangling references by table. Rows
+// can be left behind by databases written before foreign keys were
+// enforced, or by manual edits.
+func (db *DB) ListForeignKeyViolations(ctx context.Context) ([]ForeignKeyViolation, error) {
+	// PostgreSQL has always enforced them
+	if db.driver == DriverPostgres {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT "table", parent, COUNT(*)
+		FROM pragma_foreign_key_check
+		GROUP BY "table", parent
+		ORDER BY "table", parent`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var violations []ForeignKeyViolation
+	for rows.Next() {
+		var v ForeignKeyViolation
+		if err := rows.Scan(&v.Table, &v.Parent, &v.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan foreign key violation: %w", err)
+		}
+		violations = append(violations, v)
+	}
+
+	return violations, rows.Err()
+}
+
+// CountOrphanedPages counts pages whose author no longer exists.
+func (db *DB) CountOrphanedPages(ctx context.Context) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM pages
+		WHERE author_id NOT IN (SELECT id FROM users)`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count orphaned pages: %w", err)
+	}
+	return n, nil
+}
+
+// MissingFTSTriggers returns the triggers that keep the search index in
+// sync with pages but are missing from the database.
+func (db *DB) MissingFTSTriggers(ctx context.Context) ([]string, error) {
+	var missing []string
+	for _, name := range pagesFTSTriggerNames {
+		query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?"
+		if db.driver == DriverPostgres {
+			query = "SELECT COUNT(*) FROM pg_trigger WHERE tgname = ? AND NOT tgisinternal"
+		}
+		var n int
+		err := db.QueryRowContext(ctx, query, name).Scan(&n)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check trigger %s: %w", name, err)
+		}
+		if n == 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// RepairFTSTriggers recreates missing search index triggers and rebuilds
+// the index, since edits made without them were never indexed.
+func (db *DB) RepairFTSTriggers(ctx context.Context) error {
+	triggers := pagesFTSTriggers
+	if db.driver == DriverPostgres {
+		triggers = postgresFTSTriggers
+	}
+	if _, err := db.ExecContext(ctx, triggers); err != nil {
+		return fmt.Errorf("failed to create FTS triggers: %w", err)
+	}
+	return db.RebuildFTSIndex(ctx)
+}
+
+// GetLatestRevisionTime returns when the newest revision was saved, or the
+// zero time if there are none.
+func (db *DB) GetLatestRevisionTime(ctx context.Context) (time.Time, error) {
+	var t time.Time
+	err := db.QueryRowContext(ctx, "SELECT created_at FROM revisions ORDER BY created_at DESC LIMIT 1").Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get latest revision: %w", err)
+	}
+	return t, nil
+}
+
+// Page property queries
+
+// GetPageProperties returns a page's owner and review date. The owner
+// falls back to the author when none was assigned or the owner was deleted.
+func (db *DB) GetPageProperties(ctx context.Context, pageID int64) (owner *models.PropertyUser, reviewAt *time.Time, err error) {
+	var ownerID sql.NullInt64
+	var ownerName sql.NullString
+	var review sql.NullTime
+	err = db.QueryRowContext(ctx, `
+		SELECT COALESCE(pp.owner_id, p.author_id), COALESCE(o.username, a.username), pp.review_at
+		FROM pages p
+		LEFT JOIN page_properties pp ON pp.page_id = p.id
+		LEFT JOIN users o ON o.id = pp.owner_id
+		LEFT JOIN users a ON a.id = p.author_id
+		WHERE p.id = ?
+	`, pageID).Scan(&ownerID, &ownerName, &review)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get page properties: %w", err)
+	}
+
+	if ownerID.Valid && ownerName.Valid {
+		owner = &models.PropertyUser{ID: ownerID.Int64, Username: ownerName.String}
+	}
+	if review.Valid {
+		reviewAt = &review.Time
+	}
+	return owner, reviewAt, nil
+}
+
+// SetPageOwner assigns a page's owner; nil hands it back to the author.
+func (db *DB) SetPageOwner(ctx context.Context, pageID int64, ownerID *int64) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO page_properties (page_id, owner_id, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(page_id) DO UPDATE SET owner_id = excluded.owner_id, updated_at = excluded.updated_at
+	`, pageID, ownerID, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set page owner: %w", err)
+	}
+	return nil
+}
+
+// SetPageReviewDate sets the date a page is due for review; nil clears it.
+func (db *DB) SetPageReviewDate(ctx context.Context, pageID int64, reviewAt *time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO page_properties (page_id, review_at, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(page_id) DO UPDATE SET review_at = excluded.review_at, updated_at = excluded.updated_at
+	`, pageID, reviewAt, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set page review date: %w", err)
+	}
+	return nil
+}
+
+// GetPageMeta returns the description and social image set for a page.
+func (db *DB) GetPageMeta(ctx context.Context, pageID int64) (models.PageMeta, error) {
+	var meta models.PageMeta
+	err := db.QueryRowContext(ctx,
+		"SELECT description, social_image FROM page_properties WHERE page_id = ?", pageID,
+	).Scan(&meta.Description, &meta.SocialImage)
+	if err != nil && err != sql.ErrNoRows {
+		return meta, fmt.Errorf("failed to get page meta: %w", err)
+	}
+	return meta, nil
+}
+
+// SetPageMeta sets a page's description and social image.
+func (db *DB) SetPageMeta(ctx context.Context, pageID int64, meta models.PageMeta) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO page_properties (page_id, description, social_image, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(page_id) DO UPDATE SET description = excluded.description, social_image = excluded.social_image, updated_at = excluded.updated_at
+	`, pageID, meta.Description, meta.SocialImage, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("failed to set page meta: %w", err)
+	}
+	return nil
+}
+
+// Page draft queries
+
+// GetPageDraft returns a page's unpublished changes, or nil if it has none.
+func (db *DB) GetPageDraft(ctx context.Context, pageID int64) (*models.PageDraft, error) {
+	var d models.PageDraft
+	var tags string
+	var authorID sql.NullInt64
+	var author sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT d.page_id, d.title, d.content, d.tags, d.author_id, u.username, d.updated_at
+		FROM page_drafts d
+		LEFT JOIN users u ON u.id = d.author_id
+		WHERE d.page_id = ?
+	`, pageID).Scan(&d.PageID, &d.Title, &d.Content, &tags, &authorID, &author, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page draft: %w", err)
+	}
+
+	d.Tags = []string{}
+	if tags != "" {
+		d.Tags = strings.Split(tags, ",")
+	}
+	if authorID.Valid {
+		d.AuthorID = &authorID.Int64
+	}
+	d.Author = author.String
+	return &d, nil
+}
+
+// SavePageDraft creates or replaces a page's draft.
+func (db *DB) SavePageDraft(ctx context.Context, draft *models.PageDraft) error {
+	draft.UpdatedAt = time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO page_drafts (page_id, title, content, tags, author_id, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(page_id) DO UPDATE SET
+			title = excluded.title, content = excluded.content, tags = excluded.tags,
+			author_id = excluded.author_id, updated_at = excluded.updated_at
+	`, draft.PageID, draft.Title, draft.Content, strings.Join(draft.Tags, ","), draft.AuthorID, draft.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save page draft: %w", err)
+	}
+	return nil
+}
+
+// DeletePageDraft removes a page's draft. It reports whether there was one.
+func (db *DB) DeletePageDraft(ctx context.Context, pageID int64) (bool, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM page_drafts WHERE page_id = ?", pageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete page draft: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Session queries
+
+const sessionColumns = `id, user_id, data, ip_address, user_agent, created_at, last_seen_at, expires_at`
+
+func scanSession(row interface{ Scan(...any) error }) (*models.Session, error) {
+	var s models.Session
+	var lastSeen sql.NullTime
+	err := row.Scan(&s.ID, &s.UserID, &s.Data, &s.IPAddress, &s.UserAgent, &s.CreatedAt, &lastSeen, &s.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	s.LastSeenAt = s.CreatedAt
+	if lastSeen.Valid {
+		s.LastSeenAt = lastSeen.Time
+	}
+	return &s, nil
+}
+
+// GetSession retrieves a session by ID. It returns nil if there is none.
+func (db *DB) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	s, err := scanSession(db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return s, nil
+}
+
+// SaveSession creates or updates a session, marking it seen now.
+func (db *DB) SaveSession(ctx context.Context, s *models.Session) error {
+	now := time.Now().UTC()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, data, ip_address, user_agent, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			user_id = excluded.user_id,
+			data = excluded.data,
+			ip_address = excluded.ip_address,
+			user_agent = excluded.user_agent,
+			last_seen_at = excluded.last_seen_at,
+			expires_at = excluded.expires_at
+	`, s.ID, s.UserID, s.Data, s.IPAddress, s.UserAgent, now, now, s.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	s.LastSeenAt = now
+	return nil
+}
+
+// TouchSession records that a session was used from ipAddress and userAgent.
+func (db *DB) TouchSession(ctx context.Context, id, ipAddress, userAgent string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE sessions SET last_seen_at = ?, ip_address = ?, user_agent = ? WHERE id = ?
+	`, time.Now().UTC(), ipAddress, userAgent, id)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// ListUserSessions returns a user's unexpired sessions, most recently used first.
+func (db *DB) ListUserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY COALESCE(last_seen_at, created_at) DESC
+	`, userID, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+// DeleteSession removes a session, signing it out.
+func (db *DB) DeleteSession(ctx context.Context, id string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteUserSession removes one of a user's sessions. It reports false if
+// the user has no session with that ID.
+func (db *DB) DeleteUserSession(ctx context.Context, userID int64, id string) (bool, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete session: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteUserSessions removes all of a user's sessions except keepID, which
+// may be empty, and returns how many were removed.
+func (db *DB) DeleteUserSessions(ctx context.Context, userID int64, keepID string) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ? AND id != ?`, userID, keepID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpiredSessions removes sessions past their expiry.
+func (db *DB) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	result, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Find and replace queries
+
+// ListPageSources returns the markdown of every page in namespace, or of
+// every page when namespace is empty, ordered by slug. Only ID, Slug,
+// Title, Content and ArchivedAt are set.
+func (db *DB) ListPageSources(ctx context.Context, namespace string) ([]models.Page, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, slug, title, content, archived_at
+		FROM pages
+		WHERE ? = '' OR slug = ? OR substr(slug, 1, length(CAST(? AS TEXT)) + 1) = ? || '/'
+		ORDER BY slug
+	`, namespace, namespace, namespace, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list page sources: %w", err)
+	}
+	defer rows.Close()
+
+	var pages []models.Page
+	for rows.Next() {
+		var p models.Page
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Content, &p.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan page source: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	return pages, rows.Err()
+}
+
+// Audit log viewer queries
+
+// auditWhere builds the WHERE clause for filter.
+func auditWhere(filter models.AuditFilter) (string, []interface{}) {
+	clauses := []string{"1 = 1"}
+	var args []interface{}
+	if filter.Username != "" {
+		clauses = append(clauses, "u.username = ? COLLATE NOCASE")
+		args = append(args, filter.Username)
+	}
+	if filter.Action != "" {
+		clauses = append(clauses, "a.action = ?")
+		args = append(args, filter.Action)
+	}
+	if filter.EntityType != "" {
+		clauses = append(clauses, "a.entity_type = ?")
+		args = append(args, filter.EntityType)
+	}
+	if filter.Since != nil {
+		clauses = append(clauses, "a.created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if filter.Until != nil {
+		clauses = append(clauses, "a.created_at < ?")
+		args = append(args, filter.Until.UTC())
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// ListAuditEntries lists audit log entries matching filter, newest first.
+func (db *DB) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	where, args := auditWhere(filter)
+	query := `
+		SELECT a.id, a.user_id, COALESCE(u.username, ''), a.action, a.entity_type, a.entity_id,
+			COALESCE(a.details, ''), COALESCE(a.ip_address, ''), a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE ` + where + `
+		ORDER BY a.created_at DESC, a.id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, filter.Limit, filter.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Username, &e.Action, &e.EntityType, &e.EntityID, &e.Details, &e.IPAddress, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// CountAuditEntries counts the audit log entries matching filter, ignoring
+// its limit and offset.
+func (db *DB) CountAuditEntries(ctx context.Context, filter models.AuditFilter) (int, error) {
+	where, args := auditWhere(filter)
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM audit_log a
+		LEFT JOIN users u ON u.id = a.user_id
+		WHERE `+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	return count, nil
+}
+
+// ListAuditFacets returns the distinct actions and entity types in the
+// audit log, for filter menus.
+func (db *DB) ListAuditFacets(ctx context.Context) (actions, entityTypes []string, err error) {
+	for _, f := range []struct {
+		column string
+		out    *[]string
+	}{{"action", &actions}, {"entity_type", &entityTypes}} {
+		rows, err := db.QueryContext(ctx, `SELECT DISTINCT `+f.column+` FROM audit_log ORDER BY `+f.column)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list audit %ss: %w", f.column, err)
+		}
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("failed to scan audit %s: %w", f.column, err)
+			}
+			*f.out = append(*f.out, v)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return actions, entityTypes, nil
+}
+
+// Promotion queries
+
+// ErrBundleApplied is returned for a promotion bundle applied before.
+var ErrBundleApplied = errors.New("this bundle has already been promoted")
+
+// BundleApplied reports whether the promotion bundle with the ID was
+// applied.
+func (db *DB) BundleApplied(ctx context.Context, id string) (bool, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM promotion_bundles WHERE id = ?", id).Scan(&n); err != nil {
+		return false, fmt.Errorf("failed to look up bundle: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListPagesWithTag returns the unarchived pages tagged tag that aren't
+// restricted to groups, with their content and tags, ordered by slug.
+func (db *DB) ListPagesWithTag(ctx context.Context, tag string) ([]models.Page, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, p.slug, p.title, p.content, p.is_published
+		FROM pages p
+		JOIN page_tags pt ON pt.page_id = p.id
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE t.name = ? COLLATE NOCASE AND p.archived_at IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM page_groups pg WHERE pg.page_id = p.id)
+		ORDER BY p.slug
+	`, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages with tag: %w", err)
+	}
+
+	var pages []models.Page
+	for rows.Next() {
+		var p models.Page
+		if err := rows.Scan(&p.ID, &p.Slug, &p.Title, &p.Content, &p.IsPublished); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan page: %w", err)
+		}
+		pages = append(pages, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range pages {
+		tags, err := db.GetPageTags(ctx, pages[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get page tags: %w", err)
+		}
+		pages[i].Tags = tags
+	}
+	return pages, nil
+}
+
+// ApplyPromotion writes the promoted pages of bundle in one transaction,
+// so a bundle is applied completely or not at all, and records the bundle
+// as applied. It returns ErrBundleApplied if it already was. Pages must be
+// ordered parents first. Each created page gets an initial revision and
+// each changed page a revision of its previous content, both with comment.
+func (db *DB) ApplyPromotion(ctx context.Context, bundle *models.Bundle, authorID int64, comment string, pages []*models.PromotedPage) error {
+	defer db.pagesChanged()
+	return db.Transaction(ctx, func(tx *Tx) error {
+		now := time.Now().UTC()
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO promotion_bundles (id, label, source, applied_by, applied_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING
+		`, bundle.ID, bundle.Label, bundle.Source, authorID, now)
+		if err != nil {
+			return fmt.Errorf("failed to record bundle: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to record bundle: %w", err)
+		} else if n == 0 {
+			return ErrBundleApplied
+		}
+
+		for _, p := range pages {
+			var (
+				id          int64
+				content     string
+				publishedAt sql.NullTime
+				archivedAt  sql.NullTime
+			)
+			err := tx.QueryRowContext(ctx, `
+				SELECT id, content, published_at, archived_at FROM pages WHERE slug = ?
+			`, p.Slug).Scan(&id, &content, &publishedAt, &archivedAt)
+			switch {
+			case err == sql.ErrNoRows:
+				if err := db.createPromotedPageTx(ctx, tx, p, authorID, comment, now); err != nil {
+					return err
+				}
+				continue
+			case err != nil:
+				return fmt.Errorf("failed to look up %s: %w", p.Slug, err)
+			}
+
+			p.ID = id
+			if p.CreateOnly {
+				continue
+			}
+			if archivedAt.Valid {
+				return fmt.Errorf("failed to promote %s: page is archived", p.Slug)
+			}
+
+			if content != p.Content {
+				if _, err := tx.ExecContext(ctx, `
+					INSERT INTO revisions (page_id, content, author_id, comment, created_at)
+					VALUES (?, ?, ?, ?, ?)
+				`, id, content, authorID, comment, now); err != nil {
+					return fmt.Errorf("failed to create revision for %s: %w", p.Slug, err)
+				}
+			}
+			if p.IsPublished && !publishedAt.Valid {
+				publishedAt = sql.NullTime{Time: now, Valid: true}
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE pages
+				SET title = ?, content = ?, content_html = ?, excerpt = ?, word_count = ?, is_published = ?, updated_at = ?, published_at = ?
+				WHERE id = ?
+			`, p.Title, p.Content, p.ContentHTML, p.Excerpt, p.WordCount, p.IsPublished, now, publishedAt, id); err != nil {
+				return fmt.Errorf("failed to update %s: %w", p.Slug, err)
+			}
+			if err := db.setPageTagsTx(ctx, tx, id, p.Tags); err != nil {
+				return fmt.Errorf("failed to set tags for %s: %w", p.Slug, err)
+			}
+		}
+		return nil
+	})
+}
+
+// createPromotedPageTx inserts a promoted page under the page its slug
+// names as parent.
+func (db *DB) createPromotedPageTx(ctx context.Context, tx *Tx, p *models.PromotedPage, authorID int64, comment string, now time.Time) error {
+	var parentID *int64
+	if i := strings.LastIndex(p.Slug, "/"); i > 0 {
+		var id int64
+		err := tx.QueryRowContext(ctx, "SELECT id FROM pages WHERE slug = ?", p.Slug[:i]).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to find parent of %s: %w", p.Slug, err)
+		}
+		parentID = &id
+	}
+
+	var publishedAt sql.NullTime
+	if p.IsPublished {
+		publishedAt = sql.NullTime{Time: now, Valid: true}
+	}
+	var err error
+	p.ID, err = insertReturningID(ctx, tx, `
+		INSERT INTO pages (slug, title, content, content_html, excerpt, word_count, author_id, parent_id, is_published, created_at, updated_at, published_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.Slug, p.Title, p.Content, p.ContentHTML, p.Excerpt, p.WordCount, authorID, parentID, p.IsPublished, now, now, publishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", p.Slug, err)
+	}
+	p.Created = true
+
+	if !p.CreateOnly {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO revisions (page_id, content, author_id, comment, created_at)
+			VALUES (?, ?, ?, ?, ?)
+		`, p.ID, p.Content, authorID, comment, now); err != nil {
+			return fmt.Errorf("failed to create revision for %s: %w", p.Slug, err)
+		}
+		if err := db.setPageTagsTx(ctx, tx, p.ID, p.Tags); err != nil {
+			return fmt.Errorf("failed to set tags for %s: %w", p.Slug, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/services"
+	"gowiki/internal/views/auth"
+)
+
+// allowAccountEmail rate limits password reset and verification emails by
+// client IP and by address, so neither can be used to flood an inbox.
+func (h *Handlers) allowAccountEmail(c echo.Context, address string) bool {
+	keys := []string{c.RealIP(), "email:" + strings.ToLower(strings.TrimSpace(address))}
+	for _, key := range keys {
+		if allowed, _ := h.accountLimiter.Check(key); !allowed {
+			return false
+		}
+	}
+	for _, key := range keys {
+		h.accountLimiter.RecordFailure(key)
+	}
+	return true
+}
+
+// ForgotPasswordForm asks for the email address to send a reset link to.
+func (h *Handlers) ForgotPasswordForm(c echo.Context) error {
+	if !h.accounts.Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "Password reset is not available")
+	}
+
+	data := auth.ForgotPasswordData{
+		PageData: h.basePageData(c, "Forgot Password"),
+	}
+	return render(c, http.StatusOK, auth.ForgotPassword(data))
+}
+
+// ForgotPassword sends a reset link. The response is the same whether or
+// not the address has an account.
+func (h *Handlers) ForgotPassword(c echo.Context) error {
+	if !h.accounts.Enabled() {
+		return echo.NewHTTPError(http.StatusNotFound, "Password reset is not available")
+	}
+
+	data := auth.ForgotPasswordData{
+		PageData: h.basePageData(c, "Forgot Password"),
+		Email:    strings.TrimSpace(c.FormValue("email")),
+	}
+	if data.Email == "" || len(data.Email) > maxEmailLength {
+		data.Error = "Enter the email address of your account."
+		return render(c, http.StatusBadRequest, auth.ForgotPassword(data))
+	}
+
+	if !h.allowAccountEmail(c, data.Email) {
+		data.Error = "Too many reset requests. Please try again in " + formatDuration(nil) + "."
+		return render(c, http.StatusTooManyRequests, auth.ForgotPassword(data))
+	}
+
+	if err := h.accounts.RequestPasswordReset(c.Request().Context(), data.Email, c.RealIP()); err != nil {
+		fmt.Printf("Warning: failed to send password reset: %v\n", err)
+	}
+
+	data.Sent = true
+	return render(c, http.StatusOK, auth.ForgotPassword(data))
+}
+
+// ResetPasswordForm lets the user from a reset link choose a new password.
+func (h *Handlers) ResetPasswordForm(c echo.Context) error {
+	data := auth.ResetPasswordData{
+		PageData: h.basePageData(c, "Reset Password"),
+		Token:    c.Param("token"),
+	}
+
+	user, err := h.accounts.LookupPasswordReset(c.Request().Context(), data.Token)
+	if err != nil {
+		data.Error = "This reset link is invalid or has expired."
+		data.Expired = true
+		return render(c, http.StatusNotFound, auth.ResetPassword(data))
+	}
+	data.Username = user.Username
+
+	return render(c, http.StatusOK, auth.ResetPassword(data))
+}
+
+// ResetPassword sets the new password and signs the user in.
+func (h *Handlers) ResetPassword(c echo.Context) error {
+	ctx := c.Request().Context()
+	data := auth.ResetPasswordData{
+		PageData: h.basePageData(c, "Reset Password"),
+		Token:    c.Param("token"),
+	}
+
+	user, err := h.accounts.LookupPasswordReset(ctx, data.Token)
+	if err != nil {
+		data.Error = "This reset link is invalid or has expired."
+		data.Expired = true
+		return render(c, http.StatusNotFound, auth.ResetPassword(data))
+	}
+	data.Username = user.Username
+
+	password := c.FormValue("password")
+	if password != c.FormValue("password_confirm") {
+		data.Error = "Passwords do not match."
+		return render(c, http.StatusBadRequest, auth.ResetPassword(data))
+	}
+
+	if _, err := h.accounts.ResetPassword(ctx, data.Token, password, c.RealIP()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPassword):
+			data.Error = err.Error()
+		case errors.Is(err, services.ErrAccountLinkInvalid):
+			data.Error = "This reset link is invalid or has expired."
+			data.Expired = true
+		default:
+			data.Error = "Failed to set your password. Please try again."
+		}
+		return render(c, http.StatusBadRequest, auth.ResetPassword(data))
+	}
+
+	// A lockout from guessing the old password shouldn't outlast the reset
+	h.loginLimiter.RecordSuccess(c.RealIP())
+
+	// Whoever knew the old password is signed out everywhere
+	if _, err := h.wikiService.GetDB().DeleteUserSessions(ctx, user.ID, ""); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions after password reset: %v\n", err)
+	}
+
+	if err := h.sessionManager.SetUserID(c, user.ID); err != nil {
+		h.setFlash(c, "success", "Password changed! Please log in.")
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	h.setFlash(c, "success", "Your password has been changed.")
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// VerifyEmail confirms the email address from a verification link.
+func (h *Handlers) VerifyEmail(c echo.Context) error {
+	_, err := h.accounts.VerifyEmail(c.Request().Context(), c.Param("token"), c.RealIP())
+
+	if middleware.GetUser(c) != nil {
+		if err != nil {
+			h.setFlash(c, "error", "The verification link is invalid or has expired.")
+		} else {
+			h.setFlash(c, "success", "Your email address is confirmed.")
+		}
+		return c.Redirect(http.StatusSeeOther, "/")
+	}
+
+	data := auth.LoginData{
+		PageData:          h.basePageData(c, "Login"),
+		AllowRegistration: h.settings.AllowRegistration(c.Request().Context()),
+		CanResetPassword:  h.accounts.Enabled(),
+	}
+	if err != nil {
+		data.Error = "The verification link is invalid or has expired. Sign in to get a new one."
+		return render(c, http.StatusNotFound, auth.Login(data))
+	}
+	data.Notice = "Your email address is confirmed. You can sign in now."
+	return render(c, http.StatusOK, auth.Login(data))
+}
+
+// ResendVerification sends a new verification link. Like ForgotPassword
+// it answers the same way for unknown users.
+func (h *Handlers) ResendVerification(c echo.Context) error {
+	login := strings.TrimSpace(c.FormValue("login"))
+	if login == "" || !h.accounts.Enabled() {
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	data := auth.LoginData{
+		PageData:          h.basePageData(c, "Login"),
+		Username:          login,
+		AllowRegistration: h.settings.AllowRegistration(c.Request().Context()),
+		CanResetPassword:  true,
+	}
+
+	if !h.allowAccountEmail(c, login) {
+		data.Error = "Too many emails requested. Please try again in " + formatDuration(nil) + "."
+		return render(c, http.StatusTooManyRequests, auth.Login(data))
+	}
+
+	if err := h.accounts.ResendVerification(c.Request().Context(), login); err != nil {
+		fmt.Printf("Warning: failed to resend verification email: %v\n", err)
+	}
+
+	data.Notice = "If the account still needs confirming, a new link is on its way."
+	return render(c, http.StatusOK, auth.Login(data))
+}
+
+// ChangePasswordForm lets the signed-in user choose a new password. Users
+// whose password an admin asked them to change are sent here.
+func (h *Handlers) ChangePasswordForm(c echo.Context) error {
+	user := middleware.GetUser(c)
+	data := auth.ChangePasswordData{
+		PageData: h.basePageData(c, "Change Password"),
+		Forced:   user.MustChangePassword,
+	}
+	return render(c, http.StatusOK, auth.ChangePassword(data))
+}
+
+// ChangePassword sets the signed-in user's new password.
+func (h *Handlers) ChangePassword(c echo.Context) error {
+	user := middleware.GetUser(c)
+	data := auth.ChangePasswordData{
+		PageData: h.basePageData(c, "Change Password"),
+		Forced:   user.MustChangePassword,
+	}
+
+	// Guessing the current password here counts against the login limit
+	clientIP := c.RealIP()
+	if allowed, remaining := h.loginLimiter.Check(clientIP); !allowed {
+		data.Error = "Too many attempts. Please try again in " + formatDuration(remaining) + "."
+		return render(c, http.StatusTooManyRequests, auth.ChangePassword(data))
+	}
+
+	password := c.FormValue("password")
+	if password != c.FormValue("password_confirm") {
+		data.Error = "Passwords do not match."
+		return render(c, http.StatusBadRequest, auth.ChangePassword(data))
+	}
+
+	err := h.authService.ChangePassword(c.Request().Context(), user.ID, c.FormValue("current_password"), password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			h.loginLimiter.RecordFailure(clientIP)
+			data.Error = "Your current password is incorrect."
+		case errors.Is(err, services.ErrInvalidPassword), errors.Is(err, services.ErrPasswordUnchanged):
+			data.Error = err.Error()
+		case errors.Is(err, services.ErrDirectoryAccount):
+			data.Error = "Your password is managed by the directory. Change it there."
+		default:
+			data.Error = "Failed to change your password. Please try again."
+		}
+		return render(c, http.StatusBadRequest, auth.ChangePassword(data))
+	}
+
+	// Sign out other browsers that may have used the old password
+	if _, err := h.wikiService.GetDB().DeleteUserSessions(c.Request().Context(), user.ID, h.sessionManager.SessionID(c)); err != nil {
+		fmt.Printf("Warning: failed to revoke sessions after password change: %v\n", err)
+	}
+
+	h.logAdminAction(c, "password_change", "user", &user.ID, map[string]interface{}{
+		"forced": data.Forced,
+	})
+
+	h.setFlash(c, "success", "Your password has been changed.")
+	return c.Redirect(http.StatusSeeOther, "/")
+}
@@ -3,34 +3,52 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
 	"gowiki/internal/views/admin"
 )
 
+// maxLockReasonLength caps the note admins leave when locking an account.
+const maxLockReasonLength = 200
+
 // AdminDashboard renders the admin dashboard.
 func (h *Handlers) AdminDashboard(c echo.Context) error {
 	ctx := c.Request().Context()
 
 	stats, _ := h.wikiService.GetStats(ctx)
 	users, _ := h.authService.ListUsers(ctx, 100, 0)
+	roles, _ := h.roles.List(ctx)
 
+	site := h.settings.Site(ctx)
 	data := admin.DashboardData{
 		PageData: h.basePageData(c, "Admin Dashboard"),
 		Users:    users,
+		Roles:    roles,
 		Settings: &admin.Settings{
-			SiteName:          h.config.Site.Name,
-			AllowRegistration: h.config.Site.AllowRegistration,
-			DefaultRole:       h.config.Site.DefaultRole,
-			RequireAuth:       h.config.Site.RequireAuth,
+			SiteName:                 site.SiteName,
+			AllowRegistration:        site.AllowRegistration,
+			RequireEmailVerification: site.RequireEmailVerification,
+			MailEnabled:              h.mail.Enabled(),
+			DefaultRole:              site.DefaultRole,
+			RequireAuth:              site.RequireAuth,
+			Math:                     site.Math,
+			UploadMaxSize:            site.UploadMaxSize,
+			UploadAllowedTypes:       strings.Join(site.UploadAllowedTypes, ", "),
 		},
 	}
+	data.Settings.HomePage, _ = h.authService.GetSetting(ctx, services.SettingHomePage)
+	data.Settings.SidebarPage, _ = h.authService.GetSetting(ctx, services.SettingSidebarPage)
 
 	if stats != nil {
 		data.Stats = &admin.Stats{
@@ -75,6 +93,17 @@ func (h *Handlers) AdminCreateUser(c echo.Context) error {
 		role = models.RoleViewer
 	}
 
+	if !policy.CanAssignRole(middleware.GetUser(c), role) {
+		if c.Request().Header.Get("X-CSRF-Token") != "" {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"success": false,
+				"error":   "Only administrators can create administrator accounts",
+			})
+		}
+		h.setFlash(c, "error", "Only administrators can create administrator accounts")
+		return c.Redirect(http.StatusSeeOther, "/admin")
+	}
+
 	ctx := c.Request().Context()
 	newUser, err := h.authService.CreateUser(ctx, models.UserCreate{
 		Username: username,
@@ -96,6 +125,7 @@ func (h *Handlers) AdminCreateUser(c echo.Context) error {
 			"username": username,
 			"role":     string(role),
 		})
+		h.webhooks.EmitUserCreated(ctx, newUser)
 		return c.JSON(http.StatusOK, map[string]interface{}{
 			"success": true,
 			"message": "User created successfully",
@@ -113,6 +143,7 @@ func (h *Handlers) AdminCreateUser(c echo.Context) error {
 		"username": username,
 		"role":     string(role),
 	})
+	h.webhooks.EmitUserCreated(ctx, newUser)
 
 	h.setFlash(c, "success", "User created successfully")
 	return c.Redirect(http.StatusSeeOther, "/admin")
@@ -159,6 +190,53 @@ func (h *Handlers) AdminUpdateUser(c echo.Context) error {
 		update.IsActive = &isActive
 	}
 
+	// Unchecked boxes are absent from the form, so they mean false too
+	mustChange := c.FormValue("must_change_password") == "true"
+	update.MustChangePassword = &mustChange
+	locked := c.FormValue("locked") == "true"
+	update.Locked = &locked
+	if locked {
+		reason := strings.TrimSpace(c.FormValue("lock_reason"))
+		if len(reason) > maxLockReasonLength {
+			reason = reason[:maxLockReasonLength]
+		}
+		update.LockReason = &reason
+	}
+
+	if current := middleware.GetUser(c); locked && current != nil && current.ID == userID {
+		if isAjax {
+			return c.JSON(http.StatusBadRequest, map[string]interface{}{
+				"success": false,
+				"error":   "You can't lock your own account",
+			})
+		}
+		h.setFlash(c, "error", "You can't lock your own account")
+		return c.Redirect(http.StatusSeeOther, "/admin")
+	}
+
+	before, err := h.authService.GetUserByID(c.Request().Context(), userID)
+	if err != nil || before == nil {
+		if isAjax {
+			return c.JSON(http.StatusNotFound, map[string]interface{}{
+				"success": false,
+				"error":   "User not found",
+			})
+		}
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	current := middleware.GetUser(c)
+	if !policy.CanManageUser(current, before) || (update.Role != nil && !policy.CanAssignRole(current, *update.Role)) {
+		if isAjax {
+			return c.JSON(http.StatusForbidden, map[string]interface{}{
+				"success": false,
+				"error":   "Only administrators can change administrator accounts",
+			})
+		}
+		h.setFlash(c, "error", "Only administrators can change administrator accounts")
+		return c.Redirect(http.StatusSeeOther, "/admin")
+	}
+
 	if err := h.authService.UpdateUser(c.Request().Context(), userID, update); err != nil {
 		if isAjax {
 			return c.JSON(http.StatusBadRequest, map[string]interface{}{
@@ -174,6 +252,17 @@ func (h *Handlers) AdminUpdateUser(c echo.Context) error {
 	h.logAdminAction(c, "user_update", "user", &userID, map[string]interface{}{
 		"fields_updated": update,
 	})
+	switch {
+	case locked && !before.IsLocked():
+		if _, err := h.wikiService.GetDB().DeleteUserSessions(c.Request().Context(), userID, ""); err != nil {
+			fmt.Printf("Warning: failed to sign out locked user: %v\n", err)
+		}
+		h.logAdminAction(c, "user_lock", "user", &userID, map[string]interface{}{
+			"reason": *update.LockReason,
+		})
+	case !locked && before.IsLocked():
+		h.logAdminAction(c, "user_unlock", "user", &userID, nil)
+	}
 
 	if isAjax {
 		return c.JSON(http.StatusOK, map[string]interface{}{
@@ -193,6 +282,16 @@ func (h *Handlers) AdminDeleteUser(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
 	}
 
+	target, err := h.authService.GetUserByID(c.Request().Context(), userID)
+	if err != nil || target == nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"User not found","type":"error"}}`)
+		return c.NoContent(http.StatusNotFound)
+	}
+	if !policy.CanManageUser(middleware.GetUser(c), target) {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Only administrators can delete administrator accounts","type":"error"}}`)
+		return c.NoContent(http.StatusForbidden)
+	}
+
 	if err := h.authService.DeleteUser(c.Request().Context(), userID); err != nil {
 		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete user","type":"error"}}`)
 		return c.NoContent(http.StatusInternalServerError)
@@ -212,35 +311,82 @@ func (h *Handlers) AdminUpdateSettings(c echo.Context) error {
 
 	siteName := strings.TrimSpace(c.FormValue("site_name"))
 	allowReg := c.FormValue("allow_registration") == "true"
+	requireVerify := c.FormValue("require_email_verification") == "true"
 	requireAuth := c.FormValue("require_auth") == "true"
 	defaultRole := c.FormValue("default_role")
+	math := c.FormValue("math") == "true"
+	homePage := strings.TrimSpace(c.FormValue("home_page"))
+	sidebarPage := strings.TrimSpace(c.FormValue("sidebar_page"))
+	uploadMaxMB := strings.TrimSpace(c.FormValue("upload_max_size"))
+	uploadTypes := c.FormValue("upload_allowed_types")
+
+	// The home and sidebar pages must exist; a missing one stops the save
+	for _, p := range []struct{ setting, slug string }{
+		{services.SettingHomePage, homePage},
+		{services.SettingSidebarPage, sidebarPage},
+	} {
+		if err := h.wikiService.SetSitePage(ctx, p.setting, p.slug); err != nil {
+			message := "Failed to save settings"
+			if errors.Is(err, services.ErrPageNotFound) {
+				message = "There is no page " + services.Slugify(p.slug)
+			}
+			if c.Request().Header.Get("HX-Request") == "true" {
+				c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"`+message+`","type":"error"}}`)
+				return c.NoContent(http.StatusBadRequest)
+			}
+			h.setFlash(c, "error", message)
+			return c.Redirect(http.StatusSeeOther, "/admin")
+		}
+	}
 
-	// Update config in memory
-	if siteName != "" {
-		h.config.Site.Name = siteName
+	site := services.SiteSettings{
+		SiteName:                 siteName,
+		RequireAuth:              requireAuth,
+		AllowRegistration:        allowReg,
+		RequireEmailVerification: requireVerify,
+		DefaultRole:              defaultRole,
+		Math:                     math,
+		UploadMaxSize:            h.settings.UploadMaxSize(ctx),
+		UploadAllowedTypes:       strings.Split(uploadTypes, ","),
 	}
-	h.config.Site.AllowRegistration = allowReg
-	h.config.Site.RequireAuth = requireAuth
-	if defaultRole == "viewer" || defaultRole == "editor" {
-		h.config.Site.DefaultRole = defaultRole
+	if mb, err := strconv.ParseInt(uploadMaxMB, 10, 64); err == nil {
+		site.UploadMaxSize = mb << 20
+	} else if uploadMaxMB != "" {
+		site.UploadMaxSize = 0
 	}
+	mathChanged := math != h.settings.Math(ctx)
 
-	// Persist settings to database
-	if siteName != "" {
-		h.authService.SetSetting(ctx, "site_name", siteName)
+	if err := h.settings.Save(ctx, site); err != nil {
+		message := "Failed to save settings"
+		if errors.Is(err, services.ErrSettingDefaultRole) || errors.Is(err, services.ErrSettingUploadMaxSize) {
+			message = err.Error()
+		}
+		if c.Request().Header.Get("HX-Request") == "true" {
+			c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"`+message+`","type":"error"}}`)
+			return c.NoContent(http.StatusBadRequest)
+		}
+		h.setFlash(c, "error", message)
+		return c.Redirect(http.StatusSeeOther, "/admin")
 	}
-	h.authService.SetSetting(ctx, "allow_registration", strconv.FormatBool(allowReg))
-	h.authService.SetSetting(ctx, "require_auth", strconv.FormatBool(requireAuth))
-	if defaultRole == "viewer" || defaultRole == "editor" {
-		h.authService.SetSetting(ctx, "default_role", defaultRole)
+
+	// Saving the math setting switches the renderer; pages rendered
+	// before need it too
+	if mathChanged {
+		go h.rerenderPages()
 	}
 
 	// Audit log: settings updated
 	h.logAdminAction(c, "settings_update", "settings", nil, map[string]interface{}{
-		"site_name":          siteName,
-		"allow_registration": allowReg,
-		"require_auth":       requireAuth,
-		"default_role":       defaultRole,
+		"site_name":                  siteName,
+		"allow_registration":         allowReg,
+		"require_email_verification": requireVerify,
+		"require_auth":               requireAuth,
+		"default_role":               defaultRole,
+		"math":                       math,
+		"upload_max_size":            site.UploadMaxSize,
+		"upload_allowed_types":       uploadTypes,
+		"home_page":                  services.Slugify(homePage),
+		"sidebar_page":               services.Slugify(sidebarPage),
 	})
 
 	// Check if this is an HTMX request
@@ -267,9 +413,17 @@ func (h *Handlers) AdminGenerateBackups(c echo.Context) error {
 
 	ctx := c.Request().Context()
 
+	// Replicas share the backup directory; only one may regenerate it at a time
+	if err := h.cluster.TryLock(ctx, "markdown_backup", 10*time.Minute); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Backups are already being generated","type":"error"}}`)
+		return c.NoContent(http.StatusConflict)
+	}
+	defer h.cluster.Unlock(context.Background(), "markdown_backup")
+
 	// Get all pages
 	filter := models.NewPageFilter()
 	filter.Limit = 10000 // Get all pages
+	filter.IncludeArchived = true
 	pages, err := h.wikiService.ListPages(ctx, filter)
 	if err != nil {
 		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to load pages","type":"error"}}`)
@@ -295,6 +449,8 @@ func (h *Handlers) AdminGenerateBackups(c echo.Context) error {
 		}
 	}
 
+	_ = h.backupService.Commit("Regenerate all backups", user)
+
 	// Audit log
 	h.logAdminAction(c, "generate_backups", "system", nil, map[string]interface{}{
 		"success_count": successCount,
@@ -310,6 +466,42 @@ func (h *Handlers) AdminGenerateBackups(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+// AdminRestoreBackups recreates pages from the markdown backup folder tree.
+func (h *Handlers) AdminRestoreBackups(c echo.Context) error {
+	user := middleware.GetUser(c)
+	if user == nil || user.Role != models.RoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "Admin access required")
+	}
+
+	if h.backupService == nil || !h.backupService.Enabled() {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Backup service not configured","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	result, err := h.wikiService.RestoreFromBackup(c.Request().Context(), h.backupService.Path(), user.ID)
+	if err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to read backup directory","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// Audit log
+	h.logAdminAction(c, "restore_backups", "system", nil, map[string]interface{}{
+		"restored_count": len(result.Restored),
+		"skipped_count":  len(result.Skipped),
+		"error_count":    len(result.Failed),
+	})
+
+	message := "Restored " + strconv.Itoa(len(result.Restored)) + " pages"
+	if len(result.Skipped) > 0 {
+		message += ", skipped " + strconv.Itoa(len(result.Skipped)) + " existing"
+	}
+	if len(result.Failed) > 0 {
+		message += " (" + strconv.Itoa(len(result.Failed)) + " errors)"
+	}
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"`+message+`","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
 // logAdminAction logs an admin action to the audit log.
 func (h *Handlers) logAdminAction(c echo.Context, action, entityType string, entityID *int64, details map[string]interface{}) {
 	user := middleware.GetUser(c)
@@ -339,3 +531,20 @@ func (h *Handlers) logAdminAction(c echo.Context, action, entityType string, ent
 		)
 	}()
 }
+
+// rerenderPages refreshes stored page HTML after a setting changed the
+// rendering rules. It runs in the background under the same lock as the
+// startup re-render.
+func (h *Handlers) rerenderPages() {
+	ctx := context.Background()
+	err := h.cluster.WithLock(ctx, "markdown_rerender", 5*time.Minute, func() error {
+		count, err := h.wikiService.RerenderPages(ctx)
+		if count > 0 {
+			fmt.Printf("Re-rendered %d pages\n", count)
+		}
+		return err
+	})
+	if err != nil && !errors.Is(err, services.ErrLockHeld) {
+		fmt.Printf("Warning: Failed to re-render pages: %v\n", err)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// Analytics shows page views over time, the most viewed pages and pages
+// nobody viewed. Administrators see every page, other users the pages they
+// created.
+func (h *Handlers) Analytics(c echo.Context) error {
+	user := middleware.GetUser(c)
+	days, _ := strconv.Atoi(c.QueryParam("days"))
+	days = services.ValidAnalyticsPeriod(days)
+
+	var authorID *int64
+	if !policy.CanViewAllAnalytics(user) {
+		authorID = &user.ID
+	}
+
+	report, err := h.freshness.Analytics(c.Request().Context(), days, authorID)
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load analytics")
+		report = &services.PageAnalytics{Days: days}
+	}
+
+	data := pages.AnalyticsData{
+		PageData:  h.basePageData(c, "Analytics"),
+		Analytics: report,
+		AllPages:  authorID == nil,
+	}
+
+	return render(c, http.StatusOK, pages.Analytics(data))
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminAnnouncements lists announcements and the form to publish one.
+func (h *Handlers) AdminAnnouncements(c echo.Context) error {
+	all, err := h.announcements.List(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load announcements")
+	}
+
+	data := admin.AnnouncementsData{
+		PageData: h.basePageData(c, "Announcements"),
+		All:      all,
+		Now:      time.Now(),
+	}
+
+	return render(c, http.StatusOK, admin.Announcements(data))
+}
+
+// AdminCreateAnnouncement publishes a new announcement.
+func (h *Handlers) AdminCreateAnnouncement(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	input := services.AnnouncementInput{
+		Message:     c.FormValue("message"),
+		Level:       models.AnnouncementLevel(c.FormValue("level")),
+		Dismissible: c.FormValue("dismissible") == "1",
+	}
+	if raw := strings.TrimSpace(c.FormValue("expires_at")); raw != "" {
+		expires, err := time.Parse("2006-01-02T15:04", raw)
+		if err != nil {
+			h.setFlash(c, "error", "Invalid expiry date")
+			return c.Redirect(http.StatusSeeOther, "/admin/announcements")
+		}
+		input.ExpiresAt = &expires
+	}
+
+	announcement, err := h.announcements.Create(c.Request().Context(), user.ID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEmptyAnnouncement),
+			errors.Is(err, services.ErrInvalidAnnouncement),
+			errors.Is(err, services.ErrAnnouncementExpiry):
+			h.setFlash(c, "error", err.Error())
+		default:
+			h.setFlash(c, "error", "Failed to publish announcement")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/announcements")
+	}
+
+	h.logAdminAction(c, "announcement_create", "announcement", nil, map[string]interface{}{
+		"id":      announcement.ID,
+		"level":   announcement.Level,
+		"message": announcement.Message,
+	})
+
+	h.setFlash(c, "success", "Announcement published")
+	return c.Redirect(http.StatusSeeOther, "/admin/announcements")
+}
+
+// AdminDeleteAnnouncement removes an announcement.
+func (h *Handlers) AdminDeleteAnnouncement(c echo.Context) error {
+	id := c.Param("id")
+	if err := h.announcements.Delete(c.Request().Context(), id); err != nil {
+		if errors.Is(err, services.ErrAnnouncementNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Announcement not found")
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete announcement","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "announcement_delete", "announcement", nil, map[string]interface{}{
+		"id": id,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Announcement deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// DismissAnnouncement hides an announcement for the current user. The banner
+// is removed in place by HTMX.
+func (h *Handlers) DismissAnnouncement(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	if err := h.announcements.Dismiss(c.Request().Context(), user.ID, c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, services.ErrAnnouncementNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Announcement not found")
+		case errors.Is(err, services.ErrAnnouncementPermanent):
+			return echo.NewHTTPError(http.StatusConflict, "Announcement cannot be dismissed")
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to dismiss announcement","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// auditPerPage is the number of entries per audit log page.
+const auditPerPage = 50
+
+// AdminAuditLog lists audit log entries matching the query filters.
+func (h *Handlers) AdminAuditLog(c echo.Context) error {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	pageNum, _ := strconv.Atoi(c.QueryParam("page"))
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	data := admin.AuditData{
+		PageData: h.basePageData(c, "Audit Log"),
+		Page:     pageNum,
+		PerPage:  auditPerPage,
+		Query:    c.QueryParams(),
+	}
+
+	actions, entityTypes, err := db.ListAuditFacets(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load audit log")
+	}
+	data.Actions = actions
+	data.EntityTypes = entityTypes
+
+	filter, err := services.ParseAuditFilter(c.QueryParams())
+	if err != nil {
+		data.Error = "Invalid date filter: " + err.Error()
+		return render(c, http.StatusBadRequest, admin.Audit(data))
+	}
+
+	data.Total, err = db.CountAuditEntries(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load audit log")
+	}
+
+	filter.Limit = auditPerPage
+	filter.Offset = (pageNum - 1) * auditPerPage
+	data.Entries, err = db.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load audit log")
+	}
+
+	return render(c, http.StatusOK, admin.Audit(data))
+}
+
+// AdminExportAuditLog downloads every audit log entry matching the query
+// filters as CSV.
+func (h *Handlers) AdminExportAuditLog(c echo.Context) error {
+	filter, err := services.ParseAuditFilter(c.QueryParams())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid date filter")
+	}
+
+	entries, err := h.wikiService.GetDB().ListAuditEntries(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to export audit log")
+	}
+
+	h.logAdminAction(c, "audit_export", "audit_log", nil, map[string]interface{}{
+		"entries": len(entries),
+	})
+
+	filename := fmt.Sprintf("audit-log-%s.csv", time.Now().UTC().Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().WriteHeader(http.StatusOK)
+	return services.WriteAuditCSV(c.Response(), entries)
+}
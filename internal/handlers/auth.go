@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 
+	"gowiki/internal/i18n"
 	"gowiki/internal/models"
 	"gowiki/internal/services"
 	"gowiki/internal/views/auth"
@@ -28,7 +30,8 @@ func (h *Handlers) LoginForm(c echo.Context) error {
 	data := auth.LoginData{
 		PageData:          h.basePageData(c, "Login"),
 		Next:              next,
-		AllowRegistration: h.config.Site.AllowRegistration,
+		AllowRegistration: h.settings.AllowRegistration(c.Request().Context()),
+		CanResetPassword:  h.accounts.Enabled(),
 	}
 
 	return render(c, http.StatusOK, auth.Login(data))
@@ -46,7 +49,7 @@ func (h *Handlers) Login(c echo.Context) error {
 	if !allowed {
 		data := auth.LoginData{
 			PageData: h.basePageData(c, "Login"),
-			Error:    "Too many login attempts. Please try again in " + formatDuration(remaining) + ".",
+			Error:    i18n.T(c.Request().Context(), "Too many login attempts. Please try again in %s.", formatDuration(remaining)),
 			Next:     next,
 			Username: username,
 		}
@@ -71,19 +74,39 @@ func (h *Handlers) Login(c echo.Context) error {
 		h.loginLimiter.RecordFailure(clientIP)
 
 		errorMsg := "Invalid username or password."
-		if errors.Is(err, services.ErrUserInactive) {
+		switch {
+		case errors.Is(err, services.ErrUserInactive):
 			errorMsg = "Your account has been deactivated."
+		case errors.Is(err, services.ErrUserLocked):
+			errorMsg = "Your account is locked. Contact an administrator to unlock it."
+		case errors.Is(err, services.ErrDirectoryAccount):
+			errorMsg = "Directory sign-in is unavailable. Please try again later."
+		case errors.Is(err, services.ErrDirectoryEmailMissing):
+			errorMsg = "Your directory account has no email address. Ask an administrator to add one, then sign in again."
 		}
 
 		data := auth.LoginData{
-			PageData: h.basePageData(c, "Login"),
-			Error:    errorMsg,
-			Next:     next,
-			Username: username,
+			PageData:         h.basePageData(c, "Login"),
+			Error:            errorMsg,
+			Next:             next,
+			Username:         username,
+			CanResetPassword: h.accounts.Enabled(),
 		}
 		return render(c, http.StatusUnauthorized, auth.Login(data))
 	}
 
+	// The password was right, so this doesn't reveal anything new
+	if h.settings.RequireEmailVerification(c.Request().Context()) && !user.EmailVerified() {
+		data := auth.LoginData{
+			PageData:   h.basePageData(c, "Login"),
+			Error:      i18n.T(c.Request().Context(), "Confirm your email address before signing in. Follow the link we emailed to %s.", user.Email),
+			Next:       next,
+			Username:   username,
+			Unverified: h.accounts.Enabled(),
+		}
+		return render(c, http.StatusForbidden, auth.Login(data))
+	}
+
 	// Clear rate limit on success
 	h.loginLimiter.RecordSuccess(clientIP)
 
@@ -118,7 +141,7 @@ func (h *Handlers) Logout(c echo.Context) error {
 
 // RegisterForm renders the registration page.
 func (h *Handlers) RegisterForm(c echo.Context) error {
-	if !h.config.Site.AllowRegistration {
+	if !h.settings.AllowRegistration(c.Request().Context()) {
 		return c.Redirect(http.StatusSeeOther, "/login")
 	}
 
@@ -132,7 +155,7 @@ func (h *Handlers) RegisterForm(c echo.Context) error {
 
 // Register handles the registration form submission.
 func (h *Handlers) Register(c echo.Context) error {
-	if !h.config.Site.AllowRegistration {
+	if !h.settings.AllowRegistration(c.Request().Context()) {
 		return c.Redirect(http.StatusSeeOther, "/login")
 	}
 
@@ -174,12 +197,17 @@ func (h *Handlers) Register(c echo.Context) error {
 		return render(c, http.StatusBadRequest, auth.Register(data))
 	}
 
+	// New users confirm their address first when verification is required;
+	// without email there would be no way to, so it is skipped.
+	verify := h.settings.RequireEmailVerification(c.Request().Context()) && h.accounts.Enabled()
+
 	// Create user
 	user, err := h.authService.CreateUser(c.Request().Context(), models.UserCreate{
-		Username: username,
-		Email:    email,
-		Password: password,
-		Role:     models.Role(h.config.Site.DefaultRole),
+		Username:   username,
+		Email:      email,
+		Password:   password,
+		Role:       h.settings.DefaultRole(c.Request().Context()),
+		Unverified: verify,
 	})
 
 	if err != nil {
@@ -204,13 +232,27 @@ func (h *Handlers) Register(c echo.Context) error {
 		return render(c, http.StatusBadRequest, auth.Register(data))
 	}
 
+	h.webhooks.EmitUserCreated(c.Request().Context(), user)
+
+	if verify {
+		if err := h.accounts.SendVerification(c.Request().Context(), user); err != nil {
+			fmt.Printf("Warning: failed to send verification email: %v\n", err)
+		}
+		data := auth.CheckEmailData{
+			PageData: h.basePageData(c, "Confirm Your Email"),
+			Email:    user.Email,
+			Username: user.Username,
+		}
+		return render(c, http.StatusOK, auth.CheckEmail(data))
+	}
+
 	// Auto-login after registration
 	if err := h.sessionManager.SetUserID(c, user.ID); err != nil {
 		h.setFlash(c, "success", "Account created! Please log in.")
 		return c.Redirect(http.StatusSeeOther, "/login")
 	}
 
-	h.setFlash(c, "success", "Welcome to "+h.config.Site.Name+"!")
+	h.setFlash(c, "success", "Welcome to "+h.settings.SiteName(c.Request().Context())+"!")
 	return c.Redirect(http.StatusSeeOther, "/")
 }
 
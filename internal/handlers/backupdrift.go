@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// maxCompareFileSize caps the markdown file uploaded for comparison.
+const maxCompareFileSize = 5 << 20
+
+// AdminBackupDrift lists the pages whose markdown backup file is missing or
+// no longer matches the database.
+func (h *Handlers) AdminBackupDrift(c echo.Context) error {
+	data := admin.BackupDriftData{
+		PageData: h.basePageData(c, "Backup Drift"),
+		Enabled:  h.backupService != nil && h.backupService.Enabled(),
+	}
+
+	if data.Enabled {
+		drift, err := h.wikiService.FindBackupDrift(c.Request().Context(), h.backupService)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compare backups")
+		}
+		data.Pages = drift
+	}
+
+	return render(c, http.StatusOK, admin.BackupDrift(data))
+}
+
+// AdminBackupDriftPage shows the diff between a page in the database and
+// its markdown backup file.
+func (h *Handlers) AdminBackupDriftPage(c echo.Context) error {
+	page, err := h.backupDriftPage(c)
+	if err != nil {
+		return err
+	}
+
+	data := admin.BackupCompareData{
+		PageData: h.basePageData(c, "Backup Drift: "+page.Title),
+		Page:     page,
+		Enabled:  h.backupService != nil && h.backupService.Enabled(),
+	}
+	if data.Enabled {
+		data.Comparison, err = h.backupService.ComparePage(page)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read backup file")
+		}
+	}
+
+	return render(c, http.StatusOK, admin.BackupCompare(data))
+}
+
+// AdminCompareUploadedFile shows the diff between a page in the database
+// and an uploaded markdown file. The report carries the file's content so
+// restoring from it doesn't need a second upload.
+func (h *Handlers) AdminCompareUploadedFile(c echo.Context) error {
+	page, err := h.backupDriftPage(c)
+	if err != nil {
+		return err
+	}
+
+	data := admin.BackupCompareData{
+		PageData: h.basePageData(c, "Backup Drift: "+page.Title),
+		Page:     page,
+		Enabled:  h.backupService != nil && h.backupService.Enabled(),
+		Uploaded: true,
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		data.Error = "Choose a markdown file to compare"
+		return render(c, http.StatusBadRequest, admin.BackupCompare(data))
+	}
+	f, err := file.Open()
+	if err != nil {
+		data.Error = "Failed to read the uploaded file"
+		return render(c, http.StatusBadRequest, admin.BackupCompare(data))
+	}
+	content, err := io.ReadAll(io.LimitReader(f, maxCompareFileSize+1))
+	f.Close()
+	if err != nil {
+		data.Error = "Failed to read the uploaded file"
+		return render(c, http.StatusBadRequest, admin.BackupCompare(data))
+	}
+	if len(content) > maxCompareFileSize {
+		data.Error = "The file is too large (5 MB max)"
+		return render(c, http.StatusBadRequest, admin.BackupCompare(data))
+	}
+
+	data.Comparison, err = services.CompareWithFile(page, file.Filename, string(content))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to compare file")
+	}
+
+	return render(c, http.StatusOK, admin.BackupCompare(data))
+}
+
+// AdminRestorePageFromFile replaces a page's content with a markdown file's,
+// as a new revision: the content of an uploaded file when the form carries
+// it, and the page's backup file otherwise. The backup file is then
+// rewritten from the restored page.
+func (h *Handlers) AdminRestorePageFromFile(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	page, err := h.backupDriftPage(c)
+	if err != nil {
+		return err
+	}
+	back := "/admin/backups/drift/" + strconv.FormatInt(page.ID, 10)
+
+	var cmp *services.BackupComparison
+	source := "backup file"
+	if c.FormValue("uploaded") == "1" {
+		source = "uploaded file"
+		cmp, err = services.CompareWithFile(page, source, c.FormValue("content"))
+	} else if h.backupService != nil && h.backupService.Enabled() {
+		cmp, err = h.backupService.ComparePage(page)
+	} else {
+		h.setFlash(c, "error", "Markdown backups are not enabled")
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read the "+source)
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	if cmp.Missing {
+		h.setFlash(c, "error", page.Title+" has no backup file to restore from")
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	if cmp.Unified == "" {
+		h.setFlash(c, "success", page.Title+" already matches the "+source)
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+
+	result, err := h.wikiService.UpdatePage(ctx, page.ID, user.ID, models.PageUpdate{Content: &cmp.Content}, "Restored from "+source)
+	if err != nil {
+		if errors.Is(err, services.ErrPageArchived) {
+			h.setFlash(c, "error", err.Error())
+		} else {
+			h.setFlash(c, "error", "Failed to restore page")
+		}
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+
+	h.backupUpdatedPage(ctx, page.Slug, result, user, "Restore "+page.Slug+" from "+source)
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+
+	h.logAdminAction(c, "backup_restore_page", "page", &page.ID, map[string]interface{}{
+		"slug":    page.Slug,
+		"source":  source,
+		"added":   cmp.Added,
+		"removed": cmp.Removed,
+	})
+
+	h.setFlash(c, "success", "Restored "+page.Title+" from the "+source)
+	return c.Redirect(http.StatusSeeOther, back)
+}
+
+// AdminRewriteBackupFile rewrites a page's markdown backup file from the
+// database.
+func (h *Handlers) AdminRewriteBackupFile(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	page, err := h.backupDriftPage(c)
+	if err != nil {
+		return err
+	}
+	back := "/admin/backups/drift/" + strconv.FormatInt(page.ID, 10)
+
+	if h.backupService == nil || !h.backupService.Enabled() {
+		h.setFlash(c, "error", "Markdown backups are not enabled")
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+
+	if err := h.backupService.SavePageAsMarkdown(page, user.Username, getPagePathFromSlug(page.Slug)); err != nil {
+		h.setFlash(c, "error", "Failed to write backup file")
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	_ = h.backupService.Commit("Rewrite backup of "+page.Slug, user)
+
+	h.logAdminAction(c, "backup_rewrite_page", "page", &page.ID, map[string]interface{}{
+		"slug": page.Slug,
+	})
+
+	h.setFlash(c, "success", "Rewrote the backup file of "+page.Title)
+	return c.Redirect(http.StatusSeeOther, back)
+}
+
+// backupDriftPage loads the page named by the :id route parameter.
+func (h *Handlers) backupDriftPage(c echo.Context) (*models.Page, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+	page, err := h.wikiService.GetPageByID(c.Request().Context(), id)
+	if errors.Is(err, services.ErrPageNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	return page, nil
+}
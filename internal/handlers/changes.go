@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/views/pages"
+)
+
+const (
+	changesPerPage = 50
+	feedEntries    = 50
+)
+
+// RecentChanges renders the wiki-wide change log.
+func (h *Handlers) RecentChanges(c echo.Context) error {
+	pageNum, _ := strconv.Atoi(c.QueryParam("page"))
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	filter := h.changeFilter(c)
+	filter.Limit = changesPerPage + 1
+	filter.Offset = (pageNum - 1) * changesPerPage
+
+	changes, err := h.wikiService.GetDB().ListRecentChanges(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load changes")
+	}
+
+	hasMore := len(changes) > changesPerPage
+	if hasMore {
+		changes = changes[:changesPerPage]
+	}
+
+	pageData := h.basePageDataWithNav(c, "Recent Changes", "changes")
+	h.setSidebar(c, &pageData)
+
+	data := pages.ChangesData{
+		PageData: pageData,
+		Changes:  changes,
+		Tag:      filter.Tag,
+		Author:   filter.Author,
+		Page:     pageNum,
+		HasMore:  hasMore,
+	}
+
+	return render(c, http.StatusOK, pages.Changes(data))
+}
+
+// ChangesAtom serves recent changes as an Atom feed.
+func (h *Handlers) ChangesAtom(c echo.Context) error {
+	changes, err := h.feedChanges(c)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimRight(h.config.Site.URL, "/")
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   h.settings.SiteName(c.Request().Context()) + " - Recent Changes",
+		ID:      base + "/changes",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: base + c.Request().URL.RequestURI(), Rel: "self"},
+			{Href: base + "/changes", Rel: "alternate"},
+		},
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, ch := range changes {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   changeTitle(ch),
+			ID:      base + "/changes#rev-" + strconv.FormatInt(ch.RevisionID, 10),
+			Link:    atomLink{Href: base + "/wiki/" + ch.PageSlug, Rel: "alternate"},
+			Updated: ch.CreatedAt.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: ch.Author},
+			Summary: ch.Comment,
+		})
+	}
+
+	return writeFeed(c, "application/atom+xml; charset=utf-8", feed)
+}
+
+// ChangesRSS serves recent changes as an RSS 2.0 feed.
+func (h *Handlers) ChangesRSS(c echo.Context) error {
+	changes, err := h.feedChanges(c)
+	if err != nil {
+		return err
+	}
+
+	base := strings.TrimRight(h.config.Site.URL, "/")
+	channel := rssChannel{
+		Title:       h.settings.SiteName(c.Request().Context()) + " - Recent Changes",
+		Link:        base + "/changes",
+		Description: "Recent edits on " + h.settings.SiteName(c.Request().Context()),
+	}
+	if len(changes) > 0 {
+		channel.LastBuildDate = changes[0].CreatedAt.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, ch := range changes {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       changeTitle(ch),
+			Link:        base + "/wiki/" + ch.PageSlug,
+			GUID:        rssGUID{Value: base + "/changes#rev-" + strconv.FormatInt(ch.RevisionID, 10), IsPermaLink: "false"},
+			PubDate:     ch.CreatedAt.UTC().Format(time.RFC1123Z),
+			Creator:     ch.Author,
+			Description: ch.Comment,
+		})
+	}
+
+	return writeFeed(c, "application/rss+xml; charset=utf-8", rssFeed{
+		Version: "2.0",
+		XmlnsDC: "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+// changeFilter reads the tag and author filters. Unpublished pages only
+// appear for editors, and restricted pages for their groups.
+func (h *Handlers) changeFilter(c echo.Context) models.ChangeFilter {
+	user := middleware.GetUser(c)
+	filter := models.ChangeFilter{
+		Tag:           strings.TrimSpace(c.QueryParam("tag")),
+		Author:        strings.TrimSpace(c.QueryParam("author")),
+		PublishedOnly: !policy.CanViewUnpublished(user),
+	}
+	filter.HideRestricted, filter.MemberOf = policy.GroupFilter(user)
+	return filter
+}
+
+func (h *Handlers) feedChanges(c echo.Context) ([]models.RecentChange, error) {
+	filter := h.changeFilter(c)
+	filter.Limit = feedEntries
+
+	changes, err := h.wikiService.GetDB().ListRecentChanges(c.Request().Context(), filter)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load changes")
+	}
+	return changes, nil
+}
+
+func changeTitle(ch models.RecentChange) string {
+	if ch.IsNew {
+		return ch.PageTitle + " created by " + ch.Author
+	}
+	return ch.PageTitle + " edited by " + ch.Author
+}
+
+func writeFeed(c echo.Context, contentType string, feed interface{}) error {
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build feed")
+	}
+
+	// Editors see unpublished pages, so their feeds must not land in shared caches
+	if middleware.GetUser(c) != nil {
+		c.Response().Header().Set("Cache-Control", "private, max-age=300")
+	} else {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	}
+	return c.Blob(http.StatusOK, contentType, append([]byte(xml.Header), out...))
+}
+
+// Atom 1.0 (RFC 4287)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Link    atomLink   `xml:"link"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// RSS 2.0
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	XmlnsDC string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Creator     string  `xml:"dc:creator"`
+	Description string  `xml:"description,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/views/admin"
+)
+
+const (
+	// maxCSPReportBody bounds the size of a report request.
+	maxCSPReportBody = 64 * 1024
+	// maxCSPReportsPerRequest bounds how many reports one batch can store.
+	maxCSPReportsPerRequest = 20
+	// cspReportRetention is how long reports are kept.
+	cspReportRetention = 30 * 24 * time.Hour
+)
+
+// legacyCSPReport is the body browsers send to a report-uri.
+type legacyCSPReport struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is one entry of a Reporting API batch sent to report-to.
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// CSPReport ingests Content Security Policy violation reports in both the
+// legacy report-uri format and the Reporting API format.
+func (h *Handlers) CSPReport(c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxCSPReportBody))
+	if err != nil {
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	var reports []models.CSPReport
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		var batch []reportingAPIReport
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		for _, r := range batch {
+			if r.Type != "csp-violation" {
+				continue
+			}
+			reports = append(reports, models.CSPReport{
+				DocumentURI: r.Body.DocumentURL,
+				Directive:   r.Body.EffectiveDirective,
+				BlockedURI:  r.Body.BlockedURL,
+				SourceFile:  r.Body.SourceFile,
+				LineNumber:  r.Body.LineNumber,
+				Disposition: r.Body.Disposition,
+			})
+		}
+	} else {
+		var legacy legacyCSPReport
+		if err := json.Unmarshal(body, &legacy); err != nil {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		directive := legacy.Report.EffectiveDirective
+		if directive == "" {
+			directive = legacy.Report.ViolatedDirective
+		}
+		reports = append(reports, models.CSPReport{
+			DocumentURI: legacy.Report.DocumentURI,
+			Directive:   directive,
+			BlockedURI:  legacy.Report.BlockedURI,
+			SourceFile:  legacy.Report.SourceFile,
+			LineNumber:  legacy.Report.LineNumber,
+			Disposition: legacy.Report.Disposition,
+		})
+	}
+
+	if len(reports) > maxCSPReportsPerRequest {
+		reports = reports[:maxCSPReportsPerRequest]
+	}
+
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+	userAgent := truncateString(c.Request().UserAgent(), 500)
+	for _, r := range reports {
+		if r.Directive == "" {
+			continue
+		}
+		r.DocumentURI = redactReportURI(r.DocumentURI)
+		r.BlockedURI = redactReportURI(r.BlockedURI)
+		r.SourceFile = redactReportURI(r.SourceFile)
+		r.Directive = truncateString(r.Directive, 100)
+		if r.Disposition != models.CSPDispositionReport {
+			r.Disposition = models.CSPDispositionEnforce
+		}
+		r.UserAgent = userAgent
+		if err := db.CreateCSPReport(ctx, &r); err != nil {
+			c.Logger().Errorf("failed to store CSP report: %v", err)
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// AdminCSPReports summarizes CSP violations from the last week.
+func (h *Handlers) AdminCSPReports(c echo.Context) error {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	if err := db.PruneCSPReports(ctx, time.Now().Add(-cspReportRetention)); err != nil {
+		c.Logger().Errorf("failed to prune CSP reports: %v", err)
+	}
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	summaries, err := db.SummarizeCSPReports(ctx, since, 100)
+	if err != nil {
+		summaries = []models.CSPReportSummary{}
+	}
+	recent, err := db.ListCSPReports(ctx, 25)
+	if err != nil {
+		recent = []models.CSPReport{}
+	}
+	total, _ := db.CountCSPReports(ctx, since)
+
+	data := admin.CSPReportsData{
+		PageData:         h.basePageData(c, "CSP Reports"),
+		Summaries:        summaries,
+		Recent:           recent,
+		Total:            total,
+		StrictReportOnly: h.config.Security.CSPStrictReportOnly,
+	}
+
+	return render(c, http.StatusOK, admin.CSPReports(data))
+}
+
+// AdminClearCSPReports deletes all stored CSP reports.
+func (h *Handlers) AdminClearCSPReports(c echo.Context) error {
+	if err := h.wikiService.GetDB().PruneCSPReports(c.Request().Context(), time.Time{}); err != nil {
+		h.setFlash(c, "error", "Failed to clear reports")
+		return c.Redirect(http.StatusSeeOther, "/admin/csp")
+	}
+
+	h.logAdminAction(c, "csp_reports_clear", "system", nil, nil)
+
+	h.setFlash(c, "success", "CSP reports cleared")
+	return c.Redirect(http.StatusSeeOther, "/admin/csp")
+}
+
+// redactReportURI drops query strings and fragments, which may carry tokens,
+// and hides share link tokens so reports never store credentials.
+func redactReportURI(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		// Keywords such as "inline" or "eval"
+		return truncateString(raw, 500)
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	u.User = nil
+	if strings.HasPrefix(u.Path, "/s/") {
+		u.Path = "/s/redacted"
+		u.RawPath = ""
+	}
+	return truncateString(u.String(), 500)
+}
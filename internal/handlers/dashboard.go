@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// Dashboard renders the current user's notifications and pending review requests.
+func (h *Handlers) Dashboard(c echo.Context) error {
+	user := middleware.GetUser(c)
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	// Pages restricted to groups the user has since left drop off
+	hideRestricted, memberOf := policy.GroupFilter(user)
+
+	reviews, err := db.ListPendingReviewRequests(ctx, user.ID, hideRestricted, memberOf)
+	if err != nil {
+		reviews = []models.ReviewRequest{}
+	}
+
+	notifications, err := db.ListNotifications(ctx, user.ID, 50, hideRestricted, memberOf)
+	if err != nil {
+		notifications = []models.Notification{}
+	}
+
+	// Viewing the dashboard counts as reading everything on it
+	_ = db.MarkNotificationsRead(ctx, user.ID)
+
+	data := pages.DashboardData{
+		PageData:      h.basePageData(c, "Dashboard"),
+		Reviews:       reviews,
+		Notifications: notifications,
+	}
+
+	return render(c, http.StatusOK, pages.Dashboard(data))
+}
+
+// ApproveReview approves a review request assigned to the current user.
+func (h *Handlers) ApproveReview(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	requestID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid review request ID")
+	}
+
+	if _, err := h.wikiService.ApproveReview(c.Request().Context(), requestID, user); err != nil {
+		switch {
+		case errors.Is(err, services.ErrReviewNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Review request not found")
+		case errors.Is(err, services.ErrNotReviewer):
+			return echo.NewHTTPError(http.StatusForbidden, "Only the requested reviewer can approve")
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to approve review","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// Return empty response for HTMX to remove the row + toast
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Review approved","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// reviewerOptions returns the users who can be asked to review an edit.
+func (h *Handlers) reviewerOptions(c echo.Context) []models.User {
+	current := middleware.GetUser(c)
+	users, err := h.authService.ListUsers(c.Request().Context(), 1000, 0)
+	if err != nil {
+		return nil
+	}
+
+	var reviewers []models.User
+	for _, u := range users {
+		if u.IsActive && u.Role.CanEdit() && (current == nil || u.ID != current.ID) {
+			reviewers = append(reviewers, u)
+		}
+	}
+	return reviewers
+}
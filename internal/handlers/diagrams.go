@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/services"
+)
+
+// PlantUMLDiagram serves a PlantUML diagram rendered by the configured
+// PlantUML server. The URL carries the compressed diagram source, so
+// responses never change and can be cached for long.
+func (h *Handlers) PlantUMLDiagram(c echo.Context) error {
+	encoded := strings.TrimSuffix(c.Param("source"), ".svg")
+
+	svg, err := h.diagrams.PlantUMLSVG(c.Request().Context(), encoded)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPlantUMLDisabled):
+			return echo.NewHTTPError(http.StatusNotFound, "Diagram not found")
+		case errors.Is(err, services.ErrInvalidDiagram):
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid diagram")
+		}
+		fmt.Printf("Warning: failed to render PlantUML diagram: %v\n", err)
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to render diagram")
+	}
+
+	header := c.Response().Header()
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	// The SVG comes from another server; never let it run scripts if opened directly
+	header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	return c.Blob(http.StatusOK, "image/svg+xml", svg)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// linkSuggestLimit is how many pages wiki-link autocompletion offers.
+const linkSuggestLimit = 8
+
+// LinkSuggestion is a page offered while typing a [[wiki-link]].
+type LinkSuggestion struct {
+	Slug     string `json:"slug"`
+	Title    string `json:"title"`
+	Markdown string `json:"markdown"` // The complete link to insert
+}
+
+// LinkSuggest completes the [[wiki-link]] being typed in the editor from
+// page titles and slugs, matched like the quick switcher.
+func (h *Handlers) LinkSuggest(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit <= 0 {
+		limit = linkSuggestLimit
+	}
+	results, err := h.wikiService.QuickSearch(ctx, c.QueryParam("q"), limit, func(page *models.Page) bool {
+		return policy.CanView(user, page)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Search failed"})
+	}
+
+	suggestions := make([]LinkSuggestion, len(results))
+	for i, r := range results {
+		suggestions[i] = LinkSuggestion{
+			Slug:     r.Slug,
+			Title:    r.Title,
+			Markdown: services.WikiLinkMarkdown(r.Slug, r.Title),
+		}
+	}
+	return c.JSON(http.StatusOK, suggestions)
+}
+
+// FormatMarkdown applies a toolbar action, such as "bold" or "h2", to the
+// editor's selection and returns the new content and selection. The
+// selection is given as start and end offsets in UTF-16 code units, as the
+// textarea reports them.
+func (h *Handlers) FormatMarkdown(c echo.Context) error {
+	start, _ := strconv.Atoi(c.FormValue("start"))
+	end, _ := strconv.Atoi(c.FormValue("end"))
+
+	result, err := services.FormatMarkdown(c.FormValue("content"), start, end, c.FormValue("action"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Unknown format action")
+	}
+	return c.JSON(http.StatusOK, result)
+}
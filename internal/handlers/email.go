@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminEmailTemplates lists the emails the wiki sends and their variants.
+func (h *Handlers) AdminEmailTemplates(c echo.Context) error {
+	stored, err := h.mail.ListTemplates(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load email templates")
+	}
+
+	variants := make(map[string][]string)
+	for _, t := range stored {
+		variants[t.Key] = append(variants[t.Key], t.Locale)
+	}
+
+	data := admin.EmailTemplatesData{
+		PageData:    h.basePageData(c, "Email Templates"),
+		Emails:      h.mail.TemplateInfos(),
+		Variants:    variants,
+		MailEnabled: h.mail.Enabled(),
+	}
+
+	return render(c, http.StatusOK, admin.EmailTemplates(data))
+}
+
+// AdminEditEmailTemplate shows the editor for one email in one locale.
+func (h *Handlers) AdminEditEmailTemplate(c echo.Context) error {
+	ctx := c.Request().Context()
+	info := h.mail.TemplateInfo(c.Param("key"))
+	if info == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+	}
+
+	locale := services.NormalizeLocale(c.QueryParam("locale"))
+	t, err := h.mail.Template(ctx, info.Key, locale)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load email template")
+	}
+
+	stored, _ := h.mail.ListTemplates(ctx)
+	var locales []string
+	for _, s := range stored {
+		if s.Key == info.Key && s.Locale != "" {
+			locales = append(locales, s.Locale)
+		}
+	}
+
+	data := admin.EmailTemplateData{
+		PageData:    h.basePageData(c, info.Name+" Email"),
+		Info:        *info,
+		Template:    t,
+		Locale:      locale,
+		Locales:     locales,
+		HasVariant:  t.Customized && t.Locale == locale,
+		MailEnabled: h.mail.Enabled(),
+	}
+
+	return render(c, http.StatusOK, admin.EmailTemplate(data))
+}
+
+// AdminSaveEmailTemplate stores an edited email template.
+func (h *Handlers) AdminSaveEmailTemplate(c echo.Context) error {
+	user := middleware.GetUser(c)
+	t := emailTemplateFromForm(c)
+	editURL := emailTemplateURL(t.Key, t.Locale)
+
+	if err := h.mail.SaveTemplate(c.Request().Context(), t, user.ID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownEmailTemplate):
+			return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+		case errors.Is(err, services.ErrInvalidEmailTemplate), errors.Is(err, services.ErrInvalidLocale):
+			h.setFlash(c, "error", err.Error())
+		default:
+			h.setFlash(c, "error", "Failed to save email template")
+		}
+		return c.Redirect(http.StatusSeeOther, editURL)
+	}
+
+	h.logAdminAction(c, "email_template_update", "email_template", nil, map[string]interface{}{
+		"key":    t.Key,
+		"locale": t.Locale,
+	})
+
+	h.setFlash(c, "success", "Email template saved")
+	return c.Redirect(http.StatusSeeOther, emailTemplateURL(t.Key, t.Locale))
+}
+
+// AdminResetEmailTemplate deletes a customized template so the fallback
+// wording is used again.
+func (h *Handlers) AdminResetEmailTemplate(c echo.Context) error {
+	key := c.Param("key")
+	locale := services.NormalizeLocale(c.FormValue("locale"))
+
+	if err := h.mail.ResetTemplate(c.Request().Context(), key, locale); err != nil {
+		if errors.Is(err, services.ErrUnknownEmailTemplate) {
+			return echo.NewHTTPError(http.StatusNotFound, "Email template not found")
+		}
+		h.setFlash(c, "error", "Failed to reset email template")
+		return c.Redirect(http.StatusSeeOther, emailTemplateURL(key, locale))
+	}
+
+	h.logAdminAction(c, "email_template_reset", "email_template", nil, map[string]interface{}{
+		"key":    key,
+		"locale": locale,
+	})
+
+	if locale == "" {
+		h.setFlash(c, "success", "Email template reset to the built-in default")
+		return c.Redirect(http.StatusSeeOther, emailTemplateURL(key, ""))
+	}
+	h.setFlash(c, "success", "Locale variant removed")
+	return c.Redirect(http.StatusSeeOther, emailTemplateURL(key, ""))
+}
+
+// AdminPreviewEmailTemplate renders the editor contents with example values.
+func (h *Handlers) AdminPreviewEmailTemplate(c echo.Context) error {
+	email, err := h.mail.Preview(emailTemplateFromForm(c))
+	if err != nil {
+		return render(c, http.StatusOK, admin.EmailPreview(nil, err.Error()))
+	}
+	return render(c, http.StatusOK, admin.EmailPreview(email, ""))
+}
+
+// AdminTestEmailTemplate sends the editor contents, filled with example
+// values, to the signed-in admin.
+func (h *Handlers) AdminTestEmailTemplate(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	toast := func(status int, message, kind string) error {
+		c.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":%q,"type":%q}}`, message, kind))
+		return c.NoContent(status)
+	}
+
+	if user.Email == "" {
+		return toast(http.StatusBadRequest, "Your account has no email address", "error")
+	}
+
+	email, err := h.mail.Preview(emailTemplateFromForm(c))
+	if err != nil {
+		return toast(http.StatusBadRequest, err.Error(), "error")
+	}
+	email.Subject = "[Test] " + email.Subject
+
+	if err := h.mail.Send(c.Request().Context(), user.Email, email); err != nil {
+		if errors.Is(err, services.ErrMailDisabled) {
+			return toast(http.StatusBadRequest, "Email is not configured", "error")
+		}
+		fmt.Printf("Warning: failed to send test email: %v\n", err)
+		return toast(http.StatusBadGateway, "Failed to send test email", "error")
+	}
+
+	return toast(http.StatusOK, "Test email sent to "+user.Email, "success")
+}
+
+func emailTemplateFromForm(c echo.Context) *models.EmailTemplate {
+	return &models.EmailTemplate{
+		Key:      c.Param("key"),
+		Locale:   services.NormalizeLocale(c.FormValue("locale")),
+		Subject:  strings.TrimSpace(c.FormValue("subject")),
+		BodyText: c.FormValue("body_text"),
+		BodyHTML: c.FormValue("body_html"),
+	}
+}
+
+func emailTemplateURL(key, locale string) string {
+	u := "/admin/email/" + url.PathEscape(key)
+	if locale != "" {
+		u += "?locale=" + url.QueryEscape(locale)
+	}
+	return u
+}
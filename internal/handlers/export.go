@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// ExportPage downloads a page as markdown, standalone HTML, or a zip of the
+// page and its descendants, selected with ?format=md|html|zip.
+func (h *Handlers) ExportPage(c echo.Context) error {
+	page, err := h.wikiService.GetPage(c.Request().Context(), c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+
+	user := middleware.GetUser(c)
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	file, err := h.wikiService.ExportPage(c.Request().Context(), page, services.ExportOptions{
+		Format:   c.QueryParam("format"),
+		BaseURL:  h.config.Site.URL,
+		SiteName: h.settings.SiteName(c.Request().Context()),
+		CanView:  h.includeViewer(c),
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportFormat) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to export page")
+	}
+
+	return sendExport(c, file)
+}
+
+// sendExport writes an export as a file download.
+func sendExport(c echo.Context, file *services.ExportFile) error {
+	header := c.Response().Header()
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	header.Set("Cache-Control", "private, no-cache")
+	return c.Blob(http.StatusOK, file.ContentType, file.Data)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/components"
+	"gowiki/internal/views/pages"
+)
+
+const (
+	// fragmentMaxAge is how long shared caches may keep a public fragment.
+	fragmentMaxAge = 60
+	// Recent changes fragments list this many changes unless ?limit= says
+	// otherwise, and never more than maxFragmentChanges.
+	defaultFragmentChanges = 10
+	maxFragmentChanges     = 50
+)
+
+// SidebarFragment renders the page tree navigation on its own, for the
+// sidebar to refresh itself and for other sites to embed. ?current= marks
+// and expands the page being viewed.
+func (h *Handlers) SidebarFragment(c echo.Context) error {
+	tree, err := h.wikiService.GetDB().GetPageTree(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load pages")
+	}
+
+	// The tree only holds published, unrestricted pages
+	h.setFragmentCacheControl(c, true)
+	return renderConditional(c, components.NavTreeAt(tree, c.QueryParam("current"), h.fragmentBase(c)), time.Time{})
+}
+
+// TOCFragment renders a page's table of contents.
+func (h *Handlers) TOCFragment(c echo.Context) error {
+	ctx := c.Request().Context()
+	page, err := h.wikiService.GetPage(ctx, c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	if !policy.CanView(middleware.GetUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	// Embedded elsewhere, the anchors have to point back at the page
+	pageURL := ""
+	if base := h.fragmentBase(c); base != "" {
+		pageURL = base + "/wiki/" + page.Slug
+	}
+
+	h.setFragmentCacheControl(c, policy.CanView(nil, page))
+	return renderConditional(c, components.TOCList(h.wikiService.GenerateTOC(page.Content), pageURL), page.UpdatedAt)
+}
+
+// ChangesFragment renders the most recent changes, filtered by ?tag= and
+// ?author= like /changes, with ?limit= setting how many.
+func (h *Handlers) ChangesFragment(c echo.Context) error {
+	limit, err := strconv.Atoi(c.QueryParam("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultFragmentChanges
+	}
+	if limit > maxFragmentChanges {
+		limit = maxFragmentChanges
+	}
+
+	filter := h.changeFilter(c)
+	filter.Limit = limit
+	changes, err := h.wikiService.GetDB().ListRecentChanges(c.Request().Context(), filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load changes")
+	}
+
+	var lastModified time.Time
+	if len(changes) > 0 {
+		lastModified = changes[0].CreatedAt
+	}
+
+	// Signed-in users may see unpublished and restricted pages
+	h.setFragmentCacheControl(c, true)
+	return renderConditional(c, pages.ChangesList(changes, h.fragmentBase(c)), lastModified)
+}
+
+// fragmentBase returns the wiki's URL for fragments requested by another
+// site, so their links lead back to the wiki, and "" for the wiki itself.
+// Browsers only send Origin on cross-origin GETs.
+func (h *Handlers) fragmentBase(c echo.Context) string {
+	if c.Request().Header.Get("Origin") == "" {
+		return ""
+	}
+	return strings.TrimRight(h.config.Site.URL, "/")
+}
+
+// setFragmentCacheControl lets shared caches keep a fragment that anyone
+// could see, and makes everything else revalidate with its ETag.
+func (h *Handlers) setFragmentCacheControl(c echo.Context, public bool) {
+	header := c.Response().Header()
+	header.Add("Vary", "Origin")
+	if public && middleware.GetUser(c) == nil && !h.settings.RequireAuth(c.Request().Context()) {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(fragmentMaxAge))
+		return
+	}
+	header.Set("Cache-Control", "private, no-cache")
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+	"gowiki/internal/views/pages"
+)
+
+// AdminGroups lists the user groups and the form to add one.
+func (h *Handlers) AdminGroups(c echo.Context) error {
+	groups, err := h.groups.List(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load groups")
+	}
+
+	data := admin.GroupsData{
+		PageData: h.basePageData(c, "Groups"),
+		Groups:   groups,
+	}
+
+	return render(c, http.StatusOK, admin.Groups(data))
+}
+
+// AdminCreateGroup adds a group and opens it to add members.
+func (h *Handlers) AdminCreateGroup(c echo.Context) error {
+	group, err := h.groups.Create(c.Request().Context(), c.FormValue("name"), c.FormValue("description"))
+	if err != nil {
+		h.setGroupError(c, err, "Failed to create group")
+		return c.Redirect(http.StatusSeeOther, "/admin/groups")
+	}
+
+	h.logAdminAction(c, "group_create", "group", &group.ID, map[string]interface{}{
+		"name": group.Name,
+	})
+
+	h.setFlash(c, "success", "Group created")
+	return c.Redirect(http.StatusSeeOther, "/admin/groups/"+strconv.FormatInt(group.ID, 10))
+}
+
+// AdminGroup renders a group's settings and members.
+func (h *Handlers) AdminGroup(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	group, err := h.groupParam(c)
+	if err != nil {
+		return err
+	}
+
+	members, err := h.groups.Members(ctx, group.ID)
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load members")
+	}
+
+	data := admin.GroupData{
+		PageData: h.basePageData(c, group.Name),
+		Group:    group,
+		Members:  members,
+	}
+
+	return render(c, http.StatusOK, admin.Group(data))
+}
+
+// AdminUpdateGroup renames a group and replaces its description.
+func (h *Handlers) AdminUpdateGroup(c echo.Context) error {
+	group, err := h.groupParam(c)
+	if err != nil {
+		return err
+	}
+	redirect := "/admin/groups/" + strconv.FormatInt(group.ID, 10)
+
+	group, err = h.groups.Update(c.Request().Context(), group.ID, c.FormValue("name"), c.FormValue("description"))
+	if err != nil {
+		h.setGroupError(c, err, "Failed to update group")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+
+	h.logAdminAction(c, "group_update", "group", &group.ID, map[string]interface{}{
+		"name": group.Name,
+	})
+
+	h.setFlash(c, "success", "Group saved")
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// AdminDeleteGroup removes a group. The row is removed in place by HTMX.
+func (h *Handlers) AdminDeleteGroup(c echo.Context) error {
+	group, err := h.groupParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.groups.Delete(c.Request().Context(), group.ID); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete group","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "group_delete", "group", &group.ID, map[string]interface{}{
+		"name": group.Name,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Group deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// AdminAddGroupMember adds a user to a group by username.
+func (h *Handlers) AdminAddGroupMember(c echo.Context) error {
+	group, err := h.groupParam(c)
+	if err != nil {
+		return err
+	}
+	redirect := "/admin/groups/" + strconv.FormatInt(group.ID, 10)
+
+	user, err := h.groups.AddMember(c.Request().Context(), group.ID, c.FormValue("username"))
+	if err != nil {
+		h.setGroupError(c, err, "Failed to add member")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+
+	h.logAdminAction(c, "group_member_add", "group", &group.ID, map[string]interface{}{
+		"name":     group.Name,
+		"username": user.Username,
+	})
+
+	h.setFlash(c, "success", user.Username+" added to "+group.Name)
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// AdminRemoveGroupMember removes a user from a group. The row is removed in
+// place by HTMX.
+func (h *Handlers) AdminRemoveGroupMember(c echo.Context) error {
+	group, err := h.groupParam(c)
+	if err != nil {
+		return err
+	}
+	userID, err := strconv.ParseInt(c.Param("userId"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.groups.RemoveMember(c.Request().Context(), group.ID, userID); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to remove member","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "group_member_remove", "group", &group.ID, map[string]interface{}{
+		"name":    group.Name,
+		"user_id": userID,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Member removed","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// PageAccessForm renders whether a page is public and the groups it can be
+// restricted to.
+func (h *Handlers) PageAccessForm(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.accessPageParam(c)
+	if err != nil {
+		return err
+	}
+
+	groups, err := h.groups.List(ctx)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load groups")
+	}
+
+	db := h.wikiService.GetDB()
+	public, err := db.IsPagePublic(ctx, page.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page access")
+	}
+	inPublic, err := db.InPublicSubtree(ctx, page.Slug)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page access")
+	}
+
+	data := pages.AccessData{
+		PageData:     h.basePageData(c, "Access to "+page.Title),
+		Page:         page,
+		Groups:       groups,
+		Public:       public,
+		PublicParent: inPublic && !public,
+		PrivateWiki:  h.settings.RequireAuth(ctx),
+	}
+
+	return render(c, http.StatusOK, pages.Access(data))
+}
+
+// UpdatePageAccess marks a page public or not, and restricts it to the
+// checked groups, or lifts the restriction when none are checked.
+func (h *Handlers) UpdatePageAccess(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.accessPageParam(c)
+	if err != nil {
+		return err
+	}
+
+	var groupIDs []int64
+	if form, err := c.FormParams(); err == nil {
+		for _, v := range form["groups"] {
+			if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+				groupIDs = append(groupIDs, id)
+			}
+		}
+	}
+
+	public := c.FormValue("public") == "on"
+	if err := h.wikiService.GetDB().SetPagePublic(ctx, page.ID, public); err != nil {
+		h.setFlash(c, "error", "Failed to update page access")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+
+	groups, err := h.groups.SetPageGroups(ctx, page.ID, groupIDs)
+	if err != nil {
+		h.setFlash(c, "error", "Failed to update page access")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.Name
+	}
+	h.logAdminAction(c, "page_access", "page", &page.ID, map[string]interface{}{
+		"slug":   page.Slug,
+		"groups": names,
+		"public": public,
+	})
+
+	if len(groups) == 0 && page.IsRestricted() {
+		h.setFlash(c, "success", "Page is no longer restricted")
+	} else {
+		h.setFlash(c, "success", "Page access updated")
+	}
+	return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+}
+
+// groupParam loads the group named by the :id route parameter.
+func (h *Handlers) groupParam(c echo.Context) (*models.Group, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid group ID")
+	}
+
+	group, err := h.groups.Get(c.Request().Context(), id)
+	if errors.Is(err, services.ErrGroupNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Group not found")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load group")
+	}
+	return group, nil
+}
+
+// accessPageParam loads the page named by the :id route parameter if the
+// current user may restrict it.
+func (h *Handlers) accessPageParam(c echo.Context) (*models.Page, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+
+	page, err := h.wikiService.GetPageByID(c.Request().Context(), id)
+	if err != nil && !errors.Is(err, services.ErrPageNotFound) {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	if !policy.CanRestrict(middleware.GetUser(c), page) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	return page, nil
+}
+
+func (h *Handlers) setGroupError(c echo.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrGroupNotFound),
+		errors.Is(err, services.ErrGroupExists),
+		errors.Is(err, services.ErrGroupName),
+		errors.Is(err, services.ErrUserNotFound):
+		h.setFlash(c, "error", err.Error())
+	default:
+		h.setFlash(c, "error", fallback)
+	}
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/labstack/echo/v4"
+	echoMiddleware "github.com/labstack/echo/v4/middleware"
+	"golang.org/x/net/webdav"
 
 	"gowiki/internal/config"
 	"gowiki/internal/database"
+	"gowiki/internal/jobs"
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
 	"gowiki/internal/services"
@@ -17,8 +23,31 @@ type Handlers struct {
 	authService    *services.AuthService
 	wikiService    *services.WikiService
 	backupService  *services.BackupService
+	scheduler      *services.BackupScheduler
+	replication    *services.ReplicationService
+	cluster        *services.Cluster
+	webhooks       *services.WebhookService
+	privacy        *services.PrivacyService
+	announcements  *services.AnnouncementService
+	apiUsage       *services.APIUsageService
+	mail           *services.MailService
+	invites        *services.InviteService
+	accounts       *services.AccountService
+	userImport     *services.UserImportService
+	revisions      *services.RevisionPruner
+	freshness      *services.FreshnessService
+	roles          *services.RoleService
+	groups         *services.GroupService
+	settings       *services.SettingsService
 	sessionManager *middleware.SessionManager
 	loginLimiter   *middleware.LoginRateLimiter
+	accountLimiter *middleware.LoginRateLimiter
+	rateLimiter    *middleware.RateLimiter
+	jobs           *jobs.Scheduler
+	ipFilter       *middleware.IPFilter
+	uploadSigner   *services.UploadSigner
+	diagrams       *services.DiagramService
+	davLocks       webdav.LockSystem
 }
 
 // New creates a new Handlers instance.
@@ -27,15 +56,57 @@ func New(
 	authService *services.AuthService,
 	wikiService *services.WikiService,
 	backupService *services.BackupService,
+	scheduler *services.BackupScheduler,
+	replication *services.ReplicationService,
+	cluster *services.Cluster,
+	webhooks *services.WebhookService,
+	privacy *services.PrivacyService,
+	announcements *services.AnnouncementService,
+	apiUsage *services.APIUsageService,
+	mail *services.MailService,
+	invites *services.InviteService,
+	accounts *services.AccountService,
+	userImport *services.UserImportService,
+	revisions *services.RevisionPruner,
+	freshness *services.FreshnessService,
+	roles *services.RoleService,
+	groups *services.GroupService,
+	settings *services.SettingsService,
 	sessionManager *middleware.SessionManager,
+	rateLimiter *middleware.RateLimiter,
+	ipFilter *middleware.IPFilter,
+	jobScheduler *jobs.Scheduler,
 ) *Handlers {
 	return &Handlers{
 		config:         cfg,
 		authService:    authService,
 		wikiService:    wikiService,
 		backupService:  backupService,
+		scheduler:      scheduler,
+		replication:    replication,
+		cluster:        cluster,
+		webhooks:       webhooks,
+		privacy:        privacy,
+		announcements:  announcements,
+		apiUsage:       apiUsage,
+		mail:           mail,
+		invites:        invites,
+		accounts:       accounts,
+		userImport:     userImport,
+		revisions:      revisions,
+		freshness:      freshness,
+		roles:          roles,
+		groups:         groups,
+		settings:       settings,
 		sessionManager: sessionManager,
-		loginLimiter:   middleware.NewLoginRateLimiter(cfg.Security.LoginMaxAttempts, cfg.Security.LoginLockoutTime),
+		loginLimiter:   middleware.NewLoginRateLimiter(rateLimiter.Store(), "login", cfg.Security.LoginMaxAttempts, cfg.Security.LoginLockoutTime),
+		accountLimiter: middleware.NewLoginRateLimiter(rateLimiter.Store(), "account", cfg.Security.LoginMaxAttempts, cfg.Security.LoginLockoutTime),
+		rateLimiter:    rateLimiter,
+		ipFilter:       ipFilter,
+		jobs:           jobScheduler,
+		uploadSigner:   services.NewUploadSigner(cfg.Security.SecretKey, cfg.Upload.SignedURLTTL),
+		diagrams:       services.NewDiagramService(cfg.Diagram.PlantUMLURL, cfg.Diagram.Timeout),
+		davLocks:       webdav.NewMemLS(),
 	}
 }
 
@@ -55,14 +126,24 @@ func (h *Handlers) basePageDataWithNav(c echo.Context, title, activeNav string)
 		Info:    h.sessionManager.GetFlash(c, "info"),
 	}
 
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	announcements, err := h.announcements.Active(c.Request().Context(), userID)
+	if err != nil {
+		fmt.Printf("Warning: failed to load announcements: %v\n", err)
+	}
+
 	return layouts.PageData{
-		Title:       title,
-		SiteName:    h.config.Site.Name,
-		Description: h.config.Site.Name + " - A collaborative wiki",
-		User:        user,
-		CSRFToken:   csrfToken,
-		Flash:       flash,
-		ActiveNav:   activeNav,
+		Title:         title,
+		SiteName:      h.settings.SiteName(c.Request().Context()),
+		Description:   h.settings.SiteName(c.Request().Context()) + " - A collaborative wiki",
+		User:          user,
+		CSRFToken:     csrfToken,
+		Flash:         flash,
+		ActiveNav:     activeNav,
+		Announcements: announcements,
 	}
 }
 
@@ -74,23 +155,69 @@ func (h *Handlers) setFlash(c echo.Context, key, message string) {
 // basePageDataWithTree creates page data with page tree for sidebar navigation.
 func (h *Handlers) basePageDataWithTree(c echo.Context, title, currentSlug string) layouts.PageData {
 	data := h.basePageData(c, title)
-
-	// Get page tree for sidebar
-	ctx := c.Request().Context()
-	tree, err := h.wikiService.GetDB().GetPageTree(ctx)
-	if err == nil {
-		data.PageTree = tree
-	}
+	h.setSidebar(c, &data)
 	data.CurrentSlug = currentSlug
 
 	return data
 }
 
-// getPageTree returns the page tree for navigation.
+// setSidebar fills the sidebar with the page an admin chose as the
+// navigation, or with the page tree when there is none the viewer can see.
+func (h *Handlers) setSidebar(c echo.Context, data *layouts.PageData) {
+	if page := h.sitePage(c, services.SettingSidebarPage); page != nil {
+		data.SidebarHTML = h.pageHTML(c, page)
+		return
+	}
+	data.PageTree = h.getPageTree(c)
+}
+
+// sitePage returns the page a setting such as services.SettingHomePage
+// names, if the viewer may see it.
+func (h *Handlers) sitePage(c echo.Context, setting string) *models.Page {
+	page, err := h.wikiService.SitePage(c.Request().Context(), setting)
+	if err != nil {
+		fmt.Printf("Warning: failed to load %s: %v\n", setting, err)
+		return nil
+	}
+	if page == nil || !h.includeViewer(c)(page) {
+		return nil
+	}
+	return page
+}
+
+// getPageTree returns the page tree for navigation. Signed-out visitors of
+// a private wiki get only its public pages.
 func (h *Handlers) getPageTree(c echo.Context) []*database.PageTreeNode {
 	ctx := c.Request().Context()
 	tree, _ := h.wikiService.GetDB().GetPageTree(ctx)
-	return tree
+	if middleware.GetUser(c) != nil || !h.settings.RequireAuth(ctx) {
+		return tree
+	}
+
+	ids, err := h.wikiService.GetDB().ListPublicPageIDs(ctx)
+	if err != nil {
+		fmt.Printf("Warning: failed to list public pages: %v\n", err)
+		return nil
+	}
+	public := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		public[id] = true
+	}
+	return publicSubtrees(tree, public)
+}
+
+// publicSubtrees returns the outermost nodes of tree that are public, with
+// their subtrees. The tree is shared and isn't modified.
+func publicSubtrees(tree []*database.PageTreeNode, public map[int64]bool) []*database.PageTreeNode {
+	var roots []*database.PageTreeNode
+	for _, node := range tree {
+		if public[node.ID] {
+			roots = append(roots, node)
+		} else {
+			roots = append(roots, publicSubtrees(node.Children, public)...)
+		}
+	}
+	return roots
 }
 
 // RegisterRoutes registers all HTTP routes.
@@ -99,8 +226,30 @@ func (h *Handlers) RegisterRoutes(e *echo.Echo, sm *middleware.SessionManager, c
 	e.GET("/setup", h.SetupPage)
 	e.POST("/setup", h.SetupSubmit)
 
-	// Health check (always public)
+	// Health checks (always public); /healthz and /readyz are for
+	// Kubernetes liveness and readiness probes
 	e.GET("/health", h.HealthCheck)
+	e.GET("/healthz", h.Liveness)
+	e.GET("/readyz", h.Readiness)
+
+	// Browsers post CSP violation reports without a CSRF token
+	csrf.Exempt(middleware.CSPReportPath)
+	e.POST(middleware.CSPReportPath, h.CSPReport)
+
+	// Public wikis list their pages for crawlers
+	e.GET("/sitemap.xml", h.Sitemap)
+
+	// The theme is the same for everyone, and sign-in pages need it
+	e.GET("/theme.css", h.ThemeCSS)
+	e.GET("/theme/logo", h.ThemeLogo)
+
+	// WebDAV clients sign in with API tokens and send no CSRF token
+	csrf.ExemptPrefix(DAVPrefix)
+	e.Match(davMethods, DAVPrefix, h.WebDAV)
+	e.Match(davMethods, DAVPrefix+"/*", h.WebDAV)
+
+	// Uploads check access themselves so signed URLs work without a session
+	e.GET("/uploads/:name", h.ServeUpload)
 
 	// Shared page routes (public, no CSRF needed for viewing)
 	e.GET("/s/:token", h.ViewSharedPage)
@@ -110,13 +259,39 @@ func (h *Handlers) RegisterRoutes(e *echo.Echo, sm *middleware.SessionManager, c
 	// Share middleware validates share tokens for private wiki access
 	publicGroup := e.Group("")
 	publicGroup.Use(middleware.ShareMiddleware(h.wikiService.GetDB()))
-	publicGroup.Use(middleware.RequireAuthIfPrivate(h.config))
+	publicGroup.Use(middleware.RequireAuthIfPrivate(h.settings, h.wikiService.GetDB()))
 	publicGroup.GET("/", h.Home)
 	publicGroup.GET("/wiki/:slug", h.ViewPage)
+	publicGroup.GET("/wiki/:slug/print", h.PrintPage)
+	publicGroup.GET("/export/:slug", h.ExportPage)
+	publicGroup.GET(services.PlantUMLPath+":source", h.PlantUMLDiagram)
 	publicGroup.GET("/pages", h.ListPages)
 	publicGroup.GET("/tags", h.ListTags)
 	publicGroup.GET("/tag/:tag", h.ListPagesByTag)
 	publicGroup.GET("/search", h.Search)
+	publicGroup.GET("/quicksearch", h.QuickSearch)
+	publicGroup.GET("/changes", h.RecentChanges)
+	publicGroup.GET("/changes.atom", h.ChangesAtom)
+	publicGroup.GET("/changes.rss", h.ChangesRSS)
+	publicGroup.GET("/user/:username", h.UserProfile)
+
+	// Navigation fragments for HTMX, which the sites in WIKI_EMBED_ORIGINS
+	// may also load cross-origin
+	fragmentGroup := e.Group("/fragments")
+	fragmentMethods := []string{http.MethodGet}
+	if origins := h.config.Site.EmbedOrigins; len(origins) > 0 {
+		fragmentGroup.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
+			AllowOrigins: origins,
+			AllowMethods: []string{http.MethodGet},
+			AllowHeaders: []string{"HX-Request", "HX-Current-URL", "HX-Target", "HX-Trigger", "HX-Trigger-Name"},
+		}))
+		fragmentMethods = append(fragmentMethods, http.MethodOptions)
+	}
+	fragmentGroup.Use(middleware.ShareMiddleware(h.wikiService.GetDB()))
+	fragmentGroup.Use(middleware.RequireAuthIfPrivate(h.settings, h.wikiService.GetDB()))
+	fragmentGroup.Match(fragmentMethods, "/sidebar", h.SidebarFragment)
+	fragmentGroup.Match(fragmentMethods, "/toc/:slug", h.TOCFragment)
+	fragmentGroup.Match(fragmentMethods, "/changes", h.ChangesFragment)
 
 	// Auth routes (no auth required)
 	authGroup := e.Group("")
@@ -126,6 +301,16 @@ func (h *Handlers) RegisterRoutes(e *echo.Echo, sm *middleware.SessionManager, c
 	// Always register routes - handler checks if registration is allowed
 	authGroup.GET("/register", h.RegisterForm)
 	authGroup.POST("/register", h.Register)
+	authGroup.GET("/invite/:token", h.InviteForm)
+	authGroup.POST("/invite/:token", h.AcceptInvite)
+	authGroup.GET("/forgot", h.ForgotPasswordForm)
+	authGroup.POST("/forgot", h.ForgotPassword)
+	authGroup.GET("/reset/:token", h.ResetPasswordForm)
+	authGroup.POST("/reset/:token", h.ResetPassword)
+	authGroup.POST("/verify/resend", h.ResendVerification)
+
+	// Email verification links work whether or not the user is signed in
+	e.GET("/verify/:token", h.VerifyEmail)
 
 	// Logout (requires auth)
 	e.POST("/logout", h.Logout, middleware.RequireAuth())
@@ -133,42 +318,163 @@ func (h *Handlers) RegisterRoutes(e *echo.Echo, sm *middleware.SessionManager, c
 	// User routes (requires auth)
 	userGroup := e.Group("")
 	userGroup.Use(middleware.RequireAuth())
+	userGroup.GET("/dashboard", h.Dashboard)
+	userGroup.GET("/analytics", h.Analytics)
+	userGroup.GET(middleware.ChangePasswordPath, h.ChangePasswordForm)
+	userGroup.POST(middleware.ChangePasswordPath, h.ChangePassword)
+	userGroup.GET("/account/security", h.SessionsPage)
+	userGroup.DELETE("/account/sessions/:id", h.RevokeSession)
+	userGroup.POST("/account/sessions/revoke-others", h.RevokeOtherSessions)
+	userGroup.GET("/account/preferences", h.PreferencesPage)
+	userGroup.POST("/account/preferences", h.SavePreferences)
+	userGroup.POST("/account/theme", h.SaveThemePreference)
+	userGroup.POST("/announcements/:id/dismiss", h.DismissAnnouncement)
 	userGroup.GET("/tokens", h.TokensPage)
 	userGroup.POST("/tokens", h.CreateToken)
 	userGroup.DELETE("/tokens/:id", h.DeleteToken)
 
-	// Editor routes (requires editor role)
+	// Editor routes (requires the edit_page permission)
 	editorGroup := e.Group("")
-	editorGroup.Use(middleware.RequireRole(models.RoleEditor))
-	editorGroup.GET("/new", h.NewPageForm)
-	editorGroup.POST("/pages", h.CreatePage)
+	editorGroup.Use(middleware.RequirePermission(models.PermEditPage))
 	editorGroup.GET("/edit/:slug", h.EditPageForm)
 	editorGroup.POST("/pages/:id", h.UpdatePage)
-	editorGroup.DELETE("/pages/:id", h.DeletePage)
+	editorGroup.POST("/pages/:id/archive", h.ArchivePage)
+	editorGroup.POST("/pages/:id/unarchive", h.UnarchivePage)
+	editorGroup.POST("/pages/:id/draft/publish", h.PublishDraft)
+	editorGroup.POST("/pages/:id/draft/discard", h.DiscardDraft)
+	editorGroup.PATCH("/pages/:id/properties", h.UpdatePageProperties)
+	editorGroup.GET("/wanted", h.WantedPages)
+	editorGroup.GET("/orphans", h.OrphanPages)
+	editorGroup.POST("/orphans", h.BulkOrphanPages)
+	editorGroup.GET("/replace", h.ReplaceForm)
+	editorGroup.POST("/replace", h.Replace)
 	editorGroup.GET("/history/:slug", h.PageHistory)
 	editorGroup.GET("/revision/:id", h.ViewRevision)
 	editorGroup.POST("/revert/:id", h.RevertToRevision)
-	editorGroup.POST("/preview", h.PreviewMarkdown)
-	editorGroup.POST("/upload", h.UploadFile)
-	editorGroup.GET("/import", h.ImportMarkdownForm)
-	editorGroup.POST("/import", h.ImportMarkdown)
-
-	// Share link management (editors and admins)
-	editorGroup.GET("/shares", h.ListShares)
-	editorGroup.GET("/shares/new/:pageId", h.CreateShareForm)
-	editorGroup.POST("/shares", h.CreateShare)
-	editorGroup.POST("/shares/:id/revoke", h.RevokeShare)
-	editorGroup.DELETE("/shares/:id", h.DeleteShare)
-	editorGroup.GET("/shares/:id", h.ViewShareStats)
+	editorGroup.POST("/reviews/:id/approve", h.ApproveReview)
+	editorGroup.GET("/tags/manage", h.TagManage)
+	editorGroup.POST("/tags/manage/prune", h.TagPrune)
+	editorGroup.GET("/tags/manage/:id", h.TagEdit)
+	editorGroup.POST("/tags/manage/:id", h.TagUpdate)
+	editorGroup.POST("/tags/manage/:id/merge", h.TagMerge)
+	editorGroup.DELETE("/tags/manage/:id", h.TagDelete)
+
+	// Creating, deleting and uploading have their own permissions so roles
+	// can grant them separately from editing
+	canCreate := middleware.RequirePermission(models.PermCreatePage)
+	e.GET("/new", h.NewPageForm, canCreate)
+	e.POST("/pages", h.CreatePage, canCreate)
+	e.GET("/import", h.ImportMarkdownForm, canCreate)
+	e.POST("/import", h.ImportMarkdown, canCreate)
+	canWrite := middleware.RequirePermission(models.PermCreatePage, models.PermEditPage)
+	e.POST("/preview", h.PreviewMarkdown, canWrite)
+	e.GET("/editor/link-suggest", h.LinkSuggest, canWrite)
+	e.POST("/editor/format", h.FormatMarkdown, canWrite)
+	e.DELETE("/pages/:id", h.DeletePage, middleware.RequirePermission(models.PermDeletePage))
+	canAdminister := middleware.RequirePermission(models.PermAdminister)
+	e.GET("/pages/:id/access", h.PageAccessForm, canAdminister)
+	e.POST("/pages/:id/access", h.UpdatePageAccess, canAdminister)
+	canUpload := middleware.RequirePermission(models.PermUploadFiles)
+	e.POST("/upload", h.UploadFile, canUpload)
+	e.POST("/upload/paste", h.UploadPaste, canUpload)
+	e.GET("/uploads/:name/signed", h.SignUpload, canUpload)
+
+	// Share link management (requires the manage_shares permission)
+	shareGroup := e.Group("/shares")
+	shareGroup.Use(middleware.RequirePermission(models.PermManageShares))
+	shareGroup.GET("", h.ListShares)
+	shareGroup.GET("/new", h.CreateCollectionShareForm)
+	shareGroup.GET("/new/:pageId", h.CreateShareForm)
+	shareGroup.POST("", h.CreateShare)
+	shareGroup.POST("/:id/revoke", h.RevokeShare)
+	shareGroup.POST("/:id", h.UpdateShare)
+	shareGroup.DELETE("/:id", h.DeleteShare)
+	shareGroup.GET("/:id", h.ViewShareStats)
+
+	// User management (requires the manage_users permission)
+	canManageUsers := middleware.RequirePermission(models.PermManageUsers)
+	e.GET("/admin/users", h.AdminListUsers, canManageUsers)
+	e.POST("/admin/users", h.AdminCreateUser, canManageUsers)
+	e.GET("/admin/users/import", h.AdminImportUsersForm, canManageUsers)
+	e.POST("/admin/users/import", h.AdminImportUsers, canManageUsers)
+	e.GET("/admin/users/export", h.AdminExportUsers, canManageUsers)
+	e.POST("/admin/users/:id", h.AdminUpdateUser, canManageUsers)
+	e.DELETE("/admin/users/:id", h.AdminDeleteUser, canManageUsers)
+	e.POST("/admin/users/:id/sessions/revoke", h.AdminRevokeUserSessions, canManageUsers)
+	e.GET("/admin/groups", h.AdminGroups, canManageUsers)
+	e.POST("/admin/groups", h.AdminCreateGroup, canManageUsers)
+	e.GET("/admin/groups/:id", h.AdminGroup, canManageUsers)
+	e.POST("/admin/groups/:id", h.AdminUpdateGroup, canManageUsers)
+	e.DELETE("/admin/groups/:id", h.AdminDeleteGroup, canManageUsers)
+	e.POST("/admin/groups/:id/members", h.AdminAddGroupMember, canManageUsers)
+	e.DELETE("/admin/groups/:id/members/:userId", h.AdminRemoveGroupMember, canManageUsers)
 
 	// Admin routes
 	adminGroup := e.Group("/admin")
-	adminGroup.Use(middleware.RequireRole(models.RoleAdmin))
+	adminGroup.Use(middleware.RequirePermission(models.PermAdminister))
 	adminGroup.GET("", h.AdminDashboard)
-	adminGroup.GET("/users", h.AdminListUsers)
-	adminGroup.POST("/users", h.AdminCreateUser)
-	adminGroup.POST("/users/:id", h.AdminUpdateUser)
-	adminGroup.DELETE("/users/:id", h.AdminDeleteUser)
+	adminGroup.GET("/roles", h.AdminRoles)
+	adminGroup.POST("/roles", h.AdminCreateRole)
+	adminGroup.POST("/roles/:name", h.AdminUpdateRole)
+	adminGroup.DELETE("/roles/:name", h.AdminDeleteRole)
 	adminGroup.POST("/settings", h.AdminUpdateSettings)
+	adminGroup.GET("/appearance", h.AdminAppearance)
+	adminGroup.POST("/appearance", h.AdminSaveAppearance)
+	adminGroup.POST("/appearance/logo", h.AdminUploadLogo)
+	adminGroup.POST("/appearance/logo/remove", h.AdminRemoveLogo)
 	adminGroup.POST("/generate-backups", h.AdminGenerateBackups)
+	adminGroup.POST("/restore-backups", h.AdminRestoreBackups)
+	adminGroup.GET("/backups/drift", h.AdminBackupDrift)
+	adminGroup.GET("/backups/drift/:id", h.AdminBackupDriftPage)
+	adminGroup.POST("/backups/drift/:id/compare", h.AdminCompareUploadedFile)
+	adminGroup.POST("/backups/drift/:id/restore", h.AdminRestorePageFromFile)
+	adminGroup.POST("/backups/drift/:id/rewrite", h.AdminRewriteBackupFile)
+	adminGroup.GET("/uploads", h.AdminUploads)
+	adminGroup.POST("/uploads/merge", h.AdminMergeUploads)
+	adminGroup.POST("/uploads/:name/delete", h.AdminDeleteUpload)
+	adminGroup.GET("/security", h.AdminSecurity)
+	adminGroup.POST("/security/rules", h.AdminCreateIPRule)
+	adminGroup.DELETE("/security/rules/:id", h.AdminDeleteIPRule)
+	adminGroup.POST("/security/lockouts/unlock", h.AdminUnlockLogin)
+	adminGroup.GET("/csp", h.AdminCSPReports)
+	adminGroup.POST("/csp/clear", h.AdminClearCSPReports)
+	adminGroup.GET("/privacy", h.AdminPrivacy)
+	adminGroup.GET("/privacy/users/:id", h.AdminPrivacyUser)
+	adminGroup.GET("/privacy/users/:id/export", h.AdminExportPersonalData)
+	adminGroup.POST("/privacy/users/:id/anonymize", h.AdminAnonymizeUser)
+	adminGroup.POST("/privacy/anonymize", h.AdminRunIPRetention)
+	adminGroup.GET("/audit", h.AdminAuditLog)
+	adminGroup.GET("/audit/export", h.AdminExportAuditLog)
+	adminGroup.GET("/announcements", h.AdminAnnouncements)
+	adminGroup.POST("/announcements", h.AdminCreateAnnouncement)
+	adminGroup.DELETE("/announcements/:id", h.AdminDeleteAnnouncement)
+	adminGroup.GET("/api-usage", h.AdminAPIUsage)
+	adminGroup.GET("/jobs", h.AdminJobs)
+	adminGroup.POST("/jobs/:name/run", h.AdminRunJob)
+	adminGroup.GET("/revisions", h.AdminRevisions)
+	adminGroup.POST("/revisions/prune", h.AdminPruneRevisions)
+	adminGroup.POST("/revisions/pages/:id/compact", h.AdminCompactRevisions)
+	adminGroup.GET("/slugs", h.AdminSlugLimits)
+	adminGroup.POST("/slugs/pages/:id", h.AdminMovePage)
+	adminGroup.GET("/email", h.AdminEmailTemplates)
+	adminGroup.GET("/email/:key", h.AdminEditEmailTemplate)
+	adminGroup.POST("/email/:key", h.AdminSaveEmailTemplate)
+	adminGroup.POST("/email/:key/preview", h.AdminPreviewEmailTemplate)
+	adminGroup.POST("/email/:key/test", h.AdminTestEmailTemplate)
+	adminGroup.POST("/email/:key/reset", h.AdminResetEmailTemplate)
+	adminGroup.GET("/webhooks", h.AdminWebhooks)
+	adminGroup.POST("/webhooks", h.AdminCreateWebhook)
+	adminGroup.POST("/webhooks/:id/toggle", h.AdminToggleWebhook)
+	adminGroup.POST("/webhooks/:id/test", h.AdminTestWebhook)
+	adminGroup.DELETE("/webhooks/:id", h.AdminDeleteWebhook)
+	adminGroup.GET("/snapshots", h.AdminSnapshots)
+	adminGroup.POST("/snapshots", h.AdminCreateSnapshot)
+	adminGroup.GET("/snapshots/:name", h.AdminDownloadSnapshot)
+	adminGroup.POST("/snapshots/:name/restore", h.AdminRestoreSnapshot)
+	adminGroup.POST("/snapshots/restore/cancel", h.AdminCancelRestore)
+	adminGroup.DELETE("/snapshots/:name", h.AdminDeleteSnapshot)
+	adminGroup.GET("/database/backup", h.AdminDownloadDatabase)
+	adminGroup.POST("/database/vacuum", h.AdminVacuumDatabase)
+	adminGroup.POST("/database/checkpoint", h.AdminCheckpointDatabase)
+	adminGroup.POST("/database/integrity", h.AdminCheckDatabase)
 }
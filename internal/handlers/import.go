@@ -11,6 +11,7 @@ import (
 
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
 	"gowiki/internal/services"
 	"gowiki/internal/views/pages"
 )
@@ -26,7 +27,7 @@ func (h *Handlers) ImportMarkdownForm(c echo.Context) error {
 // ImportMarkdown handles markdown file import (supports multiple files).
 func (h *Handlers) ImportMarkdown(c echo.Context) error {
 	user := middleware.GetUser(c)
-	if user == nil || !user.Role.CanEdit() {
+	if !policy.CanCreate(user) {
 		return echo.NewHTTPError(http.StatusForbidden, "permission denied")
 	}
 
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/jobs"
+	"gowiki/internal/views/admin"
+)
+
+// AdminJobs lists the background jobs and their last runs.
+func (h *Handlers) AdminJobs(c echo.Context) error {
+	data := admin.JobsData{
+		PageData: h.basePageData(c, "Jobs"),
+		Jobs:     h.jobs.Statuses(),
+	}
+	return render(c, http.StatusOK, admin.Jobs(data))
+}
+
+// AdminRunJob runs a job on this node without waiting for its schedule.
+func (h *Handlers) AdminRunJob(c echo.Context) error {
+	name := c.Param("name")
+	if err := h.jobs.RunNow(name); errors.Is(err, jobs.ErrUnknownJob) {
+		return echo.NewHTTPError(http.StatusNotFound, "Job not found")
+	}
+
+	h.logAdminAction(c, "job_run", "system", nil, map[string]interface{}{"job": name})
+	h.setFlash(c, "success", "Started "+name)
+	return c.Redirect(http.StatusSeeOther, "/admin/jobs")
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/views/pages"
+)
+
+// WantedPages lists missing pages that [[wiki-links]] ask for and markdown
+// links that point at pages that no longer exist.
+func (h *Handlers) WantedPages(c echo.Context) error {
+	report, err := h.wikiService.GetLinkReport(c.Request().Context(), middleware.GetUser(c))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load link report")
+	}
+
+	data := pages.WantedData{
+		PageData: h.basePageData(c, "Wanted Pages"),
+		Report:   report,
+	}
+
+	return render(c, http.StatusOK, pages.Wanted(data))
+}
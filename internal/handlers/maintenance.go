@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminVacuumDatabase rebuilds the database file to reclaim free space.
+func (h *Handlers) AdminVacuumDatabase(c echo.Context) error {
+	return h.runMaintenance(c, h.scheduler.Vacuum)
+}
+
+// AdminCheckpointDatabase copies the write-ahead log into the database file.
+func (h *Handlers) AdminCheckpointDatabase(c echo.Context) error {
+	return h.runMaintenance(c, h.scheduler.Checkpoint)
+}
+
+// AdminCheckDatabase runs an integrity check of the database file.
+func (h *Handlers) AdminCheckDatabase(c echo.Context) error {
+	return h.runMaintenance(c, h.scheduler.CheckIntegrity)
+}
+
+// runMaintenance runs a maintenance operation and renders its result into
+// the snapshots page. The scheduler writes the audit entry.
+func (h *Handlers) runMaintenance(c echo.Context, op func(ctx context.Context, userID *int64, ipAddress string) (*services.MaintenanceResult, error)) error {
+	user := middleware.GetUser(c)
+
+	result, err := op(c.Request().Context(), &user.ID, c.RealIP())
+	if err != nil {
+		if errors.Is(err, services.ErrMaintenanceInProgress) {
+			c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"A snapshot or maintenance operation is already running","type":"error"}}`)
+			return c.NoContent(http.StatusConflict)
+		}
+		if errors.Is(err, services.ErrCheckpointReplicated) {
+			c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Litestream checkpoints the write-ahead log while replication is on","type":"error"}}`)
+			return c.NoContent(http.StatusConflict)
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Database maintenance failed","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return render(c, http.StatusOK, admin.MaintenanceResult(result))
+}
+
+// AdminDownloadDatabase streams a consistent copy of the live database,
+// taken while the wiki keeps running.
+func (h *Handlers) AdminDownloadDatabase(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	path, err := h.scheduler.Backup(c.Request().Context(), &user.ID, c.RealIP())
+	if err != nil {
+		if errors.Is(err, services.ErrMaintenanceInProgress) {
+			h.setFlash(c, "error", "A snapshot or maintenance operation is already running")
+		} else {
+			h.setFlash(c, "error", "Failed to back up the database")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+	}
+	defer os.Remove(path)
+
+	name := "wiki-backup-" + time.Now().UTC().Format("20060102-150405") + ".db"
+	return c.Attachment(path, name)
+}
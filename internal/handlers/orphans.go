@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// OrphanPages lists pages with no parent, no tags and no links from other
+// pages.
+func (h *Handlers) OrphanPages(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	orphans, err := h.wikiService.OrphanPages(c.Request().Context(), policy.CanViewRestricted(user))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load orphan pages")
+	}
+
+	data := pages.OrphansData{
+		PageData:  h.basePageData(c, "Orphan Pages"),
+		Pages:     orphans,
+		CanDelete: user.Role.Can(models.PermDeletePage),
+	}
+
+	return render(c, http.StatusOK, pages.Orphans(data))
+}
+
+// BulkOrphanPages tags the selected orphan pages, moves them under a
+// parent page, or deletes them. Pages the user may not change are skipped.
+func (h *Handlers) BulkOrphanPages(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	var ids []int64
+	if form, err := c.FormParams(); err == nil {
+		for _, v := range form["pages"] {
+			if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		h.setFlash(c, "error", "Select at least one page")
+		return c.Redirect(http.StatusSeeOther, "/orphans")
+	}
+
+	action := c.FormValue("action")
+	var update models.PagePropertiesUpdate
+	switch action {
+	case "tag":
+		tags := splitTags(c.FormValue("tags"))
+		if len(tags) == 0 {
+			h.setFlash(c, "error", "Enter the tags to add")
+			return c.Redirect(http.StatusSeeOther, "/orphans")
+		}
+		update.Tags = &tags
+	case "move":
+		parent := strings.TrimSpace(c.FormValue("parent"))
+		if parent == "" {
+			h.setFlash(c, "error", "Enter the page to move them under")
+			return c.Redirect(http.StatusSeeOther, "/orphans")
+		}
+		update.Parent = &parent
+	case "delete":
+		if !user.Role.Can(models.PermDeletePage) {
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid action")
+	}
+
+	done, skipped := 0, 0
+	var lastErr error
+	for _, id := range ids {
+		page, err := h.wikiService.GetPageByID(ctx, id)
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		if action == "delete" {
+			if !policy.CanDelete(user, page) {
+				skipped++
+				continue
+			}
+			if _, err := h.deletePageTree(c, page); err != nil {
+				skipped++
+				continue
+			}
+			done++
+			continue
+		}
+
+		if !policy.CanView(user, page) || !policy.CanEdit(user, page) {
+			skipped++
+			continue
+		}
+		pageUpdate := update
+		if update.Tags != nil {
+			// Keep any tags added since the report was loaded
+			tags := *update.Tags
+			for _, t := range page.Tags {
+				tags = append(tags, t.Name)
+			}
+			pageUpdate.Tags = &tags
+		}
+		oldSlug := page.Slug
+		result, err := h.wikiService.UpdatePageProperties(ctx, page, user.ID, pageUpdate)
+		if err != nil {
+			lastErr = err
+			skipped++
+			continue
+		}
+		h.backupUpdatedPage(ctx, oldSlug, result, user, "Update properties of "+result.Page.Slug)
+		h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+		h.logAdminAction(c, "page_properties", "page", &page.ID, map[string]interface{}{
+			"slug":   result.Page.Slug,
+			"fields": pageUpdate.Fields(),
+		})
+		done++
+	}
+
+	verb := map[string]string{"tag": "Tagged", "move": "Moved", "delete": "Deleted"}[action]
+	switch {
+	case skipped == 0:
+		h.setFlash(c, "success", fmt.Sprintf("%s %d %s", verb, done, plural(done, "page", "pages")))
+	case errors.Is(lastErr, services.ErrInvalidParent):
+		h.setFlash(c, "error", "No page exists at that parent slug")
+	case done == 0:
+		h.setFlash(c, "error", "None of the selected pages could be changed")
+	default:
+		h.setFlash(c, "warning", fmt.Sprintf("%s %d %s; %d could not be changed", verb, done, plural(done, "page", "pages"), skipped))
+	}
+	return c.Redirect(http.StatusSeeOther, "/orphans")
+}
+
+// splitTags splits a comma-separated tag list, dropping empty entries.
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -1,32 +1,44 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
 	"gowiki/internal/services"
+	"gowiki/internal/timefmt"
 	"gowiki/internal/views/pages"
 )
 
 // Input length limits
 const (
-	maxSlugLength    = 200
 	maxTitleLength   = 500
 	maxContentLength = 1000000 // 1MB
 	maxTagLength     = 50
 	maxTagsPerPage   = 20
+	maxCommentLength = 500
 )
 
 // Home renders the home page.
 func (h *Handlers) Home(c echo.Context) error {
 	ctx := c.Request().Context()
 
+	// A page chosen as the home page replaces the dashboard
+	if page := h.sitePage(c, services.SettingHomePage); page != nil {
+		c.SetParamNames("slug")
+		c.SetParamValues(page.Slug)
+		return h.ViewPage(c)
+	}
+
 	recentPages, err := h.wikiService.GetRecentPages(ctx, 10)
 	if err != nil {
 		recentPages = []models.PageSummary{}
@@ -47,7 +59,7 @@ func (h *Handlers) Home(c echo.Context) error {
 	}
 
 	pageData := h.basePageDataWithNav(c, "Home", "home")
-	pageData.PageTree = h.getPageTree(c)
+	h.setSidebar(c, &pageData)
 
 	data := pages.HomeData{
 		PageData:    pageData,
@@ -55,6 +67,16 @@ func (h *Handlers) Home(c echo.Context) error {
 		Stats:       pageStats,
 	}
 
+	if user := middleware.GetUser(c); user != nil {
+		filter := models.PageFilter{Limit: 5}
+		if !policy.CanViewUnpublished(user) {
+			published := true
+			filter.IsPublished = &published
+		}
+		filter.HideRestricted, filter.MemberOf = policy.GroupFilter(user)
+		data.RecentlyViewed, _ = h.wikiService.RecentlyViewed(ctx, user.ID, filter)
+	}
+
 	return render(c, http.StatusOK, pages.Home(data))
 }
 
@@ -65,9 +87,9 @@ func (h *Handlers) ViewPage(c echo.Context) error {
 	page, err := h.wikiService.GetPage(c.Request().Context(), slug)
 	if err != nil {
 		if errors.Is(err, services.ErrPageNotFound) {
-			// Check if user can edit, offer to create
+			// Offer to create the page if the user can
 			user := middleware.GetUser(c)
-			if user != nil && user.Role.CanEdit() {
+			if policy.CanCreate(user) {
 				h.setFlash(c, "info", "Page not found. Would you like to create it?")
 				return c.Redirect(http.StatusSeeOther, "/new?slug="+slug)
 			}
@@ -76,26 +98,54 @@ func (h *Handlers) ViewPage(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
 
-	// Check if page is published or user can view unpublished
 	user := middleware.GetUser(c)
-	if !page.IsPublished {
-		if user == nil || !user.Role.CanEdit() {
-			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	ctx := c.Request().Context()
+
+	// Editors see their unpublished changes flagged, and can preview them
+	var draft *models.PageDraft
+	showDraft := false
+	if policy.CanEdit(user, page) {
+		if draft, _ = h.wikiService.GetDraft(ctx, page.ID); draft != nil && c.QueryParam("draft") == "1" {
+			if page, err = h.draftPreview(page, draft); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render draft")
+			}
+			showDraft = true
 		}
 	}
 
+	page.ContentHTML = h.pageHTML(c, page)
+
 	toc := h.wikiService.GenerateTOC(page.Content)
 
 	// Get breadcrumbs (page path)
-	ctx := c.Request().Context()
 	breadcrumbs, _ := h.wikiService.GetDB().GetPagePath(ctx, page.ID)
 
 	// Get child pages
-	children, _ := h.wikiService.GetDB().GetPageChildren(ctx, page.ID)
+	hideRestricted, memberOf := policy.GroupFilter(user)
+	children, _ := h.wikiService.GetDB().GetPageChildren(ctx, page.ID, hideRestricted, memberOf)
+
+	backlinks, _ := h.wikiService.GetBacklinks(ctx, page.Slug, policy.CanViewUnpublished(user))
+
+	// Signed-out visitors of a private wiki only learn of the pages they
+	// can open
+	if user == nil && h.settings.RequireAuth(ctx) {
+		breadcrumbs = h.reachableSummaries(c, breadcrumbs)
+		children = h.reachableSummaries(c, children)
+		backlinks = h.reachableSummaries(c, backlinks)
+	}
+
+	related := h.relatedPages(c, page, children, backlinks)
 
 	pageData := h.basePageDataWithTree(c, page.Title, page.Slug)
 	pageData.TOC = toc
 	pageData.Breadcrumbs = breadcrumbs
+	pageData.Social = h.socialMeta(c, page, "/wiki/"+page.Slug, false)
+	pageData.Description = pageData.Social.Description
+	pageData.Canonical = pageData.Social.URL
 
 	data := pages.ViewData{
 		PageData:    pageData,
@@ -103,33 +153,121 @@ func (h *Handlers) ViewPage(c echo.Context) error {
 		TOC:         toc,
 		Breadcrumbs: breadcrumbs,
 		Children:    children,
+		Backlinks:   backlinks,
+		Related:     related,
+		Draft:       draft,
+		ShowDraft:   showDraft,
+	}
+	if user != nil {
+		data.Properties, _ = h.wikiService.PageProperties(ctx, page)
+	}
+
+	h.freshness.RecordView(page.ID)
+	if user != nil {
+		go h.wikiService.RecordVisit(context.Background(), user.ID, page.ID)
+	}
+	if user == nil && !h.settings.RequireAuth(ctx) && !pageData.Flash.HasAny() {
+		h.setPageCacheControl(c, page.ID)
+	} else {
+		// Revalidate with the ETag rather than guess from Last-Modified
+		c.Response().Header().Set("Cache-Control", "private, no-cache")
 	}
 
-	return render(c, http.StatusOK, pages.View(data))
+	return renderConditional(c, pages.View(data), page.UpdatedAt)
 }
 
-// ListPages renders the pages list (only root/top-level pages).
-func (h *Handlers) ListPages(c echo.Context) error {
-	ctx := c.Request().Context()
+// relatedPages suggests pages related to page for its Related panel,
+// leaving out the pages already listed as its children or backlinks.
+func (h *Handlers) relatedPages(c echo.Context, page *models.Page, listed ...[]models.PageSummary) []models.RelatedPage {
+	shown := make(map[int64]bool)
+	for _, summaries := range listed {
+		for _, s := range summaries {
+			shown[s.ID] = true
+		}
+	}
 
-	// Get only root pages (parent_id IS NULL)
-	pageList, err := h.wikiService.GetDB().GetRootPages(ctx)
+	candidates, err := h.wikiService.RelatedPages(c.Request().Context(), page, services.MaxRelatedPages, h.includeViewer(c))
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load pages")
+		fmt.Printf("Warning: failed to load related pages: %v\n", err)
+		return nil
+	}
+	var related []models.RelatedPage
+	for _, r := range candidates {
+		if !shown[r.ID] {
+			related = append(related, r)
+			if len(related) == services.DefaultRelatedPages {
+				break
+			}
+		}
+	}
+	return related
+}
+
+// pageHTML returns a page's HTML as the viewer sees it, with includes
+// expanded and links to missing pages marked.
+func (h *Handlers) pageHTML(c echo.Context, page *models.Page) string {
+	ctx := c.Request().Context()
+	html := h.wikiService.ExpandIncludes(ctx, page, h.includeViewer(c))
+
+	// Mark links to pages that don't exist yet
+	if missing, err := h.wikiService.MissingLinks(ctx, page.ID); err == nil {
+		html = services.MarkRedlinks(html, missing)
 	}
 
-	pageData := h.basePageDataWithNav(c, "All Pages", "pages")
-	pageData.PageTree = h.getPageTree(c)
+	// Anonymous viewers of a private wiki got here through a share token
+	if middleware.GetUser(c) == nil && h.settings.RequireAuth(ctx) {
+		html = h.uploadSigner.SignHTML(html)
+	}
+	return html
+}
+
+// setPageCacheControl lets shared caches keep an anonymous view of a public
+// page for as long as its edit history suggests it stays current.
+func (h *Handlers) setPageCacheControl(c echo.Context, pageID int64) {
+	f, err := h.freshness.Page(c.Request().Context(), pageID)
+	if err != nil {
+		fmt.Printf("Warning: failed to load page freshness: %v\n", err)
+		return
+	}
+	if f == nil {
+		return
+	}
+	c.Response().Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(f.MaxAge.Seconds())))
+}
 
-	data := pages.ListData{
-		PageData:   pageData,
-		Pages:      pageList,
-		TotalPages: len(pageList),
-		Page:       1,
-		PerPage:    len(pageList),
+// includeViewer decides which pages the current viewer may see through
+// {{include:...}} macros: the pages they could open directly.
+func (h *Handlers) includeViewer(c echo.Context) func(*models.Page) bool {
+	user := middleware.GetUser(c)
+	return func(page *models.Page) bool {
+		if !policy.CanView(user, page) {
+			return false
+		}
+		if user == nil && h.settings.RequireAuth(c.Request().Context()) {
+			return h.reachableAnonymously(c, page.Slug)
+		}
+		return true
 	}
+}
+
+// reachableAnonymously reports whether a signed-out visitor of a private
+// wiki may open the page with the slug: through their share token, or
+// because it is public.
+func (h *Handlers) reachableAnonymously(c echo.Context, slug string) bool {
+	db := h.wikiService.GetDB()
+	return middleware.CanAccessPageViaShare(c, db, slug) || middleware.IsPublicPage(c, db, slug)
+}
 
-	return render(c, http.StatusOK, pages.List(data))
+// reachableSummaries keeps the pages a signed-out visitor of a private
+// wiki may open.
+func (h *Handlers) reachableSummaries(c echo.Context, summaries []models.PageSummary) []models.PageSummary {
+	var kept []models.PageSummary
+	for _, s := range summaries {
+		if h.reachableAnonymously(c, s.Slug) {
+			kept = append(kept, s)
+		}
+	}
+	return kept
 }
 
 // NewPageForm renders the new page form.
@@ -159,6 +297,8 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 	slug := strings.TrimSpace(c.FormValue("slug"))
 	content := c.FormValue("content")
 	tagsStr := c.FormValue("tags")
+	publishAtStr := strings.TrimSpace(c.FormValue("publish_at"))
+	meta := models.PageMeta{Description: c.FormValue("description"), SocialImage: c.FormValue("social_image")}
 
 	var tagsList []string
 	if tagsStr != "" {
@@ -181,9 +321,6 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 	if len(title) > maxTitleLength {
 		errs["title"] = "Title must be less than 500 characters."
 	}
-	if len(slug) > maxSlugLength {
-		errs["slug"] = "URL slug must be less than 200 characters."
-	}
 	if len(content) > maxContentLength {
 		errs["content"] = "Content is too large (max 1MB)."
 	}
@@ -196,6 +333,19 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 			break
 		}
 	}
+	meta, err := services.CleanPageMeta(meta)
+	if err != nil {
+		errs["meta"] = err.Error()
+	}
+	var publishAt *time.Time
+	if publishAtStr != "" {
+		t, err := timefmt.FromContext(c.Request().Context()).ParseInput(publishAtStr)
+		if err != nil {
+			errs["publish_at"] = "Enter a date and time."
+		} else {
+			publishAt = &t
+		}
+	}
 
 	if len(errs) > 0 {
 		data := pages.EditData{
@@ -203,20 +353,23 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 			IsNew:    true,
 			Errors:   errs,
 			FormValues: pages.EditFormValues{
-				Title:   title,
-				Slug:    slug,
-				Content: content,
-				Tags:    tagsStr,
+				Title:     title,
+				Slug:      slug,
+				Content:   content,
+				Tags:      tagsStr,
+				Meta:      meta,
+				PublishAt: publishAtStr,
 			},
 		}
 		return render(c, http.StatusBadRequest, pages.Edit(data))
 	}
 
 	page, err := h.wikiService.CreatePage(c.Request().Context(), user.ID, models.PageCreate{
-		Slug:    slug,
-		Title:   title,
-		Content: content,
-		Tags:    tagsList,
+		Slug:      slug,
+		Title:     title,
+		Content:   content,
+		Tags:      tagsList,
+		PublishAt: publishAt,
 	})
 
 	if err != nil {
@@ -225,6 +378,8 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 			errs["slug"] = "A page with this URL already exists."
 		case errors.Is(err, services.ErrInvalidSlug):
 			errs["slug"] = "Invalid URL slug."
+		case errors.Is(err, services.ErrSlugTooDeep), errors.Is(err, services.ErrSlugTooLong):
+			errs["slug"] = err.Error()
 		case errors.Is(err, services.ErrInvalidTitle):
 			errs["title"] = "Title is required."
 		default:
@@ -236,10 +391,12 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 			IsNew:    true,
 			Errors:   errs,
 			FormValues: pages.EditFormValues{
-				Title:   title,
-				Slug:    slug,
-				Content: content,
-				Tags:    tagsStr,
+				Title:     title,
+				Slug:      slug,
+				Content:   content,
+				Tags:      tagsStr,
+				Meta:      meta,
+				PublishAt: publishAtStr,
 			},
 		}
 		return render(c, http.StatusBadRequest, pages.Edit(data))
@@ -249,8 +406,21 @@ func (h *Handlers) CreatePage(c echo.Context) error {
 	if h.backupService != nil {
 		pagePath := getPagePathFromSlug(page.Slug)
 		_ = h.backupService.SavePageAsMarkdown(page, user.Username, pagePath)
+		_ = h.backupService.Commit("Create "+page.Slug, user)
 	}
 
+	if meta != (models.PageMeta{}) {
+		if err := h.wikiService.SetPageMeta(c.Request().Context(), page, meta); err != nil {
+			c.Logger().Warnf("failed to save meta of page %d: %v", page.ID, err)
+		}
+	}
+
+	h.webhooks.EmitPage(c.Request().Context(), models.EventPageCreated, page, user)
+
+	if page.IsScheduled() {
+		h.setFlash(c, "success", "Page created. It will be published "+timefmt.FromContext(c.Request().Context()).Full(page.PublishAt.Time)+".")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
 	h.setFlash(c, "success", "Page created successfully!")
 	return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
 }
@@ -268,8 +438,20 @@ func (h *Handlers) EditPageForm(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
 
+	user := middleware.GetUser(c)
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	if page.IsArchived() {
+		h.setFlash(c, "error", "This page is archived. Unarchive it before editing.")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+	if !policy.CanEdit(user, page) {
+		return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+	}
+
 	// Count all descendant pages for delete warning
-	childCount := h.countDescendants(ctx, page.ID)
+	childCount := h.countDescendants(ctx, user, page.ID)
 
 	data := pages.EditData{
 		PageData:   h.basePageData(c, "Edit: "+page.Title),
@@ -280,6 +462,11 @@ func (h *Handlers) EditPageForm(c echo.Context) error {
 		FormValues: pages.EditFormValues{
 			Slug: page.Slug, // Pre-fill current slug for editing
 		},
+		Reviewers: h.reviewerOptions(c),
+	}
+	data.FormValues.Meta, _ = h.wikiService.PageMeta(ctx, page)
+	if draft, err := h.wikiService.GetDraft(ctx, page.ID); err == nil {
+		data.Draft = draft
 	}
 
 	return render(c, http.StatusOK, pages.Edit(data))
@@ -306,12 +493,18 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
+	if !policy.CanView(user, currentPage) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
 	oldSlug := currentPage.Slug
 
 	title := strings.TrimSpace(c.FormValue("title"))
 	slug := strings.TrimSpace(c.FormValue("slug"))
 	content := c.FormValue("content")
 	tagsStr := c.FormValue("tags")
+	comment := strings.TrimSpace(c.FormValue("comment"))
+	reviewerID, _ := strconv.ParseInt(c.FormValue("reviewer_id"), 10, 64)
+	meta := models.PageMeta{Description: c.FormValue("description"), SocialImage: c.FormValue("social_image")}
 
 	var tagsList []string
 	if tagsStr != "" {
@@ -327,8 +520,8 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 	if len(title) > maxTitleLength {
 		return echo.NewHTTPError(http.StatusBadRequest, "Title must be less than 500 characters")
 	}
-	if len(slug) > maxSlugLength {
-		return echo.NewHTTPError(http.StatusBadRequest, "URL slug must be less than 200 characters")
+	if len(comment) > maxCommentLength {
+		return echo.NewHTTPError(http.StatusBadRequest, "Edit comment must be less than 500 characters")
 	}
 	if len(content) > maxContentLength {
 		return echo.NewHTTPError(http.StatusBadRequest, "Content is too large (max 1MB)")
@@ -341,18 +534,58 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 			return echo.NewHTTPError(http.StatusBadRequest, "Tag names must be less than 50 characters")
 		}
 	}
+	meta, err = services.CleanPageMeta(meta)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// The publish time is only on the form while the page is unpublished;
+	// clearing it cancels the schedule
+	var publishAt *time.Time
+	if _, ok := c.Request().PostForm["publish_at"]; ok {
+		publishAt = &time.Time{}
+		if raw := strings.TrimSpace(c.FormValue("publish_at")); raw != "" {
+			t, err := timefmt.FromContext(ctx).ParseInput(raw)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, "Invalid publish time")
+			}
+			publishAt = &t
+		}
+	}
+
+	// Save Draft keeps the changes aside without touching the live page
+	if c.FormValue("draft") == "1" {
+		draft := &models.PageDraft{PageID: pageID, Title: title, Content: content, Tags: tagsList}
+		if err := h.wikiService.SaveDraft(ctx, user.ID, draft); err != nil {
+			if errors.Is(err, services.ErrPageArchived) {
+				return echo.NewHTTPError(http.StatusConflict, err.Error())
+			}
+			if errors.Is(err, services.ErrInvalidTitle) {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save draft")
+		}
+		h.setFlash(c, "success", "Draft saved. The live page is unchanged until you publish it.")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+oldSlug)
+	}
 
 	// Build update with slug if provided
 	update := models.PageUpdate{
-		Title:   &title,
-		Content: &content,
-		Tags:    tagsList,
+		Title:     &title,
+		Content:   &content,
+		Tags:      tagsList,
+		PublishAt: publishAt,
 	}
 	if slug != "" {
 		update.Slug = &slug
 	}
 
-	result, err := h.wikiService.UpdatePage(ctx, pageID, user.ID, update, "Updated via web editor")
+	revisionComment := comment
+	if revisionComment == "" {
+		revisionComment = "Updated via web editor"
+	}
+
+	result, err := h.wikiService.UpdatePage(ctx, pageID, user.ID, update, revisionComment)
 
 	if err != nil {
 		if errors.Is(err, services.ErrPageNotFound) {
@@ -361,34 +594,48 @@ func (h *Handlers) UpdatePage(c echo.Context) error {
 		if errors.Is(err, services.ErrPageExists) {
 			return echo.NewHTTPError(http.StatusBadRequest, "A page with this URL already exists")
 		}
+		if errors.Is(err, services.ErrSlugTooDeep) || errors.Is(err, services.ErrSlugTooLong) {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if errors.Is(err, services.ErrPageArchived) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update page")
 	}
 
 	page := result.Page
 
-	// Handle backup: delete old if slug changed, save new
-	if h.backupService != nil {
-		if oldSlug != page.Slug {
-			// Delete old backup at old path
-			oldPath := getPagePathFromSlug(oldSlug)
-			_ = h.backupService.DeleteBackup(oldSlug, oldPath)
+	// Older forms and clients that don't send the fields leave them alone
+	if _, ok := c.Request().PostForm["description"]; ok {
+		if err := h.wikiService.SetPageMeta(ctx, page, meta); err != nil {
+			c.Logger().Warnf("failed to save meta of page %d: %v", pageID, err)
 		}
-		// Save at new path
-		pagePath := getPagePathFromSlug(page.Slug)
-		_ = h.backupService.SavePageAsMarkdown(page, user.Username, pagePath)
+	}
 
-		// Handle cascaded slug changes for child pages
-		for _, change := range result.SlugChanges {
-			// Delete old backup
-			oldPath := getPagePathFromSlug(change.OldSlug)
-			_ = h.backupService.DeleteBackup(change.OldSlug, oldPath)
-
-			// Create new backup at new path (we need to fetch the page to get full content)
-			childPage, err := h.wikiService.GetPage(ctx, change.NewSlug)
-			if err == nil && childPage != nil {
-				newPath := getPagePathFromSlug(change.NewSlug)
-				_ = h.backupService.SavePageAsMarkdown(childPage, user.Username, newPath)
-			}
+	// Publishing from the editor publishes the draft it was loaded from
+	if c.FormValue("from_draft") == "1" {
+		if _, err := h.wikiService.GetDB().DeletePageDraft(ctx, pageID); err != nil {
+			c.Logger().Warnf("failed to remove published draft of page %d: %v", pageID, err)
+		}
+	}
+
+	// Handle backup: delete old if slug changed, save new
+	message := "Update " + page.Slug
+	if comment != "" {
+		message += "\n\n" + comment
+	}
+	h.backupUpdatedPage(ctx, oldSlug, result, user, message)
+
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, page, user)
+
+	// Notify @mentioned users and the requested reviewer
+	if comment != "" {
+		h.wikiService.NotifyMentions(ctx, page, user, comment)
+	}
+	if reviewerID > 0 {
+		if _, err := h.wikiService.RequestReview(ctx, page, user, reviewerID, comment); err != nil {
+			h.setFlash(c, "error", "Page saved, but the review request could not be created")
+			return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
 		}
 	}
 
@@ -404,7 +651,6 @@ func (h *Handlers) DeletePage(c echo.Context) error {
 	}
 
 	ctx := c.Request().Context()
-	db := h.wikiService.GetDB()
 
 	// Get the page to delete
 	page, err := h.wikiService.GetPageByID(ctx, pageID)
@@ -414,11 +660,42 @@ func (h *Handlers) DeletePage(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
+	if !policy.CanDelete(middleware.GetUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	descendants, err := h.deletePageTree(c, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete pages")
+	}
+
+	// Build flash message
+	msg := "Page deleted successfully."
+	if descendants > 0 {
+		msg = "Page and " + strconv.Itoa(descendants) + " child page(s) deleted successfully."
+	}
+	h.setFlash(c, "success", msg)
+
+	// For HTMX requests, redirect via header
+	if c.Request().Header.Get("HX-Request") == "true" {
+		c.Response().Header().Set("HX-Redirect", "/")
+		return c.NoContent(http.StatusOK)
+	}
+
+	return c.Redirect(http.StatusSeeOther, "/")
+}
+
+// deletePageTree deletes page and its descendants along with their
+// backups, and sends a webhook event for each. It returns how many
+// descendants were deleted.
+func (h *Handlers) deletePageTree(c echo.Context, page *models.Page) (int, error) {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
 
 	// Collect all pages to delete (this page + all descendants)
 	pagesToDelete := []pageInfo{{ID: page.ID, Slug: page.Slug}}
-	if err := h.collectDescendants(ctx, page.ID, &pagesToDelete); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to collect child pages")
+	if err := h.collectDescendants(ctx, middleware.GetUser(c), page.ID, &pagesToDelete); err != nil {
+		return 0, fmt.Errorf("failed to collect child pages: %w", err)
 	}
 
 	// Build list of IDs in reverse order (children first, parents last)
@@ -429,7 +706,7 @@ func (h *Handlers) DeletePage(c echo.Context) error {
 
 	// Delete all pages in a single transaction
 	if err := db.DeletePages(ctx, pageIDs); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete pages")
+		return 0, fmt.Errorf("failed to delete pages: %w", err)
 	}
 
 	// Delete backup files (after successful database deletion)
@@ -438,20 +715,20 @@ func (h *Handlers) DeletePage(c echo.Context) error {
 			pagePath := getPagePathFromSlug(p.Slug)
 			_ = h.backupService.DeleteBackup(p.Slug, pagePath)
 		}
+		_ = h.backupService.Commit("Delete "+page.Slug, middleware.GetUser(c))
 	}
 
-	// Build flash message
-	msg := "Page deleted successfully."
-	if len(pagesToDelete) > 1 {
-		msg = "Page and " + strconv.Itoa(len(pagesToDelete)-1) + " child page(s) deleted successfully."
+	// Child pages are deleted with their parent, so they get events too
+	h.webhooks.EmitPage(ctx, models.EventPageDeleted, page, middleware.GetUser(c))
+	for _, p := range pagesToDelete[1:] {
+		h.webhooks.EmitPage(ctx, models.EventPageDeleted, &models.Page{ID: p.ID, Slug: p.Slug}, middleware.GetUser(c))
 	}
-	h.setFlash(c, "success", msg)
 
-	// For HTMX requests, redirect via header
-	if c.Request().Header.Get("HX-Request") == "true" {
-		c.Response().Header().Set("HX-Redirect", "/")
-		return c.NoContent(http.StatusOK)
-	}
+	h.wikiService.Audit(ctx, "page_delete", "page", &page.ID, map[string]interface{}{
+		"slug":        page.Slug,
+		"title":       page.Title,
+		"descendants": len(pagesToDelete) - 1,
+	})
 
-	return c.Redirect(http.StatusSeeOther, "/")
+	return len(pagesToDelete) - 1, nil
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// ArchivePage archives a page. With subpages=1 the whole namespace under the
+// page is archived too.
+func (h *Handlers) ArchivePage(c echo.Context) error {
+	return h.setPageArchived(c, true)
+}
+
+// UnarchivePage restores an archived page, and its namespace with subpages=1.
+func (h *Handlers) UnarchivePage(c echo.Context) error {
+	return h.setPageArchived(c, false)
+}
+
+func (h *Handlers) setPageArchived(c echo.Context, archived bool) error {
+	pageID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+
+	ctx := c.Request().Context()
+	page, err := h.wikiService.GetPageByID(ctx, pageID)
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	if !policy.CanArchive(middleware.GetUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	withSubpages := c.FormValue("subpages") == "1"
+	changed, err := h.wikiService.ArchivePage(ctx, page.ID, archived, withSubpages)
+	if err != nil {
+		h.setFlash(c, "error", "Failed to update archive status")
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+
+	action := "page_archive"
+	verb := "Archived"
+	if !archived {
+		action = "page_unarchive"
+		verb = "Unarchived"
+	}
+	h.logAdminAction(c, action, "page", &page.ID, map[string]interface{}{
+		"slug":     page.Slug,
+		"subpages": withSubpages,
+		"changed":  changed,
+	})
+
+	if withSubpages {
+		h.setFlash(c, "success", fmt.Sprintf("%s %d pages under %s", verb, changed, page.Slug))
+	} else {
+		h.setFlash(c, "success", verb+" "+page.Title)
+	}
+	return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// draftPreview returns a copy of page showing draft in place of the live
+// title, content and tags.
+func (h *Handlers) draftPreview(page *models.Page, draft *models.PageDraft) (*models.Page, error) {
+	contentHTML, err := h.wikiService.RenderMarkdown(page.Slug, draft.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := *page
+	preview.Title = draft.Title
+	preview.Content = draft.Content
+	preview.ContentHTML = contentHTML
+	preview.WordCount = h.wikiService.WordCount(draft.Content)
+	preview.Tags = make([]models.Tag, len(draft.Tags))
+	for i, name := range draft.Tags {
+		preview.Tags[i] = models.Tag{Name: name}
+	}
+	return &preview, nil
+}
+
+// PublishDraft makes a page's unpublished changes live.
+func (h *Handlers) PublishDraft(c echo.Context) error {
+	page, err := h.draftPage(c)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+	result, err := h.wikiService.PublishDraft(ctx, page.ID, user.ID, "")
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoDraft):
+			h.setFlash(c, "info", "This page has no unpublished changes")
+		case errors.Is(err, services.ErrPageArchived):
+			h.setFlash(c, "error", err.Error())
+		default:
+			h.setFlash(c, "error", "Failed to publish changes")
+		}
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+
+	h.backupUpdatedPage(ctx, page.Slug, result, user, "Update "+result.Page.Slug+"\n\nPublished draft")
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+
+	h.setFlash(c, "success", "Changes published")
+	return c.Redirect(http.StatusSeeOther, "/wiki/"+result.Page.Slug)
+}
+
+// DiscardDraft throws away a page's unpublished changes.
+func (h *Handlers) DiscardDraft(c echo.Context) error {
+	page, err := h.draftPage(c)
+	if err != nil {
+		return err
+	}
+
+	user := middleware.GetUser(c)
+	if err := h.wikiService.DiscardDraft(c.Request().Context(), page.ID, user.ID); err != nil {
+		if errors.Is(err, services.ErrNoDraft) {
+			h.setFlash(c, "info", "This page has no unpublished changes")
+		} else {
+			h.setFlash(c, "error", "Failed to discard changes")
+		}
+		return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+	}
+
+	h.setFlash(c, "success", "Unpublished changes discarded")
+	return c.Redirect(http.StatusSeeOther, "/wiki/"+page.Slug)
+}
+
+// draftPage loads the page named by the :id parameter for a draft action,
+// hiding it from users who can't edit it.
+func (h *Handlers) draftPage(c echo.Context) (*models.Page, error) {
+	pageID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+
+	page, err := h.wikiService.GetPageByID(c.Request().Context(), pageID)
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	user := middleware.GetUser(c)
+	if user == nil || !policy.CanEdit(user, page) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	return page, nil
+}
@@ -8,12 +8,16 @@ import (
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/middleware"
-	"gowiki/internal/models"
+	"gowiki/internal/policy"
 	"gowiki/internal/services"
 	"gowiki/internal/views/pages"
 )
 
-// PageHistory renders page revision history.
+// historyPerPage is the number of revisions per page history page.
+const historyPerPage = 50
+
+// PageHistory renders page revision history, filtered by author and date
+// range from the query.
 func (h *Handlers) PageHistory(c echo.Context) error {
 	slug := c.Param("slug")
 	ctx := c.Request().Context()
@@ -25,16 +29,44 @@ func (h *Handlers) PageHistory(c echo.Context) error {
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
+	if !policy.CanView(middleware.GetUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
 
-	revisions, err := h.wikiService.GetPageRevisions(ctx, page.ID, 50, 0)
-	if err != nil {
-		revisions = []models.RevisionSummary{}
+	pageNum, _ := strconv.Atoi(c.QueryParam("page"))
+	if pageNum < 1 {
+		pageNum = 1
 	}
 
 	data := pages.HistoryData{
-		PageData:  h.basePageData(c, "History: "+page.Title),
-		Page:      page,
-		Revisions: revisions,
+		PageData: h.basePageData(c, "History: "+page.Title),
+		Page:     page,
+		PageNum:  pageNum,
+		PerPage:  historyPerPage,
+		Query:    c.QueryParams(),
+	}
+
+	data.Authors, err = h.wikiService.ListRevisionAuthors(ctx, page.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load history")
+	}
+
+	filter, err := services.ParseRevisionFilter(c.QueryParams())
+	if err != nil {
+		data.Error = "Invalid date filter: " + err.Error()
+		return render(c, http.StatusBadRequest, pages.History(data))
+	}
+
+	data.Total, err = h.wikiService.CountPageRevisions(ctx, page.ID, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load history")
+	}
+
+	filter.Limit = historyPerPage
+	filter.Offset = (pageNum - 1) * historyPerPage
+	data.Revisions, err = h.wikiService.GetPageRevisions(ctx, page.ID, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load history")
 	}
 
 	return render(c, http.StatusOK, pages.History(data))
@@ -61,8 +93,11 @@ func (h *Handlers) ViewRevision(c echo.Context) error {
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
 	}
+	if !policy.CanView(middleware.GetUser(c), page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Revision not found")
+	}
 
-	contentHTML, err := h.wikiService.RenderMarkdown(rev.Content)
+	contentHTML, err := h.wikiService.RenderMarkdown(page.Slug, rev.Content)
 	if err != nil {
 		contentHTML = "<p>Failed to render content</p>"
 	}
@@ -91,6 +126,9 @@ func (h *Handlers) RevertToRevision(c echo.Context) error {
 
 	page, err := h.wikiService.RevertToRevision(c.Request().Context(), revID, user.ID)
 	if err != nil {
+		if errors.Is(err, services.ErrPageArchived) {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to revert")
 	}
 
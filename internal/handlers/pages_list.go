@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/views/pages"
+)
+
+const pagesPerListPage = 24
+
+// ListPages renders the pages list.
+func (h *Handlers) ListPages(c echo.Context) error {
+	return h.listPages(c, strings.TrimSpace(c.QueryParam("tag")))
+}
+
+// ListPagesByTag renders pages with a specific tag.
+func (h *Handlers) ListPagesByTag(c echo.Context) error {
+	return h.listPages(c, c.Param("tag"))
+}
+
+// listPages renders the pages list in the display mode, sort order and
+// filters named in the query string, so every view has a shareable URL.
+// HTMX requests targeting the results get just the results back.
+func (h *Handlers) listPages(c echo.Context, tag string) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	opts := pages.ListOptions{
+		View:   c.QueryParam("view"),
+		Sort:   c.QueryParam("sort"),
+		Dir:    c.QueryParam("dir"),
+		Tag:    tag,
+		Author: strings.TrimSpace(c.QueryParam("author")),
+
+		Archived: c.QueryParam("archived") == "1",
+	}
+	opts.Page, _ = strconv.Atoi(c.QueryParam("page"))
+	opts.Normalize()
+
+	data := pages.ListData{
+		ListOptions: opts,
+		PerPage:     pagesPerListPage,
+	}
+	data.User = user
+
+	if opts.View == pages.ListViewTree {
+		data.Tree = h.getPageTree(c)
+	} else {
+		filter := models.PageFilter{
+			Limit:    pagesPerListPage,
+			Offset:   (opts.Page - 1) * pagesPerListPage,
+			OrderBy:  pages.ListSortColumns[opts.Sort],
+			OrderDir: opts.Dir,
+
+			ArchivedOnly: opts.Archived,
+		}
+		if !policy.CanViewUnpublished(user) {
+			published := true
+			filter.IsPublished = &published
+		}
+		filter.HideRestricted, filter.MemberOf = policy.GroupFilter(user)
+		if opts.Tag != "" {
+			filter.Tag = &opts.Tag
+		}
+		if opts.Author != "" {
+			filter.Author = &opts.Author
+		}
+
+		var err error
+		if data.Total, err = h.wikiService.GetDB().CountFilteredPages(ctx, filter); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load pages")
+		}
+		if data.Pages, err = h.wikiService.GetDB().ListPages(ctx, filter); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load pages")
+		}
+	}
+
+	header := c.Request().Header
+	if header.Get("HX-Request") == "true" && header.Get("HX-Target") == "page-results" && header.Get("HX-History-Restore-Request") != "true" {
+		return render(c, http.StatusOK, pages.ListResults(data))
+	}
+
+	title := "All Pages"
+	if opts.Tag != "" {
+		title = "Tag: " + opts.Tag
+	} else if opts.Archived {
+		title = "Archived Pages"
+	}
+	data.PageData = h.basePageDataWithNav(c, title, "pages")
+	h.setSidebar(c, &data.PageData)
+
+	return render(c, http.StatusOK, pages.List(data))
+}
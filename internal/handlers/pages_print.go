@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// PrintPage renders a page laid out for paper or PDF, without the site's
+// navigation. Its subpages are listed by title, or with ?children=1
+// printed in full after it, each starting a new sheet.
+func (h *Handlers) PrintPage(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	page, err := h.wikiService.GetPage(ctx, c.Param("slug"))
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+
+	user := middleware.GetUser(c)
+	if !policy.CanView(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	page.ContentHTML = h.pageHTML(c, page)
+
+	hideRestricted, memberOf := policy.GroupFilter(user)
+	children, _ := h.wikiService.GetDB().GetPageChildren(ctx, page.ID, hideRestricted, memberOf)
+	if user == nil && h.settings.RequireAuth(ctx) {
+		children = h.reachableSummaries(c, children)
+	}
+
+	data := pages.PrintData{
+		Page:     page,
+		TOC:      h.wikiService.GenerateTOC(page.Content),
+		Children: children,
+		SiteName: h.settings.SiteName(ctx),
+		URL:      strings.TrimRight(h.config.Site.URL, "/") + "/wiki/" + page.Slug,
+	}
+
+	if c.QueryParam("children") == "1" {
+		data.ExpandChildren = true
+		for _, child := range children {
+			subpage, err := h.wikiService.GetPage(ctx, child.Slug)
+			if err != nil || !policy.CanView(user, subpage) {
+				continue
+			}
+			subpage.ContentHTML = h.pageHTML(c, subpage)
+			data.Subpages = append(data.Subpages, subpage)
+		}
+	}
+
+	c.Response().Header().Set("Cache-Control", "private, no-cache")
+	return render(c, http.StatusOK, pages.Print(data))
+}
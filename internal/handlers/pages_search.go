@@ -7,17 +7,39 @@ import (
 
 	"github.com/labstack/echo/v4"
 
+	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
 	"gowiki/internal/views/pages"
 )
 
+// The search dropdown shows dropdownResults pages, picked from the best
+// dropdownCandidates matches for signed-in users.
+const (
+	dropdownResults    = 5
+	dropdownCandidates = 20
+)
+
 // Search handles search queries.
 func (h *Handlers) Search(c echo.Context) error {
 	query := strings.TrimSpace(c.QueryParam("q"))
 
 	// For HTMX dropdown requests
 	if c.Request().Header.Get("HX-Request") == "true" {
-		results, _ := h.wikiService.Search(c.Request().Context(), query, 5)
+		ctx := c.Request().Context()
+		user := middleware.GetUser(c)
+		limit := dropdownResults
+		if user != nil {
+			limit = dropdownCandidates
+		}
+		results, _ := h.wikiService.Search(ctx, query, limit, false)
+		if user != nil {
+			// Pages the user viewed lately jump ahead of better text matches
+			results = h.wikiService.BoostRecentlyViewed(ctx, user.ID, results)
+			if len(results) > dropdownResults {
+				results = results[:dropdownResults]
+			}
+		}
 		if results == nil {
 			results = []models.SearchResult{}
 		}
@@ -25,20 +47,41 @@ func (h *Handlers) Search(c echo.Context) error {
 	}
 
 	// Full search page
-	results, _ := h.wikiService.Search(c.Request().Context(), query, 50)
+	includeArchived := c.QueryParam("archived") == "1"
+	results, _ := h.wikiService.Search(c.Request().Context(), query, 50, includeArchived)
 	if results == nil {
 		results = []models.SearchResult{}
 	}
 
 	data := pages.SearchData{
-		PageData: h.basePageData(c, "Search: "+query),
-		Query:    query,
-		Results:  results,
+		PageData:        h.basePageData(c, "Search: "+query),
+		Query:           query,
+		Results:         results,
+		IncludeArchived: includeArchived,
 	}
 
 	return render(c, http.StatusOK, pages.Search(data))
 }
 
+// QuickSearch returns the pages whose titles or slugs match as JSON, for
+// the Ctrl+K quick switcher.
+func (h *Handlers) QuickSearch(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+
+	results, err := h.wikiService.QuickSearch(ctx, c.QueryParam("q"), limit, func(page *models.Page) bool {
+		return policy.CanView(user, page)
+	})
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Search failed"})
+	}
+	if user != nil {
+		results = h.wikiService.BoostRecentlyViewed(ctx, user.ID, results)
+	}
+	return c.JSON(http.StatusOK, results)
+}
+
 // ListTags renders the tags page.
 func (h *Handlers) ListTags(c echo.Context) error {
 	tags, err := h.wikiService.GetAllTags(c.Request().Context())
@@ -47,7 +90,7 @@ func (h *Handlers) ListTags(c echo.Context) error {
 	}
 
 	pageData := h.basePageDataWithNav(c, "Tags", "tags")
-	pageData.PageTree = h.getPageTree(c)
+	h.setSidebar(c, &pageData)
 
 	data := pages.TagsData{
 		PageData: pageData,
@@ -56,32 +99,3 @@ func (h *Handlers) ListTags(c echo.Context) error {
 
 	return render(c, http.StatusOK, pages.Tags(data))
 }
-
-// ListPagesByTag renders pages with a specific tag.
-func (h *Handlers) ListPagesByTag(c echo.Context) error {
-	tag := c.Param("tag")
-	pageNum, _ := strconv.Atoi(c.QueryParam("page"))
-	if pageNum < 1 {
-		pageNum = 1
-	}
-	perPage := 20
-
-	pageList, err := h.wikiService.GetPagesByTag(c.Request().Context(), tag, perPage, (pageNum-1)*perPage)
-	if err != nil {
-		pageList = []models.PageSummary{}
-	}
-
-	pageData := h.basePageDataWithNav(c, "Tag: "+tag, "tags")
-	pageData.PageTree = h.getPageTree(c)
-
-	data := pages.ListData{
-		PageData:   pageData,
-		Pages:      pageList,
-		TotalPages: len(pageList), // Simplified
-		Page:       pageNum,
-		PerPage:    perPage,
-		Tag:        tag,
-	}
-
-	return render(c, http.StatusOK, pages.List(data))
-}
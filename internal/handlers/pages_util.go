@@ -6,13 +6,20 @@ import (
 	"strings"
 
 	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/components"
 )
 
-// PreviewMarkdown renders markdown preview.
+// PreviewMarkdown renders markdown preview. Relative links resolve against
+// the slug in the form, if any.
 func (h *Handlers) PreviewMarkdown(c echo.Context) error {
 	content := c.FormValue("content")
+	slug := services.Slugify(c.FormValue("slug"))
 
-	html, err := h.wikiService.RenderMarkdown(content)
+	html, err := h.wikiService.RenderMarkdown(slug, content)
 	if err != nil {
 		return c.HTML(http.StatusOK, "<p class='text-red-500'>Failed to render markdown</p>")
 	}
@@ -21,40 +28,99 @@ func (h *Handlers) PreviewMarkdown(c echo.Context) error {
 }
 
 // HealthCheck returns server health status.
+// Replication falling behind is reported as degraded without failing the check,
+// since the wiki itself is still serving requests.
 func (h *Handlers) HealthCheck(c echo.Context) error {
+	if !h.replication.Enabled() {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	}
+
+	replication := h.replication.Status()
+	status := "ok"
+	if !replication.Healthy {
+		status = "degraded"
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status":      status,
+		"replication": replication,
+	})
+}
+
+// Liveness answers the /healthz probe. It only shows the process can
+// serve requests, so orchestrators restart it when it hangs but not when
+// a dependency is down.
+func (h *Handlers) Liveness(c echo.Context) error {
 	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// Readiness answers the /readyz probe with the result of each dependency
+// check, failing with 503 when any check fails so load balancers stop
+// sending requests here. Warnings, such as replication falling behind,
+// leave the server ready.
+func (h *Handlers) Readiness(c echo.Context) error {
+	ctx := c.Request().Context()
+	checks := services.RunReadiness(ctx, h.wikiService.GetDB(), h.config)
+	if h.replication.Enabled() {
+		replication := h.replication.Status()
+		check := services.DoctorCheck{Name: "Replication", Detail: "healthy"}
+		if !replication.Healthy {
+			check.Status = services.CheckWarn
+			check.Detail = "replication is not running or is behind"
+		}
+		checks = append(checks, check)
+	}
+
+	status := services.CheckOK
+	for _, check := range checks {
+		status = max(status, check.Status)
+	}
+	code := http.StatusOK
+	if status == services.CheckFail {
+		code = http.StatusServiceUnavailable
+	}
+
+	version, _ := h.wikiService.GetDB().CurrentVersion(ctx)
+	return c.JSON(code, map[string]interface{}{
+		"status":         status,
+		"schema_version": version,
+		"checks":         checks,
+	})
+}
+
 // pageInfo holds basic page info for deletion.
 type pageInfo struct {
 	ID   int64
 	Slug string
 }
 
-// countDescendants counts all descendant pages recursively.
-func (h *Handlers) countDescendants(ctx context.Context, parentID int64) int {
-	children, err := h.wikiService.GetDB().GetPageChildren(ctx, parentID)
+// countDescendants counts the descendant pages user can see recursively.
+func (h *Handlers) countDescendants(ctx context.Context, user *models.User, parentID int64) int {
+	hideRestricted, memberOf := policy.GroupFilter(user)
+	children, err := h.wikiService.GetDB().GetPageChildren(ctx, parentID, hideRestricted, memberOf)
 	if err != nil {
 		return 0
 	}
 
 	count := len(children)
 	for _, child := range children {
-		count += h.countDescendants(ctx, child.ID)
+		count += h.countDescendants(ctx, user, child.ID)
 	}
 	return count
 }
 
-// collectDescendants recursively collects all descendant pages.
-func (h *Handlers) collectDescendants(ctx context.Context, parentID int64, pages *[]pageInfo) error {
-	children, err := h.wikiService.GetDB().GetPageChildren(ctx, parentID)
+// collectDescendants recursively collects the descendant pages user can
+// see. Restricted subpages hidden from them are left in place, and become
+// top-level pages when their parent is deleted.
+func (h *Handlers) collectDescendants(ctx context.Context, user *models.User, parentID int64, pages *[]pageInfo) error {
+	hideRestricted, memberOf := policy.GroupFilter(user)
+	children, err := h.wikiService.GetDB().GetPageChildren(ctx, parentID, hideRestricted, memberOf)
 	if err != nil {
 		return err
 	}
 
 	for _, child := range children {
 		*pages = append(*pages, pageInfo{ID: child.ID, Slug: child.Slug})
-		if err := h.collectDescendants(ctx, child.ID, pages); err != nil {
+		if err := h.collectDescendants(ctx, user, child.ID, pages); err != nil {
 			return err
 		}
 	}
@@ -72,3 +138,69 @@ func getPagePathFromSlug(slug string) []string {
 	}
 	return parts[:len(parts)-1]
 }
+
+// backupUpdatedPage rewrites the markdown backup of an updated page and of
+// any subpages its move cascaded to, removing the files at their old
+// paths, then commits them with message.
+func (h *Handlers) backupUpdatedPage(ctx context.Context, oldSlug string, result *services.UpdateResult, user *models.User, message string) {
+	if h.backupService == nil {
+		return
+	}
+	page := result.Page
+
+	if oldSlug != page.Slug {
+		// Delete old backup at old path
+		oldPath := getPagePathFromSlug(oldSlug)
+		_ = h.backupService.DeleteBackup(oldSlug, oldPath)
+	}
+	// Save at new path
+	pagePath := getPagePathFromSlug(page.Slug)
+	_ = h.backupService.SavePageAsMarkdown(page, user.Username, pagePath)
+
+	// Handle cascaded slug changes for child pages
+	for _, change := range result.SlugChanges {
+		// Delete old backup
+		oldPath := getPagePathFromSlug(change.OldSlug)
+		_ = h.backupService.DeleteBackup(change.OldSlug, oldPath)
+
+		// Create new backup at new path (we need to fetch the page to get full content)
+		childPage, err := h.wikiService.GetPage(ctx, change.NewSlug)
+		if err == nil && childPage != nil {
+			newPath := getPagePathFromSlug(change.NewSlug)
+			_ = h.backupService.SavePageAsMarkdown(childPage, user.Username, newPath)
+		}
+	}
+
+	_ = h.backupService.Commit(message, user)
+}
+
+// socialMeta describes page to search engines and link previews, with path
+// as its address on the wiki. The description falls back to the page's
+// excerpt. Share link viewers have no session, so signed is set for them
+// to sign an uploaded image.
+func (h *Handlers) socialMeta(c echo.Context, page *models.Page, path string, signed bool) *components.SocialMeta {
+	meta, err := h.wikiService.PageMeta(c.Request().Context(), page)
+	if err != nil {
+		c.Logger().Warnf("failed to load meta of page %d: %v", page.ID, err)
+	}
+	if meta.Description == "" {
+		meta.Description = page.Excerpt
+	}
+
+	base := strings.TrimRight(h.config.Site.URL, "/")
+	image := meta.SocialImage
+	if name, ok := strings.CutPrefix(image, "/uploads/"); ok && signed {
+		image, _ = h.uploadSigner.Sign(name)
+	}
+	if strings.HasPrefix(image, "/") {
+		image = base + image
+	}
+
+	return &components.SocialMeta{
+		SiteName:    h.settings.SiteName(c.Request().Context()),
+		Title:       page.Title,
+		Description: meta.Description,
+		URL:         base + path,
+		Image:       image,
+	}
+}
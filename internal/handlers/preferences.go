@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/i18n"
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/timefmt"
+	"gowiki/internal/views/pages"
+)
+
+// PreferencesPage shows the current user's display preferences.
+func (h *Handlers) PreferencesPage(c echo.Context) error {
+	user := middleware.GetUser(c)
+	return render(c, http.StatusOK, pages.Preferences(h.preferencesData(c, pages.PreferencesData{
+		Language: user.Language,
+		Timezone: user.Timezone,
+		Locale:   user.Locale,
+		Theme:    user.Theme,
+	})))
+}
+
+// SavePreferences stores the language the current user reads the wiki in,
+// the timezone and locale they read times in, and their color scheme.
+// Empty values fall back to the browser, the site defaults and the
+// system's color scheme.
+func (h *Handlers) SavePreferences(c echo.Context) error {
+	user := middleware.GetUser(c)
+	prefs := pages.PreferencesData{
+		Language: strings.TrimSpace(c.FormValue("language")),
+		Timezone: strings.TrimSpace(c.FormValue("timezone")),
+		Locale:   strings.TrimSpace(c.FormValue("locale")),
+		Theme:    c.FormValue("theme"),
+	}
+	if !models.ValidTheme(prefs.Theme) {
+		prefs.Theme = ""
+	}
+
+	ctx := c.Request().Context()
+	if prefs.Language != "" {
+		tag, ok := i18n.Supported(prefs.Language)
+		if !ok {
+			prefs.Language = ""
+			prefs.Error = i18n.T(ctx, "Unsupported language.")
+			return render(c, http.StatusBadRequest, pages.Preferences(h.preferencesData(c, prefs)))
+		}
+		prefs.Language = tag
+	}
+	if prefs.Timezone != "" && !timefmt.ValidZone(prefs.Timezone) {
+		prefs.Error = i18n.T(ctx, "Unknown timezone %s. Use an IANA name such as Europe/Berlin.", prefs.Timezone)
+		return render(c, http.StatusBadRequest, pages.Preferences(h.preferencesData(c, prefs)))
+	}
+	if prefs.Locale != "" {
+		l, ok := timefmt.FindLocale(prefs.Locale)
+		if !ok {
+			prefs.Locale = ""
+			prefs.Error = i18n.T(ctx, "Unsupported date format.")
+			return render(c, http.StatusBadRequest, pages.Preferences(h.preferencesData(c, prefs)))
+		}
+		prefs.Locale = l.Tag
+	}
+
+	if err := h.wikiService.GetDB().UpdateUser(ctx, user.ID, &models.UserUpdate{
+		Language: &prefs.Language,
+		Timezone: &prefs.Timezone,
+		Locale:   &prefs.Locale,
+		Theme:    &prefs.Theme,
+	}); err != nil {
+		h.setFlash(c, "error", "Failed to save preferences")
+		return c.Redirect(http.StatusSeeOther, "/account/preferences")
+	}
+
+	h.logAdminAction(c, "preferences_update", "user", &user.ID, map[string]interface{}{
+		"language": prefs.Language,
+		"timezone": prefs.Timezone,
+		"locale":   prefs.Locale,
+		"theme":    prefs.Theme,
+	})
+
+	h.setFlash(c, "success", "Preferences saved")
+	return c.Redirect(http.StatusSeeOther, "/account/preferences")
+}
+
+// preferencesData fills in the page data and site defaults around the
+// user's preferences.
+func (h *Handlers) preferencesData(c echo.Context, prefs pages.PreferencesData) pages.PreferencesData {
+	prefs.PageData = h.basePageData(c, i18n.T(c.Request().Context(), "Preferences"))
+	prefs.DefaultLanguage = h.config.Site.Language
+	prefs.DefaultTimezone = h.config.Site.Timezone
+	prefs.DefaultLocale = h.config.Site.Locale
+	return prefs
+}
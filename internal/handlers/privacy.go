@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminPrivacy searches users for data-protection requests and shows the
+// IP retention job status.
+func (h *Handlers) AdminPrivacy(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+
+	var users []models.User
+	if query != "" {
+		var err error
+		users, err = h.wikiService.GetDB().SearchUsers(c.Request().Context(), query, 50)
+		if err != nil {
+			h.setFlash(c, "error", "Failed to search users")
+		}
+	}
+
+	data := admin.PrivacyData{
+		PageData:  h.basePageData(c, "Privacy"),
+		Query:     query,
+		Users:     users,
+		Retention: h.privacy.Status(),
+	}
+
+	return render(c, http.StatusOK, admin.Privacy(data))
+}
+
+// AdminPrivacyUser shows the personal data stored about a user.
+func (h *Handlers) AdminPrivacyUser(c echo.Context) error {
+	personal, err := h.personalData(c)
+	if err != nil {
+		return err
+	}
+
+	data := admin.PrivacyUserData{
+		PageData: h.basePageData(c, "Personal Data: "+personal.User.Username),
+		Personal: personal,
+	}
+
+	return render(c, http.StatusOK, admin.PrivacyUser(data))
+}
+
+// AdminExportPersonalData downloads a user's personal data as JSON.
+func (h *Handlers) AdminExportPersonalData(c echo.Context) error {
+	personal, err := h.personalData(c)
+	if err != nil {
+		return err
+	}
+
+	h.logAdminAction(c, "personal_data_export", "user", &personal.User.ID, nil)
+
+	filename := fmt.Sprintf("personal-data-%s-%s.json", personal.User.Username, time.Now().UTC().Format("20060102"))
+	c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Response().Header().Set("Cache-Control", "no-store")
+	return c.JSONPretty(http.StatusOK, personal, "  ")
+}
+
+// AdminAnonymizeUser anonymizes every IP address recorded for a user.
+func (h *Handlers) AdminAnonymizeUser(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+	redirect := "/admin/privacy/users/" + c.Param("id")
+
+	n, err := h.privacy.AnonymizeUser(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		h.setFlash(c, "error", "Failed to anonymize IP addresses")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+
+	h.logAdminAction(c, "user_ips_anonymize", "user", &id, map[string]interface{}{
+		"rows": n,
+	})
+
+	h.setFlash(c, "success", fmt.Sprintf("Anonymized %d IP address records", n))
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// AdminRunIPRetention runs the IP retention job immediately.
+func (h *Handlers) AdminRunIPRetention(c echo.Context) error {
+	n, err := h.privacy.AnonymizeExpired(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to anonymize IP addresses")
+		return c.Redirect(http.StatusSeeOther, "/admin/privacy")
+	}
+
+	h.logAdminAction(c, "ip_retention_run", "system", nil, map[string]interface{}{
+		"rows": n,
+	})
+
+	h.setFlash(c, "success", fmt.Sprintf("Anonymized %d IP address records", n))
+	return c.Redirect(http.StatusSeeOther, "/admin/privacy")
+}
+
+func (h *Handlers) personalData(c echo.Context) (*models.PersonalData, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	personal, err := h.privacy.Collect(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "User not found")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load personal data")
+	}
+	return personal, nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/views/pages"
+)
+
+// profileEntries is how many recent edits and created pages a profile lists.
+const profileEntries = 20
+
+// UserProfile renders a user's profile with their recent edits, the pages
+// they created, and contribution counts. Readers who can't view unpublished
+// pages only see activity on published ones.
+func (h *Handlers) UserProfile(c echo.Context) error {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	profile, err := db.GetUserByUsername(ctx, c.Param("username"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
+	}
+	if profile == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	user := middleware.GetUser(c)
+	publishedOnly := !policy.CanViewUnpublished(user)
+	hideRestricted, memberOf := policy.GroupFilter(user)
+
+	stats, err := db.GetUserContributions(ctx, profile.ID, publishedOnly)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load contributions")
+	}
+
+	edits, err := db.ListRecentChanges(ctx, models.ChangeFilter{
+		Author:         profile.Username,
+		PublishedOnly:  publishedOnly,
+		HideRestricted: hideRestricted,
+		MemberOf:       memberOf,
+		Limit:          profileEntries,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load edits")
+	}
+
+	filter := models.PageFilter{
+		AuthorID:       &profile.ID,
+		Limit:          profileEntries,
+		OrderBy:        "created_at",
+		OrderDir:       "DESC",
+		HideRestricted: hideRestricted,
+		MemberOf:       memberOf,
+	}
+	if publishedOnly {
+		filter.IsPublished = &publishedOnly
+	}
+	created, err := db.ListPages(ctx, filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load pages")
+	}
+
+	pageData := h.basePageData(c, profile.Username)
+	h.setSidebar(c, &pageData)
+
+	data := pages.ProfileData{
+		PageData: pageData,
+		Profile:  profile,
+		Stats:    stats,
+		Edits:    edits,
+		Created:  created,
+	}
+
+	return render(c, http.StatusOK, pages.Profile(data))
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// UpdatePageProperties changes the properties submitted from one of the
+// inline forms in the page properties panel and returns the updated panel.
+// Moving the page under another parent changes its URL, so the browser is
+// sent to the new one instead.
+func (h *Handlers) UpdatePageProperties(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	pageID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+
+	ctx := c.Request().Context()
+	page, err := h.wikiService.GetPageByID(ctx, pageID)
+	if err != nil {
+		if errors.Is(err, services.ErrPageNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	if !policy.CanView(user, page) || !policy.CanEdit(user, page) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	toast := func(status int, message, kind string) error {
+		c.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":%q,"type":%q}}`, message, kind))
+		return c.NoContent(status)
+	}
+
+	update, err := propertiesUpdateFromForm(c)
+	if err != nil {
+		return toast(http.StatusBadRequest, err.Error(), "error")
+	}
+	if update.IsEmpty() {
+		return toast(http.StatusBadRequest, "Nothing to update", "error")
+	}
+
+	oldSlug := page.Slug
+	result, err := h.wikiService.UpdatePageProperties(ctx, page, user.ID, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPageExists):
+			return toast(http.StatusBadRequest, "A page with this URL already exists under that parent", "error")
+		case errors.Is(err, services.ErrPageArchived):
+			return toast(http.StatusConflict, err.Error(), "error")
+		case errors.Is(err, services.ErrInvalidParent),
+			errors.Is(err, services.ErrInvalidOwner),
+			errors.Is(err, services.ErrInvalidReviewDate),
+			errors.Is(err, services.ErrDescriptionLength),
+			errors.Is(err, services.ErrInvalidImageURL),
+			errors.Is(err, services.ErrSlugTooDeep),
+			errors.Is(err, services.ErrSlugTooLong):
+			return toast(http.StatusBadRequest, err.Error(), "error")
+		default:
+			return toast(http.StatusInternalServerError, "Failed to update properties", "error")
+		}
+	}
+	page = result.Page
+
+	if update.IsPublished != nil || update.Parent != nil || update.Tags != nil {
+		h.backupUpdatedPage(ctx, oldSlug, result, user, "Update properties of "+page.Slug)
+		h.webhooks.EmitPage(ctx, models.EventPageUpdated, page, user)
+	}
+	h.logAdminAction(c, "page_properties", "page", &page.ID, map[string]interface{}{
+		"slug":   page.Slug,
+		"fields": update.Fields(),
+	})
+
+	if page.Slug != oldSlug {
+		h.setFlash(c, "success", "Moved to "+page.Slug)
+		c.Response().Header().Set("HX-Redirect", "/wiki/"+page.Slug)
+		return c.NoContent(http.StatusOK)
+	}
+
+	props, err := h.wikiService.PageProperties(ctx, page)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load properties")
+	}
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Properties updated","type":"success"}}`)
+	return render(c, http.StatusOK, pages.PropertiesPanel(pages.PropertiesData{
+		Properties: props,
+		CanEdit:    true,
+		CSRFToken:  middleware.GetCSRFToken(c),
+		Open:       true,
+	}))
+}
+
+// propertiesUpdateFromForm reads the properties present in the submitted
+// form. Each inline form carries a single property.
+func propertiesUpdateFromForm(c echo.Context) (models.PagePropertiesUpdate, error) {
+	var update models.PagePropertiesUpdate
+	form, err := c.FormParams()
+	if err != nil {
+		return update, errors.New("Invalid form")
+	}
+
+	if _, ok := form["is_published"]; ok {
+		published := form.Get("is_published") == "1"
+		update.IsPublished = &published
+	}
+	if _, ok := form["parent"]; ok {
+		parent := strings.TrimSpace(form.Get("parent"))
+		update.Parent = &parent
+	}
+	if _, ok := form["owner"]; ok {
+		owner := strings.TrimSpace(form.Get("owner"))
+		update.Owner = &owner
+	}
+	if _, ok := form["tags"]; ok {
+		tags := []string{}
+		for _, tag := range strings.Split(form.Get("tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag == "" {
+				continue
+			}
+			if len(tag) > maxTagLength {
+				return update, errors.New("Tag names must be less than 50 characters")
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) > maxTagsPerPage {
+			return update, errors.New("Maximum 20 tags allowed")
+		}
+		update.Tags = &tags
+	}
+	if _, ok := form["review_at"]; ok {
+		reviewAt := strings.TrimSpace(form.Get("review_at"))
+		update.ReviewAt = &reviewAt
+	}
+	if _, ok := form["description"]; ok {
+		description := form.Get("description")
+		update.Description = &description
+	}
+	if _, ok := form["social_image"]; ok {
+		image := form.Get("social_image")
+		update.SocialImage = &image
+	}
+	return update, nil
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
 	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
 )
 
 // render renders a templ component to the response.
@@ -11,3 +19,21 @@ func render(c echo.Context, status int, component templ.Component) error {
 	c.Response().WriteHeader(status)
 	return component.Render(c.Request().Context(), c.Response())
 }
+
+// renderConditional renders a component with an ETag of the HTML and the
+// given Last-Modified time, and responds 304 Not Modified instead when the
+// browser's copy is current. The ETag covers everything on the page, e.g.
+// the signed-in user and sidebar, not just the content.
+func renderConditional(c echo.Context, component templ.Component, lastModified time.Time) error {
+	var buf bytes.Buffer
+	if err := component.Render(c.Request().Context(), &buf); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if middleware.NotModified(c, etag, lastModified) {
+		return c.NoContent(http.StatusNotModified)
+	}
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
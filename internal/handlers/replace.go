@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// ReplaceForm renders the find and replace tool.
+func (h *Handlers) ReplaceForm(c echo.Context) error {
+	data := pages.ReplaceData{
+		PageData: h.basePageData(c, "Find and Replace"),
+		Query:    models.ReplaceQuery{Namespace: c.QueryParam("namespace")},
+	}
+	return render(c, http.StatusOK, pages.Replace(data))
+}
+
+// Replace previews a find and replace across pages, or with mode=apply
+// replaces it in the selected pages. Each changed page gets a revision.
+func (h *Handlers) Replace(c echo.Context) error {
+	user := middleware.GetUser(c)
+	ctx := c.Request().Context()
+
+	data := pages.ReplaceData{
+		PageData: h.basePageData(c, "Find and Replace"),
+		Query: models.ReplaceQuery{
+			Find:          c.FormValue("find"),
+			Replace:       c.FormValue("replace"),
+			Regex:         c.FormValue("regex") == "1",
+			CaseSensitive: c.FormValue("case_sensitive") == "1",
+			Namespace:     strings.TrimSpace(c.FormValue("namespace")),
+		},
+	}
+
+	replacer, err := services.NewReplacer(data.Query)
+	if err != nil {
+		data.Error = err.Error()
+		return render(c, http.StatusBadRequest, pages.Replace(data))
+	}
+	data.Query = replacer.Query()
+
+	if c.FormValue("mode") == "apply" {
+		return h.applyReplace(c, user, replacer)
+	}
+
+	matches, err := h.wikiService.FindReplaceMatches(ctx, replacer)
+	if err != nil {
+		data.Error = "Failed to search pages"
+		return render(c, http.StatusInternalServerError, pages.Replace(data))
+	}
+	for _, m := range matches {
+		page, err := h.wikiService.GetPageByID(ctx, m.PageID)
+		if err != nil || !policy.CanView(user, page) {
+			continue
+		}
+		data.Matches = append(data.Matches, pages.ReplaceResult{
+			ReplaceMatch: m,
+			Editable:     policy.CanEdit(user, page) && !page.IsArchived(),
+		})
+	}
+	data.Previewed = true
+
+	return render(c, http.StatusOK, pages.Replace(data))
+}
+
+// applyReplace replaces in each selected page the user may still edit.
+func (h *Handlers) applyReplace(c echo.Context, user *models.User, replacer *services.Replacer) error {
+	ctx := c.Request().Context()
+
+	form, _ := c.FormParams()
+	changed, occurrences, failed := 0, 0, 0
+	for _, raw := range form["page_ids"] {
+		pageID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		page, err := h.wikiService.GetPageByID(ctx, pageID)
+		if err != nil || !policy.CanView(user, page) || !policy.CanEdit(user, page) {
+			continue
+		}
+
+		result, n, err := h.wikiService.ReplaceInPage(ctx, page, user.ID, replacer)
+		if err != nil {
+			if !errors.Is(err, services.ErrPageArchived) {
+				fmt.Printf("Warning: find and replace failed on %s: %v\n", page.Slug, err)
+			}
+			failed++
+			continue
+		}
+		if result == nil {
+			continue
+		}
+
+		h.backupUpdatedPage(ctx, page.Slug, result, user, "Update "+page.Slug+"\n\n"+replacer.Comment())
+		h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+		changed++
+		occurrences += n
+	}
+
+	query := replacer.Query()
+	h.logAdminAction(c, "page_replace", "page", nil, map[string]interface{}{
+		"find":        query.Find,
+		"replace":     query.Replace,
+		"regex":       query.Regex,
+		"namespace":   query.Namespace,
+		"pages":       changed,
+		"occurrences": occurrences,
+	})
+
+	message := fmt.Sprintf("Replaced %d %s in %d %s", occurrences, plural(occurrences, "occurrence", "occurrences"), changed, plural(changed, "page", "pages"))
+	if failed > 0 {
+		h.setFlash(c, "error", fmt.Sprintf("%s; %d %s could not be updated", message, failed, plural(failed, "page", "pages")))
+	} else {
+		h.setFlash(c, "success", message)
+	}
+	return c.Redirect(http.StatusSeeOther, "/replace")
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
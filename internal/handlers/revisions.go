@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/views/admin"
+)
+
+// largestHistoriesLimit is how many pages the revisions page lists.
+const largestHistoriesLimit = 25
+
+// AdminRevisions shows the revision retention policy and the pages with
+// the longest histories.
+func (h *Handlers) AdminRevisions(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	total, err := h.wikiService.GetDB().CountRevisions(ctx, 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to count revisions")
+	}
+	largest, err := h.wikiService.GetDB().ListLargestHistories(ctx, largestHistoriesLimit)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load revision counts")
+	}
+
+	data := admin.RevisionsData{
+		PageData: h.basePageData(c, "Revisions"),
+		Enabled:  h.revisions.Enabled(),
+		Status:   h.revisions.Status(),
+		Total:    total,
+		Largest:  largest,
+	}
+
+	return render(c, http.StatusOK, admin.Revisions(data))
+}
+
+// AdminPruneRevisions applies the retention policy immediately.
+func (h *Handlers) AdminPruneRevisions(c echo.Context) error {
+	if !h.revisions.Enabled() {
+		h.setFlash(c, "error", "No revision retention policy is configured")
+		return c.Redirect(http.StatusSeeOther, "/admin/revisions")
+	}
+
+	user := middleware.GetUser(c)
+
+	// The pruner writes its own audit entry for every run
+	n, err := h.revisions.Prune(c.Request().Context(), "manual", &user.ID, c.RealIP())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to prune revisions")
+		return c.Redirect(http.StatusSeeOther, "/admin/revisions")
+	}
+
+	h.setFlash(c, "success", fmt.Sprintf("Pruned %d revisions", n))
+	return c.Redirect(http.StatusSeeOther, "/admin/revisions")
+}
+
+// AdminCompactRevisions deletes all but a page's newest revisions.
+func (h *Handlers) AdminCompactRevisions(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+
+	ctx := c.Request().Context()
+	page, err := h.wikiService.GetDB().GetPageByID(ctx, id)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+	if page == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+
+	user := middleware.GetUser(c)
+	n, err := h.revisions.Compact(ctx, page.ID, &user.ID, c.RealIP())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to compact revision history")
+		return c.Redirect(http.StatusSeeOther, "/admin/revisions")
+	}
+
+	h.setFlash(c, "success", fmt.Sprintf("Removed %d revisions of %s", n, page.Title))
+	return c.Redirect(http.StatusSeeOther, "/admin/revisions")
+}
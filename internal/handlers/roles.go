@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminRoles renders the permission matrix and the form to add a role.
+func (h *Handlers) AdminRoles(c echo.Context) error {
+	roles, err := h.roles.List(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load roles")
+	}
+
+	data := admin.RolesData{
+		PageData: h.basePageData(c, "Roles"),
+		Roles:    roles,
+	}
+
+	return render(c, http.StatusOK, admin.Roles(data))
+}
+
+// AdminCreateRole adds a custom role.
+func (h *Handlers) AdminCreateRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	role, err := h.roles.Create(ctx, roleInput(c, models.Role(c.FormValue("name"))))
+	if err != nil {
+		h.setRoleError(c, err, "Failed to create role")
+		return c.Redirect(http.StatusSeeOther, "/admin/roles")
+	}
+	_ = h.cluster.Publish(ctx, services.TopicRoles, "")
+
+	h.logAdminAction(c, "role_create", "role", nil, map[string]interface{}{
+		"name":        role.Name,
+		"permissions": role.Permissions,
+	})
+
+	h.setFlash(c, "success", "Role created")
+	return c.Redirect(http.StatusSeeOther, "/admin/roles")
+}
+
+// AdminUpdateRole replaces a role's description and permissions.
+func (h *Handlers) AdminUpdateRole(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	role, err := h.roles.Update(ctx, roleInput(c, models.Role(c.Param("name"))))
+	if err != nil {
+		h.setRoleError(c, err, "Failed to update role")
+		return c.Redirect(http.StatusSeeOther, "/admin/roles")
+	}
+	_ = h.cluster.Publish(ctx, services.TopicRoles, "")
+
+	h.logAdminAction(c, "role_update", "role", nil, map[string]interface{}{
+		"name":        role.Name,
+		"permissions": role.Permissions,
+	})
+
+	h.setFlash(c, "success", "Role "+string(role.Name)+" saved")
+	return c.Redirect(http.StatusSeeOther, "/admin/roles")
+}
+
+// AdminDeleteRole removes a custom role. The row is removed in place by HTMX.
+func (h *Handlers) AdminDeleteRole(c echo.Context) error {
+	ctx := c.Request().Context()
+	name := models.Role(c.Param("name"))
+
+	if err := h.roles.Delete(ctx, name); err != nil {
+		switch {
+		case errors.Is(err, services.ErrRoleNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Role not found")
+		case errors.Is(err, services.ErrRoleBuiltIn), errors.Is(err, services.ErrRoleInUse):
+			c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"`+err.Error()+`","type":"error"}}`)
+			return c.NoContent(http.StatusConflict)
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete role","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	_ = h.cluster.Publish(ctx, services.TopicRoles, "")
+
+	h.logAdminAction(c, "role_delete", "role", nil, map[string]interface{}{
+		"name": name,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Role deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// roleInput reads a role form. Unchecked permissions are simply absent.
+func roleInput(c echo.Context, name models.Role) services.RoleInput {
+	input := services.RoleInput{
+		Name:        name,
+		Description: c.FormValue("description"),
+	}
+	if form, err := c.FormParams(); err == nil {
+		for _, p := range form["permissions"] {
+			input.Permissions = append(input.Permissions, models.Permission(p))
+		}
+	}
+	return input
+}
+
+func (h *Handlers) setRoleError(c echo.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound),
+		errors.Is(err, services.ErrRoleExists),
+		errors.Is(err, services.ErrRoleName):
+		h.setFlash(c, "error", err.Error())
+	default:
+		h.setFlash(c, "error", fallback)
+	}
+}
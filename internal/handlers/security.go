@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminSecurity renders the rate limiting and IP ban dashboard.
+func (h *Handlers) AdminSecurity(c echo.Context) error {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	rules, err := db.ListIPRules(ctx)
+	if err != nil {
+		rules = []models.IPRule{}
+	}
+
+	shareActivity, err := db.GetShareLinkActivity(ctx, time.Now().UTC().Add(-24*time.Hour), 10)
+	if err != nil {
+		shareActivity = []models.ShareLinkActivity{}
+	}
+
+	limit, window := h.rateLimiter.Limit()
+	data := admin.SecurityData{
+		PageData:      h.basePageData(c, "Security"),
+		RateLimit:     limit,
+		RateWindow:    window.String(),
+		TopClients:    h.rateLimiter.TopClients(20),
+		Rejections:    h.rateLimiter.RecentRejections(),
+		Lockouts:      h.loginLimiter.Lockouts(),
+		ShareActivity: shareActivity,
+		Rules:         rules,
+	}
+
+	return render(c, http.StatusOK, admin.Security(data))
+}
+
+// AdminCreateIPRule bans an IP/CIDR or exempts it from rate limiting.
+func (h *Handlers) AdminCreateIPRule(c echo.Context) error {
+	user := middleware.GetUser(c)
+	cidr := strings.TrimSpace(c.FormValue("cidr"))
+	action := c.FormValue("action")
+	reason := strings.TrimSpace(c.FormValue("reason"))
+	duration := c.FormValue("duration")
+
+	network, err := models.ParseCIDR(cidr)
+	if err != nil {
+		h.setFlash(c, "error", "Enter a valid IP address or CIDR range")
+		return c.Redirect(http.StatusSeeOther, "/admin/security")
+	}
+	if action != models.IPRuleBan && action != models.IPRuleExempt {
+		h.setFlash(c, "error", "Invalid rule action")
+		return c.Redirect(http.StatusSeeOther, "/admin/security")
+	}
+	if len(reason) > 200 {
+		reason = reason[:200]
+	}
+
+	// Refuse to lock the current admin out of their own session
+	if action == models.IPRuleBan {
+		if ip := net.ParseIP(middleware.SanitizeIP(c.RealIP())); ip != nil && network.Contains(ip) {
+			h.setFlash(c, "error", "That rule would ban your own IP address")
+			return c.Redirect(http.StatusSeeOther, "/admin/security")
+		}
+	}
+
+	rule := &models.IPRule{
+		CIDR:      network.String(),
+		Action:    action,
+		Reason:    reason,
+		CreatedBy: &user.ID,
+	}
+	if duration != "" {
+		d, err := time.ParseDuration(duration)
+		if err != nil || d <= 0 {
+			h.setFlash(c, "error", "Invalid duration")
+			return c.Redirect(http.StatusSeeOther, "/admin/security")
+		}
+		expiresAt := time.Now().UTC().Add(d)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	ctx := c.Request().Context()
+	if err := h.wikiService.GetDB().CreateIPRule(ctx, rule); err != nil {
+		h.setFlash(c, "error", "Failed to save rule")
+		return c.Redirect(http.StatusSeeOther, "/admin/security")
+	}
+	_ = h.ipFilter.Reload(ctx)
+	_ = h.cluster.Publish(ctx, services.TopicIPRules, "")
+
+	h.logAdminAction(c, "ip_rule_create", "ip_rule", &rule.ID, map[string]interface{}{
+		"cidr":     rule.CIDR,
+		"action":   rule.Action,
+		"reason":   rule.Reason,
+		"duration": duration,
+	})
+
+	if action == models.IPRuleBan {
+		h.setFlash(c, "success", rule.CIDR+" banned")
+	} else {
+		h.setFlash(c, "success", rule.CIDR+" exempted from rate limiting")
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/security")
+}
+
+// AdminDeleteIPRule removes a ban or exemption.
+func (h *Handlers) AdminDeleteIPRule(c echo.Context) error {
+	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid rule ID")
+	}
+
+	ctx := c.Request().Context()
+	if err := h.wikiService.GetDB().DeleteIPRule(ctx, ruleID); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to remove rule","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	_ = h.ipFilter.Reload(ctx)
+	_ = h.cluster.Publish(ctx, services.TopicIPRules, "")
+
+	h.logAdminAction(c, "ip_rule_delete", "ip_rule", &ruleID, nil)
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Rule removed","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// AdminUnlockLogin clears a login lockout before it expires.
+func (h *Handlers) AdminUnlockLogin(c echo.Context) error {
+	identifier := c.FormValue("identifier")
+	if identifier == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Missing identifier")
+	}
+
+	h.loginLimiter.Unlock(identifier)
+	h.logAdminAction(c, "login_unlock", "ip", nil, map[string]interface{}{
+		"identifier": identifier,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Lockout cleared","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
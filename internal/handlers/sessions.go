@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/policy"
+	"gowiki/internal/views/pages"
+)
+
+// SessionsPage lists the browsers the current user is signed in on.
+func (h *Handlers) SessionsPage(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	sessions, err := h.wikiService.GetDB().ListUserSessions(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load sessions")
+	}
+
+	data := pages.SessionsData{
+		PageData:  h.basePageData(c, "Security"),
+		Sessions:  sessions,
+		CurrentID: h.sessionManager.SessionID(c),
+	}
+	return render(c, http.StatusOK, pages.Sessions(data))
+}
+
+// RevokeSession signs the current user out of one of their other sessions.
+func (h *Handlers) RevokeSession(c echo.Context) error {
+	user := middleware.GetUser(c)
+	id := c.Param("id")
+
+	if id == h.sessionManager.SessionID(c) {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Use Sign Out to end this session","type":"error"}}`)
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	found, err := h.wikiService.GetDB().DeleteUserSession(c.Request().Context(), user.ID, id)
+	if err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to sign out session","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+	if !found {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+
+	h.logAdminAction(c, "session_revoke", "user", &user.ID, nil)
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Session signed out","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// RevokeOtherSessions signs the current user out everywhere but here.
+func (h *Handlers) RevokeOtherSessions(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	n, err := h.wikiService.GetDB().DeleteUserSessions(c.Request().Context(), user.ID, h.sessionManager.SessionID(c))
+	if err != nil {
+		h.setFlash(c, "error", "Failed to sign out other sessions")
+		return c.Redirect(http.StatusSeeOther, "/account/security")
+	}
+
+	h.logAdminAction(c, "session_revoke_others", "user", &user.ID, map[string]interface{}{
+		"sessions": n,
+	})
+
+	h.setFlash(c, "success", fmt.Sprintf("Signed out %d other %s", n, plural(int(n), "session", "sessions")))
+	return c.Redirect(http.StatusSeeOther, "/account/security")
+}
+
+// AdminRevokeUserSessions signs a user out of every browser. An admin
+// signing themselves out keeps the session they're using.
+func (h *Handlers) AdminRevokeUserSessions(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	target, err := h.authService.GetUserByID(c.Request().Context(), userID)
+	if err != nil || target == nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"User not found","type":"error"}}`)
+		return c.NoContent(http.StatusNotFound)
+	}
+	if !policy.CanManageUser(middleware.GetUser(c), target) {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Only administrators can sign out administrators","type":"error"}}`)
+		return c.NoContent(http.StatusForbidden)
+	}
+
+	keep := ""
+	if userID == middleware.GetUser(c).ID {
+		keep = h.sessionManager.SessionID(c)
+	}
+
+	n, err := h.wikiService.GetDB().DeleteUserSessions(c.Request().Context(), userID, keep)
+	if err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to sign out user","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "user_force_logout", "user", &userID, map[string]interface{}{
+		"sessions": n,
+	})
+
+	message := fmt.Sprintf("Signed out of %d %s", n, plural(int(n), "session", "sessions"))
+	c.Response().Header().Set("HX-Trigger", fmt.Sprintf(`{"showToast":{"message":%q,"type":"success"}}`, message))
+	return c.NoContent(http.StatusOK)
+}
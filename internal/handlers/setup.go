@@ -21,7 +21,7 @@ func (h *Handlers) SetupPage(c echo.Context) error {
 	}
 
 	data := setup.SetupData{
-		SiteName:  h.config.Site.Name,
+		SiteName:  h.settings.SiteName(ctx),
 		CSRFToken: middleware.GetCSRFToken(c),
 	}
 	return render(c, http.StatusOK, setup.SetupPage(data))
@@ -44,7 +44,7 @@ func (h *Handlers) SetupSubmit(c echo.Context) error {
 	// Validate passwords match
 	if password != passwordConfirm {
 		data := setup.SetupData{
-			SiteName:  h.config.Site.Name,
+			SiteName:  h.settings.SiteName(ctx),
 			Error:     "Passwords do not match",
 			Username:  username,
 			Email:     email,
@@ -62,7 +62,7 @@ func (h *Handlers) SetupSubmit(c echo.Context) error {
 
 	if err != nil {
 		data := setup.SetupData{
-			SiteName:  h.config.Site.Name,
+			SiteName:  h.settings.SiteName(ctx),
 			Error:     err.Error(),
 			Username:  username,
 			Email:     email,
@@ -74,7 +74,7 @@ func (h *Handlers) SetupSubmit(c echo.Context) error {
 	// Mark setup as complete
 	if err := db.SetSetting(ctx, "setup_complete", "true"); err != nil {
 		data := setup.SetupData{
-			SiteName:  h.config.Site.Name,
+			SiteName:  h.settings.SiteName(ctx),
 			Error:     "Failed to complete setup: " + err.Error(),
 			Username:  username,
 			Email:     email,
@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,19 +13,11 @@ import (
 
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
 	"gowiki/internal/views/pages"
 )
 
-// generateSecureToken generates a cryptographically secure random token.
-func generateSecureToken() (string, error) {
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
-		return "", fmt.Errorf("failed to generate random token: %w", err)
-	}
-	// Use URL-safe base64 encoding without padding
-	return base64.RawURLEncoding.EncodeToString(bytes), nil
-}
-
 // ListShares displays all share links for the current user (or all for admins).
 func (h *Handlers) ListShares(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -35,7 +27,7 @@ func (h *Handlers) ListShares(c echo.Context) error {
 	var err error
 
 	// Admins see all shares, editors see only their own
-	if user.Role.CanAdmin() {
+	if policy.CanManageAllShares(user) {
 		shareLinks, err = h.wikiService.GetDB().ListAllShareLinks(ctx, 100, 0)
 	} else {
 		shareLinks, err = h.wikiService.GetDB().GetShareLinksByUser(ctx, user.ID)
@@ -74,41 +66,115 @@ func (h *Handlers) CreateShareForm(c echo.Context) error {
 
 	// Get the page
 	page, err := h.wikiService.GetDB().GetPageByID(ctx, pageID)
-	if err != nil || page == nil {
+	if err != nil || !policy.CanShare(middleware.GetUser(c), page) {
 		return c.String(http.StatusNotFound, "Page not found")
 	}
 
+	user := middleware.GetUser(c)
+	groups, err := h.shareGroups(c, func(group *models.Group) bool {
+		return policy.CanShareWith(user, page, group)
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to load groups")
+	}
+
 	data := pages.CreateShareFormData{
-		PageData: h.basePageData(c, "Share Page"),
-		Page:     page,
+		PageData:    h.basePageData(c, "Share Page"),
+		TargetType:  models.ShareTargetPage,
+		Page:        page,
+		Groups:      groups,
+		CanBePublic: policy.CanShareWith(user, page, nil),
+		CanEmail:    h.mail.Enabled(),
 	}
 
 	return pages.CreateShareForm(data).Render(ctx, c.Response().Writer)
 }
 
-// CreateShare creates a new share link.
+// CreateCollectionShareForm displays the form for sharing the pages with a
+// tag or found by a search (HTMX modal), named by the type and ref query
+// parameters.
+func (h *Handlers) CreateCollectionShareForm(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	targetType := c.QueryParam("type")
+	ref := strings.TrimSpace(c.QueryParam("ref"))
+	if (targetType != models.ShareTargetTag && targetType != models.ShareTargetSearch) || ref == "" {
+		return c.String(http.StatusBadRequest, "Choose a tag or search to share")
+	}
+
+	groups, err := h.shareGroups(c, func(group *models.Group) bool {
+		return policy.CanShareCollection(user, group)
+	})
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Failed to load groups")
+	}
+
+	data := pages.CreateShareFormData{
+		PageData:    h.basePageData(c, "Share Pages"),
+		TargetType:  targetType,
+		TargetRef:   ref,
+		Groups:      groups,
+		CanBePublic: true,
+		CanEmail:    h.mail.Enabled(),
+	}
+	return render(c, http.StatusOK, pages.CreateShareForm(data))
+}
+
+// CreateShare creates a new share link to a page, or to the pages with a
+// tag or found by a search.
 func (h *Handlers) CreateShare(c echo.Context) error {
 	ctx := c.Request().Context()
 	user := middleware.GetUser(c)
 
-	// Parse form data
-	pageIDStr := c.FormValue("page_id")
-	pageID, err := strconv.ParseInt(pageIDStr, 10, 64)
-	if err != nil {
-		h.setFlash(c, "error", "Invalid page ID")
-		return c.Redirect(http.StatusSeeOther, "/shares")
+	// A link is shared with anyone, or only with a group's members
+	var group *models.Group
+	if groupID, err := strconv.ParseInt(c.FormValue("group_id"), 10, 64); err == nil {
+		if group, err = h.groups.Get(ctx, groupID); err != nil {
+			h.setFlash(c, "error", "Group not found")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
 	}
 
-	// Verify page exists
-	page, err := h.wikiService.GetDB().GetPageByID(ctx, pageID)
-	if err != nil || page == nil {
-		h.setFlash(c, "error", "Page not found")
-		return c.Redirect(http.StatusSeeOther, "/shares")
+	shareLink := &models.ShareLink{
+		TargetType: models.ShareTargetPage,
+		CreatedBy:  user.ID,
 	}
+	var page *models.Page
+	switch targetType := c.FormValue("target_type"); targetType {
+	case models.ShareTargetTag, models.ShareTargetSearch:
+		shareLink.TargetType = targetType
+		shareLink.TargetRef = strings.TrimSpace(c.FormValue("target_ref"))
+		if shareLink.TargetRef == "" {
+			h.setFlash(c, "error", "Choose a tag or search to share")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
+		if !policy.CanShareCollection(user, group) {
+			h.setFlash(c, "error", "You can only share with groups you belong to")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
 
-	// Parse options
-	includeChildren := c.FormValue("include_children") == "on"
+	default:
+		pageID, err := strconv.ParseInt(c.FormValue("page_id"), 10, 64)
+		if err != nil {
+			h.setFlash(c, "error", "Invalid page ID")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
+
+		// Verify page exists
+		page, err = h.wikiService.GetDB().GetPageByID(ctx, pageID)
+		if err != nil || !policy.CanShare(user, page) {
+			h.setFlash(c, "error", "Page not found")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
+		if !policy.CanShareWith(user, page, group) {
+			h.setFlash(c, "error", "This page can only be shared with the groups it's restricted to")
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
+		shareLink.PageID, shareLink.PageTitle, shareLink.PageSlug = page.ID, page.Title, page.Slug
+		shareLink.IncludeChildren = c.FormValue("include_children") == "on"
+	}
 
+	// Parse options
 	var maxViews *int
 	if mv := c.FormValue("max_views"); mv != "" {
 		val, err := strconv.Atoi(mv)
@@ -134,22 +200,30 @@ func (h *Handlers) CreateShare(c echo.Context) error {
 		}
 	}
 
+	// The new link can be emailed straight away
+	var recipients []string
+	if h.mail.Enabled() {
+		var err error
+		if recipients, err = services.ParseShareRecipients(c.FormValue("email_to")); err != nil {
+			h.setFlash(c, "error", err.Error())
+			return c.Redirect(http.StatusSeeOther, "/shares")
+		}
+	}
+
 	// Generate secure token
-	token, err := generateSecureToken()
+	token, err := middleware.GenerateShareToken()
 	if err != nil {
 		h.setFlash(c, "error", "Failed to generate share link")
 		return c.Redirect(http.StatusSeeOther, "/shares")
 	}
 
 	// Create share link
-	shareLink := &models.ShareLink{
-		TokenHash:       middleware.HashToken(token),
-		PageID:          pageID,
-		CreatedBy:       user.ID,
-		IncludeChildren: includeChildren,
-		MaxViews:        maxViews,
-		MaxIPs:          maxIPs,
-		ExpiresAt:       expiresAt,
+	shareLink.TokenHash = middleware.HashToken(token)
+	shareLink.MaxViews = maxViews
+	shareLink.MaxIPs = maxIPs
+	shareLink.ExpiresAt = expiresAt
+	if group != nil {
+		shareLink.GroupID, shareLink.GroupName = &group.ID, group.Name
 	}
 
 	if err := h.wikiService.GetDB().CreateShareLink(ctx, shareLink); err != nil {
@@ -157,20 +231,47 @@ func (h *Handlers) CreateShare(c echo.Context) error {
 		return c.Redirect(http.StatusSeeOther, "/shares")
 	}
 
+	details := services.ShareAuditDetails(shareLink)
+	if page != nil {
+		details["include_children"] = shareLink.IncludeChildren
+	}
+	h.wikiService.Audit(ctx, "share_create", "share_link", &shareLink.ID, details)
+
 	// Build the share URL
 	shareURL := fmt.Sprintf("%s/s/%s", strings.TrimRight(h.config.Site.URL, "/"), token)
 
+	var failed []string
+	if len(recipients) > 0 {
+		title := shareLink.PageTitle
+		if shareLink.IsCollection() {
+			title = "the pages " + models.ShareCollectionFilter(shareLink.TargetType, shareLink.TargetRef)
+		}
+		failed = h.mail.EmailShareLink(ctx, recipients, user.Username, title, shareURL, strings.TrimSpace(c.FormValue("email_message")))
+		h.wikiService.Audit(ctx, "share_email", "share_link", &shareLink.ID, map[string]interface{}{
+			"recipients": recipients,
+			"failed":     len(failed),
+		})
+	}
+
 	// For HTMX requests, return the success template
 	if c.Request().Header.Get("HX-Request") == "true" {
 		data := pages.ShareSuccessData{
-			PageTitle:       page.Title,
-			IncludeChildren: includeChildren,
-			ShareURL:        shareURL,
+			Link:        shareLink,
+			ShareURL:    shareURL,
+			Emailed:     len(recipients) - len(failed),
+			EmailFailed: failed,
+		}
+		if png, err := services.ShareQRCode(shareURL); err == nil {
+			data.QRCode = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
 		}
 		return pages.ShareSuccess(data).Render(ctx, c.Response().Writer)
 	}
 
-	h.setFlash(c, "success", "Share link created: "+shareURL)
+	if len(failed) > 0 {
+		h.setFlash(c, "warning", "Share link created, but it couldn't be emailed to "+strings.Join(failed, ", ")+": "+shareURL)
+	} else {
+		h.setFlash(c, "success", "Share link created: "+shareURL)
+	}
 	return c.Redirect(http.StatusSeeOther, "/shares")
 }
 
@@ -194,7 +295,7 @@ func (h *Handlers) RevokeShare(c echo.Context) error {
 	}
 
 	// Only creator or admin can revoke
-	if link.CreatedBy != user.ID && !user.Role.CanAdmin() {
+	if !policy.CanManageShare(user, link) {
 		h.setFlash(c, "error", "You don't have permission to revoke this share link")
 		return c.Redirect(http.StatusSeeOther, "/shares")
 	}
@@ -203,6 +304,7 @@ func (h *Handlers) RevokeShare(c echo.Context) error {
 		h.setFlash(c, "error", "Failed to revoke share link")
 		return c.Redirect(http.StatusSeeOther, "/shares")
 	}
+	h.wikiService.Audit(ctx, "share_revoke", "share_link", &id, services.ShareAuditDetails(link))
 
 	h.setFlash(c, "success", "Share link revoked")
 
@@ -215,6 +317,83 @@ func (h *Handlers) RevokeShare(c echo.Context) error {
 	return c.Redirect(http.StatusSeeOther, "/shares")
 }
 
+// UpdateShare changes a share link's options, limits and expiry, or
+// restores a revoked link, keeping the URL already handed out.
+func (h *Handlers) UpdateShare(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		h.setFlash(c, "error", "Invalid share link ID")
+		return c.Redirect(http.StatusSeeOther, "/shares")
+	}
+
+	link, err := h.wikiService.GetDB().GetShareLinkByID(ctx, id)
+	if err != nil || link == nil {
+		h.setFlash(c, "error", "Share link not found")
+		return c.Redirect(http.StatusSeeOther, "/shares")
+	}
+	if !policy.CanManageShare(user, link) {
+		h.setFlash(c, "error", "You don't have permission to edit this share link")
+		return c.Redirect(http.StatusSeeOther, "/shares")
+	}
+
+	statsURL := fmt.Sprintf("/shares/%d", id)
+
+	// Empty limits mean unlimited; the expiry only changes when chosen
+	maxViews, viewsOK := parseShareLimit(c.FormValue("max_views"))
+	maxIPs, ipsOK := parseShareLimit(c.FormValue("max_ips"))
+	if !viewsOK || !ipsOK {
+		h.setFlash(c, "error", "Limits must be positive whole numbers")
+		return c.Redirect(http.StatusSeeOther, statsURL)
+	}
+	includeChildren := c.FormValue("include_children") == "on"
+	revoked := c.FormValue("revoked") == "on"
+	update := models.ShareLinkUpdate{
+		IncludeChildren: &includeChildren,
+		MaxViews:        &maxViews,
+		MaxIPs:          &maxIPs,
+		Revoked:         &revoked,
+	}
+	switch exp := c.FormValue("expires_in"); exp {
+	case "":
+	case "never":
+		never := time.Duration(0)
+		update.ExpiresIn = &never
+	default:
+		duration, err := time.ParseDuration(exp)
+		if err != nil || duration <= 0 {
+			h.setFlash(c, "error", "Invalid expiry")
+			return c.Redirect(http.StatusSeeOther, statsURL)
+		}
+		update.ExpiresIn = &duration
+	}
+
+	if err := h.wikiService.UpdateShareLink(ctx, user, link, update); err != nil {
+		if errors.Is(err, services.ErrShareNotAllowed) {
+			h.setFlash(c, "error", "You can no longer share this with the link's audience, so it can't be restored or extended to subpages")
+			return c.Redirect(http.StatusSeeOther, statsURL)
+		}
+		h.setFlash(c, "error", "Failed to update share link")
+		return c.Redirect(http.StatusSeeOther, statsURL)
+	}
+
+	h.setFlash(c, "success", "Share link updated")
+	return c.Redirect(http.StatusSeeOther, statsURL)
+}
+
+// parseShareLimit parses a view or address limit from a form, where empty
+// means unlimited (zero).
+func parseShareLimit(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(value)
+	return n, err == nil && n >= 0
+}
+
 // DeleteShare permanently deletes a share link.
 func (h *Handlers) DeleteShare(c echo.Context) error {
 	ctx := c.Request().Context()
@@ -233,13 +412,14 @@ func (h *Handlers) DeleteShare(c echo.Context) error {
 	}
 
 	// Only creator or admin can delete
-	if link.CreatedBy != user.ID && !user.Role.CanAdmin() {
+	if !policy.CanManageShare(user, link) {
 		return echo.NewHTTPError(http.StatusForbidden, "Permission denied")
 	}
 
 	if err := h.wikiService.GetDB().DeleteShareLink(ctx, id); err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete share link")
 	}
+	h.wikiService.Audit(ctx, "share_delete", "share_link", &id, services.ShareAuditDetails(link))
 
 	// For HTMX, trigger removal from list
 	if c.Request().Header.Get("HX-Request") == "true" {
@@ -271,7 +451,7 @@ func (h *Handlers) ViewShareStats(c echo.Context) error {
 	}
 
 	// Only creator or admin can view stats
-	if link.CreatedBy != user.ID && !user.Role.CanAdmin() {
+	if !policy.CanManageShare(user, link) {
 		h.setFlash(c, "error", "You don't have permission to view this")
 		return c.Redirect(http.StatusSeeOther, "/shares")
 	}
@@ -321,6 +501,12 @@ func (h *Handlers) ViewSharedPage(c echo.Context) error {
 	if link.IsViewLimitReached() {
 		return h.renderSharedError(c, "View limit reached", "This share link has reached its maximum number of views.")
 	}
+	if !policy.CanOpenShare(middleware.GetUser(c), link) {
+		if middleware.GetUser(c) == nil {
+			return c.Redirect(http.StatusSeeOther, "/login?next="+c.Request().URL.Path)
+		}
+		return h.renderSharedError(c, "Group members only", "This page was shared with the "+link.GroupName+" group, and you're not a member.")
+	}
 
 	// Check IP limit
 	if link.MaxIPs != nil {
@@ -332,6 +518,11 @@ func (h *Handlers) ViewSharedPage(c echo.Context) error {
 		}
 	}
 
+	// Tag and search links list their pages and open any of them
+	if link.IsCollection() {
+		return h.viewSharedCollection(c, link, token)
+	}
+
 	// Get the optional child slug
 	childSlug := c.Param("*")
 	var targetSlug string
@@ -352,25 +543,43 @@ func (h *Handlers) ViewSharedPage(c echo.Context) error {
 		page, err = h.wikiService.GetPage(ctx, link.PageSlug)
 	}
 
-	if err != nil || page == nil {
+	if err != nil || page == nil || !policy.CanViewShared(link, page) {
 		return h.renderSharedError(c, "Page not found", "The shared page could not be found.")
 	}
 
-	// Record the access
-	access := &models.ShareLinkAccess{
-		ShareLinkID: link.ID,
-		IPAddress:   sanitizeIP(c.RealIP()),
-		UserAgent:   truncateString(c.Request().UserAgent(), 500),
-	}
-	_ = h.wikiService.GetDB().RecordShareAccess(ctx, access)
-	_ = h.wikiService.GetDB().IncrementShareLinkViewCount(ctx, link.ID)
+	h.recordSharedView(c, link)
+	h.freshness.RecordView(page.ID)
 
 	// Get child pages if include_children is enabled and we're on the main page
 	var childPages []models.PageSummary
 	if link.IncludeChildren && targetSlug == link.PageSlug {
-		childPages, _ = h.wikiService.GetDB().GetPageChildren(ctx, page.ID)
+		// Only children the link could open are listed
+		var memberOf []int64
+		if link.GroupID != nil {
+			memberOf = []int64{*link.GroupID}
+		}
+		childPages, _ = h.wikiService.GetDB().GetPageChildren(ctx, page.ID, true, memberOf)
 	}
 
+	// Includes are limited to published pages, and in a private wiki to
+	// pages the link shares
+	page.ContentHTML = h.wikiService.ExpandIncludes(ctx, page, func(included *models.Page) bool {
+		if !included.IsPublished || !policy.CanViewShared(link, included) {
+			return false
+		}
+		if !h.settings.RequireAuth(ctx) || included.ID == link.PageID {
+			return true
+		}
+		if !link.IncludeChildren {
+			return false
+		}
+		isDescendant, err := h.wikiService.GetDB().IsPageDescendant(ctx, link.PageID, included.Slug)
+		return err == nil && isDescendant
+	})
+
+	// Share viewers have no session, so attachments need signed URLs
+	page.ContentHTML = h.uploadSigner.SignHTML(page.ContentHTML)
+
 	// Get TOC
 	toc := h.wikiService.GenerateTOC(page.Content)
 
@@ -380,20 +589,118 @@ func (h *Handlers) ViewSharedPage(c echo.Context) error {
 		IncludeChildren: link.IncludeChildren,
 		ChildPages:      childPages,
 		TOC:             toc,
-		SiteName:        h.config.Site.Name,
+		SiteName:        h.settings.SiteName(ctx),
 		SiteURL:         h.config.Site.URL,
 		ParentSlug:      link.PageSlug,
+		Social:          h.socialMeta(c, page, c.Request().URL.Path, true),
 	}
 
 	return pages.SharedPage(data).Render(ctx, c.Response().Writer)
 }
 
+// viewSharedCollection renders the index of a tag or search share link,
+// or one of the pages it lists.
+func (h *Handlers) viewSharedCollection(c echo.Context, link *models.ShareLink, token string) error {
+	ctx := c.Request().Context()
+
+	collection, err := h.wikiService.GetDB().ListShareCollection(ctx, link, models.MaxShareCollectionPages)
+	if err != nil {
+		return h.renderSharedError(c, "Pages not found", "The shared pages could not be loaded.")
+	}
+	listed := func(page *models.Page) bool {
+		for _, p := range collection {
+			if p.ID == page.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	slug := c.Param("*")
+	if slug == "" {
+		h.recordSharedView(c, link)
+		return render(c, http.StatusOK, pages.SharedCollection(pages.SharedCollectionData{
+			Link:       link,
+			Pages:      collection,
+			ShareToken: token,
+			SiteName:   h.settings.SiteName(ctx),
+			SiteURL:    h.config.Site.URL,
+		}))
+	}
+
+	page, err := h.wikiService.GetPage(ctx, slug)
+	if err != nil || page == nil || !listed(page) || !policy.CanViewShared(link, page) {
+		return h.renderSharedError(c, "Page not found", "This page is not accessible via this share link.")
+	}
+	h.recordSharedView(c, link)
+	h.freshness.RecordView(page.ID)
+
+	// Includes are limited to published pages, and in a private wiki to
+	// pages the link lists
+	page.ContentHTML = h.wikiService.ExpandIncludes(ctx, page, func(included *models.Page) bool {
+		if !included.IsPublished || !policy.CanViewShared(link, included) {
+			return false
+		}
+		return !h.settings.RequireAuth(ctx) || listed(included)
+	})
+	page.ContentHTML = h.uploadSigner.SignHTML(page.ContentHTML)
+
+	return render(c, http.StatusOK, pages.SharedPage(pages.SharedPageData{
+		Page:       page,
+		ShareToken: token,
+		Collection: link,
+		TOC:        h.wikiService.GenerateTOC(page.Content),
+		SiteName:   h.settings.SiteName(ctx),
+		SiteURL:    h.config.Site.URL,
+		Social:     h.socialMeta(c, page, c.Request().URL.Path, true),
+	}))
+}
+
+// recordSharedView logs a visit through link and counts it against the
+// link's view limit.
+func (h *Handlers) recordSharedView(c echo.Context, link *models.ShareLink) {
+	ctx := c.Request().Context()
+	access := &models.ShareLinkAccess{
+		ShareLinkID: link.ID,
+		IPAddress:   sanitizeIP(c.RealIP()),
+		UserAgent:   truncateString(c.Request().UserAgent(), 500),
+	}
+	_ = h.wikiService.GetDB().RecordShareAccess(ctx, access)
+	_ = h.wikiService.GetDB().IncrementShareLinkViewCount(ctx, link.ID)
+}
+
+// shareGroups returns the groups the current user may share with, out of
+// their own groups, or every group for administrators.
+func (h *Handlers) shareGroups(c echo.Context, allowed func(*models.Group) bool) ([]models.Group, error) {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	var candidates []models.Group
+	var err error
+	if policy.CanViewRestricted(user) {
+		candidates, err = h.groups.List(ctx)
+	} else {
+		candidates, err = h.groups.UserGroups(ctx, user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []models.Group
+	for i := range candidates {
+		if allowed(&candidates[i]) {
+			groups = append(groups, candidates[i])
+		}
+	}
+	return groups, nil
+}
+
 // renderSharedError renders an error page for shared access.
 func (h *Handlers) renderSharedError(c echo.Context, title, message string) error {
 	data := pages.SharedErrorData{
 		Title:    title,
 		Message:  message,
-		SiteName: h.config.Site.Name,
+		SiteName: h.settings.SiteName(c.Request().Context()),
 	}
 	return pages.SharedError(data).Render(c.Request().Context(), c.Response().Writer)
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Sitemap serves the published pages of a public wiki as a sitemap, with
+// change frequencies and priorities derived from each page's edit and view
+// activity. Private wikis have no sitemap.
+func (h *Handlers) Sitemap(c echo.Context) error {
+	if h.settings.RequireAuth(c.Request().Context()) {
+		return echo.NewHTTPError(http.StatusNotFound, "Not found")
+	}
+
+	entries, err := h.freshness.All(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build sitemap")
+	}
+
+	base := strings.TrimRight(h.config.Site.URL, "/")
+	urlset := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, e := range entries {
+		urlset.URLs = append(urlset.URLs, sitemapURL{
+			Loc:        base + "/wiki/" + e.Slug,
+			LastMod:    e.UpdatedAt.UTC().Format("2006-01-02"),
+			ChangeFreq: e.ChangeFreq,
+			Priority:   strconv.FormatFloat(e.Priority, 'f', 1, 64),
+		})
+	}
+
+	out, err := xml.MarshalIndent(urlset, "", "  ")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to build sitemap")
+	}
+
+	c.Response().Header().Set("Cache-Control", "public, max-age=3600")
+	return c.Blob(http.StatusOK, "application/xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// Sitemaps 0.9
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
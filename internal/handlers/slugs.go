@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminSlugLimits lists the pages beyond the slug depth and length limits,
+// each with a suggested slug to move it to.
+func (h *Handlers) AdminSlugLimits(c echo.Context) error {
+	pages, err := h.wikiService.ListPagesBeyondSlugLimits(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check page slugs")
+	}
+
+	data := admin.SlugLimitsData{
+		PageData: h.basePageData(c, "Slug Limits"),
+		Limits:   h.wikiService.SlugLimits(),
+		Pages:    pages,
+	}
+
+	return render(c, http.StatusOK, admin.SlugLimits(data))
+}
+
+// AdminMovePage moves a page from the slug limits report to a new slug.
+// Its subpages move with it.
+func (h *Handlers) AdminMovePage(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+	}
+	page, err := h.wikiService.GetPageByID(ctx, id)
+	if errors.Is(err, services.ErrPageNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load page")
+	}
+
+	oldSlug := page.Slug
+	newSlug := services.Slugify(c.FormValue("slug"))
+	if newSlug == "" || newSlug == oldSlug {
+		h.setFlash(c, "error", "Enter a new URL slug for "+page.Title)
+		return c.Redirect(http.StatusSeeOther, "/admin/slugs")
+	}
+
+	result, err := h.wikiService.UpdatePage(ctx, id, user.ID, models.PageUpdate{Slug: &newSlug}, "Moved to fit slug limits")
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPageExists),
+			errors.Is(err, services.ErrPageArchived),
+			errors.Is(err, services.ErrSlugTooDeep),
+			errors.Is(err, services.ErrSlugTooLong):
+			h.setFlash(c, "error", err.Error())
+		default:
+			h.setFlash(c, "error", "Failed to move page")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/slugs")
+	}
+
+	h.backupUpdatedPage(ctx, oldSlug, result, user, "Move "+oldSlug+" to "+result.Page.Slug)
+	h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, user)
+
+	h.logAdminAction(c, "page_move", "page", &id, map[string]interface{}{
+		"from":     oldSlug,
+		"to":       result.Page.Slug,
+		"subpages": len(result.SlugChanges),
+	})
+
+	message := fmt.Sprintf("Moved %s to %s", oldSlug, result.Page.Slug)
+	switch n := len(result.SlugChanges); {
+	case n == 1:
+		message += " with 1 subpage"
+	case n > 1:
+		message += fmt.Sprintf(" with %d subpages", n)
+	}
+	h.setFlash(c, "success", message)
+	return c.Redirect(http.StatusSeeOther, "/admin/slugs")
+}
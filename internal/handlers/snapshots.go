@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/database"
+	"gowiki/internal/middleware"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminSnapshots renders the database snapshot management page.
+func (h *Handlers) AdminSnapshots(c echo.Context) error {
+	snapshots, err := h.scheduler.List()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load snapshots")
+	}
+
+	// Maintenance tools only apply to SQLite; without a status the page
+	// leaves them out
+	status, err := h.scheduler.DatabaseStatus(c.Request().Context())
+	if err != nil && !errors.Is(err, database.ErrSQLiteOnly) {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load database status")
+	}
+
+	keepDaily, keepWeekly := h.scheduler.Retention()
+	data := admin.SnapshotsData{
+		PageData:       h.basePageData(c, "Database Snapshots"),
+		Snapshots:      snapshots,
+		Enabled:        h.scheduler.Enabled(),
+		Interval:       h.scheduler.Interval().String(),
+		KeepDaily:      keepDaily,
+		KeepWeekly:     keepWeekly,
+		PendingRestore: h.scheduler.PendingRestore(),
+		Replication:    h.replication.Status(),
+		Database:       status,
+	}
+
+	return render(c, http.StatusOK, admin.Snapshots(data))
+}
+
+// AdminCreateSnapshot takes a database snapshot immediately.
+func (h *Handlers) AdminCreateSnapshot(c echo.Context) error {
+	user := middleware.GetUser(c)
+
+	// The scheduler writes its own audit entry for every run
+	snapshot, err := h.scheduler.Run(c.Request().Context(), "manual", &user.ID, c.RealIP())
+	if err != nil {
+		if errors.Is(err, services.ErrSnapshotInProgress) {
+			h.setFlash(c, "error", "A snapshot is already running")
+		} else {
+			h.setFlash(c, "error", "Failed to create snapshot")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+	}
+
+	h.setFlash(c, "success", "Snapshot "+snapshot.Name+" created")
+	return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+}
+
+// AdminDownloadSnapshot streams a snapshot file to the client.
+func (h *Handlers) AdminDownloadSnapshot(c echo.Context) error {
+	name := c.Param("name")
+	path, err := h.scheduler.Path(name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Snapshot not found")
+	}
+
+	h.logAdminAction(c, "snapshot_download", "system", nil, map[string]interface{}{
+		"name": name,
+	})
+
+	return c.Attachment(path, name)
+}
+
+// AdminRestoreSnapshot stages a snapshot to replace the database on next restart.
+func (h *Handlers) AdminRestoreSnapshot(c echo.Context) error {
+	name := c.Param("name")
+	if err := h.scheduler.StageRestore(name); err != nil {
+		if errors.Is(err, services.ErrSnapshotNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Snapshot not found")
+		}
+		h.setFlash(c, "error", "Failed to stage restore")
+		return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+	}
+
+	h.logAdminAction(c, "snapshot_restore", "system", nil, map[string]interface{}{
+		"name": name,
+	})
+
+	h.setFlash(c, "success", "Restore of "+name+" staged. Restart the wiki to apply it.")
+	return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+}
+
+// AdminCancelRestore discards a staged restore.
+func (h *Handlers) AdminCancelRestore(c echo.Context) error {
+	if err := h.scheduler.CancelRestore(); err != nil {
+		h.setFlash(c, "error", "Failed to cancel restore")
+		return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+	}
+
+	h.logAdminAction(c, "snapshot_restore_cancel", "system", nil, nil)
+
+	h.setFlash(c, "success", "Pending restore cancelled")
+	return c.Redirect(http.StatusSeeOther, "/admin/snapshots")
+}
+
+// AdminDeleteSnapshot removes a snapshot file.
+func (h *Handlers) AdminDeleteSnapshot(c echo.Context) error {
+	name := c.Param("name")
+	if err := h.scheduler.Delete(name); err != nil {
+		if errors.Is(err, services.ErrSnapshotNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Snapshot not found")
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete snapshot","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "snapshot_delete", "system", nil, map[string]interface{}{
+		"name": name,
+	})
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Snapshot deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/pages"
+)
+
+// TagManage lists every tag for renaming, merging and deleting.
+func (h *Handlers) TagManage(c echo.Context) error {
+	tags, err := h.wikiService.GetAllTags(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load tags")
+	}
+
+	data := pages.TagManageData{
+		PageData: h.basePageDataWithNav(c, "Manage Tags", "tags"),
+		Tags:     tags,
+	}
+
+	return render(c, http.StatusOK, pages.TagManage(data))
+}
+
+// TagEdit renders the forms to rename, reparent and merge a tag.
+func (h *Handlers) TagEdit(c echo.Context) error {
+	tag, err := h.tagParam(c)
+	if err != nil {
+		return err
+	}
+
+	tags, err := h.wikiService.GetAllTags(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load tags")
+	}
+
+	data := pages.TagEditData{
+		PageData: h.basePageDataWithNav(c, "Tag: "+tag.Name, "tags"),
+		Tag:      tag,
+		Tags:     tags,
+	}
+
+	return render(c, http.StatusOK, pages.TagEdit(data))
+}
+
+// TagUpdate renames a tag and files it under the chosen parent tag.
+func (h *Handlers) TagUpdate(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	tag, err := h.tagParam(c)
+	if err != nil {
+		return err
+	}
+	redirect := "/tags/manage/" + strconv.FormatInt(tag.ID, 10)
+
+	var parent *models.Tag
+	if name := strings.TrimSpace(c.FormValue("parent")); name != "" {
+		if parent, err = h.wikiService.GetTag(ctx, name); err != nil {
+			h.setTagError(c, err, "Failed to update tag")
+			return c.Redirect(http.StatusSeeOther, redirect)
+		}
+	}
+
+	if err := h.wikiService.RenameTag(ctx, tag, c.FormValue("name")); err != nil {
+		h.setTagError(c, err, "Failed to rename tag")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+	if err := h.wikiService.SetTagParent(ctx, tag, parent); err != nil {
+		h.setTagError(c, err, "Failed to update tag")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+
+	h.setFlash(c, "success", "Tag saved")
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+// TagMerge moves a tag's pages to another tag and deletes it.
+func (h *Handlers) TagMerge(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	from, err := h.tagParam(c)
+	if err != nil {
+		return err
+	}
+	redirect := "/tags/manage/" + strconv.FormatInt(from.ID, 10)
+
+	into, err := h.wikiService.GetTag(ctx, c.FormValue("into"))
+	if err != nil {
+		h.setTagError(c, err, "Failed to merge tags")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+	if err := h.wikiService.MergeTags(ctx, from, into); err != nil {
+		h.setTagError(c, err, "Failed to merge tags")
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+
+	h.setFlash(c, "success", fmt.Sprintf("Merged %s into %s", from.Name, into.Name))
+	return c.Redirect(http.StatusSeeOther, "/tags/manage/"+strconv.FormatInt(into.ID, 10))
+}
+
+// TagDelete deletes a tag no page has. The row is removed in place by HTMX.
+func (h *Handlers) TagDelete(c echo.Context) error {
+	tag, err := h.tagParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.wikiService.DeleteTag(c.Request().Context(), tag); err != nil {
+		if errors.Is(err, services.ErrTagInUse) {
+			c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"The tag is still on pages","type":"error"}}`)
+			return c.NoContent(http.StatusConflict)
+		}
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete tag","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Tag deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// TagPrune deletes every tag no page has.
+func (h *Handlers) TagPrune(c echo.Context) error {
+	n, err := h.wikiService.DeleteUnusedTags(c.Request().Context())
+	switch {
+	case err != nil:
+		h.setFlash(c, "error", "Failed to delete unused tags")
+	case n == 0:
+		h.setFlash(c, "info", "No unused tags to delete")
+	case n == 1:
+		h.setFlash(c, "success", "Deleted 1 unused tag")
+	default:
+		h.setFlash(c, "success", fmt.Sprintf("Deleted %d unused tags", n))
+	}
+	return c.Redirect(http.StatusSeeOther, "/tags/manage")
+}
+
+// tagParam loads the tag named by the :id route parameter.
+func (h *Handlers) tagParam(c echo.Context) (*models.Tag, error) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid tag ID")
+	}
+
+	tag, err := h.wikiService.GetTagByID(c.Request().Context(), id)
+	if errors.Is(err, services.ErrTagNotFound) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load tag")
+	}
+	return tag, nil
+}
+
+func (h *Handlers) setTagError(c echo.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound),
+		errors.Is(err, services.ErrTagExists),
+		errors.Is(err, services.ErrTagName),
+		errors.Is(err, services.ErrTagInUse),
+		errors.Is(err, services.ErrTagParent),
+		errors.Is(err, services.ErrTagMergeSelf):
+		h.setFlash(c, "error", err.Error())
+	default:
+		h.setFlash(c, "error", fallback)
+	}
+}
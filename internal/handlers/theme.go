@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// ThemeCSS serves the site theme's accent colors and custom CSS. Pages
+// link it with the theme version, so that URL can be cached for good.
+func (h *Handlers) ThemeCSS(c echo.Context) error {
+	theme := middleware.GetAppearance(c.Request().Context()).Site
+	setThemeCacheControl(c, theme)
+	return c.Blob(http.StatusOK, "text/css; charset=utf-8", []byte(services.ThemeStylesheet(theme)))
+}
+
+// ThemeLogo serves the uploaded logo.
+func (h *Handlers) ThemeLogo(c echo.Context) error {
+	logo, contentType, err := h.wikiService.ThemeLogo(c.Request().Context())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load logo")
+	}
+	if logo == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "No logo")
+	}
+	setThemeCacheControl(c, middleware.GetAppearance(c.Request().Context()).Site)
+	c.Response().Header().Set("X-Content-Type-Options", "nosniff")
+	return c.Blob(http.StatusOK, contentType, logo)
+}
+
+// setThemeCacheControl lets browsers keep theme files requested with the
+// current version for a year, and revalidate any other.
+func setThemeCacheControl(c echo.Context, theme models.SiteTheme) {
+	if v := c.QueryParam("v"); v != "" && v == theme.Version {
+		c.Response().Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Response().Header().Set("Cache-Control", "no-cache")
+	}
+}
+
+// AdminAppearance renders the theme settings.
+func (h *Handlers) AdminAppearance(c echo.Context) error {
+	return render(c, http.StatusOK, admin.Appearance(h.appearanceData(c, nil, "")))
+}
+
+func (h *Handlers) appearanceData(c echo.Context, theme *models.SiteTheme, errMsg string) admin.AppearanceData {
+	data := admin.AppearanceData{
+		PageData: h.basePageData(c, "Appearance"),
+		Error:    errMsg,
+	}
+	if theme != nil {
+		data.Theme = *theme
+	} else {
+		data.Theme = middleware.GetAppearance(c.Request().Context()).Site
+	}
+	return data
+}
+
+// AdminSaveAppearance saves the accent color and custom CSS.
+func (h *Handlers) AdminSaveAppearance(c echo.Context) error {
+	theme := middleware.GetAppearance(c.Request().Context()).Site
+	theme.Accent = c.FormValue("accent")
+	if c.FormValue("default_accent") == "1" {
+		theme.Accent = ""
+	}
+	theme.CSS = c.FormValue("css")
+
+	if err := h.wikiService.SaveTheme(c.Request().Context(), theme.Accent, theme.CSS); err != nil {
+		if errors.Is(err, services.ErrThemeAccent) || errors.Is(err, services.ErrThemeCSS) {
+			return render(c, http.StatusBadRequest, admin.Appearance(h.appearanceData(c, &theme, err.Error())))
+		}
+		h.setFlash(c, "error", "Failed to save the theme")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+
+	h.logAdminAction(c, "theme_update", "settings", nil, map[string]interface{}{
+		"accent":    theme.Accent,
+		"css_bytes": len(theme.CSS),
+	})
+	h.setFlash(c, "success", "Theme saved")
+	return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+}
+
+// AdminUploadLogo replaces the logo with an uploaded image.
+func (h *Handlers) AdminUploadLogo(c echo.Context) error {
+	file, err := c.FormFile("logo")
+	if err != nil {
+		h.setFlash(c, "error", "Choose an image to upload")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+	f, err := file.Open()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read the uploaded file")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+	logo, err := io.ReadAll(io.LimitReader(f, services.MaxThemeLogoSize+1))
+	f.Close()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read the uploaded file")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+	if len(logo) == 0 {
+		h.setFlash(c, "error", "The uploaded file is empty")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+
+	if err := h.wikiService.SetThemeLogo(c.Request().Context(), logo); err != nil {
+		if errors.Is(err, services.ErrThemeLogo) {
+			h.setFlash(c, "error", err.Error())
+		} else {
+			h.setFlash(c, "error", "Failed to save the logo")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+
+	h.logAdminAction(c, "theme_logo_update", "settings", nil, map[string]interface{}{
+		"bytes": len(logo),
+	})
+	h.setFlash(c, "success", "Logo updated")
+	return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+}
+
+// AdminRemoveLogo goes back to showing the site name's initial.
+func (h *Handlers) AdminRemoveLogo(c echo.Context) error {
+	if err := h.wikiService.SetThemeLogo(c.Request().Context(), nil); err != nil {
+		h.setFlash(c, "error", "Failed to remove the logo")
+		return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+	}
+	h.logAdminAction(c, "theme_logo_remove", "settings", nil, nil)
+	h.setFlash(c, "success", "Logo removed")
+	return c.Redirect(http.StatusSeeOther, "/admin/appearance")
+}
+
+// SaveThemePreference remembers the light or dark scheme the user toggled
+// to, so it follows them to other browsers.
+func (h *Handlers) SaveThemePreference(c echo.Context) error {
+	user := middleware.GetUser(c)
+	theme := c.FormValue("theme")
+	if !models.ValidTheme(theme) {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid theme")
+	}
+	if err := h.wikiService.GetDB().UpdateUser(c.Request().Context(), user.ID, &models.UserUpdate{Theme: &theme}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save theme")
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,9 +14,13 @@ import (
 	"gowiki/internal/api"
 	"gowiki/internal/middleware"
 	"gowiki/internal/models"
+	"gowiki/internal/views/admin"
 	"gowiki/internal/views/pages"
 )
 
+// recentTokenCalls is how many recent calls are listed per token.
+const recentTokenCalls = 10
+
 // TokensPage renders the API tokens management page.
 func (h *Handlers) TokensPage(c echo.Context) error {
 	user := middleware.GetUser(c)
@@ -35,10 +40,16 @@ func (h *Handlers) TokensPage(c echo.Context) error {
 		newToken = newTokenFlash[0]
 	}
 
+	usage, err := h.apiUsage.ForUser(c.Request().Context(), user.ID, recentTokenCalls)
+	if err != nil {
+		fmt.Printf("Warning: failed to load API usage: %v\n", err)
+	}
+
 	data := pages.TokensData{
 		PageData: h.basePageData(c, "API Tokens"),
 		Tokens:   tokens,
 		NewToken: newToken,
+		Usage:    usage,
 	}
 
 	return render(c, http.StatusOK, pages.Tokens(data))
@@ -84,6 +95,10 @@ func (h *Handlers) CreateToken(c echo.Context) error {
 		h.setFlash(c, "error", "Failed to create token")
 		return c.Redirect(http.StatusSeeOther, "/tokens")
 	}
+	h.wikiService.Audit(c.Request().Context(), "api_token_create", "api_token", &token.ID, map[string]interface{}{
+		"name":   token.Name,
+		"scopes": token.Scopes,
+	})
 
 	// Store token in flash and redirect (PRG pattern)
 	h.sessionManager.SetFlash(c, "new_token", rawToken)
@@ -124,3 +139,18 @@ func (h *Handlers) DeleteToken(c echo.Context) error {
 	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Token revoked successfully","type":"success"}}`)
 	return c.NoContent(http.StatusOK)
 }
+
+// AdminAPIUsage shows API token activity across all users.
+func (h *Handlers) AdminAPIUsage(c echo.Context) error {
+	usage, err := h.apiUsage.Overview(c.Request().Context())
+	if err != nil {
+		h.setFlash(c, "error", "Failed to load API usage")
+	}
+
+	data := admin.APIUsageData{
+		PageData: h.basePageData(c, "API Usage"),
+		Usage:    usage,
+	}
+
+	return render(c, http.StatusOK, admin.APIUsage(data))
+}
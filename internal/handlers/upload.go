@@ -1,18 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
 )
 
 // UploadFile handles file uploads.
@@ -28,15 +38,125 @@ func (h *Handlers) UploadFile(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "No file uploaded")
 	}
 
+	stored, err := h.storeUpload(c, file, false, nil)
+	if err != nil {
+		return err
+	}
+
+	// Return the URL for the uploaded file
+	signedURL, expires := h.uploadSigner.Sign(stored.Name)
+
+	// Return JSON response
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success":            true,
+		"url":                stored.URL(),
+		"signed_url":         signedURL,
+		"signed_url_expires": expires.UTC(),
+		"filename":           file.Filename,
+		"size":               file.Size,
+		"mime":               stored.MimeType,
+		"sha256":             stored.Attachment.Hash,
+		"duplicate":          stored.Duplicate,
+	})
+}
+
+// pastedImageExtensions names pasted images, which browsers send as
+// unnamed blobs, by their content type.
+var pastedImageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadPaste saves an image pasted or dropped into the editor, such as a
+// screenshot, and returns the markdown that shows it. The image is attached
+// to the page given by page_id; without one it stays pending until the new
+// page being written is first saved.
+func (h *Handlers) UploadPaste(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+	if user == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Not authenticated")
+	}
+
+	var pageID *int64
+	if raw := c.FormValue("page_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid page ID")
+		}
+		page, err := h.wikiService.GetPageByID(ctx, id)
+		if err != nil || !policy.CanView(user, page) {
+			return echo.NewHTTPError(http.StatusNotFound, "Page not found")
+		}
+		if !policy.CanEdit(user, page) {
+			return echo.NewHTTPError(http.StatusForbidden, "You can't edit this page")
+		}
+		pageID = &page.ID
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "No image uploaded")
+	}
+	if filepath.Ext(file.Filename) == "" {
+		// Clipboard images arrive as "blob" or with no name at all
+		file.Filename = "pasted-image" + pastedImageExtensions[file.Header.Get("Content-Type")]
+	}
+
+	stored, err := h.storeUpload(c, file, true, pageID)
+	if err != nil {
+		return err
+	}
+
+	alt := strings.TrimSpace(c.FormValue("alt"))
+	if alt == "" {
+		alt = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"success":    true,
+		"url":        stored.URL(),
+		"mime":       stored.MimeType,
+		"markdown":   services.ImageMarkdown(alt, stored.URL()),
+		"attachment": stored.Attachment,
+		"duplicate":  stored.Duplicate,
+	})
+}
+
+// storedUpload is a file saved to the upload directory.
+type storedUpload struct {
+	Name       string // Generated name on disk
+	MimeType   string // Detected from the content
+	Attachment *models.Attachment
+	// Duplicate is set when an identical file was already stored; Name is
+	// then that file's and nothing new was written.
+	Duplicate bool
+}
+
+// URL returns where the upload is served.
+func (u *storedUpload) URL() string {
+	return "/uploads/" + u.Name
+}
+
+// storeUpload checks an uploaded file's size, type and extension, and
+// saves it under a generated name, recording it as an attachment of the
+// page with pageID, or as pending when pageID is nil. A file identical to
+// one already stored isn't written again; the attachment shares the stored
+// file. With imageOnly, files that aren't images are refused even if
+// uploads of their type are allowed. Errors are HTTP errors for the client.
+func (h *Handlers) storeUpload(c echo.Context, file *multipart.FileHeader, imageOnly bool, pageID *int64) (*storedUpload, error) {
+	ctx := c.Request().Context()
+
 	// Check file size
-	if file.Size > h.config.Upload.MaxSize {
-		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("File too large. Maximum size is %d MB", h.config.Upload.MaxSize/(1024*1024)))
+	if maxSize := h.settings.UploadMaxSize(c.Request().Context()); file.Size > maxSize {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("File too large. Maximum size is %d MB", maxSize/(1024*1024)))
 	}
 
 	// Open the uploaded file
 	src, err := file.Open()
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read uploaded file")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to read uploaded file")
 	}
 	defer src.Close()
 
@@ -44,21 +164,51 @@ func (h *Handlers) UploadFile(c echo.Context) error {
 	buffer := make([]byte, 512)
 	n, err := src.Read(buffer)
 	if err != nil && err != io.EOF {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file content")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file content")
 	}
 
 	// Detect MIME type from content (not from header, which can be spoofed)
 	mimeType := http.DetectContentType(buffer[:n])
 
 	// Validate MIME type
-	if !h.isAllowedMimeType(mimeType) {
-		return echo.NewHTTPError(http.StatusBadRequest, "File type not allowed: "+mimeType)
+	if !h.isAllowedMimeType(c.Request().Context(), mimeType) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "File type not allowed: "+mimeType)
+	}
+	if imageOnly && !strings.HasPrefix(mimeType, "image/") {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Not an image: "+mimeType)
 	}
 
 	// Validate extension
 	ext := strings.ToLower(filepath.Ext(file.Filename))
 	if !h.isAllowedExtension(ext) {
-		return echo.NewHTTPError(http.StatusBadRequest, "File extension not allowed: "+ext)
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "File extension not allowed: "+ext)
+	}
+
+	// Hash the content to find an identical upload
+	src.Seek(0, io.SeekStart)
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, src); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to read file content")
+	}
+	att := &models.Attachment{
+		PageID:     pageID,
+		Filename:   file.Filename,
+		MimeType:   mimeType,
+		SizeBytes:  file.Size,
+		Hash:       hex.EncodeToString(hasher.Sum(nil)),
+		UploaderID: middleware.GetUser(c).ID,
+	}
+
+	existing, err := h.wikiService.FindUploadByHash(ctx, att.Hash)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to check for duplicate uploads")
+	}
+	if existing != nil {
+		att.Filepath = existing.Filepath
+		if err := h.wikiService.AddAttachment(ctx, att); err != nil {
+			return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to save attachment")
+		}
+		return &storedUpload{Name: filepath.Base(existing.Filepath), MimeType: existing.MimeType, Attachment: att, Duplicate: true}, nil
 	}
 
 	// Seek back to beginning
@@ -67,50 +217,182 @@ func (h *Handlers) UploadFile(c echo.Context) error {
 	// Generate a safe filename
 	safeFilename, err := h.generateSafeFilename(file.Filename)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate filename")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate filename")
 	}
 
 	// Create upload directory if it doesn't exist
 	uploadDir := h.config.Upload.Path
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload directory")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create upload directory")
 	}
 
 	// Create the destination file
 	destPath := filepath.Join(uploadDir, safeFilename)
 	dst, err := os.Create(destPath)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create destination file")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to create destination file")
 	}
 	defer dst.Close()
 
 	// Copy the file
 	if _, err := io.Copy(dst, src); err != nil {
 		os.Remove(destPath) // Clean up on error
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save file")
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to save file")
 	}
 
-	// Return the URL for the uploaded file
-	fileURL := "/uploads/" + safeFilename
+	att.Filepath = destPath
+	if err := h.wikiService.AddAttachment(ctx, att); err != nil {
+		os.Remove(destPath)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to save attachment")
+	}
 
-	// Return JSON response
+	return &storedUpload{Name: safeFilename, MimeType: mimeType, Attachment: att}, nil
+}
+
+// ServeUpload serves an uploaded file. Files are readable by signed-in users,
+// by anyone holding a valid signed URL, and by anonymous visitors of a public
+// wiki unless the request was referred by another site. Files attached to
+// pages are only readable by those who can view one of the pages, unless
+// the URL is signed. Range requests are supported, so large PDFs and media
+// can be read piecemeal.
+func (h *Handlers) ServeUpload(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	name := c.Param("name")
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	signed := c.QueryParam("sig") != ""
+	cacheControl := "private, max-age=3600"
+	switch {
+	case signed:
+		expires, ok := h.uploadSigner.Verify(name, c.QueryParam("expires"), c.QueryParam("sig"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusForbidden, "Link expired or invalid")
+		}
+		cacheControl = "private, max-age=" + strconv.Itoa(int(time.Until(expires).Seconds()))
+	case user != nil:
+	case h.settings.RequireAuth(ctx):
+		return echo.NewHTTPError(http.StatusForbidden, "Authentication required")
+	default:
+		if h.config.Upload.HotlinkProtection && isForeignReferer(c) {
+			return echo.NewHTTPError(http.StatusForbidden, "Hotlinking is not allowed")
+		}
+		cacheControl = "public, max-age=86400"
+	}
+
+	path := filepath.Join(h.config.Upload.Path, name)
+	access, err := h.wikiService.GetUploadAccess(ctx, path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load file")
+	}
+	if !signed && !access.Open && !policy.CanViewAttachment(user, access.Pages, access.Uploaders) {
+		// Same answer as for a missing file, like restricted pages
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := c.Response().Header()
+	header.Set("Content-Type", contentType)
+	header.Set("Cache-Control", cacheControl)
+	header.Set("Content-Disposition", uploadDisposition(contentType, name))
+	if access.Hash != "" {
+		// Lets clients resume a download with If-Range
+		header.Set("ETag", `"`+access.Hash+`"`)
+	}
+	if ext == ".svg" {
+		// SVGs can carry scripts; never let them run in the wiki's origin
+		header.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+
+	http.ServeContent(c.Response(), c.Request(), name, info.ModTime(), f)
+	return nil
+}
+
+// SignUpload issues a short-lived signed URL for embedding an upload where
+// the viewer is not signed in.
+func (h *Handlers) SignUpload(c echo.Context) error {
+	name := c.Param("name")
+	if name == "" || name != filepath.Base(name) {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+	path := filepath.Join(h.config.Upload.Path, name)
+	if _, err := os.Stat(path); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	// A signed URL skips the page check, so only sign what the user can read
+	access, err := h.wikiService.GetUploadAccess(c.Request().Context(), path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load file")
+	}
+	if !access.Open && !policy.CanViewAttachment(middleware.GetUser(c), access.Pages, access.Uploaders) {
+		return echo.NewHTTPError(http.StatusNotFound, "File not found")
+	}
+
+	signedURL, expires := h.uploadSigner.Sign(name)
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"success":  true,
-		"url":      fileURL,
-		"filename": file.Filename,
-		"size":     file.Size,
-		"mime":     mimeType,
+		"url":        signedURL,
+		"expires_at": expires.UTC(),
 	})
 }
 
+// uploadDisposition shows images, PDFs and plain text inline and downloads
+// everything else, using the name the file was uploaded with.
+func uploadDisposition(contentType, name string) string {
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "application/pdf") ||
+		strings.HasPrefix(contentType, "text/plain") {
+		disposition = "inline"
+	}
+
+	// Stored names are "<random>_<original>.<ext>"
+	if _, original, ok := strings.Cut(name, "_"); ok && original != "" {
+		name = original
+	}
+	return mime.FormatMediaType(disposition, map[string]string{"filename": name})
+}
+
+// isForeignReferer reports whether the request was referred by another site.
+// Requests without a Referer, such as direct navigation, are not foreign.
+func isForeignReferer(c echo.Context) bool {
+	referer := c.Request().Referer()
+	if referer == "" {
+		return false
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return true
+	}
+	return !strings.EqualFold(u.Host, c.Request().Host)
+}
+
 // isAllowedMimeType checks if the MIME type is allowed.
-func (h *Handlers) isAllowedMimeType(mimeType string) bool {
+func (h *Handlers) isAllowedMimeType(ctx context.Context, mimeType string) bool {
 	// Normalize MIME type (remove parameters like charset)
 	if idx := strings.Index(mimeType, ";"); idx != -1 {
 		mimeType = strings.TrimSpace(mimeType[:idx])
 	}
 
-	for _, allowed := range h.config.Upload.AllowedTypes {
+	for _, allowed := range h.settings.UploadAllowedTypes(ctx) {
 		if mimeType == allowed {
 			return true
 		}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminUploads reports duplicate and large files in the upload directory.
+func (h *Handlers) AdminUploads(c echo.Context) error {
+	report, err := services.InspectUploads(c.Request().Context(), h.wikiService.GetDB(), h.config.Upload.Path)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to inspect uploads")
+	}
+
+	return render(c, http.StatusOK, admin.Uploads(admin.UploadsData{
+		PageData: h.basePageData(c, "Uploads"),
+		Report:   report,
+	}))
+}
+
+// AdminMergeUploads hard-links the stored files with the posted content
+// hash into one, so identical uploads take the space of one.
+func (h *Handlers) AdminMergeUploads(c echo.Context) error {
+	hash := c.FormValue("hash")
+	freed, err := services.MergeDuplicateUploads(c.Request().Context(), h.wikiService.GetDB(), h.config.Upload.Path, hash)
+	if errors.Is(err, os.ErrNotExist) {
+		h.setFlash(c, "error", "No uploads have that content")
+		return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+	}
+	if err != nil {
+		h.setFlash(c, "error", "Failed to merge uploads: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+	}
+
+	h.logAdminAction(c, "upload_merge", "upload", nil, map[string]interface{}{
+		"sha256": hash,
+		"freed":  freed,
+	})
+
+	h.setFlash(c, "success", fmt.Sprintf("Merged duplicate uploads, freeing %d bytes", freed))
+	return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+}
+
+// AdminDeleteUpload deletes a stored file that nothing links to.
+func (h *Handlers) AdminDeleteUpload(c echo.Context) error {
+	name := c.Param("name")
+	err := services.DeleteUpload(c.Request().Context(), h.wikiService.GetDB(), h.config.Upload.Path, name)
+	switch {
+	case errors.Is(err, services.ErrUploadInUse):
+		h.setFlash(c, "error", name+" is still linked from the wiki")
+		return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+	case errors.Is(err, os.ErrNotExist):
+		h.setFlash(c, "error", "Upload not found")
+		return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+	case err != nil:
+		h.setFlash(c, "error", "Failed to delete upload")
+		return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+	}
+
+	h.logAdminAction(c, "upload_delete", "upload", nil, map[string]interface{}{
+		"name": name,
+	})
+
+	h.setFlash(c, "success", "Deleted "+name)
+	return c.Redirect(http.StatusSeeOther, "/admin/uploads")
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+	"gowiki/internal/views/auth"
+)
+
+// maxUserImportSize caps the uploaded CSV size.
+const maxUserImportSize = 1 << 20
+
+// AdminImportUsersForm shows the CSV user import form.
+func (h *Handlers) AdminImportUsersForm(c echo.Context) error {
+	data := admin.UserImportData{
+		PageData:    h.basePageData(c, "Import Users"),
+		Mode:        models.UserImportPasswords,
+		MailEnabled: h.invites.Enabled(),
+	}
+	return render(c, http.StatusOK, admin.UserImport(data))
+}
+
+// AdminImportUsers checks an uploaded CSV and, once confirmed, creates the
+// accounts. The first submission is always a dry run; the report carries
+// the file contents so confirming doesn't need a second upload.
+func (h *Handlers) AdminImportUsers(c echo.Context) error {
+	ctx := c.Request().Context()
+	user := middleware.GetUser(c)
+
+	data := admin.UserImportData{
+		PageData:    h.basePageData(c, "Import Users"),
+		Mode:        c.FormValue("mode"),
+		MailEnabled: h.invites.Enabled(),
+	}
+	if data.Mode != models.UserImportInvites {
+		data.Mode = models.UserImportPasswords
+	}
+
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			data.Error = "Failed to read the uploaded file"
+			return render(c, http.StatusBadRequest, admin.UserImport(data))
+		}
+		content, err := io.ReadAll(io.LimitReader(f, maxUserImportSize+1))
+		f.Close()
+		if err != nil {
+			data.Error = "Failed to read the uploaded file"
+			return render(c, http.StatusBadRequest, admin.UserImport(data))
+		}
+		if len(content) > maxUserImportSize {
+			data.Error = "The file is too large (1 MB max)"
+			return render(c, http.StatusBadRequest, admin.UserImport(data))
+		}
+		data.CSV = string(content)
+	} else {
+		data.CSV = c.FormValue("csv")
+	}
+
+	rows, err := h.userImport.Parse(ctx, strings.NewReader(data.CSV), user)
+	if err != nil {
+		data.CSV = ""
+		if errors.Is(err, services.ErrInvalidUserImport) {
+			data.Error = err.Error()
+			return render(c, http.StatusBadRequest, admin.UserImport(data))
+		}
+		data.Error = "Failed to check the file"
+		return render(c, http.StatusInternalServerError, admin.UserImport(data))
+	}
+	data.Rows = rows
+
+	if c.FormValue("confirm") != "1" {
+		return render(c, http.StatusOK, admin.UserImport(data))
+	}
+
+	results, err := h.userImport.Import(ctx, rows, data.Mode, user)
+	if err != nil && len(results) == 0 {
+		if errors.Is(err, services.ErrMailDisabled) {
+			data.Error = "Email is not configured, so invitations can't be sent"
+		} else {
+			data.Error = "Import failed"
+		}
+		return render(c, http.StatusBadRequest, admin.UserImport(data))
+	}
+
+	var created, failed []string
+	for _, r := range results {
+		if r.User == nil {
+			failed = append(failed, r.Username)
+			continue
+		}
+		created = append(created, r.Username)
+		h.webhooks.EmitUserCreated(ctx, r.User)
+	}
+
+	h.logAdminAction(c, "user_import", "user", nil, map[string]interface{}{
+		"mode":    data.Mode,
+		"created": created,
+		"failed":  failed,
+		"skipped": len(rows) - len(results),
+	})
+
+	data.Rows = nil
+	data.CSV = ""
+	data.Results = results
+	data.Imported = true
+	return render(c, http.StatusOK, admin.UserImport(data))
+}
+
+// AdminExportUsers downloads every account as CSV. The first columns match
+// the import format, so the file can be edited and imported elsewhere.
+func (h *Handlers) AdminExportUsers(c echo.Context) error {
+	filename := fmt.Sprintf("users-%s.csv", time.Now().UTC().Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Response().Header().Set("Cache-Control", "no-store")
+	c.Response().WriteHeader(http.StatusOK)
+
+	n, err := h.userImport.Export(c.Request().Context(), c.Response())
+	if err != nil {
+		// Headers are already sent; the truncated file is all we can do
+		return err
+	}
+
+	h.logAdminAction(c, "user_export", "user", nil, map[string]interface{}{
+		"users": n,
+	})
+	return nil
+}
+
+// InviteForm lets an invited user choose a password.
+func (h *Handlers) InviteForm(c echo.Context) error {
+	data := auth.InviteData{
+		PageData: h.basePageData(c, "Accept Invitation"),
+		Token:    c.Param("token"),
+	}
+
+	invite, err := h.invites.Lookup(c.Request().Context(), data.Token)
+	if err != nil {
+		data.Error = services.ErrInviteNotFound.Error()
+		data.Expired = true
+		return render(c, http.StatusNotFound, auth.Invite(data))
+	}
+	data.Username = invite.Username
+
+	return render(c, http.StatusOK, auth.Invite(data))
+}
+
+// AcceptInvite sets the invited user's password and signs them in.
+func (h *Handlers) AcceptInvite(c echo.Context) error {
+	ctx := c.Request().Context()
+	data := auth.InviteData{
+		PageData: h.basePageData(c, "Accept Invitation"),
+		Token:    c.Param("token"),
+	}
+
+	invite, err := h.invites.Lookup(ctx, data.Token)
+	if err != nil {
+		data.Error = services.ErrInviteNotFound.Error()
+		data.Expired = true
+		return render(c, http.StatusNotFound, auth.Invite(data))
+	}
+	data.Username = invite.Username
+
+	password := c.FormValue("password")
+	if password != c.FormValue("password_confirm") {
+		data.Error = "Passwords do not match."
+		return render(c, http.StatusBadRequest, auth.Invite(data))
+	}
+
+	if _, err := h.invites.Accept(ctx, data.Token, password); err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			data.Error = err.Error()
+		} else {
+			data.Error = "Failed to set your password. Please try again."
+		}
+		return render(c, http.StatusBadRequest, auth.Invite(data))
+	}
+
+	if err := h.sessionManager.SetUserID(c, invite.UserID); err != nil {
+		h.setFlash(c, "success", "Password set! Please log in.")
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	h.setFlash(c, "success", "Welcome to "+h.settings.SiteName(ctx)+"!")
+	return c.Redirect(http.StatusSeeOther, "/")
+}
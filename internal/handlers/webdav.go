@@ -0,0 +1,551 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/webdav"
+
+	"gowiki/internal/api"
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+	"gowiki/internal/services"
+)
+
+// DAVPrefix is where pages are served over WebDAV.
+const DAVPrefix = "/dav"
+
+// davMethods are the methods WebDAV clients use.
+var davMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// davReadMethods change nothing, so tokens without the write scope may
+// use them.
+var davReadMethods = map[string]bool{
+	http.MethodOptions: true,
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	"PROPFIND":         true,
+}
+
+// WebDAV serves the pages the caller can read as a tree of markdown files,
+// so the wiki can be mounted as a network drive or edited in desktop
+// editors. The page linux/networking is the file linux/networking.md, and
+// a page with subpages is also a folder holding them. Clients sign in with
+// their username and an API token as the password; changes need a token
+// with the write scope and go through the same revisions, backups and
+// webhooks as edits in the browser.
+func (h *Handlers) WebDAV(c echo.Context) error {
+	user, token, err := h.davAuthenticate(c)
+	if err != nil {
+		return err
+	}
+	if !davReadMethods[c.Request().Method] && !token.HasScope("write") {
+		return echo.NewHTTPError(http.StatusForbidden, "This API token can only read; use one with the write scope")
+	}
+
+	ctx := middleware.WithUser(c.Request().Context(), user)
+	ctx = services.WithAuditActor(ctx, &user.ID, c.RealIP())
+	c.SetRequest(c.Request().WithContext(ctx))
+
+	// Saves are buffered until the file is closed, so cap them like the
+	// editor does before the body is read
+	if c.Request().Method == http.MethodPut {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxContentLength))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Content is too large (max 1MB)")
+			}
+			return echo.NewHTTPError(http.StatusBadRequest, "Failed to read the request body")
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	handler := &webdav.Handler{
+		Prefix:     DAVPrefix,
+		FileSystem: &davFS{h: h, c: c, user: user},
+		LockSystem: h.davLocks,
+		Logger: func(r *http.Request, err error) {
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				c.Logger().Warnf("webdav %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		},
+	}
+	handler.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// davAuthenticate signs in the client with HTTP basic auth, where the
+// password is one of the user's API tokens. Failures count towards the
+// same lockout as the sign-in form.
+func (h *Handlers) davAuthenticate(c echo.Context) (*models.User, *models.APIToken, error) {
+	ctx := c.Request().Context()
+	clientIP := c.RealIP()
+	if allowed, _ := h.loginLimiter.Check(clientIP); !allowed {
+		return nil, nil, echo.NewHTTPError(http.StatusTooManyRequests, "Too many failed sign-in attempts")
+	}
+
+	challenge := func(msg string) error {
+		c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", h.settings.SiteName(ctx)))
+		return echo.NewHTTPError(http.StatusUnauthorized, msg)
+	}
+	username, secret, ok := c.Request().BasicAuth()
+	if !ok {
+		return nil, nil, challenge("Sign in with your username and an API token as the password")
+	}
+
+	db := h.wikiService.GetDB()
+	token, err := db.GetAPITokenByHash(ctx, api.HashToken(secret))
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to validate token")
+	}
+	var user *models.User
+	if token != nil && time.Now().Before(token.ExpiresAt) {
+		if user, err = db.GetUserByID(ctx, token.UserID); err != nil {
+			return nil, nil, echo.NewHTTPError(http.StatusInternalServerError, "Failed to load user")
+		}
+	}
+	if user == nil || !user.CanSignIn() || user.MustChangePassword ||
+		(!strings.EqualFold(username, user.Username) && !strings.EqualFold(username, user.Email)) {
+		h.loginLimiter.RecordFailure(clientIP)
+		return nil, nil, challenge("Invalid username or API token")
+	}
+
+	go db.UpdateAPITokenLastUsed(context.Background(), token.ID)
+	return user, token, nil
+}
+
+// davFS presents pages as files for one request. The listing is loaded
+// once and dropped after every change.
+type davFS struct {
+	h     *Handlers
+	c     echo.Context
+	user  *models.User
+	files []models.PageFile
+}
+
+// davPath maps a path to the slug it names and whether it names a page's
+// markdown file rather than a folder. Each segment is slugified, so files
+// saved as "Meeting Notes.md" find the page meeting-notes.
+func davPath(name string) (slug string, file bool) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if base, ok := strings.CutSuffix(name, ".md"); ok {
+		return services.Slugify(base), true
+	}
+	return services.Slugify(name), false
+}
+
+// davHidden reports whether name is one of the dot or lock files desktop
+// clients leave around, which have no place among pages.
+func davHidden(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") || strings.HasPrefix(segment, "~") {
+			return true
+		}
+	}
+	return false
+}
+
+// davError maps the wiki's errors to the filesystem errors the WebDAV
+// handler turns into status codes.
+func davError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrPageNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, services.ErrPageExists):
+		return os.ErrExist
+	case errors.Is(err, services.ErrPageArchived), errors.Is(err, services.ErrInvalidSlug),
+		errors.Is(err, services.ErrInvalidTitle), errors.Is(err, services.ErrSlugTooDeep),
+		errors.Is(err, services.ErrSlugTooLong):
+		return os.ErrPermission
+	}
+	return err
+}
+
+// pageFiles lists the pages the user can read.
+func (d *davFS) pageFiles(ctx context.Context) ([]models.PageFile, error) {
+	if d.files != nil {
+		return d.files, nil
+	}
+	filter := models.PageFilter{}
+	if !policy.CanViewUnpublished(d.user) {
+		published := true
+		filter.IsPublished = &published
+	}
+	filter.HideRestricted, filter.MemberOf = policy.GroupFilter(d.user)
+	files, err := d.h.wikiService.GetDB().ListPageFiles(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	d.files = append([]models.PageFile{}, files...)
+	return d.files, nil
+}
+
+// davInfo describes a page's markdown file or folder.
+type davInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i davInfo) Name() string       { return i.name }
+func (i davInfo) Size() int64        { return i.size }
+func (i davInfo) ModTime() time.Time { return i.modTime }
+func (i davInfo) IsDir() bool        { return i.dir }
+func (i davInfo) Sys() any           { return nil }
+
+func (i davInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType saves the WebDAV handler from reading every file in a
+// listing to sniff its type.
+func (i davInfo) ContentType(ctx context.Context) (string, error) {
+	if i.dir {
+		return "", webdav.ErrNotImplemented
+	}
+	return "text/markdown; charset=utf-8", nil
+}
+
+func fileInfo(f models.PageFile) davInfo {
+	return davInfo{name: path.Base(f.Slug) + ".md", size: f.Size, modTime: f.UpdatedAt}
+}
+
+// folderInfo describes the folder of the page slug, which exists when the
+// page has subpages or, like parents created for them, no content of its
+// own.
+func folderInfo(files []models.PageFile, slug string) (davInfo, bool) {
+	info := davInfo{name: path.Base(slug), dir: true}
+	exists := false
+	for _, f := range files {
+		if f.Slug == slug {
+			info.modTime = f.UpdatedAt
+			exists = exists || f.Size == 0
+		} else if strings.HasPrefix(f.Slug, slug+"/") {
+			exists = true
+		}
+	}
+	return info, exists
+}
+
+// children lists the files and folders directly in the folder of slug,
+// or at the top for an empty slug.
+func children(files []models.PageFile, slug string) []fs.FileInfo {
+	prefix := ""
+	if slug != "" {
+		prefix = slug + "/"
+	}
+	var infos []fs.FileInfo
+	folders := map[string]bool{}
+	for _, f := range files {
+		rest, ok := strings.CutPrefix(f.Slug, prefix)
+		if !ok || rest == "" {
+			continue
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if child == rest {
+			infos = append(infos, fileInfo(f))
+		}
+		if !folders[child] {
+			if info, ok := folderInfo(files, prefix+child); ok {
+				folders[child] = true
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos
+}
+
+// hasChildren reports whether the page slug has subpages the user can see.
+func hasChildren(files []models.PageFile, slug string) bool {
+	for _, f := range files {
+		if strings.HasPrefix(f.Slug, slug+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Stat implements webdav.FileSystem.
+func (d *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	if davHidden(name) {
+		return nil, os.ErrNotExist
+	}
+	slug, file := davPath(name)
+	if slug == "" {
+		if file {
+			return nil, os.ErrNotExist
+		}
+		return davInfo{name: "/", dir: true}, nil
+	}
+	files, err := d.pageFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if file {
+		for _, f := range files {
+			if f.Slug == slug {
+				return fileInfo(f), nil
+			}
+		}
+		return nil, os.ErrNotExist
+	}
+	if info, ok := folderInfo(files, slug); ok {
+		return info, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// OpenFile implements webdav.FileSystem. Opening to create or truncate
+// returns a file that saves the page when closed; anything else opens it
+// for reading.
+func (d *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		return d.create(ctx, name)
+	}
+
+	info, err := d.Stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	slug, _ := davPath(name)
+	if info.IsDir() {
+		files, err := d.pageFiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &davDir{info: info, children: children(files, slug)}, nil
+	}
+
+	page, err := d.h.wikiService.GetDB().GetPageBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if !policy.CanView(d.user, page) {
+		return nil, os.ErrNotExist
+	}
+	return &davFile{Reader: bytes.NewReader([]byte(page.Content)), info: info}, nil
+}
+
+// create opens the page name for writing, if the user may change it or
+// create it.
+func (d *davFS) create(ctx context.Context, name string) (webdav.File, error) {
+	slug, file := davPath(name)
+	if davHidden(name) || !file || slug == "" {
+		return nil, os.ErrPermission
+	}
+	page, err := d.h.wikiService.GetDB().GetPageBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if page != nil {
+		if !policy.CanView(d.user, page) || !policy.CanEdit(d.user, page) || page.IsArchived() {
+			return nil, os.ErrPermission
+		}
+	} else if !policy.CanCreate(d.user) {
+		return nil, os.ErrPermission
+	}
+	return &davWriter{fs: d, ctx: ctx, name: name, slug: slug, page: page}, nil
+}
+
+// save stores what was written to a page's file, creating the page when
+// there is none. Writing back unchanged content records nothing.
+func (d *davFS) save(ctx context.Context, name, slug string, page *models.Page, content string) error {
+	d.files = nil
+	if page == nil {
+		title := strings.TrimSuffix(path.Base(name), ".md")
+		if title == services.Slugify(title) {
+			title = services.SlugTitle(title)
+		}
+		return d.createPage(ctx, slug, title, content)
+	}
+	if content == page.Content {
+		return nil
+	}
+
+	result, err := d.h.wikiService.UpdatePage(ctx, page.ID, d.user.ID, models.PageUpdate{Content: &content}, "Edited over WebDAV")
+	if err != nil {
+		return davError(err)
+	}
+	d.h.backupUpdatedPage(ctx, page.Slug, result, d.user, "Update "+page.Slug)
+	d.h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, d.user)
+	return nil
+}
+
+// createPage creates a page along with its backup.
+func (d *davFS) createPage(ctx context.Context, slug, title, content string) error {
+	page, err := d.h.wikiService.CreatePage(ctx, d.user.ID, models.PageCreate{
+		Slug:    slug,
+		Title:   title,
+		Content: content,
+	})
+	if err != nil {
+		return davError(err)
+	}
+	if d.h.backupService != nil {
+		_ = d.h.backupService.SavePageAsMarkdown(page, d.user.Username, getPagePathFromSlug(page.Slug))
+		_ = d.h.backupService.Commit("Create "+page.Slug, d.user)
+	}
+	d.h.webhooks.EmitPage(ctx, models.EventPageCreated, page, d.user)
+	return nil
+}
+
+// Mkdir implements webdav.FileSystem. A new folder is a new empty page,
+// titled like the folder, for pages to be saved under.
+func (d *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	slug, file := davPath(name)
+	if davHidden(name) || file || slug == "" {
+		return os.ErrPermission
+	}
+	if _, err := d.Stat(ctx, name); err == nil {
+		return os.ErrExist
+	}
+	page, err := d.h.wikiService.GetDB().GetPageBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if page != nil {
+		// Saving a page into the folder will make it appear
+		return nil
+	}
+	if !policy.CanCreate(d.user) {
+		return os.ErrPermission
+	}
+	d.files = nil
+	return d.createPage(ctx, slug, path.Base(strings.TrimSuffix(name, "/")), "")
+}
+
+// RemoveAll implements webdav.FileSystem. Deleting a folder deletes its
+// page and every page under it; a page with subpages can't be deleted as
+// just a file, so a stray delete can't orphan them.
+func (d *davFS) RemoveAll(ctx context.Context, name string) error {
+	slug, file := davPath(name)
+	if davHidden(name) {
+		return os.ErrNotExist
+	}
+	if slug == "" {
+		return os.ErrPermission
+	}
+	page, err := d.h.wikiService.GetDB().GetPageBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if !policy.CanView(d.user, page) || !policy.CanDelete(d.user, page) {
+		return os.ErrPermission
+	}
+	files, err := d.pageFiles(ctx)
+	if err != nil {
+		return err
+	}
+	if file && hasChildren(files, slug) {
+		return os.ErrPermission
+	}
+
+	d.files = nil
+	_, err = d.h.deletePageTree(d.c, page)
+	return err
+}
+
+// Rename implements webdav.FileSystem by moving the page, and with it
+// any subpages, to the new slug.
+func (d *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldSlug, oldFile := davPath(oldName)
+	newSlug, newFile := davPath(newName)
+	if davHidden(oldName) || davHidden(newName) || oldFile != newFile || oldSlug == "" || newSlug == "" {
+		return os.ErrPermission
+	}
+	page, err := d.h.wikiService.GetDB().GetPageBySlug(ctx, oldSlug)
+	if err != nil {
+		return err
+	}
+	if !policy.CanView(d.user, page) {
+		return os.ErrNotExist
+	}
+	if !policy.CanEdit(d.user, page) {
+		return os.ErrPermission
+	}
+
+	d.files = nil
+	result, err := d.h.wikiService.UpdatePage(ctx, page.ID, d.user.ID, models.PageUpdate{Slug: &newSlug}, "Moved over WebDAV")
+	if err != nil {
+		return davError(err)
+	}
+	d.h.backupUpdatedPage(ctx, oldSlug, result, d.user, "Move "+oldSlug+" to "+result.Page.Slug)
+	d.h.webhooks.EmitPage(ctx, models.EventPageUpdated, result.Page, d.user)
+	return nil
+}
+
+var errDAVReadOnly = errors.New("file is open for reading")
+
+// davFile is a page's markdown opened for reading.
+type davFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (f *davFile) Close() error                             { return nil }
+func (f *davFile) Stat() (os.FileInfo, error)               { return f.info, nil }
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+func (f *davFile) Write(p []byte) (int, error)              { return 0, errDAVReadOnly }
+
+// davDir is a folder opened for listing.
+type davDir struct {
+	info     os.FileInfo
+	children []fs.FileInfo
+	read     bool
+}
+
+func (d *davDir) Close() error                                 { return nil }
+func (d *davDir) Stat() (os.FileInfo, error)                   { return d.info, nil }
+func (d *davDir) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (d *davDir) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (d *davDir) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+
+func (d *davDir) Readdir(count int) ([]os.FileInfo, error) {
+	if d.read {
+		return nil, nil
+	}
+	d.read = true
+	return d.children, nil
+}
+
+// davWriter collects a page's new markdown and saves it on Close.
+type davWriter struct {
+	fs   *davFS
+	ctx  context.Context
+	name string
+	slug string
+	page *models.Page
+	buf  bytes.Buffer
+}
+
+func (w *davWriter) Write(p []byte) (int, error)                  { return w.buf.Write(p) }
+func (w *davWriter) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (w *davWriter) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (w *davWriter) Readdir(count int) ([]os.FileInfo, error)     { return nil, os.ErrInvalid }
+
+func (w *davWriter) Stat() (os.FileInfo, error) {
+	return davInfo{name: path.Base(w.name), size: int64(w.buf.Len()), modTime: time.Now()}, nil
+}
+
+func (w *davWriter) Close() error {
+	return w.fs.save(w.ctx, w.name, w.slug, w.page, w.buf.String())
+}
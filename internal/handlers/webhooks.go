@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/middleware"
+	"gowiki/internal/models"
+	"gowiki/internal/services"
+	"gowiki/internal/views/admin"
+)
+
+// AdminWebhooks renders registered webhooks and recent deliveries.
+func (h *Handlers) AdminWebhooks(c echo.Context) error {
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+
+	hooks, err := db.ListWebhooks(ctx)
+	if err != nil {
+		hooks = []models.Webhook{}
+	}
+
+	deliveries, err := db.ListWebhookDeliveries(ctx, 50)
+	if err != nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+
+	urls := make(map[int64]string, len(hooks))
+	for _, hook := range hooks {
+		urls[hook.ID] = hook.URL
+	}
+
+	data := admin.WebhooksData{
+		PageData:    h.basePageData(c, "Webhooks"),
+		Webhooks:    hooks,
+		Deliveries:  deliveries,
+		WebhookURLs: urls,
+		Events:      models.WebhookEvents,
+	}
+
+	return render(c, http.StatusOK, admin.Webhooks(data))
+}
+
+// AdminCreateWebhook registers a webhook.
+func (h *Handlers) AdminCreateWebhook(c echo.Context) error {
+	user := middleware.GetUser(c)
+	rawURL := strings.TrimSpace(c.FormValue("url"))
+	secret := strings.TrimSpace(c.FormValue("secret"))
+
+	form, err := c.FormParams()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid form")
+	}
+
+	hook, err := h.webhooks.Create(c.Request().Context(), rawURL, secret, form["events"], &user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrNoWebhookEvents):
+			h.setFlash(c, "error", err.Error())
+		default:
+			h.setFlash(c, "error", "Failed to create webhook")
+		}
+		return c.Redirect(http.StatusSeeOther, "/admin/webhooks")
+	}
+
+	h.logAdminAction(c, "webhook_create", "webhook", &hook.ID, map[string]interface{}{
+		"url":    hook.URL,
+		"events": hook.Events,
+	})
+
+	if secret == "" {
+		h.setFlash(c, "success", "Webhook created. Signing secret: "+hook.Secret)
+	} else {
+		h.setFlash(c, "success", "Webhook created")
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/webhooks")
+}
+
+// AdminToggleWebhook enables or disables a webhook.
+func (h *Handlers) AdminToggleWebhook(c echo.Context) error {
+	hookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	ctx := c.Request().Context()
+	db := h.wikiService.GetDB()
+	hook, err := db.GetWebhook(ctx, hookID)
+	if err != nil || hook == nil {
+		h.setFlash(c, "error", "Webhook not found")
+		return c.Redirect(http.StatusSeeOther, "/admin/webhooks")
+	}
+
+	if err := db.SetWebhookActive(ctx, hookID, !hook.IsActive); err != nil {
+		h.setFlash(c, "error", "Failed to update webhook")
+		return c.Redirect(http.StatusSeeOther, "/admin/webhooks")
+	}
+
+	h.logAdminAction(c, "webhook_update", "webhook", &hookID, map[string]interface{}{
+		"is_active": !hook.IsActive,
+	})
+
+	if hook.IsActive {
+		h.setFlash(c, "success", "Webhook disabled")
+	} else {
+		h.setFlash(c, "success", "Webhook enabled")
+	}
+	return c.Redirect(http.StatusSeeOther, "/admin/webhooks")
+}
+
+// AdminTestWebhook queues a ping delivery for a webhook.
+func (h *Handlers) AdminTestWebhook(c echo.Context) error {
+	hookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	if err := h.webhooks.Ping(c.Request().Context(), hookID); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to queue test delivery","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Test delivery queued","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
+
+// AdminDeleteWebhook removes a webhook and its delivery history.
+func (h *Handlers) AdminDeleteWebhook(c echo.Context) error {
+	hookID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	if err := h.wikiService.GetDB().DeleteWebhook(c.Request().Context(), hookID); err != nil {
+		c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Failed to delete webhook","type":"error"}}`)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	h.logAdminAction(c, "webhook_delete", "webhook", &hookID, nil)
+
+	c.Response().Header().Set("HX-Trigger", `{"showToast":{"message":"Webhook deleted","type":"success"}}`)
+	return c.NoContent(http.StatusOK)
+}
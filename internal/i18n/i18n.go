@@ -0,0 +1,210 @@
+// Package i18n translates the interface into the reader's language.
+// Messages are keyed by their English text, so anything a catalog doesn't
+// translate reads in English. Catalogs ship with the wiki; more can be
+// added, or the shipped ones overridden, by dropping JSON files in a
+// directory read at startup.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language messages are written in.
+const DefaultLanguage = "en"
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+// Catalog holds one language's translations.
+type Catalog struct {
+	Tag  string `json:"-"` // BCP 47 language tag from the file name, e.g. "de"
+	Name string `json:"name"`
+	// Messages maps English messages to translations. fmt verbs in the
+	// English text must appear in the translation in the same order.
+	Messages map[string]string `json:"messages"`
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]*Catalog{}
+)
+
+func init() {
+	if err := load(builtin, "locales"); err != nil {
+		panic(err)
+	}
+}
+
+// LoadDir adds the catalogs in dir, one <tag>.json file per language.
+// Messages in a file for a shipped language replace the shipped ones.
+func LoadDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return load(os.DirFS(dir), ".")
+}
+
+func load(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read catalog %s: %w", file, err)
+		}
+		var cat Catalog
+		if err := json.Unmarshal(data, &cat); err != nil {
+			return fmt.Errorf("failed to parse catalog %s: %w", file, err)
+		}
+		cat.Tag = strings.TrimSuffix(path.Base(file), ".json")
+		if existing, ok := find(cat.Tag); ok {
+			if cat.Name != "" {
+				existing.Name = cat.Name
+			}
+			for msg, translation := range cat.Messages {
+				existing.Messages[msg] = translation
+			}
+			continue
+		}
+		if cat.Name == "" {
+			cat.Name = cat.Tag
+		}
+		if cat.Messages == nil {
+			cat.Messages = map[string]string{}
+		}
+		catalogs[strings.ToLower(cat.Tag)] = &cat
+	}
+	return nil
+}
+
+func find(tag string) (*Catalog, bool) {
+	cat, ok := catalogs[strings.ToLower(tag)]
+	return cat, ok
+}
+
+// Language is a language the interface can be shown in.
+type Language struct {
+	Tag  string
+	Name string
+}
+
+// Languages returns the available languages, English first.
+func Languages() []Language {
+	mu.RLock()
+	defer mu.RUnlock()
+	langs := make([]Language, 0, len(catalogs))
+	for _, cat := range catalogs {
+		langs = append(langs, Language{Tag: cat.Tag, Name: cat.Name})
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if (langs[i].Tag == DefaultLanguage) != (langs[j].Tag == DefaultLanguage) {
+			return langs[i].Tag == DefaultLanguage
+		}
+		return langs[i].Name < langs[j].Name
+	})
+	return langs
+}
+
+// Supported returns the tag of the available language named tag, ignoring
+// case, and whether there is one.
+func Supported(tag string) (string, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if cat, ok := find(tag); ok {
+		return cat.Tag, true
+	}
+	return "", false
+}
+
+// Match returns the tag of the available language that best matches an
+// Accept-Language header, or "" when none does. Regional variants fall
+// back to their language, so de-AT is read in German.
+func Match(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if t, ok := Supported(tag); ok {
+			return t
+		}
+		lang, _, _ := strings.Cut(tag, "-")
+		if t, ok := Supported(lang); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+// Translator translates messages into one language.
+type Translator struct {
+	lang string
+}
+
+// New returns a translator for lang, falling back to English for an
+// unknown one.
+func New(lang string) Translator {
+	if tag, ok := Supported(lang); ok {
+		return Translator{lang: tag}
+	}
+	return Translator{lang: DefaultLanguage}
+}
+
+// Lang returns the translator's language tag.
+func (t Translator) Lang() string {
+	return t.lang
+}
+
+// T translates msg and, given args, formats it like fmt.Sprintf.
+func (t Translator) T(msg string, args ...any) string {
+	mu.RLock()
+	if cat, ok := find(t.lang); ok {
+		if translation, ok := cat.Messages[msg]; ok && translation != "" {
+			msg = translation
+		}
+	}
+	mu.RUnlock()
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+type contextKey struct{}
+
+// WithTranslator returns a context carrying t for the handlers and
+// templates that use it.
+func WithTranslator(ctx context.Context, t Translator) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the translator ctx carries, or an English one.
+func FromContext(ctx context.Context) Translator {
+	if t, ok := ctx.Value(contextKey{}).(Translator); ok {
+		return t
+	}
+	return Translator{lang: DefaultLanguage}
+}
+
+// T translates msg for the reader of ctx.
+func T(ctx context.Context, msg string, args ...any) string {
+	return FromContext(ctx).T(msg, args...)
+}
+
+// Lang returns the language tag of the reader of ctx, for lang attributes.
+func Lang(ctx context.Context) string {
+	return FromContext(ctx).Lang()
+}
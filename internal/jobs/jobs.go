@@ -0,0 +1,234 @@
+// Package jobs runs periodic background work, such as pruning old data,
+// on a schedule and keeps each job's recent results for the admin pages.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownJob is returned by RunNow for a job that isn't registered.
+var ErrUnknownJob = errors.New("unknown job")
+
+// Job is a recurring piece of background work.
+type Job struct {
+	// Name identifies the job in logs and URLs, e.g. "revision-pruning".
+	Name        string
+	Description string
+	// Interval is the time between runs.
+	Interval time.Duration
+	// Jitter adds up to this much random delay before each run, so
+	// replicas started together don't all run at once.
+	Jitter time.Duration
+	// LeaderOnly jobs run only on the cluster leader.
+	LeaderOnly bool
+	// Run does the work. ctx is cancelled when the scheduler stops.
+	Run func(ctx context.Context) error
+}
+
+// Status describes a job and its runs on this node.
+type Status struct {
+	Name         string
+	Description  string
+	Interval     time.Duration
+	LeaderOnly   bool
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	NextRun      time.Time
+	Runs         int64
+	Failures     int64
+}
+
+type entry struct {
+	job     Job
+	trigger chan struct{}
+
+	// Guarded by Scheduler.mu
+	status Status
+}
+
+// Scheduler runs registered jobs until stopped.
+type Scheduler struct {
+	isLeader func() bool
+
+	mu      sync.Mutex
+	entries []*entry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler. isLeader reports whether this node runs
+// leader-only jobs; nil means it always does.
+func New(isLeader func() bool) *Scheduler {
+	if isLeader == nil {
+		isLeader = func() bool { return true }
+	}
+	return &Scheduler{isLeader: isLeader}
+}
+
+// Register adds a job. Jobs registered after Start don't run.
+func (s *Scheduler) Register(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries = append(s.entries, &entry{
+		job:     job,
+		trigger: make(chan struct{}, 1),
+		status: Status{
+			Name:        job.Name,
+			Description: job.Description,
+			Interval:    job.Interval,
+			LeaderOnly:  job.LeaderOnly,
+		},
+	})
+}
+
+// Start runs every registered job in the background. Each first runs
+// after its jitter, then every interval.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel = cancel
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.loop(ctx, e)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	s.wg.Wait()
+}
+
+// RunNow runs the named job as soon as possible, whether or not this node
+// is the leader. A run already waiting is not queued twice.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			select {
+			case e.trigger <- struct{}{}:
+			default:
+			}
+			return nil
+		}
+	}
+	return ErrUnknownJob
+}
+
+// Statuses returns every job's status, by name.
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.entries))
+	for _, e := range s.entries {
+		statuses = append(statuses, e.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	defer s.wg.Done()
+
+	delay := jitter(e.job.Jitter)
+	for {
+		s.mu.Lock()
+		e.status.NextRun = time.Now().Add(delay)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		manual := false
+		select {
+		case <-timer.C:
+		case <-e.trigger:
+			timer.Stop()
+			manual = true
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		if manual || !e.job.LeaderOnly || s.isLeader() {
+			s.run(ctx, e)
+		}
+		delay = e.job.Interval + jitter(e.job.Jitter)
+	}
+}
+
+// run runs the job once, recording and logging the result.
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	s.mu.Lock()
+	e.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := runJob(ctx, e.job)
+	elapsed := time.Since(start)
+
+	s.mu.Lock()
+	e.status.Running = false
+	e.status.LastRun = start
+	e.status.LastDuration = elapsed
+	e.status.LastError = ""
+	e.status.Runs++
+	if err != nil {
+		e.status.LastError = err.Error()
+		e.status.Failures++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		fmt.Printf("Warning: job %s failed after %s: %v\n", e.job.Name, roundDuration(elapsed), err)
+	} else {
+		fmt.Printf("Job %s finished in %s\n", e.job.Name, roundDuration(elapsed))
+	}
+}
+
+// runJob runs job, turning a panic into an error so one job can't stop
+// the others.
+func runJob(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// roundDuration rounds d for logging, keeping sub-millisecond runs
+// distinguishable from zero.
+func roundDuration(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/config"
+)
+
+// probePaths are left out of the allowlist so orchestrators can check the
+// server from outside the allowed networks.
+var probePaths = map[string]bool{"/health": true, "/healthz": true, "/readyz": true}
+
+// AccessControl turns clients away by network or country, as configured,
+// before anything else looks at the request. Unlike the IP rules admins
+// manage at runtime, it is fixed at startup, so it can lock down the admin
+// pages themselves.
+type AccessControl struct {
+	allow            []*net.IPNet
+	allowPaths       []string
+	deny             []*net.IPNet
+	trusted          []*net.IPNet
+	countryHeader    string
+	blockedCountries map[string]bool
+}
+
+// NewAccessControl builds the access rules in cfg. It returns nil when
+// there are none.
+func NewAccessControl(cfg *config.SecurityConfig) (*AccessControl, error) {
+	if len(cfg.IPAllow) == 0 && len(cfg.IPDeny) == 0 && len(cfg.BlockedCountries) == 0 {
+		return nil, nil
+	}
+
+	a := &AccessControl{
+		allowPaths:       cfg.IPAllowPaths,
+		countryHeader:    cfg.CountryHeader,
+		blockedCountries: map[string]bool{},
+	}
+	for _, list := range []struct {
+		ranges []string
+		dst    *[]*net.IPNet
+	}{{cfg.IPAllow, &a.allow}, {cfg.IPDeny, &a.deny}, {cfg.TrustedProxies, &a.trusted}} {
+		for _, r := range list.ranges {
+			ipRange, err := config.ParseIPRange(r)
+			if err != nil {
+				return nil, err
+			}
+			*list.dst = append(*list.dst, ipRange)
+		}
+	}
+	for _, country := range cfg.BlockedCountries {
+		a.blockedCountries[strings.ToUpper(country)] = true
+	}
+	return a, nil
+}
+
+// Allowed reports whether a client at ip may request the path p. r
+// supplies the country header and the address the request came from.
+func (a *AccessControl) Allowed(r *http.Request, ip, p string) bool {
+	parsed := net.ParseIP(SanitizeIP(ip))
+	if parsed == nil {
+		return len(a.allow) == 0
+	}
+	if containsIP(a.deny, parsed) {
+		return false
+	}
+	if len(a.allow) > 0 && !probePaths[p] && a.restricted(p) && !containsIP(a.allow, parsed) {
+		return false
+	}
+	if len(a.blockedCountries) > 0 && a.fromTrustedProxy(r) {
+		if a.blockedCountries[strings.ToUpper(strings.TrimSpace(r.Header.Get(a.countryHeader)))] {
+			return false
+		}
+	}
+	return true
+}
+
+// restricted reports whether the allowlist covers the path p.
+func (a *AccessControl) restricted(p string) bool {
+	if len(a.allowPaths) == 0 {
+		return true
+	}
+	for _, prefix := range a.allowPaths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy reports whether the connection came from a trusted
+// proxy, whose headers can be believed.
+func (a *AccessControl) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	parsed := net.ParseIP(host)
+	return parsed != nil && containsIP(a.trusted, parsed)
+}
+
+func containsIP(ranges []*net.IPNet, ip net.IP) bool {
+	for _, r := range ranges {
+		if r.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects clients the rules turn away.
+func (a *AccessControl) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !a.Allowed(c.Request(), c.RealIP(), path.Clean("/"+c.Request().URL.Path)) {
+				return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+			}
+			return next(c)
+		}
+	}
+}
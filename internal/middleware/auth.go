@@ -3,11 +3,13 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"github.com/labstack/echo/v4"
 
 	"gowiki/internal/config"
+	"gowiki/internal/database"
 	"gowiki/internal/models"
 	"gowiki/internal/services"
 )
@@ -16,25 +18,25 @@ import (
 type contextKey string
 
 const (
-	userContextKey    contextKey = "user"
-	sessionContextKey contextKey = "session"
+	userContextKey     contextKey = "user"
+	sessionContextKey  contextKey = "session"
+	clientIPContextKey contextKey = "client_ip"
 )
 
 // SessionManager handles secure session management.
 type SessionManager struct {
-	store       *sessions.CookieStore
+	store       *DBStore
+	db          *database.DB
 	sessionName string
 	authService *services.AuthService
 }
 
-// NewSessionManager creates a new session manager.
-func NewSessionManager(cfg *config.Config, authService *services.AuthService) *SessionManager {
-	// Create cookie store with secure key
-	store := sessions.NewCookieStore([]byte(cfg.Security.SecretKey))
-
+// NewSessionManager creates a new session manager. Signed-in sessions are
+// stored in the database so they can be listed and revoked.
+func NewSessionManager(cfg *config.Config, db *database.DB, authService *services.AuthService) *SessionManager {
 	// Configure secure session options
 	isHTTPS := len(cfg.Site.URL) >= 5 && cfg.Site.URL[:5] == "https"
-	store.Options = &sessions.Options{
+	options := &sessions.Options{
 		Path:     "/",
 		MaxAge:   cfg.Security.SessionMaxAge,
 		HttpOnly: true,                 // Prevent JavaScript access
@@ -43,7 +45,8 @@ func NewSessionManager(cfg *config.Config, authService *services.AuthService) *S
 	}
 
 	return &SessionManager{
-		store:       store,
+		store:       NewDBStore(db, options, []byte(cfg.Security.SecretKey)),
+		db:          db,
 		sessionName: cfg.Security.SessionName,
 		authService: authService,
 	}
@@ -54,17 +57,34 @@ func (sm *SessionManager) GetSession(c echo.Context) (*sessions.Session, error)
 	return sm.store.Get(c.Request(), sm.sessionName)
 }
 
-// SetUserID stores the user ID in the session.
+// SetUserID stores the user ID in the session. Signing in always starts a
+// new stored session, so an ID set before sign-in can't be reused.
 func (sm *SessionManager) SetUserID(c echo.Context, userID int64) error {
 	session, err := sm.GetSession(c)
 	if err != nil {
 		return err
 	}
 
-	session.Values["user_id"] = userID
+	if session.ID != "" {
+		if err := sm.db.DeleteSession(c.Request().Context(), session.ID); err != nil {
+			return err
+		}
+		session.ID = ""
+	}
+	session.Values[sessionUserIDKey] = userID
 	return session.Save(c.Request(), c.Response())
 }
 
+// SessionID returns the ID of the current stored session, or "" when the
+// request isn't signed in.
+func (sm *SessionManager) SessionID(c echo.Context) string {
+	session, err := sm.GetSession(c)
+	if err != nil {
+		return ""
+	}
+	return session.ID
+}
+
 // GetUserID retrieves the user ID from the session.
 func (sm *SessionManager) GetUserID(c echo.Context) (int64, bool) {
 	session, err := sm.GetSession(c)
@@ -72,7 +92,7 @@ func (sm *SessionManager) GetUserID(c echo.Context) (int64, bool) {
 		return 0, false
 	}
 
-	userID, ok := session.Values["user_id"].(int64)
+	userID, ok := session.Values[sessionUserIDKey].(int64)
 	return userID, ok
 }
 
@@ -108,6 +128,9 @@ func (sm *SessionManager) GetFlash(c echo.Context, key string) []string {
 	}
 
 	flashes := session.Flashes(key)
+	if len(flashes) == 0 {
+		return nil
+	}
 	session.Save(c.Request(), c.Response())
 
 	messages := make([]string, 0, len(flashes))
@@ -124,27 +147,55 @@ func (sm *SessionManager) GetFlash(c echo.Context, key string) []string {
 func (sm *SessionManager) AuthMiddleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			// The session store records the client address with sessions,
+			// and audit entries record it with the acting user
+			ctx := context.WithValue(c.Request().Context(), clientIPContextKey, c.RealIP())
+			ctx = services.WithAuditActor(ctx, nil, c.RealIP())
+			c.SetRequest(c.Request().WithContext(ctx))
+
 			userID, ok := sm.GetUserID(c)
 			if !ok {
 				return next(c)
 			}
 
 			user, err := sm.authService.GetUserByID(c.Request().Context(), userID)
-			if err != nil || user == nil || !user.IsActive {
+			if err != nil || user == nil || !user.CanSignIn() {
 				// Invalid session, clear it
 				sm.ClearSession(c)
 				return next(c)
 			}
 
 			// Store user in context
-			ctx := context.WithValue(c.Request().Context(), userContextKey, user)
+			ctx = context.WithValue(c.Request().Context(), userContextKey, user)
+			ctx = services.WithAuditActor(ctx, &user.ID, c.RealIP())
 			c.SetRequest(c.Request().WithContext(ctx))
 
+			if user.MustChangePassword && !allowedBeforePasswordChange(c.Request().URL.Path) {
+				if c.Request().Header.Get("HX-Request") == "true" {
+					c.Response().Header().Set("HX-Redirect", ChangePasswordPath)
+					return c.NoContent(http.StatusForbidden)
+				}
+				if c.Request().Method != http.MethodGet {
+					return echo.NewHTTPError(http.StatusForbidden, "Choose a new password first")
+				}
+				return c.Redirect(http.StatusSeeOther, ChangePasswordPath)
+			}
+
 			return next(c)
 		}
 	}
 }
 
+// ChangePasswordPath is where users choose a new password. Users who must
+// change their password are sent there from every other page.
+const ChangePasswordPath = "/account/password"
+
+// allowedBeforePasswordChange reports whether a user who must change their
+// password may still request path.
+func allowedBeforePasswordChange(path string) bool {
+	return path == ChangePasswordPath || path == "/logout" || strings.HasPrefix(path, "/static/")
+}
+
 // RequireAuth middleware ensures user is authenticated.
 func RequireAuth() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -185,6 +236,25 @@ func RequireRole(minRole models.Role) echo.MiddlewareFunc {
 	}
 }
 
+// RequirePermission middleware ensures the user's role grants at least one
+// of the permissions.
+func RequirePermission(perms ...models.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := GetUser(c)
+			if user == nil {
+				return redirectToLogin(c)
+			}
+			for _, perm := range perms {
+				if user.Role.Can(perm) {
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	}
+}
+
 // RequireNoAuth middleware ensures user is NOT authenticated (for login page).
 func RequireNoAuth() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -198,6 +268,12 @@ func RequireNoAuth() echo.MiddlewareFunc {
 	}
 }
 
+// WithUser returns ctx with user signed in, for requests that
+// authenticate some other way than with a session.
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
 // GetUser retrieves the current user from context.
 func GetUser(c echo.Context) *models.User {
 	user, ok := c.Request().Context().Value(userContextKey).(*models.User)
@@ -229,9 +305,16 @@ func CanAdmin(c echo.Context) bool {
 	return user != nil && user.Role.CanAdmin()
 }
 
+// Can returns true if the current user's role grants a permission.
+func Can(c echo.Context, perm models.Permission) bool {
+	user := GetUser(c)
+	return user != nil && user.Role.Can(perm)
+}
+
 // RequireAuthIfPrivate middleware requires authentication if the wiki is set to private mode.
-// It allows access if a valid share token is present that grants access to the requested page.
-func RequireAuthIfPrivate(cfg *config.Config) echo.MiddlewareFunc {
+// It allows access if a valid share token is present that grants access to the requested page,
+// or if the route's :slug is a public page or one of its subpages.
+func RequireAuthIfPrivate(settings *services.SettingsService, db *database.DB) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			// If user is authenticated, allow access
@@ -240,7 +323,7 @@ func RequireAuthIfPrivate(cfg *config.Config) echo.MiddlewareFunc {
 			}
 
 			// If wiki is not private, allow access
-			if !cfg.Site.RequireAuth {
+			if !settings.RequireAuth(c.Request().Context()) {
 				return next(c)
 			}
 
@@ -249,6 +332,11 @@ func RequireAuthIfPrivate(cfg *config.Config) echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			// Public pages and their subpages are open to everyone
+			if slug := c.Param("slug"); slug != "" && IsPublicPage(c, db, slug) {
+				return next(c)
+			}
+
 			// No valid access, redirect to login
 			return redirectToLogin(c)
 		}
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/labstack/echo/v4"
+)
+
+// CompressConfig configures response compression.
+type CompressConfig struct {
+	// Encodings are offered in order of preference: "br" and "gzip". An
+	// empty list, or one containing "off", disables compression.
+	Encodings []string
+	// MinSize is the smallest body worth compressing, in bytes.
+	MinSize int
+	// Types are the media types to compress. "text/*" matches a family.
+	Types       []string
+	GzipLevel   int
+	BrotliLevel int
+}
+
+// encoder hands out pooled compressors for one content coding.
+type encoder struct {
+	name string
+	pool sync.Pool
+}
+
+// resettableWriter is a pooled compressor, flushed as the response is
+// streamed and reset for each response.
+type resettableWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compress compresses responses with brotli or gzip, whichever the client
+// accepts and comes first in the config. Responses that are small, of a
+// type that doesn't compress, already encoded, partial, or without a body
+// are sent as they are.
+func Compress(cfg CompressConfig) echo.MiddlewareFunc {
+	encoders := newEncoders(cfg)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		if len(encoders) == 0 {
+			return next
+		}
+		return func(c echo.Context) error {
+			req := c.Request()
+			if req.Method == http.MethodHead || req.Header.Get("Range") != "" || req.Header.Get("Upgrade") != "" {
+				return next(c)
+			}
+			enc := negotiateEncoding(req.Header.Get("Accept-Encoding"), encoders)
+			if enc == nil {
+				c.Response().Header().Add("Vary", "Accept-Encoding")
+				return next(c)
+			}
+
+			res := c.Response()
+			cw := &compressWriter{ResponseWriter: res.Writer, enc: enc, cfg: &cfg}
+			res.Writer = cw
+			defer func() {
+				cw.close()
+				res.Writer = cw.ResponseWriter
+			}()
+			return next(c)
+		}
+	}
+}
+
+// newEncoders creates the encoders cfg offers, in order.
+func newEncoders(cfg CompressConfig) []*encoder {
+	var encoders []*encoder
+	for _, name := range cfg.Encodings {
+		switch name {
+		case "off":
+			return nil
+		case "br":
+			level := cfg.BrotliLevel
+			encoders = append(encoders, &encoder{name: name, pool: sync.Pool{New: func() interface{} {
+				return brotli.NewWriterLevel(io.Discard, level)
+			}}})
+		case "gzip":
+			level := cfg.GzipLevel
+			encoders = append(encoders, &encoder{name: name, pool: sync.Pool{New: func() interface{} {
+				w, _ := gzip.NewWriterLevel(io.Discard, level)
+				return w
+			}}})
+		}
+	}
+	return encoders
+}
+
+// negotiateEncoding returns the first of encoders that the Accept-Encoding
+// header accepts, or nil.
+func negotiateEncoding(header string, encoders []*encoder) *encoder {
+	if header == "" {
+		return nil
+	}
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		ok := true
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				ok = false
+			}
+		}
+		if name == "*" {
+			wildcard = ok
+			continue
+		}
+		accepted[name] = ok
+	}
+	for _, enc := range encoders {
+		if ok, listed := accepted[enc.name]; ok || (!listed && wildcard) {
+			return enc
+		}
+	}
+	return nil
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// to compress it: once MinSize bytes are written, the handler flushes, or
+// the handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	enc *encoder
+	cfg *CompressConfig
+
+	status  int
+	buf     []byte
+	decided bool
+	w       resettableWriter // nil when the response is sent as it is
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.decided {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	// Bodiless and partial responses are never compressed
+	if status < http.StatusOK || status == http.StatusNoContent ||
+		status == http.StatusNotModified || status == http.StatusPartialContent {
+		cw.start(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.cfg.MinSize {
+			return len(b), nil
+		}
+		if err := cw.start(cw.compressible()); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.w != nil {
+		return cw.w.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far, compressing a streamed
+// response of a compressible type whatever its size.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.start(cw.compressible())
+	}
+	if cw.w != nil {
+		cw.w.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response's type is worth compressing
+// and it isn't encoded already.
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+		header.Set("Content-Type", contentType)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range cw.cfg.Types {
+		if family, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, family+"/") {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// start sends the headers, compressed or not, and the buffered body.
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+	header := cw.Header()
+	if compress {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.enc.name)
+		header.Add("Vary", "Accept-Encoding")
+		cw.w = cw.enc.pool.Get().(resettableWriter)
+		cw.w.Reset(cw.ResponseWriter)
+	} else if len(cw.buf) > 0 && cw.compressible() {
+		// A larger body of this type would have been compressed
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if cw.w != nil {
+		_, err = cw.w.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close finishes the response once the handler returns.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		// Nothing was written, or less than MinSize
+		if cw.status == 0 {
+			return
+		}
+		cw.start(false)
+	}
+	if cw.w != nil {
+		cw.w.Close()
+		cw.w.Reset(io.Discard)
+		cw.enc.pool.Put(cw.w)
+		cw.w = nil
+	}
+}
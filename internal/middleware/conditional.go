@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// NotModified sets the ETag and Last-Modified validators of a response and
+// reports whether the request's If-None-Match or If-Modified-Since shows the
+// client already has it, in which case the caller should respond 304.
+// If-Modified-Since is only consulted without If-None-Match, as RFC 9110
+// requires. A zero lastModified leaves Last-Modified unset.
+func NotModified(c echo.Context, etag string, lastModified time.Time) bool {
+	header := c.Response().Header()
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	req := c.Request()
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagListMatches(inm, etag, true)
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		since, err := http.ParseTime(ims)
+		// HTTP dates have second precision
+		return err == nil && !lastModified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// IfMatch reports whether the request's If-Match precondition, if any,
+// holds for a resource whose current ETag is etag. An empty etag means the
+// resource doesn't exist, which fails any If-Match.
+func IfMatch(c echo.Context, etag string) bool {
+	im := c.Request().Header.Get("If-Match")
+	if im == "" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	return etagListMatches(im, etag, false)
+}
+
+// etagListMatches reports whether a comma-separated If-Match or
+// If-None-Match list contains etag or "*". Weak comparison ignores the W/
+// prefix; strong comparison never matches a weak tag.
+func etagListMatches(list, etag string, weak bool) bool {
+	if weak {
+		etag = strings.TrimPrefix(etag, "W/")
+	} else if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
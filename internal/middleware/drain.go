@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Drainer tracks in-flight requests so shutdown can wait for them to finish,
+// and reports the server as unhealthy once draining starts so load balancers
+// and process supervisors stop routing new requests to it.
+type Drainer struct {
+	active   atomic.Int64
+	draining atomic.Bool
+}
+
+// NewDrainer creates a new request drainer.
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Middleware counts requests in flight and fails health and readiness
+// checks while draining. Liveness still passes, so the process isn't
+// killed before in-flight requests finish.
+func (d *Drainer) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if d.draining.Load() && (c.Path() == "/health" || c.Path() == "/readyz") {
+				return c.JSON(http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+			}
+
+			d.active.Add(1)
+			defer d.active.Add(-1)
+
+			return next(c)
+		}
+	}
+}
+
+// StartDraining marks the server as shutting down.
+func (d *Drainer) StartDraining() {
+	d.draining.Store(true)
+}
+
+// Active returns the number of requests currently being handled.
+func (d *Drainer) Active() int64 {
+	return d.active.Load()
+}
+
+// Wait blocks until all in-flight requests complete or ctx is done.
+func (d *Drainer) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for d.active.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
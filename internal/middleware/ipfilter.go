@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// rateLimitExemptKey marks requests from exempt addresses in the echo context.
+const rateLimitExemptKey = "rate_limit_exempt"
+
+// ipRule is a parsed, ready-to-match IP rule.
+type ipRule struct {
+	network   *net.IPNet
+	action    string
+	expiresAt *time.Time
+}
+
+// IPFilter enforces persisted IP bans and rate limit exemptions.
+// Rules are held in memory and refreshed from the database on Reload.
+type IPFilter struct {
+	db    *database.DB
+	rules []ipRule
+	mu    sync.RWMutex
+}
+
+// NewIPFilter creates an IP filter and loads the current rules.
+func NewIPFilter(db *database.DB) *IPFilter {
+	f := &IPFilter{db: db}
+
+	// A failed load starts with no rules rather than refusing to boot
+	_ = f.Reload(context.Background())
+
+	// Periodically reload so expired rules drop out without a restart
+	go f.refresh()
+
+	return f
+}
+
+// Reload re-reads all active rules from the database.
+func (f *IPFilter) Reload(ctx context.Context) error {
+	records, err := f.db.ListIPRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]ipRule, 0, len(records))
+	for _, r := range records {
+		network, err := r.Network()
+		if err != nil {
+			continue
+		}
+		rules = append(rules, ipRule{network: network, action: r.Action, expiresAt: r.ExpiresAt})
+	}
+
+	f.mu.Lock()
+	f.rules = rules
+	f.mu.Unlock()
+	return nil
+}
+
+// Match returns the action that applies to ip, or "" when no rule matches.
+// Bans take precedence over exemptions.
+func (f *IPFilter) Match(ip string) string {
+	parsed := net.ParseIP(SanitizeIP(ip))
+	if parsed == nil {
+		return ""
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	now := time.Now()
+	action := ""
+	for _, r := range f.rules {
+		if r.expiresAt != nil && now.After(*r.expiresAt) {
+			continue
+		}
+		if !r.network.Contains(parsed) {
+			continue
+		}
+		if r.action == models.IPRuleBan {
+			return models.IPRuleBan
+		}
+		action = r.action
+	}
+	return action
+}
+
+// Middleware rejects banned clients and flags exempt ones for the rate limiter.
+func (f *IPFilter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch f.Match(c.RealIP()) {
+			case models.IPRuleBan:
+				return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+			case models.IPRuleExempt:
+				c.Set(rateLimitExemptKey, true)
+			}
+			return next(c)
+		}
+	}
+}
+
+// refresh reloads rules every minute.
+func (f *IPFilter) refresh() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_ = f.Reload(context.Background())
+	}
+}
+
+// isRateLimitExempt reports whether the IP filter exempted this request.
+func isRateLimitExempt(c echo.Context) bool {
+	exempt, _ := c.Get(rateLimitExemptKey).(bool)
+	return exempt
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/i18n"
+	"gowiki/internal/models"
+	"gowiki/internal/timefmt"
+)
+
+// Localize puts the translator and time formatter for the current user in
+// the request context, for handlers and templates. Visitors read times in
+// the site defaults, so shared caches keep pages looking the same to
+// everyone; their language follows the browser, and responses say so with
+// Vary.
+func Localize(defaultZone, defaultLocale, defaultLanguage string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			user := GetUser(c)
+			acceptLanguage := c.Request().Header.Get("Accept-Language")
+			if user == nil {
+				c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+			}
+			f := UserTimeFormatter(user, acceptLanguage, defaultZone, defaultLocale)
+			t := UserTranslator(user, acceptLanguage, defaultLanguage)
+			ctx := timefmt.WithFormatter(c.Request().Context(), f)
+			ctx = i18n.WithTranslator(ctx, t)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// UserTimeFormatter returns the formatter user reads times with: their own
+// timezone and locale, falling back to their browser's language and then
+// the site defaults. A nil user gets the site defaults.
+func UserTimeFormatter(user *models.User, acceptLanguage, defaultZone, defaultLocale string) timefmt.Formatter {
+	zone, locale := defaultZone, defaultLocale
+	if user != nil {
+		if user.Timezone != "" {
+			zone = user.Timezone
+		}
+		if user.Locale != "" {
+			locale = user.Locale
+		} else if tag := timefmt.MatchLocale(acceptLanguage); tag != "" {
+			locale = tag
+		}
+	}
+	return timefmt.New(zone, locale)
+}
+
+// UserTranslator returns the translator for the language user chose,
+// falling back to their browser's language and then the site default.
+// user may be nil.
+func UserTranslator(user *models.User, acceptLanguage, defaultLanguage string) i18n.Translator {
+	if user != nil && user.Language != "" {
+		if _, ok := i18n.Supported(user.Language); ok {
+			return i18n.New(user.Language)
+		}
+	}
+	if tag := i18n.Match(acceptLanguage); tag != "" {
+		return i18n.New(tag)
+	}
+	return i18n.New(defaultLanguage)
+}
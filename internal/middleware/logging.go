@@ -57,6 +57,11 @@ func RequestLogger() echo.MiddlewareFunc {
 				logEntry += fmt.Sprintf(" | user=%s", userInfo)
 			}
 
+			// Add trace ID so log lines can be matched to exported spans
+			if traceID := GetTraceID(c); traceID != "" {
+				logEntry += fmt.Sprintf(" | trace=%s", traceID)
+			}
+
 			// Add query string if present
 			if req.URL.RawQuery != "" {
 				logEntry += fmt.Sprintf(" | query=%s", req.URL.RawQuery)
@@ -94,6 +99,9 @@ func RecoveryMiddleware() echo.MiddlewareFunc {
 					// Get request info for context
 					req := c.Request()
 					fmt.Printf("\033[31m[PANIC] [%s] Request: %s %s\033[0m\n", reqID, req.Method, req.URL.Path)
+					if traceID := GetTraceID(c); traceID != "" {
+						fmt.Printf("\033[31m[PANIC] [%s] Trace: %s\033[0m\n", reqID, traceID)
+					}
 
 					// Return 500 error
 					c.Error(echo.NewHTTPError(500, "Internal server error"))
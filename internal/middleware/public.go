@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/database"
+)
+
+// IsPublicPage reports whether the page with the slug is marked public, or
+// is a subpage of one, so anonymous visitors may read it on a wiki that
+// requires sign-in.
+func IsPublicPage(c echo.Context, db *database.DB, slug string) bool {
+	public, err := db.InPublicSubtree(c.Request().Context(), slug)
+	if err != nil {
+		c.Logger().Errorf("failed to check public page: %v", err)
+		return false
+	}
+	return public
+}
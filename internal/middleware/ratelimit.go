@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/config"
+	"gowiki/internal/models"
+)
+
+// Limits fail open: when the store can't be reached, requests and sign-ins
+// are let through rather than locking everyone out.
+
+// maxRecentRejections bounds the in-memory log of rate-limited requests.
+const maxRecentRejections = 100
+
+// RateLimiter provides request rate limiting.
+type RateLimiter struct {
+	store       RateLimitStore
+	routes      []config.RouteRateLimit
+	rejections  []RateLimitRejection
+	mu          sync.RWMutex
+	maxRequests int
+	window      time.Duration
+}
+
+// RateLimitClient reports a client's request volume in the current window.
+type RateLimitClient struct {
+	IP       string
+	Count    int
+	Limited  bool
+	ResetsAt time.Time
+}
+
+// RateLimitRejection records a request that was answered with 429.
+type RateLimitRejection struct {
+	IP   string
+	Path string
+	At   time.Time
+}
+
+// NewRateLimiter creates a rate limiter counting in store. routes are
+// stricter limits for some paths, as in WIKI_RATE_LIMIT_ROUTES.
+func NewRateLimiter(store RateLimitStore, maxRequests int, window time.Duration, routes []string) (*RateLimiter, error) {
+	rl := &RateLimiter{
+		store:       store,
+		maxRequests: maxRequests,
+		window:      window,
+	}
+	for _, r := range routes {
+		route, err := config.ParseRouteRateLimit(r)
+		if err != nil {
+			return nil, err
+		}
+		rl.routes = append(rl.routes, route)
+	}
+	return rl, nil
+}
+
+// Middleware returns the rate limiting middleware.
+func (rl *RateLimiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// Exempt addresses (e.g. office IPs) bypass the limit entirely
+			if isRateLimitExempt(c) {
+				return next(c)
+			}
+
+			// Get client identifier (IP address)
+			clientIP := c.RealIP()
+
+			// Check rate limit
+			if allowed, retryAfter := rl.allow(c.Request().Context(), clientIP, c.Request().URL.Path); !allowed {
+				rl.recordRejection(clientIP, c.Request().URL.Path)
+				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(retryAfter.Seconds())))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Rate limit exceeded")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// allow counts a request from clientID to path against the overall limit
+// and any route limits covering path. When one is exceeded it returns how
+// long until it resets.
+func (rl *RateLimiter) allow(ctx context.Context, clientID, path string) (bool, time.Duration) {
+	type limit struct {
+		key    string
+		max    int
+		window time.Duration
+	}
+	limits := []limit{{"req:" + clientID, rl.maxRequests, rl.window}}
+	for _, route := range rl.routes {
+		if path == route.Prefix || strings.HasPrefix(path, route.Prefix+"/") {
+			limits = append(limits, limit{"route:" + route.Prefix + ":" + clientID, route.Requests, route.Window})
+		}
+	}
+
+	for _, l := range limits {
+		counter, err := rl.store.Increment(ctx, l.key, l.window)
+		if err != nil {
+			logRateLimitError(err)
+			continue
+		}
+		if counter.Count > l.max {
+			return false, retryAfter(counter)
+		}
+	}
+	return true, 0
+}
+
+// retryAfter returns how long until counter resets, rounded up to a
+// whole second.
+func retryAfter(counter *models.RateLimitCounter) time.Duration {
+	d := time.Until(counter.ResetsAt).Truncate(time.Second) + time.Second
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
+
+func logRateLimitError(err error) {
+	fmt.Printf("Warning: rate limit store: %v\n", err)
+}
+
+// recordRejection appends to the bounded log of recent 429 responses.
+func (rl *RateLimiter) recordRejection(clientID, path string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.rejections = append(rl.rejections, RateLimitRejection{IP: clientID, Path: path, At: time.Now()})
+	if len(rl.rejections) > maxRecentRejections {
+		rl.rejections = rl.rejections[len(rl.rejections)-maxRecentRejections:]
+	}
+}
+
+// TopClients returns the clients with the most requests in the current window.
+func (rl *RateLimiter) TopClients(limit int) []RateLimitClient {
+	counters, err := rl.store.List(context.Background(), "req:")
+	if err != nil {
+		logRateLimitError(err)
+		return nil
+	}
+
+	clients := make([]RateLimitClient, 0, len(counters))
+	for _, counter := range counters {
+		clients = append(clients, RateLimitClient{
+			IP:       strings.TrimPrefix(counter.Key, "req:"),
+			Count:    counter.Count,
+			Limited:  counter.Count >= rl.maxRequests,
+			ResetsAt: counter.ResetsAt,
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Count > clients[j].Count
+	})
+	if len(clients) > limit {
+		clients = clients[:limit]
+	}
+	return clients
+}
+
+// RecentRejections returns the most recent 429 responses from this
+// process, newest first.
+func (rl *RateLimiter) RecentRejections() []RateLimitRejection {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	recent := make([]RateLimitRejection, len(rl.rejections))
+	for i, r := range rl.rejections {
+		recent[len(rl.rejections)-1-i] = r
+	}
+	return recent
+}
+
+// Limit returns the configured request limit per window.
+func (rl *RateLimiter) Limit() (int, time.Duration) {
+	return rl.maxRequests, rl.window
+}
+
+// Store returns the store the limiter counts in, for sign-in limiters to
+// share.
+func (rl *RateLimiter) Store() RateLimitStore {
+	return rl.store
+}
+
+// LoginRateLimiter provides stricter rate limiting for login attempts.
+// Failures count for twice the lockout time; reaching maxAttempts locks the
+// identifier out for the lockout time.
+type LoginRateLimiter struct {
+	store       RateLimitStore
+	name        string
+	maxAttempts int
+	lockoutTime time.Duration
+}
+
+// NewLoginRateLimiter creates a rate limiter specifically for login
+// attempts. name keeps its counters apart from other limiters in store.
+func NewLoginRateLimiter(store RateLimitStore, name string, maxAttempts int, lockoutTime time.Duration) *LoginRateLimiter {
+	return &LoginRateLimiter{
+		store:       store,
+		name:        name,
+		maxAttempts: maxAttempts,
+		lockoutTime: lockoutTime,
+	}
+}
+
+func (lrl *LoginRateLimiter) failuresKey(identifier string) string {
+	return lrl.name + ":" + identifier
+}
+
+func (lrl *LoginRateLimiter) lockPrefix() string {
+	return lrl.name + "-lock:"
+}
+
+// Check returns true if login attempt should be allowed.
+func (lrl *LoginRateLimiter) Check(identifier string) (bool, time.Duration) {
+	lock, err := lrl.store.Get(context.Background(), lrl.lockPrefix()+identifier)
+	if err != nil {
+		logRateLimitError(err)
+		return true, 0
+	}
+	if lock == nil {
+		return true, 0
+	}
+	return false, time.Until(lock.ResetsAt)
+}
+
+// RecordFailure records a failed login attempt.
+func (lrl *LoginRateLimiter) RecordFailure(identifier string) {
+	ctx := context.Background()
+	failures, err := lrl.store.Increment(ctx, lrl.failuresKey(identifier), lrl.lockoutTime*2)
+	if err != nil {
+		logRateLimitError(err)
+		return
+	}
+	if failures.Count < lrl.maxAttempts {
+		return
+	}
+
+	lock := &models.RateLimitCounter{
+		Key:      lrl.lockPrefix() + identifier,
+		Count:    failures.Count,
+		ResetsAt: time.Now().Add(lrl.lockoutTime),
+	}
+	if err := lrl.store.Set(ctx, lock); err != nil {
+		logRateLimitError(err)
+		return
+	}
+	if err := lrl.store.Delete(ctx, failures.Key); err != nil {
+		logRateLimitError(err)
+	}
+}
+
+// RecordSuccess clears failed attempts after successful login.
+func (lrl *LoginRateLimiter) RecordSuccess(identifier string) {
+	ctx := context.Background()
+	for _, key := range []string{lrl.failuresKey(identifier), lrl.lockPrefix() + identifier} {
+		if err := lrl.store.Delete(ctx, key); err != nil {
+			logRateLimitError(err)
+		}
+	}
+}
+
+// LoginLockout describes a client currently locked out of logging in.
+type LoginLockout struct {
+	Identifier  string
+	Attempts    int
+	LockedUntil time.Time
+}
+
+// Lockouts returns all clients currently locked out.
+func (lrl *LoginRateLimiter) Lockouts() []LoginLockout {
+	locks, err := lrl.store.List(context.Background(), lrl.lockPrefix())
+	if err != nil {
+		logRateLimitError(err)
+		return nil
+	}
+
+	var lockouts []LoginLockout
+	for _, lock := range locks {
+		lockouts = append(lockouts, LoginLockout{
+			Identifier:  strings.TrimPrefix(lock.Key, lrl.lockPrefix()),
+			Attempts:    lock.Count,
+			LockedUntil: lock.ResetsAt,
+		})
+	}
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LockedUntil.After(lockouts[j].LockedUntil)
+	})
+	return lockouts
+}
+
+// Unlock clears a lockout before it expires.
+func (lrl *LoginRateLimiter) Unlock(identifier string) {
+	lrl.RecordSuccess(identifier)
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// RateLimitStore keeps the counters behind rate limits and sign-in
+// lockouts. Counters reset on their own once their window passes.
+type RateLimitStore interface {
+	// Increment adds one to the counter at key and returns it. A counter
+	// that is missing or has reset starts again at one, resetting after
+	// window.
+	Increment(ctx context.Context, key string, window time.Duration) (*models.RateLimitCounter, error)
+	// Get returns the counter at key, or nil if there is none or it has
+	// reset.
+	Get(ctx context.Context, key string) (*models.RateLimitCounter, error)
+	// Set replaces the counter at c.Key.
+	Set(ctx context.Context, c *models.RateLimitCounter) error
+	// Delete removes the counter at key.
+	Delete(ctx context.Context, key string) error
+	// List returns the counters whose keys start with prefix and haven't
+	// reset.
+	List(ctx context.Context, prefix string) ([]models.RateLimitCounter, error)
+}
+
+// NewRateLimitStore returns the store named by WIKI_RATE_LIMIT_STORE:
+// "database" keeps counters in db, anything else in memory.
+func NewRateLimitStore(kind string, db *database.DB) RateLimitStore {
+	if kind == "database" {
+		return NewDBRateLimitStore(db)
+	}
+	return NewMemoryRateLimitStore()
+}
+
+// rateLimitCleanupInterval is how often stores drop counters that have
+// reset.
+const rateLimitCleanupInterval = time.Minute
+
+// MemoryRateLimitStore keeps counters in this process. They are lost on
+// restart and not shared with other replicas.
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	counters map[string]models.RateLimitCounter
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{counters: make(map[string]models.RateLimitCounter)}
+	go s.cleanup()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (*models.RateLimitCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.ResetsAt) {
+		c = models.RateLimitCounter{Key: key, ResetsAt: now.Add(window)}
+	}
+	c.Count++
+	s.counters[key] = c
+	return &c, nil
+}
+
+func (s *MemoryRateLimitStore) Get(ctx context.Context, key string) (*models.RateLimitCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || !time.Now().Before(c.ResetsAt) {
+		return nil, nil
+	}
+	return &c, nil
+}
+
+func (s *MemoryRateLimitStore) Set(ctx context.Context, c *models.RateLimitCounter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[c.Key] = *c
+	return nil
+}
+
+func (s *MemoryRateLimitStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.counters, key)
+	return nil
+}
+
+func (s *MemoryRateLimitStore) List(ctx context.Context, prefix string) ([]models.RateLimitCounter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var counters []models.RateLimitCounter
+	for key, c := range s.counters {
+		if strings.HasPrefix(key, prefix) && now.Before(c.ResetsAt) {
+			counters = append(counters, c)
+		}
+	}
+	return counters, nil
+}
+
+// cleanup removes counters that have reset periodically.
+func (s *MemoryRateLimitStore) cleanup() {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		now := time.Now()
+		for key, c := range s.counters {
+			if !now.Before(c.ResetsAt) {
+				delete(s.counters, key)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// DBRateLimitStore keeps counters in the rate_limits table, so they
+// survive restarts and every replica sees the same counts. Each counted
+// request is a database write.
+type DBRateLimitStore struct {
+	db *database.DB
+}
+
+// NewDBRateLimitStore creates a store backed by db.
+func NewDBRateLimitStore(db *database.DB) *DBRateLimitStore {
+	s := &DBRateLimitStore{db: db}
+	go s.cleanup()
+	return s
+}
+
+func (s *DBRateLimitStore) Increment(ctx context.Context, key string, window time.Duration) (*models.RateLimitCounter, error) {
+	return s.db.IncrementRateLimit(ctx, key, window)
+}
+
+func (s *DBRateLimitStore) Get(ctx context.Context, key string) (*models.RateLimitCounter, error) {
+	return s.db.GetRateLimit(ctx, key)
+}
+
+func (s *DBRateLimitStore) Set(ctx context.Context, c *models.RateLimitCounter) error {
+	return s.db.SetRateLimit(ctx, c)
+}
+
+func (s *DBRateLimitStore) Delete(ctx context.Context, key string) error {
+	return s.db.DeleteRateLimit(ctx, key)
+}
+
+func (s *DBRateLimitStore) List(ctx context.Context, prefix string) ([]models.RateLimitCounter, error) {
+	return s.db.ListRateLimits(ctx, prefix)
+}
+
+// cleanup removes counters that have reset periodically.
+func (s *DBRateLimitStore) cleanup() {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_ = s.db.DeleteExpiredRateLimits(context.Background())
+	}
+}
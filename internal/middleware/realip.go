@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/config"
+)
+
+// IPExtractor returns how c.RealIP finds the client's address. Without
+// trusted proxies it is the connection's address, so clients can't claim
+// another one with X-Forwarded-For. With them, X-Forwarded-For is read from
+// the right, skipping the trusted proxies, and only for requests that came
+// through one.
+func IPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, proxy := range trustedProxies {
+		ipRange, err := config.ParseIPRange(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
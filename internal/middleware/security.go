@@ -4,17 +4,49 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/base64"
-	"fmt"
 	"net/http"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
-// SecurityHeaders middleware adds security-related HTTP headers.
-func SecurityHeaders() echo.MiddlewareFunc {
+// CSPReportPath is where browsers send Content Security Policy violation reports.
+const CSPReportPath = "/csp-report"
+
+// SecurityHeaders middleware adds security-related HTTP headers. With
+// strictReportOnly, a stricter policy without 'unsafe-inline' and
+// 'unsafe-eval' is sent in report-only mode, so violations of the policy the
+// wiki would like to enforce are reported without breaking pages.
+func SecurityHeaders(strictReportOnly bool) echo.MiddlewareFunc {
+	// Content Security Policy - restrictive but allows necessary functionality
+	csp := strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'unsafe-inline' 'unsafe-eval'",                // Allow inline scripts for HTMX, eval for Alpine.js
+		"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com", // Allow inline styles for Tailwind + Google Fonts
+		"img-src 'self' data: https:",                                   // Allow images from self, data URIs, and HTTPS
+		"font-src 'self' https://fonts.gstatic.com",                     // Allow Google Fonts
+		"connect-src 'self'",                                            // Allow AJAX/fetch to self
+		"frame-ancestors 'self'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"report-uri " + CSPReportPath, // Legacy reporting, still the only option in Firefox
+		"report-to csp-endpoint",
+	}, "; ")
+
+	strict := strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self'",
+		"style-src 'self' https://fonts.googleapis.com",
+		"img-src 'self' data: https:",
+		"font-src 'self' https://fonts.gstatic.com",
+		"connect-src 'self'",
+		"frame-ancestors 'self'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"report-uri " + CSPReportPath,
+		"report-to csp-endpoint",
+	}, "; ")
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			h := c.Response().Header()
@@ -34,19 +66,11 @@ func SecurityHeaders() echo.MiddlewareFunc {
 			// Permissions policy (formerly Feature-Policy)
 			h.Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
 
-			// Content Security Policy - restrictive but allows necessary functionality
-			csp := strings.Join([]string{
-				"default-src 'self'",
-				"script-src 'self' 'unsafe-inline' 'unsafe-eval'",                // Allow inline scripts for HTMX, eval for Alpine.js
-				"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com", // Allow inline styles for Tailwind + Google Fonts
-				"img-src 'self' data: https:",                                   // Allow images from self, data URIs, and HTTPS
-				"font-src 'self' https://fonts.gstatic.com",                     // Allow Google Fonts
-				"connect-src 'self'",                                            // Allow AJAX/fetch to self
-				"frame-ancestors 'self'",
-				"base-uri 'self'",
-				"form-action 'self'",
-			}, "; ")
 			h.Set("Content-Security-Policy", csp)
+			if strictReportOnly {
+				h.Set("Content-Security-Policy-Report-Only", strict)
+			}
+			h.Set("Reporting-Endpoints", `csp-endpoint="`+CSPReportPath+`"`)
 
 			return next(c)
 		}
@@ -57,6 +81,8 @@ func SecurityHeaders() echo.MiddlewareFunc {
 type CSRF struct {
 	sessionManager *SessionManager
 	tokenLength    int
+	exempt         map[string]bool
+	exemptPrefixes []string
 }
 
 // NewCSRF creates a new CSRF protection middleware.
@@ -64,7 +90,36 @@ func NewCSRF(sm *SessionManager) *CSRF {
 	return &CSRF{
 		sessionManager: sm,
 		tokenLength:    32,
+		exempt:         make(map[string]bool),
+	}
+}
+
+// Exempt disables CSRF validation for the given paths. Only use it for
+// endpoints that browsers post to without a page, such as CSP reports.
+func (csrf *CSRF) Exempt(paths ...string) {
+	for _, path := range paths {
+		csrf.exempt[path] = true
+	}
+}
+
+// ExemptPrefix disables CSRF validation for every path under prefix. Only
+// use it for routes that never authenticate with cookies, such as the
+// bearer-token API.
+func (csrf *CSRF) ExemptPrefix(prefix string) {
+	csrf.exemptPrefixes = append(csrf.exemptPrefixes, prefix)
+}
+
+// isExempt reports whether path skips CSRF validation.
+func (csrf *CSRF) isExempt(path string) bool {
+	if csrf.exempt[path] {
+		return true
 	}
+	for _, prefix := range csrf.exemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 // Middleware returns the CSRF middleware function.
@@ -82,6 +137,10 @@ func (csrf *CSRF) Middleware() echo.MiddlewareFunc {
 				return next(c)
 			}
 
+			if csrf.isExempt(c.Request().URL.Path) {
+				return next(c)
+			}
+
 			// Validate CSRF token for unsafe methods
 			session, err := csrf.sessionManager.GetSession(c)
 			if err != nil {
@@ -169,198 +228,3 @@ func GetCSRFToken(c echo.Context) string {
 	}
 	return token
 }
-
-// RateLimiter provides request rate limiting.
-type RateLimiter struct {
-	requests    map[string]*rateLimitEntry
-	mu          sync.RWMutex
-	maxRequests int
-	window      time.Duration
-}
-
-type rateLimitEntry struct {
-	count     int
-	expiresAt time.Time
-}
-
-// NewRateLimiter creates a new rate limiter.
-func NewRateLimiter(maxRequests int, window time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		requests:    make(map[string]*rateLimitEntry),
-		maxRequests: maxRequests,
-		window:      window,
-	}
-
-	// Start cleanup goroutine
-	go rl.cleanup()
-
-	return rl
-}
-
-// Middleware returns the rate limiting middleware.
-func (rl *RateLimiter) Middleware() echo.MiddlewareFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			// Get client identifier (IP address)
-			clientIP := c.RealIP()
-
-			// Check rate limit
-			if !rl.allow(clientIP) {
-				c.Response().Header().Set("Retry-After", fmt.Sprintf("%d", int(rl.window.Seconds())))
-				return echo.NewHTTPError(http.StatusTooManyRequests, "Rate limit exceeded")
-			}
-
-			return next(c)
-		}
-	}
-}
-
-// allow checks if a request should be allowed.
-func (rl *RateLimiter) allow(clientID string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-
-	entry, exists := rl.requests[clientID]
-	if !exists || now.After(entry.expiresAt) {
-		// Create new entry
-		rl.requests[clientID] = &rateLimitEntry{
-			count:     1,
-			expiresAt: now.Add(rl.window),
-		}
-		return true
-	}
-
-	// Check if limit exceeded
-	if entry.count >= rl.maxRequests {
-		return false
-	}
-
-	entry.count++
-	return true
-}
-
-// cleanup removes expired entries periodically.
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(rl.window)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for key, entry := range rl.requests {
-			if now.After(entry.expiresAt) {
-				delete(rl.requests, key)
-			}
-		}
-		rl.mu.Unlock()
-	}
-}
-
-// LoginRateLimiter provides stricter rate limiting for login attempts.
-type LoginRateLimiter struct {
-	attempts    map[string]*loginAttempt
-	mu          sync.RWMutex
-	maxAttempts int
-	lockoutTime time.Duration
-}
-
-type loginAttempt struct {
-	count     int
-	lockedAt  time.Time
-	lastTry   time.Time
-}
-
-// NewLoginRateLimiter creates a rate limiter specifically for login attempts.
-func NewLoginRateLimiter(maxAttempts int, lockoutTime time.Duration) *LoginRateLimiter {
-	lrl := &LoginRateLimiter{
-		attempts:    make(map[string]*loginAttempt),
-		maxAttempts: maxAttempts,
-		lockoutTime: lockoutTime,
-	}
-
-	go lrl.cleanup()
-
-	return lrl
-}
-
-// Check returns true if login attempt should be allowed.
-func (lrl *LoginRateLimiter) Check(identifier string) (bool, time.Duration) {
-	lrl.mu.Lock()
-	defer lrl.mu.Unlock()
-
-	now := time.Now()
-
-	attempt, exists := lrl.attempts[identifier]
-	if !exists {
-		lrl.attempts[identifier] = &loginAttempt{
-			count:   0,
-			lastTry: now,
-		}
-		return true, 0
-	}
-
-	// Check if in lockout period
-	if !attempt.lockedAt.IsZero() {
-		remaining := attempt.lockedAt.Add(lrl.lockoutTime).Sub(now)
-		if remaining > 0 {
-			return false, remaining
-		}
-		// Lockout expired, reset
-		attempt.count = 0
-		attempt.lockedAt = time.Time{}
-	}
-
-	return true, 0
-}
-
-// RecordFailure records a failed login attempt.
-func (lrl *LoginRateLimiter) RecordFailure(identifier string) {
-	lrl.mu.Lock()
-	defer lrl.mu.Unlock()
-
-	now := time.Now()
-
-	attempt, exists := lrl.attempts[identifier]
-	if !exists {
-		lrl.attempts[identifier] = &loginAttempt{
-			count:   1,
-			lastTry: now,
-		}
-		return
-	}
-
-	attempt.count++
-	attempt.lastTry = now
-
-	if attempt.count >= lrl.maxAttempts {
-		attempt.lockedAt = now
-	}
-}
-
-// RecordSuccess clears failed attempts after successful login.
-func (lrl *LoginRateLimiter) RecordSuccess(identifier string) {
-	lrl.mu.Lock()
-	defer lrl.mu.Unlock()
-
-	delete(lrl.attempts, identifier)
-}
-
-// cleanup removes old entries.
-func (lrl *LoginRateLimiter) cleanup() {
-	ticker := time.NewTicker(lrl.lockoutTime)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		lrl.mu.Lock()
-		now := time.Now()
-		for key, attempt := range lrl.attempts {
-			// Remove entries that haven't been used in a while
-			if now.Sub(attempt.lastTry) > lrl.lockoutTime*2 {
-				delete(lrl.attempts, key)
-			}
-		}
-		lrl.mu.Unlock()
-	}
-}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"encoding/base32"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+const (
+	// sessionIDKey holds the session ID in the cookie of a stored session.
+	sessionIDKey = "_session_id"
+	// sessionUserIDKey holds the signed-in user's ID in the session values.
+	sessionUserIDKey = "user_id"
+	// sessionTouchInterval limits how often last_seen_at is written.
+	sessionTouchInterval = time.Minute
+	// sessionDefaultTTL is how long a stored browser-session cookie
+	// (MaxAge 0) stays valid on the server.
+	sessionDefaultTTL = 24 * time.Hour
+)
+
+// DBStore is a sessions.Store that keeps signed-in sessions in the sessions
+// table, so users can see where they are signed in and revoke a session from
+// anywhere. The cookie of a stored session carries only its signed ID.
+// Sessions without a user, such as a visitor's CSRF token, stay in the cookie
+// like a sessions.CookieStore so anonymous traffic never writes to the
+// database.
+type DBStore struct {
+	db      *database.DB
+	codecs  []securecookie.Codec
+	options *sessions.Options
+}
+
+// NewDBStore creates a store that signs cookies and stored values with
+// keyPairs, as sessions.NewCookieStore does.
+func NewDBStore(db *database.DB, options *sessions.Options, keyPairs ...[]byte) *DBStore {
+	return &DBStore{
+		db:      db,
+		codecs:  securecookie.CodecsFromPairs(keyPairs...),
+		options: options,
+	}
+}
+
+// Get returns the session for name, loading it once per request.
+func (s *DBStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie. A stored session
+// that was revoked or has expired comes back empty, which signs the user
+// out.
+func (s *DBStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	values := make(map[interface{}]interface{})
+	if err := securecookie.DecodeMulti(name, cookie.Value, &values, s.codecs...); err != nil {
+		return session, err
+	}
+
+	id, ok := values[sessionIDKey].(string)
+	if !ok {
+		session.Values = values
+		session.IsNew = false
+		return session, nil
+	}
+
+	stored, err := s.db.GetSession(r.Context(), id)
+	if err != nil {
+		return session, err
+	}
+	if stored == nil || stored.IsExpired() {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, stored.Data, &session.Values, s.codecs...); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+
+	ip, userAgent := sessionClient(r)
+	if time.Since(stored.LastSeenAt) > sessionTouchInterval || stored.IPAddress != ip {
+		_ = s.db.TouchSession(r.Context(), id, ip, userAgent)
+	}
+
+	return session, nil
+}
+
+// Save writes a signed-in session to the database and its ID to the
+// cookie, or the values themselves to the cookie for anonymous sessions.
+// A negative MaxAge deletes the session.
+func (s *DBStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	ctx := r.Context()
+
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.db.DeleteSession(ctx, session.ID); err != nil {
+				return err
+			}
+			session.ID = ""
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	payload := session.Values
+	if userID, ok := session.Values[sessionUserIDKey].(int64); ok {
+		if session.ID == "" {
+			session.ID = newSessionID()
+			// New sign-ins are rare enough to clear out expired sessions
+			_, _ = s.db.DeleteExpiredSessions(ctx)
+		}
+
+		data, err := securecookie.EncodeMulti(session.Name(), session.Values, s.codecs...)
+		if err != nil {
+			return err
+		}
+
+		ttl := time.Duration(session.Options.MaxAge) * time.Second
+		if ttl == 0 {
+			ttl = sessionDefaultTTL
+		}
+		ip, userAgent := sessionClient(r)
+		err = s.db.SaveSession(ctx, &models.Session{
+			ID:        session.ID,
+			UserID:    userID,
+			Data:      data,
+			IPAddress: ip,
+			UserAgent: userAgent,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+		if err != nil {
+			return err
+		}
+		payload = map[interface{}]interface{}{sessionIDKey: session.ID}
+	} else if session.ID != "" {
+		// The user was removed from the session without clearing it
+		if err := s.db.DeleteSession(ctx, session.ID); err != nil {
+			return err
+		}
+		session.ID = ""
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), payload, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// newSessionID returns a random session ID.
+func newSessionID() string {
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)), "=")
+}
+
+// sessionClient returns the client address and user agent recorded with a
+// session. AuthMiddleware puts the address echo resolved, honoring proxy
+// headers, in the request context.
+func sessionClient(r *http.Request) (ip, userAgent string) {
+	ip, _ = r.Context().Value(clientIPContextKey).(string)
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}
+	userAgent = r.UserAgent()
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+	return SanitizeIP(ip), userAgent
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/labstack/echo/v4"
 
@@ -10,15 +11,22 @@ import (
 )
 
 // SetupRequired creates middleware that redirects to setup if not complete.
+// Setup can't be undone, so once it is complete the check is skipped.
 func SetupRequired(db *database.DB) echo.MiddlewareFunc {
+	var complete atomic.Bool
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
+			if complete.Load() {
+				return next(c)
+			}
+
 			path := c.Request().URL.Path
 
 			// Always allow static files and setup routes
 			if strings.HasPrefix(path, "/static/") ||
 				strings.HasPrefix(path, "/setup") ||
-				path == "/health" {
+				path == "/health" || path == "/healthz" || path == "/readyz" {
 				return next(c)
 			}
 
@@ -34,6 +42,7 @@ func SetupRequired(db *database.DB) echo.MiddlewareFunc {
 				return c.Redirect(http.StatusSeeOther, "/setup")
 			}
 
+			complete.Store(true)
 			return next(c)
 		}
 	}
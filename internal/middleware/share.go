@@ -2,9 +2,12 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"net"
 	"strings"
 
@@ -12,6 +15,7 @@ import (
 
 	"gowiki/internal/database"
 	"gowiki/internal/models"
+	"gowiki/internal/policy"
 )
 
 // Share context keys
@@ -111,6 +115,12 @@ func validateShareLink(ctx context.Context, db *database.DB, link *models.ShareL
 		return false
 	}
 
+	// Check group membership
+	if !policy.CanOpenShare(GetUser(c), link) {
+		shareCtx.InvalidReason = "This share link is only for members of the " + link.GroupName + " group"
+		return false
+	}
+
 	// Check IP limit
 	if link.MaxIPs != nil {
 		ipAddress := SanitizeIP(c.RealIP())
@@ -200,6 +210,22 @@ func CanAccessPageViaShare(c echo.Context, db *database.DB, pageSlug string) boo
 		return false
 	}
 
+	// Tag and search links reach the pages they list
+	if shareCtx.Link.IsCollection() {
+		collection, err := db.ListShareCollection(c.Request().Context(), shareCtx.Link, models.MaxShareCollectionPages)
+		if err != nil {
+			c.Logger().Errorf("failed to list shared pages: %v", err)
+			return false
+		}
+		for _, p := range collection {
+			if strings.EqualFold(p.Slug, pageSlug) {
+				shareCtx.RequestedSlug = pageSlug
+				return true
+			}
+		}
+		return false
+	}
+
 	// Direct match on the shared page
 	if strings.EqualFold(shareCtx.Link.PageSlug, pageSlug) {
 		return true
@@ -233,6 +259,16 @@ func HashToken(token string) string {
 	return hashToken(token)
 }
 
+// GenerateShareToken generates a new share link token: 32 random bytes,
+// base64url encoded without padding.
+func GenerateShareToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
 // isValidTokenFormat checks if a token has a valid format.
 // Tokens are base64url encoded 32 bytes = 43 characters.
 func isValidTokenFormat(token string) bool {
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+
+	"gowiki/internal/models"
+)
+
+// Appearance is how pages look to the current request: the admin's site
+// theme and the user's light or dark preference.
+type Appearance struct {
+	Site models.SiteTheme
+	// Scheme is the user's models.ThemeLight or ThemeDark, or empty to
+	// follow the browser
+	Scheme string
+	// SignedIn users have their scheme saved to their account; visitors
+	// keep theirs in the browser
+	SignedIn bool
+}
+
+type appearanceKey struct{}
+
+// Theme puts the Appearance for the current user in the request context,
+// for the templates. A failure to load the site theme falls back to the
+// built-in look.
+func Theme(load func(context.Context) (models.SiteTheme, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var a Appearance
+			a.Site, _ = load(c.Request().Context())
+			if user := GetUser(c); user != nil {
+				a.Scheme, a.SignedIn = user.Theme, true
+			}
+			ctx := context.WithValue(c.Request().Context(), appearanceKey{}, a)
+			c.SetRequest(c.Request().WithContext(ctx))
+			return next(c)
+		}
+	}
+}
+
+// GetAppearance returns the Appearance ctx carries, or the built-in look
+// following the browser.
+func GetAppearance(ctx context.Context) Appearance {
+	a, _ := ctx.Value(appearanceKey{}).(Appearance)
+	return a
+}
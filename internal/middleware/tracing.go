@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"gowiki/internal/tracing"
+)
+
+// TraceIDKey is the context key for the trace ID.
+const TraceIDKey = "trace_id"
+
+// Tracing starts a server span for each request, continuing any incoming
+// W3C traceparent, and exposes the trace ID via the X-Trace-ID header.
+// Spans are named after the route template rather than the requested path,
+// which may carry share tokens and other secrets.
+func Tracing() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !tracing.Enabled() {
+				return next(c)
+			}
+			req := c.Request()
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracing.Tracer().Start(ctx, req.Method+" "+route, trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+
+			traceID := span.SpanContext().TraceID().String()
+			c.SetRequest(req.WithContext(ctx))
+			c.Set(TraceIDKey, traceID)
+			c.Response().Header().Set("X-Trace-ID", traceID)
+
+			span.SetAttributes(
+				attribute.String("http.method", req.Method),
+				attribute.String("http.route", route),
+				attribute.String("net.peer.ip", c.RealIP()),
+				attribute.String("gowiki.request_id", GetRequestID(c)),
+			)
+
+			err := next(c)
+
+			status := c.Response().Status
+			if err != nil {
+				if he, ok := err.(*echo.HTTPError); ok {
+					status = he.Code
+				} else {
+					status = http.StatusInternalServerError
+				}
+			}
+			span.SetAttributes(attribute.Int("http.status_code", status))
+			if status >= 500 {
+				if err != nil {
+					tracing.RecordError(span, err)
+				} else {
+					span.SetStatus(codes.Error, http.StatusText(status))
+				}
+			}
+			if user := GetUser(c); user != nil {
+				span.SetAttributes(attribute.String("enduser.id", user.Username))
+			}
+
+			return err
+		}
+	}
+}
+
+// GetTraceID returns the trace ID for the request, or "" when tracing is off.
+func GetTraceID(c echo.Context) string {
+	if id, ok := c.Get(TraceIDKey).(string); ok {
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PageViewDay is the number of page views on one day (UTC).
+type PageViewDay struct {
+	Day   time.Time `json:"day"`
+	Views int64     `json:"views"`
+}
+
+// PageViewStat is a page's view count over a period.
+type PageViewStat struct {
+	PageID int64  `json:"page_id"`
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	// Views counts views in the period, TotalViews every view recorded.
+	Views        int64      `json:"views"`
+	TotalViews   int64      `json:"total_views"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// AnnouncementLevel controls how prominently an announcement is shown.
+type AnnouncementLevel string
+
+const (
+	AnnouncementInfo     AnnouncementLevel = "info"
+	AnnouncementWarning  AnnouncementLevel = "warning"
+	AnnouncementCritical AnnouncementLevel = "critical"
+)
+
+// IsValid reports whether the level is known.
+func (l AnnouncementLevel) IsValid() bool {
+	switch l {
+	case AnnouncementInfo, AnnouncementWarning, AnnouncementCritical:
+		return true
+	}
+	return false
+}
+
+// Announcement is a site-wide banner shown at the top of every page.
+type Announcement struct {
+	ID          string            `json:"id"`
+	Message     string            `json:"message"`
+	Level       AnnouncementLevel `json:"level"`
+	Dismissible bool              `json:"dismissible"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	CreatedBy   int64             `json:"created_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// IsExpired reports whether the announcement's expiry has passed.
+func (a *Announcement) IsExpired(now time.Time) bool {
+	return a.ExpiresAt != nil && !now.Before(*a.ExpiresAt)
+}
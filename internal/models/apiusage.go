@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// APICall is one request made with an API token.
+type APICall struct {
+	TokenID   int64     `json:"token_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"` // Route pattern, e.g. /api/v1/pages/:slug
+	Status    int       `json:"status"`
+	IPAddress string    `json:"ip_address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsError reports whether the call failed.
+func (c APICall) IsError() bool {
+	return c.Status >= 400
+}
+
+// APITokenUsage summarizes recent activity for an API token.
+type APITokenUsage struct {
+	TokenID     int64        `json:"token_id"`
+	TokenName   string       `json:"token_name"`
+	Username    string       `json:"username"`
+	LastUsedAt  sql.NullTime `json:"last_used_at"`
+	Requests24h int          `json:"requests_24h"`
+	Errors24h   int          `json:"errors_24h"`
+	Requests7d  int          `json:"requests_7d"`
+	Errors7d    int          `json:"errors_7d"`
+	// DistinctIPs counts the client addresses among the recent calls.
+	DistinctIPs int       `json:"distinct_ips"`
+	RecentCalls []APICall `json:"recent_calls,omitempty"`
+	// Flags describe unusual activity that may indicate a leaked token.
+	Flags []string `json:"flags,omitempty"`
+}
+
+// ErrorRate returns the share of failed requests over the last 7 days.
+func (u *APITokenUsage) ErrorRate() float64 {
+	if u.Requests7d == 0 {
+		return 0
+	}
+	return float64(u.Errors7d) / float64(u.Requests7d)
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// AuditEntry is a record in the audit log.
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	UserID     *int64    `json:"user_id,omitempty"`
+	Username   string    `json:"username,omitempty"` // empty for deleted users and system events
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   *int64    `json:"entity_id,omitempty"`
+	Details    string    `json:"details,omitempty"` // JSON object
+	IPAddress  string    `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditFilter selects audit log entries. Zero fields match everything.
+type AuditFilter struct {
+	Username   string // case-insensitive
+	Action     string
+	EntityType string
+	Since      *time.Time // inclusive
+	Until      *time.Time // exclusive
+
+	Limit  int // 0 lists every matching entry
+	Offset int
+}
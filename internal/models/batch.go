@@ -0,0 +1,25 @@
+package models
+
+// Page batch actions.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// PageBatchWrite is one page write in a batch applied by a single
+// transaction.
+type PageBatchWrite struct {
+	Action string
+	// Page holds the page to create, the updated page, or the page to
+	// delete. Creates fill in its ID, timestamps and parent.
+	Page *Page
+	// Tags replace the page's tags; nil keeps them.
+	Tags []string
+	// Links replace the page's outgoing links; nil keeps them, for updates
+	// that don't change the content.
+	Links []PageLink
+	// PreviousContent is the content an update replaces, kept as a
+	// revision.
+	PreviousContent string
+}
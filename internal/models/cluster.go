@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ClusterLock is a named lease held by one replica until it expires.
+type ClusterLock struct {
+	Name       string
+	Owner      string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+// ClusterEvent is a message broadcast to all replicas, such as a cache invalidation.
+type ClusterEvent struct {
+	ID        int64
+	Topic     string
+	Payload   string
+	Origin    string
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// CSP report dispositions
+const (
+	CSPDispositionEnforce = "enforce"
+	CSPDispositionReport  = "report"
+)
+
+// CSPReport is a Content Security Policy violation reported by a browser.
+type CSPReport struct {
+	ID          int64
+	DocumentURI string
+	Directive   string
+	BlockedURI  string
+	SourceFile  string
+	LineNumber  int
+	Disposition string
+	UserAgent   string
+	CreatedAt   time.Time
+}
+
+// CSPReportSummary groups violations of one directive by the same blocked resource.
+type CSPReportSummary struct {
+	Directive      string
+	BlockedURI     string
+	Disposition    string
+	Count          int
+	Documents      int
+	SampleDocument string
+	SampleSource   string
+	LastSeen       time.Time
+}
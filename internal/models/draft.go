@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// PageDraft holds a page's unpublished changes: a working copy of its title,
+// content and tags that editors can keep revising without touching the live
+// page until it is published.
+type PageDraft struct {
+	PageID    int64     `json:"page_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	AuthorID  *int64    `json:"author_id,omitempty"`
+	Author    string    `json:"author,omitempty"` // username of the last editor
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Update returns the page update that publishes the draft.
+func (d *PageDraft) Update() PageUpdate {
+	return PageUpdate{Title: &d.Title, Content: &d.Content, Tags: d.Tags}
+}
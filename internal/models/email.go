@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Email template keys.
+const (
+	EmailNotification  = "notification"
+	EmailInvite        = "invite"
+	EmailPasswordReset = "password_reset"
+	EmailVerify        = "verify_email"
+	EmailShareLink     = "share_link"
+)
+
+// EmailTemplate is an editable email. Subject and BodyText use Go text
+// templates and BodyHTML an HTML template, e.g. "Hello {{.Username}}".
+// An empty Locale is the default used when no variant matches.
+type EmailTemplate struct {
+	Key       string    `json:"key"`
+	Locale    string    `json:"locale"`
+	Subject   string    `json:"subject"`
+	BodyText  string    `json:"body_text"`
+	BodyHTML  string    `json:"body_html"`
+	UpdatedBy *int64    `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Customized is false for built-in templates that were never edited.
+	Customized bool `json:"customized"`
+}
+
+// EmailTemplateInfo describes a kind of email the wiki sends.
+type EmailTemplateInfo struct {
+	Key         string
+	Name        string
+	Description string
+	// Variables lists the template fields with an example value, used for
+	// the editor help and for previews.
+	Variables map[string]string
+}
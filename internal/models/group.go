@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Group is a named set of users. Pages can be restricted to groups and
+// share links can be shared with one.
+type Group struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// GroupMember is a user's membership in a group.
+type GroupMember struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	Role     Role      `json:"role"`
+	AddedAt  time.Time `json:"added_at"`
+}
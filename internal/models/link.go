@@ -0,0 +1,38 @@
+package models
+
+// Link kinds recorded in the link graph.
+const (
+	// LinkKindWiki is a [[wiki-link]].
+	LinkKindWiki = "wiki"
+	// LinkKindMarkdown is a regular markdown link to /wiki/<slug>.
+	LinkKindMarkdown = "markdown"
+	// LinkKindInclude is an {{include:<slug>}} macro.
+	LinkKindInclude = "include"
+)
+
+// PageLink is an internal link from a page to another page's slug.
+type PageLink struct {
+	TargetSlug string `json:"target_slug"`
+	Kind       string `json:"kind"`
+}
+
+// LinkEdge is a link between two existing pages.
+type LinkEdge struct {
+	SourceID int64
+	TargetID int64
+}
+
+// BrokenLink is an internal link whose target page does not exist.
+type BrokenLink struct {
+	SourceID    int64  `json:"source_id"`
+	SourceSlug  string `json:"source_slug"`
+	SourceTitle string `json:"source_title"`
+	TargetSlug  string `json:"target_slug"`
+	Kind        string `json:"kind"`
+}
+
+// WantedPage is a missing page that other pages link to.
+type WantedPage struct {
+	Slug    string        `json:"slug"`
+	Sources []PageSummary `json:"sources"`
+}
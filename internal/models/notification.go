@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Notification kinds
+const (
+	NotificationMention        = "mention"
+	NotificationReviewRequest  = "review_request"
+	NotificationReviewApproved = "review_approved"
+)
+
+// Notification is a message shown to a user on their dashboard.
+type Notification struct {
+	ID        int64
+	UserID    int64
+	ActorID   *int64
+	PageID    *int64
+	Kind      string
+	Message   string
+	IsRead    bool
+	CreatedAt time.Time
+
+	// Joined fields for display
+	ActorUsername string
+	PageTitle     string
+	PageSlug      string
+}
+
+// Review request statuses
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+)
+
+// ReviewRequest asks a user to review changes made to a page.
+type ReviewRequest struct {
+	ID          int64
+	PageID      int64
+	RequesterID int64
+	ReviewerID  int64
+	Comment     string
+	Status      string
+	CreatedAt   time.Time
+	ResolvedAt  *time.Time
+
+	// Joined fields for display
+	PageTitle         string
+	PageSlug          string
+	RequesterUsername string
+}
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"time"
 )
 
@@ -10,8 +13,12 @@ type Page struct {
 	ID          int64        `json:"id"`
 	Slug        string       `json:"slug"`
 	Title       string       `json:"title"`
-	Content     string       `json:"content"`      // Raw markdown
-	ContentHTML string       `json:"content_html"` // Rendered HTML
+	Content     string       `json:"content"`                // Raw markdown
+	ContentHTML string       `json:"content_html"`           // Rendered HTML
+	ContentHash string       `json:"content_hash,omitempty"` // SHA-256 of Content, set by the API
+	Excerpt     string       `json:"excerpt,omitempty"`      // Plain-text opening of Content
+	WordCount   int          `json:"word_count"`             // Words of prose in Content
+	ReadingTime int          `json:"reading_minutes"`        // Set by the API
 	AuthorID    int64        `json:"author_id"`
 	Author      *User        `json:"author,omitempty"`
 	ParentID    *int64       `json:"parent_id,omitempty"`
@@ -21,7 +28,102 @@ type Page struct {
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 	PublishedAt sql.NullTime `json:"published_at,omitempty"`
+	PublishAt   sql.NullTime `json:"publish_at,omitempty"` // When an unpublished page goes live
+	ArchivedAt  sql.NullTime `json:"archived_at,omitempty"`
 	Tags        []Tag        `json:"tags,omitempty"`
+	Groups      []Group      `json:"groups,omitempty"`    // Restricts the page to these groups' members
+	HasDraft    bool         `json:"has_draft,omitempty"` // Unpublished changes exist, set by the API for editors
+	Display     TimeDisplays `json:"display,omitempty"`   // Set by the API
+}
+
+// TimeDisplay is a timestamp as the API caller reads it, in their timezone
+// and locale. The timestamp itself stays in UTC.
+type TimeDisplay struct {
+	Local    string `json:"local"`    // RFC 3339 with the caller's UTC offset
+	Text     string `json:"text"`     // e.g. "Jan 2, 2006, 3:04 PM"
+	Relative string `json:"relative"` // e.g. "2 hours ago"
+}
+
+// TimeDisplays maps the JSON names of a value's timestamps to how they
+// display.
+type TimeDisplays map[string]TimeDisplay
+
+// HashContent returns the hex SHA-256 of markdown content, which API
+// clients compare to detect changes without diffing the content.
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// WordsPerMinute is the reading speed reading time estimates assume.
+const WordsPerMinute = 200
+
+// ReadingMinutes estimates how many minutes reading words takes, rounded
+// up so any content takes at least a minute.
+func ReadingMinutes(words int) int {
+	if words <= 0 {
+		return 0
+	}
+	return (words + WordsPerMinute - 1) / WordsPerMinute
+}
+
+// ETag returns a strong entity tag for the stored page. It changes whenever
+// the page is edited, archived or unarchived, so API clients can send it
+// back in If-None-Match and If-Match.
+func (p *Page) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%d:%t:%d:", p.ID, p.UpdatedAt.UnixNano(), p.ArchivedAt.Valid, p.ArchivedAt.Time.UnixNano())
+	h.Write([]byte(p.Content))
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// SchedulePublish sets when the page goes live. A time after now keeps
+// the page unpublished until then, an earlier one publishes it now, and
+// the zero time cancels the schedule.
+func (p *Page) SchedulePublish(at, now time.Time) {
+	switch {
+	case at.IsZero():
+		p.PublishAt = sql.NullTime{}
+	case at.After(now):
+		p.IsPublished = false
+		p.PublishAt = sql.NullTime{Time: at.UTC(), Valid: true}
+	default:
+		p.Publish(now)
+	}
+}
+
+// Publish makes the page live, cancelling any scheduled publication.
+func (p *Page) Publish(now time.Time) {
+	p.IsPublished = true
+	p.PublishAt = sql.NullTime{}
+	if !p.PublishedAt.Valid {
+		p.PublishedAt = sql.NullTime{Time: now.UTC(), Valid: true}
+	}
+}
+
+// IsScheduled reports whether the page is waiting to be published.
+func (p *Page) IsScheduled() bool {
+	return !p.IsPublished && p.PublishAt.Valid
+}
+
+// IsArchived reports whether the page has been archived.
+func (p *Page) IsArchived() bool {
+	return p.ArchivedAt.Valid
+}
+
+// IsRestricted reports whether the page is limited to members of groups.
+func (p *Page) IsRestricted() bool {
+	return len(p.Groups) > 0
+}
+
+// HasGroup reports whether the page is restricted to the group.
+func (p *Page) HasGroup(groupID int64) bool {
+	for _, g := range p.Groups {
+		if g.ID == groupID {
+			return true
+		}
+	}
+	return false
 }
 
 // PageCreate contains data for creating a new page.
@@ -31,6 +133,8 @@ type PageCreate struct {
 	Content  string   `json:"content"`
 	ParentID *int64   `json:"parent_id,omitempty"`
 	Tags     []string `json:"tags,omitempty"`
+	// PublishAt, if in the future, keeps the page unpublished until then
+	PublishAt *time.Time `json:"publish_at,omitempty"`
 }
 
 // PageUpdate contains data for updating a page.
@@ -41,36 +145,99 @@ type PageUpdate struct {
 	ParentID    *int64   `json:"parent_id,omitempty"`
 	IsPublished *bool    `json:"is_published,omitempty"`
 	Tags        []string `json:"tags,omitempty"`
+	// PublishAt schedules the page; see Page.SchedulePublish
+	PublishAt *time.Time `json:"publish_at,omitempty"`
 }
 
 // PageSummary contains minimal page info for listings.
 type PageSummary struct {
-	ID        int64     `json:"id"`
-	Slug      string    `json:"slug"`
-	Title     string    `json:"title"`
-	Excerpt   string    `json:"excerpt"`
-	ParentID  *int64    `json:"parent_id,omitempty"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Author    string    `json:"author"`
+	ID          int64        `json:"id"`
+	Slug        string       `json:"slug"`
+	Title       string       `json:"title"`
+	Excerpt     string       `json:"excerpt"`
+	WordCount   int          `json:"word_count"`
+	ReadingTime int          `json:"reading_minutes"` // Set by the API
+	ParentID    *int64       `json:"parent_id,omitempty"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Author      string       `json:"author"`
+	ArchivedAt  *time.Time   `json:"archived_at,omitempty"`
+	Display     TimeDisplays `json:"display,omitempty"`
 }
 
 // Revision represents a page version in history.
 type Revision struct {
-	ID        int64     `json:"id"`
-	PageID    int64     `json:"page_id"`
-	Content   string    `json:"content"`
-	AuthorID  int64     `json:"author_id"`
-	Author    *User     `json:"author,omitempty"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64        `json:"id"`
+	PageID    int64        `json:"page_id"`
+	Content   string       `json:"content"`
+	AuthorID  int64        `json:"author_id"`
+	Author    *User        `json:"author,omitempty"`
+	Comment   string       `json:"comment"`
+	CreatedAt time.Time    `json:"created_at"`
+	Display   TimeDisplays `json:"display,omitempty"`
 }
 
 // RevisionSummary contains minimal revision info for history lists.
 type RevisionSummary struct {
-	ID        int64     `json:"id"`
-	Author    string    `json:"author"`
-	Comment   string    `json:"comment"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64        `json:"id"`
+	Number    int          `json:"number"` // Position in the page's history, counting from 1
+	Author    string       `json:"author"`
+	Comment   string       `json:"comment"`
+	CreatedAt time.Time    `json:"created_at"`
+	Display   TimeDisplays `json:"display,omitempty"`
+}
+
+// PageRevisionCount is the size of a page's stored history.
+type PageRevisionCount struct {
+	PageID    int64     `json:"page_id"`
+	Slug      string    `json:"slug"`
+	Title     string    `json:"title"`
+	Revisions int64     `json:"revisions"`
+	Oldest    time.Time `json:"oldest"`
+}
+
+// PageActivity summarizes how often a page is edited and read, which
+// drives its sitemap entry and cache lifetime.
+type PageActivity struct {
+	PageID      int64     `json:"page_id"`
+	Slug        string    `json:"slug"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Archived    bool      `json:"archived"`
+	RecentEdits int64     `json:"recent_edits"`
+	Views       int64     `json:"views"`
+}
+
+// RecentChange is one edit in the wiki-wide change log.
+type RecentChange struct {
+	RevisionID int64     `json:"revision_id"`
+	PageID     int64     `json:"page_id"`
+	PageSlug   string    `json:"page_slug"`
+	PageTitle  string    `json:"page_title"`
+	Author     string    `json:"author"`
+	Comment    string    `json:"comment"`
+	IsNew      bool      `json:"is_new"` // True for the edit that created the page
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ChangeFilter contains filter options for the recent changes log.
+type ChangeFilter struct {
+	Tag           string
+	Author        string
+	PublishedOnly bool
+	// As in PageFilter, restricted pages are hidden unless in MemberOf
+	HideRestricted bool
+	MemberOf       []int64
+	Limit          int
+	Offset         int
+}
+
+// RevisionFilter selects revisions of a page. Zero fields match everything.
+type RevisionFilter struct {
+	Author string     // case-insensitive
+	Since  *time.Time // inclusive
+	Until  *time.Time // exclusive
+
+	Limit  int // 0 lists every matching revision
+	Offset int
 }
 
 // Tag represents a page tag.
@@ -78,40 +245,57 @@ type Tag struct {
 	ID        int64  `json:"id"`
 	Name      string `json:"name"`
 	PageCount int    `json:"page_count,omitempty"`
+	ParentID  *int64 `json:"parent_id,omitempty"` // Broader tag this one falls under
+	Parent    string `json:"parent,omitempty"`    // Parent tag's name
 }
 
 // Attachment represents a file attached to a page.
 type Attachment struct {
 	ID         int64     `json:"id"`
-	PageID     int64     `json:"page_id"`
+	PageID     *int64    `json:"page_id"` // Nil until the new page it was uploaded for is saved
 	Filename   string    `json:"filename"`
 	Filepath   string    `json:"-"` // Internal path, not exposed
 	MimeType   string    `json:"mime_type"`
 	SizeBytes  int64     `json:"size_bytes"`
+	Hash       string    `json:"content_hash"` // SHA-256 of the file, hex encoded
 	UploaderID int64     `json:"uploader_id"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
 // SearchResult represents a full-text search hit.
 type SearchResult struct {
-	PageID    int64   `json:"page_id"`
-	Slug      string  `json:"slug"`
-	Title     string  `json:"title"`
-	Snippet   string  `json:"snippet"`
-	Rank      float64 `json:"rank"`
-	UpdatedAt time.Time `json:"updated_at"`
+	PageID    int64        `json:"page_id"`
+	Slug      string       `json:"slug"`
+	Title     string       `json:"title"`
+	Snippet   string       `json:"snippet"`
+	Rank      float64      `json:"rank"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Archived  bool         `json:"archived"`
+	Display   TimeDisplays `json:"display,omitempty"`
 }
 
 // PageFilter contains options for filtering page queries.
 type PageFilter struct {
 	AuthorID    *int64
+	Author      *string // Author username, case-insensitive
 	IsPublished *bool
 	Tag         *string
 	Search      *string
-	Limit       int
-	Offset      int
-	OrderBy     string
-	OrderDir    string
+
+	// With HideRestricted, pages restricted to groups are left out unless
+	// one of their groups is in MemberOf.
+	HideRestricted bool
+	MemberOf       []int64
+
+	// Archived pages are left out unless IncludeArchived is set.
+	// ArchivedOnly lists nothing but them.
+	IncludeArchived bool
+	ArchivedOnly    bool
+
+	Limit    int
+	Offset   int
+	OrderBy  string
+	OrderDir string
 }
 
 // NewPageFilter creates a filter with sensible defaults.
@@ -123,3 +307,23 @@ func NewPageFilter() PageFilter {
 		OrderDir: "DESC",
 	}
 }
+
+// RelatedPage is a page suggested alongside another, with why it was
+// picked.
+type RelatedPage struct {
+	ID         int64    `json:"id"`
+	Slug       string   `json:"slug"`
+	Title      string   `json:"title"`
+	Score      float64  `json:"score"`
+	SharedTags []string `json:"shared_tags,omitempty"`
+	Linked     bool     `json:"linked,omitempty"` // One page links to the other
+}
+
+// PageFile describes a page as a markdown file, for clients that browse
+// pages as files.
+type PageFile struct {
+	ID        int64
+	Slug      string
+	Size      int64 // Bytes of content
+	UpdatedAt time.Time
+}
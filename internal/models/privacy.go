@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// PersonalData gathers everything the wiki stores about a user, for
+// answering data-protection access requests.
+type PersonalData struct {
+	GeneratedAt time.Time             `json:"generated_at"`
+	User        *User                 `json:"user"`
+	Pages       []PersonalPage        `json:"pages"`
+	Revisions   []PersonalRevision    `json:"revisions"`
+	AuditLog    []PersonalAuditEntry  `json:"audit_log"`
+	ShareLinks  []PersonalShareLink   `json:"share_links"`
+	ShareAccess []PersonalShareAccess `json:"share_access"`
+	APITokens   []APIToken            `json:"api_tokens"`
+	// IPAddresses lists the distinct addresses recorded for the user.
+	IPAddresses []string `json:"ip_addresses"`
+}
+
+// PersonalPage is a page authored by the user.
+type PersonalPage struct {
+	ID          int64     `json:"id"`
+	Slug        string    `json:"slug"`
+	Title       string    `json:"title"`
+	IsPublished bool      `json:"is_published"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PersonalRevision is an edit made by the user, including its comment.
+type PersonalRevision struct {
+	ID        int64     `json:"id"`
+	PageID    int64     `json:"page_id"`
+	PageSlug  string    `json:"page_slug"`
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PersonalAuditEntry is an audit log entry recorded for the user.
+type PersonalAuditEntry struct {
+	ID         int64     `json:"id"`
+	Action     string    `json:"action"`
+	EntityType string    `json:"entity_type"`
+	EntityID   *int64    `json:"entity_id,omitempty"`
+	Details    string    `json:"details,omitempty"`
+	IPAddress  string    `json:"ip_address,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PersonalShareLink is a share link created by the user.
+type PersonalShareLink struct {
+	ID         int64      `json:"id"`
+	TargetType string     `json:"target_type"`
+	TargetRef  string     `json:"target_ref,omitempty"`
+	PageSlug   string     `json:"page_slug,omitempty"`
+	ViewCount  int        `json:"view_count"`
+	IsRevoked  bool       `json:"is_revoked"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// PersonalShareAccess is a share link visit from one of the user's addresses.
+type PersonalShareAccess struct {
+	ShareLinkID int64     `json:"share_link_id"`
+	IPAddress   string    `json:"ip_address"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	AccessedAt  time.Time `json:"accessed_at"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// BundleVersion is the format version of promotion bundles. Version 2
+// added the bundle ID.
+const BundleVersion = 2
+
+// Bundle is a labeled set of pages exported from one wiki to be promoted to
+// another, e.g. from staging to production.
+type Bundle struct {
+	Version   int          `json:"version"`
+	ID        string       `json:"id"` // random; a wiki applies each bundle once
+	Label     string       `json:"label"`
+	Source    string       `json:"source"` // site URL of the exporting wiki
+	CreatedBy string       `json:"created_by"`
+	CreatedAt time.Time    `json:"created_at"`
+	Pages     []BundlePage `json:"pages"`
+}
+
+// BundlePage is a page in a promotion bundle.
+type BundlePage struct {
+	Slug        string   `json:"slug"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	Tags        []string `json:"tags,omitempty"`
+	IsPublished bool     `json:"is_published"`
+}
+
+// SignedBundle is the file format of a promotion bundle. Bundle holds the
+// exact JSON bytes the signature covers.
+type SignedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"` // hex HMAC-SHA256 of Bundle
+}
+
+// PromotedPage is a page write applied by a promotion.
+type PromotedPage struct {
+	Slug        string
+	Title       string
+	Content     string
+	ContentHTML string
+	Excerpt     string
+	WordCount   int
+	Tags        []string
+	IsPublished bool
+	// CreateOnly pages are parents created to hold bundle pages; an
+	// existing page with the slug is left alone.
+	CreateOnly bool
+
+	// Set by the write
+	ID      int64
+	Created bool
+}
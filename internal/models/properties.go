@@ -0,0 +1,101 @@
+package models
+
+import "time"
+
+// PageProperties are a page's attributes apart from its title and content:
+// what the properties panel shows and the properties API serves.
+type PageProperties struct {
+	PageID      int64          `json:"page_id"`
+	Slug        string         `json:"slug"`
+	IsPublished bool           `json:"is_published"`
+	PublishAt   *time.Time     `json:"publish_at"` // when an unpublished page goes live, if scheduled
+	Parent      *PropertyPage  `json:"parent"`     // nil for top-level pages
+	Owner       *PropertyUser  `json:"owner"`      // the author unless reassigned
+	Tags        []string       `json:"tags"`
+	ReviewAt    *time.Time     `json:"review_at"`    // date the page is due for review
+	Description string         `json:"description"`  // for search engines and link previews
+	SocialImage string         `json:"social_image"` // image URL for link previews
+	Access      PageAccessInfo `json:"access"`
+}
+
+// PropertyPage identifies a page in PageProperties.
+type PropertyPage struct {
+	ID    int64  `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
+// PropertyUser identifies a user in PageProperties.
+type PropertyUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// PageAccessInfo summarizes who can read a page. It is read-only here;
+// groups are changed on the page access screen.
+type PageAccessInfo struct {
+	Restricted bool     `json:"restricted"`
+	Groups     []string `json:"groups,omitempty"`
+	Public     bool     `json:"public"`      // readable without signing in, as or under a public page
+	ShareLinks int      `json:"share_links"` // active share links
+	Summary    string   `json:"summary"`
+}
+
+// ReviewDue reports whether the page's review date has arrived.
+func (p *PageProperties) ReviewDue(now time.Time) bool {
+	return p.ReviewAt != nil && !now.Before(*p.ReviewAt)
+}
+
+// PagePropertiesUpdate changes some of a page's properties; nil fields are
+// left alone. An empty Parent moves the page to the top level, an empty
+// Owner hands the page back to its author, and an empty ReviewAt clears
+// the review date. An empty Description falls back to the page's excerpt.
+type PagePropertiesUpdate struct {
+	IsPublished *bool     `json:"is_published,omitempty"`
+	Parent      *string   `json:"parent,omitempty"` // slug of the new parent page
+	Owner       *string   `json:"owner,omitempty"`  // username
+	Tags        *[]string `json:"tags,omitempty"`
+	ReviewAt    *string   `json:"review_at,omitempty"` // YYYY-MM-DD
+	Description *string   `json:"description,omitempty"`
+	SocialImage *string   `json:"social_image,omitempty"`
+}
+
+// IsEmpty reports whether the update changes nothing.
+func (u *PagePropertiesUpdate) IsEmpty() bool {
+	return u.IsPublished == nil && u.Parent == nil && u.Owner == nil && u.Tags == nil && u.ReviewAt == nil &&
+		u.Description == nil && u.SocialImage == nil
+}
+
+// Fields names the properties the update changes, for audit logs.
+func (u *PagePropertiesUpdate) Fields() []string {
+	var fields []string
+	if u.IsPublished != nil {
+		fields = append(fields, "is_published")
+	}
+	if u.Parent != nil {
+		fields = append(fields, "parent")
+	}
+	if u.Owner != nil {
+		fields = append(fields, "owner")
+	}
+	if u.Tags != nil {
+		fields = append(fields, "tags")
+	}
+	if u.ReviewAt != nil {
+		fields = append(fields, "review_at")
+	}
+	if u.Description != nil {
+		fields = append(fields, "description")
+	}
+	if u.SocialImage != nil {
+		fields = append(fields, "social_image")
+	}
+	return fields
+}
+
+// PageMeta describes a page to search engines and link previews. Empty
+// fields fall back to the page's excerpt and no image.
+type PageMeta struct {
+	Description string `json:"description"`
+	SocialImage string `json:"social_image"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// ReplaceQuery describes a find and replace across page content.
+type ReplaceQuery struct {
+	Find    string
+	Replace string
+	// Regex treats Find as a regular expression and lets Replace refer to
+	// its groups as $1 or ${name}.
+	Regex         bool
+	CaseSensitive bool
+	// Namespace limits the search to a page and its subpages.
+	Namespace string
+}
+
+// ReplaceMatch is a page whose content matches a ReplaceQuery.
+type ReplaceMatch struct {
+	PageID   int64
+	Slug     string
+	Title    string
+	Count    int
+	Archived bool
+	// Snippets show the first few occurrences in context.
+	Snippets []ReplaceSnippet
+}
+
+// ReplaceSnippet is one occurrence with the text around it.
+type ReplaceSnippet struct {
+	Before      string
+	Match       string
+	Replacement string
+	After       string
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Permission is a single capability a role can grant.
+type Permission string
+
+const (
+	PermCreatePage      Permission = "create_page"
+	PermEditPage        Permission = "edit_page"
+	PermDeletePage      Permission = "delete_page"
+	PermViewUnpublished Permission = "view_unpublished"
+	PermUploadFiles     Permission = "upload_files"
+	PermManageShares    Permission = "manage_shares"
+	PermManageUsers     Permission = "manage_users"
+	PermAdminister      Permission = "administer"
+)
+
+// PermissionInfo describes a permission for the role editor.
+type PermissionInfo struct {
+	Permission  Permission
+	Label       string
+	Description string
+}
+
+// Permissions lists every permission in the order the role editor shows them.
+var Permissions = []PermissionInfo{
+	{PermCreatePage, "Create pages", "Create new pages and import markdown"},
+	{PermEditPage, "Edit pages", "Edit, archive and revert pages, and browse history"},
+	{PermDeletePage, "Delete pages", "Delete pages permanently"},
+	{PermViewUnpublished, "View unpublished", "Read unpublished pages and their backlinks"},
+	{PermUploadFiles, "Upload files", "Upload images and attachments"},
+	{PermManageShares, "Manage shares", "Create and revoke share links for pages"},
+	{PermManageUsers, "Manage users", "Create, update, import and delete user accounts"},
+	{PermAdminister, "Administer", "Site settings, backups, security and every other admin page"},
+}
+
+// IsValid checks if the permission is a known value.
+func (p Permission) IsValid() bool {
+	for _, info := range Permissions {
+		if info.Permission == p {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleDefinition is a named set of permissions users can be assigned.
+type RoleDefinition struct {
+	Name        Role         `json:"name"`
+	Description string       `json:"description"`
+	Permissions []Permission `json:"permissions"`
+	BuiltIn     bool         `json:"built_in"`
+	UserCount   int          `json:"user_count"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// Has reports whether the definition grants p.
+func (d *RoleDefinition) Has(p Permission) bool {
+	for _, granted := range d.Permissions {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePermissions parses the comma-separated permission list stored in the
+// roles table, dropping unknown names.
+func ParsePermissions(s string) []Permission {
+	var perms []Permission
+	for _, name := range strings.Split(s, ",") {
+		p := Permission(strings.TrimSpace(name))
+		if p.IsValid() {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// FormatPermissions joins permissions for storage in the roles table.
+func FormatPermissions(perms []Permission) string {
+	names := make([]string, len(perms))
+	for i, p := range perms {
+		names[i] = string(p)
+	}
+	return strings.Join(names, ",")
+}
+
+// DefaultRoles are the built-in roles with their initial permissions. The
+// roles table is seeded with these, and they apply until it's loaded.
+var DefaultRoles = []RoleDefinition{
+	{
+		Name:        RoleAdmin,
+		Description: "Full access to the wiki and its administration",
+		Permissions: []Permission{PermCreatePage, PermEditPage, PermDeletePage, PermViewUnpublished, PermUploadFiles, PermManageShares, PermManageUsers, PermAdminister},
+		BuiltIn:     true,
+	},
+	{
+		Name:        RoleEditor,
+		Description: "Create, edit and share pages",
+		Permissions: []Permission{PermCreatePage, PermEditPage, PermDeletePage, PermViewUnpublished, PermUploadFiles, PermManageShares},
+		BuiltIn:     true,
+	},
+	{
+		Name:        RoleViewer,
+		Description: "Read published pages",
+		BuiltIn:     true,
+	},
+}
+
+// roleRegistry holds the permissions of every defined role. Role methods
+// consult it, so permission checks don't need a database round trip.
+var roleRegistry = struct {
+	sync.RWMutex
+	roles map[Role]map[Permission]bool
+}{roles: roleSet(DefaultRoles)}
+
+// SetRoles replaces the role registry with defs, as loaded from the roles
+// table at startup and after every change.
+func SetRoles(defs []RoleDefinition) {
+	roles := roleSet(defs)
+	roleRegistry.Lock()
+	roleRegistry.roles = roles
+	roleRegistry.Unlock()
+}
+
+// RoleNames returns the names of every defined role, sorted.
+func RoleNames() []Role {
+	roleRegistry.RLock()
+	defer roleRegistry.RUnlock()
+
+	names := make([]Role, 0, len(roleRegistry.roles))
+	for name := range roleRegistry.roles {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+func roleSet(defs []RoleDefinition) map[Role]map[Permission]bool {
+	roles := make(map[Role]map[Permission]bool, len(defs))
+	for _, def := range defs {
+		perms := make(map[Permission]bool, len(def.Permissions))
+		for _, p := range def.Permissions {
+			perms[p] = true
+		}
+		roles[def.Name] = perms
+	}
+	return roles
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// IP rule actions
+const (
+	IPRuleBan    = "ban"
+	IPRuleExempt = "exempt"
+)
+
+// IPRule bans or exempts from rate limiting a single IP or CIDR range.
+type IPRule struct {
+	ID        int64
+	CIDR      string
+	Action    string
+	Reason    string
+	CreatedBy *int64
+	ExpiresAt *time.Time // nil = permanent
+	CreatedAt time.Time
+
+	// Joined fields for display
+	CreatorUsername string
+}
+
+// IsExpired checks if a temporary rule has lapsed.
+func (r *IPRule) IsExpired() bool {
+	return r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt)
+}
+
+// Network parses the rule into an IP network. Bare addresses become /32 or /128.
+func (r *IPRule) Network() (*net.IPNet, error) {
+	return ParseCIDR(r.CIDR)
+}
+
+// ParseCIDR parses an IP address or CIDR range into a network.
+func ParseCIDR(value string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "IP address", Text: value}
+		}
+		if ip.To4() != nil {
+			value += "/32"
+		} else {
+			value += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(value)
+	return network, err
+}
+
+// RateLimitCounter counts a client's requests or failed sign-ins in a
+// window that ends at ResetsAt.
+type RateLimitCounter struct {
+	Key      string
+	Count    int
+	ResetsAt time.Time
+}
@@ -1,26 +1,83 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
-// ShareLink represents a shareable link to a wiki page
+// Share link targets
+const (
+	ShareTargetPage   = "page"   // One page, and its subpages with IncludeChildren
+	ShareTargetTag    = "tag"    // Every published page with a tag
+	ShareTargetSearch = "search" // The published pages a search finds
+)
+
+// MaxShareCollectionPages caps how many pages a tag or search share link
+// lists and opens.
+const MaxShareCollectionPages = 200
+
+// ShareLink represents a shareable link to a wiki page, or to a collection
+// of pages: those with a tag or those a search finds.
 type ShareLink struct {
-	ID              int64
-	TokenHash       string // SHA-256 hash of the token (never store raw token)
-	PageID          int64
-	CreatedBy       int64
-	IncludeChildren bool
-	MaxViews        *int       // nil = unlimited
-	MaxIPs          *int       // nil = unlimited
-	ExpiresAt       *time.Time // nil = never expires
-	IsRevoked       bool
-	ViewCount       int
-	CreatedAt       time.Time
+	ID              int64      `json:"id"`
+	TokenHash       string     `json:"-"` // SHA-256 hash of the token (never store raw token)
+	TargetType      string     `json:"target_type"`
+	TargetRef       string     `json:"target_ref,omitempty"` // The tag or search query of a collection
+	PageID          int64      `json:"page_id,omitempty"`    // 0 for collections
+	CreatedBy       int64      `json:"created_by"`
+	IncludeChildren bool       `json:"include_children"`
+	MaxViews        *int       `json:"max_views"`  // nil = unlimited
+	MaxIPs          *int       `json:"max_ips"`    // nil = unlimited
+	ExpiresAt       *time.Time `json:"expires_at"` // nil = never expires
+	GroupID         *int64     `json:"group_id"`   // nil = anyone with the link
+	IsRevoked       bool       `json:"is_revoked"`
+	ViewCount       int        `json:"view_count"`
+	CreatedAt       time.Time  `json:"created_at"`
 
 	// Joined fields for display
-	PageTitle       string
-	PageSlug        string
-	CreatorUsername string
-	UniqueIPs       int // Count of unique IPs that accessed this link
+	PageTitle       string `json:"page_title"`
+	PageSlug        string `json:"page_slug"`
+	CreatorUsername string `json:"creator_username"`
+	GroupName       string `json:"group_name,omitempty"`
+	UniqueIPs       int    `json:"unique_ips"` // Count of unique IPs that accessed this link
+}
+
+// ShareLinkUpdate changes the settings of an existing share link, keeping
+// its URL. Nil fields are left as they are. A zero MaxViews or MaxIPs
+// removes the limit, and a zero ExpiresIn makes the link never expire.
+type ShareLinkUpdate struct {
+	IncludeChildren *bool
+	MaxViews        *int
+	MaxIPs          *int
+	ExpiresIn       *time.Duration // From now
+	Revoked         *bool
+}
+
+// IsCollection reports whether the link shares the pages with a tag or
+// matching a search rather than one page.
+func (s *ShareLink) IsCollection() bool {
+	return s.TargetType == ShareTargetTag || s.TargetType == ShareTargetSearch
+}
+
+// TargetTitle describes what the link shares, for lists: the page's title,
+// or the tag or search.
+func (s *ShareLink) TargetTitle() string {
+	switch s.TargetType {
+	case ShareTargetTag:
+		return "Tag: " + s.TargetRef
+	case ShareTargetSearch:
+		return "Search: " + s.TargetRef
+	}
+	return s.PageTitle
+}
+
+// ShareCollectionFilter describes the pages a tag or search share link
+// lists, to follow "pages": `tagged "howto"` or `matching "vpn setup"`.
+func ShareCollectionFilter(targetType, ref string) string {
+	if targetType == ShareTargetTag {
+		return fmt.Sprintf("tagged %q", ref)
+	}
+	return fmt.Sprintf("matching %q", ref)
 }
 
 // IsValid checks if the share link is currently valid for access
@@ -54,11 +111,11 @@ func (s *ShareLink) IsIPLimitReached(currentUniqueIPs int) bool {
 
 // ShareLinkAccess records individual access to a share link
 type ShareLinkAccess struct {
-	ID          int64
-	ShareLinkID int64
-	IPAddress   string
-	UserAgent   string
-	AccessedAt  time.Time
+	ID          int64     `json:"id"`
+	ShareLinkID int64     `json:"share_link_id"`
+	IPAddress   string    `json:"ip_address"`
+	UserAgent   string    `json:"user_agent"`
+	AccessedAt  time.Time `json:"accessed_at"`
 }
 
 // ShareLinkStats provides aggregated statistics for a share link
@@ -67,3 +124,15 @@ type ShareLinkStats struct {
 	UniqueIPs  int
 	LastAccess *time.Time
 }
+
+// ShareLinkActivity summarizes recent traffic on a share link for abuse
+// monitoring. Tag and search links have no PageSlug, and a PageTitle
+// naming the tag or search.
+type ShareLinkActivity struct {
+	LinkID    int64
+	PageTitle string
+	PageSlug  string
+	Accesses  int
+	UniqueIPs int
+	IsRevoked bool
+}
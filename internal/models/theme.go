@@ -0,0 +1,30 @@
+package models
+
+// Theme preferences. An empty preference follows the browser's color
+// scheme.
+const (
+	ThemeLight = "light"
+	ThemeDark  = "dark"
+)
+
+// ValidTheme reports whether theme is a preference users can pick.
+func ValidTheme(theme string) bool {
+	return theme == "" || theme == ThemeLight || theme == ThemeDark
+}
+
+// SiteTheme is how an admin customized the wiki's look. The zero value is
+// the built-in look.
+type SiteTheme struct {
+	Accent  string // #rrggbb replacing the default blue, or ""
+	CSS     string // added after the built-in styles
+	HasLogo bool   // an uploaded logo replaces the site name's initial
+	// Version changes with every customization, so the stylesheet and
+	// logo URLs can be cached for long.
+	Version string
+}
+
+// HasStylesheet reports whether the theme adds styles to the built-in
+// ones.
+func (t SiteTheme) HasStylesheet() bool {
+	return t.Accent != "" || t.CSS != ""
+}
@@ -6,32 +6,49 @@ import (
 	"time"
 )
 
-// Role represents user permission levels.
+// Role names a set of permissions defined in the roles table.
 type Role string
 
+// Built-in roles. They can't be deleted, and admin's permissions are fixed.
 const (
 	RoleAdmin  Role = "admin"
 	RoleEditor Role = "editor"
 	RoleViewer Role = "viewer"
 )
 
-// IsValid checks if the role is a valid value.
+// IsValid checks if the role is defined.
 func (r Role) IsValid() bool {
-	switch r {
-	case RoleAdmin, RoleEditor, RoleViewer:
-		return true
+	roleRegistry.RLock()
+	defer roleRegistry.RUnlock()
+	_, ok := roleRegistry.roles[r]
+	return ok
+}
+
+// Label returns the role name for display, e.g. "Page reviewer" for
+// "page-reviewer".
+func (r Role) Label() string {
+	label := strings.NewReplacer("-", " ", "_", " ").Replace(string(r))
+	if label == "" {
+		return label
 	}
-	return false
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// Can returns true if the role grants the permission.
+func (r Role) Can(p Permission) bool {
+	roleRegistry.RLock()
+	defer roleRegistry.RUnlock()
+	return roleRegistry.roles[r][p]
 }
 
 // CanEdit returns true if the role has edit permissions.
 func (r Role) CanEdit() bool {
-	return r == RoleAdmin || r == RoleEditor
+	return r.Can(PermEditPage)
 }
 
 // CanAdmin returns true if the role has admin permissions.
 func (r Role) CanAdmin() bool {
-	return r == RoleAdmin
+	return r.Can(PermAdminister)
 }
 
 // User represents a wiki user.
@@ -45,6 +62,69 @@ type User struct {
 	CreatedAt    time.Time    `json:"created_at"`
 	UpdatedAt    time.Time    `json:"updated_at"`
 	LastLoginAt  sql.NullTime `json:"last_login_at,omitempty"`
+	// EmailVerifiedAt is unset while a registration awaits verification
+	EmailVerifiedAt sql.NullTime `json:"email_verified_at,omitempty"`
+	// MustChangePassword sends the user to choose a new password before
+	// anything else; set by admins to rotate passwords
+	MustChangePassword bool `json:"must_change_password"`
+	// LockedAt is set while an admin has locked the account. Unlike
+	// deactivation, a lock records when and why.
+	LockedAt   sql.NullTime `json:"locked_at,omitempty"`
+	LockReason string       `json:"lock_reason,omitempty"`
+	// Timezone and Locale format the times the user reads; empty means
+	// the site default
+	Timezone string `json:"timezone,omitempty"`
+	Locale   string `json:"locale,omitempty"`
+	// Theme is ThemeLight or ThemeDark, or empty to follow the browser
+	Theme string `json:"theme,omitempty"`
+	// Language is the tag of the catalog the interface is shown in; empty
+	// follows the browser
+	Language string `json:"language,omitempty"`
+	// DirectoryDN is the LDAP entry the account was provisioned for, and
+	// empty for local accounts
+	DirectoryDN string  `json:"directory_dn,omitempty"`
+	GroupIDs    []int64 `json:"-"` // Groups the user belongs to
+}
+
+// EmailVerified reports whether the user confirmed their email address.
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt.Valid
+}
+
+// IsLocked reports whether an admin has locked the account.
+func (u *User) IsLocked() bool {
+	return u.LockedAt.Valid
+}
+
+// CanSignIn reports whether the account may be used: it is active and
+// not locked.
+func (u *User) CanSignIn() bool {
+	return u.IsActive && !u.IsLocked()
+}
+
+// IsDirectoryAccount reports whether the user signs in with their
+// directory password rather than a local one.
+func (u *User) IsDirectoryAccount() bool {
+	return u.DirectoryDN != ""
+}
+
+// InGroup reports whether the user belongs to the group.
+func (u *User) InGroup(groupID int64) bool {
+	for _, id := range u.GroupIDs {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// UserContributions summarizes a user's activity for their profile.
+type UserContributions struct {
+	Edits        int64     `json:"edits"`
+	PagesCreated int64     `json:"pages_created"`
+	PagesEdited  int64     `json:"pages_edited"`
+	FirstEdit    time.Time `json:"first_edit"`
+	LastEdit     time.Time `json:"last_edit"`
 }
 
 // UserCreate contains data for creating a new user.
@@ -53,6 +133,10 @@ type UserCreate struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 	Role     Role   `json:"role"`
+	// Unverified leaves the email address to be confirmed by the user
+	Unverified bool `json:"-"`
+	// DirectoryDN marks an account provisioned by directory sign-in
+	DirectoryDN string `json:"-"`
 }
 
 // UserUpdate contains data for updating a user.
@@ -61,17 +145,34 @@ type UserUpdate struct {
 	Password *string `json:"password,omitempty"`
 	Role     *Role   `json:"role,omitempty"`
 	IsActive *bool   `json:"is_active,omitempty"`
+	// MustChangePassword forces a password change at the next request
+	MustChangePassword *bool `json:"must_change_password,omitempty"`
+	// Locked locks or unlocks the account; unlocking clears the reason
+	Locked     *bool   `json:"locked,omitempty"`
+	LockReason *string `json:"lock_reason,omitempty"`
+	Timezone   *string `json:"timezone,omitempty"`
+	Locale     *string `json:"locale,omitempty"`
+	Theme      *string `json:"theme,omitempty"`
+	Language   *string `json:"language,omitempty"`
+	// DirectoryDN follows a directory entry that moved
+	DirectoryDN *string `json:"-"`
 }
 
 // Session represents a user session for database-backed sessions.
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    int64     `json:"user_id"`
-	Data      string    `json:"data"`
-	IPAddress string    `json:"ip_address"`
-	UserAgent string    `json:"user_agent"`
-	CreatedAt time.Time `json:"created_at"`
-	ExpiresAt time.Time `json:"expires_at"`
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Data       string    `json:"-"` // encoded session values
+	IPAddress  string    `json:"ip_address"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// IsExpired reports whether the session has run past its expiry.
+func (s *Session) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
 }
 
 // APIToken represents an API access token.
@@ -115,15 +216,54 @@ func (t *APIToken) ScopeList() []string {
 	return result
 }
 
-// LastUsedString returns a string representation of LastUsedAt.
-func (t *APIToken) LastUsedString() string {
-	if t.LastUsedAt.Valid {
-		return t.LastUsedAt.Time.Format("Jan 2, 2006")
-	}
-	return ""
-}
-
 // WasUsed returns true if the token has been used.
 func (t *APIToken) WasUsed() bool {
 	return t.LastUsedAt.Valid
 }
+
+// User import modes.
+const (
+	UserImportPasswords = "passwords"
+	UserImportInvites   = "invites"
+)
+
+// UserImportRow is one account read from a user import CSV.
+type UserImportRow struct {
+	Line     int
+	Username string
+	Email    string
+	Role     Role
+	Group    string
+	// Password is the account's password from the file; empty to generate
+	// one or send an invitation
+	Password string
+	Errors   []string
+	Warnings []string
+}
+
+// Valid reports whether the row can be imported.
+func (r *UserImportRow) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// UserImportResult is the outcome of importing one row.
+type UserImportResult struct {
+	User     *User // Set when the account was created
+	Username string
+	Email    string
+	Password string // Set when a password was generated
+	// OwnPassword is set when the account got the password from the file
+	OwnPassword bool
+	Invited     bool
+	Error       string
+}
+
+// UserInvite is a pending invitation to choose a password.
+type UserInvite struct {
+	UserID    int64
+	Username  string
+	Email     string
+	InvitedBy *int64
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Webhook events
+const (
+	EventPageCreated = "page.created"
+	EventPageUpdated = "page.updated"
+	EventPageDeleted = "page.deleted"
+	EventUserCreated = "user.created"
+	EventPing        = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []string{EventPageCreated, EventPageUpdated, EventPageDeleted, EventUserCreated}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an admin-registered URL that receives signed event payloads.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string
+	Events    []string
+	IsActive  bool
+	CreatedBy *int64
+	CreatedAt time.Time
+}
+
+// HasEvent reports whether the webhook subscribes to event. Pings go to every webhook.
+func (w *Webhook) HasEvent(event string) bool {
+	if event == EventPing {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// EventsString returns the subscribed events as stored in the database.
+func (w *Webhook) EventsString() string {
+	return strings.Join(w.Events, ",")
+}
+
+// WebhookDelivery is one queued or completed POST to a webhook.
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	Event         string
+	Payload       string
+	Status        string
+	Attempts      int
+	ResponseCode  *int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	DeliveredAt   *time.Time
+}
@@ -0,0 +1,215 @@
+// Package policy decides what a user may do with a page. The HTML handlers,
+// the API and the templates all ask here instead of checking roles
+// directly, so a new page state only has to be taught to this package.
+//
+// For each action and page state, the matrix names the permissions the
+// user's role needs. Anonymous visitors have none.
+//
+//	             published      unpublished                       archived
+//	CanView      -              view_unpublished                  as when not archived
+//	CanEdit      edit_page      edit_page, view_unpublished       never
+//	CanArchive   edit_page      edit_page, view_unpublished       as when not archived
+//	CanDelete    delete_page    delete_page, view_unpublished     as when not archived
+//	CanShare     manage_shares  manage_shares, view_unpublished   as when not archived
+//
+// Every action on a page requires CanView first, so a role that can edit but
+// not view unpublished pages can't touch them either. Pages restricted to
+// groups are also hidden from users outside those groups, whatever their
+// role, unless the role has the administer permission.
+package policy
+
+import "gowiki/internal/models"
+
+// can reports whether user's role grants perm. Anonymous users have no
+// permissions.
+func can(user *models.User, perm models.Permission) bool {
+	return user != nil && user.Role.Can(perm)
+}
+
+// CanViewUnpublished reports whether user may see unpublished pages. Lists,
+// search and feeds use it to decide whether to filter them out.
+func CanViewUnpublished(user *models.User) bool {
+	return can(user, models.PermViewUnpublished)
+}
+
+// CanViewRestricted reports whether user may see pages restricted to groups
+// they don't belong to.
+func CanViewRestricted(user *models.User) bool {
+	return can(user, models.PermAdminister)
+}
+
+// CanView reports whether user may read page.
+func CanView(user *models.User, page *models.Page) bool {
+	if page == nil {
+		return false
+	}
+	return (page.IsPublished || CanViewUnpublished(user)) && inPageGroups(user, page)
+}
+
+// inPageGroups reports whether user belongs to one of the groups page is
+// restricted to, or page isn't restricted.
+func inPageGroups(user *models.User, page *models.Page) bool {
+	if !page.IsRestricted() || CanViewRestricted(user) {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	for _, g := range page.Groups {
+		if user.InGroup(g.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupFilter returns the page list settings that hide restricted pages
+// user can't see: whether to hide them, and the groups whose pages still
+// show.
+func GroupFilter(user *models.User) (hideRestricted bool, memberOf []int64) {
+	if CanViewRestricted(user) {
+		return false, nil
+	}
+	if user == nil {
+		return true, nil
+	}
+	return true, user.GroupIDs
+}
+
+// CanViewAttachment reports whether user may read a stored file attached
+// to pages: they must be able to view at least one of them. Files attached
+// to no page, because they're still pending or their pages are gone, are
+// only readable by the users in uploaders who uploaded them and by
+// administrators.
+func CanViewAttachment(user *models.User, pages []*models.Page, uploaders []int64) bool {
+	for _, page := range pages {
+		if CanView(user, page) {
+			return true
+		}
+	}
+	if user == nil {
+		return false
+	}
+	for _, id := range uploaders {
+		if id == user.ID {
+			return true
+		}
+	}
+	return can(user, models.PermAdminister)
+}
+
+// CanCreate reports whether user may create pages.
+func CanCreate(user *models.User) bool {
+	return can(user, models.PermCreatePage)
+}
+
+// CanEdit reports whether user may change page's title, content or tags.
+// Archived pages are read-only until they're unarchived.
+func CanEdit(user *models.User, page *models.Page) bool {
+	return CanView(user, page) && can(user, models.PermEditPage) && !page.IsArchived()
+}
+
+// CanArchive reports whether user may archive or unarchive page.
+func CanArchive(user *models.User, page *models.Page) bool {
+	return CanView(user, page) && can(user, models.PermEditPage)
+}
+
+// CanDelete reports whether user may delete page and its subpages.
+func CanDelete(user *models.User, page *models.Page) bool {
+	return CanView(user, page) && can(user, models.PermDeletePage)
+}
+
+// CanShare reports whether user may create share links for page.
+func CanShare(user *models.User, page *models.Page) bool {
+	return CanView(user, page) && can(user, models.PermManageShares)
+}
+
+// CanShareWith reports whether user may share page with the group, or with
+// anyone who has the link when group is nil. Users share with groups they
+// belong to, and restricted pages only with the groups they're restricted
+// to.
+func CanShareWith(user *models.User, page *models.Page, group *models.Group) bool {
+	if !CanShare(user, page) {
+		return false
+	}
+	if group == nil {
+		return !page.IsRestricted()
+	}
+	if page.IsRestricted() && !page.HasGroup(group.ID) {
+		return false
+	}
+	return user.InGroup(group.ID) || CanViewRestricted(user)
+}
+
+// CanShareCollection reports whether user may share the pages with a tag
+// or found by a search, with the group or with anyone when group is nil.
+// Those links only reach published pages, so any sharer may make them.
+func CanShareCollection(user *models.User, group *models.Group) bool {
+	if !can(user, models.PermManageShares) {
+		return false
+	}
+	return group == nil || user.InGroup(group.ID) || CanViewRestricted(user)
+}
+
+// CanRestrict reports whether user may choose the groups page is
+// restricted to.
+func CanRestrict(user *models.User, page *models.Page) bool {
+	return CanView(user, page) && can(user, models.PermAdminister)
+}
+
+// CanViewShared reports whether link reaches page, the shared page or one
+// of its subpages. Restricted pages are only reachable through links shared
+// with one of their groups, even if they were restricted after the link was
+// made.
+func CanViewShared(link *models.ShareLink, page *models.Page) bool {
+	return !page.IsRestricted() || (link.GroupID != nil && page.HasGroup(*link.GroupID))
+}
+
+// CanOpenShare reports whether user may open link. Links shared with a
+// group only open for its signed-in members.
+func CanOpenShare(user *models.User, link *models.ShareLink) bool {
+	if link.GroupID == nil {
+		return true
+	}
+	return user != nil && user.InGroup(*link.GroupID)
+}
+
+// CanManageShare reports whether user may view the stats of, revoke or
+// delete link. Users manage their own links; administrators manage all.
+func CanManageShare(user *models.User, link *models.ShareLink) bool {
+	if user == nil || link == nil {
+		return false
+	}
+	return (link.CreatedBy == user.ID && can(user, models.PermManageShares)) || CanManageAllShares(user)
+}
+
+// CanManageAllShares reports whether user sees and manages every share link
+// rather than only their own.
+func CanManageAllShares(user *models.User) bool {
+	return can(user, models.PermAdminister)
+}
+
+// CanViewAllAnalytics reports whether user sees view analytics for every
+// page rather than only the pages they created.
+func CanViewAllAnalytics(user *models.User) bool {
+	return can(user, models.PermAdminister)
+}
+
+// CanAssignRole reports whether user may give an account role. Only
+// administrators may hand out roles that grant administer, so managing
+// users can't be turned into administering the wiki.
+func CanAssignRole(user *models.User, role models.Role) bool {
+	if !can(user, models.PermManageUsers) {
+		return false
+	}
+	return !role.Can(models.PermAdminister) || can(user, models.PermAdminister)
+}
+
+// CanManageUser reports whether user may edit, sign out or delete target.
+// Accounts holding administer can only be managed by administrators.
+func CanManageUser(user, target *models.User) bool {
+	if !can(user, models.PermManageUsers) || target == nil {
+		return false
+	}
+	return !target.Role.Can(models.PermAdminister) || can(user, models.PermAdminister)
+}
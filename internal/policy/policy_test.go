@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+// roleWriter can edit pages but not see unpublished ones, and
+// roleUserManager manages accounts without administering the wiki.
+const (
+	roleWriter      models.Role = "writer"
+	roleUserManager models.Role = "user-manager"
+)
+
+func TestMain(m *testing.M) {
+	models.SetRoles(append(append([]models.RoleDefinition{}, models.DefaultRoles...),
+		models.RoleDefinition{Name: roleWriter, Permissions: []models.Permission{models.PermEditPage}},
+		models.RoleDefinition{Name: roleUserManager, Permissions: []models.Permission{models.PermManageUsers}},
+	))
+	os.Exit(m.Run())
+}
+
+const ownerID = 3
+
+var (
+	admin        = &models.User{ID: 1, Role: models.RoleAdmin}
+	viewer       = &models.User{ID: 2, Role: models.RoleViewer}
+	owner        = &models.User{ID: ownerID, Role: models.RoleViewer}
+	editor       = &models.User{ID: 4, Role: models.RoleEditor}
+	writer       = &models.User{ID: 5, Role: roleWriter}
+	member       = &models.User{ID: 6, Role: models.RoleViewer, GroupIDs: []int64{1}}
+	editorMember = &models.User{ID: 7, Role: models.RoleEditor, GroupIDs: []int64{1}}
+	userManager  = &models.User{ID: 8, Role: roleUserManager}
+)
+
+var archivedAt = sql.NullTime{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+func published() *models.Page {
+	return &models.Page{ID: 1, AuthorID: ownerID, IsPublished: true}
+}
+
+func unpublished() *models.Page {
+	return &models.Page{ID: 2, AuthorID: ownerID}
+}
+
+func archived() *models.Page {
+	return &models.Page{ID: 3, AuthorID: ownerID, IsPublished: true, ArchivedAt: archivedAt}
+}
+
+func restricted() *models.Page {
+	return &models.Page{ID: 4, AuthorID: ownerID, IsPublished: true, Groups: []models.Group{{ID: 1}}}
+}
+
+func TestPageActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    *models.User
+		page    *models.Page
+		view    bool
+		edit    bool
+		archive bool
+		delete  bool
+		share   bool
+	}{
+		{"anonymous published", nil, published(), true, false, false, false, false},
+		{"viewer published", viewer, published(), true, false, false, false, false},
+		{"owner published", owner, published(), true, false, false, false, false},
+		{"writer published", writer, published(), true, true, true, false, false},
+		{"editor published", editor, published(), true, true, true, true, true},
+		{"admin published", admin, published(), true, true, true, true, true},
+
+		{"anonymous unpublished", nil, unpublished(), false, false, false, false, false},
+		{"viewer unpublished", viewer, unpublished(), false, false, false, false, false},
+		{"owner unpublished", owner, unpublished(), false, false, false, false, false},
+		{"writer unpublished", writer, unpublished(), false, false, false, false, false},
+		{"editor unpublished", editor, unpublished(), true, true, true, true, true},
+		{"admin unpublished", admin, unpublished(), true, true, true, true, true},
+
+		{"anonymous archived", nil, archived(), true, false, false, false, false},
+		{"viewer archived", viewer, archived(), true, false, false, false, false},
+		{"owner archived", owner, archived(), true, false, false, false, false},
+		{"writer archived", writer, archived(), true, false, true, false, false},
+		{"editor archived", editor, archived(), true, false, true, true, true},
+		{"admin archived", admin, archived(), true, false, true, true, true},
+
+		{"anonymous restricted", nil, restricted(), false, false, false, false, false},
+		{"viewer restricted", viewer, restricted(), false, false, false, false, false},
+		{"owner restricted", owner, restricted(), false, false, false, false, false},
+		{"member restricted", member, restricted(), true, false, false, false, false},
+		{"editor restricted", editor, restricted(), false, false, false, false, false},
+		{"editor member restricted", editorMember, restricted(), true, true, true, true, true},
+		{"admin restricted", admin, restricted(), true, true, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanView(tt.user, tt.page); got != tt.view {
+				t.Errorf("CanView = %v, want %v", got, tt.view)
+			}
+			if got := CanEdit(tt.user, tt.page); got != tt.edit {
+				t.Errorf("CanEdit = %v, want %v", got, tt.edit)
+			}
+			if got := CanArchive(tt.user, tt.page); got != tt.archive {
+				t.Errorf("CanArchive = %v, want %v", got, tt.archive)
+			}
+			if got := CanDelete(tt.user, tt.page); got != tt.delete {
+				t.Errorf("CanDelete = %v, want %v", got, tt.delete)
+			}
+			if got := CanShare(tt.user, tt.page); got != tt.share {
+				t.Errorf("CanShare = %v, want %v", got, tt.share)
+			}
+		})
+	}
+}
+
+func TestCanViewNilPage(t *testing.T) {
+	if CanView(admin, nil) {
+		t.Error("CanView(admin, nil) = true, want false")
+	}
+}
+
+func TestCanShareWith(t *testing.T) {
+	group1 := &models.Group{ID: 1}
+	group2 := &models.Group{ID: 2}
+
+	tests := []struct {
+		name  string
+		user  *models.User
+		page  *models.Page
+		group *models.Group
+		want  bool
+	}{
+		{"anyone with published page", editor, published(), nil, true},
+		{"anyone with restricted page", editorMember, restricted(), nil, false},
+		{"own group", editorMember, published(), group1, true},
+		{"other group", editorMember, published(), group2, false},
+		{"restricted page with its group", editorMember, restricted(), group1, true},
+		{"restricted page with another group", admin, restricted(), group2, false},
+		{"admin with any group", admin, published(), group2, true},
+		{"viewer", viewer, published(), nil, false},
+		{"anonymous", nil, published(), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanShareWith(tt.user, tt.page, tt.group); got != tt.want {
+				t.Errorf("CanShareWith = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserManagement(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *models.User
+		target *models.User
+		role   models.Role
+		manage bool
+		assign bool
+	}{
+		{"admin manages admin", admin, &models.User{ID: 9, Role: models.RoleAdmin}, models.RoleAdmin, true, true},
+		{"admin manages editor", admin, editor, models.RoleEditor, true, true},
+		{"user manager manages editor", userManager, editor, models.RoleEditor, true, true},
+		{"user manager manages admin", userManager, admin, models.RoleAdmin, false, false},
+		{"user manager promotes to admin", userManager, viewer, models.RoleAdmin, true, false},
+		{"editor", editor, viewer, models.RoleViewer, false, false},
+		{"anonymous", nil, viewer, models.RoleViewer, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanManageUser(tt.user, tt.target); got != tt.manage {
+				t.Errorf("CanManageUser = %v, want %v", got, tt.manage)
+			}
+			if got := CanAssignRole(tt.user, tt.role); got != tt.assign {
+				t.Errorf("CanAssignRole(%s) = %v, want %v", tt.role, got, tt.assign)
+			}
+		})
+	}
+}
+
+func TestCanViewAttachment(t *testing.T) {
+	tests := []struct {
+		name      string
+		user      *models.User
+		pages     []*models.Page
+		uploaders []int64
+		want      bool
+	}{
+		{"published page", nil, []*models.Page{published()}, nil, true},
+		{"one viewable page", viewer, []*models.Page{restricted(), published()}, nil, true},
+		{"restricted page", viewer, []*models.Page{restricted()}, nil, false},
+		{"restricted page member", member, []*models.Page{restricted()}, nil, true},
+		{"pending uploader", viewer, nil, []int64{viewer.ID}, true},
+		{"pending other user", editor, nil, []int64{viewer.ID}, false},
+		{"pending anonymous", nil, nil, []int64{viewer.ID}, false},
+		{"no pages", editor, nil, nil, false},
+		{"no pages admin", admin, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanViewAttachment(tt.user, tt.pages, tt.uploaders); got != tt.want {
+				t.Errorf("CanViewAttachment = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
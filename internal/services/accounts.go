@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// ErrAccountLinkInvalid is returned for tampered, expired or used password
+// reset and email verification links.
+var ErrAccountLinkInvalid = errors.New("link is invalid or has expired")
+
+const (
+	// passwordResetTTL is how long a password reset link stays valid.
+	passwordResetTTL = time.Hour
+	// emailVerifyTTL is how long an email verification link stays valid.
+	emailVerifyTTL = 48 * time.Hour
+)
+
+// Purposes bound into account link signatures, so a link for one can't be
+// used for the other.
+const (
+	purposePasswordReset = "password_reset"
+	purposeVerifyEmail   = "verify_email"
+)
+
+// AccountService sends password reset and email verification links and
+// acts on them. Links are signed with the application secret instead of
+// stored. A reset link is also bound to the current password hash, so it
+// stops working once used, and a verification link to the email address.
+type AccountService struct {
+	db      *database.DB
+	auth    *AuthService
+	mail    *MailService
+	key     []byte
+	siteURL string
+}
+
+// NewAccountService creates a new AccountService.
+func NewAccountService(db *database.DB, cfg *config.Config, auth *AuthService, mail *MailService) *AccountService {
+	return &AccountService{
+		db:      db,
+		auth:    auth,
+		mail:    mail,
+		key:     []byte("accounts:" + cfg.Security.SecretKey),
+		siteURL: strings.TrimRight(cfg.Site.URL, "/"),
+	}
+}
+
+// Enabled reports whether account emails can be sent.
+func (s *AccountService) Enabled() bool {
+	return s.mail.Enabled()
+}
+
+// RequestPasswordReset emails a reset link to the active user with the
+// given email address. Unknown addresses are ignored without an error, so
+// the response doesn't reveal which addresses have accounts.
+func (s *AccountService) RequestPasswordReset(ctx context.Context, email, ipAddress string) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+
+	user, err := s.db.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return err
+	}
+	// Directory accounts change their password in the directory
+	if user == nil || !user.CanSignIn() || user.IsDirectoryAccount() {
+		return nil
+	}
+
+	token := s.sign(purposePasswordReset, user.ID, user.PasswordHash, time.Now().Add(passwordResetTTL))
+	err = s.mail.SendTemplate(ctx, models.EmailPasswordReset, "", user.Email, map[string]string{
+		"Username":  user.Username,
+		"Link":      s.siteURL + "/reset/" + token,
+		"ExpiresIn": "1 hour",
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit(user.ID, "password_reset_request", nil, ipAddress)
+	return nil
+}
+
+// LookupPasswordReset returns the user a reset link is for. Links for
+// directory accounts are refused, even ones sent before the account was
+// linked to the directory.
+func (s *AccountService) LookupPasswordReset(ctx context.Context, token string) (*models.User, error) {
+	user, err := s.verify(ctx, purposePasswordReset, token, func(u *models.User) string { return u.PasswordHash })
+	if err != nil {
+		return nil, err
+	}
+	if user.IsDirectoryAccount() {
+		return nil, ErrAccountLinkInvalid
+	}
+	return user, nil
+}
+
+// ResetPassword sets a new password from a reset link. Following the link
+// also proves the user owns the email address, so it is marked verified.
+func (s *AccountService) ResetPassword(ctx context.Context, token, password, ipAddress string) (*models.User, error) {
+	user, err := s.LookupPasswordReset(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user chose this password, so it also satisfies a forced rotation
+	mustChange := false
+	if err := s.auth.UpdateUser(ctx, user.ID, &models.UserUpdate{Password: &password, MustChangePassword: &mustChange}); err != nil {
+		return nil, err
+	}
+	if !user.EmailVerified() {
+		if err := s.db.SetEmailVerified(ctx, user.ID, user.Email); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+
+	s.audit(user.ID, "password_reset", nil, ipAddress)
+	return user, nil
+}
+
+// SendVerification emails a user a link to confirm their email address.
+func (s *AccountService) SendVerification(ctx context.Context, user *models.User) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+
+	token := s.sign(purposeVerifyEmail, user.ID, user.Email, time.Now().Add(emailVerifyTTL))
+	return s.mail.SendTemplate(ctx, models.EmailVerify, "", user.Email, map[string]string{
+		"Username":  user.Username,
+		"Link":      s.siteURL + "/verify/" + token,
+		"ExpiresIn": "48 hours",
+	})
+}
+
+// ResendVerification sends a new verification link to the unverified user
+// with the given username or email address. Like RequestPasswordReset it
+// doesn't report unknown users.
+func (s *AccountService) ResendVerification(ctx context.Context, login string) error {
+	login = strings.TrimSpace(login)
+	user, err := s.db.GetUserByUsername(ctx, login)
+	if err == nil && user == nil {
+		user, err = s.db.GetUserByEmail(ctx, login)
+	}
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.CanSignIn() || user.EmailVerified() {
+		return nil
+	}
+	return s.SendVerification(ctx, user)
+}
+
+// VerifyEmail marks the email address a verification link was sent to as
+// verified.
+func (s *AccountService) VerifyEmail(ctx context.Context, token, ipAddress string) (*models.User, error) {
+	user, err := s.verify(ctx, purposeVerifyEmail, token, func(u *models.User) string { return strings.ToLower(u.Email) })
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerified() {
+		return user, nil
+	}
+
+	if err := s.db.SetEmailVerified(ctx, user.ID, user.Email); err != nil {
+		return nil, err
+	}
+	s.audit(user.ID, "email_verified", map[string]interface{}{"email": user.Email}, ipAddress)
+	return user, nil
+}
+
+// sign returns a token of the form "<user id>.<expiry>.<signature>". The
+// signature covers the purpose and binding, which are not in the token.
+func (s *AccountService) sign(purpose string, userID int64, binding string, expires time.Time) string {
+	id := strconv.FormatInt(userID, 10)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return id + "." + exp + "." + s.signature(purpose, id, exp, binding)
+}
+
+// verify checks a token's expiry and signature against the user's current
+// binding and returns the user.
+func (s *AccountService) verify(ctx context.Context, purpose, token string, binding func(*models.User) string) (*models.User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrAccountLinkInvalid
+	}
+	userID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrAccountLinkInvalid
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().After(time.Unix(unix, 0)) {
+		return nil, ErrAccountLinkInvalid
+	}
+
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.CanSignIn() {
+		return nil, ErrAccountLinkInvalid
+	}
+
+	expected := s.signature(purpose, parts[0], parts[1], binding(user))
+	if !hmac.Equal([]byte(parts[2]), []byte(expected)) {
+		return nil, ErrAccountLinkInvalid
+	}
+	return user, nil
+}
+
+func (s *AccountService) signature(purpose, userID, expires, binding string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(purpose + "\n" + userID + "\n" + expires + "\n" + binding))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// audit records an account event with the user as the actor. It runs in
+// the background so a slow audit write doesn't delay the response.
+func (s *AccountService) audit(userID int64, action string, details map[string]interface{}, ipAddress string) {
+	var detailsStr string
+	if details != nil {
+		if b, err := json.Marshal(details); err == nil {
+			detailsStr = string(b)
+		}
+	}
+
+	go func() {
+		if err := s.db.LogAudit(context.Background(), &userID, action, "user", &userID, detailsStr, ipAddress); err != nil {
+			fmt.Printf("Warning: failed to audit %s: %v\n", action, err)
+		}
+	}()
+}
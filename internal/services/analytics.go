@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+const (
+	// pageViewDayRetention is how long daily view counts are kept.
+	pageViewDayRetention = 365 * 24 * time.Hour
+	// analyticsListLimit caps the most viewed and unviewed page lists.
+	analyticsListLimit = 20
+)
+
+// AnalyticsPeriods are the report periods offered, in days.
+var AnalyticsPeriods = []int{7, 30, 90, 365}
+
+// PageAnalytics reports page views over a period.
+type PageAnalytics struct {
+	// Days is the length of the period.
+	Days int `json:"days"`
+	// Daily has one entry per day of the period, oldest first.
+	Daily      []models.PageViewDay  `json:"daily"`
+	TotalViews int64                 `json:"total_views"`
+	MostViewed []models.PageViewStat `json:"most_viewed"`
+	// Unviewed lists published pages nobody viewed in the period.
+	Unviewed []models.PageViewStat `json:"unviewed"`
+}
+
+// MaxDailyViews returns the busiest day's views, for scaling charts.
+func (a *PageAnalytics) MaxDailyViews() int64 {
+	var max int64
+	for _, d := range a.Daily {
+		if d.Views > max {
+			max = d.Views
+		}
+	}
+	return max
+}
+
+// Analytics reports views of the pages authorID created over the last days
+// days, or of every page for a nil authorID. Views still buffered in
+// memory are written out first so the report is current.
+func (s *FreshnessService) Analytics(ctx context.Context, days int, authorID *int64) (*PageAnalytics, error) {
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-days)
+
+	counted, err := s.db.ListPageViewDays(ctx, since, authorID)
+	if err != nil {
+		return nil, err
+	}
+	report := &PageAnalytics{Days: days, Daily: make([]models.PageViewDay, days)}
+	for i := range report.Daily {
+		report.Daily[i].Day = since.AddDate(0, 0, i)
+	}
+	for _, d := range counted {
+		if i := int(d.Day.UTC().Sub(since) / (24 * time.Hour)); i >= 0 && i < days {
+			report.Daily[i].Views += d.Views
+		}
+		report.TotalViews += d.Views
+	}
+
+	if report.MostViewed, err = s.db.ListMostViewedPages(ctx, since, authorID, analyticsListLimit); err != nil {
+		return nil, err
+	}
+	if report.Unviewed, err = s.db.ListUnviewedPages(ctx, since, authorID, analyticsListLimit); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// ValidAnalyticsPeriod returns days if it is one of AnalyticsPeriods, and
+// 30 otherwise.
+func ValidAnalyticsPeriod(days int) int {
+	for _, p := range AnalyticsPeriods {
+		if p == days {
+			return days
+		}
+	}
+	return 30
+}
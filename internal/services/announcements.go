@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// announcementsSetting is the settings key holding the announcements as JSON.
+const announcementsSetting = "announcements"
+
+var (
+	ErrAnnouncementNotFound  = errors.New("announcement not found")
+	ErrEmptyAnnouncement     = errors.New("announcement message is required")
+	ErrInvalidAnnouncement   = errors.New("level must be info, warning or critical")
+	ErrAnnouncementExpiry    = errors.New("expiry must be in the future")
+	ErrAnnouncementPermanent = errors.New("announcement cannot be dismissed")
+)
+
+// AnnouncementInput contains the fields of a new announcement.
+type AnnouncementInput struct {
+	Message     string                   `json:"message"`
+	Level       models.AnnouncementLevel `json:"level"`
+	Dismissible bool                     `json:"dismissible"`
+	ExpiresAt   *time.Time               `json:"expires_at,omitempty"`
+}
+
+// AnnouncementService manages the site-wide banners admins post for
+// maintenance notices and policy changes. Announcements live in the
+// settings table; per-user dismissals have their own table.
+type AnnouncementService struct {
+	db *database.DB
+	mu sync.Mutex
+}
+
+// NewAnnouncementService creates an announcement service.
+func NewAnnouncementService(db *database.DB) *AnnouncementService {
+	return &AnnouncementService{db: db}
+}
+
+// List returns every announcement, including expired ones, newest first.
+func (s *AnnouncementService) List(ctx context.Context) ([]models.Announcement, error) {
+	value, err := s.db.GetSetting(ctx, announcementsSetting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load announcements: %w", err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	var announcements []models.Announcement
+	if err := json.Unmarshal([]byte(value), &announcements); err != nil {
+		return nil, fmt.Errorf("failed to parse announcements: %w", err)
+	}
+	return announcements, nil
+}
+
+// Active returns the unexpired announcements a user should see. Pass a zero
+// userID for anonymous visitors, who can't dismiss banners.
+func (s *AnnouncementService) Active(ctx context.Context, userID int64) ([]models.Announcement, error) {
+	all, err := s.List(ctx)
+	if err != nil || len(all) == 0 {
+		return nil, err
+	}
+
+	dismissed := map[string]bool{}
+	if userID != 0 {
+		if dismissed, err = s.db.ListDismissedAnnouncements(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	var active []models.Announcement
+	for _, a := range all {
+		if a.IsExpired(now) || (a.Dismissible && dismissed[a.ID]) {
+			continue
+		}
+		active = append(active, a)
+	}
+	return active, nil
+}
+
+// Create validates and publishes a new announcement.
+func (s *AnnouncementService) Create(ctx context.Context, createdBy int64, input AnnouncementInput) (*models.Announcement, error) {
+	message := strings.TrimSpace(input.Message)
+	if message == "" {
+		return nil, ErrEmptyAnnouncement
+	}
+	if input.Level == "" {
+		input.Level = models.AnnouncementInfo
+	}
+	if !input.Level.IsValid() {
+		return nil, ErrInvalidAnnouncement
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrAnnouncementExpiry
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate announcement ID: %w", err)
+	}
+
+	announcement := models.Announcement{
+		ID:          hex.EncodeToString(b),
+		Message:     message,
+		Level:       input.Level,
+		Dismissible: input.Dismissible,
+		CreatedBy:   createdBy,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if input.ExpiresAt != nil {
+		expires := input.ExpiresAt.UTC()
+		announcement.ExpiresAt = &expires
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	all = append([]models.Announcement{announcement}, all...)
+	if err := s.save(ctx, all); err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// Delete removes an announcement and its dismissals.
+func (s *AnnouncementService) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	kept := all[:0]
+	for _, a := range all {
+		if a.ID != id {
+			kept = append(kept, a)
+		}
+	}
+	if len(kept) == len(all) {
+		return ErrAnnouncementNotFound
+	}
+
+	if err := s.save(ctx, kept); err != nil {
+		return err
+	}
+	return s.db.DeleteAnnouncementDismissals(ctx, id)
+}
+
+// Dismiss hides a dismissible announcement for a user.
+func (s *AnnouncementService) Dismiss(ctx context.Context, userID int64, id string) error {
+	all, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, a := range all {
+		if a.ID == id {
+			if !a.Dismissible {
+				return ErrAnnouncementPermanent
+			}
+			return s.db.DismissAnnouncement(ctx, userID, id)
+		}
+	}
+	return ErrAnnouncementNotFound
+}
+
+func (s *AnnouncementService) save(ctx context.Context, announcements []models.Announcement) error {
+	data, err := json.Marshal(announcements)
+	if err != nil {
+		return fmt.Errorf("failed to encode announcements: %w", err)
+	}
+	if err := s.db.SetSetting(ctx, announcementsSetting, string(data)); err != nil {
+		return fmt.Errorf("failed to save announcements: %w", err)
+	}
+	return nil
+}
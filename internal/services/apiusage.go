@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+const (
+	// apiUsageFlushInterval is how often buffered calls are written out.
+	apiUsageFlushInterval = 10 * time.Second
+	// apiUsageRetention is how long hourly counters are kept.
+	apiUsageRetention = 30 * 24 * time.Hour
+	// apiCallsPerToken is how many recent calls are kept per token.
+	apiCallsPerToken = 100
+	// apiUsageMaxBuffer caps buffered calls if the database is unavailable.
+	apiUsageMaxBuffer = 10000
+)
+
+// Anomaly thresholds for the admin overview.
+const (
+	spikeMinRequests     = 100 // requests in 24h before a spike is considered
+	spikeFactor          = 5   // times the previous daily average
+	errorRateMinRequests = 20  // requests in 24h before the error rate counts
+	errorRateThreshold   = 0.25
+	distinctIPThreshold  = 5 // client addresses among recent calls
+)
+
+// APIUsageService records API calls per token so users can spot leaked
+// tokens and admins can see unusual activity. Calls are buffered in memory
+// and flushed in batches to keep the request path cheap.
+type APIUsageService struct {
+	db *database.DB
+
+	mu     sync.Mutex
+	buffer []models.APICall
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewAPIUsageService creates an API usage service.
+func NewAPIUsageService(db *database.DB) *APIUsageService {
+	return &APIUsageService{db: db}
+}
+
+// Record buffers a call made with an API token.
+func (s *APIUsageService) Record(tokenID int64, method, path string, status int, ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buffer) >= apiUsageMaxBuffer {
+		return
+	}
+	s.buffer = append(s.buffer, models.APICall{
+		TokenID:   tokenID,
+		Method:    method,
+		Path:      path,
+		Status:    status,
+		IPAddress: ip,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+// Flush writes buffered calls to the database.
+func (s *APIUsageService) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	calls := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	if len(calls) == 0 {
+		return nil
+	}
+	return s.db.RecordAPICalls(ctx, calls, apiCallsPerToken)
+}
+
+// ForUser returns usage for a user's tokens, with their recent calls.
+func (s *APIUsageService) ForUser(ctx context.Context, userID int64, recent int) (map[int64]*models.APITokenUsage, error) {
+	usage, err := s.db.ListAPIUsage(ctx, &userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	byToken := make(map[int64]*models.APITokenUsage, len(usage))
+	for i := range usage {
+		u := &usage[i]
+		if u.Requests7d > 0 && recent > 0 {
+			if u.RecentCalls, err = s.db.ListAPICalls(ctx, u.TokenID, recent); err != nil {
+				return nil, err
+			}
+		}
+		u.Flags = usageFlags(u)
+		byToken[u.TokenID] = u
+	}
+	return byToken, nil
+}
+
+// Overview returns usage for every token, flagged tokens first.
+func (s *APIUsageService) Overview(ctx context.Context) ([]models.APITokenUsage, error) {
+	usage, err := s.db.ListAPIUsage(ctx, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range usage {
+		usage[i].Flags = usageFlags(&usage[i])
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		return len(usage[i].Flags) > 0 && len(usage[j].Flags) == 0
+	})
+	return usage, nil
+}
+
+// usageFlags describes activity that may indicate a leaked or misbehaving token.
+func usageFlags(u *models.APITokenUsage) []string {
+	var flags []string
+
+	previousDaily := float64(u.Requests7d-u.Requests24h) / 6
+	if u.Requests24h >= spikeMinRequests && float64(u.Requests24h) > spikeFactor*previousDaily {
+		flags = append(flags, "Traffic spike")
+	}
+	if u.Requests24h >= errorRateMinRequests && float64(u.Errors24h) >= errorRateThreshold*float64(u.Requests24h) {
+		flags = append(flags, "High error rate")
+	}
+	if u.DistinctIPs >= distinctIPThreshold {
+		flags = append(flags, fmt.Sprintf("Used from %d addresses", u.DistinctIPs))
+	}
+	return flags
+}
+
+// Start flushes buffered calls in the background and prunes old counters.
+func (s *APIUsageService) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(apiUsageFlushInterval)
+		defer ticker.Stop()
+
+		lastPrune := time.Time{}
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				if err := s.Flush(context.Background()); err != nil {
+					fmt.Printf("Warning: failed to flush API usage: %v\n", err)
+				}
+				return
+			}
+
+			if err := s.Flush(context.Background()); err != nil {
+				fmt.Printf("Warning: failed to flush API usage: %v\n", err)
+			}
+			if time.Since(lastPrune) > time.Hour {
+				if err := s.db.DeleteAPIUsageBefore(context.Background(), time.Now().Add(-apiUsageRetention)); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+				lastPrune = time.Now()
+			}
+		}
+	}()
+}
+
+// Stop flushes remaining calls and halts the background loop.
+func (s *APIUsageService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
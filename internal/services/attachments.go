@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gowiki/internal/models"
+)
+
+// AddAttachment records an uploaded file. An attachment without a page is
+// pending: it was uploaded while writing a new page, and is linked to the
+// page when the page is first saved.
+func (s *WikiService) AddAttachment(ctx context.Context, att *models.Attachment) error {
+	if err := s.db.CreateAttachment(ctx, att); err != nil {
+		return fmt.Errorf("failed to save attachment: %w", err)
+	}
+	return nil
+}
+
+// FindUploadByHash returns the earliest attachment with the content hash
+// whose file is still stored, or nil when there is none.
+func (s *WikiService) FindUploadByHash(ctx context.Context, hash string) (*models.Attachment, error) {
+	att, err := s.db.FindAttachmentByHash(ctx, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find attachment: %w", err)
+	}
+	if att == nil {
+		return nil, nil
+	}
+	if _, err := os.Stat(att.Filepath); err != nil {
+		// Deleted since, e.g. by the orphaned uploads cleanup
+		return nil, nil
+	}
+	return att, nil
+}
+
+// UploadAccess describes who may read a stored upload.
+type UploadAccess struct {
+	// Pages are the pages the file is attached to that still exist.
+	// Reading it takes being able to view one of them.
+	Pages []*models.Page
+	// Uploaders are the users whose uploads of the file are still pending,
+	// waiting for the page they were meant for to be saved.
+	Uploaders []int64
+	// Open is set for files the wiki has no attachment record of, stored
+	// before uploads were recorded. Anyone who may browse the wiki may
+	// read them, as they always could.
+	Open bool
+	Hash string // SHA-256 of the content when known, hex encoded
+}
+
+// GetUploadAccess returns who may read the stored file at path.
+func (s *WikiService) GetUploadAccess(ctx context.Context, path string) (*UploadAccess, error) {
+	attachments, err := s.db.ListAttachmentsByPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+
+	access := &UploadAccess{Open: len(attachments) == 0}
+	seen := make(map[int64]bool)
+	for _, att := range attachments {
+		if att.Hash != "" {
+			access.Hash = att.Hash
+		}
+		if att.PageID == nil {
+			access.Uploaders = append(access.Uploaders, att.UploaderID)
+			continue
+		}
+		if seen[*att.PageID] {
+			continue
+		}
+		seen[*att.PageID] = true
+
+		page, err := s.db.GetPageByID(ctx, *att.PageID)
+		if err != nil {
+			return nil, err
+		}
+		if page != nil {
+			access.Pages = append(access.Pages, page)
+		}
+	}
+	return access, nil
+}
+
+// ListAttachments returns the files attached to a page, newest first.
+func (s *WikiService) ListAttachments(ctx context.Context, pageID int64) ([]models.Attachment, error) {
+	return s.db.ListAttachments(ctx, pageID)
+}
+
+// linkPendingAttachments links the author's pending attachments that a page
+// shows or links to, when it's created or its content changes. The rest
+// stay pending for the page they were meant for.
+func (s *WikiService) linkPendingAttachments(ctx context.Context, page *models.Page, authorID int64) {
+	pending, err := s.db.ListPendingAttachments(ctx, authorID)
+	if err != nil || len(pending) == 0 {
+		return
+	}
+
+	used := uploadNames(page.ContentHTML)
+	for _, att := range pending {
+		if !used[filepath.Base(att.Filepath)] {
+			continue
+		}
+		if err := s.db.LinkAttachment(ctx, att.ID, page.ID); err != nil {
+			fmt.Printf("Warning: failed to link attachment %d: %v\n", att.ID, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"gowiki/internal/models"
+)
+
+func TestGetUploadAccess(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	wiki := NewWikiService(db, NewMarkdownService())
+	user := newTestUser(t, db, "alice")
+
+	page := &models.Page{Slug: "guide", Title: "Guide", AuthorID: user.ID, IsPublished: true}
+	if err := db.CreatePage(ctx, page); err != nil {
+		t.Fatalf("create page: %v", err)
+	}
+
+	attach := func(path string, pageID *int64) {
+		t.Helper()
+		err := db.CreateAttachment(ctx, &models.Attachment{
+			PageID:     pageID,
+			Filename:   filepath.Base(path),
+			Filepath:   path,
+			MimeType:   "image/png",
+			UploaderID: user.ID,
+			Hash:       "abc123",
+		})
+		if err != nil {
+			t.Fatalf("create attachment: %v", err)
+		}
+	}
+	attach("uploads/linked.png", &page.ID)
+	attach("uploads/pending.png", nil)
+
+	t.Run("legacy file", func(t *testing.T) {
+		access, err := wiki.GetUploadAccess(ctx, "uploads/legacy.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !access.Open {
+			t.Error("file with no attachment record isn't open")
+		}
+	})
+
+	t.Run("linked file", func(t *testing.T) {
+		access, err := wiki.GetUploadAccess(ctx, "uploads/linked.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if access.Open {
+			t.Error("attached file is open")
+		}
+		if len(access.Pages) != 1 || access.Pages[0].ID != page.ID {
+			t.Errorf("Pages = %v, want the guide page", access.Pages)
+		}
+		if access.Hash != "abc123" {
+			t.Errorf("Hash = %q, want abc123", access.Hash)
+		}
+	})
+
+	t.Run("pending file", func(t *testing.T) {
+		access, err := wiki.GetUploadAccess(ctx, "uploads/pending.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if access.Open || len(access.Pages) != 0 {
+			t.Errorf("Open = %v, Pages = %v, want closed with no pages", access.Open, access.Pages)
+		}
+		if len(access.Uploaders) != 1 || access.Uploaders[0] != user.ID {
+			t.Errorf("Uploaders = %v, want [%d]", access.Uploaders, user.ID)
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// ErrInvalidDate is returned for a date filter that isn't YYYY-MM-DD.
+var ErrInvalidDate = errors.New("dates must be formatted as YYYY-MM-DD")
+
+// filterDateLayout is the format of the from and to filters.
+const filterDateLayout = "2006-01-02"
+
+// ParseAuditFilter reads the user, action, entity_type, from and to query
+// parameters shared by the audit log page, its export and the API. Dates are
+// UTC days and both ends are inclusive.
+func ParseAuditFilter(query url.Values) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Username:   strings.TrimSpace(query.Get("user")),
+		Action:     strings.TrimSpace(query.Get("action")),
+		EntityType: strings.TrimSpace(query.Get("entity_type")),
+	}
+
+	var err error
+	filter.Since, filter.Until, err = parseDateRange(query)
+	return filter, err
+}
+
+// parseDateRange reads the from and to query parameters as UTC days, both
+// inclusive. It returns the start of from and the end of to, nil when a
+// parameter is empty.
+func parseDateRange(query url.Values) (since, until *time.Time, err error) {
+	if from := strings.TrimSpace(query.Get("from")); from != "" {
+		t, err := time.Parse(filterDateLayout, from)
+		if err != nil {
+			return nil, nil, ErrInvalidDate
+		}
+		since = &t
+	}
+	if to := strings.TrimSpace(query.Get("to")); to != "" {
+		t, err := time.Parse(filterDateLayout, to)
+		if err != nil {
+			return nil, nil, ErrInvalidDate
+		}
+		t = t.AddDate(0, 0, 1)
+		until = &t
+	}
+	return since, until, nil
+}
+
+// auditCSVHeader is the first row of an audit log export.
+var auditCSVHeader = []string{"id", "created_at", "user_id", "username", "action", "entity_type", "entity_id", "ip_address", "details"}
+
+// WriteAuditCSV writes entries as CSV. Cells that a spreadsheet would read
+// as a formula are prefixed with a quote, since usernames and details are
+// user-supplied.
+func WriteAuditCSV(w io.Writer, entries []models.AuditEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(auditCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		record := []string{
+			strconv.FormatInt(e.ID, 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			optionalID(e.UserID),
+			csvCell(e.Username),
+			csvCell(e.Action),
+			csvCell(e.EntityType),
+			optionalID(e.EntityID),
+			csvCell(e.IPAddress),
+			csvCell(e.Details),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+// csvCell neutralizes spreadsheet formula injection.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// auditActorKey is the context key of the request's audit actor.
+type auditActorKey struct{}
+
+// auditActor is the user and client address a request acts as.
+type auditActor struct {
+	userID *int64
+	ip     string
+}
+
+// WithAuditActor returns a context whose audit entries are attributed to
+// userID, which may be nil for anonymous requests, from the address ip.
+func WithAuditActor(ctx context.Context, userID *int64, ip string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, auditActor{userID: userID, ip: ip})
+}
+
+// Auditor records user activity such as page edits, sign-ins and share
+// links in the audit log. Administrative actions are logged separately and
+// regardless of this switch.
+type Auditor struct {
+	db      *database.DB
+	enabled bool
+}
+
+// NewAuditor creates an auditor that logs when WIKI_AUDIT_ACTIVITY is on.
+func NewAuditor(db *database.DB, cfg *config.Config) *Auditor {
+	return &Auditor{db: db, enabled: cfg.Security.AuditActivity}
+}
+
+// Log records an event. The actor and client address come from ctx; userID
+// overrides the actor when the caller knows it better, e.g. while signing
+// in. It runs in the background so a slow audit write doesn't delay the
+// request. A nil Auditor logs nothing.
+func (a *Auditor) Log(ctx context.Context, userID *int64, action, entityType string, entityID *int64, details map[string]interface{}) {
+	if a == nil || !a.enabled {
+		return
+	}
+
+	actor, _ := ctx.Value(auditActorKey{}).(auditActor)
+	if userID == nil {
+		userID = actor.userID
+	}
+
+	var detailsStr string
+	if details != nil {
+		if b, err := json.Marshal(details); err == nil {
+			detailsStr = string(b)
+		}
+	}
+
+	go func() {
+		if err := a.db.LogAudit(context.Background(), userID, action, entityType, entityID, detailsStr, actor.ip); err != nil {
+			fmt.Printf("Warning: failed to audit %s: %v\n", action, err)
+		}
+	}()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/subtle"
+	"database/sql"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -24,6 +25,9 @@ var (
 	ErrInvalidCredentials = errors.New("invalid username or password")
 	ErrUserNotFound       = errors.New("user not found")
 	ErrUserInactive       = errors.New("user account is inactive")
+	ErrUserLocked         = errors.New("user account is locked")
+	ErrDirectoryAccount   = errors.New("account signs in through the directory")
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current one")
 	ErrUserExists         = errors.New("username or email already exists")
 	ErrInvalidPassword    = errors.New("password does not meet requirements")
 	ErrInvalidUsername    = errors.New("username does not meet requirements")
@@ -34,23 +38,69 @@ var (
 type AuthService struct {
 	db         *database.DB
 	cfg        *config.Config
+	settings   *SettingsService
 	bcryptCost int
+	auditor    *Auditor
+	directory  *ldapDirectory // Nil unless LDAP sign-in is configured
 }
 
 // NewAuthService creates a new authentication service.
-func NewAuthService(db *database.DB, cfg *config.Config) *AuthService {
+func NewAuthService(db *database.DB, cfg *config.Config, settings *SettingsService) *AuthService {
 	return &AuthService{
 		db:         db,
 		cfg:        cfg,
+		settings:   settings,
 		bcryptCost: cfg.Security.BcryptCost,
+		directory:  newLDAPDirectory(cfg.LDAP),
 	}
 }
 
+// SetAuditor sets where sign-ins are audited.
+func (s *AuthService) SetAuditor(auditor *Auditor) {
+	s.auditor = auditor
+}
+
 // Authenticate verifies user credentials and returns the user if valid.
+// Successful and failed attempts are audited.
 func (s *AuthService) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := s.authenticate(ctx, username, password)
+	switch {
+	case err == nil:
+		s.auditor.Log(ctx, &user.ID, "login", "user", &user.ID, nil)
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrUserInactive), errors.Is(err, ErrUserLocked):
+		details := map[string]interface{}{
+			"username": strings.TrimSpace(username),
+			"reason":   err.Error(),
+		}
+		if user != nil {
+			s.auditor.Log(ctx, &user.ID, "login_failed", "user", &user.ID, details)
+		} else {
+			s.auditor.Log(ctx, nil, "login_failed", "user", nil, details)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// authenticate checks credentials. On failure it still returns the user
+// the credentials named, if any, for auditing.
+func (s *AuthService) authenticate(ctx context.Context, username, password string) (*models.User, error) {
 	// Normalize username
 	username = strings.TrimSpace(username)
 
+	// The directory comes first; local accounts cover users it doesn't
+	// know and keep admins able to sign in while it is down
+	if s.directory != nil {
+		user, err := s.authenticateDirectory(ctx, username, password)
+		if errors.Is(err, errDirectoryUnavailable) {
+			fmt.Printf("Warning: LDAP sign-in failed, trying local accounts: %v\n", err)
+		} else if !errors.Is(err, errDirectoryUserNotFound) {
+			return user, err
+		}
+	}
+
 	// Attempt to find user by username or email
 	user, err := s.db.GetUserByUsername(ctx, username)
 	if err != nil {
@@ -75,12 +125,21 @@ func (s *AuthService) Authenticate(ctx context.Context, username, password strin
 
 	// Check if user is active
 	if !user.IsActive {
-		return nil, ErrUserInactive
+		return user, ErrUserInactive
+	}
+	if user.IsLocked() {
+		return user, ErrUserLocked
+	}
+
+	// Directory accounts have a random local password and only sign in
+	// through the directory
+	if user.IsDirectoryAccount() {
+		return user, ErrDirectoryAccount
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return nil, ErrInvalidCredentials
+		return user, ErrInvalidCredentials
 	}
 
 	// Update last login
@@ -111,7 +170,7 @@ func (s *AuthService) CreateUser(ctx context.Context, input models.UserCreate) (
 
 	// Validate role
 	if !input.Role.IsValid() {
-		input.Role = models.Role(s.cfg.Site.DefaultRole)
+		input.Role = s.settings.DefaultRole(ctx)
 	}
 
 	// Check if user already exists
@@ -140,6 +199,10 @@ func (s *AuthService) CreateUser(ctx context.Context, input models.UserCreate) (
 		IsActive:     true,
 		CreatedAt:    now,
 		UpdatedAt:    now,
+		DirectoryDN:  input.DirectoryDN,
+	}
+	if !input.Unverified {
+		user.EmailVerifiedAt = sql.NullTime{Time: now, Valid: true}
 	}
 
 	if err := s.db.CreateUser(ctx, user); err != nil {
@@ -158,6 +221,9 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int64, currentP
 	if user == nil {
 		return ErrUserNotFound
 	}
+	if user.IsDirectoryAccount() {
+		return ErrDirectoryAccount
+	}
 
 	// Verify current password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
@@ -168,6 +234,9 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int64, currentP
 	if err := s.ValidatePassword(newPassword); err != nil {
 		return err
 	}
+	if newPassword == currentPassword {
+		return ErrPasswordUnchanged
+	}
 
 	// Hash new password
 	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), s.bcryptCost)
@@ -175,8 +244,10 @@ func (s *AuthService) ChangePassword(ctx context.Context, userID int64, currentP
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	// Choosing a new password satisfies a forced rotation
 	hashStr := string(hash)
-	return s.db.UpdateUser(ctx, userID, &models.UserUpdate{Password: &hashStr})
+	mustChange := false
+	return s.db.UpdateUser(ctx, userID, &models.UserUpdate{Password: &hashStr, MustChangePassword: &mustChange})
 }
 
 // ValidateUsername checks if a username meets requirements.
@@ -376,6 +447,7 @@ func (s *AuthService) createUserInternal(ctx context.Context, input models.UserC
 		CreatedAt:    time.Now().UTC(),
 		UpdatedAt:    time.Now().UTC(),
 	}
+	user.EmailVerifiedAt = sql.NullTime{Time: user.CreatedAt, Valid: true}
 
 	if err := s.db.CreateUser(ctx, user); err != nil {
 		if strings.Contains(err.Error(), "UNIQUE constraint") {
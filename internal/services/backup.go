@@ -15,6 +15,7 @@ import (
 type BackupService struct {
 	enabled bool
 	path    string
+	git     *gitRepo
 }
 
 // NewBackupService creates a new BackupService.
@@ -28,10 +29,30 @@ func NewBackupService(cfg *config.Config) (*BackupService, error) {
 		return nil, fmt.Errorf("failed to create backup directory: %w", err)
 	}
 
-	return &BackupService{
+	service := &BackupService{
 		enabled: true,
 		path:    cfg.Backup.Path,
-	}, nil
+	}
+
+	if cfg.Backup.Git {
+		repo, err := newGitRepo(cfg.Backup)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize backup git repository: %w", err)
+		}
+		service.git = repo
+	}
+
+	return service, nil
+}
+
+// Enabled reports whether markdown backups are being written.
+func (s *BackupService) Enabled() bool {
+	return s.enabled
+}
+
+// Path returns the root of the backup folder tree.
+func (s *BackupService) Path() string {
+	return s.path
 }
 
 // SavePageAsMarkdown saves a page's content as a markdown file with YAML frontmatter.
@@ -41,7 +62,25 @@ func (s *BackupService) SavePageAsMarkdown(page *models.Page, authorName string,
 		return nil
 	}
 
-	// Build frontmatter
+	content := PageFrontmatter(page, authorName) + page.Content
+	filePath := s.pageFile(page.Slug, pagePath)
+
+	// Create directory structure if needed
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Write file
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write backup file: %w", err)
+	}
+
+	return nil
+}
+
+// PageFrontmatter returns the YAML frontmatter block written ahead of page
+// content in backups and markdown exports.
+func PageFrontmatter(page *models.Page, authorName string) string {
 	var tags []string
 	for _, tag := range page.Tags {
 		tags = append(tags, tag.Name)
@@ -66,41 +105,47 @@ func (s *BackupService) SavePageAsMarkdown(page *models.Page, authorName string,
 	frontmatter.WriteString(fmt.Sprintf("published: %t\n", page.IsPublished))
 	frontmatter.WriteString("---\n\n")
 
-	// Combine frontmatter with content
-	content := frontmatter.String() + page.Content
-
-	// Build directory path from parent slugs
-	dirPath := s.path
-	for _, parentSlug := range pagePath {
-		dirPath = filepath.Join(dirPath, sanitizeFilename(parentSlug))
-	}
+	return frontmatter.String()
+}
 
-	// Create directory structure if needed
-	if err := os.MkdirAll(dirPath, 0755); err != nil {
-		return fmt.Errorf("failed to create backup directory: %w", err)
+// DeleteBackup removes the markdown backup file for a page.
+// The pagePath parameter contains parent page slugs for hierarchical folder structure.
+func (s *BackupService) DeleteBackup(slug string, pagePath []string) error {
+	if !s.enabled {
+		return nil
 	}
 
-	// Extract just the last segment of the slug for the filename
-	slugParts := strings.Split(page.Slug, "/")
-	finalName := slugParts[len(slugParts)-1]
-	filename := sanitizeFilename(finalName) + ".md"
-	filePath := filepath.Join(dirPath, filename)
+	filePath := s.pageFile(slug, pagePath)
 
-	// Write file
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write backup file: %w", err)
+	// Remove file if it exists, ignore if it doesn't
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete backup file: %w", err)
 	}
 
+	// Try to remove empty parent directories
+	s.cleanEmptyDirs(filepath.Dir(filePath))
+
 	return nil
 }
 
-// DeleteBackup removes the markdown backup file for a page.
-// The pagePath parameter contains parent page slugs for hierarchical folder structure.
-func (s *BackupService) DeleteBackup(slug string, pagePath []string) error {
+// ReadPageMarkdown returns the markdown backup file of the page with slug,
+// frontmatter included. The error satisfies os.IsNotExist when the page has
+// no backup file.
+func (s *BackupService) ReadPageMarkdown(slug string) (string, error) {
 	if !s.enabled {
-		return nil
+		return "", os.ErrNotExist
 	}
+	parts := strings.Split(slug, "/")
+	content, err := os.ReadFile(s.pageFile(slug, parts[:len(parts)-1]))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
 
+// pageFile returns where the backup of the page with slug is written. The
+// pagePath parameter contains parent page slugs for hierarchical folder structure.
+func (s *BackupService) pageFile(slug string, pagePath []string) string {
 	// Build directory path from parent slugs
 	dirPath := s.path
 	for _, parentSlug := range pagePath {
@@ -110,17 +155,19 @@ func (s *BackupService) DeleteBackup(slug string, pagePath []string) error {
 	// Extract just the last segment of the slug for the filename
 	slugParts := strings.Split(slug, "/")
 	finalName := slugParts[len(slugParts)-1]
-	filename := sanitizeFilename(finalName) + ".md"
-	filePath := filepath.Join(dirPath, filename)
+	return filepath.Join(dirPath, sanitizeFilename(finalName)+".md")
+}
 
-	// Remove file if it exists, ignore if it doesn't
-	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete backup file: %w", err)
+// Commit records all pending backup file changes in git, attributed to author.
+// It is a no-op unless git sync is enabled.
+func (s *BackupService) Commit(message string, author *models.User) error {
+	if !s.enabled || s.git == nil {
+		return nil
+	}
+	if err := s.git.commit(message, author); err != nil {
+		fmt.Printf("Warning: Failed to commit backup changes: %v\n", err)
+		return err
 	}
-
-	// Try to remove empty parent directories
-	s.cleanEmptyDirs(dirPath)
-
 	return nil
 }
 
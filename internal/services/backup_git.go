@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/models"
+)
+
+// gitTimeout bounds every git invocation so a hung remote cannot block requests.
+const gitTimeout = 60 * time.Second
+
+// gitRepo mirrors the backup directory into a git repository.
+type gitRepo struct {
+	dir    string
+	remote string
+	branch string
+	push   bool
+
+	// mu serializes changes to the working tree and history. Pushes don't
+	// hold it, so a slow remote never holds up commits.
+	mu sync.Mutex
+
+	// pushMu guards pushing and pushQueued. While a push runs, commits only
+	// queue another one to follow it.
+	pushMu     sync.Mutex
+	pushing    bool
+	pushQueued bool
+}
+
+// newGitRepo prepares the backup directory as a git repository, initializing it
+// and configuring the remote when needed.
+func newGitRepo(cfg config.BackupConfig) (*gitRepo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git backup sync enabled but git is not installed: %w", err)
+	}
+
+	repo := &gitRepo{
+		dir:    cfg.Path,
+		remote: cfg.GitRemote,
+		branch: cfg.GitBranch,
+		push:   cfg.GitPush,
+	}
+
+	if _, err := os.Stat(filepath.Join(cfg.Path, ".git")); os.IsNotExist(err) {
+		if _, err := repo.run("init", "--initial-branch="+repo.branch); err != nil {
+			return nil, err
+		}
+	}
+
+	if repo.remote != "" {
+		current, err := repo.run("remote", "get-url", "origin")
+		switch {
+		case err != nil:
+			if _, err := repo.run("remote", "add", "origin", repo.remote); err != nil {
+				return nil, err
+			}
+		case strings.TrimSpace(current) != repo.remote:
+			if _, err := repo.run("remote", "set-url", "origin", repo.remote); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return repo, nil
+}
+
+// commit stages every change in the backup directory and commits it as author.
+// Nothing is committed when the tree is clean. Pushing happens in the background.
+func (r *gitRepo) commit(message string, author *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.run("add", "--all"); err != nil {
+		return err
+	}
+
+	status, err := r.run("status", "--porcelain")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) == "" {
+		return nil
+	}
+
+	args := []string{"commit", "--quiet", "-m", message}
+	if author != nil {
+		args = append(args, "--author", fmt.Sprintf("%s <%s>", author.Username, author.Email))
+	}
+	if _, err := r.run(args...); err != nil {
+		return err
+	}
+
+	if r.push {
+		r.schedulePush()
+	}
+	return nil
+}
+
+// schedulePush starts a background push, or queues one to run after the
+// push in progress so it picks up the latest commits.
+func (r *gitRepo) schedulePush() {
+	r.pushMu.Lock()
+	defer r.pushMu.Unlock()
+
+	if r.pushing {
+		r.pushQueued = true
+		return
+	}
+	r.pushing = true
+	go r.pushRemote()
+}
+
+// pushRemote pushes until no more pushes are queued. Git locks the refs it
+// reads, so commits can go on while it runs.
+func (r *gitRepo) pushRemote() {
+	for {
+		if _, err := r.run("push", "--quiet", "origin", "HEAD:"+r.branch); err != nil {
+			fmt.Printf("Warning: Failed to push backup repository: %v\n", err)
+		}
+
+		r.pushMu.Lock()
+		if !r.pushQueued {
+			r.pushing = false
+			r.pushMu.Unlock()
+			return
+		}
+		r.pushQueued = false
+		r.pushMu.Unlock()
+	}
+}
+
+// run executes a git command in the backup directory. The wiki itself is the
+// committer so commits work even when the host has no git identity configured.
+func (r *gitRepo) run(args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_COMMITTER_NAME=GoWiki",
+		"GIT_COMMITTER_EMAIL=gowiki@localhost",
+		"GIT_AUTHOR_NAME=GoWiki",
+		"GIT_AUTHOR_EMAIL=gowiki@localhost",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
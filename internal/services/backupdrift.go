@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gowiki/internal/models"
+)
+
+// BackupDrift is a page whose markdown backup file has fallen out of step
+// with the database, e.g. after a failed write or a hand edit of the file.
+type BackupDrift struct {
+	Page    models.PageSummary
+	Missing bool // The page has no backup file at all
+	Added   int  // Lines only in the file
+	Removed int  // Lines only in the database
+}
+
+// BackupComparison sets a page's content in the database against a markdown
+// file: its backup file or one an admin uploaded.
+type BackupComparison struct {
+	Source  string // The file's name, labelling its side of the diff
+	Missing bool   // There was no file to compare with
+	Title   string // Title from the file's frontmatter, if it has one
+	Content string // The file's page content, without frontmatter
+	ContentDiff
+}
+
+// CompareWithFile compares page's content with a markdown file in the
+// backup format. Files without frontmatter are compared whole.
+func CompareWithFile(page *models.Page, source, file string) (*BackupComparison, error) {
+	parsed := parseBackupFile(file)
+	diff, err := DiffContent("database", source, page.Content, parsed.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff content: %w", err)
+	}
+	return &BackupComparison{
+		Source:      source,
+		Title:       parsed.Title,
+		Content:     parsed.Content,
+		ContentDiff: diff,
+	}, nil
+}
+
+// ComparePage compares page's content with its markdown backup file. A page
+// without a backup file compares as Missing.
+func (s *BackupService) ComparePage(page *models.Page) (*BackupComparison, error) {
+	file, err := s.ReadPageMarkdown(page.Slug)
+	if os.IsNotExist(err) {
+		return &BackupComparison{Source: "backup", Missing: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup file: %w", err)
+	}
+	return CompareWithFile(page, "backup", file)
+}
+
+// FindBackupDrift compares every page, archived ones included, with its
+// markdown backup file and returns those that differ.
+func (s *WikiService) FindBackupDrift(ctx context.Context, backup *BackupService) ([]BackupDrift, error) {
+	filter := models.NewPageFilter()
+	filter.Limit = 10000
+	filter.IncludeArchived = true
+	filter.OrderBy = "title"
+	filter.OrderDir = "ASC"
+	summaries, err := s.db.ListPages(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pages: %w", err)
+	}
+
+	var drift []BackupDrift
+	for _, summary := range summaries {
+		page, err := s.db.GetPageByID(ctx, summary.ID)
+		if err != nil || page == nil {
+			continue
+		}
+		cmp, err := backup.ComparePage(page)
+		if err != nil {
+			return nil, err
+		}
+		if cmp.Missing || cmp.Unified != "" {
+			drift = append(drift, BackupDrift{
+				Page:    summary,
+				Missing: cmp.Missing,
+				Added:   cmp.Added,
+				Removed: cmp.Removed,
+			})
+		}
+	}
+	return drift, nil
+}
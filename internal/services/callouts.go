@@ -0,0 +1,153 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Markdown extension for GitHub-style callouts: a blockquote opening with
+// a [!NOTE], [!TIP], [!IMPORTANT], [!WARNING] or [!CAUTION] line renders
+// as a styled callout box. Text after the marker replaces the default
+// title.
+
+var kindCallout = ast.NewNodeKind("Callout")
+
+// calloutTypes maps marker names to their default titles.
+var calloutTypes = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+}
+
+// calloutMarkerRe matches the marker line opening a callout.
+var calloutMarkerRe = regexp.MustCompile(`(?i)^\[!(note|tip|important|warning|caution)\](?:[ \t]+(.*))?$`)
+
+// calloutNode replaces a blockquote carrying a callout marker.
+type calloutNode struct {
+	ast.BaseBlock
+	Variant string
+	Title   string
+}
+
+func (n *calloutNode) Kind() ast.NodeKind {
+	return kindCallout
+}
+
+func (n *calloutNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Variant": n.Variant, "Title": n.Title}, nil)
+}
+
+type calloutExtension struct{}
+
+func (e *calloutExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(&calloutTransformer{}, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&calloutRenderer{}, 100),
+		),
+	)
+}
+
+type calloutTransformer struct{}
+
+func (t *calloutTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var quotes []*ast.Blockquote
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if quote, ok := node.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, quote := range quotes {
+		para, ok := quote.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+		marker := para.Lines().At(0)
+		m := calloutMarkerRe.FindStringSubmatch(strings.TrimSpace(string(marker.Value(source))))
+		if m == nil {
+			continue
+		}
+
+		calloutType := strings.ToLower(m[1])
+		node := &calloutNode{Variant: calloutType, Title: strings.TrimSpace(m[2])}
+		if node.Title == "" {
+			node.Title = calloutTypes[calloutType]
+		}
+
+		// Drop the marker line, and the paragraph with it if nothing follows
+		removeLeadingInlines(para, marker.Stop)
+		if para.ChildCount() == 0 {
+			quote.RemoveChild(quote, para)
+		}
+
+		for child := quote.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			node.AppendChild(node, child)
+			child = next
+		}
+		quote.Parent().ReplaceChild(quote.Parent(), quote, node)
+	}
+}
+
+// removeLeadingInlines removes the inline children of a paragraph that
+// start before the given source offset.
+func removeLeadingInlines(para *ast.Paragraph, stop int) {
+	for child := para.FirstChild(); child != nil; {
+		start := inlineStart(child)
+		if start < 0 || start >= stop {
+			return
+		}
+		next := child.NextSibling()
+		para.RemoveChild(para, child)
+		child = next
+	}
+}
+
+// inlineStart returns the source offset of the first text within an inline
+// node, or -1 when it has none.
+func inlineStart(node ast.Node) int {
+	if t, ok := node.(*ast.Text); ok {
+		return t.Segment.Start
+	}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if start := inlineStart(child); start >= 0 {
+			return start
+		}
+	}
+	return -1
+}
+
+type calloutRenderer struct{}
+
+func (r *calloutRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindCallout, r.render)
+}
+
+func (r *calloutRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*calloutNode)
+	if entering {
+		fmt.Fprintf(w, "<div class=\"callout callout-%s\" role=\"note\">\n<div class=\"callout-title\">%s</div>\n", n.Variant, html.EscapeString(n.Title))
+	} else {
+		w.WriteString("</div>\n")
+	}
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/jobs"
+)
+
+// uploadReferencePattern finds the upload names in links such as
+// /uploads/3f2a-diagram.png, however the link is written.
+var uploadReferencePattern = regexp.MustCompile(`/uploads/([A-Za-z0-9._-]+)`)
+
+// CleanupService deletes data that has expired or is no longer used:
+// expired API tokens and sessions, old share link visits, and uploads
+// nothing links to.
+type CleanupService struct {
+	db  *database.DB
+	cfg *config.Config
+}
+
+// NewCleanupService creates a cleanup service.
+func NewCleanupService(db *database.DB, cfg *config.Config) *CleanupService {
+	return &CleanupService{db: db, cfg: cfg}
+}
+
+// Jobs returns the cleanup jobs that are enabled by the configuration.
+func (s *CleanupService) Jobs() []jobs.Job {
+	list := []jobs.Job{
+		{
+			Name:        "expired-tokens",
+			Description: "Deletes expired API tokens and sessions",
+			Interval:    time.Hour,
+			Jitter:      5 * time.Minute,
+			LeaderOnly:  true,
+			Run:         s.DeleteExpired,
+		},
+	}
+	if s.cfg.Security.ShareAccessRetention > 0 {
+		list = append(list, jobs.Job{
+			Name:        "share-access-logs",
+			Description: "Deletes share link visits older than " + s.cfg.Security.ShareAccessRetention.String(),
+			Interval:    24 * time.Hour,
+			Jitter:      time.Hour,
+			LeaderOnly:  true,
+			Run:         s.PruneShareAccess,
+		})
+	}
+	if s.cfg.Upload.OrphanAge > 0 {
+		// Every replica cleans up, in case uploads aren't on shared storage
+		list = append(list, jobs.Job{
+			Name:        "orphaned-uploads",
+			Description: "Deletes uploads nothing has linked to for " + s.cfg.Upload.OrphanAge.String(),
+			Interval:    24 * time.Hour,
+			Jitter:      time.Hour,
+			Run:         s.DeleteOrphanedUploads,
+		})
+	}
+	return list
+}
+
+// DeleteExpired deletes expired API tokens and sessions.
+func (s *CleanupService) DeleteExpired(ctx context.Context) error {
+	tokens, err := s.db.DeleteExpiredAPITokens(ctx)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.db.DeleteExpiredSessions(ctx)
+	if err != nil {
+		return err
+	}
+	if tokens > 0 || sessions > 0 {
+		fmt.Printf("Deleted %d expired API tokens and %d expired sessions\n", tokens, sessions)
+	}
+	return nil
+}
+
+// PruneShareAccess deletes share link visits older than the retention
+// period.
+func (s *CleanupService) PruneShareAccess(ctx context.Context) error {
+	n, err := s.db.DeleteShareAccessBefore(ctx, time.Now().Add(-s.cfg.Security.ShareAccessRetention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("Deleted %d share link visits\n", n)
+	}
+	return nil
+}
+
+// DeleteOrphanedUploads deletes uploads older than the orphan age that no
+// page, revision, draft, setting or email template links to. Files with an
+// attachment on a page are kept, as are ones uploaded again within the
+// orphan age and still waiting for their page to be saved. Attachments
+// left waiting for longer are deleted with their file. Deleted files are
+// recorded in the audit log.
+func (s *CleanupService) DeleteOrphanedUploads(ctx context.Context) error {
+	entries, err := os.ReadDir(s.cfg.Upload.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	cutoff := time.Now().Add(-s.cfg.Upload.OrphanAge)
+	candidates := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		candidates[entry.Name()] = true
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	attached, err := s.db.ListAttachmentPathsInUse(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	for _, path := range attached {
+		if filepath.Dir(path) == filepath.Clean(s.cfg.Upload.Path) {
+			delete(candidates, filepath.Base(path))
+		}
+	}
+
+	err = s.db.ScanUploadReferences(ctx, func(text string) {
+		for _, m := range uploadReferencePattern.FindAllStringSubmatch(text, -1) {
+			delete(candidates, m[1])
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var deleted []string
+	for name := range candidates {
+		path := filepath.Join(s.cfg.Upload.Path, name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete upload %s: %w", name, err)
+		}
+		if err := s.db.DeleteAttachmentsByPath(ctx, path); err != nil {
+			return fmt.Errorf("failed to delete attachments of %s: %w", name, err)
+		}
+		deleted = append(deleted, name)
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	fmt.Printf("Deleted %d orphaned uploads\n", len(deleted))
+	details, _ := json.Marshal(map[string]interface{}{"files": deleted})
+	if err := s.db.LogAudit(context.Background(), nil, "upload_cleanup", "system", nil, string(details), ""); err != nil {
+		fmt.Printf("Warning: Failed to write audit log: %v\n", err)
+	}
+	return nil
+}
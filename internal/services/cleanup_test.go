@@ -0,0 +1,83 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/models"
+)
+
+func TestDeleteOrphanedUploads(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice")
+	dir := t.TempDir()
+
+	page := &models.Page{Slug: "guide", Title: "Guide", AuthorID: user.ID, IsPublished: true}
+	if err := db.CreatePage(ctx, page); err != nil {
+		t.Fatalf("create page: %v", err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"linked.png", "recent.png", "abandoned.png", "orphan.png"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	attach := func(name string, pageID *int64) *models.Attachment {
+		t.Helper()
+		att := &models.Attachment{
+			PageID:     pageID,
+			Filename:   name,
+			Filepath:   filepath.Join(dir, name),
+			MimeType:   "image/png",
+			UploaderID: user.ID,
+		}
+		if err := db.CreateAttachment(ctx, att); err != nil {
+			t.Fatalf("create attachment: %v", err)
+		}
+		return att
+	}
+	attach("linked.png", &page.ID)
+	attach("recent.png", nil)
+	abandoned := attach("abandoned.png", nil)
+	if _, err := db.ExecContext(ctx, "UPDATE attachments SET created_at = ? WHERE id = ?", old.UTC(), abandoned.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := NewCleanupService(db, &config.Config{Upload: config.UploadConfig{Path: dir, OrphanAge: 24 * time.Hour}})
+	if err := cleanup.DeleteOrphanedUploads(ctx); err != nil {
+		t.Fatalf("DeleteOrphanedUploads: %v", err)
+	}
+
+	for name, kept := range map[string]bool{
+		"linked.png":    true,
+		"recent.png":    true,
+		"abandoned.png": false,
+		"orphan.png":    false,
+	} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != kept {
+			t.Errorf("%s exists = %v, want %v", name, exists, kept)
+		}
+	}
+
+	rows, err := db.ListAttachmentsByPath(ctx, filepath.Join(dir, "abandoned.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("abandoned.png still has %d attachments", len(rows))
+	}
+}
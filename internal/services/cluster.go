@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+)
+
+var (
+	ErrLockHeld = errors.New("lock is held by another replica")
+)
+
+// Cluster topics broadcast between replicas.
+const (
+	TopicIPRules  = "ip_rules"
+	TopicPages    = "pages"
+	TopicRoles    = "roles"
+	TopicSettings = "settings"
+)
+
+// leaderLock is the lock name used for leader election.
+const leaderLock = "leader"
+
+// clusterEventRetention is how long broadcast events are kept for slow pollers.
+const clusterEventRetention = time.Hour
+
+// clusterEventOverlap is how far back each poll looks again for events that
+// committed after events with higher IDs. Delivered events are remembered
+// for as long so none is delivered twice.
+const clusterEventOverlap = time.Minute
+
+// Cluster coordinates replicas that share one database: named locks for
+// exclusive work, leader election for schedulers, and a polled event feed
+// for cache invalidation. A single replica is simply always the leader.
+type Cluster struct {
+	db     *database.DB
+	cfg    config.ClusterConfig
+	nodeID string
+
+	leader atomic.Bool
+	lastID int64
+	seen   map[int64]time.Time // Delivered events within the overlap, by ID
+
+	mu          sync.RWMutex
+	subscribers map[string][]func(payload string)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewCluster creates a cluster coordinator for this process.
+func NewCluster(db *database.DB, cfg *config.Config) *Cluster {
+	nodeID := cfg.Cluster.NodeID
+	if nodeID == "" {
+		nodeID = defaultNodeID()
+	}
+
+	return &Cluster{
+		db:          db,
+		cfg:         cfg.Cluster,
+		nodeID:      nodeID,
+		seen:        make(map[int64]time.Time),
+		subscribers: make(map[string][]func(string)),
+	}
+}
+
+// NodeID returns the identifier this replica uses as lock owner.
+func (c *Cluster) NodeID() string {
+	return c.nodeID
+}
+
+// IsLeader reports whether this replica currently holds the leader lease.
+func (c *Cluster) IsLeader() bool {
+	return c.leader.Load()
+}
+
+// Start runs leader election and event polling in the background.
+func (c *Cluster) Start() {
+	ctx := context.Background()
+
+	// Only events published after startup are relevant to this process
+	if id, err := c.db.LatestClusterEventID(ctx); err == nil {
+		c.lastID = id
+	}
+	if events, err := c.db.ListClusterEventsSince(ctx, c.lastID, time.Now().Add(-clusterEventOverlap)); err == nil {
+		for _, event := range events {
+			c.seen[event.ID] = event.CreatedAt
+		}
+	}
+	c.campaign(ctx)
+
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		renew := time.NewTicker(c.cfg.LeaderTTL / 3)
+		defer renew.Stop()
+		poll := time.NewTicker(c.cfg.PollInterval)
+		defer poll.Stop()
+
+		for {
+			select {
+			case <-renew.C:
+				c.campaign(ctx)
+			case <-poll.C:
+				c.poll(ctx)
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts background work and hands leadership to another replica.
+func (c *Cluster) Stop() {
+	if c.stop == nil {
+		return
+	}
+	close(c.stop)
+	<-c.done
+
+	if c.leader.Load() {
+		_ = c.db.ReleaseLock(context.Background(), leaderLock, c.nodeID)
+		c.leader.Store(false)
+	}
+}
+
+// TryLock acquires the named lock for ttl. It returns ErrLockHeld if another
+// replica holds it. Re-acquiring a lock this replica already holds extends it.
+func (c *Cluster) TryLock(ctx context.Context, name string, ttl time.Duration) error {
+	ok, err := c.db.AcquireLock(ctx, name, c.nodeID, time.Now().UTC().Add(ttl))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLockHeld
+	}
+	return nil
+}
+
+// Unlock releases a lock held by this replica.
+func (c *Cluster) Unlock(ctx context.Context, name string) error {
+	return c.db.ReleaseLock(ctx, name, c.nodeID)
+}
+
+// WithLock runs fn while holding the named lock. The lease is renewed while
+// fn runs so long jobs are not taken over, and released when fn returns.
+func (c *Cluster) WithLock(ctx context.Context, name string, ttl time.Duration, fn func() error) error {
+	if err := c.TryLock(ctx, name, ttl); err != nil {
+		return err
+	}
+	defer func() {
+		if err := c.Unlock(context.Background(), name); err != nil {
+			fmt.Printf("Warning: failed to release lock %s: %v\n", name, err)
+		}
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.TryLock(context.Background(), name, ttl); err != nil {
+					fmt.Printf("Warning: failed to renew lock %s: %v\n", name, err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return fn()
+}
+
+// Publish broadcasts an event to the other replicas. Subscribers in this
+// process are not called; the publisher is expected to have applied the change.
+func (c *Cluster) Publish(ctx context.Context, topic, payload string) error {
+	return c.db.PublishClusterEvent(ctx, topic, payload, c.nodeID)
+}
+
+// Subscribe registers fn to be called when another replica publishes on topic.
+func (c *Cluster) Subscribe(topic string, fn func(payload string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers[topic] = append(c.subscribers[topic], fn)
+}
+
+// campaign takes or renews the leader lease and prunes old events while leading.
+func (c *Cluster) campaign(ctx context.Context) {
+	ok, err := c.db.AcquireLock(ctx, leaderLock, c.nodeID, time.Now().UTC().Add(c.cfg.LeaderTTL))
+	if err != nil {
+		fmt.Printf("Warning: leader election failed: %v\n", err)
+		ok = false
+	}
+
+	if was := c.leader.Swap(ok); was != ok {
+		if ok {
+			fmt.Printf("Node %s is now the leader\n", c.nodeID)
+		} else {
+			fmt.Printf("Node %s is no longer the leader\n", c.nodeID)
+		}
+	}
+
+	if ok {
+		if err := c.db.PruneClusterEvents(ctx, time.Now().Add(-clusterEventRetention)); err != nil {
+			fmt.Printf("Warning: failed to prune cluster events: %v\n", err)
+		}
+	}
+}
+
+// poll delivers events published by other replicas since the last poll.
+func (c *Cluster) poll(ctx context.Context) {
+	cutoff := time.Now().Add(-clusterEventOverlap)
+	events, err := c.db.ListClusterEventsSince(ctx, c.lastID, cutoff)
+	if err != nil {
+		fmt.Printf("Warning: failed to poll cluster events: %v\n", err)
+		return
+	}
+
+	// Events before the cutoff aren't listed again unless their ID is new
+	for id, at := range c.seen {
+		if at.Before(cutoff) {
+			delete(c.seen, id)
+		}
+	}
+
+	for _, event := range events {
+		if _, ok := c.seen[event.ID]; ok {
+			continue
+		}
+		c.seen[event.ID] = event.CreatedAt
+		if event.ID > c.lastID {
+			c.lastID = event.ID
+		}
+		if event.Origin == c.nodeID {
+			continue
+		}
+
+		c.mu.RLock()
+		handlers := c.subscribers[event.Topic]
+		c.mu.RUnlock()
+
+		for _, fn := range handlers {
+			fn(event.Payload)
+		}
+	}
+}
+
+// defaultNodeID combines the hostname with a random suffix so restarted
+// processes never inherit a previous process's locks.
+func defaultNodeID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "gowiki"
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return host + "-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,39 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gowiki/internal/config"
+)
+
+func TestClusterPollOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	cluster := NewCluster(db, &config.Config{Cluster: config.ClusterConfig{NodeID: "a"}})
+
+	var got []string
+	cluster.Subscribe(TopicPages, func(payload string) { got = append(got, payload) })
+
+	// IDs are taken when an insert starts, so a lower one can commit last
+	insert := func(id int64, payload string) {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, `
+			INSERT INTO cluster_events (id, topic, payload, origin, created_at) VALUES (?, ?, ?, ?, ?)
+		`, id, TopicPages, payload, "b", time.Now().UTC()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert(10, "first")
+	cluster.poll(ctx)
+	insert(5, "late")
+	cluster.poll(ctx)
+	cluster.poll(ctx)
+
+	if len(got) != 2 || got[0] != "first" || got[1] != "late" {
+		t.Errorf("delivered %v, want [first late] once each", got)
+	}
+}
@@ -0,0 +1,56 @@
+//go:build sqlite_fts5
+
+// Tests that need a database are built with the sqlite_fts5 tag, like the
+// wiki itself, since the schema uses SQLite's full-text search:
+//
+//	go test -tags sqlite_fts5 ./...
+
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// newTestDB opens a migrated SQLite database in a temporary directory.
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.New(&config.DatabaseConfig{
+		Path:            filepath.Join(t.TempDir(), "wiki.db"),
+		MaxOpenConns:    1,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// newTestUser creates a user to own test pages and uploads.
+func newTestUser(t *testing.T, db *database.DB, username string) *models.User {
+	t.Helper()
+	now := time.Now().UTC()
+	user := &models.User{
+		Username:  username,
+		Email:     username + "@example.com",
+		Role:      models.RoleEditor,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := db.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
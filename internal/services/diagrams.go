@@ -0,0 +1,239 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Diagram languages recognised on fenced code blocks.
+const (
+	DiagramMermaid  = "mermaid"
+	DiagramPlantUML = "plantuml"
+)
+
+// PlantUMLPath is where rendered PlantUML diagrams are served from.
+const PlantUMLPath = "/diagrams/plantuml/"
+
+var (
+	ErrPlantUMLDisabled = errors.New("plantuml rendering is not configured")
+	ErrInvalidDiagram   = errors.New("invalid diagram source")
+)
+
+// plantUMLEncoding is PlantUML's base64 variant used in diagram URLs.
+var plantUMLEncoding = base64.NewEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_").WithPadding(base64.NoPadding)
+
+// plantUMLSourceRe matches an encoded diagram.
+var plantUMLSourceRe = regexp.MustCompile(`^[0-9A-Za-z_-]+$`)
+
+// maxPlantUMLSource caps the encoded diagram length, well above what a
+// page-sized diagram compresses to.
+const maxPlantUMLSource = 16384
+
+// EncodePlantUML compresses diagram source into the form PlantUML servers
+// accept in URLs.
+func EncodePlantUML(source string) string {
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write([]byte(source))
+	w.Close()
+	return plantUMLEncoding.EncodeToString(buf.Bytes())
+}
+
+// Markdown extension turning ```mermaid and ```plantuml blocks into diagrams
+
+var kindDiagram = ast.NewNodeKind("Diagram")
+
+// diagramNode replaces a fenced code block holding diagram source.
+type diagramNode struct {
+	ast.BaseBlock
+	Language string
+	Source   string
+}
+
+func (n *diagramNode) Kind() ast.NodeKind {
+	return kindDiagram
+}
+
+func (n *diagramNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Language": n.Language}, nil)
+}
+
+type diagramExtension struct {
+	transformer *diagramTransformer
+}
+
+func (e *diagramExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(e.transformer, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&diagramRenderer{}, 100),
+		),
+	)
+}
+
+type diagramTransformer struct {
+	// plantUML is set once a PlantUML server is configured; until then
+	// plantuml blocks render as ordinary code.
+	plantUML atomic.Bool
+}
+
+func (t *diagramTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var blocks []*ast.FencedCodeBlock
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if block, ok := node.(*ast.FencedCodeBlock); ok && entering {
+			switch string(block.Language(source)) {
+			case DiagramMermaid:
+				blocks = append(blocks, block)
+			case DiagramPlantUML:
+				if t.plantUML.Load() {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		var buf bytes.Buffer
+		lines := block.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			buf.Write(line.Value(source))
+		}
+
+		node := &diagramNode{
+			Language: string(block.Language(source)),
+			Source:   buf.String(),
+		}
+		block.Parent().ReplaceChild(block.Parent(), block, node)
+	}
+}
+
+type diagramRenderer struct{}
+
+func (r *diagramRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindDiagram, r.render)
+}
+
+func (r *diagramRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*diagramNode)
+	switch n.Language {
+	case DiagramMermaid:
+		// Drawn in the browser by mermaid.js, which reads the source text
+		fmt.Fprintf(w, "<div class=\"diagram diagram-mermaid\"><pre class=\"mermaid\">%s</pre></div>\n", html.EscapeString(n.Source))
+	case DiagramPlantUML:
+		fmt.Fprintf(w, "<div class=\"diagram diagram-plantuml\"><img src=\"%s%s.svg\" alt=\"PlantUML diagram\"/></div>\n", PlantUMLPath, EncodePlantUML(n.Source))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// DiagramService renders PlantUML diagrams through a PlantUML server,
+// keeping recently rendered SVGs in memory.
+type DiagramService struct {
+	serverURL string
+	client    *http.Client
+
+	mu    sync.Mutex
+	cache map[string][]byte
+}
+
+// maxCachedDiagrams bounds the in-memory SVG cache.
+const maxCachedDiagrams = 256
+
+// maxDiagramSize caps the SVG accepted from the PlantUML server.
+const maxDiagramSize = 5 << 20
+
+// NewDiagramService creates a diagram service. An empty serverURL disables
+// PlantUML rendering.
+func NewDiagramService(serverURL string, timeout time.Duration) *DiagramService {
+	return &DiagramService{
+		serverURL: serverURL,
+		client:    &http.Client{Timeout: timeout},
+		cache:     make(map[string][]byte),
+	}
+}
+
+// PlantUMLEnabled reports whether a PlantUML server is configured.
+func (s *DiagramService) PlantUMLEnabled() bool {
+	return s.serverURL != ""
+}
+
+// PlantUMLSVG returns the SVG for an encoded PlantUML diagram.
+func (s *DiagramService) PlantUMLSVG(ctx context.Context, encoded string) ([]byte, error) {
+	if !s.PlantUMLEnabled() {
+		return nil, ErrPlantUMLDisabled
+	}
+	if len(encoded) > maxPlantUMLSource || !plantUMLSourceRe.MatchString(encoded) {
+		return nil, ErrInvalidDiagram
+	}
+
+	s.mu.Lock()
+	svg, ok := s.cache[encoded]
+	s.mu.Unlock()
+	if ok {
+		return svg, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.serverURL+"/svg/"+encoded, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create plantuml request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach plantuml server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// PlantUML answers syntax errors with an error image and a 400 status,
+	// which is still worth showing to the author.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("plantuml server returned %s", resp.Status)
+	}
+
+	svg, err = io.ReadAll(io.LimitReader(resp.Body, maxDiagramSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plantuml response: %w", err)
+	}
+
+	s.mu.Lock()
+	if len(s.cache) >= maxCachedDiagrams {
+		for key := range s.cache {
+			delete(s.cache, key)
+			break
+		}
+	}
+	s.cache[encoded] = svg
+	s.mu.Unlock()
+
+	return svg, nil
+}
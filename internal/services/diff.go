@@ -0,0 +1,65 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContextLines is how many unchanged lines surround each change.
+const diffContextLines = 3
+
+// ContentDiff is a line diff between two versions of a page's markdown.
+type ContentDiff struct {
+	Unified string `json:"unified"` // Unified diff, empty when the versions match
+	Added   int    `json:"added"`   // Lines only in the newer version
+	Removed int    `json:"removed"` // Lines only in the older version
+}
+
+// DiffContent compares two versions of markdown line by line. fromName and
+// toName label the versions in the unified diff header.
+func DiffContent(fromName, toName, from, to string) (ContentDiff, error) {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	var diff ContentDiff
+	for _, op := range difflib.NewMatcher(a, b).GetOpCodes() {
+		switch op.Tag {
+		case 'r':
+			diff.Removed += op.I2 - op.I1
+			diff.Added += op.J2 - op.J1
+		case 'd':
+			diff.Removed += op.I2 - op.I1
+		case 'i':
+			diff.Added += op.J2 - op.J1
+		}
+	}
+
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        a,
+		B:        b,
+		FromFile: fromName,
+		ToFile:   toName,
+		Context:  diffContextLines,
+	})
+	if err != nil {
+		return ContentDiff{}, err
+	}
+	diff.Unified = unified
+	return diff, nil
+}
+
+// splitLines splits text into lines that each end in a newline. Unlike
+// difflib.SplitLines, a trailing newline doesn't produce an extra empty line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
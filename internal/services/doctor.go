@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+)
+
+// CheckStatus is the outcome of a self-check.
+type CheckStatus int
+
+const (
+	CheckOK CheckStatus = iota
+	CheckWarn
+	CheckFail
+)
+
+// String returns "ok", "warn" or "fail".
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckWarn:
+		return "warn"
+	case CheckFail:
+		return "fail"
+	}
+	return "ok"
+}
+
+// MarshalText encodes the status as its name in JSON.
+func (s CheckStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+const (
+	// minSecretKeyBits is the estimated entropy below which the secret key
+	// is reported as guessable.
+	minSecretKeyBits = 128
+	// maxClockSkew is how far the newest revision may be ahead of the clock
+	// before the clock is reported as wrong.
+	maxClockSkew = time.Minute
+)
+
+// DoctorCheck is the result of one self-check, with what to do about it
+// when it didn't pass.
+type DoctorCheck struct {
+	Name   string      `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail"`
+	Fix    string      `json:"fix,omitempty"`
+	// Blocking checks stop the server from starting.
+	Blocking bool `json:"-"`
+}
+
+// RunDoctor checks the installation for problems that otherwise surface as
+// obscure errors at runtime: a schema from a newer binary, missing search
+// triggers, unwritable directories, a weak secret key, a wrong clock and
+// dangling rows. It runs at startup and from `wiki doctor`.
+func RunDoctor(ctx context.Context, db *database.DB, cfg *config.Config) []DoctorCheck {
+	checks := []DoctorCheck{
+		checkSchema(ctx, db),
+		checkFTSTriggers(ctx, db),
+		checkWritable("Uploads directory", cfg.Upload.Path, "WIKI_UPLOAD_PATH"),
+	}
+	if cfg.Backup.Enabled {
+		checks = append(checks, checkWritable("Backup directory", cfg.Backup.Path, "WIKI_BACKUP_PATH"))
+	}
+	if db.Driver() == database.DriverSQLite {
+		checks = append(checks, checkWritable("Snapshot directory", cfg.Snapshot.Path, "WIKI_SNAPSHOT_PATH"))
+	}
+	checks = append(checks,
+		checkSecretKey(cfg),
+		checkClock(ctx, db, time.Now()),
+		checkOrphanedPages(ctx, db, cfg.Database.Path),
+		checkForeignKeys(ctx, db, cfg.Database.Path),
+	)
+	return checks
+}
+
+// RunReadiness runs the checks that decide whether this server can take
+// requests: the database answers, its schema matches the binary, and the
+// directories pages and files are written to are writable. They are quick
+// enough for the /readyz probe to run each time.
+func RunReadiness(ctx context.Context, db *database.DB, cfg *config.Config) []DoctorCheck {
+	checks := []DoctorCheck{
+		checkDatabase(ctx, db),
+		checkSchema(ctx, db),
+		checkWritable("Uploads directory", cfg.Upload.Path, "WIKI_UPLOAD_PATH"),
+	}
+	if cfg.Backup.Enabled {
+		checks = append(checks, checkWritable("Backup directory", cfg.Backup.Path, "WIKI_BACKUP_PATH"))
+	}
+	if db.Driver() == database.DriverSQLite {
+		checks = append(checks, checkWritable("Snapshot directory", cfg.Snapshot.Path, "WIKI_SNAPSHOT_PATH"))
+	}
+	return checks
+}
+
+// readinessTimeout bounds how long the database may take to answer a
+// readiness probe.
+const readinessTimeout = 2 * time.Second
+
+func checkDatabase(ctx context.Context, db *database.DB) DoctorCheck {
+	check := DoctorCheck{Name: "Database"}
+
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := db.HealthCheck(ctx); err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%s answered in %s", db.Driver(), time.Since(start).Round(time.Microsecond))
+	return check
+}
+
+func checkSchema(ctx context.Context, db *database.DB) DoctorCheck {
+	check := DoctorCheck{Name: "Schema version"}
+
+	current, err := db.CurrentVersion(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("could not read the schema version: %v", err)
+		check.Fix = "Check that WIKI_DB_PATH, or WIKI_DB_URL on PostgreSQL, points at the wiki's database, then start the server once to create the schema."
+		return check
+	}
+
+	latest := database.LatestVersion()
+	switch {
+	case current > latest:
+		check.Status = CheckFail
+		check.Blocking = true
+		check.Detail = fmt.Sprintf("the database is at version %d but this binary only knows up to %d", current, latest)
+		check.Fix = "Run the release that last migrated this database, or restore a backup taken before the upgrade."
+	case current < latest:
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("the database is at version %d; %d migrations are pending", current, latest-current)
+		check.Fix = "Take a backup, then start the server to apply them."
+	default:
+		check.Detail = fmt.Sprintf("version %d", current)
+	}
+	return check
+}
+
+func checkFTSTriggers(ctx context.Context, db *database.DB) DoctorCheck {
+	check := DoctorCheck{Name: "Search triggers"}
+
+	missing, err := db.MissingFTSTriggers(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	if len(missing) > 0 {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("missing %s; search results won't reflect page edits", strings.Join(missing, ", "))
+		check.Fix = "Run `wiki doctor --fix` to recreate them and rebuild the search index."
+		return check
+	}
+
+	check.Detail = "present"
+	return check
+}
+
+// checkWritable creates and removes a file in dir, creating dir first if
+// needed, as the server does on first use.
+func checkWritable(name, dir, env string) DoctorCheck {
+	check := DoctorCheck{Name: name}
+	fix := fmt.Sprintf("Make %s writable by the user running the wiki (uid %d), or point %s at a directory that is.", dir, os.Getuid(), env)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("cannot create %s: %v", dir, err)
+		check.Fix = fix
+		return check
+	}
+
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("cannot write to %s: %v", dir, err)
+		check.Fix = fix
+		return check
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	check.Detail = dir + " is writable"
+	return check
+}
+
+func checkSecretKey(cfg *config.Config) DoctorCheck {
+	check := DoctorCheck{Name: "Secret key"}
+	fix := "Set WIKI_SECRET_KEY to a random value, e.g. the output of `openssl rand -hex 32`."
+
+	if cfg.Security.SecretKeyGenerated {
+		check.Status = CheckWarn
+		check.Detail = "WIKI_SECRET_KEY is not set; a new key is generated on every start, signing out every session and invalidating API tokens"
+		check.Fix = fix
+		return check
+	}
+
+	bits := estimateEntropy(cfg.Security.SecretKey)
+	if bits < minSecretKeyBits {
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("WIKI_SECRET_KEY has about %d bits of entropy; at least %d are recommended", bits, minSecretKeyBits)
+		check.Fix = fix
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("about %d bits of entropy", bits)
+	return check
+}
+
+// estimateEntropy estimates the entropy of s in bits from the frequency of
+// its characters. It overestimates keys built from repeated words but
+// catches short alphabets and repeated characters.
+func estimateEntropy(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+
+	var perChar float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+	return int(perChar * float64(n))
+}
+
+// checkClock compares now with the newest revision. A clock behind the
+// database means this node rejects API tokens issued elsewhere as not yet
+// valid, and sorts its own edits before older ones.
+func checkClock(ctx context.Context, db *database.DB, now time.Time) DoctorCheck {
+	check := DoctorCheck{Name: "Clock"}
+	fix := "Sync the system clock with NTP, e.g. `timedatectl set-ntp true`."
+
+	if now.Year() < 2024 {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("the system clock reads %s", now.UTC().Format(time.RFC3339))
+		check.Fix = fix
+		return check
+	}
+
+	latest, err := db.GetLatestRevisionTime(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	if ahead := latest.Sub(now); ahead > maxClockSkew {
+		check.Status = CheckFail
+		check.Detail = fmt.Sprintf("the newest revision was saved %s ahead of this clock; API tokens from other nodes will be rejected", ahead.Round(time.Second))
+		check.Fix = fix
+		return check
+	}
+
+	check.Detail = now.UTC().Format(time.RFC3339)
+	return check
+}
+
+func checkOrphanedPages(ctx context.Context, db *database.DB, dbPath string) DoctorCheck {
+	check := DoctorCheck{Name: "Page authors"}
+
+	n, err := db.CountOrphanedPages(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+	if n > 0 {
+		check.Status = CheckWarn
+		check.Detail = fmt.Sprintf("%d pages have an author that no longer exists; editing them may fail", n)
+		check.Fix = fmt.Sprintf(`Reassign them to an existing user: sqlite3 %s "UPDATE pages SET author_id = <user id> WHERE author_id NOT IN (SELECT id FROM users)"`, dbPath)
+		return check
+	}
+
+	check.Detail = "every page has an author"
+	return check
+}
+
+// checkForeignKeys reports other dangling references. Pages with missing
+// authors are left to checkOrphanedPages.
+func checkForeignKeys(ctx context.Context, db *database.DB, dbPath string) DoctorCheck {
+	check := DoctorCheck{Name: "Dangling rows"}
+
+	violations, err := db.ListForeignKeyViolations(ctx)
+	if err != nil {
+		check.Status = CheckFail
+		check.Detail = err.Error()
+		return check
+	}
+
+	var found []string
+	for _, v := range violations {
+		if v.Table == "pages" && v.Parent == "users" {
+			continue
+		}
+		found = append(found, fmt.Sprintf("%d in %s referencing missing %s", v.Count, v.Table, v.Parent))
+	}
+	if len(found) > 0 {
+		check.Status = CheckWarn
+		check.Detail = strings.Join(found, ", ")
+		check.Fix = fmt.Sprintf(`List them with: sqlite3 %s "PRAGMA foreign_key_check", then delete or repair the rows.`, dbPath)
+		return check
+	}
+
+	check.Detail = "none"
+	return check
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gowiki/internal/models"
+)
+
+// ErrNoDraft is returned when a page has no unpublished changes.
+var ErrNoDraft = errors.New("page has no unpublished changes")
+
+// GetDraft returns a page's unpublished changes, or ErrNoDraft.
+func (s *WikiService) GetDraft(ctx context.Context, pageID int64) (*models.PageDraft, error) {
+	draft, err := s.db.GetPageDraft(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if draft == nil {
+		return nil, ErrNoDraft
+	}
+	return draft, nil
+}
+
+// SaveDraft stores draft as the page's unpublished changes, replacing any
+// earlier draft. The live page is left as it is.
+func (s *WikiService) SaveDraft(ctx context.Context, authorID int64, draft *models.PageDraft) error {
+	page, err := s.GetPageByID(ctx, draft.PageID)
+	if err != nil {
+		return err
+	}
+	if page.IsArchived() {
+		return ErrPageArchived
+	}
+
+	draft.Title = strings.TrimSpace(draft.Title)
+	if draft.Title == "" {
+		return ErrInvalidTitle
+	}
+	if draft.Tags == nil {
+		draft.Tags = []string{}
+	}
+	draft.AuthorID = &authorID
+
+	if err := s.db.SavePageDraft(ctx, draft); err != nil {
+		return err
+	}
+	s.auditor.Log(ctx, &authorID, "draft_save", "page", &page.ID, map[string]interface{}{
+		"slug": page.Slug,
+	})
+	return nil
+}
+
+// PublishDraft makes a page's unpublished changes live, recording the
+// previous content as a revision, and removes the draft.
+func (s *WikiService) PublishDraft(ctx context.Context, pageID, authorID int64, comment string) (*UpdateResult, error) {
+	draft, err := s.GetDraft(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	if comment == "" {
+		comment = "Published draft"
+	}
+
+	result, oldSlug, err := s.updatePage(ctx, pageID, authorID, draft.Update(), comment)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.DeletePageDraft(ctx, pageID); err != nil {
+		return nil, fmt.Errorf("failed to remove published draft: %w", err)
+	}
+
+	details := map[string]interface{}{"slug": result.Page.Slug, "draft": true, "comment": comment}
+	if oldSlug != result.Page.Slug {
+		details["old_slug"] = oldSlug
+	}
+	s.auditor.Log(ctx, &authorID, "page_update", "page", &pageID, details)
+	return result, nil
+}
+
+// DiscardDraft throws away a page's unpublished changes.
+func (s *WikiService) DiscardDraft(ctx context.Context, pageID, userID int64) error {
+	existed, err := s.db.DeletePageDraft(ctx, pageID)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		return ErrNoDraft
+	}
+	s.auditor.Log(ctx, &userID, "draft_discard", "page", &pageID, nil)
+	return nil
+}
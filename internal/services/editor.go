@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrUnknownFormat is returned for an editor format action that doesn't
+// exist.
+var ErrUnknownFormat = errors.New("unknown format action")
+
+// Editor format actions, named as the edit form's toolbar buttons send
+// them.
+const (
+	FormatBold          = "bold"
+	FormatItalic        = "italic"
+	FormatStrikethrough = "strikethrough"
+	FormatCode          = "code"
+	FormatLink          = "link"
+	FormatWikiLink      = "wikilink"
+	FormatH1            = "h1"
+	FormatH2            = "h2"
+	FormatH3            = "h3"
+	FormatBullet        = "bullet"
+	FormatNumbered      = "numbered"
+	FormatTask          = "task"
+	FormatQuote         = "quote"
+	FormatCodeBlock     = "codeblock"
+	FormatTable         = "table"
+	FormatRule          = "hr"
+)
+
+// inlineFormats wrap the selection in a marker on each side.
+var inlineFormats = map[string][2]string{
+	FormatBold:          {"**", "**"},
+	FormatItalic:        {"_", "_"},
+	FormatStrikethrough: {"~~", "~~"},
+	FormatCode:          {"`", "`"},
+	FormatWikiLink:      {"[[", "]]"},
+}
+
+// lineFormats prefix every line the selection touches. Numbered lists
+// count up from the prefix's number.
+var lineFormats = map[string]string{
+	FormatH1:       "# ",
+	FormatH2:       "## ",
+	FormatH3:       "### ",
+	FormatBullet:   "- ",
+	FormatNumbered: "1. ",
+	FormatTask:     "- [ ] ",
+	FormatQuote:    "> ",
+}
+
+// FormatResult is markdown after a format action, with the text to select
+// next. Selection offsets count UTF-16 code units, as a browser textarea's
+// selectionStart and selectionEnd do.
+type FormatResult struct {
+	Content        string `json:"content"`
+	SelectionStart int    `json:"selection_start"`
+	SelectionEnd   int    `json:"selection_end"`
+}
+
+// FormatMarkdown applies an editor toolbar action to the selection between
+// start and end of content, both in UTF-16 code units. Applying a marker or
+// line prefix that is already there removes it again, so buttons toggle.
+func FormatMarkdown(content string, start, end int, action string) (FormatResult, error) {
+	units := len(utf16.Encode([]rune(content)))
+	start, end = clampSelection(start, end, units)
+	from, to := byteOffset(content, start), byteOffset(content, end)
+
+	var edited string
+	var selFrom, selTo int
+	if markers, ok := inlineFormats[action]; ok {
+		edited, selFrom, selTo = toggleInline(content, from, to, markers[0], markers[1])
+	} else if prefix, ok := lineFormats[action]; ok {
+		edited, selFrom, selTo = toggleLinePrefix(content, from, to, prefix)
+	} else {
+		switch action {
+		case FormatLink:
+			edited, selFrom, selTo = insertLink(content, from, to)
+		case FormatCodeBlock:
+			edited, selFrom, selTo = wrapCodeBlock(content, from, to)
+		case FormatTable:
+			edited, selFrom, selTo = insertBlock(content, to, "| Column | Column |\n| --- | --- |\n| Cell | Cell |", 2, 8)
+		case FormatRule:
+			edited, selFrom, selTo = insertBlock(content, to, "---", 3, 3)
+		default:
+			return FormatResult{}, ErrUnknownFormat
+		}
+	}
+
+	return FormatResult{
+		Content:        edited,
+		SelectionStart: utf16Offset(edited, selFrom),
+		SelectionEnd:   utf16Offset(edited, selTo),
+	}, nil
+}
+
+// toggleInline wraps content[from:to] in prefix and suffix, or unwraps it
+// when the markers already surround it. It returns the new content and
+// the byte range of the selected text in it.
+func toggleInline(content string, from, to int, prefix, suffix string) (string, int, int) {
+	before, selected, after := content[:from], content[from:to], content[to:]
+	if strings.HasSuffix(before, prefix) && strings.HasPrefix(after, suffix) {
+		before = before[:len(before)-len(prefix)]
+		after = after[len(suffix):]
+		return before + selected + after, len(before), len(before) + len(selected)
+	}
+	if len(selected) >= len(prefix)+len(suffix) && strings.HasPrefix(selected, prefix) && strings.HasSuffix(selected, suffix) {
+		selected = selected[len(prefix) : len(selected)-len(suffix)]
+		return before + selected + after, len(before), len(before) + len(selected)
+	}
+	start := len(before) + len(prefix)
+	return before + prefix + selected + suffix + after, start, start + len(selected)
+}
+
+// toggleLinePrefix adds prefix to every line touched by content[from:to],
+// or removes it when all of those lines already have it. Headings replace
+// a heading of another level.
+func toggleLinePrefix(content string, from, to int, prefix string) (string, int, int) {
+	lineStart := strings.LastIndexByte(content[:from], '\n') + 1
+	lineEnd := len(content)
+	if to > from && content[to-1] == '\n' {
+		// A selection ending at a line break doesn't take in the next line
+		to--
+	}
+	if i := strings.IndexByte(content[to:], '\n'); i >= 0 {
+		lineEnd = to + i
+	}
+
+	lines := strings.Split(content[lineStart:lineEnd], "\n")
+	remove := true
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, linePrefix(prefix, i)) {
+			remove = false
+			break
+		}
+	}
+
+	heading := strings.HasPrefix(prefix, "#")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" && len(lines) > 1 {
+			continue
+		}
+		p := linePrefix(prefix, i)
+		switch {
+		case remove:
+			lines[i] = strings.TrimPrefix(line, p)
+		case heading:
+			lines[i] = p + strings.TrimLeft(strings.TrimLeft(line, "#"), " ")
+		default:
+			lines[i] = p + line
+		}
+	}
+
+	block := strings.Join(lines, "\n")
+	return content[:lineStart] + block + content[lineEnd:], lineStart, lineStart + len(block)
+}
+
+// linePrefix returns prefix for line i of a selection, counting numbered
+// list items up.
+func linePrefix(prefix string, i int) string {
+	if prefix == lineFormats[FormatNumbered] {
+		return strconv.Itoa(i+1) + ". "
+	}
+	return prefix
+}
+
+// insertLink makes content[from:to] the text of a markdown link and
+// selects its URL, or selects the empty text when nothing was selected.
+func insertLink(content string, from, to int) (string, int, int) {
+	before, selected, after := content[:from], content[from:to], content[to:]
+	link := "[" + selected + "](url)"
+	if selected == "" {
+		return before + link + after, len(before) + 1, len(before) + 1
+	}
+	urlStart := len(before) + len(link) - len("url)")
+	return before + link + after, urlStart, urlStart + len("url")
+}
+
+// wrapCodeBlock fences content[from:to] as a code block on lines of its
+// own, leaving the code selected.
+func wrapCodeBlock(content string, from, to int) (string, int, int) {
+	before, selected, after := content[:from], strings.TrimSuffix(content[from:to], "\n"), content[to:]
+	open, close := "```\n", "\n```"
+	if before != "" && !strings.HasSuffix(before, "\n") {
+		open = "\n" + open
+	}
+	if after != "" && !strings.HasPrefix(after, "\n") {
+		close += "\n"
+	}
+	start := len(before) + len(open)
+	return before + open + selected + close + after, start, start + len(selected)
+}
+
+// insertBlock inserts block after at as a paragraph of its own, selecting
+// block[selFrom:selTo].
+func insertBlock(content string, at int, block string, selFrom, selTo int) (string, int, int) {
+	before, after := content[:at], content[at:]
+	lead, trail := "", ""
+	switch {
+	case before == "", strings.HasSuffix(before, "\n\n"):
+	case strings.HasSuffix(before, "\n"):
+		lead = "\n"
+	default:
+		lead = "\n\n"
+	}
+	switch {
+	case after == "", strings.HasPrefix(after, "\n\n"):
+	case strings.HasPrefix(after, "\n"):
+		trail = "\n"
+	default:
+		trail = "\n\n"
+	}
+	start := len(before) + len(lead)
+	return before + lead + block + trail + after, start + selFrom, start + selTo
+}
+
+// WikiLinkMarkdown returns a [[wiki-link]] to the page, naming it by title
+// when the title leads to the slug and by slug otherwise.
+func WikiLinkMarkdown(slug, title string) string {
+	if strings.ContainsAny(title, "[]") {
+		return "[[" + slug + "]]"
+	}
+	text := strings.NewReplacer(`\`, `\\`, `|`, `\|`, `#`, `\#`).Replace(title)
+	if slugify(title) == slug {
+		return "[[" + text + "]]"
+	}
+	return "[[" + slug + "|" + text + "]]"
+}
+
+// ImageMarkdown returns markdown showing the image at url with alt text.
+func ImageMarkdown(alt, url string) string {
+	alt = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`).Replace(alt)
+	return "![" + alt + "](" + url + ")"
+}
+
+// clampSelection keeps a selection within text of n UTF-16 code units,
+// with start before end.
+func clampSelection(start, end, n int) (int, int) {
+	start = min(max(start, 0), n)
+	end = min(max(end, 0), n)
+	if end < start {
+		start, end = end, start
+	}
+	return start, end
+}
+
+// byteOffset converts an offset in UTF-16 code units into a byte offset in
+// s. An offset inside a surrogate pair rounds down to the character.
+func byteOffset(s string, units int) int {
+	n := 0
+	for i, r := range s {
+		w := utf16.RuneLen(r)
+		if w < 0 {
+			w = 1
+		}
+		if n+w > units {
+			return i
+		}
+		n += w
+	}
+	return len(s)
+}
+
+// utf16Offset converts a byte offset in s into UTF-16 code units.
+func utf16Offset(s string, bytes int) int {
+	n := 0
+	for _, r := range s[:bytes] {
+		w := utf16.RuneLen(r)
+		if w < 0 {
+			w = 1
+		}
+		n += w
+	}
+	return n
+}
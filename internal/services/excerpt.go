@@ -0,0 +1,148 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	emojiast "github.com/yuin/goldmark-emoji/ast"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// ExcerptLength is the most characters an excerpt keeps before "...".
+	ExcerptLength = 150
+	// excerptMinBreak is how far into the excerpt a word break must be for
+	// truncation to prefer it over cutting mid-word.
+	excerptMinBreak = 100
+)
+
+// Excerpt returns the opening prose of a page as plain text for page lists
+// and cards. Headings, code blocks, images, HTML, diagrams and math are
+// left out, and the text is truncated to ExcerptLength characters at a word
+// boundary where possible.
+func (s *MarkdownService) Excerpt(markdown string) string {
+	source := []byte(markdown)
+	doc := s.md.Parser().Parse(text.NewReader(source))
+
+	var b strings.Builder
+	// space is set when the next text needs a separator, e.g. after a soft
+	// line break or between blocks.
+	space := false
+	write := func(str string) {
+		if str == "" {
+			return
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(str)
+	}
+
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		// Collecting a little more than needed tells truncate to add "..."
+		if b.Len() > ExcerptLength*4 {
+			return ast.WalkStop, nil
+		}
+		if node.Type() == ast.TypeBlock {
+			space = true
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := node.(type) {
+		case *ast.Heading, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock,
+			*ast.RawHTML, *ast.Image, *ast.ThematicBreak,
+			*east.FootnoteLink, *east.FootnoteList,
+			*diagramNode, *mathNode, *mathBlock, *tocMacro, *includeMacro:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			write(string(n.Segment.Value(source)))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				space = true
+			}
+		case *ast.String:
+			write(string(n.Value))
+		case *emojiast.Emoji:
+			if n.Value != nil {
+				write(string(n.Value.Unicode))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return truncateExcerpt(strings.Join(strings.Fields(b.String()), " "))
+}
+
+// truncateExcerpt shortens text to ExcerptLength characters, preferring to
+// cut at a word break, and marks the cut with "...".
+func truncateExcerpt(s string) string {
+	runes := []rune(s)
+	if len(runes) <= ExcerptLength {
+		return s
+	}
+
+	cut := runes[:ExcerptLength]
+	for i := len(cut) - 1; i >= excerptMinBreak; i-- {
+		if unicode.IsSpace(cut[i]) {
+			cut = cut[:i]
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(cut), unicode.IsSpace) + "..."
+}
+
+// WordCount returns how many words of prose a page has, for reading time
+// estimates. Headings count, but code blocks, HTML, diagrams and math are
+// left out as in Excerpt.
+func (s *MarkdownService) WordCount(markdown string) int {
+	source := []byte(markdown)
+	doc := s.md.Parser().Parse(text.NewReader(source))
+
+	words := 0
+	// joined is set while a word may continue from the previous text node,
+	// e.g. across emphasis in "un*believ*able".
+	joined := false
+	count := func(str string) {
+		if str == "" {
+			return
+		}
+		first, _ := utf8.DecodeRuneInString(str)
+		last, _ := utf8.DecodeLastRuneInString(str)
+		n := len(strings.Fields(str))
+		if joined && n > 0 && !unicode.IsSpace(first) {
+			n--
+		}
+		words += n
+		joined = !unicode.IsSpace(last)
+	}
+
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if node.Type() == ast.TypeBlock {
+			joined = false
+		}
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := node.(type) {
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock,
+			*ast.RawHTML, *ast.Image, *east.FootnoteLink,
+			*diagramNode, *mathNode, *mathBlock, *tocMacro, *includeMacro:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			count(string(n.Segment.Value(source)))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				joined = false
+			}
+		case *ast.String:
+			count(string(n.Value))
+		}
+		return ast.WalkContinue, nil
+	})
+	return words
+}
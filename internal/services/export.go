@@ -0,0 +1,218 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+// Export formats.
+const (
+	ExportMarkdown = "md"
+	ExportHTML     = "html"
+	ExportZip      = "zip"
+)
+
+// ErrInvalidExportFormat is returned for unknown export formats.
+var ErrInvalidExportFormat = errors.New("export format must be md, html or zip")
+
+// rootRelativeLink matches root-relative links in rendered page HTML.
+var rootRelativeLink = regexp.MustCompile(`(src|href)="/([^/"][^"]*)"`)
+
+// ExportFile is a downloadable page export.
+type ExportFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ExportOptions controls how a page is exported.
+type ExportOptions struct {
+	Format string
+	// BaseURL makes links in standalone HTML resolve outside the wiki.
+	BaseURL  string
+	SiteName string
+	// CanView decides which descendants subtree exports include, and which
+	// {{include:...}} pages HTML exports inline.
+	CanView func(*models.Page) bool
+}
+
+// ExportPage exports a page as markdown with frontmatter, as a standalone
+// HTML document, or together with its descendants as a zip of markdown files.
+// The zip uses the same layout as markdown backups so it can be restored.
+// Markdown keeps {{include:...}} macros as written, while standalone HTML
+// has the included pages inlined, since it can't load them later.
+func (s *WikiService) ExportPage(ctx context.Context, page *models.Page, opts ExportOptions) (*ExportFile, error) {
+	name := exportName(page.Slug)
+
+	switch opts.Format {
+	case ExportMarkdown, "":
+		return &ExportFile{
+			Filename:    name + ".md",
+			ContentType: "text/markdown; charset=utf-8",
+			Data:        []byte(PageFrontmatter(page, exportAuthor(page)) + page.Content),
+		}, nil
+
+	case ExportHTML:
+		data, err := standaloneHTML(page, s.ExpandIncludes(ctx, page, opts.CanView), opts)
+		if err != nil {
+			return nil, err
+		}
+		return &ExportFile{
+			Filename:    name + ".html",
+			ContentType: "text/html; charset=utf-8",
+			Data:        data,
+		}, nil
+
+	case ExportZip:
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		if err := s.writeExportTree(ctx, zw, page, "", opts.CanView); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to finish export archive: %w", err)
+		}
+		return &ExportFile{
+			Filename:    name + ".zip",
+			ContentType: "application/zip",
+			Data:        buf.Bytes(),
+		}, nil
+	}
+
+	return nil, ErrInvalidExportFormat
+}
+
+// writeExportTree adds a page to the archive and recurses into its children,
+// placing them in a folder named after the parent.
+func (s *WikiService) writeExportTree(ctx context.Context, zw *zip.Writer, page *models.Page, dir string, canView func(*models.Page) bool) error {
+	name := exportName(page.Slug)
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     path.Join(dir, name+".md"),
+		Method:   zip.Deflate,
+		Modified: page.UpdatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add page to export archive: %w", err)
+	}
+	if _, err := w.Write([]byte(PageFrontmatter(page, exportAuthor(page)) + page.Content)); err != nil {
+		return fmt.Errorf("failed to add page to export archive: %w", err)
+	}
+
+	children, err := s.db.GetPageChildren(ctx, page.ID, false, nil)
+	if err != nil {
+		return err
+	}
+	for _, summary := range children {
+		child, err := s.db.GetPageByID(ctx, summary.ID)
+		if err != nil {
+			return err
+		}
+		if child == nil || !canView(child) {
+			continue
+		}
+		if err := s.writeExportTree(ctx, zw, child, path.Join(dir, name), canView); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// exportName is the last slug segment, safe for use as a filename.
+func exportName(slug string) string {
+	parts := strings.Split(slug, "/")
+	return sanitizeFilename(parts[len(parts)-1])
+}
+
+func exportAuthor(page *models.Page) string {
+	if page.Author != nil {
+		return page.Author.Username
+	}
+	return ""
+}
+
+// standaloneHTML renders a self-contained document with inlined styles
+// around content, the page's HTML with its includes expanded.
+func standaloneHTML(page *models.Page, content string, opts ExportOptions) ([]byte, error) {
+	if base := strings.TrimRight(opts.BaseURL, "/"); base != "" {
+		content = rootRelativeLink.ReplaceAllString(content, `$1="`+base+`/$2"`)
+	}
+
+	var tags []string
+	for _, tag := range page.Tags {
+		tags = append(tags, tag.Name)
+	}
+
+	var buf bytes.Buffer
+	err := standaloneTemplate.Execute(&buf, map[string]interface{}{
+		"Title":    page.Title,
+		"SiteName": opts.SiteName,
+		"Author":   exportAuthor(page),
+		"Updated":  page.UpdatedAt.UTC().Format(time.RFC1123),
+		"Tags":     tags,
+		"Source":   strings.TrimRight(opts.BaseURL, "/") + "/wiki/" + page.Slug,
+		"Styles":   template.CSS(standaloneStyles),
+		// Page HTML is produced by the sanitizing markdown renderer
+		"Content": template.HTML(content),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var standaloneTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="{{.SiteName}}">
+<title>{{.Title}}</title>
+<style>{{.Styles}}</style>
+</head>
+<body>
+<article>
+<header>
+<h1>{{.Title}}</h1>
+<p class="meta">{{if .Author}}{{.Author}} &middot; {{end}}Updated {{.Updated}}{{range .Tags}} <span class="tag">{{.}}</span>{{end}}</p>
+</header>
+{{.Content}}
+<footer>Exported from <a href="{{.Source}}">{{.SiteName}}</a></footer>
+</article>
+</body>
+</html>
+`))
+
+const standaloneStyles = `
+*,*::before,*::after{box-sizing:border-box}
+body{margin:0;background:#fff;color:#1f2937;font:16px/1.7 -apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,Helvetica,Arial,sans-serif}
+article{max-width:48rem;margin:0 auto;padding:2.5rem 1.5rem}
+header{border-bottom:1px solid #e5e7eb;margin-bottom:2rem;padding-bottom:1rem}
+header h1{margin:0 0 .5rem;font-size:2.25rem;line-height:1.2}
+.meta{margin:0;color:#6b7280;font-size:.875rem}
+.tag{display:inline-block;margin-left:.25rem;padding:0 .5rem;border-radius:9999px;background:#eef2ff;color:#4338ca;font-size:.75rem}
+h1,h2,h3,h4,h5,h6{line-height:1.3;margin:2rem 0 .75rem}
+a{color:#4f46e5}
+img{max-width:100%;height:auto}
+code{font-family:ui-monospace,SFMono-Regular,Menlo,Consolas,monospace;font-size:.875em;background:#f3f4f6;padding:.125rem .375rem;border-radius:.25rem}
+pre{background:#111827;color:#f9fafb;padding:1rem;border-radius:.5rem;overflow-x:auto;line-height:1.5}
+pre code{background:none;padding:0;color:inherit}
+blockquote{margin:1.5rem 0;padding:.25rem 1rem;border-left:4px solid #c7d2fe;color:#4b5563}
+table{border-collapse:collapse;width:100%;margin:1.5rem 0}
+th,td{border:1px solid #e5e7eb;padding:.5rem .75rem;text-align:left}
+th{background:#f9fafb}
+hr{border:0;border-top:1px solid #e5e7eb;margin:2rem 0}
+footer{margin-top:3rem;padding-top:1rem;border-top:1px solid #e5e7eb;color:#9ca3af;font-size:.75rem}
+@media print{article{padding:0}a{color:inherit}footer{display:none}}
+`
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+const (
+	// pageViewFlushInterval is how often buffered page views are written out.
+	pageViewFlushInterval = 30 * time.Second
+	// freshnessWindow is how far back edits count towards a page's freshness.
+	freshnessWindow = 30 * 24 * time.Hour
+	// staleAfter is how long an unedited page takes to be treated as archival.
+	staleAfter = 180 * 24 * time.Hour
+)
+
+// Freshness tells crawlers and caches how often a page is likely to change
+// and how much it matters relative to the rest of the wiki.
+type Freshness struct {
+	ChangeFreq string        // sitemap changefreq
+	Priority   float64       // sitemap priority, 0.0 to 1.0
+	MaxAge     time.Duration // Cache-Control max-age for anonymous readers
+}
+
+// PageFreshness derives a page's freshness from its activity. Pages edited
+// often get short cache lifetimes and frequent crawls; pages that haven't
+// changed in months, and archived pages, are cached for a day. Priority
+// grows with views relative to maxViews, the most-viewed page, and with
+// recent edits.
+func PageFreshness(a models.PageActivity, maxViews int64, now time.Time) Freshness {
+	var f Freshness
+	switch {
+	case a.Archived:
+		f = Freshness{ChangeFreq: "yearly", MaxAge: 24 * time.Hour}
+	case a.RecentEdits >= 8:
+		f = Freshness{ChangeFreq: "daily", MaxAge: time.Minute}
+	case a.RecentEdits >= 2:
+		f = Freshness{ChangeFreq: "weekly", MaxAge: 5 * time.Minute}
+	case now.Sub(a.UpdatedAt) < staleAfter:
+		f = Freshness{ChangeFreq: "monthly", MaxAge: time.Hour}
+	default:
+		f = Freshness{ChangeFreq: "yearly", MaxAge: 24 * time.Hour}
+	}
+
+	priority := 0.3
+	if maxViews > 0 && a.Views > 0 {
+		priority += 0.5 * math.Log1p(float64(a.Views)) / math.Log1p(float64(maxViews))
+	}
+	if a.RecentEdits > 0 {
+		priority += 0.2
+	}
+	if a.Archived {
+		priority /= 3
+	}
+	f.Priority = math.Round(math.Min(priority, 1)*10) / 10
+	if f.Priority < 0.1 {
+		f.Priority = 0.1
+	}
+	return f
+}
+
+// FreshnessService counts page views and reports page freshness. Views are
+// buffered in memory and flushed in batches to keep page loads cheap.
+type FreshnessService struct {
+	db *database.DB
+
+	mu     sync.Mutex
+	buffer map[int64]int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFreshnessService creates a freshness service.
+func NewFreshnessService(db *database.DB) *FreshnessService {
+	return &FreshnessService{db: db, buffer: make(map[int64]int64)}
+}
+
+// RecordView buffers a view of a page.
+func (s *FreshnessService) RecordView(pageID int64) {
+	s.mu.Lock()
+	s.buffer[pageID]++
+	s.mu.Unlock()
+}
+
+// Flush writes buffered views to the database.
+func (s *FreshnessService) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	views := s.buffer
+	s.buffer = make(map[int64]int64)
+	s.mu.Unlock()
+
+	if len(views) == 0 {
+		return nil
+	}
+	return s.db.RecordPageViews(ctx, views, time.Now())
+}
+
+// Page returns the freshness of one published page, or nil if the page
+// isn't published. Its priority is left unset, since that is relative to
+// the rest of the wiki.
+func (s *FreshnessService) Page(ctx context.Context, pageID int64) (*Freshness, error) {
+	now := time.Now()
+	activity, err := s.db.GetPageActivity(ctx, pageID, now.Add(-freshnessWindow))
+	if err != nil || activity == nil {
+		return nil, err
+	}
+	f := PageFreshness(*activity, 0, now)
+	f.Priority = 0
+	return &f, nil
+}
+
+// PageFreshnessEntry pairs a page's activity with its derived freshness.
+type PageFreshnessEntry struct {
+	models.PageActivity
+	Freshness
+}
+
+// All returns the freshness of every published page, ordered by slug.
+func (s *FreshnessService) All(ctx context.Context) ([]PageFreshnessEntry, error) {
+	now := time.Now()
+	activity, err := s.db.ListPageActivity(ctx, now.Add(-freshnessWindow))
+	if err != nil {
+		return nil, err
+	}
+
+	var maxViews int64
+	for _, a := range activity {
+		if a.Views > maxViews {
+			maxViews = a.Views
+		}
+	}
+
+	entries := make([]PageFreshnessEntry, len(activity))
+	for i, a := range activity {
+		entries[i] = PageFreshnessEntry{PageActivity: a, Freshness: PageFreshness(a, maxViews, now)}
+	}
+	return entries, nil
+}
+
+// Start flushes buffered views in the background.
+func (s *FreshnessService) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(pageViewFlushInterval)
+		defer ticker.Stop()
+
+		lastPrune := time.Time{}
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				if err := s.Flush(context.Background()); err != nil {
+					fmt.Printf("Warning: failed to flush page views: %v\n", err)
+				}
+				return
+			}
+
+			if err := s.Flush(context.Background()); err != nil {
+				fmt.Printf("Warning: failed to flush page views: %v\n", err)
+			}
+			if time.Since(lastPrune) > 24*time.Hour {
+				if err := s.db.DeletePageViewDaysBefore(context.Background(), time.Now().Add(-pageViewDayRetention)); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+				lastPrune = time.Now()
+			}
+		}
+	}()
+}
+
+// Stop flushes remaining views and halts the background loop.
+func (s *FreshnessService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
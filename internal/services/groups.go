@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+var (
+	ErrGroupNotFound = errors.New("group not found")
+	ErrGroupExists   = errors.New("a group with that name already exists")
+	ErrGroupName     = errors.New("group names are 1-64 characters")
+)
+
+const maxGroupNameLength = 64
+
+// GroupService manages user groups, their members and the pages
+// restricted to them. Memberships are read from the database on every
+// request, so changes apply immediately on every node.
+type GroupService struct {
+	db *database.DB
+	mu sync.Mutex
+}
+
+// NewGroupService creates a group service.
+func NewGroupService(db *database.DB) *GroupService {
+	return &GroupService{db: db}
+}
+
+// List returns every group with its member count.
+func (s *GroupService) List(ctx context.Context) ([]models.Group, error) {
+	return s.db.ListGroups(ctx)
+}
+
+// Get returns a group by ID.
+func (s *GroupService) Get(ctx context.Context, id int64) (*models.Group, error) {
+	group, err := s.db.GetGroup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, ErrGroupNotFound
+	}
+	return group, nil
+}
+
+// Create validates and adds a group.
+func (s *GroupService) Create(ctx context.Context, name, description string) (*models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.create(ctx, name, description)
+}
+
+func (s *GroupService) create(ctx context.Context, name, description string) (*models.Group, error) {
+	name, err := s.checkName(ctx, name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.Group{Name: name, Description: strings.TrimSpace(description)}
+	if err := s.db.CreateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Ensure returns the group with the given name, creating it if it doesn't
+// exist. User imports use it to create the groups their files name.
+func (s *GroupService) Ensure(ctx context.Context, name string) (*models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, err := s.db.GetGroupByName(ctx, strings.TrimSpace(name))
+	if err != nil || group != nil {
+		return group, err
+	}
+	return s.create(ctx, name, "")
+}
+
+// Update renames a group and replaces its description.
+func (s *GroupService) Update(ctx context.Context, id int64, name, description string) (*models.Group, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	group, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group.Name, err = s.checkName(ctx, name, id); err != nil {
+		return nil, err
+	}
+	group.Description = strings.TrimSpace(description)
+
+	if err := s.db.UpdateGroup(ctx, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Delete removes a group. Pages restricted only to it become visible to
+// everyone, and links shared with it are deleted.
+func (s *GroupService) Delete(ctx context.Context, id int64) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.db.DeleteGroup(ctx, id)
+}
+
+// Members returns a group's members.
+func (s *GroupService) Members(ctx context.Context, id int64) ([]models.GroupMember, error) {
+	return s.db.ListGroupMembers(ctx, id)
+}
+
+// AddMember adds the user with the given username to a group.
+func (s *GroupService) AddMember(ctx context.Context, id int64, username string) (*models.User, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if err := s.db.AddGroupMember(ctx, id, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RemoveMember removes a user from a group.
+func (s *GroupService) RemoveMember(ctx context.Context, id, userID int64) error {
+	return s.db.RemoveGroupMember(ctx, id, userID)
+}
+
+// UserGroups returns the groups a user belongs to.
+func (s *GroupService) UserGroups(ctx context.Context, userID int64) ([]models.Group, error) {
+	return s.db.ListUserGroups(ctx, userID)
+}
+
+// SetPageGroups restricts a page to the given groups, or lifts the
+// restriction when there are none. Unknown groups are ignored.
+func (s *GroupService) SetPageGroups(ctx context.Context, pageID int64, groupIDs []int64) ([]models.Group, error) {
+	groups, err := s.db.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	requested := make(map[int64]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		requested[id] = true
+	}
+
+	var selected []models.Group
+	var ids []int64
+	for _, g := range groups {
+		if requested[g.ID] {
+			selected = append(selected, g)
+			ids = append(ids, g.ID)
+		}
+	}
+
+	if err := s.db.SetPageGroups(ctx, pageID, ids); err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
+
+// checkName trims and validates a group name, checking that no group other
+// than the one with the given ID has it.
+func (s *GroupService) checkName(ctx context.Context, name string, id int64) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxGroupNameLength {
+		return "", ErrGroupName
+	}
+
+	existing, err := s.db.GetGroupByName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil && existing.ID != id {
+		return "", ErrGroupExists
+	}
+	return name, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// ErrInviteNotFound is returned for unknown, used or expired invitations.
+var ErrInviteNotFound = errors.New("invitation is invalid or has expired")
+
+// inviteTTL is how long an invitation link stays valid.
+const inviteTTL = 7 * 24 * time.Hour
+
+// InviteService emails users a link to choose their own password.
+type InviteService struct {
+	db      *database.DB
+	auth    *AuthService
+	mail    *MailService
+	siteURL string
+}
+
+// NewInviteService creates a new InviteService.
+func NewInviteService(db *database.DB, cfg *config.Config, auth *AuthService, mail *MailService) *InviteService {
+	return &InviteService{
+		db:      db,
+		auth:    auth,
+		mail:    mail,
+		siteURL: strings.TrimRight(cfg.Site.URL, "/"),
+	}
+}
+
+// Enabled reports whether invitations can be sent.
+func (s *InviteService) Enabled() bool {
+	return s.mail.Enabled()
+}
+
+// Invite creates an invitation for user and emails it. Earlier invitations
+// for the same user stop working.
+func (s *InviteService) Invite(ctx context.Context, user *models.User, inviter *models.User) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+
+	if err := s.db.DeleteExpiredUserInvites(ctx); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if err := s.db.DeleteUserInvites(ctx, user.ID); err != nil {
+		return err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	var invitedBy *int64
+	inviterName := "An administrator"
+	if inviter != nil {
+		invitedBy = &inviter.ID
+		inviterName = inviter.Username
+	}
+
+	if err := s.db.CreateUserInvite(ctx, hashInviteToken(token), user.ID, invitedBy, time.Now().UTC().Add(inviteTTL)); err != nil {
+		return err
+	}
+
+	err := s.mail.SendTemplate(ctx, models.EmailInvite, "", user.Email, map[string]string{
+		"Username":  user.Username,
+		"InvitedBy": inviterName,
+		"Link":      s.siteURL + "/invite/" + token,
+		"ExpiresIn": "7 days",
+	})
+	if err != nil {
+		_ = s.db.DeleteUserInvites(ctx, user.ID)
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the pending invitation for token.
+func (s *InviteService) Lookup(ctx context.Context, token string) (*models.UserInvite, error) {
+	invite, err := s.db.GetUserInvite(ctx, hashInviteToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInviteNotFound
+	}
+	return invite, nil
+}
+
+// Accept sets the invited user's password and uses up the invitation.
+func (s *InviteService) Accept(ctx context.Context, token, password string) (*models.UserInvite, error) {
+	invite, err := s.Lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.UpdateUser(ctx, invite.UserID, &models.UserUpdate{Password: &password}); err != nil {
+		return nil, err
+	}
+	if err := s.db.DeleteUserInvites(ctx, invite.UserID); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	return invite, nil
+}
+
+func hashInviteToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"gowiki/internal/config"
+	"gowiki/internal/models"
+)
+
+// Directory sign-in errors that let local accounts be tried instead.
+var (
+	errDirectoryUserNotFound = errors.New("user not found in directory")
+	errDirectoryUnavailable  = errors.New("directory unavailable")
+)
+
+// ErrDirectoryEmailMissing is returned when a directory user signing in for
+// the first time has no email address to give their account.
+var ErrDirectoryEmailMissing = errors.New("directory entry has no email address")
+
+// directoryUser is a user's entry in the LDAP directory.
+type directoryUser struct {
+	DN       string
+	Username string
+	Email    string
+	Groups   []string // Common names of the user's groups
+}
+
+// ldapDirectory checks credentials against an LDAP or Active Directory
+// server: it finds the user's entry, with the service account if one is
+// configured, then binds as the user with their password.
+type ldapDirectory struct {
+	cfg config.LDAPConfig
+}
+
+// newLDAPDirectory returns the configured directory, or nil when LDAP is
+// disabled.
+func newLDAPDirectory(cfg config.LDAPConfig) *ldapDirectory {
+	if cfg.URL == "" {
+		return nil
+	}
+	return &ldapDirectory{cfg: cfg}
+}
+
+// authenticate checks username and password against the directory. It
+// returns errDirectoryUserNotFound when no single entry matches and an
+// error wrapping errDirectoryUnavailable when the server can't be used.
+func (d *ldapDirectory) authenticate(username, password string) (*directoryUser, error) {
+	// An empty password would be an unauthenticated bind, which succeeds
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if d.cfg.BindDN != "" {
+		if err := conn.Bind(d.cfg.BindDN, d.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("%w: service account bind failed: %v", errDirectoryUnavailable, err)
+		}
+	}
+
+	attributes := []string{d.cfg.UsernameAttr, d.cfg.EmailAttr}
+	if d.cfg.GroupAttr != "" {
+		attributes = append(attributes, d.cfg.GroupAttr)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		d.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(d.cfg.Timeout.Seconds()), false,
+		strings.ReplaceAll(d.cfg.UserFilter, "{username}", ldap.EscapeFilter(username)),
+		attributes, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("%w: search failed: %v", errDirectoryUnavailable, err)
+	}
+	// Two matches mean the filter is too loose to tell who is signing in
+	if result == nil || len(result.Entries) != 1 {
+		return nil, errDirectoryUserNotFound
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("%w: user bind failed: %v", errDirectoryUnavailable, err)
+	}
+
+	user := &directoryUser{
+		DN:       entry.DN,
+		Username: strings.TrimSpace(entry.GetAttributeValue(d.cfg.UsernameAttr)),
+		Email:    strings.ToLower(strings.TrimSpace(entry.GetAttributeValue(d.cfg.EmailAttr))),
+	}
+	if user.Username == "" {
+		user.Username = username
+	}
+	if d.cfg.GroupAttr != "" {
+		for _, group := range entry.GetAttributeValues(d.cfg.GroupAttr) {
+			user.Groups = append(user.Groups, groupCommonName(group))
+		}
+	}
+	return user, nil
+}
+
+// dial connects to the directory server, upgrading to TLS if configured.
+func (d *ldapDirectory) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: d.cfg.SkipVerify}
+	conn, err := ldap.DialURL(d.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: d.cfg.Timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errDirectoryUnavailable, err)
+	}
+	conn.SetTimeout(d.cfg.Timeout)
+
+	if d.cfg.StartTLS {
+		if u, err := url.Parse(d.cfg.URL); err == nil {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: StartTLS failed: %v", errDirectoryUnavailable, err)
+		}
+	}
+	return conn, nil
+}
+
+// role returns the role for a directory user in groups: that of the first
+// group mapping that matches, else the configured default, which may be
+// empty.
+func (d *ldapDirectory) role(groups []string) models.Role {
+	for _, mapping := range d.cfg.GroupRoles {
+		group, role, err := config.ParseLDAPGroupRole(mapping)
+		if err != nil {
+			continue
+		}
+		for _, g := range groups {
+			if strings.EqualFold(g, group) {
+				return models.Role(role)
+			}
+		}
+	}
+	return models.Role(d.cfg.DefaultRole)
+}
+
+// groupCommonName returns the cn of a group DN such as
+// cn=wiki-admins,ou=groups,dc=example,dc=com. Values that aren't DNs, like
+// plain group names, are returned as they are.
+func groupCommonName(group string) string {
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 {
+		return group
+	}
+	for _, attr := range dn.RDNs[0].Attributes {
+		if strings.EqualFold(attr.Type, "cn") {
+			return attr.Value
+		}
+	}
+	return group
+}
+
+// authenticateDirectory signs a user in with their directory credentials.
+// Users signing in for the first time get a local account, and returning
+// users have their email and role brought in line with the directory.
+// Local accounts are never taken over: when one has the directory user's
+// username, it is left to sign in with its own password. A failed bind
+// only fails the sign-in for directory accounts; anyone else is tried
+// against the local accounts.
+func (s *AuthService) authenticateDirectory(ctx context.Context, username, password string) (*models.User, error) {
+	entry, err := s.directory.authenticate(username, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		user, err := s.db.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, fmt.Errorf("authentication error: %w", err)
+		}
+		if user == nil || !user.IsDirectoryAccount() {
+			return nil, errDirectoryUserNotFound
+		}
+		// Return the account so the failure is audited
+		return user, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.db.GetUserByUsername(ctx, entry.Username)
+	if err != nil {
+		return nil, fmt.Errorf("authentication error: %w", err)
+	}
+	if user == nil {
+		return s.provisionDirectoryUser(ctx, entry)
+	}
+	if !user.IsDirectoryAccount() {
+		fmt.Printf("Warning: directory user %s matches local account %s, which directory sign-in won't take over\n", entry.DN, user.Username)
+		return nil, errDirectoryUserNotFound
+	}
+
+	if !user.IsActive {
+		return user, ErrUserInactive
+	}
+	if user.IsLocked() {
+		return user, ErrUserLocked
+	}
+
+	s.syncDirectoryUser(ctx, user, entry)
+	if err := s.db.UpdateUserLastLogin(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to update last login: %v\n", err)
+	}
+	return user, nil
+}
+
+// provisionDirectoryUser creates the local account of a directory user
+// signing in for the first time. Its password is random: the user keeps
+// signing in with their directory password. Accounts need an email
+// address, so entries without one are refused.
+func (s *AuthService) provisionDirectoryUser(ctx context.Context, entry *directoryUser) (*models.User, error) {
+	if entry.Email == "" {
+		fmt.Printf("Warning: directory user %s has no %s attribute to provision an account with\n", entry.DN, s.directory.cfg.EmailAttr)
+		return nil, ErrDirectoryEmailMissing
+	}
+
+	password, err := GeneratePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.CreateUser(ctx, models.UserCreate{
+		Username:    entry.Username,
+		Email:       entry.Email,
+		Password:    password,
+		Role:        s.directory.role(entry.Groups),
+		DirectoryDN: entry.DN,
+	})
+	if err != nil {
+		fmt.Printf("Warning: failed to create account for directory user %s: %v\n", entry.DN, err)
+		return nil, fmt.Errorf("failed to provision directory user: %w", err)
+	}
+
+	if err := s.db.UpdateUserLastLogin(ctx, user.ID); err != nil {
+		fmt.Printf("Warning: failed to update last login: %v\n", err)
+	}
+	s.auditor.Log(ctx, &user.ID, "user_provisioned", "user", &user.ID, map[string]interface{}{
+		"username": user.Username,
+		"dn":       entry.DN,
+		"role":     user.Role,
+	})
+	return user, nil
+}
+
+// syncDirectoryUser updates a returning directory user's email, role and
+// DN from the directory. Roles only change when a group mapping or default
+// role names a valid one.
+func (s *AuthService) syncDirectoryUser(ctx context.Context, user *models.User, entry *directoryUser) {
+	update := &models.UserUpdate{}
+	changed := false
+
+	if entry.Email != "" && entry.Email != user.Email {
+		if existing, _ := s.db.GetUserByEmail(ctx, entry.Email); existing == nil {
+			update.Email = &entry.Email
+			changed = true
+		}
+	}
+	if role := s.directory.role(entry.Groups); role != "" && role != user.Role && role.IsValid() {
+		update.Role = &role
+		changed = true
+	}
+	if entry.DN != user.DirectoryDN {
+		update.DirectoryDN = &entry.DN
+		changed = true
+	}
+	if !changed {
+		return
+	}
+
+	if err := s.UpdateUser(ctx, user.ID, update); err != nil {
+		fmt.Printf("Warning: failed to update directory user %s: %v\n", user.Username, err)
+		return
+	}
+	if update.Email != nil {
+		user.Email = *update.Email
+	}
+	if update.DirectoryDN != nil {
+		user.DirectoryDN = *update.DirectoryDN
+	}
+	if update.Role != nil {
+		s.auditor.Log(ctx, &user.ID, "user_role_synced", "user", &user.ID, map[string]interface{}{
+			"username": user.Username,
+			"from":     user.Role,
+			"to":       *update.Role,
+		})
+		user.Role = *update.Role
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+)
+
+// LinkReport lists internal links that point at pages that don't exist.
+type LinkReport struct {
+	// Wanted groups missing [[wiki-link]] targets with the pages asking for them.
+	Wanted []models.WantedPage
+	// Dead lists markdown links to /wiki/ pages and includes of pages that
+	// don't exist, usually left behind by a rename or delete.
+	Dead []models.BrokenLink
+}
+
+// PageLinks extracts the internal links of a page's markdown content for the
+// link graph. Relative wiki-links resolve against the page's slug.
+func (s *WikiService) PageLinks(slug, content string) []models.PageLink {
+	var links []models.PageLink
+	for _, target := range s.markdown.ExtractLinks(slug, content) {
+		links = append(links, models.PageLink{TargetSlug: target, Kind: models.LinkKindWiki})
+	}
+	for _, target := range s.markdown.ExtractInternalLinks(content) {
+		links = append(links, models.PageLink{TargetSlug: target, Kind: models.LinkKindMarkdown})
+	}
+	for _, target := range s.markdown.ExtractIncludes(content) {
+		links = append(links, models.PageLink{TargetSlug: target, Kind: models.LinkKindInclude})
+	}
+	return links
+}
+
+// IndexLinks records a page's outgoing links. Failures only leave the link
+// report stale, so they are logged rather than returned.
+func (s *WikiService) IndexLinks(ctx context.Context, page *models.Page) {
+	if err := s.db.SetPageLinks(ctx, page.ID, s.PageLinks(page.Slug, page.Content)); err != nil {
+		fmt.Printf("Warning: failed to index links for %s: %v\n", page.Slug, err)
+	}
+}
+
+// RebuildLinkIndex re-extracts the links of every page.
+func (s *WikiService) RebuildLinkIndex(ctx context.Context) error {
+	return s.db.RebuildPageLinks(ctx, s.PageLinks)
+}
+
+// MissingLinks returns the slugs a page links to that don't exist.
+func (s *WikiService) MissingLinks(ctx context.Context, pageID int64) ([]string, error) {
+	return s.db.ListMissingLinkTargets(ctx, pageID)
+}
+
+// GetBacklinks returns the pages that link to slug, using the link index.
+// Unpublished linking pages are only included for editors.
+func (s *WikiService) GetBacklinks(ctx context.Context, slug string, includeUnpublished bool) ([]models.PageSummary, error) {
+	return s.db.ListBacklinks(ctx, slug, includeUnpublished)
+}
+
+// GetLinkReport builds the wanted pages and dead links report from the
+// links of the pages user can see.
+func (s *WikiService) GetLinkReport(ctx context.Context, user *models.User) (*LinkReport, error) {
+	hideRestricted, memberOf := policy.GroupFilter(user)
+	broken, err := s.db.ListBrokenLinks(ctx, hideRestricted, memberOf)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &LinkReport{}
+	wanted := make(map[string]int)
+	for _, l := range broken {
+		if l.Kind != models.LinkKindWiki {
+			report.Dead = append(report.Dead, l)
+			continue
+		}
+		source := models.PageSummary{ID: l.SourceID, Slug: l.SourceSlug, Title: l.SourceTitle}
+		if i, ok := wanted[l.TargetSlug]; ok {
+			report.Wanted[i].Sources = append(report.Wanted[i].Sources, source)
+			continue
+		}
+		wanted[l.TargetSlug] = len(report.Wanted)
+		report.Wanted = append(report.Wanted, models.WantedPage{
+			Slug:    l.TargetSlug,
+			Sources: []models.PageSummary{source},
+		})
+	}
+
+	return report, nil
+}
+
+// OrphanPages lists the pages nothing leads to: no parent, no tags and no
+// links from other pages. The pages set as the home page or sidebar are
+// reachable from every page, so they are left out.
+func (s *WikiService) OrphanPages(ctx context.Context, includeRestricted bool) ([]models.PageSummary, error) {
+	orphans, err := s.db.ListOrphanPages(ctx, includeRestricted)
+	if err != nil {
+		return nil, err
+	}
+
+	sitePages := make(map[string]bool)
+	for _, setting := range sitePageSettings {
+		if slug, err := s.db.GetSetting(ctx, setting); err == nil && slug != "" {
+			sitePages[slug] = true
+		}
+	}
+	kept := orphans[:0]
+	for _, p := range orphans {
+		if !sitePages[p.Slug] {
+			kept = append(kept, p)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"gowiki/internal/models"
+)
+
+// Markdown extension for block macros written alone on a line: {{toc}}
+// inserts the page's table of contents and {{include:slug}} transcludes
+// another page. Includes depend on who is reading, so they render as
+// placeholders that ExpandIncludes fills in when the page is served.
+
+var (
+	kindTOCMacro     = ast.NewNodeKind("TOCMacro")
+	kindIncludeMacro = ast.NewNodeKind("IncludeMacro")
+)
+
+// macroRe matches a macro line.
+var macroRe = regexp.MustCompile(`^\{\{\s*(?:(toc)|include:\s*([^{}]+?))\s*\}\}$`)
+
+// includeLineRe finds include macros in markdown source for the link index.
+var includeLineRe = regexp.MustCompile(`(?m)^[ \t]{0,3}\{\{\s*include:\s*([^{}\n]+?)\s*\}\}[ \t]*$`)
+
+// includePlaceholderRe matches the placeholder rendered for an include.
+var includePlaceholderRe = regexp.MustCompile(`<div class="page-include" data-include="([a-z0-9/-]+)"></div>`)
+
+// maxIncludeDepth bounds how deeply included pages may include others.
+const maxIncludeDepth = 5
+
+// tocMacro is filled with the document's headings once parsing is done.
+type tocMacro struct {
+	ast.BaseBlock
+	Entries []TOCEntry
+}
+
+func (n *tocMacro) Kind() ast.NodeKind {
+	return kindTOCMacro
+}
+
+func (n *tocMacro) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// includeMacro marks where another page's content goes.
+type includeMacro struct {
+	ast.BaseBlock
+	Slug string
+}
+
+func (n *includeMacro) Kind() ast.NodeKind {
+	return kindIncludeMacro
+}
+
+func (n *includeMacro) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Slug": n.Slug}, nil)
+}
+
+type macroExtension struct{}
+
+func (e *macroExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			util.Prioritized(&macroParser{}, 90),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(&tocTransformer{}, 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&macroRenderer{}, 100),
+		),
+	)
+}
+
+type macroParser struct{}
+
+func (b *macroParser) Trigger() []byte {
+	return []byte{'{'}
+}
+
+func (b *macroParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+
+	m := macroRe.FindSubmatch(util.TrimRightSpace(line[pos:]))
+	if m == nil {
+		return nil, parser.NoChildren
+	}
+
+	var node ast.Node
+	if m[1] != nil {
+		node = &tocMacro{}
+	} else {
+		slug := Slugify(string(m[2]))
+		if slug == "" {
+			return nil, parser.NoChildren
+		}
+		node = &includeMacro{Slug: slug}
+	}
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (b *macroParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (b *macroParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *macroParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *macroParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type tocTransformer struct{}
+
+func (t *tocTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	var macros []*tocMacro
+	var entries []TOCEntry
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *tocMacro:
+			macros = append(macros, n)
+		case *ast.Heading:
+			id, _ := n.AttributeString("id")
+			if id, ok := id.([]byte); ok {
+				entries = append(entries, TOCEntry{
+					Level: n.Level,
+					Text:  extractTextFromNode(n, source),
+					ID:    string(id),
+				})
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, macro := range macros {
+		macro.Entries = entries
+	}
+}
+
+type macroRenderer struct{}
+
+func (r *macroRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindTOCMacro, r.renderTOC)
+	reg.Register(kindIncludeMacro, r.renderInclude)
+}
+
+func (r *macroRenderer) renderTOC(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*tocMacro)
+	if !entering || len(n.Entries) == 0 {
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString("<div class=\"toc\">\n<div class=\"toc-title\">Contents</div>\n")
+
+	// Nest deeper headings inside the item before them
+	var open []int
+	for _, entry := range n.Entries {
+		if len(open) == 0 || entry.Level > open[len(open)-1] {
+			w.WriteString("<ul>\n")
+			open = append(open, entry.Level)
+		} else {
+			for len(open) > 1 && entry.Level < open[len(open)-1] {
+				w.WriteString("</li>\n</ul>\n")
+				open = open[:len(open)-1]
+			}
+			w.WriteString("</li>\n")
+		}
+		fmt.Fprintf(w, "<li><a href=\"#%s\">%s</a>", html.EscapeString(entry.ID), html.EscapeString(entry.Text))
+	}
+	for range open {
+		w.WriteString("</li>\n</ul>\n")
+	}
+
+	w.WriteString("</div>\n")
+	return ast.WalkContinue, nil
+}
+
+func (r *macroRenderer) renderInclude(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		fmt.Fprintf(w, "<div class=\"page-include\" data-include=\"%s\"></div>\n", node.(*includeMacro).Slug)
+	}
+	return ast.WalkContinue, nil
+}
+
+// ExtractIncludes returns the slugs of the pages a markdown document
+// includes.
+func (s *MarkdownService) ExtractIncludes(markdown string) []string {
+	matches := includeLineRe.FindAllStringSubmatch(markdown, -1)
+
+	slugs := make([]string, 0, len(matches))
+	seen := make(map[string]bool)
+
+	for _, match := range matches {
+		slug := Slugify(match[1])
+		if slug != "" && !seen[slug] {
+			slugs = append(slugs, slug)
+			seen[slug] = true
+		}
+	}
+
+	return slugs
+}
+
+// ExpandIncludes returns a page's rendered HTML with {{include:...}}
+// placeholders replaced by the content of the included pages, recursively.
+// Pages canView rejects, missing pages and include cycles render as a short
+// notice instead.
+func (s *WikiService) ExpandIncludes(ctx context.Context, page *models.Page, canView func(*models.Page) bool) string {
+	return s.expandIncludes(ctx, page.ContentHTML, []string{strings.ToLower(page.Slug)}, canView)
+}
+
+func (s *WikiService) expandIncludes(ctx context.Context, content string, stack []string, canView func(*models.Page) bool) string {
+	if !strings.Contains(content, `class="page-include"`) {
+		return content
+	}
+
+	return includePlaceholderRe.ReplaceAllStringFunc(content, func(placeholder string) string {
+		slug := includePlaceholderRe.FindStringSubmatch(placeholder)[1]
+
+		if slices.Contains(stack, slug) {
+			return includeNotice(slug, "is not included again because it includes this page")
+		}
+		if len(stack) > maxIncludeDepth {
+			return includeNotice(slug, "is nested too deeply to include")
+		}
+
+		page, err := s.db.GetPageBySlug(ctx, slug)
+		if err != nil {
+			fmt.Printf("Warning: failed to load included page %s: %v\n", slug, err)
+		}
+		if page == nil || !canView(page) {
+			return includeNotice(slug, "is not available")
+		}
+
+		inner := s.expandIncludes(ctx, page.ContentHTML, append(stack[:len(stack):len(stack)], slug), canView)
+		return fmt.Sprintf("<div class=\"page-include\" data-include=\"%s\">\n%s</div>", slug, inner)
+	})
+}
+
+// includeNotice explains in place of an include why it is missing.
+func includeNotice(slug, reason string) string {
+	return fmt.Sprintf("<div class=\"page-include page-include-missing\">Included page <a href=\"/wiki/%s\">%s</a> %s.</div>", slug, slug, reason)
+}
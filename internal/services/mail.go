@@ -0,0 +1,504 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+var (
+	ErrMailDisabled          = errors.New("email is not configured")
+	ErrUnknownEmailTemplate  = errors.New("unknown email template")
+	ErrInvalidEmailTemplate  = errors.New("invalid email template")
+	ErrInvalidLocale         = errors.New("locale must look like en or pt-BR")
+	ErrInvalidEmailRecipient = errors.New("invalid email address")
+)
+
+// mailTimeout bounds a whole SMTP conversation.
+const mailTimeout = 30 * time.Second
+
+// localeRe matches a language with an optional region, e.g. "de" or "pt-BR".
+var localeRe = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2})?$`)
+
+// builtinEmails are the emails the wiki sends, with their default wording.
+var builtinEmails = []struct {
+	info     models.EmailTemplateInfo
+	template models.EmailTemplate
+}{
+	{
+		info: models.EmailTemplateInfo{
+			Key:         models.EmailNotification,
+			Name:        "Notification",
+			Description: "General notices about activity on the wiki.",
+			Variables: map[string]string{
+				"Username": "alice",
+				"Title":    "Getting Started was updated",
+				"Message":  "bob edited Getting Started: fixed install steps",
+				"Link":     "https://wiki.example.com/wiki/getting-started",
+			},
+		},
+		template: models.EmailTemplate{
+			Subject:  "[{{.SiteName}}] {{.Title}}",
+			BodyText: "Hi {{.Username}},\n\n{{.Message}}\n\n{{.Link}}\n\n-- \n{{.SiteName}}\n",
+			BodyHTML: `<p>Hi {{.Username}},</p>
+<p>{{.Message}}</p>
+<p><a href="{{.Link}}">{{.Link}}</a></p>
+<p>&mdash; {{.SiteName}}</p>`,
+		},
+	},
+	{
+		info: models.EmailTemplateInfo{
+			Key:         models.EmailInvite,
+			Name:        "Invitation",
+			Description: "Sent when someone is invited to join the wiki.",
+			Variables: map[string]string{
+				"Username":  "alice",
+				"InvitedBy": "bob",
+				"Link":      "https://wiki.example.com/invite/abc123",
+				"ExpiresIn": "7 days",
+			},
+		},
+		template: models.EmailTemplate{
+			Subject:  "You're invited to {{.SiteName}}",
+			BodyText: "Hi {{.Username}},\n\n{{.InvitedBy}} invited you to {{.SiteName}}. Accept the invitation here:\n\n{{.Link}}\n\nThe link expires in {{.ExpiresIn}}.\n",
+			BodyHTML: `<p>Hi {{.Username}},</p>
+<p>{{.InvitedBy}} invited you to {{.SiteName}}.</p>
+<p><a href="{{.Link}}">Accept the invitation</a></p>
+<p>The link expires in {{.ExpiresIn}}.</p>`,
+		},
+	},
+	{
+		info: models.EmailTemplateInfo{
+			Key:         models.EmailPasswordReset,
+			Name:        "Password reset",
+			Description: "Sent when a user asks to reset a forgotten password.",
+			Variables: map[string]string{
+				"Username":  "alice",
+				"Link":      "https://wiki.example.com/reset/abc123",
+				"ExpiresIn": "1 hour",
+			},
+		},
+		template: models.EmailTemplate{
+			Subject:  "Reset your {{.SiteName}} password",
+			BodyText: "Hi {{.Username}},\n\nSomeone asked to reset your {{.SiteName}} password. If it was you, choose a new password here:\n\n{{.Link}}\n\nThe link expires in {{.ExpiresIn}}. If you didn't ask for this, you can ignore this email.\n",
+			BodyHTML: `<p>Hi {{.Username}},</p>
+<p>Someone asked to reset your {{.SiteName}} password. If it was you, choose a new password:</p>
+<p><a href="{{.Link}}">Reset password</a></p>
+<p>The link expires in {{.ExpiresIn}}. If you didn't ask for this, you can ignore this email.</p>`,
+		},
+	},
+	{
+		info: models.EmailTemplateInfo{
+			Key:         models.EmailVerify,
+			Name:        "Email verification",
+			Description: "Sent after registration when email verification is required.",
+			Variables: map[string]string{
+				"Username":  "alice",
+				"Link":      "https://wiki.example.com/verify/abc123",
+				"ExpiresIn": "48 hours",
+			},
+		},
+		template: models.EmailTemplate{
+			Subject:  "Confirm your email for {{.SiteName}}",
+			BodyText: "Hi {{.Username}},\n\nConfirm your email address to finish signing up for {{.SiteName}}:\n\n{{.Link}}\n\nThe link expires in {{.ExpiresIn}}. If you didn't sign up, you can ignore this email.\n",
+			BodyHTML: `<p>Hi {{.Username}},</p>
+<p>Confirm your email address to finish signing up for {{.SiteName}}.</p>
+<p><a href="{{.Link}}">Confirm email</a></p>
+<p>The link expires in {{.ExpiresIn}}. If you didn't sign up, you can ignore this email.</p>`,
+		},
+	},
+	{
+		info: models.EmailTemplateInfo{
+			Key:         models.EmailShareLink,
+			Name:        "Share link",
+			Description: "Sent to the recipients a user chooses when creating a share link.",
+			Variables: map[string]string{
+				"SharedBy": "bob",
+				"Title":    "Getting Started",
+				"Message":  "Here are the install notes we talked about.",
+				"Link":     "https://wiki.example.com/s/abc123",
+			},
+		},
+		template: models.EmailTemplate{
+			Subject:  "{{.SharedBy}} shared {{.Title}} with you",
+			BodyText: "Hi,\n\n{{.SharedBy}} shared {{.Title}} from {{.SiteName}} with you.\n{{if .Message}}\n{{.Message}}\n{{end}}\n{{.Link}}\n",
+			BodyHTML: `<p>Hi,</p>
+<p>{{.SharedBy}} shared {{.Title}} from {{.SiteName}} with you.</p>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<p><a href="{{.Link}}">Open {{.Title}}</a></p>`,
+		},
+	},
+}
+
+// Email is a rendered email ready to send.
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// MailService renders email templates and sends email over SMTP.
+type MailService struct {
+	db       *database.DB
+	cfg      config.MailConfig
+	settings *SettingsService
+	siteURL  string
+}
+
+// NewMailService creates a new MailService.
+func NewMailService(db *database.DB, cfg *config.Config, settings *SettingsService) *MailService {
+	return &MailService{
+		db:       db,
+		cfg:      cfg.Mail,
+		settings: settings,
+		siteURL:  strings.TrimRight(cfg.Site.URL, "/"),
+	}
+}
+
+// Enabled reports whether an SMTP server is configured.
+func (s *MailService) Enabled() bool {
+	return s.cfg.Host != ""
+}
+
+// TemplateInfos describes every email the wiki sends.
+func (s *MailService) TemplateInfos() []models.EmailTemplateInfo {
+	infos := make([]models.EmailTemplateInfo, len(builtinEmails))
+	for i, b := range builtinEmails {
+		infos[i] = b.info
+	}
+	return infos
+}
+
+// TemplateInfo describes one email, or returns nil for an unknown key.
+func (s *MailService) TemplateInfo(key string) *models.EmailTemplateInfo {
+	for _, b := range builtinEmails {
+		if b.info.Key == key {
+			info := b.info
+			return &info
+		}
+	}
+	return nil
+}
+
+// ListTemplates returns the customized templates, including locale variants.
+func (s *MailService) ListTemplates(ctx context.Context) ([]models.EmailTemplate, error) {
+	return s.db.ListEmailTemplates(ctx)
+}
+
+// Template returns the template used for key in locale. It falls back from
+// "pt-BR" to "pt", then to the default locale, then to the built-in wording.
+func (s *MailService) Template(ctx context.Context, key, locale string) (*models.EmailTemplate, error) {
+	var builtin *models.EmailTemplate
+	for _, b := range builtinEmails {
+		if b.info.Key == key {
+			t := b.template
+			t.Key = key
+			builtin = &t
+		}
+	}
+	if builtin == nil {
+		return nil, ErrUnknownEmailTemplate
+	}
+
+	locale = NormalizeLocale(locale)
+	candidates := []string{locale}
+	if lang, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, lang)
+	}
+	if locale != "" {
+		candidates = append(candidates, "")
+	}
+
+	for _, candidate := range candidates {
+		t, err := s.db.GetEmailTemplate(ctx, key, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			return t, nil
+		}
+	}
+	return builtin, nil
+}
+
+// SaveTemplate validates and stores a template for its key and locale.
+func (s *MailService) SaveTemplate(ctx context.Context, t *models.EmailTemplate, userID int64) error {
+	info := s.TemplateInfo(t.Key)
+	if info == nil {
+		return ErrUnknownEmailTemplate
+	}
+	t.Locale = NormalizeLocale(t.Locale)
+	if t.Locale != "" && !localeRe.MatchString(t.Locale) {
+		return ErrInvalidLocale
+	}
+	if strings.TrimSpace(t.Subject) == "" || strings.TrimSpace(t.BodyText) == "" {
+		return fmt.Errorf("%w: subject and text body are required", ErrInvalidEmailTemplate)
+	}
+	if _, err := s.Render(t, info.Variables); err != nil {
+		return err
+	}
+
+	t.UpdatedBy = &userID
+	return s.db.SaveEmailTemplate(ctx, t)
+}
+
+// ResetTemplate deletes a customized template, restoring the fallback.
+func (s *MailService) ResetTemplate(ctx context.Context, key, locale string) error {
+	if s.TemplateInfo(key) == nil {
+		return ErrUnknownEmailTemplate
+	}
+	return s.db.DeleteEmailTemplate(ctx, key, NormalizeLocale(locale))
+}
+
+// Render fills a template with data. SiteName and SiteURL are always set.
+func (s *MailService) Render(t *models.EmailTemplate, data map[string]string) (*Email, error) {
+	vars := map[string]string{"SiteName": s.settings.SiteName(context.Background()), "SiteURL": s.siteURL}
+	for k, v := range data {
+		vars[k] = v
+	}
+
+	subject, err := executeText("subject", t.Subject, vars)
+	if err != nil {
+		return nil, err
+	}
+	text, err := executeText("text", t.BodyText, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	email := &Email{
+		// Header injection is impossible with a single-line subject
+		Subject: strings.Join(strings.Fields(subject), " "),
+		Text:    text,
+	}
+
+	if strings.TrimSpace(t.BodyHTML) != "" {
+		tmpl, err := htmltemplate.New("html").Option("missingkey=zero").Parse(t.BodyHTML)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEmailTemplate, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEmailTemplate, err)
+		}
+		email.HTML = buf.String()
+	}
+
+	return email, nil
+}
+
+// Preview renders a template with the example values of its variables.
+func (s *MailService) Preview(t *models.EmailTemplate) (*Email, error) {
+	info := s.TemplateInfo(t.Key)
+	if info == nil {
+		return nil, ErrUnknownEmailTemplate
+	}
+	return s.Render(t, info.Variables)
+}
+
+// SendTemplate renders the template for key in the recipient's locale and
+// sends it.
+func (s *MailService) SendTemplate(ctx context.Context, key, locale, to string, data map[string]string) error {
+	t, err := s.Template(ctx, key, locale)
+	if err != nil {
+		return err
+	}
+	email, err := s.Render(t, data)
+	if err != nil {
+		return err
+	}
+	return s.Send(ctx, to, email)
+}
+
+// Send delivers an email to a single recipient.
+func (s *MailService) Send(ctx context.Context, to string, email *Email) error {
+	if !s.Enabled() {
+		return ErrMailDisabled
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return ErrInvalidEmailRecipient
+	}
+
+	from := s.cfg.From
+	if from == "" {
+		from = "noreply@" + s.cfg.Host
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("invalid WIKI_MAIL_FROM: %w", err)
+	}
+	if sender.Name == "" {
+		sender.Name = s.settings.SiteName(ctx)
+	}
+
+	msg, err := buildMessage(sender, rcpt, email)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, sender.Address, rcpt.Address, msg)
+}
+
+// deliver runs the SMTP conversation. Port 465 uses implicit TLS; other
+// ports upgrade with STARTTLS when the server offers it.
+func (s *MailService) deliver(ctx context.Context, from, to string, msg []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: mailTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.Port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: s.cfg.Host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to mail server: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(mailTimeout))
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		auth := smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate with mail server: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("mail server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(to); err != nil {
+		return fmt.Errorf("mail server rejected recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return client.Quit()
+}
+
+// NormalizeLocale turns "pt_br" or "PT-br" into "pt-BR".
+func NormalizeLocale(locale string) string {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	lang, region, ok := strings.Cut(locale, "-")
+	if !ok {
+		return strings.ToLower(lang)
+	}
+	return strings.ToLower(lang) + "-" + strings.ToUpper(region)
+}
+
+func executeText(name, src string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEmailTemplate, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidEmailTemplate, err)
+	}
+	return buf.String(), nil
+}
+
+// buildMessage assembles a MIME message with a plain text part and, when
+// present, an HTML alternative.
+func buildMessage(from, to *mail.Address, email *Email) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", email.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+randomID()+"@"+domainOf(from.Address)+">")
+	header("MIME-Version", "1.0")
+
+	if email.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, email.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	boundary := "gowiki-" + randomID()
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", email.Text},
+		{"text/html; charset=utf-8", email.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, part.body); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, body string) error {
+	w := quotedprintable.NewWriter(buf)
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return w.Close()
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndex(address, "@"); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gowiki/internal/database"
+)
+
+// ErrMaintenanceInProgress is returned when a maintenance operation is
+// started while a snapshot or another operation is running.
+var ErrMaintenanceInProgress = errors.New("a snapshot or maintenance operation is already running")
+
+// ErrCheckpointReplicated is returned by Checkpoint while Litestream
+// replicates the database. Truncating the write-ahead log under it can drop
+// frames it hasn't shipped yet, and it checkpoints the log itself.
+var ErrCheckpointReplicated = errors.New("the write-ahead log is checkpointed by Litestream while replication is on")
+
+// Database maintenance operations, as recorded in the audit log.
+const (
+	MaintenanceVacuum     = "vacuum"
+	MaintenanceCheckpoint = "checkpoint"
+	MaintenanceIntegrity  = "integrity_check"
+	MaintenanceBackup     = "backup"
+)
+
+// maxIntegrityProblems caps how many problems an integrity check reports.
+const maxIntegrityProblems = 100
+
+// DatabaseStatus describes the live SQLite database.
+type DatabaseStatus struct {
+	database.StorageStats
+	// WALSize is the size of the write-ahead log, which a checkpoint
+	// truncates.
+	WALSize int64
+}
+
+// MaintenanceResult reports what a maintenance operation did.
+type MaintenanceResult struct {
+	Operation string
+	Duration  time.Duration
+	// SizeBefore and SizeAfter are the database size around a vacuum and
+	// the write-ahead log size around a checkpoint. SizeAfter is also the
+	// size of a backup.
+	SizeBefore int64
+	SizeAfter  int64
+	// Checkpoint is set for checkpoints.
+	Checkpoint *database.CheckpointResult
+	// Problems lists what an integrity check found.
+	Problems []string
+}
+
+// DatabaseStatus reports the size and free space of the live database.
+// It returns database.ErrSQLiteOnly on PostgreSQL.
+func (s *BackupScheduler) DatabaseStatus(ctx context.Context) (*DatabaseStatus, error) {
+	stats, err := s.db.StorageStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &DatabaseStatus{StorageStats: *stats, WALSize: s.walSize()}, nil
+}
+
+func (s *BackupScheduler) walSize() int64 {
+	info, err := os.Stat(s.dbPath + "-wal")
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Vacuum rebuilds the database file to reclaim free space.
+func (s *BackupScheduler) Vacuum(ctx context.Context, userID *int64, ipAddress string) (*MaintenanceResult, error) {
+	return s.maintain(ctx, MaintenanceVacuum, userID, ipAddress, func(result *MaintenanceResult) error {
+		before, err := s.db.StorageStats(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.db.Vacuum(ctx); err != nil {
+			return err
+		}
+		after, err := s.db.StorageStats(ctx)
+		if err != nil {
+			return err
+		}
+		result.SizeBefore, result.SizeAfter = before.Size(), after.Size()
+		return nil
+	})
+}
+
+// Checkpoint copies the write-ahead log into the database file and
+// truncates it. It returns ErrCheckpointReplicated while the database is
+// replicated.
+func (s *BackupScheduler) Checkpoint(ctx context.Context, userID *int64, ipAddress string) (*MaintenanceResult, error) {
+	if s.replicated {
+		return nil, ErrCheckpointReplicated
+	}
+	return s.maintain(ctx, MaintenanceCheckpoint, userID, ipAddress, func(result *MaintenanceResult) error {
+		result.SizeBefore = s.walSize()
+		var err error
+		result.Checkpoint, err = s.db.Checkpoint(ctx)
+		result.SizeAfter = s.walSize()
+		return err
+	})
+}
+
+// CheckIntegrity verifies the database file. Problems found are part of
+// the result, not an error.
+func (s *BackupScheduler) CheckIntegrity(ctx context.Context, userID *int64, ipAddress string) (*MaintenanceResult, error) {
+	return s.maintain(ctx, MaintenanceIntegrity, userID, ipAddress, func(result *MaintenanceResult) error {
+		var err error
+		result.Problems, err = s.db.IntegrityCheck(ctx, maxIntegrityProblems)
+		return err
+	})
+}
+
+// Backup writes a consistent copy of the live database to a temporary file
+// for download and returns its path. The caller removes the file.
+func (s *BackupScheduler) Backup(ctx context.Context, userID *int64, ipAddress string) (string, error) {
+	var path string
+	_, err := s.maintain(ctx, MaintenanceBackup, userID, ipAddress, func(result *MaintenanceResult) error {
+		// VACUUM INTO needs a name that doesn't exist yet
+		f, err := os.CreateTemp(s.cfg.Path, "download-*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %w", err)
+		}
+		path = f.Name()
+		f.Close()
+		os.Remove(path)
+
+		if err := s.db.VacuumInto(ctx, path); err != nil {
+			os.Remove(path)
+			return err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			os.Remove(path)
+			return fmt.Errorf("failed to stat backup: %w", err)
+		}
+		result.SizeAfter = info.Size()
+		return nil
+	})
+	return path, err
+}
+
+// maintain runs a maintenance operation, keeping snapshots and other
+// operations out meanwhile, and records it in the audit log.
+func (s *BackupScheduler) maintain(ctx context.Context, operation string, userID *int64, ipAddress string, fn func(*MaintenanceResult) error) (*MaintenanceResult, error) {
+	if !s.begin() {
+		return nil, ErrMaintenanceInProgress
+	}
+	defer s.end()
+
+	// Other replicas may be taking a snapshot of the same file
+	result := &MaintenanceResult{Operation: operation}
+	start := time.Now()
+	err := s.cluster.WithLock(ctx, "snapshot", time.Minute, func() error {
+		return fn(result)
+	})
+	if errors.Is(err, ErrLockHeld) {
+		return nil, ErrMaintenanceInProgress
+	}
+	result.Duration = time.Since(start)
+
+	details := map[string]interface{}{
+		"duration_ms": result.Duration.Milliseconds(),
+	}
+	action := "database_" + operation
+	switch {
+	case err != nil:
+		action += "_failed"
+		details["error"] = err.Error()
+	case operation == MaintenanceVacuum, operation == MaintenanceCheckpoint:
+		details["size_before"] = result.SizeBefore
+		details["size_after"] = result.SizeAfter
+		if result.Checkpoint != nil {
+			details["busy"] = result.Checkpoint.Busy
+		}
+	case operation == MaintenanceBackup:
+		details["size"] = result.SizeAfter
+	case operation == MaintenanceIntegrity:
+		details["problems"] = len(result.Problems)
+	}
+	s.audit(action, userID, details, ipAddress)
+
+	return result, err
+}
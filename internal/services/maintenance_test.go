@@ -0,0 +1,26 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gowiki/internal/config"
+)
+
+func TestCheckpointReplicated(t *testing.T) {
+	db := newTestDB(t)
+	cfg := &config.Config{
+		Snapshot: config.SnapshotConfig{Path: t.TempDir()},
+		Replica:  config.ReplicaConfig{URL: "s3://bucket/wiki"},
+	}
+	scheduler, err := NewBackupScheduler(db, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.Checkpoint(context.Background(), nil, ""); !errors.Is(err, ErrCheckpointReplicated) {
+		t.Errorf("Checkpoint error = %v, want ErrCheckpointReplicated", err)
+	}
+}
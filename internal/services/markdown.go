@@ -2,27 +2,40 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
+	emoji "github.com/yuin/goldmark-emoji"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
 	"github.com/yuin/goldmark/text"
-	"github.com/yuin/goldmark/util"
+	"go.opentelemetry.io/otel/attribute"
+
+	"gowiki/internal/tracing"
 )
 
 // MarkdownService handles markdown parsing and rendering.
 type MarkdownService struct {
 	md        goldmark.Markdown
 	sanitizer *bluemonday.Policy
+	diagrams  *diagramTransformer
+	math      *mathExtension
+	cache     *renderCache
 }
 
 // NewMarkdownService creates a new markdown service with secure defaults.
 func NewMarkdownService() *MarkdownService {
+	diagrams := &diagramTransformer{}
+	diagramExt := &diagramExtension{transformer: diagrams}
+	math := &mathExtension{}
+	emojiExt := emoji.New(emoji.WithRenderingMethod(emoji.Unicode))
+
 	md := goldmark.New(
 		goldmark.WithExtensions(
 			extension.GFM,            // GitHub Flavored Markdown
@@ -30,6 +43,11 @@ func NewMarkdownService() *MarkdownService {
 			extension.DefinitionList, // Definition lists
 			extension.Footnote,       // Footnotes
 			&wikiLinkExtension{},     // Custom [[wiki-links]]
+			diagramExt,               // Mermaid and PlantUML diagrams
+			math,                     // $inline$ and $$display$$ math
+			&calloutExtension{},      // > [!NOTE] callout boxes
+			&macroExtension{},        // {{toc}} and {{include:slug}}
+			emojiExt,                 // :emoji: shortcodes
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(), // Auto-generate heading IDs
@@ -56,6 +74,9 @@ func NewMarkdownService() *MarkdownService {
 		"ul", "ol", "li", "blockquote", "h1", "h2", "h3", "h4", "h5", "h6",
 	)
 
+	// Allow the note role on callout boxes
+	sanitizer.AllowAttrs("role").Matching(regexp.MustCompile(`^note$`)).OnElements("div")
+
 	// Allow id attributes for heading anchors
 	sanitizer.AllowAttrs("id").OnElements("h1", "h2", "h3", "h4", "h5", "h6", "a")
 
@@ -68,26 +89,93 @@ func NewMarkdownService() *MarkdownService {
 
 	// Allow images with alt text
 	sanitizer.AllowAttrs("alt", "title", "width", "height").OnElements("img")
-	sanitizer.AllowAttrs("src").Matching(regexp.MustCompile(`^(/uploads/|https?://)`)).OnElements("img")
+	sanitizer.AllowAttrs("src").Matching(regexp.MustCompile(`^(/uploads/|/diagrams/|https?://)`)).OnElements("img")
 
 	return &MarkdownService{
 		md:        md,
 		sanitizer: sanitizer,
+		diagrams:  diagrams,
+		math:      math,
+		cache:     newRenderCache(maxRenderCacheBytes),
 	}
 }
 
-// Render converts markdown to sanitized HTML.
+// markdownRenderVersion changes whenever rendering changes in a way that
+// affects stored page HTML, excerpts or word counts, so pages are
+// re-rendered on the next start.
+const markdownRenderVersion = 6
+
+// RenderVersion identifies the current rendering rules, including optional
+// features that change the output.
+func (s *MarkdownService) RenderVersion() string {
+	version := fmt.Sprintf("%d", markdownRenderVersion)
+	if s.diagrams.plantUML.Load() {
+		version += "+plantuml"
+	}
+	if s.math.enabled.Load() {
+		version += "+math"
+	}
+	return version
+}
+
+// EnablePlantUML renders plantuml code blocks as diagrams served from
+// PlantUMLPath. Call it before rendering any content.
+func (s *MarkdownService) EnablePlantUML() {
+	s.diagrams.plantUML.Store(true)
+}
+
+// SetMath turns $...$ and $$...$$ math rendering on or off. Stored pages
+// keep their HTML until re-rendered.
+func (s *MarkdownService) SetMath(enabled bool) {
+	s.math.enabled.Store(enabled)
+}
+
+// MathEnabled reports whether math rendering is on.
+func (s *MarkdownService) MathEnabled() bool {
+	return s.math.enabled.Load()
+}
+
+// Render converts markdown to sanitized HTML. Relative [[./wiki-links]]
+// resolve from the top of the wiki; use RenderPage for a page's content.
 func (s *MarkdownService) Render(markdown string) (string, error) {
+	return s.RenderPage("", markdown)
+}
+
+// RenderPage converts the markdown of the page at slug to sanitized HTML,
+// resolving relative [[./wiki-links]] against slug. Results are cached by
+// page, content and rendering rules, so unchanged markdown is only parsed
+// once.
+func (s *MarkdownService) RenderPage(slug, markdown string) (string, error) {
+	key := renderCacheKey(s.RenderVersion(), slug+"\x00"+markdown)
+	if html, ok := s.cache.get(key); ok {
+		return html, nil
+	}
+
 	var buf bytes.Buffer
 
-	if err := s.md.Convert([]byte(markdown), &buf); err != nil {
+	pc := parser.NewContext()
+	pc.Set(wikiLinkBaseKey, slug)
+	if err := s.md.Convert([]byte(markdown), &buf, parser.WithContext(pc)); err != nil {
 		return "", err
 	}
 
 	// Sanitize the output
-	sanitized := s.sanitizer.SanitizeBytes(buf.Bytes())
+	sanitized := string(s.sanitizer.SanitizeBytes(buf.Bytes()))
+
+	s.cache.put(key, sanitized)
+	return sanitized, nil
+}
+
+// RenderContext renders a page's markdown like RenderPage, recording a
+// trace span.
+func (s *MarkdownService) RenderContext(ctx context.Context, slug, markdown string) (string, error) {
+	_, span := tracing.Tracer().Start(ctx, "markdown.render")
+	defer span.End()
+	span.SetAttributes(attribute.Int("markdown.length", len(markdown)))
 
-	return string(sanitized), nil
+	html, err := s.RenderPage(slug, markdown)
+	tracing.RecordError(span, err)
+	return html, err
 }
 
 // RenderUnsafe converts markdown to HTML without sanitization.
@@ -116,25 +204,81 @@ func (s *MarkdownService) ExtractTitle(markdown string) string {
 	return ""
 }
 
-// ExtractLinks extracts all wiki-style links from markdown.
-func (s *MarkdownService) ExtractLinks(markdown string) []string {
-	re := regexp.MustCompile(`\[\[([^\]|]+)(?:\|[^\]]+)?\]\]`)
-	matches := re.FindAllStringSubmatch(markdown, -1)
+// ExtractLinks returns the slugs of the pages the wiki-style links in the
+// markdown of the page at slug point to, in order of first appearance.
+// Links to a section of the same page are left out.
+func (s *MarkdownService) ExtractLinks(slug, markdown string) []string {
+	var links []string
+	seen := make(map[string]bool)
+
+	for i := 0; ; {
+		j := strings.Index(markdown[i:], "[[")
+		if j < 0 {
+			break
+		}
+		i += j
+		if escapedAt(markdown, i) {
+			i++
+			continue
+		}
+		inner, n, ok := scanWikiLink(markdown[i:])
+		if !ok {
+			i++
+			continue
+		}
+		i += n
+
+		link, ok := parseWikiLink(inner)
+		if !ok || link.Target == "" {
+			continue
+		}
+		if target := resolveWikiTarget(slug, link.Target); target != "" && !seen[target] {
+			links = append(links, target)
+			seen[target] = true
+		}
+	}
+
+	return links
+}
+
+// internalLinkRe matches markdown links to wiki pages, e.g. [text](/wiki/slug).
+var internalLinkRe = regexp.MustCompile(`\]\(/wiki/([^)\s#?"]+)`)
+
+// ExtractInternalLinks extracts the slugs of regular markdown links to
+// /wiki/ pages. Wiki-style links are handled by ExtractLinks.
+func (s *MarkdownService) ExtractInternalLinks(markdown string) []string {
+	matches := internalLinkRe.FindAllStringSubmatch(markdown, -1)
 
-	links := make([]string, 0, len(matches))
+	slugs := make([]string, 0, len(matches))
 	seen := make(map[string]bool)
 
 	for _, match := range matches {
-		if len(match) > 1 {
-			link := strings.TrimSpace(match[1])
-			if !seen[link] {
-				links = append(links, link)
-				seen[link] = true
-			}
+		slug := strings.Trim(match[1], "/")
+		if slug != "" && !seen[slug] {
+			slugs = append(slugs, slug)
+			seen[slug] = true
 		}
 	}
 
-	return links
+	return slugs
+}
+
+// MarkRedlinks adds the "redlink" class to rendered links pointing at the
+// given missing slugs, so readers can tell they lead nowhere yet.
+func MarkRedlinks(html string, missing []string) string {
+	if len(missing) == 0 {
+		return html
+	}
+
+	quoted := make([]string, len(missing))
+	for i, slug := range missing {
+		quoted[i] = regexp.QuoteMeta(slug)
+	}
+	re := regexp.MustCompile(`<a href="/wiki/(?:` + strings.Join(quoted, "|") + `)(?:#[^"]*)?"`)
+
+	return re.ReplaceAllStringFunc(html, func(tag string) string {
+		return tag + ` class="redlink"`
+	})
 }
 
 // GenerateTOC extracts headings and generates a table of contents.
@@ -197,66 +341,6 @@ func generateHeadingID(text string) string {
 	return id
 }
 
-// Wiki link extension for [[page]] syntax
-
-type wikiLinkExtension struct{}
-
-func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
-	m.Parser().AddOptions(
-		parser.WithInlineParsers(
-			util.Prioritized(&wikiLinkParser{}, 100),
-		),
-	)
-}
-
-type wikiLinkParser struct{}
-
-func (p *wikiLinkParser) Trigger() []byte {
-	return []byte{'['}
-}
-
-func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
-	line, segment := block.PeekLine()
-
-	// Check for [[
-	if len(line) < 4 || line[0] != '[' || line[1] != '[' {
-		return nil
-	}
-
-	// Find closing ]]
-	end := bytes.Index(line[2:], []byte("]]"))
-	if end < 0 {
-		return nil
-	}
-
-	content := string(line[2 : end+2])
-
-	// Split on | for display text
-	parts := strings.SplitN(content, "|", 2)
-	pageName := strings.TrimSpace(parts[0])
-	displayText := pageName
-	if len(parts) > 1 {
-		displayText = strings.TrimSpace(parts[1])
-	}
-
-	// Create the slug from page name
-	slug := slugify(pageName)
-
-	// Create link node
-	link := ast.NewLink()
-	link.Destination = []byte("/wiki/" + slug)
-	link.Title = []byte(pageName)
-
-	text := ast.NewString([]byte(displayText))
-	text.SetRaw(true)
-	link.AppendChild(link, text)
-
-	// Advance reader past the wiki link
-	block.Advance(segment.Start + end + 4)
-
-	return link
-}
-
 // slugify converts a page name to a URL-safe slug.
 // Preserves forward slashes for hierarchical paths like "linux/ubuntu/networking".
 func slugify(name string) string {
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"html"
+	"sync/atomic"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Markdown extension for $inline$ and $$display$$ TeX math. Formulas are
+// emitted as escaped TeX inside .math elements and typeset by KaTeX in the
+// browser. Parsing them before the Typographer keeps quotes and dashes in
+// formulas intact.
+
+var (
+	kindMath      = ast.NewNodeKind("Math")
+	kindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// mathNode is inline math, or display math written on a single line.
+type mathNode struct {
+	ast.BaseInline
+	Source  []byte
+	Display bool
+}
+
+func (n *mathNode) Kind() ast.NodeKind {
+	return kindMath
+}
+
+func (n *mathNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Source": string(n.Source)}, nil)
+}
+
+// mathBlock is display math between lines holding only $$.
+type mathBlock struct {
+	ast.BaseBlock
+}
+
+func (n *mathBlock) Kind() ast.NodeKind {
+	return kindMathBlock
+}
+
+func (n *mathBlock) IsRaw() bool {
+	return true
+}
+
+func (n *mathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+type mathExtension struct {
+	// enabled is toggled by the math site setting; while unset dollar
+	// signs are ordinary text.
+	enabled atomic.Bool
+}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(
+			// Ahead of paragraphs so $$ can interrupt them
+			util.Prioritized(&mathBlockParser{ext: e}, 90),
+		),
+		parser.WithInlineParsers(
+			// Ahead of emphasis and the Typographer
+			util.Prioritized(&mathInlineParser{ext: e}, 90),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&mathRenderer{}, 100),
+		),
+	)
+}
+
+type mathInlineParser struct {
+	ext *mathExtension
+}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+// Parse follows Pandoc's rules so prices aren't taken for math: the opening
+// $ must not be followed by a space, and the closing $ must not follow a
+// space or precede a digit.
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	if !p.ext.enabled.Load() {
+		return nil
+	}
+
+	line, _ := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+
+	body := line[delim:]
+	if len(body) == 0 || util.IsSpace(body[0]) || body[0] == '$' {
+		return nil
+	}
+
+	for i := 1; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '$':
+			if delim == 2 {
+				if i+1 < len(body) && body[i+1] == '$' {
+					block.Advance(delim + i + 2)
+					return &mathNode{Source: body[:i], Display: true}
+				}
+				continue
+			}
+			if util.IsSpace(body[i-1]) || (i+1 < len(body) && body[i+1] >= '0' && body[i+1] <= '9') {
+				continue
+			}
+			block.Advance(delim + i + 1)
+			return &mathNode{Source: body[:i]}
+		}
+	}
+
+	return nil
+}
+
+type mathBlockParser struct {
+	ext *mathExtension
+}
+
+func (b *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (b *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	if !b.ext.enabled.Load() {
+		return nil, parser.NoChildren
+	}
+
+	line, _ := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !isMathFence(line[pos:]) {
+		return nil, parser.NoChildren
+	}
+	return &mathBlock{}, parser.NoChildren
+}
+
+func (b *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if isMathFence(util.TrimLeftSpace(line)) {
+		reader.Advance(segment.Len())
+		return parser.Close
+	}
+
+	node.Lines().Append(segment)
+	reader.Advance(segment.Len() - 1)
+	return parser.Continue | parser.NoChildren
+}
+
+func (b *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (b *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (b *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// isMathFence reports whether line is $$ and nothing else.
+func isMathFence(line []byte) bool {
+	line = util.TrimRightSpace(line)
+	return len(line) == 2 && line[0] == '$' && line[1] == '$'
+}
+
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindMath, r.renderMath)
+	reg.Register(kindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	n := node.(*mathNode)
+	if n.Display {
+		w.WriteString(`<span class="math math-display">`)
+	} else {
+		w.WriteString(`<span class="math math-inline">`)
+	}
+	w.WriteString(html.EscapeString(string(n.Source)))
+	w.WriteString(`</span>`)
+
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString(`<div class="math math-display">`)
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		w.WriteString(html.EscapeString(string(line.Value(source))))
+	}
+	w.WriteString("</div>\n")
+
+	return ast.WalkSkipChildren, nil
+}
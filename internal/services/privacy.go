@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/jobs"
+	"gowiki/internal/models"
+)
+
+// PrivacyService gathers a user's personal data for data-protection requests
+// and anonymizes client IP addresses once they fall out of retention.
+type PrivacyService struct {
+	db        *database.DB
+	retention time.Duration
+	cluster   *Cluster
+
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+	lastN   int64
+}
+
+// RetentionStatus describes the IP retention job.
+type RetentionStatus struct {
+	Retention  time.Duration
+	LastRun    time.Time
+	Anonymized int64
+	Error      string
+}
+
+// NewPrivacyService creates a privacy service.
+func NewPrivacyService(db *database.DB, cfg *config.Config, cluster *Cluster) *PrivacyService {
+	return &PrivacyService{
+		db:        db,
+		retention: cfg.Security.IPRetention,
+		cluster:   cluster,
+	}
+}
+
+// Collect gathers everything stored about a user. Share link visits are
+// matched against the addresses recorded in the user's audit log entries.
+func (s *PrivacyService) Collect(ctx context.Context, userID int64) (*models.PersonalData, error) {
+	user, err := s.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	data := &models.PersonalData{
+		GeneratedAt: time.Now().UTC(),
+		User:        user,
+	}
+
+	if data.Pages, err = s.db.ListPagesByAuthor(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Revisions, err = s.db.ListRevisionsByAuthor(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.AuditLog, err = s.db.ListAuditEntriesByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.APITokens, err = s.db.ListAPITokensByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	links, err := s.db.GetShareLinksByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		data.ShareLinks = append(data.ShareLinks, models.PersonalShareLink{
+			ID:         l.ID,
+			TargetType: l.TargetType,
+			TargetRef:  l.TargetRef,
+			PageSlug:   l.PageSlug,
+			ViewCount:  l.ViewCount,
+			IsRevoked:  l.IsRevoked,
+			ExpiresAt:  l.ExpiresAt,
+			CreatedAt:  l.CreatedAt,
+		})
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range data.AuditLog {
+		if e.IPAddress != "" && !seen[e.IPAddress] {
+			seen[e.IPAddress] = true
+			data.IPAddresses = append(data.IPAddresses, e.IPAddress)
+		}
+	}
+	if data.ShareAccess, err = s.db.ListShareAccessByIPs(ctx, data.IPAddresses); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// AnonymizeUser anonymizes every IP address recorded for a user, regardless
+// of age, and returns how many rows changed.
+func (s *PrivacyService) AnonymizeUser(ctx context.Context, userID int64) (int64, error) {
+	data, err := s.Collect(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	return s.db.AnonymizeIPs(ctx, data.IPAddresses, AnonymizeIP)
+}
+
+// AnonymizeExpired anonymizes IP addresses older than the retention window.
+func (s *PrivacyService) AnonymizeExpired(ctx context.Context) (int64, error) {
+	if s.retention <= 0 {
+		return 0, nil
+	}
+
+	n, err := s.db.AnonymizeIPsBefore(ctx, time.Now().Add(-s.retention), AnonymizeIP)
+
+	s.mu.Lock()
+	s.lastRun = time.Now()
+	s.lastN = n
+	s.lastErr = err
+	s.mu.Unlock()
+
+	return n, err
+}
+
+// Status reports the retention window and the outcome of the last run.
+func (s *PrivacyService) Status() RetentionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := RetentionStatus{
+		Retention:  s.retention,
+		LastRun:    s.lastRun,
+		Anonymized: s.lastN,
+	}
+	if s.lastErr != nil {
+		status.Error = s.lastErr.Error()
+	}
+	return status
+}
+
+// Job returns the hourly anonymization job, which runs only on the
+// leader.
+func (s *PrivacyService) Job() jobs.Job {
+	return jobs.Job{
+		Name:        "ip-anonymization",
+		Description: "Anonymizes IP addresses in audit and share logs older than the retention period",
+		Interval:    time.Hour,
+		Jitter:      time.Minute,
+		LeaderOnly:  true,
+		Run: func(ctx context.Context) error {
+			_, err := s.AnonymizeExpired(ctx)
+			return err
+		},
+	}
+}
+
+// Enabled reports whether IP addresses are anonymized after a retention
+// period.
+func (s *PrivacyService) Enabled() bool {
+	return s.retention > 0
+}
+
+// AnonymizeIP truncates an address to its /24 (IPv4) or /48 (IPv6) network,
+// keeping it useful for abuse statistics without identifying a person.
+// Unparseable values are dropped.
+func AnonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
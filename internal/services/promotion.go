@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+var (
+	ErrPromotionDisabled = errors.New("promotion is disabled; set WIKI_PROMOTION_KEY on both wikis")
+	ErrBundleLabel       = errors.New("a label is required")
+	ErrBundleEmpty       = errors.New("no pages have this label")
+	ErrBundleSignature   = errors.New("bundle signature is invalid; check that both wikis use the same WIKI_PROMOTION_KEY")
+	ErrInvalidBundle     = errors.New("invalid bundle")
+	ErrBundleApplied     = database.ErrBundleApplied
+)
+
+// Promotion change actions.
+const (
+	PromotionCreate    = "create"
+	PromotionUpdate    = "update"
+	PromotionUnchanged = "unchanged"
+)
+
+// PromotionChange describes what promoting a bundle does to one page.
+type PromotionChange struct {
+	Slug   string `json:"slug"`
+	Title  string `json:"title"`
+	Action string `json:"action"`
+	// Fields lists what an update changes: title, content, tags or published.
+	Fields []string `json:"fields,omitempty"`
+	// Parent is set for empty pages created to hold bundle pages whose
+	// parent doesn't exist yet.
+	Parent bool         `json:"parent,omitempty"`
+	Diff   *ContentDiff `json:"diff,omitempty"`
+}
+
+// PromotionPlan is the preview, or the result, of promoting a bundle.
+type PromotionPlan struct {
+	Label     string            `json:"label"`
+	Source    string            `json:"source"`
+	CreatedBy string            `json:"created_by"`
+	CreatedAt time.Time         `json:"created_at"`
+	Applied   bool              `json:"applied"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Changes   []PromotionChange `json:"changes"`
+}
+
+// PromotionResult is an applied promotion.
+type PromotionResult struct {
+	Plan    *PromotionPlan
+	Created []*models.Page
+	Updated []*models.Page
+}
+
+// PromotionService exports labeled pages as signed bundles and applies
+// bundles exported by another wiki, so documentation changes can move from
+// staging to production like a code release.
+type PromotionService struct {
+	db     *database.DB
+	wiki   *WikiService
+	key    []byte
+	source string
+}
+
+// NewPromotionService creates a promotion service.
+func NewPromotionService(db *database.DB, wiki *WikiService, cfg *config.Config) *PromotionService {
+	return &PromotionService{
+		db:     db,
+		wiki:   wiki,
+		key:    []byte(cfg.Security.PromotionKey),
+		source: cfg.Site.URL,
+	}
+}
+
+// Enabled reports whether a promotion key is configured.
+func (s *PromotionService) Enabled() bool {
+	return len(s.key) > 0
+}
+
+// Export signs the unarchived pages tagged label into a bundle. Pages
+// restricted to groups are left out: groups aren't shared between wikis,
+// so the restriction couldn't follow them.
+func (s *PromotionService) Export(ctx context.Context, label, username string) (*models.SignedBundle, error) {
+	if !s.Enabled() {
+		return nil, ErrPromotionDisabled
+	}
+	label = strings.ToLower(strings.TrimSpace(label))
+	if label == "" {
+		return nil, ErrBundleLabel
+	}
+
+	pages, err := s.db.ListPagesWithTag(ctx, label)
+	if err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, ErrBundleEmpty
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate bundle ID: %w", err)
+	}
+
+	bundle := models.Bundle{
+		Version:   models.BundleVersion,
+		ID:        hex.EncodeToString(id),
+		Label:     label,
+		Source:    s.source,
+		CreatedBy: username,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, p := range pages {
+		tags := make([]string, len(p.Tags))
+		for i, t := range p.Tags {
+			tags[i] = t.Name
+		}
+		bundle.Pages = append(bundle.Pages, models.BundlePage{
+			Slug:        p.Slug,
+			Title:       p.Title,
+			Content:     p.Content,
+			Tags:        tags,
+			IsPublished: p.IsPublished,
+		})
+	}
+
+	payload, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	return &models.SignedBundle{Bundle: payload, Signature: s.sign(payload)}, nil
+}
+
+// Open verifies a signed bundle and checks that its pages can be created
+// on this wiki.
+func (s *PromotionService) Open(data []byte) (*models.Bundle, error) {
+	if !s.Enabled() {
+		return nil, ErrPromotionDisabled
+	}
+
+	var signed models.SignedBundle
+	if err := json.Unmarshal(data, &signed); err != nil || len(signed.Bundle) == 0 {
+		return nil, fmt.Errorf("%w: not a signed bundle", ErrInvalidBundle)
+	}
+	signature, err := hex.DecodeString(signed.Signature)
+	if err != nil || !hmac.Equal(signature, s.mac(signed.Bundle)) {
+		return nil, ErrBundleSignature
+	}
+
+	var bundle models.Bundle
+	if err := json.Unmarshal(signed.Bundle, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if bundle.Version != models.BundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBundle, bundle.Version)
+	}
+	if bundle.ID == "" {
+		return nil, fmt.Errorf("%w: no ID", ErrInvalidBundle)
+	}
+	if len(bundle.Pages) == 0 {
+		return nil, fmt.Errorf("%w: no pages", ErrInvalidBundle)
+	}
+
+	seen := make(map[string]bool, len(bundle.Pages))
+	for _, p := range bundle.Pages {
+		if p.Slug == "" || Slugify(p.Slug) != p.Slug {
+			return nil, fmt.Errorf("%w: invalid slug %q", ErrInvalidBundle, p.Slug)
+		}
+		if seen[p.Slug] {
+			return nil, fmt.Errorf("%w: %s appears twice", ErrInvalidBundle, p.Slug)
+		}
+		seen[p.Slug] = true
+		if strings.TrimSpace(p.Title) == "" {
+			return nil, fmt.Errorf("%w: %s has no title", ErrInvalidBundle, p.Slug)
+		}
+		if err := s.wiki.SlugLimits().Check(p.Slug); err != nil {
+			return nil, err
+		}
+	}
+	return &bundle, nil
+}
+
+// Plan compares a bundle with this wiki's pages. It returns the preview and
+// the page writes that apply it, parents first, or ErrBundleApplied for a
+// bundle this wiki has applied before.
+func (s *PromotionService) Plan(ctx context.Context, bundle *models.Bundle) (*PromotionPlan, []*models.PromotedPage, error) {
+	applied, err := s.db.BundleApplied(ctx, bundle.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if applied {
+		return nil, nil, ErrBundleApplied
+	}
+
+	plan := &PromotionPlan{
+		Label:     bundle.Label,
+		Source:    bundle.Source,
+		CreatedBy: bundle.CreatedBy,
+		CreatedAt: bundle.CreatedAt,
+		Changes:   []PromotionChange{},
+	}
+
+	pages := make([]models.BundlePage, len(bundle.Pages))
+	copy(pages, bundle.Pages)
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Slug < pages[j].Slug })
+
+	inBundle := make(map[string]bool, len(pages))
+	for _, p := range pages {
+		inBundle[p.Slug] = true
+	}
+
+	var writes []*models.PromotedPage
+	planned := make(map[string]bool)
+	for _, p := range pages {
+		// Missing parents are created empty, as CreatePage does
+		parts := strings.Split(p.Slug, "/")
+		for i := 1; i < len(parts); i++ {
+			parentSlug := strings.Join(parts[:i], "/")
+			if inBundle[parentSlug] || planned[parentSlug] {
+				continue
+			}
+			exists, err := s.wiki.PageExists(ctx, parentSlug)
+			if err != nil {
+				return nil, nil, err
+			}
+			planned[parentSlug] = true
+			if exists {
+				continue
+			}
+			title := SlugTitle(parts[i-1])
+			writes = append(writes, &models.PromotedPage{Slug: parentSlug, Title: title, IsPublished: true, CreateOnly: true})
+			plan.Changes = append(plan.Changes, PromotionChange{Slug: parentSlug, Title: title, Action: PromotionCreate, Parent: true})
+			plan.Created++
+		}
+
+		change, err := s.planPage(ctx, p)
+		if err != nil {
+			return nil, nil, err
+		}
+		plan.Changes = append(plan.Changes, *change)
+		switch change.Action {
+		case PromotionCreate:
+			plan.Created++
+		case PromotionUpdate:
+			plan.Updated++
+		default:
+			plan.Unchanged++
+			continue
+		}
+		writes = append(writes, &models.PromotedPage{
+			Slug:        p.Slug,
+			Title:       strings.TrimSpace(p.Title),
+			Content:     p.Content,
+			Tags:        p.Tags,
+			IsPublished: p.IsPublished,
+		})
+	}
+	return plan, writes, nil
+}
+
+// planPage compares a bundle page with the page at its slug.
+func (s *PromotionService) planPage(ctx context.Context, p models.BundlePage) (*PromotionChange, error) {
+	change := &PromotionChange{Slug: p.Slug, Title: strings.TrimSpace(p.Title)}
+
+	existing, err := s.db.GetPageBySlug(ctx, p.Slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page: %w", err)
+	}
+
+	var current string
+	if existing == nil {
+		change.Action = PromotionCreate
+	} else {
+		if existing.IsArchived() {
+			return nil, fmt.Errorf("%w: %s", ErrPageArchived, p.Slug)
+		}
+		current = existing.Content
+
+		tags, err := s.db.GetPageTags(ctx, existing.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get page tags: %w", err)
+		}
+		if existing.Title != change.Title {
+			change.Fields = append(change.Fields, "title")
+		}
+		if existing.Content != p.Content {
+			change.Fields = append(change.Fields, "content")
+		}
+		if !sameTags(tags, p.Tags) {
+			change.Fields = append(change.Fields, "tags")
+		}
+		if existing.IsPublished != p.IsPublished {
+			change.Fields = append(change.Fields, "published")
+		}
+		change.Action = PromotionUnchanged
+		if len(change.Fields) > 0 {
+			change.Action = PromotionUpdate
+		}
+	}
+
+	if current != p.Content {
+		diff, err := DiffContent(p.Slug+" (current)", p.Slug+" (bundle)", current, p.Content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", p.Slug, err)
+		}
+		change.Diff = &diff
+	}
+	return change, nil
+}
+
+// Apply promotes a bundle in one transaction and returns the pages it
+// created or changed.
+func (s *PromotionService) Apply(ctx context.Context, bundle *models.Bundle, userID int64) (*PromotionResult, error) {
+	plan, writes, err := s.Plan(ctx, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range writes {
+		if w.ContentHTML, err = s.wiki.markdown.RenderContext(ctx, w.Slug, w.Content); err != nil {
+			return nil, fmt.Errorf("failed to render %s: %w", w.Slug, err)
+		}
+		w.Excerpt = s.wiki.markdown.Excerpt(w.Content)
+		w.WordCount = s.wiki.markdown.WordCount(w.Content)
+	}
+
+	comment := fmt.Sprintf("Promoted %q from %s", bundle.Label, bundle.Source)
+	if err := s.db.ApplyPromotion(ctx, bundle, userID, comment, writes); err != nil {
+		return nil, err
+	}
+	plan.Applied = true
+
+	result := &PromotionResult{Plan: plan}
+	for _, w := range writes {
+		if w.CreateOnly && !w.Created {
+			continue
+		}
+		page, err := s.db.GetPageByID(ctx, w.ID)
+		if err != nil || page == nil {
+			continue
+		}
+		s.wiki.IndexLinks(ctx, page)
+		if w.Created {
+			result.Created = append(result.Created, page)
+		} else {
+			result.Updated = append(result.Updated, page)
+		}
+	}
+	return result, nil
+}
+
+// sign returns the hex signature of a bundle payload.
+func (s *PromotionService) sign(payload []byte) string {
+	return hex.EncodeToString(s.mac(payload))
+}
+
+func (s *PromotionService) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// sameTags reports whether a page's tags match a bundle's tag names.
+func sameTags(tags []models.Tag, names []string) bool {
+	have := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		have[strings.ToLower(t.Name)] = true
+	}
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			want[name] = true
+		}
+	}
+	if len(have) != len(want) {
+		return false
+	}
+	for name := range want {
+		if !have[name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gowiki/internal/config"
+	"gowiki/internal/models"
+)
+
+func TestPromotion(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	wiki := NewWikiService(db, NewMarkdownService())
+	cfg := &config.Config{Security: config.SecurityConfig{PromotionKey: "a-shared-key-of-at-least-32-characters"}}
+	promotion := NewPromotionService(db, wiki, cfg)
+	user := newTestUser(t, db, "alice")
+
+	group := &models.Group{Name: "staff"}
+	if err := db.CreateGroup(ctx, group); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	for _, slug := range []string{"guide", "internal"} {
+		page := &models.Page{Slug: slug, Title: slug, Content: "v1", AuthorID: user.ID, IsPublished: true}
+		if err := db.CreatePage(ctx, page); err != nil {
+			t.Fatalf("create page: %v", err)
+		}
+		if err := db.SetPageTags(ctx, page.ID, []string{"release"}); err != nil {
+			t.Fatalf("tag page: %v", err)
+		}
+		if slug == "internal" {
+			if err := db.SetPageGroups(ctx, page.ID, []int64{group.ID}); err != nil {
+				t.Fatalf("restrict page: %v", err)
+			}
+		}
+	}
+
+	signed, err := promotion.Export(ctx, "release", user.Username)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	data, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := promotion.Open(data)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if len(bundle.Pages) != 1 || bundle.Pages[0].Slug != "guide" {
+		t.Errorf("bundle pages = %+v, want only the unrestricted guide", bundle.Pages)
+	}
+
+	if _, err := promotion.Apply(ctx, bundle, user.ID); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if _, err := promotion.Apply(ctx, bundle, user.ID); !errors.Is(err, ErrBundleApplied) {
+		t.Errorf("second Apply error = %v, want ErrBundleApplied", err)
+	}
+	if _, _, err := promotion.Plan(ctx, bundle); !errors.Is(err, ErrBundleApplied) {
+		t.Errorf("Plan of an applied bundle error = %v, want ErrBundleApplied", err)
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gowiki/internal/models"
+)
+
+// Page property errors.
+var (
+	ErrInvalidParent     = errors.New("parent must be another existing page outside this one")
+	ErrInvalidOwner      = errors.New("owner must be an active user")
+	ErrInvalidReviewDate = errors.New("review date must be a date like 2025-01-31")
+	ErrDescriptionLength = fmt.Errorf("description must be at most %d characters", maxDescriptionLength)
+	ErrInvalidImageURL   = errors.New("social image must be an http(s) URL or a path on this wiki like /uploads/cover.png")
+)
+
+// reviewDateLayout is the format of review dates in forms and the API.
+const reviewDateLayout = "2006-01-02"
+
+// maxDescriptionLength is about as much as search results and link
+// previews show.
+const maxDescriptionLength = 300
+
+// PageProperties returns the attributes shown in page's properties panel.
+func (s *WikiService) PageProperties(ctx context.Context, page *models.Page) (*models.PageProperties, error) {
+	props := &models.PageProperties{
+		PageID:      page.ID,
+		Slug:        page.Slug,
+		IsPublished: page.IsPublished,
+		Tags:        make([]string, len(page.Tags)),
+	}
+	for i, t := range page.Tags {
+		props.Tags[i] = t.Name
+	}
+	if page.IsScheduled() {
+		props.PublishAt = &page.PublishAt.Time
+	}
+
+	if page.ParentID != nil {
+		parent, err := s.db.GetPageByID(ctx, *page.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent != nil {
+			props.Parent = &models.PropertyPage{ID: parent.ID, Slug: parent.Slug, Title: parent.Title}
+		}
+	}
+
+	owner, reviewAt, err := s.db.GetPageProperties(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+	props.Owner = owner
+	props.ReviewAt = reviewAt
+
+	meta, err := s.db.GetPageMeta(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+	props.Description = meta.Description
+	props.SocialImage = meta.SocialImage
+
+	links, err := s.db.GetShareLinksByPage(ctx, page.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		if links[i].IsValid() {
+			props.Access.ShareLinks++
+		}
+	}
+	props.Access.Restricted = page.IsRestricted()
+	for _, g := range page.Groups {
+		props.Access.Groups = append(props.Access.Groups, g.Name)
+	}
+	if props.Access.Public, err = s.db.InPublicSubtree(ctx, page.Slug); err != nil {
+		return nil, err
+	}
+	props.Access.Summary = accessSummary(page, props.Access.Groups, props.Access.Public)
+
+	return props, nil
+}
+
+// accessSummary describes who can read page in a sentence.
+func accessSummary(page *models.Page, groups []string, public bool) string {
+	var who string
+	switch {
+	case len(groups) == 0 && public:
+		who = "Everyone, including visitors who aren't signed in"
+	case len(groups) == 1:
+		who = "Members of " + groups[0]
+	case len(groups) > 1:
+		who = "Members of " + strings.Join(groups[:len(groups)-1], ", ") + " and " + groups[len(groups)-1]
+	default:
+		who = "Everyone who can read the wiki"
+	}
+	if !page.IsPublished {
+		if len(groups) > 0 {
+			return who + " whose role can view unpublished pages"
+		}
+		return "Roles that can view unpublished pages"
+	}
+	return who
+}
+
+// UpdatePageProperties applies update to page on behalf of userID.
+// Publishing, moving under a new parent and tagging go through UpdatePage,
+// so they follow the same slug limits and cascade to subpages; the owner
+// and review date are stored alongside the page.
+func (s *WikiService) UpdatePageProperties(ctx context.Context, page *models.Page, userID int64, update models.PagePropertiesUpdate) (*UpdateResult, error) {
+	if page.IsArchived() {
+		return nil, ErrPageArchived
+	}
+
+	// Validate everything before changing anything
+	var input models.PageUpdate
+	pageChanged := false
+	if update.IsPublished != nil && *update.IsPublished != page.IsPublished {
+		input.IsPublished = update.IsPublished
+		pageChanged = true
+	}
+	if update.Parent != nil {
+		newSlug, err := s.slugUnderParent(ctx, page, *update.Parent)
+		if err != nil {
+			return nil, err
+		}
+		if newSlug != page.Slug {
+			input.Slug = &newSlug
+			pageChanged = true
+		}
+	}
+	if update.Tags != nil {
+		input.Tags = []string{}
+		for _, tag := range *update.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				input.Tags = append(input.Tags, tag)
+			}
+		}
+		pageChanged = true
+	}
+
+	var ownerID *int64
+	if update.Owner != nil && strings.TrimSpace(*update.Owner) != "" {
+		owner, err := s.db.GetUserByUsername(ctx, strings.TrimSpace(*update.Owner))
+		if err != nil {
+			return nil, err
+		}
+		if owner == nil || !owner.CanSignIn() {
+			return nil, ErrInvalidOwner
+		}
+		ownerID = &owner.ID
+	}
+
+	var reviewAt *time.Time
+	if update.ReviewAt != nil && strings.TrimSpace(*update.ReviewAt) != "" {
+		t, err := time.Parse(reviewDateLayout, strings.TrimSpace(*update.ReviewAt))
+		if err != nil {
+			return nil, ErrInvalidReviewDate
+		}
+		reviewAt = &t
+	}
+
+	var meta models.PageMeta
+	if update.Description != nil || update.SocialImage != nil {
+		current, err := s.db.GetPageMeta(ctx, page.ID)
+		if err != nil {
+			return nil, err
+		}
+		if update.Description != nil {
+			current.Description = *update.Description
+		}
+		if update.SocialImage != nil {
+			current.SocialImage = *update.SocialImage
+		}
+		if meta, err = CleanPageMeta(current); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &UpdateResult{Page: page}
+	if pageChanged {
+		var err error
+		result, err = s.UpdatePage(ctx, page.ID, userID, input, "Updated page properties")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if update.Owner != nil {
+		if err := s.db.SetPageOwner(ctx, page.ID, ownerID); err != nil {
+			return nil, err
+		}
+	}
+	if update.ReviewAt != nil {
+		if err := s.db.SetPageReviewDate(ctx, page.ID, reviewAt); err != nil {
+			return nil, err
+		}
+	}
+	if update.Description != nil || update.SocialImage != nil {
+		if err := s.db.SetPageMeta(ctx, page.ID, meta); err != nil {
+			return nil, err
+		}
+	}
+
+	reloaded, err := s.db.GetPageByID(ctx, page.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload page: %w", err)
+	}
+	result.Page = reloaded
+	return result, nil
+}
+
+// slugUnderParent returns the slug page gets when moved under the page
+// with parentSlug, keeping its last segment. An empty parentSlug moves it
+// to the top level.
+func (s *WikiService) slugUnderParent(ctx context.Context, page *models.Page, parentSlug string) (string, error) {
+	name := page.Slug[strings.LastIndex(page.Slug, "/")+1:]
+	parentSlug = Slugify(parentSlug)
+	if parentSlug == "" {
+		return name, nil
+	}
+	if parentSlug == page.Slug || strings.HasPrefix(parentSlug, page.Slug+"/") {
+		return "", ErrInvalidParent
+	}
+
+	parent, err := s.db.GetPageBySlug(ctx, parentSlug)
+	if err != nil {
+		return "", err
+	}
+	if parent == nil {
+		return "", ErrInvalidParent
+	}
+	return parent.Slug + "/" + name, nil
+}
+
+// PageMeta returns the description and social image set for page.
+func (s *WikiService) PageMeta(ctx context.Context, page *models.Page) (models.PageMeta, error) {
+	return s.db.GetPageMeta(ctx, page.ID)
+}
+
+// SetPageMeta sets page's description and social image, as the editor
+// submits them alongside the content.
+func (s *WikiService) SetPageMeta(ctx context.Context, page *models.Page, meta models.PageMeta) error {
+	meta, err := CleanPageMeta(meta)
+	if err != nil {
+		return err
+	}
+	current, err := s.db.GetPageMeta(ctx, page.ID)
+	if err != nil {
+		return err
+	}
+	if current == meta {
+		return nil
+	}
+	return s.db.SetPageMeta(ctx, page.ID, meta)
+}
+
+// CleanPageMeta trims meta and checks the description fits and the image
+// is a web URL or a path on the wiki. Forms check it before saving.
+func CleanPageMeta(meta models.PageMeta) (models.PageMeta, error) {
+	meta.Description = strings.Join(strings.Fields(meta.Description), " ")
+	meta.SocialImage = strings.TrimSpace(meta.SocialImage)
+	if utf8.RuneCountInString(meta.Description) > maxDescriptionLength {
+		return meta, ErrDescriptionLength
+	}
+	if meta.SocialImage != "" && !validImageURL(meta.SocialImage) {
+		return meta, ErrInvalidImageURL
+	}
+	return meta, nil
+}
+
+func validImageURL(s string) bool {
+	if len(s) > 2048 {
+		return false
+	}
+	if strings.HasPrefix(s, "/") {
+		return !strings.HasPrefix(s, "//")
+	}
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
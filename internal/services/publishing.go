@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gowiki/internal/jobs"
+	"gowiki/internal/models"
+)
+
+// PublishScheduler publishes pages when the time they were scheduled for
+// comes.
+type PublishScheduler struct {
+	wiki     *WikiService
+	webhooks *WebhookService
+}
+
+// NewPublishScheduler creates a publish scheduler. Pages it publishes are
+// announced to webhooks as updated.
+func NewPublishScheduler(wiki *WikiService, webhooks *WebhookService) *PublishScheduler {
+	return &PublishScheduler{wiki: wiki, webhooks: webhooks}
+}
+
+// Job returns the job that publishes due pages every minute.
+func (s *PublishScheduler) Job() jobs.Job {
+	return jobs.Job{
+		Name:        "scheduled-publishing",
+		Description: "Publishes pages whose scheduled time has come",
+		Interval:    time.Minute,
+		LeaderOnly:  true,
+		Run:         s.PublishDue,
+	}
+}
+
+// PublishDue publishes every page scheduled for now or earlier.
+func (s *PublishScheduler) PublishDue(ctx context.Context) error {
+	now := time.Now()
+	ids, err := s.wiki.db.ListDuePages(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		published, err := s.wiki.db.PublishScheduledPage(ctx, id, now)
+		if err != nil {
+			return err
+		}
+		if !published {
+			continue
+		}
+
+		page, err := s.wiki.db.GetPageByID(ctx, id)
+		if err != nil || page == nil {
+			continue
+		}
+		fmt.Printf("Published scheduled page %s\n", page.Slug)
+		s.wiki.Audit(ctx, "page_publish", "page", &page.ID, map[string]interface{}{
+			"slug":      page.Slug,
+			"scheduled": true,
+		})
+		s.webhooks.EmitPage(ctx, models.EventPageUpdated, page, nil)
+	}
+	return nil
+}
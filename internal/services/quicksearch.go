@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// MaxQuickSearchResults caps how many pages a quick search returns.
+const MaxQuickSearchResults = 20
+
+// Match scores for one query word against a title or slug. Slug matches
+// score slugPenalty less than the same title match.
+const (
+	quickScoreExact      = 100
+	quickScorePrefix     = 90
+	quickScoreWordPrefix = 80
+	quickScoreContains   = 60
+	quickScoreFuzzy      = 40
+	quickSlugPenalty     = 5
+)
+
+// quickIndex keeps every page's title and slug in memory for the quick
+// switcher, which matches on nothing else and has to answer on every
+// keystroke. It is rebuilt on the first search after a page write.
+type quickIndex struct {
+	db *database.DB
+
+	mu      sync.RWMutex
+	entries []quickEntry
+	valid   bool
+	// gen changes on every invalidation, so a build that raced a write
+	// doesn't store the pages it read before the write.
+	gen uint64
+}
+
+type quickEntry struct {
+	page  models.Page
+	title string // lowercased
+	slug  string // lowercased
+}
+
+func newQuickIndex(db *database.DB) *quickIndex {
+	idx := &quickIndex{db: db}
+	db.OnPagesChanged(idx.invalidate)
+	return idx
+}
+
+func (idx *quickIndex) invalidate() {
+	idx.mu.Lock()
+	idx.entries, idx.valid = nil, false
+	idx.gen++
+	idx.mu.Unlock()
+}
+
+// load returns the indexed pages, building them if a write dropped them.
+// The slice is shared and must not be modified.
+func (idx *quickIndex) load(ctx context.Context) ([]quickEntry, error) {
+	idx.mu.RLock()
+	entries, valid, gen := idx.entries, idx.valid, idx.gen
+	idx.mu.RUnlock()
+	if valid {
+		return entries, nil
+	}
+
+	pages, err := idx.db.ListPageTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries = make([]quickEntry, len(pages))
+	for i, p := range pages {
+		entries[i] = quickEntry{page: p, title: strings.ToLower(p.Title), slug: strings.ToLower(p.Slug)}
+	}
+
+	idx.mu.Lock()
+	if idx.gen == gen {
+		idx.entries, idx.valid = entries, true
+	}
+	idx.mu.Unlock()
+	return entries, nil
+}
+
+// InvalidateQuickSearch drops the quick switcher's index. Page writes
+// through this process do it themselves; call it when another replica
+// reports a change.
+func (s *WikiService) InvalidateQuickSearch() {
+	s.quick.invalidate()
+}
+
+// QuickSearch matches query against page titles and slugs for the quick
+// switcher: whole words, prefixes of words, substrings, and the letters of
+// a word in order, e.g. "netcfg" for "Network Config". Every word of the
+// query has to match. Archived pages and pages canView rejects are left
+// out. Results are best first, at most limit of them.
+func (s *WikiService) QuickSearch(ctx context.Context, query string, limit int, canView func(*models.Page) bool) ([]models.SearchResult, error) {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return []models.SearchResult{}, nil
+	}
+	if limit <= 0 || limit > MaxQuickSearchResults {
+		limit = MaxQuickSearchResults
+	}
+
+	entries, err := s.quick.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		entry *quickEntry
+		score int
+	}
+	var matches []match
+	for i := range entries {
+		e := &entries[i]
+		if e.page.IsArchived() {
+			continue
+		}
+		score, ok := quickScore(words, e)
+		if !ok || !canView(&e.page) {
+			continue
+		}
+		matches = append(matches, match{e, score})
+	}
+
+	// Best score first, then shorter titles, which the query covers more of
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if len(a.entry.title) != len(b.entry.title) {
+			return len(a.entry.title) < len(b.entry.title)
+		}
+		return a.entry.slug < b.entry.slug
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	results := make([]models.SearchResult, len(matches))
+	for i, m := range matches {
+		results[i] = models.SearchResult{
+			PageID:    m.entry.page.ID,
+			Slug:      m.entry.page.Slug,
+			Title:     m.entry.page.Title,
+			Rank:      float64(m.score),
+			UpdatedAt: m.entry.page.UpdatedAt,
+		}
+	}
+	return results, nil
+}
+
+// quickScore averages how well each query word matches the page's title
+// or slug. It fails if any word matches neither.
+func quickScore(words []string, e *quickEntry) (int, bool) {
+	total := 0
+	for _, word := range words {
+		score := quickMatch(word, e.title)
+		if slug := quickMatch(word, e.slug) - quickSlugPenalty; slug > score {
+			score = slug
+		}
+		if score <= 0 {
+			return 0, false
+		}
+		total += score
+	}
+	score := total / len(words)
+	if len(words) > 1 && strings.Join(words, " ") == e.title {
+		score = quickScoreExact
+	}
+	return score, true
+}
+
+// quickMatch scores one lowercased word against lowercased text, or
+// returns 0 if it doesn't match.
+func quickMatch(word, text string) int {
+	switch {
+	case word == text:
+		return quickScoreExact
+	case strings.HasPrefix(text, word):
+		return quickScorePrefix
+	}
+	if i := strings.Index(text, word); i >= 0 {
+		for ; i >= 0; i = nextIndex(text, word, i) {
+			if isWordStart(text, i) {
+				return quickScoreWordPrefix
+			}
+		}
+		return quickScoreContains
+	}
+
+	// The word's letters in order, losing a point for each letter skipped
+	// in between
+	pos, gaps := -1, 0
+	for _, r := range word {
+		next := strings.IndexRune(text[pos+1:], r)
+		if next < 0 {
+			return 0
+		}
+		if pos >= 0 {
+			gaps += next
+		}
+		pos += next + 1
+	}
+	if gaps >= quickScoreFuzzy {
+		return 1
+	}
+	return quickScoreFuzzy - gaps
+}
+
+// nextIndex returns the next occurrence of word in text after index i, or
+// -1.
+func nextIndex(text, word string, i int) int {
+	j := strings.Index(text[i+1:], word)
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+// isWordStart reports whether a word starts at byte i of text: at the
+// start, or after a space, hyphen, underscore, slash or dot.
+func isWordStart(text string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	return strings.ContainsRune(" -_/.", rune(text[i-1]))
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+// maxRecentlyViewed is how many pages each user's view history keeps.
+const maxRecentlyViewed = 50
+
+// RecordVisit adds a page to a signed-in user's recently viewed pages.
+// Failures only leave the list stale, so they are logged rather than
+// returned.
+func (s *WikiService) RecordVisit(ctx context.Context, userID, pageID int64) {
+	if err := s.db.RecordUserPageView(ctx, userID, pageID, time.Now(), maxRecentlyViewed); err != nil {
+		fmt.Printf("Warning: failed to record page view: %v\n", err)
+	}
+}
+
+// RecentlyViewed returns the pages a user viewed most recently, newest
+// first. filter decides which of them the user may still see, and how
+// many are returned.
+func (s *WikiService) RecentlyViewed(ctx context.Context, userID int64, filter models.PageFilter) ([]models.PageSummary, error) {
+	return s.db.ListRecentlyViewed(ctx, userID, filter)
+}
+
+// BoostRecentlyViewed moves the search results a user viewed recently to
+// the front, most recently viewed first, and keeps the rest in order.
+func (s *WikiService) BoostRecentlyViewed(ctx context.Context, userID int64, results []models.SearchResult) []models.SearchResult {
+	if len(results) < 2 {
+		return results
+	}
+	recent, err := s.db.ListRecentlyViewed(ctx, userID, models.PageFilter{Limit: maxRecentlyViewed})
+	if err != nil || len(recent) == 0 {
+		return results
+	}
+
+	byID := make(map[int64]int, len(results))
+	for i, r := range results {
+		byID[r.PageID] = i
+	}
+	boosted := make([]models.SearchResult, 0, len(results))
+	taken := make(map[int]bool)
+	for _, page := range recent {
+		if i, ok := byID[page.ID]; ok {
+			boosted = append(boosted, results[i])
+			taken[i] = true
+		}
+	}
+	for i, r := range results {
+		if !taken[i] {
+			boosted = append(boosted, r)
+		}
+	}
+	return boosted
+}
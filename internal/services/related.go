@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+const (
+	// DefaultRelatedPages is how many related pages a page shows.
+	DefaultRelatedPages = 5
+	// MaxRelatedPages caps how many related pages a request can ask for.
+	MaxRelatedPages = 20
+	// relatedCandidates is how many scored pages are cached per page, enough
+	// to fill MaxRelatedPages after the viewer's permissions are applied.
+	relatedCandidates = 50
+)
+
+// Related page weights. A shared tag counts for less the more pages have
+// it; a direct link counts for more than pages reached through a shared
+// neighbour, of which at most relatedNeighbourCap count.
+const (
+	relatedTagWeight       = 3.0
+	relatedLinkWeight      = 4.0
+	relatedNeighbourWeight = 1.0
+	relatedNeighbourCap    = 3
+	relatedTitleWeight     = 4.0
+	relatedMinScore        = 1.0
+)
+
+// relatedIndex keeps the tag and link graph of every page in memory to
+// suggest related pages. Like the quick switcher's index it is rebuilt on
+// first use after a page write, and the suggestions for each page are
+// cached until then.
+type relatedIndex struct {
+	db *database.DB
+
+	mu    sync.RWMutex
+	graph *relatedGraph
+	// gen changes on every invalidation, so a build that raced a write
+	// doesn't store the graph it read before the write.
+	gen uint64
+}
+
+// relatedGraph is one build of the index. It is read-only apart from the
+// results cache.
+type relatedGraph struct {
+	pages      map[int64]*models.Page
+	tags       map[int64][]models.Tag
+	tagCounts  map[int64]int
+	links      map[int64]map[int64]bool // both directions
+	titleWords map[int64]map[string]bool
+
+	mu      sync.Mutex
+	results map[int64][]models.RelatedPage
+}
+
+func newRelatedIndex(db *database.DB) *relatedIndex {
+	idx := &relatedIndex{db: db}
+	db.OnPagesChanged(idx.invalidate)
+	return idx
+}
+
+func (idx *relatedIndex) invalidate() {
+	idx.mu.Lock()
+	idx.graph = nil
+	idx.gen++
+	idx.mu.Unlock()
+}
+
+// load returns the current graph, building it if a write dropped it.
+func (idx *relatedIndex) load(ctx context.Context) (*relatedGraph, error) {
+	idx.mu.RLock()
+	graph, gen := idx.graph, idx.gen
+	idx.mu.RUnlock()
+	if graph != nil {
+		return graph, nil
+	}
+
+	pages, err := idx.db.ListPageTitles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := idx.db.ListAllPageTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	edges, err := idx.db.ListLinkEdges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	graph = &relatedGraph{
+		pages:      make(map[int64]*models.Page, len(pages)),
+		tags:       tags,
+		tagCounts:  make(map[int64]int),
+		links:      make(map[int64]map[int64]bool),
+		titleWords: make(map[int64]map[string]bool, len(pages)),
+		results:    make(map[int64][]models.RelatedPage),
+	}
+	for i := range pages {
+		p := &pages[i]
+		graph.pages[p.ID] = p
+		graph.titleWords[p.ID] = titleWords(p.Title)
+	}
+	for _, pageTags := range tags {
+		for _, t := range pageTags {
+			graph.tagCounts[t.ID]++
+		}
+	}
+	for _, e := range edges {
+		graph.link(e.SourceID, e.TargetID)
+		graph.link(e.TargetID, e.SourceID)
+	}
+
+	idx.mu.Lock()
+	if idx.gen == gen {
+		idx.graph = graph
+	}
+	idx.mu.Unlock()
+	return graph, nil
+}
+
+func (g *relatedGraph) link(from, to int64) {
+	if g.links[from] == nil {
+		g.links[from] = make(map[int64]bool)
+	}
+	g.links[from][to] = true
+}
+
+// related returns the best scored pages for pageID, best first, computing
+// them on first use.
+func (g *relatedGraph) related(pageID int64) []models.RelatedPage {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if results, ok := g.results[pageID]; ok {
+		return results
+	}
+
+	var results []models.RelatedPage
+	for id, p := range g.pages {
+		if id == pageID {
+			continue
+		}
+		r := g.score(pageID, id)
+		if r.Score < relatedMinScore {
+			continue
+		}
+		r.ID, r.Slug, r.Title = p.ID, p.Slug, p.Title
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Title < results[j].Title
+	})
+	if len(results) > relatedCandidates {
+		results = results[:relatedCandidates]
+	}
+	g.results[pageID] = results
+	return results
+}
+
+// score rates how related page b is to page a, from their shared tags,
+// the links between them and to common pages, and their titles' words.
+func (g *relatedGraph) score(a, b int64) models.RelatedPage {
+	var r models.RelatedPage
+
+	for _, ta := range g.tags[a] {
+		for _, tb := range g.tags[b] {
+			if ta.ID == tb.ID {
+				r.SharedTags = append(r.SharedTags, ta.Name)
+				r.Score += relatedTagWeight / math.Log2(1+float64(g.tagCounts[ta.ID]))
+			}
+		}
+	}
+
+	if g.links[a][b] {
+		r.Linked = true
+		r.Score += relatedLinkWeight
+	}
+	neighbours := 0
+	for n := range g.links[a] {
+		if n != b && g.links[b][n] {
+			neighbours++
+		}
+	}
+	r.Score += relatedNeighbourWeight * float64(min(neighbours, relatedNeighbourCap))
+
+	wa, wb := g.titleWords[a], g.titleWords[b]
+	if len(wa) > 0 && len(wb) > 0 {
+		shared := 0
+		for w := range wa {
+			if wb[w] {
+				shared++
+			}
+		}
+		r.Score += relatedTitleWeight * float64(shared) / float64(len(wa)+len(wb)-shared)
+	}
+
+	r.Score = math.Round(r.Score*100) / 100
+	return r
+}
+
+// titleWords returns the lowercased words of a title worth comparing:
+// three letters or more, and not among the most common English ones.
+func titleWords(title string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) >= 3 && !titleStopWords[w] {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+var titleStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "how": true,
+	"from": true, "into": true, "about": true, "your": true, "you": true,
+}
+
+// InvalidateRelatedPages drops the related pages index. Page writes
+// through this process do it themselves; call it when another replica
+// reports a change.
+func (s *WikiService) InvalidateRelatedPages() {
+	s.related.invalidate()
+}
+
+// RelatedPages suggests pages related to page through shared tags, links
+// and similar titles, best first. Archived pages and pages canView rejects
+// are left out; limit is capped at MaxRelatedPages.
+func (s *WikiService) RelatedPages(ctx context.Context, page *models.Page, limit int, canView func(*models.Page) bool) ([]models.RelatedPage, error) {
+	if limit <= 0 || limit > MaxRelatedPages {
+		limit = MaxRelatedPages
+	}
+
+	graph, err := s.related.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	related := []models.RelatedPage{}
+	for _, r := range graph.related(page.ID) {
+		p := graph.pages[r.ID]
+		if p.IsArchived() || !canView(p) {
+			continue
+		}
+		related = append(related, r)
+		if len(related) == limit {
+			break
+		}
+	}
+	return related, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// maxRenderCacheBytes bounds the HTML kept by the render cache.
+const maxRenderCacheBytes = 32 << 20
+
+// renderCache is a least-recently-used cache of rendered HTML keyed by a
+// hash of the markdown and the rendering rules, so previews, revisions and
+// saves of unchanged content skip the parser.
+type renderCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // front is most recently used
+	size    int
+	max     int
+}
+
+type renderCacheEntry struct {
+	key  [sha256.Size]byte
+	html string
+}
+
+func newRenderCache(maxBytes int) *renderCache {
+	return &renderCache{
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+		max:     maxBytes,
+	}
+}
+
+// renderCacheKey identifies markdown rendered under version.
+func renderCacheKey(version, markdown string) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(version))
+	h.Write([]byte{0})
+	h.Write([]byte(markdown))
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func (c *renderCache) get(key [sha256.Size]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*renderCacheEntry).html, true
+}
+
+func (c *renderCache) put(key [sha256.Size]byte, html string) {
+	// Pages too big to share the cache with others aren't worth keeping
+	if len(html) > c.max/4 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&renderCacheEntry{key: key, html: html})
+	c.size += len(html)
+
+	for c.size > c.max {
+		oldest := c.order.Back()
+		entry := c.order.Remove(oldest).(*renderCacheEntry)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.html)
+	}
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gowiki/internal/models"
+)
+
+// Find and replace errors.
+var (
+	ErrReplaceEmpty   = errors.New("enter the text to find")
+	ErrReplacePattern = errors.New("invalid regular expression")
+)
+
+const (
+	// maxReplaceSnippets is how many occurrences a preview shows per page.
+	maxReplaceSnippets = 5
+	// replaceSnippetContext is how many bytes of text surround a snippet.
+	replaceSnippetContext = 40
+	// maxReplaceTerm shortens the find and replace text in revision comments.
+	maxReplaceTerm = 80
+)
+
+// Replacer finds and replaces a ReplaceQuery in page content.
+type Replacer struct {
+	query models.ReplaceQuery
+	re    *regexp.Regexp
+}
+
+// NewReplacer compiles query. Patterns that match empty text are refused,
+// since they would insert the replacement between every character.
+func NewReplacer(query models.ReplaceQuery) (*Replacer, error) {
+	if query.Find == "" {
+		return nil, ErrReplaceEmpty
+	}
+
+	pattern := query.Find
+	if !query.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReplacePattern, err)
+	}
+	if !query.CaseSensitive {
+		re = regexp.MustCompile("(?i)" + pattern)
+	}
+	if re.MatchString("") {
+		return nil, fmt.Errorf("%w: the pattern matches empty text", ErrReplacePattern)
+	}
+
+	query.Namespace = strings.Trim(Slugify(query.Namespace), "/")
+	return &Replacer{query: query, re: re}, nil
+}
+
+// Query returns the normalized query.
+func (r *Replacer) Query() models.ReplaceQuery {
+	return r.query
+}
+
+// Comment is the revision comment recorded for pages the replacement changes.
+func (r *Replacer) Comment() string {
+	return fmt.Sprintf("Find and replace: %q → %q", truncateTerm(r.query.Find), truncateTerm(r.query.Replace))
+}
+
+// Apply returns content with every occurrence replaced and how many there were.
+func (r *Replacer) Apply(content string) (string, int) {
+	n := len(r.re.FindAllStringIndex(content, -1))
+	if n == 0 {
+		return content, 0
+	}
+	if r.query.Regex {
+		return r.re.ReplaceAllString(content, r.query.Replace), n
+	}
+	return r.re.ReplaceAllLiteralString(content, r.query.Replace), n
+}
+
+// match finds the occurrences in content and shows the first few in context.
+func (r *Replacer) match(content string) (int, []models.ReplaceSnippet) {
+	found := r.re.FindAllStringSubmatchIndex(content, -1)
+	snippets := make([]models.ReplaceSnippet, 0, min(len(found), maxReplaceSnippets))
+	for _, m := range found[:min(len(found), maxReplaceSnippets)] {
+		replacement := r.query.Replace
+		if r.query.Regex {
+			replacement = string(r.re.ExpandString(nil, r.query.Replace, content, m))
+		}
+		snippets = append(snippets, models.ReplaceSnippet{
+			Before:      snippetBefore(content, m[0]),
+			Match:       content[m[0]:m[1]],
+			Replacement: replacement,
+			After:       snippetAfter(content, m[1]),
+		})
+	}
+	return len(found), snippets
+}
+
+// FindReplaceMatches lists the pages in the query's namespace whose content
+// matches, without changing anything.
+func (s *WikiService) FindReplaceMatches(ctx context.Context, r *Replacer) ([]models.ReplaceMatch, error) {
+	pages, err := s.db.ListPageSources(ctx, r.query.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.ReplaceMatch
+	for _, page := range pages {
+		count, snippets := r.match(page.Content)
+		if count == 0 {
+			continue
+		}
+		matches = append(matches, models.ReplaceMatch{
+			PageID:   page.ID,
+			Slug:     page.Slug,
+			Title:    page.Title,
+			Count:    count,
+			Archived: page.IsArchived(),
+			Snippets: snippets,
+		})
+	}
+	return matches, nil
+}
+
+// ReplaceInPage applies the replacement to page as a new revision by
+// userID. It returns a nil result when the page no longer matches.
+func (s *WikiService) ReplaceInPage(ctx context.Context, page *models.Page, userID int64, r *Replacer) (*UpdateResult, int, error) {
+	content, n := r.Apply(page.Content)
+	if n == 0 {
+		return nil, 0, nil
+	}
+	result, err := s.UpdatePage(ctx, page.ID, userID, models.PageUpdate{Content: &content}, r.Comment())
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, n, nil
+}
+
+// snippetBefore returns up to replaceSnippetContext bytes ending at i,
+// starting on a rune boundary and on a single line.
+func snippetBefore(content string, i int) string {
+	start := max(0, i-replaceSnippetContext)
+	for start < i && !utf8.RuneStart(content[start]) {
+		start++
+	}
+	text := content[start:i]
+	if nl := strings.LastIndexByte(text, '\n'); nl >= 0 {
+		return text[nl+1:]
+	}
+	if start > 0 {
+		return "…" + text
+	}
+	return text
+}
+
+// snippetAfter returns up to replaceSnippetContext bytes starting at i,
+// ending on a rune boundary and on a single line.
+func snippetAfter(content string, i int) string {
+	end := min(len(content), i+replaceSnippetContext)
+	for end > i && end < len(content) && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	text := content[i:end]
+	if nl := strings.IndexByte(text, '\n'); nl >= 0 {
+		return text[:nl]
+	}
+	if end < len(content) {
+		return text + "…"
+	}
+	return text
+}
+
+func truncateTerm(s string) string {
+	if utf8.RuneCountInString(s) <= maxReplaceTerm {
+		return s
+	}
+	return string([]rune(s)[:maxReplaceTerm]) + "…"
+}
@@ -0,0 +1,340 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gowiki/internal/config"
+)
+
+var (
+	ErrReplicationDisabled = errors.New("replication is not configured")
+)
+
+// ReplicationStatus reports the state of continuous replication.
+type ReplicationStatus struct {
+	Enabled    bool          `json:"enabled"`
+	Running    bool          `json:"running"`
+	ReplicaURL string        `json:"replica_url,omitempty"`
+	LastSync   *time.Time    `json:"last_sync,omitempty"`
+	Lag        time.Duration `json:"-"`
+	LagSeconds float64       `json:"lag_seconds"`
+	LastError  string        `json:"last_error,omitempty"`
+	Restarts   int           `json:"restarts"`
+	Healthy    bool          `json:"healthy"`
+}
+
+// ReplicationService runs Litestream alongside the wiki to continuously ship
+// WAL changes to a replica such as S3. With several replicas of the wiki, only
+// the cluster leader runs it so the database is replicated exactly once.
+type ReplicationService struct {
+	cfg        config.ReplicaConfig
+	dbPath     string
+	configPath string
+	cluster    *Cluster
+
+	mu        sync.Mutex
+	cmd       *exec.Cmd
+	exited    chan struct{}
+	lastSync  time.Time
+	lastError string
+	restarts  int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReplicationService creates a replication service. It is inert when no
+// replica URL is configured.
+func NewReplicationService(cfg *config.Config, cluster *Cluster) (*ReplicationService, error) {
+	s := &ReplicationService{
+		cfg:     cfg.Replica,
+		cluster: cluster,
+	}
+	if !s.Enabled() {
+		return s, nil
+	}
+
+	dbPath, err := filepath.Abs(cfg.Database.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	s.dbPath = dbPath
+	s.configPath = dbPath + ".litestream.yml"
+
+	if err := writeLitestreamConfig(s.configPath, dbPath, cfg.Replica); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(cfg.Replica.Binary); err != nil {
+		return nil, fmt.Errorf("litestream binary %q not found: %w", cfg.Replica.Binary, err)
+	}
+
+	return s, nil
+}
+
+// Enabled reports whether a replica URL is configured.
+func (s *ReplicationService) Enabled() bool {
+	return s.cfg.URL != ""
+}
+
+// Start supervises the Litestream process, starting it while this replica is
+// the cluster leader and restarting it if it exits.
+func (s *ReplicationService) Start() {
+	if !s.Enabled() {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			s.supervise()
+
+			select {
+			case <-ticker.C:
+			case <-s.stop:
+				s.terminate()
+				return
+			}
+		}
+	}()
+}
+
+// Stop terminates Litestream, letting it flush pending WAL frames first.
+func (s *ReplicationService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// Status returns the current replication state. Lag is an upper bound: the
+// time since the last confirmed upload, when the database has changed since.
+func (s *ReplicationService) Status() ReplicationStatus {
+	status := ReplicationStatus{Enabled: s.Enabled()}
+	if !status.Enabled {
+		status.Healthy = true
+		return status
+	}
+
+	s.mu.Lock()
+	status.Running = s.cmd != nil
+	status.LastError = s.lastError
+	status.Restarts = s.restarts
+	lastSync := s.lastSync
+	s.mu.Unlock()
+
+	status.ReplicaURL = redactURL(s.cfg.URL)
+	if !lastSync.IsZero() {
+		status.LastSync = &lastSync
+	}
+
+	if modified := s.lastWrite(); modified.After(lastSync) {
+		if lastSync.IsZero() {
+			status.Lag = time.Since(modified)
+		} else {
+			status.Lag = time.Since(lastSync)
+		}
+	}
+	status.LagSeconds = status.Lag.Seconds()
+
+	// Followers don't run Litestream; the leader reports replication health
+	status.Healthy = !s.cluster.IsLeader() || (status.Running && status.Lag <= s.cfg.MaxLag)
+	return status
+}
+
+// supervise starts or stops Litestream to match leadership.
+func (s *ReplicationService) supervise() {
+	s.mu.Lock()
+	running := s.cmd != nil
+	s.mu.Unlock()
+
+	leader := s.cluster.IsLeader()
+	switch {
+	case leader && !running:
+		if err := s.launch(); err != nil {
+			s.recordError(err.Error())
+			fmt.Printf("Warning: failed to start litestream: %v\n", err)
+		}
+	case !leader && running:
+		s.terminate()
+	}
+}
+
+func (s *ReplicationService) launch() error {
+	cmd := exec.Command(s.cfg.Binary, "replicate", "-config", s.configPath)
+	output, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = cmd.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	s.mu.Lock()
+	if s.exited != nil {
+		s.restarts++
+	}
+	s.cmd = cmd
+	s.exited = exited
+	s.mu.Unlock()
+
+	fmt.Printf("Replicating database to %s\n", redactURL(s.cfg.URL))
+
+	go s.watchOutput(output)
+	go func() {
+		err := cmd.Wait()
+		close(exited)
+
+		s.mu.Lock()
+		if s.cmd == cmd {
+			s.cmd = nil
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			s.recordError("litestream exited: " + err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// terminate asks Litestream to shut down and waits for it to sync and exit.
+func (s *ReplicationService) terminate() {
+	s.mu.Lock()
+	cmd, exited := s.cmd, s.exited
+	s.mu.Unlock()
+	if cmd == nil {
+		return
+	}
+
+	_ = cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(30 * time.Second):
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// watchOutput scans Litestream's log for uploads and errors.
+func (s *ReplicationService) watchOutput(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lower := strings.ToLower(line)
+
+		switch {
+		case strings.Contains(lower, "level=error") || strings.Contains(lower, "error:"):
+			s.recordError(line)
+			fmt.Printf("Warning: litestream: %s\n", line)
+		case strings.Contains(lower, "written") && (strings.Contains(lower, "wal segment") || strings.Contains(lower, "snapshot")):
+			s.mu.Lock()
+			s.lastSync = time.Now()
+			s.lastError = ""
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *ReplicationService) recordError(msg string) {
+	s.mu.Lock()
+	s.lastError = msg
+	s.mu.Unlock()
+}
+
+// lastWrite returns the most recent modification time of the database or its WAL.
+func (s *ReplicationService) lastWrite() time.Time {
+	var latest time.Time
+	for _, path := range []string{s.dbPath, s.dbPath + "-wal"} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// RestoreReplica restores the database at dbPath from the configured replica.
+// An existing database is kept alongside as <path>.pre-restore. It must run
+// before the database is opened.
+func RestoreReplica(cfg *config.Config) error {
+	if cfg.Replica.URL == "" {
+		return ErrReplicationDisabled
+	}
+
+	dbPath, err := filepath.Abs(cfg.Database.Path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve database path: %w", err)
+	}
+	configPath := dbPath + ".litestream.yml"
+	if err := writeLitestreamConfig(configPath, dbPath, cfg.Replica); err != nil {
+		return err
+	}
+
+	// Litestream refuses to overwrite, so restore to a temporary file and swap it in
+	tmp := dbPath + ".replica"
+	os.Remove(tmp)
+	cmd := exec.Command(cfg.Replica.Binary, "restore", "-config", configPath, "-o", tmp, dbPath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("litestream restore failed: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			return fmt.Errorf("failed to move existing database aside: %w", err)
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s file: %w", suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return fmt.Errorf("failed to apply restored database: %w", err)
+	}
+	return nil
+}
+
+// writeLitestreamConfig writes the Litestream configuration for one database.
+// Credentials come from the standard AWS/Litestream environment variables.
+func writeLitestreamConfig(path, dbPath string, cfg config.ReplicaConfig) error {
+	content := fmt.Sprintf("dbs:\n  - path: %q\n    replicas:\n      - url: %q\n        sync-interval: %s\n",
+		dbPath, cfg.URL, cfg.SyncInterval)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write litestream config: %w", err)
+	}
+	return nil
+}
+
+// redactURL strips credentials from a replica URL for display.
+func redactURL(raw string) string {
+	if i := strings.Index(raw, "@"); i >= 0 {
+		if j := strings.Index(raw, "://"); j >= 0 && j < i {
+			return raw[:j+3] + "***" + raw[i:]
+		}
+	}
+	return raw
+}
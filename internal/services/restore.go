@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+// RestoreResult summarizes a restore from the markdown backup directory.
+type RestoreResult struct {
+	Restored []string
+	Skipped  []string
+	Failed   []string
+}
+
+// backupFile holds the metadata and content parsed from a markdown backup file.
+type backupFile struct {
+	Path        string
+	Title       string
+	Slug        string
+	Author      string
+	Tags        []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	PublishedAt *time.Time
+	Published   bool
+	Content     string
+}
+
+// RestoreFromBackup walks a BackupService folder tree and recreates the pages it
+// contains, including hierarchy, tags, timestamps, and publish state.
+// Pages whose slug already exists are skipped unless they are empty placeholders.
+// Authors are matched by username and fall back to fallbackAuthorID.
+func (s *WikiService) RestoreFromBackup(ctx context.Context, dir string, fallbackAuthorID int64) (*RestoreResult, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("backup path %s is not a directory", dir)
+	}
+
+	var files []backupFile
+	result := &RestoreResult{}
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(d.Name()), ".md") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			result.Failed = append(result.Failed, path+" (could not read)")
+			return nil
+		}
+
+		file := parseBackupFile(string(content))
+		file.Path = path
+		if file.Slug == "" {
+			// Files without frontmatter get their slug from their place in the tree
+			rel, _ := filepath.Rel(dir, path)
+			file.Slug = Slugify(strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel)))
+		}
+		if file.Title == "" {
+			parts := strings.Split(file.Slug, "/")
+			file.Title = SlugTitle(parts[len(parts)-1])
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk backup directory: %w", err)
+	}
+
+	// Parents first so children can be attached to them
+	sort.Slice(files, func(i, j int) bool {
+		di, dj := strings.Count(files[i].Slug, "/"), strings.Count(files[j].Slug, "/")
+		if di != dj {
+			return di < dj
+		}
+		return files[i].Slug < files[j].Slug
+	})
+
+	authors := make(map[string]int64)
+	for _, file := range files {
+		if err := s.restoreBackupFile(ctx, file, s.resolveAuthor(ctx, authors, file.Author, fallbackAuthorID), result); err != nil {
+			result.Failed = append(result.Failed, file.Path+" ("+err.Error()+")")
+		}
+	}
+
+	return result, nil
+}
+
+// resolveAuthor maps a backup author name to a user ID, caching lookups.
+func (s *WikiService) resolveAuthor(ctx context.Context, cache map[string]int64, username string, fallback int64) int64 {
+	if username == "" {
+		return fallback
+	}
+	if id, ok := cache[username]; ok {
+		return id
+	}
+
+	id := fallback
+	if user, err := s.db.GetUserByUsername(ctx, username); err == nil && user != nil {
+		id = user.ID
+	}
+	cache[username] = id
+	return id
+}
+
+func (s *WikiService) restoreBackupFile(ctx context.Context, file backupFile, authorID int64, result *RestoreResult) error {
+	slug := Slugify(file.Slug)
+	if slug == "" {
+		return ErrInvalidSlug
+	}
+
+	contentHTML, err := s.markdown.RenderContext(ctx, slug, file.Content)
+	if err != nil {
+		return fmt.Errorf("failed to render markdown: %w", err)
+	}
+
+	var publishedAt sql.NullTime
+	if file.PublishedAt != nil {
+		publishedAt = sql.NullTime{Time: *file.PublishedAt, Valid: true}
+	} else if file.Published {
+		publishedAt = sql.NullTime{Time: file.UpdatedAt, Valid: true}
+	}
+
+	existing, err := s.db.GetPageBySlug(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		// Auto-created parent placeholders are filled in; real pages are left alone
+		if strings.TrimSpace(existing.Content) != "" {
+			result.Skipped = append(result.Skipped, slug)
+			return nil
+		}
+
+		existing.Title = file.Title
+		existing.Content = file.Content
+		existing.ContentHTML = contentHTML
+		existing.Excerpt = s.markdown.Excerpt(file.Content)
+		existing.WordCount = s.markdown.WordCount(file.Content)
+		existing.IsPublished = file.Published
+		existing.PublishedAt = publishedAt
+		if err := s.db.UpdatePage(ctx, existing); err != nil {
+			return fmt.Errorf("failed to update page: %w", err)
+		}
+		if err := s.db.SetPageTags(ctx, existing.ID, file.Tags); err != nil {
+			fmt.Printf("Warning: failed to set tags: %v\n", err)
+		}
+		s.createRestoreRevision(ctx, existing.ID, authorID, file.Content)
+		result.Restored = append(result.Restored, slug)
+		return nil
+	}
+
+	var parentID *int64
+	if strings.Contains(slug, "/") {
+		parentID, err = s.ensureParentPages(ctx, authorID, slug)
+		if err != nil {
+			return fmt.Errorf("failed to create parent pages: %w", err)
+		}
+	}
+
+	page := &models.Page{
+		Slug:        slug,
+		Title:       file.Title,
+		Content:     file.Content,
+		ContentHTML: contentHTML,
+		Excerpt:     s.markdown.Excerpt(file.Content),
+		WordCount:   s.markdown.WordCount(file.Content),
+		AuthorID:    authorID,
+		ParentID:    parentID,
+		IsPublished: file.Published,
+		CreatedAt:   file.CreatedAt,
+		UpdatedAt:   file.UpdatedAt,
+		PublishedAt: publishedAt,
+	}
+
+	if err := s.db.RestorePage(ctx, page); err != nil {
+		return err
+	}
+
+	if len(file.Tags) > 0 {
+		if err := s.db.SetPageTags(ctx, page.ID, file.Tags); err != nil {
+			fmt.Printf("Warning: failed to set tags: %v\n", err)
+		}
+	}
+
+	s.createRestoreRevision(ctx, page.ID, authorID, file.Content)
+	result.Restored = append(result.Restored, slug)
+	return nil
+}
+
+func (s *WikiService) createRestoreRevision(ctx context.Context, pageID, authorID int64, content string) {
+	revision := &models.Revision{
+		PageID:   pageID,
+		Content:  content,
+		AuthorID: authorID,
+		Comment:  "Restored from backup",
+	}
+	if err := s.db.CreateRevision(ctx, revision); err != nil {
+		fmt.Printf("Warning: failed to create restore revision: %v\n", err)
+	}
+}
+
+// parseBackupFile parses the frontmatter written by BackupService.SavePageAsMarkdown.
+// Files without frontmatter are treated as published content with no metadata.
+func parseBackupFile(content string) backupFile {
+	now := time.Now().UTC()
+	file := backupFile{
+		Published: true,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Content:   content,
+	}
+
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return file
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return file
+	}
+
+	for _, line := range lines[1:end] {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "title":
+			file.Title = unquoteFrontmatter(value)
+		case "slug":
+			file.Slug = unquoteFrontmatter(value)
+		case "author":
+			file.Author = unquoteFrontmatter(value)
+		case "tags":
+			for _, tag := range strings.Split(strings.Trim(value, "[]"), ",") {
+				if tag = unquoteFrontmatter(strings.TrimSpace(tag)); tag != "" {
+					file.Tags = append(file.Tags, tag)
+				}
+			}
+		case "created_at":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				file.CreatedAt = t.UTC()
+			}
+		case "updated_at":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				file.UpdatedAt = t.UTC()
+			}
+		case "published_at":
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				t = t.UTC()
+				file.PublishedAt = &t
+			}
+		case "published":
+			if b, err := strconv.ParseBool(value); err == nil {
+				file.Published = b
+			}
+		}
+	}
+
+	// SavePageAsMarkdown writes a blank line after the closing delimiter
+	file.Content = strings.TrimPrefix(strings.Join(lines[end+1:], "\n"), "\n")
+	return file
+}
+
+// unquoteFrontmatter strips the Go-style quoting used when writing frontmatter values.
+func unquoteFrontmatter(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return strings.Trim(value, "\"'")
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/jobs"
+)
+
+// RevisionPruner deletes page revisions that fall outside the retention
+// policy, always keeping each page's newest revisions.
+type RevisionPruner struct {
+	db      *database.DB
+	cfg     config.RevisionConfig
+	cluster *Cluster
+
+	mu      sync.Mutex
+	lastRun time.Time
+	lastErr error
+	lastN   int64
+}
+
+// RevisionPruneStatus describes the retention policy and the last run.
+type RevisionPruneStatus struct {
+	MaxCount   int
+	MaxAge     time.Duration
+	KeepLatest int
+	LastRun    time.Time
+	Pruned     int64
+	Error      string
+}
+
+// NewRevisionPruner creates a revision pruner. With several replicas,
+// scheduled pruning runs only on the cluster leader.
+func NewRevisionPruner(db *database.DB, cfg *config.Config, cluster *Cluster) *RevisionPruner {
+	return &RevisionPruner{
+		db:      db,
+		cfg:     cfg.Revision,
+		cluster: cluster,
+	}
+}
+
+// Enabled reports whether a retention limit is configured.
+func (s *RevisionPruner) Enabled() bool {
+	return s.cfg.MaxCount > 0 || s.cfg.MaxAge > 0
+}
+
+// Prune applies the retention policy to every page and records the run in
+// the audit log. userID and ipAddress identify who triggered a manual run
+// and are empty for scheduled runs, which are only logged when they
+// deleted something.
+func (s *RevisionPruner) Prune(ctx context.Context, trigger string, userID *int64, ipAddress string) (int64, error) {
+	var cutoff time.Time
+	if s.cfg.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.cfg.MaxAge)
+	}
+
+	pruned, err := s.db.PruneRevisions(ctx, 0, s.cfg.KeepLatest, s.cfg.MaxCount, cutoff)
+	n := sumPruned(pruned)
+
+	s.mu.Lock()
+	s.lastRun = time.Now()
+	s.lastN = n
+	s.lastErr = err
+	s.mu.Unlock()
+
+	if err != nil {
+		s.audit("revisions_prune_failed", nil, userID, map[string]interface{}{
+			"trigger": trigger,
+			"error":   err.Error(),
+		}, ipAddress)
+		return 0, err
+	}
+	if n > 0 || userID != nil {
+		s.audit("revisions_prune", nil, userID, map[string]interface{}{
+			"trigger": trigger,
+			"pruned":  n,
+			"pages":   len(pruned),
+		}, ipAddress)
+	}
+	return n, nil
+}
+
+// Compact deletes all but a page's newest revisions, as many as the policy
+// always keeps, and records it in the audit log.
+func (s *RevisionPruner) Compact(ctx context.Context, pageID int64, userID *int64, ipAddress string) (int64, error) {
+	pruned, err := s.db.PruneRevisions(ctx, pageID, s.cfg.KeepLatest, s.cfg.KeepLatest, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	n := sumPruned(pruned)
+	s.audit("revisions_compact", &pageID, userID, map[string]interface{}{
+		"pruned": n,
+		"kept":   s.cfg.KeepLatest,
+	}, ipAddress)
+	return n, nil
+}
+
+// Status reports the retention policy and the outcome of the last run.
+func (s *RevisionPruner) Status() RevisionPruneStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := RevisionPruneStatus{
+		MaxCount:   s.cfg.MaxCount,
+		MaxAge:     s.cfg.MaxAge,
+		KeepLatest: s.cfg.KeepLatest,
+		LastRun:    s.lastRun,
+		Pruned:     s.lastN,
+	}
+	if s.lastErr != nil {
+		status.Error = s.lastErr.Error()
+	}
+	return status
+}
+
+// Job returns the hourly pruning job, which runs only on the leader.
+func (s *RevisionPruner) Job() jobs.Job {
+	return jobs.Job{
+		Name:        "revision-pruning",
+		Description: "Deletes page revisions outside the retention policy",
+		Interval:    time.Hour,
+		Jitter:      time.Minute,
+		LeaderOnly:  true,
+		Run: func(ctx context.Context) error {
+			_, err := s.Prune(ctx, "scheduled", nil, "")
+			return err
+		},
+	}
+}
+
+func (s *RevisionPruner) audit(action string, pageID, userID *int64, details map[string]interface{}, ipAddress string) {
+	var detailsStr string
+	if b, err := json.Marshal(details); err == nil {
+		detailsStr = string(b)
+	}
+	entityType := "system"
+	if pageID != nil {
+		entityType = "page"
+	}
+	if err := s.db.LogAudit(context.Background(), userID, action, entityType, pageID, detailsStr, ipAddress); err != nil {
+		fmt.Printf("Warning: Failed to write audit log: %v\n", err)
+	}
+}
+
+func sumPruned(pruned map[int64]int64) int64 {
+	var n int64
+	for _, count := range pruned {
+		n += count
+	}
+	return n
+}
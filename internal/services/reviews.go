@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+)
+
+var (
+	ErrReviewNotFound   = errors.New("review request not found")
+	ErrReviewerNotFound = errors.New("reviewer not found")
+	ErrNotReviewer      = errors.New("only the requested reviewer can approve")
+)
+
+// mentionPattern matches @username where username follows the registration rules.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([a-zA-Z][a-zA-Z0-9_-]{2,31})`)
+
+// ExtractMentions returns the unique usernames mentioned in text, in order of appearance.
+func ExtractMentions(text string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(m[1])
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// NotifyMentions notifies every existing user mentioned in an edit comment
+// who can view the page. The author is never notified of their own
+// mention. Returns the usernames notified.
+func (s *WikiService) NotifyMentions(ctx context.Context, page *models.Page, actor *models.User, comment string) []string {
+	var notified []string
+	for _, name := range ExtractMentions(comment) {
+		user, err := s.db.GetUserByUsername(ctx, name)
+		if err != nil || user == nil || !user.IsActive || user.ID == actor.ID || !policy.CanView(user, page) {
+			continue
+		}
+
+		n := &models.Notification{
+			UserID:  user.ID,
+			ActorID: &actor.ID,
+			PageID:  &page.ID,
+			Kind:    models.NotificationMention,
+			Message: comment,
+		}
+		if err := s.db.CreateNotification(ctx, n); err != nil {
+			fmt.Printf("Warning: failed to create mention notification: %v\n", err)
+			continue
+		}
+		notified = append(notified, user.Username)
+	}
+	return notified
+}
+
+// RequestReview asks reviewerID to review the latest changes to a page.
+// Reviewers must be able to edit the page.
+func (s *WikiService) RequestReview(ctx context.Context, page *models.Page, requester *models.User, reviewerID int64, comment string) (*models.ReviewRequest, error) {
+	reviewer, err := s.db.GetUserByID(ctx, reviewerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviewer: %w", err)
+	}
+	if reviewer == nil || !reviewer.IsActive || !policy.CanEdit(reviewer, page) || reviewer.ID == requester.ID {
+		return nil, ErrReviewerNotFound
+	}
+
+	req := &models.ReviewRequest{
+		PageID:      page.ID,
+		RequesterID: requester.ID,
+		ReviewerID:  reviewer.ID,
+		Comment:     comment,
+	}
+	if err := s.db.CreateReviewRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	n := &models.Notification{
+		UserID:  reviewer.ID,
+		ActorID: &requester.ID,
+		PageID:  &page.ID,
+		Kind:    models.NotificationReviewRequest,
+		Message: comment,
+	}
+	if err := s.db.CreateNotification(ctx, n); err != nil {
+		fmt.Printf("Warning: failed to create review notification: %v\n", err)
+	}
+
+	return req, nil
+}
+
+// ApproveReview approves a pending review request and notifies the requester.
+func (s *WikiService) ApproveReview(ctx context.Context, requestID int64, reviewer *models.User) (*models.ReviewRequest, error) {
+	req, err := s.db.GetReviewRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, ErrReviewNotFound
+	}
+	if req.ReviewerID != reviewer.ID {
+		return nil, ErrNotReviewer
+	}
+	if req.Status != models.ReviewPending {
+		return req, nil
+	}
+
+	if err := s.db.ApproveReviewRequest(ctx, requestID); err != nil {
+		return nil, fmt.Errorf("failed to approve review request: %w", err)
+	}
+	req.Status = models.ReviewApproved
+
+	n := &models.Notification{
+		UserID:  req.RequesterID,
+		ActorID: &reviewer.ID,
+		PageID:  &req.PageID,
+		Kind:    models.NotificationReviewApproved,
+	}
+	if err := s.db.CreateNotification(ctx, n); err != nil {
+		fmt.Printf("Warning: failed to create approval notification: %v\n", err)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+var (
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("a role with that name already exists")
+	ErrRoleName     = errors.New("role names are 2-32 lowercase letters, digits, dashes or underscores")
+	ErrRoleBuiltIn  = errors.New("built-in roles cannot be deleted")
+	ErrRoleInUse    = errors.New("role is assigned to users; reassign them first")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{1,31}$`)
+
+// RoleInput contains the editable fields of a role.
+type RoleInput struct {
+	Name        models.Role         `json:"name"`
+	Description string              `json:"description"`
+	Permissions []models.Permission `json:"permissions"`
+}
+
+// RoleService manages the roles table and keeps the in-memory registry
+// that models.Role permission checks read in sync with it.
+type RoleService struct {
+	db *database.DB
+	mu sync.Mutex
+}
+
+// NewRoleService creates a role service.
+func NewRoleService(db *database.DB) *RoleService {
+	return &RoleService{db: db}
+}
+
+// Load reads the roles table into the registry. It runs at startup and
+// after every change.
+func (s *RoleService) Load(ctx context.Context) error {
+	roles, err := s.db.ListRoles(ctx)
+	if err != nil {
+		return err
+	}
+	models.SetRoles(roles)
+	return nil
+}
+
+// List returns every role with its user count.
+func (s *RoleService) List(ctx context.Context) ([]models.RoleDefinition, error) {
+	return s.db.ListRoles(ctx)
+}
+
+// Get returns a role by name.
+func (s *RoleService) Get(ctx context.Context, name models.Role) (*models.RoleDefinition, error) {
+	roles, err := s.db.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		if strings.EqualFold(string(roles[i].Name), string(name)) {
+			return &roles[i], nil
+		}
+	}
+	return nil, ErrRoleNotFound
+}
+
+// Create validates and adds a custom role.
+func (s *RoleService) Create(ctx context.Context, input RoleInput) (*models.RoleDefinition, error) {
+	name := models.Role(strings.ToLower(strings.TrimSpace(string(input.Name))))
+	if !roleNamePattern.MatchString(string(name)) {
+		return nil, ErrRoleName
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.Get(ctx, name); err == nil {
+		return nil, ErrRoleExists
+	} else if !errors.Is(err, ErrRoleNotFound) {
+		return nil, err
+	}
+
+	role := &models.RoleDefinition{
+		Name:        name,
+		Description: strings.TrimSpace(input.Description),
+		Permissions: validPermissions(input.Permissions),
+	}
+	if err := s.db.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, s.Load(ctx)
+}
+
+// Update replaces a role's description and permissions. The admin role's
+// permissions are fixed.
+func (s *RoleService) Update(ctx context.Context, input RoleInput) (*models.RoleDefinition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, err := s.Get(ctx, input.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Admin always keeps every permission so the wiki can't be locked out
+	role.Description = strings.TrimSpace(input.Description)
+	if role.Name != models.RoleAdmin {
+		role.Permissions = validPermissions(input.Permissions)
+	}
+	if err := s.db.UpdateRole(ctx, role); err != nil {
+		return nil, err
+	}
+	return role, s.Load(ctx)
+}
+
+// Delete removes a custom role that no user is assigned to.
+func (s *RoleService) Delete(ctx context.Context, name models.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, err := s.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+	if role.UserCount > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.db.DeleteRole(ctx, role.Name); err != nil {
+		return err
+	}
+	return s.Load(ctx)
+}
+
+// validPermissions drops unknown and repeated permissions and orders the
+// rest as models.Permissions lists them.
+func validPermissions(perms []models.Permission) []models.Permission {
+	requested := make(map[models.Permission]bool, len(perms))
+	for _, p := range perms {
+		requested[p] = true
+	}
+
+	var valid []models.Permission
+	for _, info := range models.Permissions {
+		if requested[info.Permission] {
+			valid = append(valid, info.Permission)
+		}
+	}
+	return valid
+}
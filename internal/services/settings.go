@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+// Settings the admin dashboard changes at runtime. Unset ones fall back to
+// the configuration.
+const (
+	SettingSiteName                 = "site_name"
+	SettingRequireAuth              = "require_auth"
+	SettingAllowRegistration        = "allow_registration"
+	SettingRequireEmailVerification = "require_email_verification"
+	SettingDefaultRole              = "default_role"
+	SettingMath                     = "math_enabled"
+	SettingUploadMaxSize            = "upload_max_size"      // bytes
+	SettingUploadAllowedTypes       = "upload_allowed_types" // comma-separated
+)
+
+var (
+	ErrSettingDefaultRole   = errors.New("the default role must exist and can't administer the wiki")
+	ErrSettingUploadMaxSize = errors.New("the upload size limit must be positive")
+)
+
+// SiteSettings are the runtime settings, as the admin dashboard edits them.
+type SiteSettings struct {
+	SiteName                 string
+	RequireAuth              bool
+	AllowRegistration        bool
+	RequireEmailVerification bool
+	DefaultRole              string
+	Math                     bool
+	UploadMaxSize            int64
+	UploadAllowedTypes       []string
+}
+
+// SettingsService reads the settings the admin can change without a
+// restart, falling back to the configuration for unset ones. Reads go
+// through the database's settings cache, so every handler and middleware
+// sees a change as soon as it is saved here or announced by another
+// replica.
+type SettingsService struct {
+	db  *database.DB
+	cfg *config.Config
+
+	mu       sync.RWMutex
+	watchers map[string][]func()
+
+	// last holds the values last read, for when the database can't be read
+	lastMu sync.Mutex
+	last   map[string]string
+}
+
+// NewSettingsService creates a settings service.
+func NewSettingsService(db *database.DB, cfg *config.Config) *SettingsService {
+	return &SettingsService{db: db, cfg: cfg, watchers: make(map[string][]func()), last: make(map[string]string)}
+}
+
+// lookup returns a setting, or "" when it is unset. When the settings
+// can't be read, the value last read is returned instead, and ok is false
+// if there is none.
+func (s *SettingsService) lookup(ctx context.Context, key string) (value string, ok bool) {
+	value, err := s.db.GetSetting(ctx, key)
+	s.lastMu.Lock()
+	defer s.lastMu.Unlock()
+	if err == nil {
+		s.last[key] = value
+		return value, true
+	}
+	value, ok = s.last[key]
+	return value, ok
+}
+
+// get returns a setting, or "" when it is unset or has never been read.
+func (s *SettingsService) get(ctx context.Context, key string) string {
+	value, _ := s.lookup(ctx, key)
+	return value
+}
+
+func (s *SettingsService) getBool(ctx context.Context, key string, defaultValue bool) bool {
+	if b, err := strconv.ParseBool(s.get(ctx, key)); err == nil {
+		return b
+	}
+	return defaultValue
+}
+
+// getGuardBool reads a setting that guards access like getBool, except
+// that when it can't be read and never has been, it fails closed with the
+// safe value rather than the configured default.
+func (s *SettingsService) getGuardBool(ctx context.Context, key string, defaultValue, safe bool) bool {
+	value, ok := s.lookup(ctx, key)
+	if !ok {
+		return safe
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return defaultValue
+}
+
+// SiteName returns the wiki's name.
+func (s *SettingsService) SiteName(ctx context.Context) string {
+	if name := s.get(ctx, SettingSiteName); name != "" {
+		return name
+	}
+	return s.cfg.Site.Name
+}
+
+// RequireAuth reports whether the wiki is private. If that can't be told,
+// it is.
+func (s *SettingsService) RequireAuth(ctx context.Context) bool {
+	return s.getGuardBool(ctx, SettingRequireAuth, s.cfg.Site.RequireAuth, true)
+}
+
+// AllowRegistration reports whether visitors can create accounts. If that
+// can't be told, they can't.
+func (s *SettingsService) AllowRegistration(ctx context.Context) bool {
+	return s.getGuardBool(ctx, SettingAllowRegistration, s.cfg.Site.AllowRegistration, false)
+}
+
+// RequireEmailVerification reports whether new users confirm their address
+// before signing in. If that can't be told, they do.
+func (s *SettingsService) RequireEmailVerification(ctx context.Context) bool {
+	return s.getGuardBool(ctx, SettingRequireEmailVerification, s.cfg.Site.RequireEmailVerification, true)
+}
+
+// DefaultRole returns the role new users get.
+func (s *SettingsService) DefaultRole(ctx context.Context) models.Role {
+	if role := models.Role(s.get(ctx, SettingDefaultRole)); role.IsValid() {
+		return role
+	}
+	return models.Role(s.cfg.Site.DefaultRole)
+}
+
+// Math reports whether math formulas are typeset.
+func (s *SettingsService) Math(ctx context.Context) bool {
+	return s.getBool(ctx, SettingMath, s.cfg.Site.Math)
+}
+
+// UploadMaxSize returns the largest upload allowed, in bytes.
+func (s *SettingsService) UploadMaxSize(ctx context.Context) int64 {
+	if n, err := strconv.ParseInt(s.get(ctx, SettingUploadMaxSize), 10, 64); err == nil && n > 0 {
+		return n
+	}
+	return s.cfg.Upload.MaxSize
+}
+
+// UploadAllowedTypes returns the MIME types uploads may have.
+func (s *SettingsService) UploadAllowedTypes(ctx context.Context) []string {
+	if value := s.get(ctx, SettingUploadAllowedTypes); value != "" {
+		return splitList(value)
+	}
+	return s.cfg.Upload.AllowedTypes
+}
+
+// Site returns every runtime setting.
+func (s *SettingsService) Site(ctx context.Context) SiteSettings {
+	return SiteSettings{
+		SiteName:                 s.SiteName(ctx),
+		RequireAuth:              s.RequireAuth(ctx),
+		AllowRegistration:        s.AllowRegistration(ctx),
+		RequireEmailVerification: s.RequireEmailVerification(ctx),
+		DefaultRole:              string(s.DefaultRole(ctx)),
+		Math:                     s.Math(ctx),
+		UploadMaxSize:            s.UploadMaxSize(ctx),
+		UploadAllowedTypes:       s.UploadAllowedTypes(ctx),
+	}
+}
+
+// Save validates and stores the runtime settings. An empty site name or
+// list of upload types keeps the current one.
+func (s *SettingsService) Save(ctx context.Context, site SiteSettings) error {
+	role := models.Role(site.DefaultRole)
+	if !role.IsValid() || role.CanAdmin() {
+		return ErrSettingDefaultRole
+	}
+	if site.UploadMaxSize <= 0 {
+		return ErrSettingUploadMaxSize
+	}
+
+	values := []struct{ key, value string }{
+		{SettingRequireAuth, strconv.FormatBool(site.RequireAuth)},
+		{SettingAllowRegistration, strconv.FormatBool(site.AllowRegistration)},
+		{SettingRequireEmailVerification, strconv.FormatBool(site.RequireEmailVerification)},
+		{SettingDefaultRole, string(role)},
+		{SettingMath, strconv.FormatBool(site.Math)},
+		{SettingUploadMaxSize, strconv.FormatInt(site.UploadMaxSize, 10)},
+	}
+	if name := strings.TrimSpace(site.SiteName); name != "" {
+		values = append(values, struct{ key, value string }{SettingSiteName, name})
+	}
+	if types := splitList(strings.Join(site.UploadAllowedTypes, ",")); len(types) > 0 {
+		values = append(values, struct{ key, value string }{SettingUploadAllowedTypes, strings.Join(types, ",")})
+	}
+
+	for _, v := range values {
+		if v.value == s.get(ctx, v.key) {
+			continue
+		}
+		if err := s.db.SetSetting(ctx, v.key, v.value); err != nil {
+			return fmt.Errorf("failed to save %s: %w", v.key, err)
+		}
+	}
+	return nil
+}
+
+// OnChange registers fn to be called after the setting key changes, here
+// or on another replica.
+func (s *SettingsService) OnChange(key string, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watchers[key] = append(s.watchers[key], fn)
+}
+
+// Changed runs the OnChange functions for key. Call it once the settings
+// cache no longer holds the old value.
+func (s *SettingsService) Changed(key string) {
+	s.mu.RLock()
+	watchers := s.watchers[key]
+	s.mu.RUnlock()
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,40 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gowiki/internal/config"
+)
+
+func TestSettingsFailClosed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	cfg := &config.Config{Site: config.SiteConfig{AllowRegistration: true}}
+
+	if err := db.SetSetting(ctx, SettingRequireAuth, "false"); err != nil {
+		t.Fatal(err)
+	}
+	settings := NewSettingsService(db, cfg)
+	if settings.RequireAuth(ctx) {
+		t.Fatal("RequireAuth = true, want the stored false")
+	}
+
+	// Every read fails from here on
+	db.InvalidateSettings()
+	db.Close()
+
+	if settings.RequireAuth(ctx) {
+		t.Error("RequireAuth = true after a failed read, want the false read before")
+	}
+
+	unread := NewSettingsService(db, cfg)
+	if !unread.RequireAuth(ctx) {
+		t.Error("RequireAuth = false when never read, want true")
+	}
+	if unread.AllowRegistration(ctx) {
+		t.Error("AllowRegistration = true when never read, want false")
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+)
+
+const (
+	// maxShareRecipients caps how many addresses a new share link is
+	// emailed to.
+	maxShareRecipients = 20
+	// shareQRSize is the width and height of share link QR codes, in
+	// pixels.
+	shareQRSize = 256
+)
+
+var (
+	// ErrInvalidShareUpdate is returned for negative share link limits or
+	// expiry.
+	ErrInvalidShareUpdate     = errors.New("share link limits and expiry can't be negative")
+	ErrTooManyShareRecipients = fmt.Errorf("a share link can be emailed to at most %d addresses", maxShareRecipients)
+	// ErrShareNotAllowed is returned when restoring a share link or
+	// extending it to subpages would share what the user no longer may.
+	ErrShareNotAllowed = errors.New("you can no longer share this with the link's audience")
+)
+
+// UpdateShareLink changes a share link's settings in place, so the URL
+// already handed out keeps working, and audits what changed. link is
+// updated to match. Restoring a revoked link or extending it to subpages
+// shares more, so user must still be allowed to share it as it is now.
+func (s *WikiService) UpdateShareLink(ctx context.Context, user *models.User, link *models.ShareLink, update models.ShareLinkUpdate) error {
+	for _, n := range []*int{update.MaxViews, update.MaxIPs} {
+		if n != nil && *n < 0 {
+			return ErrInvalidShareUpdate
+		}
+	}
+	if update.ExpiresIn != nil && *update.ExpiresIn < 0 {
+		return ErrInvalidShareUpdate
+	}
+
+	restored := update.Revoked != nil && !*update.Revoked && link.IsRevoked
+	widened := update.IncludeChildren != nil && *update.IncludeChildren && !link.IncludeChildren
+	if restored || widened {
+		allowed, err := s.canReshare(ctx, user, link)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return ErrShareNotAllowed
+		}
+	}
+
+	changes := make(map[string]interface{})
+	if update.IncludeChildren != nil && *update.IncludeChildren != link.IncludeChildren {
+		link.IncludeChildren = *update.IncludeChildren
+		changes["include_children"] = link.IncludeChildren
+	}
+	if update.MaxViews != nil && !sameLimit(link.MaxViews, *update.MaxViews) {
+		link.MaxViews = shareLimit(*update.MaxViews)
+		changes["max_views"] = *update.MaxViews
+	}
+	if update.MaxIPs != nil && !sameLimit(link.MaxIPs, *update.MaxIPs) {
+		link.MaxIPs = shareLimit(*update.MaxIPs)
+		changes["max_ips"] = *update.MaxIPs
+	}
+	if update.ExpiresIn != nil {
+		link.ExpiresAt = nil
+		changes["expires_at"] = "never"
+		if *update.ExpiresIn > 0 {
+			t := time.Now().UTC().Add(*update.ExpiresIn)
+			link.ExpiresAt = &t
+			changes["expires_at"] = t
+		}
+	}
+	if update.Revoked != nil && *update.Revoked != link.IsRevoked {
+		link.IsRevoked = *update.Revoked
+		changes["revoked"] = link.IsRevoked
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := s.db.UpdateShareLink(ctx, link); err != nil {
+		return err
+	}
+	for k, v := range ShareAuditDetails(link) {
+		changes[k] = v
+	}
+	s.Audit(ctx, "share_update", "share_link", &link.ID, changes)
+	return nil
+}
+
+// canReshare reports whether user may share what link shares, with its
+// audience, given the page and groups as they are now.
+func (s *WikiService) canReshare(ctx context.Context, user *models.User, link *models.ShareLink) (bool, error) {
+	var group *models.Group
+	if link.GroupID != nil {
+		g, err := s.db.GetGroup(ctx, *link.GroupID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get group: %w", err)
+		}
+		if g == nil {
+			return false, nil
+		}
+		group = g
+	}
+
+	if link.IsCollection() {
+		return policy.CanShareCollection(user, group), nil
+	}
+	page, err := s.db.GetPageByID(ctx, link.PageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get page: %w", err)
+	}
+	return page != nil && policy.CanShareWith(user, page, group), nil
+}
+
+// ShareAuditDetails identifies what link shares in audit entries: its page,
+// or the tag or search of a collection.
+func ShareAuditDetails(link *models.ShareLink) map[string]interface{} {
+	if link.IsCollection() {
+		return map[string]interface{}{
+			"target_type": link.TargetType,
+			"target_ref":  link.TargetRef,
+		}
+	}
+	return map[string]interface{}{"page_id": link.PageID}
+}
+
+// shareLimit converts a limit where zero means none to a share link's.
+func shareLimit(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+// sameLimit reports whether a share link's limit is already n, where zero
+// means none.
+func sameLimit(limit *int, n int) bool {
+	if limit == nil {
+		return n == 0
+	}
+	return *limit == n
+}
+
+// ParseShareRecipients parses the addresses to email a share link to,
+// separated by commas, semicolons or new lines. Duplicates are dropped.
+func ParseShareRecipients(list string) ([]string, error) {
+	fields := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ';' || r == '\n' || r == '\r'
+	})
+
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEmailRecipient, field)
+		}
+		if key := strings.ToLower(addr.Address); !seen[key] {
+			seen[key] = true
+			recipients = append(recipients, addr.Address)
+		}
+	}
+	if len(recipients) > maxShareRecipients {
+		return nil, ErrTooManyShareRecipients
+	}
+	return recipients, nil
+}
+
+// EmailShareLink sends a new share link's URL to each recipient, with an
+// optional message from the sharer. It returns the addresses it couldn't
+// send to.
+func (s *MailService) EmailShareLink(ctx context.Context, recipients []string, sharedBy, title, url, message string) []string {
+	var failed []string
+	for _, to := range recipients {
+		err := s.SendTemplate(ctx, models.EmailShareLink, "", to, map[string]string{
+			"SharedBy": sharedBy,
+			"Title":    title,
+			"Message":  message,
+			"Link":     url,
+		})
+		if err != nil {
+			fmt.Printf("Warning: failed to email share link to %s: %v\n", to, err)
+			failed = append(failed, to)
+		}
+	}
+	return failed
+}
+
+// ShareQRCode renders a share link's URL as a QR code PNG, for opening it
+// on a phone or printing it.
+func ShareQRCode(url string) ([]byte, error) {
+	return qrcode.Encode(url, qrcode.Medium, shareQRSize)
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gowiki/internal/models"
+)
+
+// Settings naming the pages that stand in for the built-in home dashboard
+// and the sidebar's page tree. Unset means the built-in one.
+const (
+	SettingHomePage    = "home_page"
+	SettingSidebarPage = "sidebar_page"
+)
+
+var sitePageSettings = []string{SettingHomePage, SettingSidebarPage}
+
+// SitePage returns the page a setting such as SettingHomePage names, or
+// nil when none is set or the page no longer exists.
+func (s *WikiService) SitePage(ctx context.Context, setting string) (*models.Page, error) {
+	slug, err := s.db.GetSetting(ctx, setting)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", setting, err)
+	}
+	if slug == "" {
+		return nil, nil
+	}
+	page, err := s.db.GetPageBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page: %w", err)
+	}
+	return page, nil
+}
+
+// SetSitePage points a setting such as SettingHomePage at the page with
+// slug, which must exist. An empty slug restores the built-in one.
+func (s *WikiService) SetSitePage(ctx context.Context, setting, slug string) error {
+	if slug = Slugify(slug); slug != "" {
+		page, err := s.db.GetPageBySlug(ctx, slug)
+		if err != nil {
+			return fmt.Errorf("failed to get page: %w", err)
+		}
+		if page == nil {
+			return ErrPageNotFound
+		}
+	}
+	return s.db.SetSetting(ctx, setting, slug)
+}
+
+// followSitePages keeps the home and sidebar settings pointing at their
+// pages when a page moves, taking its subpages along.
+func (s *WikiService) followSitePages(ctx context.Context, oldSlug, newSlug string) {
+	for _, setting := range sitePageSettings {
+		slug, err := s.db.GetSetting(ctx, setting)
+		if err != nil || slug == "" {
+			continue
+		}
+		if slug != oldSlug && !strings.HasPrefix(slug, oldSlug+"/") {
+			continue
+		}
+		if err := s.db.SetSetting(ctx, setting, newSlug+strings.TrimPrefix(slug, oldSlug)); err != nil {
+			fmt.Printf("Warning: failed to update %s: %v\n", setting, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// SlugLimits caps how deeply pages nest and how long their slugs get.
+// Very deep slugs crowd breadcrumbs, and long ones overflow path limits
+// when backups are written to disk on Windows. Zero means no limit.
+type SlugLimits struct {
+	MaxDepth  int
+	MaxLength int
+}
+
+// SlugDepth returns how many levels a slug has; "a/b/c" has three.
+func SlugDepth(slug string) int {
+	if slug == "" {
+		return 0
+	}
+	return strings.Count(slug, "/") + 1
+}
+
+// SlugTitle turns one slug segment into a title for pages that have none,
+// e.g. "getting-started" becomes "Getting Started".
+func SlugTitle(segment string) string {
+	return cases.Title(language.Und).String(strings.ReplaceAll(segment, "-", " "))
+}
+
+// Check returns ErrSlugTooDeep or ErrSlugTooLong, wrapped with the limit,
+// if slug exceeds the limits.
+func (l SlugLimits) Check(slug string) error {
+	if depth := SlugDepth(slug); l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Errorf("%w: %s has %d levels, at most %d are allowed", ErrSlugTooDeep, slug, depth, l.MaxDepth)
+	}
+	if l.MaxLength > 0 && len(slug) > l.MaxLength {
+		return fmt.Errorf("%w: %s has %d characters, at most %d are allowed", ErrSlugTooLong, slug, len(slug), l.MaxLength)
+	}
+	return nil
+}
+
+// Exceeds reports whether slug is beyond either limit.
+func (l SlugLimits) Exceeds(slug string) bool {
+	return l.Check(slug) != nil
+}
+
+// Suggest proposes a slug within the limits for one that exceeds them. Too
+// deep slugs keep their top levels and their last segment, so the page
+// moves up next to its closest allowed ancestor. Too long slugs have their
+// last segment shortened. The suggestion may still collide with another
+// page, so it is only a starting point for the admin.
+func (l SlugLimits) Suggest(slug string) string {
+	segments := strings.Split(slug, "/")
+	if l.MaxDepth > 0 && len(segments) > l.MaxDepth {
+		last := segments[len(segments)-1]
+		segments = append(segments[:l.MaxDepth-1], last)
+	}
+
+	suggestion := strings.Join(segments, "/")
+	if l.MaxLength > 0 && len(suggestion) > l.MaxLength {
+		last := segments[len(segments)-1]
+		keep := len(last) - (len(suggestion) - l.MaxLength)
+		if keep < 1 {
+			// The parent path alone is too long; shortening it is up to the admin.
+			return suggestion
+		}
+		segments[len(segments)-1] = strings.TrimRight(last[:keep], "-")
+		suggestion = strings.Join(segments, "/")
+	}
+	return suggestion
+}
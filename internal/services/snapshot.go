@@ -0,0 +1,430 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+)
+
+var (
+	ErrSnapshotNotFound   = errors.New("snapshot not found")
+	ErrSnapshotInProgress = errors.New("a snapshot is already running")
+)
+
+const snapshotTimeFormat = "20060102-150405"
+
+// snapshotNamePattern matches files created by the scheduler; anything else
+// in the snapshot directory is ignored and never served or deleted.
+var snapshotNamePattern = regexp.MustCompile(`^wiki-(\d{8}-\d{6})\.db$`)
+
+// Snapshot describes a database snapshot on disk.
+type Snapshot struct {
+	Name      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// BackupScheduler periodically snapshots the SQLite database into a directory
+// and prunes old snapshots according to the retention policy.
+type BackupScheduler struct {
+	db      *database.DB
+	cfg     config.SnapshotConfig
+	dbPath  string
+	cluster *Cluster
+	// replicated is set when Litestream replicates the database; it
+	// checkpoints the write-ahead log itself
+	replicated bool
+
+	mu      sync.Mutex
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBackupScheduler creates a new BackupScheduler. With several replicas,
+// scheduled snapshots run only on the cluster leader.
+func NewBackupScheduler(db *database.DB, cfg *config.Config, cluster *Cluster) (*BackupScheduler, error) {
+	if err := os.MkdirAll(cfg.Snapshot.Path, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	return &BackupScheduler{
+		db:      db,
+		cfg:     cfg.Snapshot,
+		dbPath:  cfg.Database.Path,
+		cluster: cluster,
+
+		replicated: cfg.Replica.URL != "",
+	}, nil
+}
+
+// Enabled reports whether scheduled snapshots are turned on.
+func (s *BackupScheduler) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// Interval returns the configured time between scheduled snapshots.
+func (s *BackupScheduler) Interval() time.Duration {
+	return s.cfg.Interval
+}
+
+// Retention returns the number of daily and weekly snapshots kept.
+func (s *BackupScheduler) Retention() (daily, weekly int) {
+	return s.cfg.KeepDaily, s.cfg.KeepWeekly
+}
+
+// Start begins the background snapshot loop. It is a no-op when scheduling is disabled.
+func (s *BackupScheduler) Start() {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		// Catch up immediately if the newest snapshot is older than one interval
+		if s.cluster.IsLeader() && s.isDue() {
+			s.runScheduled()
+		}
+
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.runScheduled()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop and waits for an in-flight snapshot to finish.
+func (s *BackupScheduler) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+func (s *BackupScheduler) isDue() bool {
+	snapshots, err := s.List()
+	if err != nil || len(snapshots) == 0 {
+		return true
+	}
+	return time.Since(snapshots[0].CreatedAt) >= s.cfg.Interval
+}
+
+func (s *BackupScheduler) runScheduled() {
+	if !s.cluster.IsLeader() {
+		return
+	}
+	if _, err := s.Run(context.Background(), "scheduled", nil, ""); err != nil {
+		fmt.Printf("Warning: Scheduled snapshot failed: %v\n", err)
+	}
+}
+
+// Run takes a snapshot now, applies retention, and records the run in the audit log.
+// userID and ipAddress identify who triggered a manual run and are empty for scheduled runs.
+func (s *BackupScheduler) Run(ctx context.Context, trigger string, userID *int64, ipAddress string) (*Snapshot, error) {
+	if !s.begin() {
+		return nil, ErrSnapshotInProgress
+	}
+	defer s.end()
+
+	// Other replicas share the snapshot directory, so only one may write at a time
+	var snapshot *Snapshot
+	var pruned []string
+	start := time.Now()
+	err := s.cluster.WithLock(ctx, "snapshot", time.Minute, func() error {
+		var err error
+		snapshot, pruned, err = s.snapshot(ctx)
+		return err
+	})
+	if errors.Is(err, ErrLockHeld) {
+		return nil, ErrSnapshotInProgress
+	}
+
+	details := map[string]interface{}{
+		"trigger":     trigger,
+		"duration_ms": time.Since(start).Milliseconds(),
+	}
+	action := "snapshot_create"
+	if err != nil {
+		action = "snapshot_failed"
+		details["error"] = err.Error()
+	} else {
+		details["name"] = snapshot.Name
+		details["size"] = snapshot.Size
+		details["pruned"] = pruned
+	}
+	s.audit(action, userID, details, ipAddress)
+
+	return snapshot, err
+}
+
+// begin marks a snapshot or maintenance operation as running. It reports
+// false when one already is, since they lock the database against each other.
+func (s *BackupScheduler) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return false
+	}
+	s.running = true
+	return true
+}
+
+func (s *BackupScheduler) end() {
+	s.mu.Lock()
+	s.running = false
+	s.mu.Unlock()
+}
+
+func (s *BackupScheduler) snapshot(ctx context.Context) (*Snapshot, []string, error) {
+	now := time.Now().UTC()
+	name := "wiki-" + now.Format(snapshotTimeFormat) + ".db"
+	path := filepath.Join(s.cfg.Path, name)
+
+	// Write to a temp name so a partial file is never listed as a snapshot
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	if err := s.db.VacuumInto(ctx, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return nil, nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, nil, fmt.Errorf("failed to finalize snapshot: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+
+	pruned, err := s.Prune()
+	if err != nil {
+		fmt.Printf("Warning: Failed to prune snapshots: %v\n", err)
+	}
+
+	return &Snapshot{Name: name, Size: info.Size(), CreatedAt: now}, pruned, nil
+}
+
+// List returns all snapshots, newest first.
+func (s *BackupScheduler) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		m := snapshotNamePattern.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		createdAt, err := time.Parse(snapshotTimeFormat, m[1])
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+
+	return snapshots, nil
+}
+
+// Prune deletes snapshots not covered by the retention policy and returns their names.
+// The newest snapshot of each of the last KeepDaily days and KeepWeekly ISO weeks is
+// kept, as is the most recent snapshot overall. Zero for both disables pruning.
+func (s *BackupScheduler) Prune() ([]string, error) {
+	if s.cfg.KeepDaily == 0 && s.cfg.KeepWeekly == 0 {
+		return nil, nil
+	}
+
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	for i, snap := range snapshots {
+		if i == 0 {
+			keep[snap.Name] = true
+		}
+
+		day := snap.CreatedAt.Format("2006-01-02")
+		if !days[day] && len(days) < s.cfg.KeepDaily {
+			days[day] = true
+			keep[snap.Name] = true
+		}
+
+		year, wk := snap.CreatedAt.ISOWeek()
+		week := fmt.Sprintf("%d-%02d", year, wk)
+		if !weeks[week] && len(weeks) < s.cfg.KeepWeekly {
+			weeks[week] = true
+			keep[snap.Name] = true
+		}
+	}
+
+	var pruned []string
+	for _, snap := range snapshots {
+		if keep[snap.Name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.cfg.Path, snap.Name)); err != nil && !os.IsNotExist(err) {
+			return pruned, fmt.Errorf("failed to delete snapshot %s: %w", snap.Name, err)
+		}
+		pruned = append(pruned, snap.Name)
+	}
+
+	return pruned, nil
+}
+
+// Path returns the on-disk path of a snapshot, validating the name.
+func (s *BackupScheduler) Path(name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", ErrSnapshotNotFound
+	}
+	path := filepath.Join(s.cfg.Path, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrSnapshotNotFound
+	}
+	return path, nil
+}
+
+// Delete removes a snapshot.
+func (s *BackupScheduler) Delete(name string) error {
+	path, err := s.Path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete snapshot: %w", err)
+	}
+	return nil
+}
+
+// StageRestore copies a snapshot next to the live database so it replaces it
+// on the next startup. The running database is never overwritten in place.
+func (s *BackupScheduler) StageRestore(name string) error {
+	src, err := s.Path(name)
+	if err != nil {
+		return err
+	}
+
+	if err := copyFile(src, restorePath(s.dbPath)); err != nil {
+		return fmt.Errorf("failed to stage restore: %w", err)
+	}
+	return nil
+}
+
+// PendingRestore reports whether a restore has been staged for the next startup.
+func (s *BackupScheduler) PendingRestore() bool {
+	_, err := os.Stat(restorePath(s.dbPath))
+	return err == nil
+}
+
+// CancelRestore removes a staged restore.
+func (s *BackupScheduler) CancelRestore() error {
+	if err := os.Remove(restorePath(s.dbPath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to cancel restore: %w", err)
+	}
+	return nil
+}
+
+// ApplyPendingRestore swaps a staged snapshot in for the database at dbPath.
+// It must run before the database is opened. Returns true if a restore was applied.
+func ApplyPendingRestore(dbPath string) (bool, error) {
+	staged := restorePath(dbPath)
+	if _, err := os.Stat(staged); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	// Stale WAL files belong to the old database and would corrupt the restored one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove %s file: %w", suffix, err)
+		}
+	}
+
+	if err := os.Rename(staged, dbPath); err != nil {
+		return false, fmt.Errorf("failed to apply restore: %w", err)
+	}
+	return true, nil
+}
+
+func (s *BackupScheduler) audit(action string, userID *int64, details map[string]interface{}, ipAddress string) {
+	var detailsStr string
+	if b, err := json.Marshal(details); err == nil {
+		detailsStr = string(b)
+	}
+	if err := s.db.LogAudit(context.Background(), userID, action, "system", nil, detailsStr, ipAddress); err != nil {
+		fmt.Printf("Warning: Failed to write audit log: %v\n", err)
+	}
+}
+
+func restorePath(dbPath string) string {
+	return dbPath + ".restore"
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
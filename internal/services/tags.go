@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"gowiki/internal/models"
+)
+
+var (
+	ErrTagNotFound  = errors.New("tag not found")
+	ErrTagExists    = errors.New("a tag with that name already exists; merge the tags instead")
+	ErrTagName      = errors.New("tag names are 1-50 characters without commas")
+	ErrTagInUse     = errors.New("the tag is still on pages; merge it into another tag instead")
+	ErrTagParent    = errors.New("a tag can't fall under itself or a tag under it")
+	ErrTagMergeSelf = errors.New("a tag can't be merged into itself")
+)
+
+// maxTagNameLength matches the limit on tags typed into the page editor.
+const maxTagNameLength = 50
+
+// GetTag returns the tag with the name, with its page count and parent.
+func (s *WikiService) GetTag(ctx context.Context, name string) (*models.Tag, error) {
+	tag, err := s.db.GetTag(ctx, strings.TrimSpace(name))
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+// GetTagByID returns a tag by ID, with its page count and parent.
+func (s *WikiService) GetTagByID(ctx context.Context, id int64) (*models.Tag, error) {
+	tag, err := s.db.GetTagByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+// RenameTag renames tag on every page that has it. Tags are lowercased, as
+// the page editor saves them. Renaming onto another tag's name fails with
+// ErrTagExists; MergeTags combines them.
+func (s *WikiService) RenameTag(ctx context.Context, tag *models.Tag, name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || utf8.RuneCountInString(name) > maxTagNameLength || strings.Contains(name, ",") {
+		return ErrTagName
+	}
+	if name == tag.Name {
+		return nil
+	}
+	existing, err := s.db.GetTag(ctx, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != tag.ID {
+		return ErrTagExists
+	}
+
+	if err := s.db.RenameTag(ctx, tag.ID, name); err != nil {
+		return err
+	}
+	s.Audit(ctx, "tag_rename", "tag", &tag.ID, map[string]interface{}{
+		"from": tag.Name,
+		"to":   name,
+	})
+	tag.Name = name
+	return nil
+}
+
+// SetTagParent files tag under parent, or at the top level for a nil
+// parent. A tag can't fall under itself or one of the tags under it.
+func (s *WikiService) SetTagParent(ctx context.Context, tag, parent *models.Tag) error {
+	var parentID *int64
+	var parentName string
+	if parent != nil {
+		cycle, err := s.tagWithin(ctx, parent, tag.ID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrTagParent
+		}
+		parentID, parentName = &parent.ID, parent.Name
+	}
+	if sameParent(tag.ParentID, parentID) {
+		return nil
+	}
+
+	if err := s.db.SetTagParent(ctx, tag.ID, parentID); err != nil {
+		return err
+	}
+	s.Audit(ctx, "tag_parent", "tag", &tag.ID, map[string]interface{}{
+		"name":   tag.Name,
+		"parent": parentName,
+	})
+	tag.ParentID, tag.Parent = parentID, parentName
+	return nil
+}
+
+// tagWithin reports whether tag is the tag with ancestorID or is filed
+// somewhere under it.
+func (s *WikiService) tagWithin(ctx context.Context, tag *models.Tag, ancestorID int64) (bool, error) {
+	for t := tag; t != nil; {
+		if t.ID == ancestorID {
+			return true, nil
+		}
+		if t.ParentID == nil {
+			return false, nil
+		}
+		next, err := s.db.GetTagByID(ctx, *t.ParentID)
+		if err != nil {
+			return false, err
+		}
+		t = next
+	}
+	return false, nil
+}
+
+// sameParent reports whether two optional parent tag IDs are the same.
+func sameParent(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// MergeTags moves every page tagged from to into, along with the tags
+// filed under from, and deletes from.
+func (s *WikiService) MergeTags(ctx context.Context, from, into *models.Tag) error {
+	if from.ID == into.ID {
+		return ErrTagMergeSelf
+	}
+	// into can't stay under from, which is going away, or the tags
+	// between them would end up under into and into under them
+	under, err := s.tagWithin(ctx, into, from.ID)
+	if err != nil {
+		return err
+	}
+	if under {
+		if err := s.db.SetTagParent(ctx, into.ID, from.ParentID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.MergeTags(ctx, from.ID, into.ID); err != nil {
+		return err
+	}
+	s.Audit(ctx, "tag_merge", "tag", &into.ID, map[string]interface{}{
+		"from":  from.Name,
+		"into":  into.Name,
+		"pages": from.PageCount,
+	})
+	return nil
+}
+
+// DeleteTag deletes a tag no page has. Tags filed under it move to the
+// top level.
+func (s *WikiService) DeleteTag(ctx context.Context, tag *models.Tag) error {
+	if tag.PageCount > 0 {
+		return ErrTagInUse
+	}
+	if err := s.db.DeleteTag(ctx, tag.ID); err != nil {
+		return err
+	}
+	s.Audit(ctx, "tag_delete", "tag", &tag.ID, map[string]interface{}{
+		"name": tag.Name,
+	})
+	return nil
+}
+
+// DeleteUnusedTags deletes every tag no page has and no tag is filed
+// under, and returns how many it deleted.
+func (s *WikiService) DeleteUnusedTags(ctx context.Context) (int64, error) {
+	n, err := s.db.DeleteUnusedTags(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		s.Audit(ctx, "tag_prune", "tag", nil, map[string]interface{}{
+			"deleted": n,
+		})
+	}
+	return n, nil
+}
+
+// TagChildren returns the tags filed directly under tag, from tags.
+func TagChildren(tags []models.Tag, tag *models.Tag) []models.Tag {
+	var children []models.Tag
+	for _, t := range tags {
+		if t.ParentID != nil && *t.ParentID == tag.ID {
+			children = append(children, t)
+		}
+	}
+	return children
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowiki/internal/models"
+)
+
+// Settings holding the admin's customization of the wiki's look.
+const (
+	SettingThemeAccent   = "theme_accent"
+	SettingThemeCSS      = "theme_css"
+	SettingThemeLogo     = "theme_logo" // base64
+	SettingThemeLogoType = "theme_logo_type"
+	SettingThemeVersion  = "theme_version"
+)
+
+// Limits on theme customizations. Settings are cached in memory, so the
+// logo is kept small.
+const (
+	MaxThemeCSSSize  = 64 << 10
+	MaxThemeLogoSize = 256 << 10
+)
+
+var (
+	ErrThemeAccent = errors.New("accent color must be a hex color like #2563eb")
+	ErrThemeCSS    = fmt.Errorf("custom CSS must be at most %d KB", MaxThemeCSSSize>>10)
+	ErrThemeLogo   = fmt.Errorf("logo must be a PNG, JPEG, GIF or WebP image of at most %d KB", MaxThemeLogoSize>>10)
+)
+
+var accentPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// logoTypes are the logo formats browsers can show without running
+// anything; SVG is left out because it can carry scripts.
+var logoTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// SiteTheme returns the admin's customization of the wiki's look.
+func (s *WikiService) SiteTheme(ctx context.Context) (models.SiteTheme, error) {
+	var theme models.SiteTheme
+	var logo string
+	for key, dst := range map[string]*string{
+		SettingThemeAccent:  &theme.Accent,
+		SettingThemeCSS:     &theme.CSS,
+		SettingThemeLogo:    &logo,
+		SettingThemeVersion: &theme.Version,
+	} {
+		value, err := s.db.GetSetting(ctx, key)
+		if err != nil {
+			return models.SiteTheme{}, fmt.Errorf("failed to get theme: %w", err)
+		}
+		*dst = value
+	}
+	theme.HasLogo = logo != ""
+	return theme, nil
+}
+
+// bumpThemeVersion records that the theme changed, so browsers fetch the
+// new stylesheet and logo.
+func (s *WikiService) bumpThemeVersion(ctx context.Context) error {
+	return s.db.SetSetting(ctx, SettingThemeVersion, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+// SaveTheme sets the accent color and custom CSS. Empty values restore
+// the built-in look.
+func (s *WikiService) SaveTheme(ctx context.Context, accent, css string) error {
+	accent = strings.ToLower(strings.TrimSpace(accent))
+	if accent != "" && !accentPattern.MatchString(accent) {
+		return ErrThemeAccent
+	}
+	if len(css) > MaxThemeCSSSize {
+		return ErrThemeCSS
+	}
+	if err := s.db.SetSetting(ctx, SettingThemeAccent, accent); err != nil {
+		return err
+	}
+	if err := s.db.SetSetting(ctx, SettingThemeCSS, strings.TrimSpace(css)); err != nil {
+		return err
+	}
+	return s.bumpThemeVersion(ctx)
+}
+
+// SetThemeLogo replaces the logo with an uploaded image, or removes it
+// when data is empty.
+func (s *WikiService) SetThemeLogo(ctx context.Context, data []byte) error {
+	contentType := ""
+	if len(data) > 0 {
+		contentType = http.DetectContentType(data)
+		if len(data) > MaxThemeLogoSize || !logoTypes[contentType] {
+			return ErrThemeLogo
+		}
+	}
+	if err := s.db.SetSetting(ctx, SettingThemeLogoType, contentType); err != nil {
+		return err
+	}
+	if err := s.db.SetSetting(ctx, SettingThemeLogo, base64.StdEncoding.EncodeToString(data)); err != nil {
+		return err
+	}
+	return s.bumpThemeVersion(ctx)
+}
+
+// ThemeLogo returns the uploaded logo and its content type, or nil when
+// there is none.
+func (s *WikiService) ThemeLogo(ctx context.Context) ([]byte, string, error) {
+	encoded, err := s.db.GetSetting(ctx, SettingThemeLogo)
+	if err != nil || encoded == "" {
+		return nil, "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode logo: %w", err)
+	}
+	contentType, err := s.db.GetSetting(ctx, SettingThemeLogoType)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentType, nil
+}
+
+// ThemeStylesheet returns the CSS theme adds to the built-in styles: the
+// primary color scale derived from the accent, for both color schemes,
+// then the admin's own CSS.
+func ThemeStylesheet(theme models.SiteTheme) string {
+	var b strings.Builder
+	if rgb, ok := parseAccent(theme.Accent); ok {
+		light := []struct {
+			shade string
+			color [3]float64
+		}{
+			{"50", mixColor(rgb, white, 0.93)},
+			{"100", mixColor(rgb, white, 0.85)},
+			{"200", mixColor(rgb, white, 0.7)},
+			{"400", mixColor(rgb, white, 0.3)},
+			{"500", mixColor(rgb, white, 0.15)},
+			{"600", rgb},
+			{"700", mixColor(rgb, black, 0.2)},
+		}
+		b.WriteString(":root {\n")
+		for _, v := range light {
+			fmt.Fprintf(&b, "  --color-primary-%s: %s;\n", v.shade, hexColor(v.color))
+		}
+		b.WriteString("}\n\n")
+
+		// Dark mode reads light shades on dark backgrounds
+		dark := []struct {
+			shade string
+			color [3]float64
+		}{
+			{"50", mixColor(rgb, black, 0.65)},
+			{"100", rgb},
+			{"400", mixColor(rgb, white, 0.45)},
+			{"500", mixColor(rgb, white, 0.45)},
+			{"600", mixColor(rgb, white, 0.65)},
+			{"700", mixColor(rgb, white, 0.8)},
+		}
+		b.WriteString("[data-theme=\"dark\"] {\n")
+		for _, v := range dark {
+			fmt.Fprintf(&b, "  --color-primary-%s: %s;\n", v.shade, hexColor(v.color))
+		}
+		b.WriteString("}\n")
+	}
+	if theme.CSS != "" {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(theme.CSS)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var (
+	white = [3]float64{255, 255, 255}
+	black = [3]float64{0, 0, 0}
+)
+
+func parseAccent(accent string) ([3]float64, bool) {
+	var rgb [3]float64
+	if !accentPattern.MatchString(accent) {
+		return rgb, false
+	}
+	for i := range rgb {
+		v, _ := strconv.ParseUint(accent[1+2*i:3+2*i], 16, 8)
+		rgb[i] = float64(v)
+	}
+	return rgb, true
+}
+
+// mixColor blends weight of with into c.
+func mixColor(c, with [3]float64, weight float64) [3]float64 {
+	var out [3]float64
+	for i := range c {
+		out[i] = c[i]*(1-weight) + with[i]*weight
+	}
+	return out
+}
+
+func hexColor(c [3]float64) string {
+	return fmt.Sprintf("#%02x%02x%02x", int(c[0]+0.5), int(c[1]+0.5), int(c[2]+0.5))
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gowiki/internal/database"
+)
+
+// LargeUploadSize is the size from which the upload report lists a file
+// as large.
+const LargeUploadSize = 10 << 20
+
+// ErrUploadInUse is returned when deleting an upload something links to.
+var ErrUploadInUse = errors.New("the file is still linked from the wiki")
+
+// StoredUpload is a file in the upload directory.
+type StoredUpload struct {
+	Name       string
+	Size       int64
+	ModTime    time.Time
+	Hash       string // SHA-256 of the content, hex encoded
+	Referenced bool   // Linked from a page, revision, draft, setting or email template
+}
+
+// DuplicateUploads are stored files with the same content.
+type DuplicateUploads struct {
+	Hash  string
+	Files []StoredUpload // Oldest first; the first is kept when merging
+	// Reclaimable is the space freed by merging the files into one.
+	Reclaimable int64
+}
+
+// UploadReport summarizes the upload directory for reclaiming space.
+type UploadReport struct {
+	Files      int
+	TotalSize  int64
+	Duplicates []DuplicateUploads // Most reclaimable space first
+	Large      []StoredUpload     // At least LargeUploadSize, largest first
+}
+
+// InspectUploads reports the duplicates and large files in the upload
+// directory dir. Files that are hard links to one another count as one.
+func InspectUploads(ctx context.Context, db *database.DB, dir string) (*UploadReport, error) {
+	files, err := listUploads(dir)
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := newUploadHashes(ctx, db, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	err = db.ScanUploadReferences(ctx, func(text string) {
+		for _, m := range uploadReferencePattern.FindAllStringSubmatch(text, -1) {
+			referenced[m[1]] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UploadReport{}
+	byHash := make(map[string][]StoredUpload)
+	seen := make(fileSet)
+	for _, info := range files {
+		report.Files++
+		if !seen.add(info) {
+			continue
+		}
+		report.TotalSize += info.Size()
+
+		upload := StoredUpload{
+			Name:       info.Name(),
+			Size:       info.Size(),
+			ModTime:    info.ModTime(),
+			Referenced: referenced[info.Name()],
+		}
+		if upload.Hash, err = hashes.hash(info.Name()); err != nil {
+			return nil, err
+		}
+		byHash[upload.Hash] = append(byHash[upload.Hash], upload)
+		if upload.Size >= LargeUploadSize {
+			report.Large = append(report.Large, upload)
+		}
+	}
+
+	for hash, group := range byHash {
+		if len(group) < 2 {
+			continue
+		}
+		sort.Slice(group, func(i, j int) bool { return group[i].ModTime.Before(group[j].ModTime) })
+		report.Duplicates = append(report.Duplicates, DuplicateUploads{
+			Hash:        hash,
+			Files:       group,
+			Reclaimable: group[0].Size * int64(len(group)-1),
+		})
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		if report.Duplicates[i].Reclaimable != report.Duplicates[j].Reclaimable {
+			return report.Duplicates[i].Reclaimable > report.Duplicates[j].Reclaimable
+		}
+		return report.Duplicates[i].Hash < report.Duplicates[j].Hash
+	})
+	sort.Slice(report.Large, func(i, j int) bool { return report.Large[i].Size > report.Large[j].Size })
+
+	return report, nil
+}
+
+// MergeDuplicateUploads replaces every file in dir with the content hash
+// by a hard link to the oldest of them, so they take the space of one
+// while every link to them keeps working. It returns the space freed.
+func MergeDuplicateUploads(ctx context.Context, db *database.DB, dir, hash string) (int64, error) {
+	files, err := listUploads(dir)
+	if err != nil {
+		return 0, err
+	}
+	hashes, err := newUploadHashes(ctx, db, dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var keep os.FileInfo
+	var others []os.FileInfo
+	for _, info := range files {
+		h, err := hashes.hash(info.Name())
+		if err != nil {
+			return 0, err
+		}
+		if h != hash {
+			continue
+		}
+		if keep == nil || info.ModTime().Before(keep.ModTime()) {
+			if keep != nil {
+				others = append(others, keep)
+			}
+			keep = info
+		} else {
+			others = append(others, info)
+		}
+	}
+	if keep == nil {
+		return 0, os.ErrNotExist
+	}
+
+	var freed int64
+	keepPath := filepath.Join(dir, keep.Name())
+	for _, info := range others {
+		if os.SameFile(keep, info) {
+			continue
+		}
+		// Link under a temporary name first so the file is never missing
+		path := filepath.Join(dir, info.Name())
+		tmp := filepath.Join(dir, ".merge-"+info.Name())
+		if err := os.Link(keepPath, tmp); err != nil {
+			return freed, fmt.Errorf("failed to link %s: %w", info.Name(), err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return freed, fmt.Errorf("failed to replace %s: %w", info.Name(), err)
+		}
+		freed += info.Size()
+	}
+	return freed, nil
+}
+
+// DeleteUpload deletes the named file from the upload directory dir, with
+// its attachments, unless something links to it.
+func DeleteUpload(ctx context.Context, db *database.DB, dir, name string) error {
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return os.ErrNotExist
+	}
+
+	inUse := false
+	err := db.ScanUploadReferences(ctx, func(text string) {
+		for _, m := range uploadReferencePattern.FindAllStringSubmatch(text, -1) {
+			if m[1] == name {
+				inUse = true
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrUploadInUse
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return db.DeleteAttachmentsByPath(ctx, path)
+}
+
+// listUploads lists the regular files in the upload directory, leaving out
+// hidden ones.
+func listUploads(dir string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, nil
+}
+
+// fileSet holds distinct files by size, so spotting hard links compares a
+// file with the few of the same size only.
+type fileSet map[int64][]os.FileInfo
+
+// add adds info, reporting false if it is a hard link to a file in the set.
+func (s fileSet) add(info os.FileInfo) bool {
+	for _, other := range s[info.Size()] {
+		if os.SameFile(other, info) {
+			return false
+		}
+	}
+	s[info.Size()] = append(s[info.Size()], info)
+	return true
+}
+
+// uploadHashes looks up the content hashes of files in an upload
+// directory, hashing only the files with no hash recorded on their
+// attachments.
+type uploadHashes struct {
+	dir    string
+	stored map[string]string
+}
+
+func newUploadHashes(ctx context.Context, db *database.DB, dir string) (*uploadHashes, error) {
+	stored, err := db.ListAttachmentHashes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &uploadHashes{dir: dir, stored: stored}, nil
+}
+
+// hash returns the hex encoded SHA-256 of the named file.
+func (h *uploadHashes) hash(name string) (string, error) {
+	path := filepath.Join(h.dir, name)
+	if hash, ok := h.stored[path]; ok {
+		return hash, nil
+	}
+	return hashFile(path)
+}
+
+// hashFile returns the hex encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", filepath.Base(path), err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
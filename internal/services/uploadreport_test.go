@@ -0,0 +1,59 @@
+//go:build sqlite_fts5
+
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gowiki/internal/models"
+)
+
+func TestInspectUploads(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	user := newTestUser(t, db, "alice")
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.png", "same")
+	write("b.png", "same")
+	write("c.png", "other")
+	if err := os.Link(filepath.Join(dir, "c.png"), filepath.Join(dir, "d.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	// a.png has its hash recorded; the others are hashed from disk
+	hash, err := hashFile(filepath.Join(dir, "b.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	att := &models.Attachment{
+		Filename:   "a.png",
+		Filepath:   filepath.Join(dir, "a.png"),
+		MimeType:   "image/png",
+		Hash:       hash,
+		UploaderID: user.ID,
+	}
+	if err := db.CreateAttachment(ctx, att); err != nil {
+		t.Fatalf("create attachment: %v", err)
+	}
+
+	report, err := InspectUploads(ctx, db, dir)
+	if err != nil {
+		t.Fatalf("InspectUploads: %v", err)
+	}
+	if report.Files != 4 || report.TotalSize != int64(len("same")*2+len("other")) {
+		t.Errorf("Files = %d, TotalSize = %d, want 4 files with the hard link counted once", report.Files, report.TotalSize)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0].Hash != hash || len(report.Duplicates[0].Files) != 2 {
+		t.Errorf("Duplicates = %+v, want a.png and b.png", report.Duplicates)
+	}
+}
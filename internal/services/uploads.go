@@ -0,0 +1,84 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uploadLinkPattern matches upload references in rendered page HTML.
+var uploadLinkPattern = regexp.MustCompile(`(src|href)="/uploads/([A-Za-z0-9._-]+)"`)
+
+// UploadSigner issues and verifies short-lived signed URLs for uploaded files,
+// letting anonymous viewers such as share link visitors load attachments of a
+// private wiki without exposing the whole upload directory.
+type UploadSigner struct {
+	key []byte
+	ttl time.Duration
+}
+
+// NewUploadSigner creates a signer keyed with the application secret.
+func NewUploadSigner(secret string, ttl time.Duration) *UploadSigner {
+	return &UploadSigner{
+		key: []byte("uploads:" + secret),
+		ttl: ttl,
+	}
+}
+
+// Sign returns a signed URL for the named upload and when it expires.
+func (s *UploadSigner) Sign(name string) (string, time.Time) {
+	expires := time.Now().Add(s.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	q := url.Values{}
+	q.Set("expires", exp)
+	q.Set("sig", s.signature(name, exp))
+	return "/uploads/" + name + "?" + q.Encode(), expires
+}
+
+// Verify checks a signature and returns its expiry. It reports false for
+// tampered or expired signatures.
+func (s *UploadSigner) Verify(name, expires, sig string) (time.Time, bool) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	exp := time.Unix(unix, 0)
+	if time.Now().After(exp) {
+		return time.Time{}, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signature(name, expires))) {
+		return time.Time{}, false
+	}
+	return exp, true
+}
+
+// SignHTML rewrites upload links in rendered HTML to signed URLs.
+func (s *UploadSigner) SignHTML(html string) string {
+	return uploadLinkPattern.ReplaceAllStringFunc(html, func(m string) string {
+		parts := uploadLinkPattern.FindStringSubmatch(m)
+		signed, _ := s.Sign(parts[2])
+		// Attribute values are HTML-escaped, so & must be too
+		return parts[1] + `="` + strings.ReplaceAll(signed, "&", "&amp;") + `"`
+	})
+}
+
+// uploadNames returns the names of the uploads linked from rendered HTML.
+func uploadNames(html string) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range uploadLinkPattern.FindAllStringSubmatch(html, -1) {
+		names[m[2]] = true
+	}
+	return names
+}
+
+func (s *UploadSigner) signature(name, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(name + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+	"gowiki/internal/policy"
+)
+
+// ErrInvalidUserImport is returned when a user import file can't be read.
+var ErrInvalidUserImport = errors.New("invalid user import file")
+
+// maxUserImportRows caps the accounts created by one import.
+const maxUserImportRows = 1000
+
+// userImportColumns are the recognised CSV columns, in their default order
+// when the file has no header row.
+var userImportColumns = []string{"username", "email", "role", "group", "password"}
+
+// userExportHeader names the columns written by Export. The first four
+// match userImportColumns, so an export can be edited and imported again.
+var userExportHeader = []string{"username", "email", "role", "group", "status", "email_verified", "created_at", "last_login_at"}
+
+// UserImportService creates accounts in bulk from CSV files.
+type UserImportService struct {
+	db      *database.DB
+	auth    *AuthService
+	invites *InviteService
+	groups  *GroupService
+}
+
+// NewUserImportService creates a new UserImportService.
+func NewUserImportService(db *database.DB, auth *AuthService, invites *InviteService, groups *GroupService) *UserImportService {
+	return &UserImportService{db: db, auth: auth, invites: invites, groups: groups}
+}
+
+// Parse reads a CSV of username, email, role, group and password columns
+// and checks every row without creating anything. A header row naming the
+// columns is optional, and so are every column but username and email.
+// Rows giving a role importer may not assign are errors.
+func (s *UserImportService) Parse(ctx context.Context, r io.Reader, importer *models.User) ([]models.UserImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidUserImport, err)
+	}
+
+	// Spreadsheet exports often start with a byte order mark
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	}
+
+	columns := map[string]int{}
+	for i, name := range userImportColumns {
+		columns[name] = i
+	}
+	firstLine := 1
+	if len(records) > 0 && isUserImportHeader(records[0]) {
+		columns = map[string]int{}
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["username"]; !ok {
+			return nil, fmt.Errorf("%w: missing username column", ErrInvalidUserImport)
+		}
+		if _, ok := columns["email"]; !ok {
+			return nil, fmt.Errorf("%w: missing email column", ErrInvalidUserImport)
+		}
+		records = records[1:]
+		firstLine = 2
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no users found", ErrInvalidUserImport)
+	}
+	if len(records) > maxUserImportRows {
+		return nil, fmt.Errorf("%w: at most %d users can be imported at once", ErrInvalidUserImport, maxUserImportRows)
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	seenUsernames := map[string]int{}
+	seenEmails := map[string]int{}
+	rows := make([]models.UserImportRow, 0, len(records))
+
+	for i, record := range records {
+		row := models.UserImportRow{
+			Line:     firstLine + i,
+			Username: field(record, "username"),
+			Email:    strings.ToLower(field(record, "email")),
+			Role:     models.Role(strings.ToLower(field(record, "role"))),
+			Group:    field(record, "group"),
+			Password: field(record, "password"),
+		}
+
+		if err := s.auth.ValidateUsername(row.Username); err != nil {
+			row.Errors = append(row.Errors, strings.TrimPrefix(err.Error(), ErrInvalidUsername.Error()+": "))
+		} else if line, ok := seenUsernames[strings.ToLower(row.Username)]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate username (line %d)", line))
+		} else if existing, _ := s.db.GetUserByUsername(ctx, row.Username); existing != nil {
+			row.Errors = append(row.Errors, "username already exists")
+		}
+
+		if err := s.auth.ValidateEmail(row.Email); err != nil {
+			row.Errors = append(row.Errors, "invalid email address")
+		} else if line, ok := seenEmails[row.Email]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate email (line %d)", line))
+		} else if existing, _ := s.db.GetUserByEmail(ctx, row.Email); existing != nil {
+			row.Errors = append(row.Errors, "email already in use")
+		}
+
+		if row.Role == "" {
+			row.Role = s.auth.settings.DefaultRole(ctx)
+		} else if !row.Role.IsValid() {
+			row.Errors = append(row.Errors, fmt.Sprintf("unknown role %q", row.Role))
+		}
+		if row.Role.IsValid() && !policy.CanAssignRole(importer, row.Role) {
+			row.Errors = append(row.Errors, "only administrators can import administrator accounts")
+		}
+
+		if row.Password != "" {
+			if err := s.auth.ValidatePassword(row.Password); err != nil {
+				row.Errors = append(row.Errors, strings.TrimPrefix(err.Error(), ErrInvalidPassword.Error()+": "))
+			}
+		}
+
+		for _, name := range importGroupNames(row.Group) {
+			if utf8.RuneCountInString(name) > maxGroupNameLength {
+				row.Errors = append(row.Errors, ErrGroupName.Error())
+			} else if group, err := s.db.GetGroupByName(ctx, name); err == nil && group == nil {
+				row.Warnings = append(row.Warnings, fmt.Sprintf("group %q will be created", name))
+			}
+		}
+
+		if row.Username != "" {
+			if _, ok := seenUsernames[strings.ToLower(row.Username)]; !ok {
+				seenUsernames[strings.ToLower(row.Username)] = row.Line
+			}
+		}
+		if row.Email != "" {
+			if _, ok := seenEmails[row.Email]; !ok {
+				seenEmails[row.Email] = row.Line
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// Import creates an account for every valid row. Rows with a password get
+// that password. Otherwise, in passwords mode each account gets a generated
+// password that is returned once; in invites mode users are emailed a link
+// to choose their own.
+func (s *UserImportService) Import(ctx context.Context, rows []models.UserImportRow, mode string, inviter *models.User) ([]models.UserImportResult, error) {
+	if mode == models.UserImportInvites && !s.invites.Enabled() {
+		return nil, ErrMailDisabled
+	}
+
+	var results []models.UserImportResult
+	for _, row := range rows {
+		if !row.Valid() {
+			continue
+		}
+
+		result := models.UserImportResult{Username: row.Username, Email: row.Email}
+		if !policy.CanAssignRole(inviter, row.Role) {
+			result.Error = "only administrators can import administrator accounts"
+			results = append(results, result)
+			continue
+		}
+
+		password := row.Password
+		if password == "" {
+			var err error
+			if password, err = GeneratePassword(); err != nil {
+				return results, err
+			}
+		}
+
+		user, err := s.auth.CreateUser(ctx, models.UserCreate{
+			Username: row.Username,
+			Email:    row.Email,
+			Password: password,
+			Role:     row.Role,
+		})
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.User = user
+
+		for _, name := range importGroupNames(row.Group) {
+			if err := s.addToGroup(ctx, user, name); err != nil {
+				fmt.Printf("Warning: failed to add %s to group %s: %v\n", user.Username, name, err)
+				result.Error = "account created, but it could not be added to its group"
+			}
+		}
+
+		if row.Password != "" {
+			result.OwnPassword = true
+		} else if mode == models.UserImportInvites {
+			if err := s.invites.Invite(ctx, user, inviter); err != nil {
+				fmt.Printf("Warning: failed to invite %s: %v\n", user.Username, err)
+				// Fall back to handing out the password so the account is usable
+				result.Password = password
+				result.Error = "account created, but the invitation could not be sent"
+			} else {
+				result.Invited = true
+			}
+		} else {
+			result.Password = password
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// addToGroup adds an imported user to the named group, creating the group
+// if needed.
+func (s *UserImportService) addToGroup(ctx context.Context, user *models.User, name string) error {
+	group, err := s.groups.Ensure(ctx, name)
+	if err != nil {
+		return err
+	}
+	return s.db.AddGroupMember(ctx, group.ID, user.ID)
+}
+
+// Export writes every account as CSV, by username, and returns how many
+// were written. Users in several groups have them joined by "; ", which
+// Parse reads back.
+func (s *UserImportService) Export(ctx context.Context, w io.Writer) (int, error) {
+	groups, err := s.db.ListGroupNamesByUser(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(userExportHeader); err != nil {
+		return 0, err
+	}
+
+	const batch = 500
+	written := 0
+	for offset := 0; ; offset += batch {
+		users, err := s.db.ListUsers(ctx, batch, offset)
+		if err != nil {
+			return written, err
+		}
+		for _, u := range users {
+			status := "active"
+			switch {
+			case u.IsLocked():
+				status = "locked"
+			case !u.IsActive:
+				status = "inactive"
+			}
+			lastLogin := ""
+			if u.LastLoginAt.Valid {
+				lastLogin = u.LastLoginAt.Time.UTC().Format(time.RFC3339)
+			}
+			record := []string{
+				csvCell(u.Username),
+				csvCell(u.Email),
+				string(u.Role),
+				csvCell(strings.Join(groups[u.ID], "; ")),
+				status,
+				strconv.FormatBool(u.EmailVerified()),
+				u.CreatedAt.UTC().Format(time.RFC3339),
+				lastLogin,
+			}
+			if err := cw.Write(record); err != nil {
+				return written, err
+			}
+			written++
+		}
+		if len(users) < batch {
+			break
+		}
+	}
+
+	cw.Flush()
+	return written, cw.Error()
+}
+
+// importGroupNames splits a group cell into group names. Several groups
+// are separated by semicolons, as Export writes them.
+func importGroupNames(cell string) []string {
+	var names []string
+	for _, name := range strings.Split(cell, ";") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isUserImportHeader(record []string) bool {
+	for _, name := range record {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "username" || name == "email" {
+			return true
+		}
+	}
+	return false
+}
+
+// GeneratePassword returns a random password that satisfies
+// ValidatePassword.
+func GeneratePassword() (string, error) {
+	const (
+		lower  = "abcdefghijkmnopqrstuvwxyz"
+		upper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits = "23456789"
+		all    = lower + upper + digits
+	)
+
+	sets := []string{lower, upper, digits}
+	for len(sets) < 16 {
+		sets = append(sets, all)
+	}
+
+	password := make([]byte, len(sets))
+	for i, set := range sets {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = set[n.Int64()]
+	}
+
+	// Shuffle so the required classes aren't always first
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,344 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gowiki/internal/config"
+	"gowiki/internal/database"
+	"gowiki/internal/models"
+)
+
+var (
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute http or https URL")
+	ErrNoWebhookEvents   = errors.New("select at least one event")
+	ErrWebhookNotFound   = errors.New("webhook not found")
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it is marked failed.
+	webhookMaxAttempts = 8
+	// webhookRetryBase is the delay before the first retry; each retry doubles it.
+	webhookRetryBase = 30 * time.Second
+	// webhookRetention is how long finished deliveries are kept for the admin log.
+	webhookRetention = 7 * 24 * time.Hour
+)
+
+// WebhookPayload is the JSON body POSTed to webhook URLs.
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookService queues events for registered webhooks and delivers them in
+// the background. Deliveries are stored in the database so they survive
+// restarts, and only the cluster leader dispatches them.
+type WebhookService struct {
+	db      *database.DB
+	siteURL string
+	cluster *Cluster
+	client  *http.Client
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWebhookService creates a new WebhookService.
+func NewWebhookService(db *database.DB, cfg *config.Config, cluster *Cluster) *WebhookService {
+	return &WebhookService{
+		db:      db,
+		siteURL: strings.TrimRight(cfg.Site.URL, "/"),
+		cluster: cluster,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Create registers a webhook. A random secret is generated when none is given.
+func (s *WebhookService) Create(ctx context.Context, rawURL, secret string, events []string, createdBy *int64) (*models.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	var valid []string
+	for _, e := range models.WebhookEvents {
+		for _, selected := range events {
+			if e == selected {
+				valid = append(valid, e)
+				break
+			}
+		}
+	}
+	if len(valid) == 0 {
+		return nil, ErrNoWebhookEvents
+	}
+
+	if secret == "" {
+		b := make([]byte, 24)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %w", err)
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	hook := &models.Webhook{
+		URL:       u.String(),
+		Secret:    secret,
+		Events:    valid,
+		IsActive:  true,
+		CreatedBy: createdBy,
+	}
+	if err := s.db.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// Emit queues event for every active webhook subscribed to it. Failures are
+// logged rather than returned so a webhook problem never fails the request.
+func (s *WebhookService) Emit(ctx context.Context, event string, data interface{}) {
+	hooks, err := s.db.ListWebhooks(ctx)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load webhooks: %v\n", err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, hook := range hooks {
+		if !hook.IsActive || !hook.HasEvent(event) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(WebhookPayload{Event: event, Timestamp: time.Now().UTC(), Data: data})
+			if err != nil {
+				fmt.Printf("Warning: Failed to encode webhook payload: %v\n", err)
+				return
+			}
+		}
+		if err := s.queue(ctx, hook.ID, event, payload); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		s.notify()
+	}
+}
+
+// EmitPage queues a page event. actor is the user who made the change.
+func (s *WebhookService) EmitPage(ctx context.Context, event string, page *models.Page, actor *models.User) {
+	data := map[string]interface{}{
+		"id":    page.ID,
+		"slug":  page.Slug,
+		"title": page.Title,
+		"url":   s.siteURL + "/wiki/" + page.Slug,
+	}
+	if actor != nil {
+		data["user"] = actor.Username
+	}
+	s.Emit(ctx, event, data)
+}
+
+// EmitUserCreated queues a user.created event.
+func (s *WebhookService) EmitUserCreated(ctx context.Context, user *models.User) {
+	s.Emit(ctx, models.EventUserCreated, map[string]interface{}{
+		"id":       user.ID,
+		"username": user.Username,
+		"role":     string(user.Role),
+	})
+}
+
+// Ping queues a test event for one webhook regardless of its subscriptions.
+func (s *WebhookService) Ping(ctx context.Context, id int64) error {
+	hook, err := s.db.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if hook == nil {
+		return ErrWebhookNotFound
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		Event:     models.EventPing,
+		Timestamp: time.Now().UTC(),
+		Data:      map[string]interface{}{"webhook_id": hook.ID},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	if err := s.queue(ctx, hook.ID, models.EventPing, payload); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+func (s *WebhookService) queue(ctx context.Context, webhookID int64, event string, payload []byte) error {
+	return s.db.CreateWebhookDelivery(ctx, &models.WebhookDelivery{
+		WebhookID: webhookID,
+		Event:     event,
+		Payload:   string(payload),
+	})
+}
+
+// notify wakes the dispatcher without blocking.
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the delivery loop in the background.
+func (s *WebhookService) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		prune := time.NewTicker(time.Hour)
+		defer prune.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.wake:
+			case <-prune.C:
+				if s.cluster.IsLeader() {
+					if err := s.db.PruneWebhookDeliveries(context.Background(), time.Now().Add(-webhookRetention)); err != nil {
+						fmt.Printf("Warning: %v\n", err)
+					}
+				}
+				continue
+			case <-s.stop:
+				return
+			}
+			s.dispatch()
+		}
+	}()
+}
+
+// Stop halts the delivery loop. Pending deliveries are sent after the next start.
+func (s *WebhookService) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+}
+
+// dispatch sends every due delivery once.
+func (s *WebhookService) dispatch() {
+	if !s.cluster.IsLeader() {
+		return
+	}
+
+	ctx := context.Background()
+	deliveries, err := s.db.ListDueWebhookDeliveries(ctx, 50)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+
+	hooks := make(map[int64]*models.Webhook)
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		hook, ok := hooks[d.WebhookID]
+		if !ok {
+			hook, err = s.db.GetWebhook(ctx, d.WebhookID)
+			if err != nil {
+				fmt.Printf("Warning: %v\n", err)
+				continue
+			}
+			hooks[d.WebhookID] = hook
+		}
+		if hook == nil {
+			continue
+		}
+
+		s.deliver(ctx, hook, d)
+		if err := s.db.UpdateWebhookDelivery(ctx, d); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// deliver POSTs one delivery and updates it with the outcome and next retry.
+func (s *WebhookService) deliver(ctx context.Context, hook *models.Webhook, d *models.WebhookDelivery) {
+	d.Attempts++
+
+	code, err := s.post(ctx, hook, d)
+	if code != 0 {
+		d.ResponseCode = &code
+	}
+
+	if err == nil {
+		now := time.Now().UTC()
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &now
+		return
+	}
+
+	d.LastError = err.Error()
+	if d.Attempts >= webhookMaxAttempts {
+		d.Status = models.DeliveryFailed
+		return
+	}
+	d.NextAttemptAt = time.Now().UTC().Add(webhookRetryBase << (d.Attempts - 1))
+}
+
+func (s *WebhookService) post(ctx context.Context, hook *models.Webhook, d *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader([]byte(d.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoWiki-Webhook/1.0")
+	req.Header.Set("X-GoWiki-Event", d.Event)
+	req.Header.Set("X-GoWiki-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-GoWiki-Signature", "sha256="+SignWebhookPayload(hook.Secret, []byte(d.Payload)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of payload, as sent in the
+// X-GoWiki-Signature header.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
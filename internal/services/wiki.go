@@ -2,15 +2,18 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"gowiki/internal/database"
 	"gowiki/internal/models"
+	"gowiki/internal/tracing"
 )
 
 // Wiki errors.
@@ -20,6 +23,9 @@ var (
 	ErrInvalidSlug      = errors.New("invalid page slug")
 	ErrInvalidTitle     = errors.New("page title is required")
 	ErrRevisionNotFound = errors.New("revision not found")
+	ErrPageArchived     = errors.New("page is archived; unarchive it to make changes")
+	ErrSlugTooDeep      = errors.New("page is nested too deeply")
+	ErrSlugTooLong      = errors.New("page slug is too long")
 )
 
 // SlugChange represents a slug that was changed during an update.
@@ -38,6 +44,10 @@ type UpdateResult struct {
 type WikiService struct {
 	db       *database.DB
 	markdown *MarkdownService
+	limits   SlugLimits
+	auditor  *Auditor
+	quick    *quickIndex
+	related  *relatedIndex
 }
 
 // NewWikiService creates a new wiki service.
@@ -45,9 +55,22 @@ func NewWikiService(db *database.DB, markdown *MarkdownService) *WikiService {
 	return &WikiService{
 		db:       db,
 		markdown: markdown,
+		quick:    newQuickIndex(db),
+		related:  newRelatedIndex(db),
 	}
 }
 
+// SetAuditor sets where page changes are audited.
+func (s *WikiService) SetAuditor(auditor *Auditor) {
+	s.auditor = auditor
+}
+
+// Audit records user activity for the request in ctx, such as share link
+// changes made outside the service.
+func (s *WikiService) Audit(ctx context.Context, action, entityType string, entityID *int64, details map[string]interface{}) {
+	s.auditor.Log(ctx, nil, action, entityType, entityID, details)
+}
+
 // GetDB returns the database instance.
 func (s *WikiService) GetDB() *database.DB {
 	return s.db
@@ -68,6 +91,9 @@ func (s *WikiService) CreatePage(ctx context.Context, authorID int64, input mode
 	if slug == "" {
 		return nil, ErrInvalidSlug
 	}
+	if err := s.limits.Check(slug); err != nil {
+		return nil, err
+	}
 
 	// Validate title
 	title := strings.TrimSpace(input.Title)
@@ -94,7 +120,7 @@ func (s *WikiService) CreatePage(ctx context.Context, authorID int64, input mode
 	}
 
 	// Render markdown to HTML
-	contentHTML, err := s.markdown.Render(input.Content)
+	contentHTML, err := s.markdown.RenderContext(ctx, slug, input.Content)
 	if err != nil {
 		return nil, fmt.Errorf("failed to render markdown: %w", err)
 	}
@@ -104,14 +130,20 @@ func (s *WikiService) CreatePage(ctx context.Context, authorID int64, input mode
 		Title:       title,
 		Content:     input.Content,
 		ContentHTML: contentHTML,
+		Excerpt:     s.markdown.Excerpt(input.Content),
+		WordCount:   s.markdown.WordCount(input.Content),
 		AuthorID:    authorID,
 		ParentID:    parentID,
 		IsPublished: true,
 	}
+	if input.PublishAt != nil {
+		page.SchedulePublish(*input.PublishAt, time.Now())
+	}
 
 	if err := s.db.CreatePage(ctx, page); err != nil {
 		return nil, fmt.Errorf("failed to create page: %w", err)
 	}
+	s.IndexLinks(ctx, page)
 
 	// Save initial revision
 	revision := &models.Revision{
@@ -135,6 +167,13 @@ func (s *WikiService) CreatePage(ctx context.Context, authorID int64, input mode
 		page.Tags = tags
 	}
 
+	s.linkPendingAttachments(ctx, page, authorID)
+
+	s.auditor.Log(ctx, &authorID, "page_create", "page", &page.ID, map[string]interface{}{
+		"slug":  page.Slug,
+		"title": page.Title,
+	})
+
 	return page, nil
 }
 
@@ -165,13 +204,38 @@ func (s *WikiService) GetPageByID(ctx context.Context, id int64) (*models.Page,
 // UpdatePage updates an existing page.
 // Returns UpdateResult containing the page and any cascaded slug changes.
 func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, input models.PageUpdate, comment string) (*UpdateResult, error) {
+	result, oldSlug, err := s.updatePage(ctx, pageID, authorID, input, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	details := map[string]interface{}{"slug": result.Page.Slug}
+	if comment != "" {
+		details["comment"] = comment
+	}
+	if oldSlug != result.Page.Slug {
+		details["old_slug"] = oldSlug
+		s.followSitePages(ctx, oldSlug, result.Page.Slug)
+	}
+	s.auditor.Log(ctx, &authorID, "page_update", "page", &result.Page.ID, details)
+
+	return result, nil
+}
+
+// updatePage applies a page update and returns the slug the page had
+// before it.
+func (s *WikiService) updatePage(ctx context.Context, pageID, authorID int64, input models.PageUpdate, comment string) (*UpdateResult, string, error) {
 	page, err := s.db.GetPageByID(ctx, pageID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get page: %w", err)
+		return nil, "", fmt.Errorf("failed to get page: %w", err)
 	}
 	if page == nil {
-		return nil, ErrPageNotFound
+		return nil, "", ErrPageNotFound
+	}
+	if page.IsArchived() {
+		return nil, "", ErrPageArchived
 	}
+	oldSlug := page.Slug
 
 	var slugChanges []SlugChange
 
@@ -179,15 +243,29 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 	if input.Slug != nil {
 		newSlug := Slugify(*input.Slug)
 		if newSlug != "" && newSlug != page.Slug {
-			oldSlug := page.Slug
-
 			// Check for collision (but allow updating self)
 			existing, err := s.db.GetPageBySlug(ctx, newSlug)
 			if err != nil {
-				return nil, fmt.Errorf("failed to check slug: %w", err)
+				return nil, "", fmt.Errorf("failed to check slug: %w", err)
 			}
 			if existing != nil && existing.ID != pageID {
-				return nil, ErrPageExists
+				return nil, "", ErrPageExists
+			}
+
+			// The page and every subpage must fit the limits at the new path
+			if err := s.limits.Check(newSlug); err != nil {
+				return nil, "", err
+			}
+			descendants, err := s.db.GetAllDescendants(ctx, pageID)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to get descendants: %w", err)
+			}
+			for _, desc := range descendants {
+				if strings.HasPrefix(desc.Slug, oldSlug+"/") {
+					if err := s.limits.Check(newSlug + strings.TrimPrefix(desc.Slug, oldSlug)); err != nil {
+						return nil, "", err
+					}
+				}
 			}
 
 			// Resolve new parent from slug hierarchy
@@ -195,7 +273,7 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 			if strings.Contains(newSlug, "/") {
 				newParentID, err = s.ensureParentPages(ctx, authorID, newSlug)
 				if err != nil {
-					return nil, fmt.Errorf("failed to create parent pages: %w", err)
+					return nil, "", fmt.Errorf("failed to create parent pages: %w", err)
 				}
 			}
 			// If no "/" in slug, newParentID stays nil (becomes root level)
@@ -204,11 +282,6 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 			page.ParentID = newParentID
 
 			// Cascade update: update all descendant slugs
-			descendants, err := s.db.GetAllDescendants(ctx, pageID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get descendants: %w", err)
-			}
-
 			for _, desc := range descendants {
 				// Replace old parent slug prefix with new one
 				// e.g., if oldSlug="linux" and newSlug="commands/linux"
@@ -216,7 +289,7 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 				if strings.HasPrefix(desc.Slug, oldSlug+"/") {
 					newChildSlug := newSlug + strings.TrimPrefix(desc.Slug, oldSlug)
 					if err := s.db.UpdatePageSlug(ctx, desc.ID, newChildSlug); err != nil {
-						return nil, fmt.Errorf("failed to update descendant slug: %w", err)
+						return nil, "", fmt.Errorf("failed to update descendant slug: %w", err)
 					}
 					// Track the change for backup updates
 					slugChanges = append(slugChanges, SlugChange{
@@ -224,6 +297,7 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 						NewSlug: newChildSlug,
 					})
 					fmt.Printf("Cascade updated slug: %s -> %s\n", desc.Slug, newChildSlug)
+					s.rerenderMovedPage(ctx, desc.ID)
 				}
 			}
 		}
@@ -246,31 +320,50 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 	if input.Title != nil {
 		title := strings.TrimSpace(*input.Title)
 		if title == "" {
-			return nil, ErrInvalidTitle
+			return nil, "", ErrInvalidTitle
 		}
 		page.Title = title
 	}
 
-	if input.Content != nil {
+	// Stored HTML is kept current by RerenderPages, so unchanged content
+	// doesn't need rendering again unless a move changed where its relative
+	// links point
+	contentChanged := input.Content != nil && *input.Content != page.Content
+	if contentChanged {
 		page.Content = *input.Content
-		contentHTML, err := s.markdown.Render(*input.Content)
+	}
+	relinked := page.Slug != oldSlug && hasRelativeWikiLinks(page.Content)
+	if contentChanged || relinked {
+		contentHTML, err := s.markdown.RenderContext(ctx, page.Slug, page.Content)
 		if err != nil {
-			return nil, fmt.Errorf("failed to render markdown: %w", err)
+			return nil, "", fmt.Errorf("failed to render markdown: %w", err)
 		}
 		page.ContentHTML = contentHTML
+		page.Excerpt = s.markdown.Excerpt(page.Content)
+		page.WordCount = s.markdown.WordCount(page.Content)
 	}
 
 	if input.IsPublished != nil {
-		page.IsPublished = *input.IsPublished
-		if *input.IsPublished && !page.PublishedAt.Valid {
-			page.PublishedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		if *input.IsPublished {
+			page.Publish(time.Now())
+		} else {
+			page.IsPublished = false
 		}
 	}
+	if input.PublishAt != nil {
+		page.SchedulePublish(*input.PublishAt, time.Now())
+	}
 
 	page.UpdatedAt = time.Now().UTC()
 
 	if err := s.db.UpdatePage(ctx, page); err != nil {
-		return nil, fmt.Errorf("failed to update page: %w", err)
+		return nil, "", fmt.Errorf("failed to update page: %w", err)
+	}
+	if contentChanged || relinked {
+		s.IndexLinks(ctx, page)
+	}
+	if contentChanged {
+		s.linkPendingAttachments(ctx, page, authorID)
 	}
 
 	// Update tags if provided
@@ -287,7 +380,26 @@ func (s *WikiService) UpdatePage(ctx context.Context, pageID, authorID int64, in
 	return &UpdateResult{
 		Page:        page,
 		SlugChanges: slugChanges,
-	}, nil
+	}, oldSlug, nil
+}
+
+// rerenderMovedPage refreshes the stored HTML and links of a subpage moved
+// along with its parent, when it has relative links that now point
+// elsewhere. Failures are logged; RerenderPages repairs the HTML later.
+func (s *WikiService) rerenderMovedPage(ctx context.Context, pageID int64) {
+	page, err := s.db.GetPageByID(ctx, pageID)
+	if err != nil || page == nil || !hasRelativeWikiLinks(page.Content) {
+		return
+	}
+	html, err := s.markdown.RenderContext(ctx, page.Slug, page.Content)
+	if err == nil {
+		err = s.db.UpdatePageHTML(ctx, page.ID, html)
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to re-render moved page %s: %v\n", page.Slug, err)
+		return
+	}
+	s.IndexLinks(ctx, page)
 }
 
 // DeletePage removes a page.
@@ -300,7 +412,15 @@ func (s *WikiService) DeletePage(ctx context.Context, pageID int64) error {
 		return ErrPageNotFound
 	}
 
-	return s.db.DeletePage(ctx, pageID)
+	if err := s.db.DeletePage(ctx, pageID); err != nil {
+		return err
+	}
+
+	s.auditor.Log(ctx, nil, "page_delete", "page", &pageID, map[string]interface{}{
+		"slug":  page.Slug,
+		"title": page.Title,
+	})
+	return nil
 }
 
 // ListPages retrieves pages with filtering.
@@ -308,19 +428,43 @@ func (s *WikiService) ListPages(ctx context.Context, filter models.PageFilter) (
 	return s.db.ListPages(ctx, filter)
 }
 
-// GetRecentPages retrieves the most recently updated pages.
+// GetRecentPages retrieves the most recently updated pages, leaving out
+// unpublished and restricted ones.
 func (s *WikiService) GetRecentPages(ctx context.Context, limit int) ([]models.PageSummary, error) {
 	filter := models.NewPageFilter()
 	filter.Limit = limit
 	published := true
 	filter.IsPublished = &published
+	filter.HideRestricted = true
 
 	return s.db.ListPages(ctx, filter)
 }
 
-// GetPageRevisions retrieves revision history for a page.
-func (s *WikiService) GetPageRevisions(ctx context.Context, pageID int64, limit, offset int) ([]models.RevisionSummary, error) {
-	return s.db.ListRevisions(ctx, pageID, limit, offset)
+// GetPageRevisions retrieves the revisions of a page matching filter,
+// newest first.
+func (s *WikiService) GetPageRevisions(ctx context.Context, pageID int64, filter models.RevisionFilter) ([]models.RevisionSummary, error) {
+	return s.db.ListRevisions(ctx, pageID, filter)
+}
+
+// CountPageRevisions counts the revisions of a page matching filter.
+func (s *WikiService) CountPageRevisions(ctx context.Context, pageID int64, filter models.RevisionFilter) (int, error) {
+	return s.db.CountPageRevisions(ctx, pageID, filter)
+}
+
+// ListRevisionAuthors returns the usernames that have edited a page.
+func (s *WikiService) ListRevisionAuthors(ctx context.Context, pageID int64) ([]string, error) {
+	return s.db.ListRevisionAuthors(ctx, pageID)
+}
+
+// ParseRevisionFilter reads the author, from and to query parameters shared
+// by the page history and the API. Dates are UTC days and both ends are
+// inclusive.
+func ParseRevisionFilter(query url.Values) (models.RevisionFilter, error) {
+	filter := models.RevisionFilter{Author: strings.TrimSpace(query.Get("author"))}
+
+	var err error
+	filter.Since, filter.Until, err = parseDateRange(query)
+	return filter, err
 }
 
 // GetRevision retrieves a specific revision.
@@ -345,17 +489,29 @@ func (s *WikiService) RevertToRevision(ctx context.Context, revisionID, authorID
 	content := rev.Content
 	comment := fmt.Sprintf("Reverted to revision %d", revisionID)
 
-	result, err := s.UpdatePage(ctx, rev.PageID, authorID, models.PageUpdate{
+	result, _, err := s.updatePage(ctx, rev.PageID, authorID, models.PageUpdate{
 		Content: &content,
 	}, comment)
 	if err != nil {
 		return nil, err
 	}
+
+	s.auditor.Log(ctx, &authorID, "page_revert", "page", &rev.PageID, map[string]interface{}{
+		"slug":        result.Page.Slug,
+		"revision_id": revisionID,
+	})
 	return result.Page, nil
 }
 
+// ArchivePage archives or unarchives a page, and every page under it when
+// withDescendants is set. Archived pages stay readable but are hidden from
+// search by default and cannot be edited. It returns how many pages changed.
+func (s *WikiService) ArchivePage(ctx context.Context, pageID int64, archived, withDescendants bool) (int64, error) {
+	return s.db.SetPagesArchived(ctx, pageID, archived, withDescendants)
+}
+
 // Search performs full-text search on pages.
-func (s *WikiService) Search(ctx context.Context, query string, limit int) ([]models.SearchResult, error) {
+func (s *WikiService) Search(ctx context.Context, query string, limit int, includeArchived bool) ([]models.SearchResult, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -363,7 +519,14 @@ func (s *WikiService) Search(ctx context.Context, query string, limit int) ([]mo
 		limit = 100
 	}
 
-	return s.db.SearchPages(ctx, query, limit)
+	ctx, span := tracing.Tracer().Start(ctx, "wiki.search")
+	defer span.End()
+	span.SetAttributes(attribute.String("search.query", query))
+
+	results, err := s.db.SearchPages(ctx, query, limit, 0, includeArchived)
+	tracing.RecordError(span, err)
+	span.SetAttributes(attribute.Int("search.results", len(results)))
+	return results, err
 }
 
 // GetAllTags retrieves all tags with page counts.
@@ -371,7 +534,7 @@ func (s *WikiService) GetAllTags(ctx context.Context) ([]models.Tag, error) {
 	return s.db.ListTags(ctx)
 }
 
-// GetPagesByTag retrieves pages with a specific tag.
+// GetPagesByTag retrieves published, unrestricted pages with a specific tag.
 func (s *WikiService) GetPagesByTag(ctx context.Context, tag string, limit, offset int) ([]models.PageSummary, error) {
 	filter := models.NewPageFilter()
 	filter.Tag = &tag
@@ -379,31 +542,80 @@ func (s *WikiService) GetPagesByTag(ctx context.Context, tag string, limit, offs
 	filter.Offset = offset
 	published := true
 	filter.IsPublished = &published
+	filter.HideRestricted = true
 
 	return s.db.ListPages(ctx, filter)
 }
 
-// RenderMarkdown renders markdown content to HTML.
-func (s *WikiService) RenderMarkdown(content string) (string, error) {
-	return s.markdown.Render(content)
+// RenderMarkdown renders markdown content to HTML. Relative wiki-links
+// resolve against slug, the page the content belongs to.
+func (s *WikiService) RenderMarkdown(slug, content string) (string, error) {
+	return s.markdown.RenderPage(slug, content)
 }
 
-// GenerateTOC generates a table of contents from markdown.
-func (s *WikiService) GenerateTOC(content string) []TOCEntry {
-	return s.markdown.GenerateTOC(content)
+// Excerpt returns the plain-text excerpt stored with a page's content.
+func (s *WikiService) Excerpt(content string) string {
+	return s.markdown.Excerpt(content)
+}
+
+// WordCount returns the word count stored with a page's content.
+func (s *WikiService) WordCount(content string) int {
+	return s.markdown.WordCount(content)
 }
 
-// GetBacklinks finds pages that link to a given page.
-func (s *WikiService) GetBacklinks(ctx context.Context, slug string) ([]models.PageSummary, error) {
-	// Search for pages containing the wiki link
-	searchPattern := "[[" + slug
+// SetMath turns math rendering on or off for pages rendered from now on.
+func (s *WikiService) SetMath(enabled bool) {
+	s.markdown.SetMath(enabled)
+}
 
-	filter := models.NewPageFilter()
-	filter.Search = &searchPattern
-	published := true
-	filter.IsPublished = &published
+// SetSlugLimits sets the depth and length limits for new and moved pages.
+// Existing pages beyond them are left alone; ListPagesBeyondSlugLimits
+// finds them.
+func (s *WikiService) SetSlugLimits(limits SlugLimits) {
+	s.limits = limits
+}
 
-	return s.db.ListPages(ctx, filter)
+// SlugLimits returns the depth and length limits for new and moved pages.
+func (s *WikiService) SlugLimits() SlugLimits {
+	return s.limits
+}
+
+// ListPagesBeyondSlugLimits returns the pages whose slugs exceed the
+// current limits, e.g. after the limits were lowered.
+func (s *WikiService) ListPagesBeyondSlugLimits(ctx context.Context) ([]models.PageSummary, error) {
+	return s.db.ListPagesBeyondSlugLimits(ctx, s.limits.MaxDepth, s.limits.MaxLength)
+}
+
+// renderVersionKey is the setting recording which rendering rules produced
+// the stored page HTML.
+const renderVersionKey = "markdown_render_version"
+
+// RerenderPages refreshes stored page HTML after the markdown rendering rules
+// changed, e.g. when diagram support was added or PlantUML was configured.
+// It returns how many pages changed.
+func (s *WikiService) RerenderPages(ctx context.Context) (int, error) {
+	version := s.markdown.RenderVersion()
+	current, err := s.db.GetSetting(ctx, renderVersionKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get render version: %w", err)
+	}
+	if current == version {
+		return 0, nil
+	}
+
+	count, err := s.db.RerenderPages(ctx, s.markdown.RenderPage, s.markdown.Excerpt, s.markdown.WordCount)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.db.SetSetting(ctx, renderVersionKey, version); err != nil {
+		return count, fmt.Errorf("failed to save render version: %w", err)
+	}
+	return count, nil
+}
+
+// GenerateTOC generates a table of contents from markdown.
+func (s *WikiService) GenerateTOC(content string) []TOCEntry {
+	return s.markdown.GenerateTOC(content)
 }
 
 // PageExists checks if a page with the given slug exists.
@@ -535,7 +747,7 @@ func (s *WikiService) ensureParentPages(ctx context.Context, authorID int64, slu
 			parentID = &existing.ID
 		} else {
 			// Create parent page with title derived from slug segment
-			title := strings.Title(strings.ReplaceAll(parts[i], "-", " "))
+			title := SlugTitle(parts[i])
 
 			contentHTML, _ := s.markdown.Render("")
 
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Markdown extension for [[wiki-links]]:
+//
+//	[[Page Name]]          link to the page, slugified
+//	[[Page Name|text]]     link with display text
+//	[[Page#Section]]       link to a heading on the page
+//	[[#Section]]           link to a heading on the same page
+//	[[./child]]            link to a subpage of the current page
+//	[[../sibling]]         link to a subpage of the current page's parent
+//	\[[not a link]]        literal brackets
+//
+// A link may wrap onto the next lines of its paragraph; line breaks inside
+// it read as spaces. A backslash escapes a | ] or # that belongs to the
+// text.
+
+// Limits on what is read as one link, so a stray [[ doesn't make the
+// parser scan the rest of a long paragraph.
+const (
+	maxWikiLinkLength = 512
+	maxWikiLinkLines  = 3
+)
+
+// wikiLinkBaseKey holds the slug of the page being rendered, which
+// relative links resolve against.
+var wikiLinkBaseKey = parser.NewContextKey()
+
+// wikiLink is a parsed [[...]] link.
+type wikiLink struct {
+	// Target is the page as written, e.g. "Setup" or "./child". It is
+	// empty for links to a section of the same page.
+	Target string
+	// Anchor is the heading after #, if any.
+	Anchor string
+	// Text is the link's display text.
+	Text string
+}
+
+// scanWikiLink reads a wiki-link at the start of s, which begins with [[.
+// It returns the text between the brackets and the length of the link.
+// Links can't contain [ or a blank line, and are limited in length and
+// lines.
+func scanWikiLink(s string) (string, int, bool) {
+	lines := 1
+	for i := 2; i < len(s) && i < maxWikiLinkLength; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			return "", 0, false
+		case '\n':
+			lines++
+			if lines > maxWikiLinkLines {
+				return "", 0, false
+			}
+			rest := s[i+1:]
+			if end := strings.IndexByte(rest, '\n'); end >= 0 && strings.TrimSpace(rest[:end]) == "" {
+				return "", 0, false
+			}
+		case ']':
+			if i+1 < len(s) && s[i+1] == ']' {
+				return s[2:i], i + 2, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// parseWikiLink splits the text between a link's brackets into its
+// target, anchor and display text. It reports false for links with
+// neither a target nor an anchor.
+func parseWikiLink(inner string) (wikiLink, bool) {
+	target, display, hasDisplay := cutUnescaped(inner, '|')
+	target, anchor, _ := cutUnescaped(target, '#')
+
+	link := wikiLink{
+		Target: collapseSpaces(unescapeWikiText(target)),
+		Anchor: collapseSpaces(unescapeWikiText(anchor)),
+	}
+	if link.Target == "" && link.Anchor == "" {
+		return link, false
+	}
+
+	if hasDisplay {
+		link.Text = collapseSpaces(unescapeWikiText(display))
+	}
+	if link.Text == "" {
+		link.Text = trimRelativePrefix(link.Target)
+		if link.Anchor != "" {
+			if link.Text != "" {
+				link.Text += "#"
+			}
+			link.Text += link.Anchor
+		}
+	}
+	return link, true
+}
+
+// cutUnescaped slices s around the first sep not escaped by a backslash.
+func cutUnescaped(s string, sep byte) (before, after string, found bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// unescapeWikiText drops the backslash from escaped punctuation.
+func unescapeWikiText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isASCIIPunct(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+// collapseSpaces trims s and turns each run of whitespace, including line
+// breaks, into one space.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// escapedAt reports whether the byte at i is escaped by a backslash.
+func escapedAt(s string, i int) bool {
+	n := 0
+	for i > 0 && s[i-1] == '\\' {
+		n++
+		i--
+	}
+	return n%2 == 1
+}
+
+// isRelativeWikiTarget reports whether a link target is relative to the
+// current page.
+func isRelativeWikiTarget(target string) bool {
+	return strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../")
+}
+
+// hasRelativeWikiLinks reports whether markdown may contain relative
+// wiki-links, whose targets depend on where the page is.
+func hasRelativeWikiLinks(markdown string) bool {
+	return strings.Contains(markdown, "[[./") || strings.Contains(markdown, "[[../")
+}
+
+// trimRelativePrefix drops the leading ./ and ../ of a relative target.
+func trimRelativePrefix(target string) string {
+	for isRelativeWikiTarget(target) {
+		target = target[strings.IndexByte(target, '/')+1:]
+	}
+	return target
+}
+
+// resolveWikiTarget returns the slug of the page a link target names.
+// "./child" is a subpage of the page at base and "../sibling" a subpage of
+// its parent; other targets are full page names. It returns "" when the
+// target names no page, e.g. when it climbs above the top of the wiki.
+func resolveWikiTarget(base, target string) string {
+	if !isRelativeWikiTarget(target) {
+		return slugify(target)
+	}
+
+	var segments []string
+	if base != "" {
+		segments = strings.Split(base, "/")
+	}
+	for {
+		if rest, ok := strings.CutPrefix(target, "./"); ok {
+			target = rest
+		} else if rest, ok := strings.CutPrefix(target, "../"); ok {
+			if len(segments) == 0 {
+				return ""
+			}
+			segments = segments[:len(segments)-1]
+			target = rest
+		} else {
+			break
+		}
+	}
+
+	name := slugify(target)
+	if name == "" {
+		return ""
+	}
+	return strings.Join(append(segments, name), "/")
+}
+
+// wikiLinkHref returns where a link on the page at base points, or "" when
+// it points nowhere.
+func wikiLinkHref(base string, link wikiLink) string {
+	var href string
+	if link.Target != "" {
+		slug := resolveWikiTarget(base, link.Target)
+		if slug == "" {
+			return ""
+		}
+		href = "/wiki/" + slug
+	}
+	if link.Anchor != "" {
+		href += "#" + generateHeadingID(strings.ReplaceAll(link.Anchor, "/", " "))
+	}
+	return href
+}
+
+type wikiLinkExtension struct{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithInlineParsers(
+			util.Prioritized(&wikiLinkParser{}, 100),
+		),
+	)
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if len(line) < 4 || line[0] != '[' || line[1] != '[' {
+		return nil
+	}
+
+	// Read ahead over the following lines of the paragraph, since a link
+	// may wrap, then go back to where the link starts
+	startLine, startPos := block.Position()
+	var src []byte
+	var lengths []int
+	for i := 0; i < maxWikiLinkLines; i++ {
+		line, _ := block.PeekLine()
+		if line == nil {
+			break
+		}
+		src = append(src, line...)
+		lengths = append(lengths, len(line))
+		block.AdvanceLine()
+	}
+	block.SetPosition(startLine, startPos)
+
+	inner, n, ok := scanWikiLink(string(src))
+	if !ok {
+		return nil
+	}
+	link, ok := parseWikiLink(inner)
+	if !ok {
+		return nil
+	}
+	// Excerpts parse without a page to resolve relative links against, and
+	// only need the text
+	base, onPage := pc.Get(wikiLinkBaseKey).(string)
+	href := wikiLinkHref(base, link)
+	if href == "" && onPage {
+		return nil
+	}
+
+	// Advance past the link, across the lines it wraps over
+	for _, length := range lengths {
+		if n < length {
+			block.Advance(n)
+			break
+		}
+		n -= length
+		block.AdvanceLine()
+	}
+
+	if href == "" {
+		return ast.NewString([]byte(link.Text))
+	}
+
+	node := ast.NewLink()
+	node.Destination = []byte(href)
+	if link.Target != "" {
+		title := link.Target
+		if isRelativeWikiTarget(title) {
+			title = resolveWikiTarget(base, title)
+		}
+		node.Title = []byte(title)
+	}
+
+	text := ast.NewString([]byte(link.Text))
+	text.SetRaw(true)
+	node.AppendChild(node, text)
+
+	return node
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScanWikiLink(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		inner string
+		n     int
+		ok    bool
+	}{
+		{"simple", "[[Page]] after", "Page", 8, true},
+		{"display text", "[[Page|text]]", "Page|text", 13, true},
+		{"escaped bracket", `[[a\]]b]]`, `a\]]b`, 9, true},
+		{"soft line break", "[[Long\nName]] rest", "Long\nName", 13, true},
+		{"three lines", "[[a\nb\nc]]", "a\nb\nc", 9, true},
+		{"too many lines", "[[a\nb\nc\nd]]", "", 0, false},
+		{"blank line", "[[a\n\nb]]", "", 0, false},
+		{"whitespace line", "[[a\n  \nb]]", "", 0, false},
+		{"unterminated", "[[Page", "", 0, false},
+		{"single bracket", "[[Page]", "", 0, false},
+		{"nested bracket", "[[a [b]]", "", 0, false},
+		{"at length limit", "[[" + strings.Repeat("a", maxWikiLinkLength-3) + "]]", strings.Repeat("a", maxWikiLinkLength-3), maxWikiLinkLength + 1, true},
+		{"too long", "[[" + strings.Repeat("a", maxWikiLinkLength) + "]]", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner, n, ok := scanWikiLink(tt.input)
+			if inner != tt.inner || n != tt.n || ok != tt.ok {
+				t.Errorf("scanWikiLink(%q) = %q, %d, %v, want %q, %d, %v", tt.input, inner, n, ok, tt.inner, tt.n, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseWikiLink(t *testing.T) {
+	tests := []struct {
+		inner string
+		want  wikiLink
+		ok    bool
+	}{
+		{"Page Name", wikiLink{Target: "Page Name", Text: "Page Name"}, true},
+		{"Page|the text", wikiLink{Target: "Page", Text: "the text"}, true},
+		{"Page|", wikiLink{Target: "Page", Text: "Page"}, true},
+		{"Page#Section", wikiLink{Target: "Page", Anchor: "Section", Text: "Page#Section"}, true},
+		{"#Section", wikiLink{Anchor: "Section", Text: "Section"}, true},
+		{"#Section|text", wikiLink{Anchor: "Section", Text: "text"}, true},
+		{"./child", wikiLink{Target: "./child", Text: "child"}, true},
+		{"../sibling#Top", wikiLink{Target: "../sibling", Anchor: "Top", Text: "sibling#Top"}, true},
+		{"Long\n  Name|wrapped\ntext", wikiLink{Target: "Long Name", Text: "wrapped text"}, true},
+		{`Page|a \| b`, wikiLink{Target: "Page", Text: "a | b"}, true},
+		{`C\#|C sharp`, wikiLink{Target: "C#", Text: "C sharp"}, true},
+		{"", wikiLink{}, false},
+		{"  |text", wikiLink{}, false},
+		{"#", wikiLink{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.inner, func(t *testing.T) {
+			got, ok := parseWikiLink(tt.inner)
+			if ok != tt.ok {
+				t.Fatalf("parseWikiLink(%q) ok = %v, want %v", tt.inner, ok, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("parseWikiLink(%q) = %+v, want %+v", tt.inner, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWikiTarget(t *testing.T) {
+	tests := []struct {
+		base   string
+		target string
+		want   string
+	}{
+		{"docs/setup", "Getting Started", "getting-started"},
+		{"docs/setup", "Linux/Ubuntu", "linux/ubuntu"},
+		{"docs/setup", "./Network Config", "docs/setup/network-config"},
+		{"docs/setup", "../install", "docs/install"},
+		{"docs/setup", "../../faq", "faq"},
+		{"docs/setup", "../.././faq", "faq"},
+		{"docs/setup", "../../../faq", ""},
+		{"docs", "./", ""},
+		{"", "./child", "child"},
+		{"", "../child", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base+" "+tt.target, func(t *testing.T) {
+			if got := resolveWikiTarget(tt.base, tt.target); got != tt.want {
+				t.Errorf("resolveWikiTarget(%q, %q) = %q, want %q", tt.base, tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderWikiLinks(t *testing.T) {
+	md := NewMarkdownService()
+
+	tests := []struct {
+		name     string
+		slug     string
+		markdown string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "page link",
+			slug:     "home",
+			markdown: "See [[Getting Started]].",
+			contains: []string{`<a href="/wiki/getting-started" title="Getting Started" rel="nofollow">Getting Started</a>`},
+		},
+		{
+			name:     "display text",
+			slug:     "home",
+			markdown: "[[Setup|the setup guide]]",
+			contains: []string{`<a href="/wiki/setup" title="Setup" rel="nofollow">the setup guide</a>`},
+		},
+		{
+			name:     "anchor on page",
+			slug:     "home",
+			markdown: "[[Setup#Install Steps]]",
+			contains: []string{`href="/wiki/setup#install-steps"`, `>Setup#Install Steps</a>`},
+		},
+		{
+			name:     "same page anchor",
+			slug:     "home",
+			markdown: "[[#Usage|usage]]",
+			contains: []string{`<a href="#usage" rel="nofollow">usage</a>`},
+		},
+		{
+			name:     "subpage",
+			slug:     "docs",
+			markdown: "[[./install]]",
+			contains: []string{`<a href="/wiki/docs/install" title="docs/install" rel="nofollow">install</a>`},
+		},
+		{
+			name:     "sibling",
+			slug:     "docs/setup",
+			markdown: "[[../faq|FAQ]]",
+			contains: []string{`<a href="/wiki/docs/faq" title="docs/faq" rel="nofollow">FAQ</a>`},
+		},
+		{
+			name:     "above the top",
+			slug:     "docs",
+			markdown: "[[../../faq]]",
+			contains: []string{"[[../../faq]]"},
+			excludes: []string{"<a "},
+		},
+		{
+			name:     "wrapped over lines",
+			slug:     "home",
+			markdown: "Read [[Getting\nStarted|the\nguide]] now.",
+			contains: []string{`<a href="/wiki/getting-started" title="Getting Started" rel="nofollow">the guide</a> now.`},
+		},
+		{
+			name:     "escaped",
+			slug:     "home",
+			markdown: `\[[Not a link]]`,
+			contains: []string{"[[Not a link]]"},
+			excludes: []string{"<a "},
+		},
+		{
+			name:     "unterminated",
+			slug:     "home",
+			markdown: "[[Not a link",
+			contains: []string{"[[Not a link"},
+			excludes: []string{"<a "},
+		},
+		{
+			name:     "too many lines",
+			slug:     "home",
+			markdown: "[[a\nb\nc\nd]]",
+			excludes: []string{"<a "},
+		},
+		{
+			name:     "too long",
+			slug:     "home",
+			markdown: "[[" + strings.Repeat("a", maxWikiLinkLength) + "]]",
+			excludes: []string{"<a "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := md.RenderPage(tt.slug, tt.markdown)
+			if err != nil {
+				t.Fatalf("RenderPage: %v", err)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(html, s) {
+					t.Errorf("rendered %q\nwant it to contain %q", html, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(html, s) {
+					t.Errorf("rendered %q\nwant it not to contain %q", html, s)
+				}
+			}
+		})
+	}
+}
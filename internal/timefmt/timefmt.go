@@ -0,0 +1,230 @@
+// Package timefmt formats timestamps for readers in their own timezone and
+// locale. Times are stored and exchanged in UTC; only what people read is
+// converted.
+package timefmt
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // zone names work on hosts without a zoneinfo database
+)
+
+// Locale holds the date and time layouts a locale writes timestamps with.
+type Locale struct {
+	Tag      string // BCP 47 language tag, e.g. "en-GB"
+	Name     string
+	Date     string // time layout of a date
+	DateTime string // time layout of a date and time of day
+}
+
+// Locales are the supported locales. The first is the default.
+var Locales = []Locale{
+	{Tag: "en-US", Name: "English (United States)", Date: "Jan 2, 2006", DateTime: "Jan 2, 2006, 3:04 PM"},
+	{Tag: "en-GB", Name: "English (United Kingdom)", Date: "2 Jan 2006", DateTime: "2 Jan 2006, 15:04"},
+	{Tag: "de-DE", Name: "Deutsch (Deutschland)", Date: "02.01.2006", DateTime: "02.01.2006, 15:04"},
+	{Tag: "fr-FR", Name: "Français (France)", Date: "02/01/2006", DateTime: "02/01/2006 15:04"},
+	{Tag: "es-ES", Name: "Español (España)", Date: "02/01/2006", DateTime: "02/01/2006, 15:04"},
+	{Tag: "nl-NL", Name: "Nederlands (Nederland)", Date: "02-01-2006", DateTime: "02-01-2006 15:04"},
+	{Tag: "sv-SE", Name: "Svenska (Sverige)", Date: "2006-01-02", DateTime: "2006-01-02 15:04"},
+	{Tag: "ja-JP", Name: "日本語 (日本)", Date: "2006/01/02", DateTime: "2006/01/02 15:04"},
+}
+
+// Zones are common timezones offered as suggestions. Any IANA zone name is
+// accepted.
+var Zones = []string{
+	"UTC",
+	"America/Los_Angeles", "America/Denver", "America/Chicago", "America/New_York",
+	"America/Toronto", "America/Mexico_City", "America/Sao_Paulo",
+	"Europe/London", "Europe/Dublin", "Europe/Lisbon", "Europe/Paris", "Europe/Berlin",
+	"Europe/Amsterdam", "Europe/Madrid", "Europe/Stockholm", "Europe/Warsaw",
+	"Europe/Helsinki", "Europe/Istanbul", "Europe/Moscow",
+	"Africa/Lagos", "Africa/Johannesburg", "Asia/Dubai", "Asia/Kolkata",
+	"Asia/Singapore", "Asia/Shanghai", "Asia/Tokyo", "Asia/Seoul",
+	"Australia/Sydney", "Pacific/Auckland",
+}
+
+// FindLocale returns the supported locale with tag, ignoring case.
+func FindLocale(tag string) (Locale, bool) {
+	for _, l := range Locales {
+		if strings.EqualFold(l.Tag, tag) {
+			return l, true
+		}
+	}
+	return Locale{}, false
+}
+
+// ValidZone reports whether name is an IANA timezone name.
+func ValidZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// Formatter formats times in one timezone and locale.
+type Formatter struct {
+	loc    *time.Location
+	locale Locale
+}
+
+// New returns a formatter for the zone and locale, falling back to UTC and
+// the default locale for ones that are empty or unknown.
+func New(zone, locale string) Formatter {
+	f := Formatter{loc: time.UTC, locale: Locales[0]}
+	if ValidZone(zone) {
+		f.loc, _ = time.LoadLocation(zone)
+	}
+	if l, ok := FindLocale(locale); ok {
+		f.locale = l
+	}
+	return f
+}
+
+// Zone returns the formatter's timezone name.
+func (f Formatter) Zone() string {
+	return f.loc.String()
+}
+
+// Locale returns the formatter's locale.
+func (f Formatter) Locale() Locale {
+	return f.locale
+}
+
+// Date formats the day t falls on in the reader's zone.
+func (f Formatter) Date(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.loc).Format(f.locale.Date)
+}
+
+// DateTime formats t with its time of day in the reader's zone.
+func (f Formatter) DateTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.loc).Format(f.locale.DateTime)
+}
+
+// Full formats t like DateTime followed by the zone's abbreviation, for
+// tooltips that should leave no doubt.
+func (f Formatter) Full(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return f.DateTime(t) + " " + t.In(f.loc).Format("MST")
+}
+
+// ISO formats t as RFC 3339 with the reader's UTC offset.
+func (f Formatter) ISO(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.loc).Format(time.RFC3339)
+}
+
+// inputLayout is the value format of an HTML datetime-local input.
+const inputLayout = "2006-01-02T15:04"
+
+// Input formats t as the value of a datetime-local input in the reader's
+// zone.
+func (f Formatter) Input(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(f.loc).Format(inputLayout)
+}
+
+// ParseInput reads the value of a datetime-local input as a time in the
+// reader's zone.
+func (f Formatter) ParseInput(value string) (time.Time, error) {
+	return time.ParseInLocation(inputLayout, value, f.loc)
+}
+
+// Relative describes t relative to now, e.g. "2 hours ago" or "in 3 days",
+// and falls back to the date for times more than a year away.
+func (f Formatter) Relative(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	diff := time.Since(t)
+	future := diff < 0
+	if future {
+		diff = -diff
+	}
+
+	var n int
+	var unit string
+	switch {
+	case diff < time.Minute:
+		return "just now"
+	case diff < time.Hour:
+		n, unit = int(diff.Minutes()), "minute"
+	case diff < 24*time.Hour:
+		n, unit = int(diff.Hours()), "hour"
+	case diff < 7*24*time.Hour:
+		n, unit = int(diff.Hours()/24), "day"
+		if n == 1 {
+			if future {
+				return "tomorrow"
+			}
+			return "yesterday"
+		}
+	case diff < 30*24*time.Hour:
+		n, unit = int(diff.Hours()/24/7), "week"
+	case diff < 365*24*time.Hour:
+		n, unit = int(diff.Hours()/24/30), "month"
+	default:
+		return f.Date(t)
+	}
+
+	s := strconv.Itoa(n) + " " + unit
+	if n != 1 {
+		s += "s"
+	}
+	if future {
+		return "in " + s
+	}
+	return s + " ago"
+}
+
+// MatchLocale returns the tag of the supported locale that best matches an
+// Accept-Language header, or "" when none does.
+func MatchLocale(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		if l, ok := FindLocale(tag); ok {
+			return l.Tag
+		}
+		lang, _, _ := strings.Cut(tag, "-")
+		for _, l := range Locales {
+			if strings.EqualFold(strings.SplitN(l.Tag, "-", 2)[0], lang) {
+				return l.Tag
+			}
+		}
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// WithFormatter returns a context carrying f for the templates rendered
+// with it.
+func WithFormatter(ctx context.Context, f Formatter) context.Context {
+	return context.WithValue(ctx, contextKey{}, f)
+}
+
+// FromContext returns the formatter ctx carries, or one for UTC and the
+// default locale.
+func FromContext(ctx context.Context) Formatter {
+	if f, ok := ctx.Value(contextKey{}).(Formatter); ok {
+		return f
+	}
+	return New("", "")
+}
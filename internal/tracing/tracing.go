@@ -0,0 +1,73 @@
+// Package tracing sets up OpenTelemetry request tracing. Spans follow the
+// W3C Trace Context format and are exported to an OTLP/HTTP collector.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the wiki's tracer.
+const instrumentationName = "gowiki"
+
+var enabled atomic.Bool
+
+// Enabled reports whether Init installed a provider, so callers can skip
+// building span attributes when nothing would record them.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Tracer returns the wiki's tracer. Its spans are no-ops until Init runs.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Init installs a global provider exporting to endpoint (e.g.
+// http://collector:4318). ratio is the fraction of new traces to sample;
+// incoming traces keep the sampling decision of their parent.
+func Init(ctx context.Context, endpoint, serviceName string, ratio float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(strings.TrimSuffix(endpoint, "/")+"/v1/traces"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled.Store(true)
+	return provider, nil
+}
+
+// RecordError records err on span and marks the span as failed. A nil err
+// is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,9 @@
+// Starts Swagger UI on the API docs page.
+window.addEventListener("load", function () {
+  window.ui = SwaggerUIBundle({
+    url: "/api/v1/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    persistAuthorization: true
+  });
+});